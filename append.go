@@ -0,0 +1,59 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+// NewAppendEncoder returns an encoder that appends to buf, keeping its
+// contents, e.g. a header written by other code before the encoded values.
+// Bytes returns buf followed by what the encoder wrote; like the append
+// built-in, the encoder writes in place while buf has spare capacity, and
+// moves it to a larger array when it runs out of room, so that a large
+// enough buf is never copied.
+//
+// Written, and the limit set by WithMaxEncodedSize, only count the bytes
+// written by the encoder, not the initial contents of buf.
+func NewAppendEncoder(buf []byte, enc Encoding) *Encoder {
+	w := &appendWriter{buf: buf}
+	e := NewEncoderWithEncoding(w, enc)
+	e.appendTo = w
+	return e
+}
+
+// Bytes returns the buffer of an encoder created with NewAppendEncoder: its
+// initial contents followed by the bytes written so far. It aliases the buffer,
+// which later writes may modify or move. It returns nil for other encoders.
+func (e *Encoder) Bytes() []byte {
+	if e.appendTo == nil {
+		return nil
+	}
+	return e.appendTo.buf
+}
+
+// MarshalAppend appends the encoding of v to buf, and returns the extended buffer.
+func MarshalAppend(buf []byte, v interface{}, enc Encoding) ([]byte, error) {
+	e := NewAppendEncoder(buf, enc)
+	if err := e.Encode(v); err != nil {
+		return buf, err
+	}
+	return e.Bytes(), nil
+}
+
+type appendWriter struct {
+	buf []byte
+}
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendEncoder(t *testing.T) {
+	header := make([]byte, 2, 64)
+	header[0], header[1] = 0xca, 0xfe
+
+	e := NewAppendEncoder(header, EncodingBorsh)
+	require.NoError(t, e.Encode(uint16(1)))
+	require.NoError(t, e.Encode("ab"))
+
+	out := e.Bytes()
+	assert.Equal(t, []byte{0xca, 0xfe, 1, 0, 2, 0, 0, 0, 'a', 'b'}, out)
+	assert.Equal(t, 8, e.Written())
+	// There was room in header: the encoder wrote in place.
+	assert.Equal(t, &header[0], &out[0])
+
+	// The initial contents don't count towards the maximum size.
+	e = NewAppendEncoder(make([]byte, 10), EncodingBorsh).WithMaxEncodedSize(4)
+	require.NoError(t, e.Encode(uint32(1)))
+	assert.Len(t, e.Bytes(), 14)
+
+	assert.Nil(t, NewBorshEncoder(new(bytes.Buffer)).Bytes())
+}
+
+func TestMarshalAppend(t *testing.T) {
+	type record struct {
+		A uint8
+		B []byte
+	}
+	in := record{A: 7, B: []byte{1, 2}}
+	want, err := MarshalBin(&in)
+	require.NoError(t, err)
+
+	out, err := MarshalAppend([]byte("hdr"), &in, EncodingBin)
+	require.NoError(t, err)
+	assert.Equal(t, append([]byte("hdr"), want...), out)
+
+	var got record
+	require.NoError(t, UnmarshalBin(&got, out[3:]))
+	assert.Equal(t, in, got)
+}
@@ -0,0 +1,475 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// ArrowType is the Arrow data type of a column, named as by Arrow,
+// e.g. "int64", "utf8" or "fixed_size_binary[16]".
+type ArrowType string
+
+const (
+	ArrowBool      ArrowType = "bool"
+	ArrowInt8      ArrowType = "int8"
+	ArrowInt16     ArrowType = "int16"
+	ArrowInt32     ArrowType = "int32"
+	ArrowInt64     ArrowType = "int64"
+	ArrowUint8     ArrowType = "uint8"
+	ArrowUint16    ArrowType = "uint16"
+	ArrowUint32    ArrowType = "uint32"
+	ArrowUint64    ArrowType = "uint64"
+	ArrowFloat16   ArrowType = "float16"
+	ArrowFloat32   ArrowType = "float32"
+	ArrowFloat64   ArrowType = "float64"
+	ArrowUTF8      ArrowType = "utf8"
+	ArrowBinary    ArrowType = "binary"
+	ArrowTimestamp ArrowType = "timestamp[ns, tz=UTC]"
+)
+
+// ArrowFixedSizeBinary returns the type of the columns of n-byte values.
+func ArrowFixedSizeBinary(n int) ArrowType {
+	return ArrowType("fixed_size_binary[" + strconv.Itoa(n) + "]")
+}
+
+// An ArrowField describes a column of an ArrowRecordBatch.
+type ArrowField struct {
+	// Name is the path of the struct field, e.g. "Header.Slot".
+	Name     string
+	Type     ArrowType
+	Nullable bool
+}
+
+// An ArrowColumn holds the buffers of a column, laid out as by the Arrow
+// columnar format, so that they can be handed as they are to an Arrow library.
+type ArrowColumn struct {
+	Length    int
+	NullCount int
+	// Validity is the validity bitmap (bit i is set if the value i is not null),
+	// or nil if there are no nulls.
+	Validity []byte
+	// Offsets are the Length+1 offsets of the values of utf8 and binary
+	// columns in Values; they are nil for the other columns.
+	Offsets []int32
+	// Values are the little endian values of fixed-size columns, a bitmap for
+	// bool columns, and the concatenated values of utf8 and binary columns.
+	Values []byte
+}
+
+// An ArrowRecordBatch is a set of equal-length columns, one per field of the schema.
+type ArrowRecordBatch struct {
+	Schema  []ArrowField
+	Columns []*ArrowColumn
+	Length  int
+}
+
+// An ArrowExporter maps values of a struct type to Arrow record batches,
+// with a column for each encoded field, using the layout described by
+// ExplainType:
+//
+//   - nested structs are flattened into "Parent.Child" columns;
+//
+//   - skipped, reserved and interface fields have no column;
+//
+//   - optional fields and pointers are nullable;
+//
+//   - integers, floats, bools, strings, byte slices and byte arrays map to the
+//     matching Arrow types, int and uint to the size set by their width tag,
+//     and time.Time to nanosecond timestamps;
+//
+//   - any other field (slices, maps, enums, custom types, ...) is stored as
+//     its encoding, in a fixed_size_binary column if it has a fixed size,
+//     or else in a binary column.
+//
+// For example:
+//
+//	exporter, err := bin.NewArrowExporter(Transfer{}, bin.EncodingBin)
+//	for _, transfer := range transfers {
+//		err = exporter.Append(transfer)
+//	}
+//	batch := exporter.Flush()
+type ArrowExporter struct {
+	rt       reflect.Type
+	encoding Encoding
+	schema   []ArrowField
+	columns  []*arrowColumnBuilder
+	length   int
+}
+
+type arrowColumnBuilder struct {
+	// path are the indices of the struct fields leading to the value.
+	path  []int
+	node  *layoutNode
+	typ   ArrowType
+	width int
+	// encoded is set for the columns that hold the encoding of the values.
+	encoded bool
+	col     *ArrowColumn
+}
+
+// NewArrowExporter returns an exporter of values of the type of `v` (a struct
+// or a pointer to a struct); values that are stored as their encoding
+// are encoded with the provided encoding.
+func NewArrowExporter(v interface{}, enc Encoding) (*ArrowExporter, error) {
+	rt := reflect.TypeOf(v)
+	if rt == nil {
+		return nil, fmt.Errorf("arrow: nil type")
+	}
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("arrow: expected a struct, got %s", rt)
+	}
+	if !isValidEncoding(enc) {
+		return nil, fmt.Errorf("arrow: invalid encoding %d", enc)
+	}
+	layout, err := describeType(rt, enc)
+	if err != nil {
+		return nil, fmt.Errorf("arrow: %s: %w", rt, err)
+	}
+	x := &ArrowExporter{
+		rt:       rt,
+		encoding: enc,
+	}
+	if layout.Wire != wireStruct || layout.Recursive {
+		return nil, fmt.Errorf("arrow: %s is encoded as %s, not as a struct", rt, layout.Wire)
+	}
+	x.addColumns("", nil, rt, layout, false)
+	x.Flush()
+	return x, nil
+}
+
+func (x *ArrowExporter) addColumns(prefix string, path []int, rt reflect.Type, n *layoutNode, nullable bool) {
+	for _, field := range n.Fields {
+		if field.Wire == wireReserved || field.Wire == wireNothing {
+			continue
+		}
+		plan := planOf(rt)
+		structField := plan.fields[plan.fieldIndex(field.Name)]
+		fieldPath := append(append([]int(nil), path...), structField.Index...)
+		fieldNullable := nullable || field.Presence != presenceNone || structField.Type.Kind() == reflect.Ptr
+		name := prefix + field.Name
+		if field.Wire == wireStruct && !field.Recursive && len(field.Fields) > 0 {
+			x.addColumns(name+".", fieldPath, field.Type, field, fieldNullable)
+			continue
+		}
+		typ, width, encoded := arrowTypeOf(field)
+		x.schema = append(x.schema, ArrowField{Name: name, Type: typ, Nullable: fieldNullable})
+		x.columns = append(x.columns, &arrowColumnBuilder{
+			path:    fieldPath,
+			node:    field,
+			typ:     typ,
+			width:   width,
+			encoded: encoded,
+		})
+	}
+}
+
+// arrowTypeOf returns the Arrow type of a field, the size of its values
+// if they have a fixed size of more than a bit, and whether they are
+// stored as their encoding.
+func arrowTypeOf(n *layoutNode) (typ ArrowType, width int, encoded bool) {
+	rt := n.Type
+	if rt == timeType {
+		return ArrowTimestamp, TypeSize.Uint64, false
+	}
+	if rt == float16Type {
+		return ArrowFloat16, TypeSize.Float16, false
+	}
+	switch rt.Kind() {
+	case reflect.Bool:
+		return ArrowBool, 0, false
+	case reflect.Int8:
+		return ArrowInt8, 1, false
+	case reflect.Int16:
+		return ArrowInt16, 2, false
+	case reflect.Int32:
+		return ArrowInt32, 4, false
+	case reflect.Int64:
+		return ArrowInt64, 8, false
+	case reflect.Uint8:
+		return ArrowUint8, 1, false
+	case reflect.Uint16:
+		return ArrowUint16, 2, false
+	case reflect.Uint32:
+		return ArrowUint32, 4, false
+	case reflect.Uint64:
+		return ArrowUint64, 8, false
+	case reflect.Int:
+		if n.valueSize() == TypeSize.Uint32 {
+			return ArrowInt32, 4, false
+		}
+		return ArrowInt64, 8, false
+	case reflect.Uint:
+		if n.valueSize() == TypeSize.Uint32 {
+			return ArrowUint32, 4, false
+		}
+		return ArrowUint64, 8, false
+	case reflect.Float32:
+		return ArrowFloat32, 4, false
+	case reflect.Float64:
+		return ArrowFloat64, 8, false
+	case reflect.String:
+		return ArrowUTF8, 0, false
+	case reflect.Slice:
+		if rt.Elem().Kind() == reflect.Uint8 {
+			return ArrowBinary, 0, false
+		}
+	case reflect.Array:
+		if rt.Elem().Kind() == reflect.Uint8 {
+			return ArrowFixedSizeBinary(rt.Len()), rt.Len(), false
+		}
+	}
+	if size := n.valueSize(); size > 0 {
+		return ArrowFixedSizeBinary(size), size, true
+	}
+	return ArrowBinary, 0, true
+}
+
+// Schema returns the fields of the record batches.
+func (x *ArrowExporter) Schema() []ArrowField {
+	return append([]ArrowField(nil), x.schema...)
+}
+
+// Len returns the number of values appended since the last Flush.
+func (x *ArrowExporter) Len() int {
+	return x.length
+}
+
+// Append adds a value (of the type of the exporter, or a pointer to it) as a row.
+func (x *ArrowExporter) Append(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return fmt.Errorf("arrow: nil %s", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+	if rv.Type() != x.rt {
+		return fmt.Errorf("arrow: expected %s, got %s", x.rt, rv.Type())
+	}
+	for i, c := range x.columns {
+		if err := c.append(rv, x.encoding); err != nil {
+			// Keep the columns the same length.
+			for _, done := range x.columns[:i] {
+				done.truncate(x.length)
+			}
+			return fmt.Errorf("arrow: field %q: %w", x.schema[i].Name, err)
+		}
+	}
+	x.length++
+	return nil
+}
+
+// AppendFrom decodes a value of the type of the exporter and adds it as a row,
+// e.g. to export a stream of encoded records without keeping them:
+//
+//	for dec.HasRemaining() {
+//		if err := exporter.AppendFrom(dec); err != nil {
+//			return err
+//		}
+//	}
+func (x *ArrowExporter) AppendFrom(dec *Decoder) error {
+	rv := reflect.New(x.rt)
+	if err := dec.Decode(rv.Interface()); err != nil {
+		return err
+	}
+	return x.Append(rv.Interface())
+}
+
+// Flush returns the rows appended since the last Flush as a record batch.
+func (x *ArrowExporter) Flush() *ArrowRecordBatch {
+	batch := &ArrowRecordBatch{
+		Schema: x.Schema(),
+		Length: x.length,
+	}
+	for _, c := range x.columns {
+		col := c.col
+		if col != nil && col.NullCount == 0 {
+			col.Validity = nil
+		}
+		batch.Columns = append(batch.Columns, col)
+		c.col = &ArrowColumn{}
+		if c.width == 0 && c.typ != ArrowBool {
+			c.col.Offsets = []int32{0}
+		}
+	}
+	x.length = 0
+	return batch
+}
+
+// ExportArrow maps a slice of structs (or of pointers to structs)
+// to a record batch; see ArrowExporter.
+func ExportArrow(slice interface{}, enc Encoding) (*ArrowRecordBatch, error) {
+	rv := reflect.ValueOf(slice)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("arrow: expected a slice, got %T", slice)
+	}
+	x, err := NewArrowExporter(reflect.Zero(rv.Type().Elem()).Interface(), enc)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if err := x.Append(rv.Index(i).Interface()); err != nil {
+			return nil, newElementError("exporting", i, err)
+		}
+	}
+	return x.Flush(), nil
+}
+
+// lookup returns the value of the column in a struct, or false if it's null.
+func (c *arrowColumnBuilder) lookup(rv reflect.Value) (reflect.Value, bool) {
+	for _, i := range c.path {
+		for rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return rv, false
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(i)
+	}
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return rv, false
+		}
+		rv = rv.Elem()
+	}
+	return rv, true
+}
+
+func setBit(bitmap []byte, i int, set bool) []byte {
+	if i%8 == 0 {
+		bitmap = append(bitmap, 0)
+	}
+	if set {
+		bitmap[i/8] |= 1 << uint(i%8)
+	}
+	return bitmap
+}
+
+func (c *arrowColumnBuilder) append(rv reflect.Value, enc Encoding) error {
+	col := c.col
+	rv, ok := c.lookup(rv)
+	var value []byte
+	if ok {
+		var err error
+		if value, err = c.value(rv, enc); err != nil {
+			return err
+		}
+	} else {
+		col.NullCount++
+	}
+	col.Validity = setBit(col.Validity, col.Length, ok)
+	switch {
+	case c.typ == ArrowBool:
+		col.Values = setBit(col.Values, col.Length, ok && rv.Bool())
+	case c.width > 0:
+		if !ok {
+			value = make([]byte, c.width)
+		}
+		if len(value) != c.width {
+			return fmt.Errorf("expected %d bytes, got %d", c.width, len(value))
+		}
+		col.Values = append(col.Values, value...)
+	default:
+		col.Values = append(col.Values, value...)
+		if int64(len(col.Values)) > math.MaxInt32 {
+			return fmt.Errorf("column exceeds %d bytes", math.MaxInt32)
+		}
+		col.Offsets = append(col.Offsets, int32(len(col.Values)))
+	}
+	col.Length++
+	return nil
+}
+
+// value returns the bytes of a non-null value.
+func (c *arrowColumnBuilder) value(rv reflect.Value, enc Encoding) ([]byte, error) {
+	if c.encoded {
+		encoded := new(bytes.Buffer)
+		if err := NewEncoderWithEncoding(encoded, enc).Encode(rv.Interface()); err != nil {
+			return nil, err
+		}
+		return encoded.Bytes(), nil
+	}
+	buf := make([]byte, c.width)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return nil, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		putArrowUint(buf, uint64(rv.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		putArrowUint(buf, rv.Uint())
+	case reflect.Float32:
+		binary.LittleEndian.PutUint32(buf, math.Float32bits(float32Value(rv)))
+	case reflect.Float64:
+		binary.LittleEndian.PutUint64(buf, math.Float64bits(rv.Float()))
+	case reflect.String:
+		return []byte(rv.String()), nil
+	case reflect.Slice:
+		return rv.Bytes(), nil
+	case reflect.Array:
+		reflect.Copy(reflect.ValueOf(buf), rv)
+	case reflect.Struct:
+		putArrowUint(buf, uint64(rv.Interface().(time.Time).UnixNano()))
+	}
+	return buf, nil
+}
+
+func putArrowUint(buf []byte, v uint64) {
+	for i := range buf {
+		buf[i] = byte(v >> (8 * uint(i)))
+	}
+}
+
+func (c *arrowColumnBuilder) truncate(length int) {
+	col := c.col
+	if col.Length == length {
+		return
+	}
+	if !bitSet(col.Validity, length) {
+		col.NullCount--
+	}
+	col.Validity = truncateBitmap(col.Validity, length)
+	switch {
+	case c.typ == ArrowBool:
+		col.Values = truncateBitmap(col.Values, length)
+	case c.width > 0:
+		col.Values = col.Values[:length*c.width]
+	default:
+		col.Offsets = col.Offsets[:length+1]
+		col.Values = col.Values[:col.Offsets[length]]
+	}
+	col.Length = length
+}
+
+func bitSet(bitmap []byte, i int) bool {
+	return bitmap[i/8]&(1<<uint(i%8)) != 0
+}
+
+func truncateBitmap(bitmap []byte, length int) []byte {
+	bitmap = bitmap[:(length+7)/8]
+	if length%8 != 0 {
+		bitmap[length/8] &= byte(1)<<uint(length%8) - 1
+	}
+	return bitmap
+}
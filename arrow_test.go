@@ -0,0 +1,171 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type arrowHeader struct {
+	Slot uint64
+	Ok   bool
+}
+
+type arrowRecord struct {
+	Header   arrowHeader
+	Amount   int32
+	Count    int `bin:"width=4"`
+	Name     string
+	Memo     *string `bin:"optional"`
+	Key      [4]byte
+	Data     []byte
+	Tags     []uint16
+	Value    Uint128
+	At       time.Time
+	Internal string  `bin:"-"`
+	Pad      [2]byte `bin:"reserved=2"`
+}
+
+func arrowRecords() []arrowRecord {
+	memo := "hello"
+	at := time.Unix(1700000000, 5).UTC()
+	return []arrowRecord{
+		{
+			Header: arrowHeader{Slot: 1, Ok: true},
+			Amount: -1,
+			Count:  7,
+			Name:   "a",
+			Memo:   &memo,
+			Key:    [4]byte{1, 2, 3, 4},
+			Data:   []byte{0xff},
+			Tags:   []uint16{1, 2},
+			Value:  Uint128{Lo: 1},
+			At:     at,
+		},
+		{
+			Header: arrowHeader{Slot: 2},
+			Amount: 2,
+			Name:   "bc",
+			At:     at,
+		},
+	}
+}
+
+func TestExportArrow(t *testing.T) {
+	batch, err := ExportArrow(arrowRecords(), EncodingBin)
+	require.NoError(t, err)
+	require.Equal(t, 2, batch.Length)
+
+	assert.Equal(t, []ArrowField{
+		{Name: "Header.Slot", Type: ArrowUint64},
+		{Name: "Header.Ok", Type: ArrowBool},
+		{Name: "Amount", Type: ArrowInt32},
+		{Name: "Count", Type: ArrowInt32},
+		{Name: "Name", Type: ArrowUTF8},
+		{Name: "Memo", Type: ArrowUTF8, Nullable: true},
+		{Name: "Key", Type: ArrowFixedSizeBinary(4)},
+		{Name: "Data", Type: ArrowBinary},
+		{Name: "Tags", Type: ArrowBinary},
+		{Name: "Value", Type: ArrowFixedSizeBinary(16)},
+		{Name: "At", Type: ArrowTimestamp},
+	}, batch.Schema)
+	require.Len(t, batch.Columns, len(batch.Schema))
+	for _, col := range batch.Columns {
+		assert.Equal(t, 2, col.Length)
+	}
+
+	col := func(name string) *ArrowColumn {
+		for i, field := range batch.Schema {
+			if field.Name == name {
+				return batch.Columns[i]
+			}
+		}
+		t.Fatalf("no column %q", name)
+		return nil
+	}
+
+	assert.Equal(t, []byte{1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0}, col("Header.Slot").Values)
+	assert.Nil(t, col("Header.Slot").Validity)
+	assert.Equal(t, []byte{0x01}, col("Header.Ok").Values)
+	assert.Equal(t, []byte{0xff, 0xff, 0xff, 0xff, 2, 0, 0, 0}, col("Amount").Values)
+	assert.Equal(t, []byte{7, 0, 0, 0, 0, 0, 0, 0}, col("Count").Values)
+
+	name := col("Name")
+	assert.Equal(t, []int32{0, 1, 3}, name.Offsets)
+	assert.Equal(t, []byte("abc"), name.Values)
+
+	memo := col("Memo")
+	assert.Equal(t, 1, memo.NullCount)
+	assert.Equal(t, []byte{0x01}, memo.Validity)
+	assert.Equal(t, []int32{0, 5, 5}, memo.Offsets)
+
+	assert.Equal(t, []byte{1, 2, 3, 4, 0, 0, 0, 0}, col("Key").Values)
+
+	tags, err := MarshalBin([]uint16{1, 2})
+	require.NoError(t, err)
+	empty, err := MarshalBin([]uint16(nil))
+	require.NoError(t, err)
+	assert.Equal(t, append(tags, empty...), col("Tags").Values)
+
+	at := col("At").Values
+	assert.Equal(t, int64(1700000000000000005), int64(binary.LittleEndian.Uint64(at)))
+}
+
+func TestArrowExporter(t *testing.T) {
+	x, err := NewArrowExporter(&arrowRecord{}, EncodingBorsh)
+	require.NoError(t, err)
+
+	t.Run("from decoder", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		enc := NewBorshEncoder(buf)
+		for _, record := range arrowRecords() {
+			require.NoError(t, enc.Encode(record))
+		}
+		dec := NewBorshDecoder(buf.Bytes())
+		for dec.HasRemaining() {
+			require.NoError(t, x.AppendFrom(dec))
+		}
+		assert.Equal(t, 2, x.Len())
+
+		batch := x.Flush()
+		assert.Equal(t, 2, batch.Length)
+		assert.Equal(t, 0, x.Len())
+		assert.Equal(t, []byte("abc"), batch.Columns[4].Values)
+	})
+
+	t.Run("flush starts a new batch", func(t *testing.T) {
+		require.NoError(t, x.Append(arrowRecords()[1]))
+		batch := x.Flush()
+		assert.Equal(t, 1, batch.Length)
+		assert.Equal(t, []int32{0, 2}, batch.Columns[4].Offsets)
+		assert.Equal(t, []byte{0}, batch.Columns[5].Validity)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		assert.Error(t, x.Append(arrowHeader{}))
+		_, err := NewArrowExporter(uint64(0), EncodingBin)
+		assert.Error(t, err)
+		_, err = NewArrowExporter(struct{ N int }{}, EncodingBin)
+		assert.Error(t, err)
+		_, err = ExportArrow(arrowRecord{}, EncodingBin)
+		assert.Error(t, err)
+	})
+}
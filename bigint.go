@@ -0,0 +1,154 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+// ErrNonCanonicalBigInt is returned by decoders in canonical mode for
+// length-prefixed big.Int values whose magnitude has leading zero bytes,
+// or that are negative zeros.
+var ErrNonCanonicalBigInt = errors.New("non-canonical big.Int")
+
+var (
+	bigIntType    = reflect.TypeOf(big.Int{})
+	bigIntPtrType = reflect.TypeOf(&big.Int{})
+)
+
+// Signs of length-prefixed big.Int values.
+const (
+	bigIntPositive byte = 0
+	bigIntNegative byte = 1
+)
+
+// bigIntValue returns the big.Int of a big.Int or *big.Int value;
+// nil pointers are zero.
+func bigIntValue(rv reflect.Value) (*big.Int, bool) {
+	switch rv.Type() {
+	case bigIntPtrType:
+		if rv.IsNil() {
+			return new(big.Int), true
+		}
+		return rv.Interface().(*big.Int), true
+	case bigIntType:
+		if rv.CanAddr() {
+			return rv.Addr().Interface().(*big.Int), true
+		}
+		v := rv.Interface().(big.Int)
+		return &v, true
+	default:
+		return nil, false
+	}
+}
+
+// encodeBigInt writes a big.Int or *big.Int value: as a `width=N` bytes two's
+// complement integer in the byte order of the field when it has a width,
+// otherwise as a sign byte (1 for negative values) followed by the
+// length-prefixed big-endian bytes of its absolute value.
+func (e *Encoder) encodeBigInt(rv reflect.Value, opt *option) (bool, error) {
+	x, ok := bigIntValue(rv)
+	if !ok {
+		return false, nil
+	}
+	if opt.Width > 0 {
+		buf, err := bigIntToFixed(x, opt.Width, widthOrder(e.encoding, opt))
+		if err != nil {
+			return true, err
+		}
+		return true, e.WriteBytes(buf, false)
+	}
+	sign := bigIntPositive
+	if x.Sign() < 0 {
+		sign = bigIntNegative
+	}
+	if err := e.WriteByte(sign); err != nil {
+		return true, err
+	}
+	return true, e.WriteBytes(x.Bytes(), true)
+}
+
+func (dec *Decoder) decodeBigInt(rv reflect.Value, opt *option) (bool, error) {
+	if rv.Type() != bigIntType {
+		return false, nil
+	}
+	x := rv.Addr().Interface().(*big.Int)
+	if opt.Width > 0 {
+		buf, err := dec.ReadNBytes(opt.Width)
+		if err != nil {
+			return true, err
+		}
+		bigIntFromFixed(x, buf, widthOrder(dec.encoding, opt))
+		return true, nil
+	}
+	sign, err := dec.ReadByte()
+	if err != nil {
+		return true, err
+	}
+	if sign != bigIntPositive && sign != bigIntNegative {
+		return true, fmt.Errorf("invalid big.Int sign byte %d", sign)
+	}
+	magnitude, err := dec.ReadByteSlice()
+	if err != nil {
+		return true, err
+	}
+	if dec.canonical && len(magnitude) > 0 && magnitude[0] == 0 {
+		return true, fmt.Errorf("%w: leading zero bytes", ErrNonCanonicalBigInt)
+	}
+	x.SetBytes(magnitude)
+	if sign == bigIntNegative {
+		if dec.canonical && x.Sign() == 0 {
+			return true, fmt.Errorf("%w: negative zero", ErrNonCanonicalBigInt)
+		}
+		x.Neg(x)
+	}
+	return true, nil
+}
+
+// bigIntToFixed returns the width bytes two's complement of x.
+func bigIntToFixed(x *big.Int, width int, order binary.ByteOrder) ([]byte, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(8*width-1))
+	if x.Cmp(limit) >= 0 || x.Cmp(new(big.Int).Neg(limit)) < 0 {
+		return nil, fmt.Errorf("big.Int %s overflows %d bytes", x, width)
+	}
+	v := x
+	if x.Sign() < 0 {
+		v = new(big.Int).Add(x, new(big.Int).Lsh(limit, 1))
+	}
+	b := v.Bytes()
+	buf := make([]byte, width)
+	copy(buf[width-len(b):], b)
+	if order == LE {
+		ReverseBytes(buf)
+	}
+	return buf, nil
+}
+
+// bigIntFromFixed sets x to the value of the two's complement in buf.
+func bigIntFromFixed(x *big.Int, buf []byte, order binary.ByteOrder) {
+	be := make([]byte, len(buf))
+	copy(be, buf)
+	if order == LE {
+		ReverseBytes(be)
+	}
+	x.SetBytes(be)
+	if be[0]&0x80 != 0 {
+		x.Sub(x, new(big.Int).Lsh(big.NewInt(1), uint(8*len(be))))
+	}
+}
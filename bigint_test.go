@@ -0,0 +1,113 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bigBalances struct {
+	Supply   *big.Int
+	Debt     big.Int
+	Fixed    *big.Int   `bin:"width=32"`
+	FixedBE  *big.Int   `bin:"width=16 big"`
+	Pending  *big.Int   `bin:"optional"`
+	Missing  *big.Int   `bin:"optional"`
+	Deltas   []*big.Int `bin:"width=8"`
+	Unset    *big.Int
+	Trailing uint8
+}
+
+func TestBigInt(t *testing.T) {
+	huge, ok := new(big.Int).SetString("123456789012345678901234567890123456789", 10)
+	require.True(t, ok)
+	in := bigBalances{
+		Supply:   huge,
+		Debt:     *big.NewInt(-1000),
+		Fixed:    new(big.Int).Neg(huge),
+		FixedBE:  big.NewInt(-2),
+		Pending:  big.NewInt(7),
+		Deltas:   []*big.Int{big.NewInt(-1), big.NewInt(256)},
+		Trailing: 9,
+	}
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&in), enc)
+
+		var out bigBalances
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out), enc)
+		assert.Equal(t, 0, in.Supply.Cmp(out.Supply), enc)
+		assert.Equal(t, 0, in.Debt.Cmp(&out.Debt), enc)
+		assert.Equal(t, 0, in.Fixed.Cmp(out.Fixed), enc)
+		assert.Equal(t, 0, in.FixedBE.Cmp(out.FixedBE), enc)
+		assert.Equal(t, 0, in.Pending.Cmp(out.Pending), enc)
+		assert.Nil(t, out.Missing, enc)
+		require.Len(t, out.Deltas, 2, enc)
+		assert.Equal(t, int64(-1), out.Deltas[0].Int64(), enc)
+		assert.Equal(t, int64(256), out.Deltas[1].Int64(), enc)
+		assert.Equal(t, 0, out.Unset.Sign(), enc)
+		assert.Equal(t, uint8(9), out.Trailing, enc)
+		assert.True(t, EqualWire(in, out), enc)
+	}
+}
+
+func TestBigInt_Wire(t *testing.T) {
+	type signed struct {
+		V *big.Int
+	}
+	data := mustMarshalBin(t, signed{V: big.NewInt(-0x1234)})
+	assert.Equal(t, []byte{1, 2, 0x12, 0x34}, data)
+
+	type fixed struct {
+		LE *big.Int `bin:"width=4"`
+		BE *big.Int `bin:"width=4 big"`
+	}
+	data = mustMarshalBin(t, fixed{LE: big.NewInt(-2), BE: big.NewInt(0x0102)})
+	assert.Equal(t, []byte{0xfe, 0xff, 0xff, 0xff, 0, 0, 1, 2}, data)
+
+	// Fixed-width values are signed.
+	_, err := MarshalBin(fixed{LE: big.NewInt(1 << 31), BE: big.NewInt(0)})
+	assert.EqualError(t, err, `error while encoding "LE" field: big.Int 2147483648 overflows 4 bytes`)
+
+	var out signed
+	assert.EqualError(t, UnmarshalBin(&out, []byte{2, 0}), `error while decoding "V" field: invalid big.Int sign byte 2`)
+
+	require.NoError(t, UnmarshalBin(&out, []byte{0, 2, 0, 1}))
+	assert.Equal(t, int64(1), out.V.Int64())
+	err = NewBinDecoder([]byte{0, 2, 0, 1}).WithCanonicalMode().Decode(&out)
+	assert.ErrorIs(t, err, ErrNonCanonicalBigInt)
+	err = NewBinDecoder([]byte{1, 0}).WithCanonicalMode().Decode(&out)
+	assert.ErrorIs(t, err, ErrNonCanonicalBigInt)
+
+	type notBigInt struct {
+		A float64 `bin:"width=4"`
+	}
+	assert.EqualError(t, Precompile(notBigInt{}), `precompile: bin.notBigInt: field "A": the width tag only applies to big.Int, Decimal and integers, got float64`)
+}
+
+func TestBigInt_CloneWire(t *testing.T) {
+	in := bigBalances{Supply: big.NewInt(5), Debt: *big.NewInt(-6)}
+	out := CloneWire(in).(bigBalances)
+	assert.True(t, EqualWire(in, out))
+	out.Supply.SetInt64(1)
+	assert.Equal(t, int64(5), in.Supply.Int64())
+	assert.Equal(t, int64(-6), out.Debt.Int64())
+}
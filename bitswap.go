@@ -0,0 +1,221 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"reflect"
+)
+
+// Integer fields can be transformed on the wire with the following tags,
+// for hardware protocols that require it:
+//
+//	type Frame struct {
+//		Addr  uint32 `bin:"swap"`       // bytes in the opposite order of the field's byte order
+//		Mask  uint16 `bin:"bitreverse"` // bits in reverse order (bit 0 becomes bit 15)
+//		Flags uint8  `bin:"bitreverse"`
+//	}
+//
+// Both tags can be combined, and apply to the integer elements
+// of slices and arrays too. The transforms are their own inverse,
+// so decoding restores the original value.
+
+// swapBits applies the `swap` and `bitreverse` transforms to
+// the lowest `size` bytes of v.
+func swapBits(v uint64, size int, swap, reverse bool) uint64 {
+	switch size {
+	case 1:
+		if reverse {
+			v = uint64(bits.Reverse8(uint8(v)))
+		}
+	case 2:
+		if swap {
+			v = uint64(bits.ReverseBytes16(uint16(v)))
+		}
+		if reverse {
+			v = uint64(bits.Reverse16(uint16(v)))
+		}
+	case 4:
+		if swap {
+			v = uint64(bits.ReverseBytes32(uint32(v)))
+		}
+		if reverse {
+			v = uint64(bits.Reverse32(uint32(v)))
+		}
+	case 8:
+		if swap {
+			v = bits.ReverseBytes64(v)
+		}
+		if reverse {
+			v = bits.Reverse64(v)
+		}
+	}
+	return v
+}
+
+func isSwappableKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	default:
+		return false
+	}
+}
+
+func (o *option) hasBitTransform() bool {
+	return o.Swap || o.BitReverse
+}
+
+// byteOrder returns the byte order the encoding uses for the provided option.
+func byteOrder(enc Encoding, opt *option) binary.ByteOrder {
+	if enc.IsBorsh() {
+		return LE
+	}
+	return opt.Order
+}
+
+// encodeSwapped handles the values affected by the `swap` and `bitreverse` tags.
+func (e *Encoder) encodeSwapped(rv reflect.Value, opt *option) (handled bool, err error) {
+	if !opt.hasBitTransform() {
+		return false, nil
+	}
+	switch {
+	case isSwappableKind(rv.Kind()):
+		return true, e.writeSwapped(rv, opt)
+	case (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && isSwappableKind(rv.Type().Elem().Kind()):
+		l := rv.Len()
+		if rv.Kind() == reflect.Slice {
+			if opt.hasSizeOfSlice() {
+				l = opt.getSizeOfSlice()
+			} else if err := e.WriteLength(l); err != nil {
+				return true, err
+			}
+		}
+		for i := 0; i < l; i++ {
+			if err := e.writeSwapped(rv.Index(i), opt); err != nil {
+				return true, newElementError("encoding", i, err)
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (e *Encoder) writeSwapped(rv reflect.Value, opt *option) error {
+	size := int(rv.Type().Size())
+	var v uint64
+	if rv.Kind() >= reflect.Uint8 && rv.Kind() <= reflect.Uint64 {
+		v = rv.Uint()
+	} else {
+		v = uint64(rv.Int())
+	}
+	v = swapBits(v, size, opt.Swap, opt.BitReverse)
+	order := byteOrder(e.encoding, opt)
+	switch size {
+	case 1:
+		return e.WriteUint8(uint8(v))
+	case 2:
+		return e.WriteUint16(uint16(v), order)
+	case 4:
+		return e.WriteUint32(uint32(v), order)
+	default:
+		return e.WriteUint64(v, order)
+	}
+}
+
+// decodeSwapped handles the values affected by the `swap` and `bitreverse` tags.
+func (dec *Decoder) decodeSwapped(rv reflect.Value, opt *option) (handled bool, err error) {
+	if !opt.hasBitTransform() {
+		return false, nil
+	}
+	switch {
+	case isSwappableKind(rv.Kind()):
+		return true, dec.readSwapped(rv, opt)
+	case rv.Kind() == reflect.Array && isSwappableKind(rv.Type().Elem().Kind()):
+		for i := 0; i < rv.Len(); i++ {
+			if err := dec.readSwapped(rv.Index(i), opt); err != nil {
+				return true, newElementError("decoding", i, err)
+			}
+		}
+		return true, nil
+	case rv.Kind() == reflect.Slice && isSwappableKind(rv.Type().Elem().Kind()):
+		var l int
+		if opt.hasSizeOfSlice() {
+			l = opt.getSizeOfSlice()
+		} else {
+			l, err = dec.ReadLength()
+			if err != nil {
+				return true, err
+			}
+		}
+		size := int(rv.Type().Elem().Size())
+		if l*size > dec.Remaining() {
+			return true, fmt.Errorf("%s of length %d: %d bytes required, remaining [%d]", rv.Type(), l, l*size, dec.Remaining())
+		}
+		out := reflect.MakeSlice(rv.Type(), l, l)
+		for i := 0; i < l; i++ {
+			if err := dec.readSwapped(out.Index(i), opt); err != nil {
+				return true, newElementError("decoding", i, err)
+			}
+		}
+		rv.Set(out)
+		return true, nil
+	}
+	return false, nil
+}
+
+func (dec *Decoder) readSwapped(rv reflect.Value, opt *option) error {
+	size := int(rv.Type().Size())
+	order := byteOrder(dec.encoding, opt)
+	var v uint64
+	switch size {
+	case 1:
+		n, err := dec.ReadUint8()
+		if err != nil {
+			return err
+		}
+		v = uint64(n)
+	case 2:
+		n, err := dec.ReadUint16(order)
+		if err != nil {
+			return err
+		}
+		v = uint64(n)
+	case 4:
+		n, err := dec.ReadUint32(order)
+		if err != nil {
+			return err
+		}
+		v = uint64(n)
+	default:
+		n, err := dec.ReadUint64(order)
+		if err != nil {
+			return err
+		}
+		v = n
+	}
+	v = swapBits(v, size, opt.Swap, opt.BitReverse)
+	if rv.Kind() >= reflect.Uint8 && rv.Kind() <= reflect.Uint64 {
+		rv.SetUint(v)
+	} else {
+		// Sign-extend from the value's width.
+		shift := uint(64 - 8*size)
+		rv.SetInt(int64(v<<shift) >> shift)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bitswapTestFrame struct {
+	Addr    uint32   `bin:"swap"`
+	BigAddr uint32   `bin:"big swap"`
+	Mask    uint16   `bin:"bitreverse"`
+	Flags   uint8    `bin:"bitreverse"`
+	Delta   int16    `bin:"swap bitreverse"`
+	Count   uint8    `bin:"sizeof=Words"`
+	Words   []uint16 `bin:"swap"`
+	Pair    [2]int8  `bin:"bitreverse"`
+	Ptr     *uint16  `bin:"optional swap"`
+}
+
+func TestBitSwap(t *testing.T) {
+	ptr := uint16(0x0102)
+	frame := bitswapTestFrame{
+		Addr:    0x11223344,
+		BigAddr: 0x11223344,
+		Mask:    0x0001,
+		Flags:   0x01,
+		Delta:   -2,
+		Count:   2,
+		Words:   []uint16{0xaabb, 0xccdd},
+		Pair:    [2]int8{1, -128},
+		Ptr:     &ptr,
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).Encode(frame))
+	assert.Equal(t, []byte{
+		0x11, 0x22, 0x33, 0x44, // Addr: little endian swapped
+		0x44, 0x33, 0x22, 0x11, // BigAddr: big endian swapped
+		0x00, 0x80, // Mask
+		0x80,       // Flags
+		0x7f, 0xff, // Delta: 0xfffe swapped to 0xfeff, then reversed to 0xff7f
+		0x02,
+		0xaa, 0xbb, 0xcc, 0xdd, // Words
+		0x80, 0x01, // Pair
+		0x01, 0x00, 0x00, 0x00, 0x01, 0x02, // Ptr
+	}, buf.Bytes())
+
+	var out bitswapTestFrame
+	require.NoError(t, NewBinDecoder(buf.Bytes()).Decode(&out))
+	assert.Equal(t, frame, out)
+
+	for _, enc := range []Encoding{EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(frame))
+		var out bitswapTestFrame
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out))
+		assert.Equal(t, frame, out, enc.String())
+	}
+}
+
+func TestSwapBits(t *testing.T) {
+	assert.Equal(t, uint64(0x80), swapBits(0x01, 1, true, true))
+	assert.Equal(t, uint64(0x3412), swapBits(0x1234, 2, true, false))
+	assert.Equal(t, uint64(0x2c48), swapBits(0x1234, 2, false, true))
+	assert.Equal(t, uint64(0x482c), swapBits(0x1234, 2, true, true))
+	assert.Equal(t, uint64(0x0100000000000000), swapBits(0x01, 8, true, false))
+	assert.Equal(t, uint64(0x8000000000000000), swapBits(0x01, 8, false, true))
+}
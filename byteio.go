@@ -0,0 +1,63 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"io"
+)
+
+// NewEncoderWithByteWriter returns an encoder that writes to w one byte at a time,
+// for targets (e.g. TinyGo on microcontrollers) where a UART or a ring buffer
+// only offers io.ByteWriter.
+func NewEncoderWithByteWriter(w io.ByteWriter, enc Encoding) *Encoder {
+	return NewEncoderWithEncoding(byteWriter{w}, enc)
+}
+
+type byteWriter struct {
+	w io.ByteWriter
+}
+
+func (w byteWriter) Write(p []byte) (int, error) {
+	for i, b := range p {
+		if err := w.w.WriteByte(b); err != nil {
+			return i, err
+		}
+	}
+	return len(p), nil
+}
+
+// NewDecoderWithByteReader returns a decoder over the bytes read from r,
+// one at a time, until io.EOF. As decoders need the whole message
+// in memory, r must only hold one message (or a few), e.g. a frame
+// received by a device; at most maxSize bytes are read, and more
+// is an error.
+func NewDecoderWithByteReader(r io.ByteReader, enc Encoding, maxSize int) (*Decoder, error) {
+	var data []byte
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == maxSize {
+			return nil, fmt.Errorf("byte reader: more than %d bytes", maxSize)
+		}
+		data = append(data, b)
+	}
+	return NewDecoderWithEncoding(data, enc), nil
+}
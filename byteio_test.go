@@ -0,0 +1,62 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingByteWriter struct {
+	n int
+}
+
+func (w *failingByteWriter) WriteByte(b byte) error {
+	if w.n == 0 {
+		return errors.New("full")
+	}
+	w.n--
+	return nil
+}
+
+func TestByteReaderWriter(t *testing.T) {
+	type reading struct {
+		Sensor uint8
+		Value  int16
+		Label  string
+	}
+	in := reading{Sensor: 3, Value: -20, Label: "temp"}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewEncoderWithByteWriter(buf, EncodingBorsh).Encode(in))
+	expected, err := MarshalBorsh(in)
+	require.NoError(t, err)
+	assert.Equal(t, expected, buf.Bytes())
+
+	dec, err := NewDecoderWithByteReader(bytes.NewReader(buf.Bytes()), EncodingBorsh, 64)
+	require.NoError(t, err)
+	var out reading
+	require.NoError(t, dec.Decode(&out))
+	assert.Equal(t, in, out)
+
+	_, err = NewDecoderWithByteReader(bytes.NewReader(buf.Bytes()), EncodingBorsh, 4)
+	require.Error(t, err)
+
+	require.Error(t, NewEncoderWithByteWriter(&failingByteWriter{n: 2}, EncodingBorsh).Encode(in))
+}
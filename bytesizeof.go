@@ -0,0 +1,135 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A struct field tagged `bin:"bytesizeof=Field"` holds the encoded size in bytes
+// of another field of the struct (which keeps its own length prefix, if any),
+// like the length of a TLV record:
+//
+//	type Record struct {
+//		Tag   uint8
+//		Len   uint16 `bin:"bytesizeof=Value"`
+//		Value Payload
+//	}
+//
+// Encoders fill it in, whatever its value: the target field is encoded first,
+// behind a checkpoint, and the size is written in front of it. Decoders read
+// the target field from a sub-decoder of that many bytes, which it must use entirely.
+
+// byteSizePrefix is a `bytesizeof` field whose target is being encoded.
+type byteSizePrefix struct {
+	field string
+	cp    Checkpoint
+	typ   reflect.Type
+	opt   *option
+}
+
+// beginByteSize holds the output from the current position, where the value
+// of the `bytesizeof` field with the provided name, type and options goes.
+func (e *Encoder) beginByteSize(field string, rt reflect.Type, opt *option) *byteSizePrefix {
+	return &byteSizePrefix{
+		field: field,
+		cp:    e.Checkpoint(),
+		typ:   rt,
+		opt:   opt,
+	}
+}
+
+// encodeByteSized encodes the target of a `bytesizeof` field with encode,
+// then inserts the value of that field in front of what was written since it.
+func (e *Encoder) encodeByteSized(p *byteSizePrefix, rv reflect.Value, opt *option, encode func(reflect.Value, *option) error) error {
+	start := e.pending.Len()
+	if err := encode(rv, opt); err != nil {
+		return err
+	}
+	size := reflect.New(p.typ).Elem()
+	if err := setByteSize(size, e.pending.Len()-start); err != nil {
+		return fmt.Errorf("bytesizeof field %q: %w", p.field, err)
+	}
+
+	tail := append([]byte(nil), e.pending.Bytes()[p.cp.offset:]...)
+	e.pending.Truncate(p.cp.offset)
+	e.count -= len(tail)
+	if err := encode(size, p.opt); err != nil {
+		return fmt.Errorf("bytesizeof field %q: %w", p.field, err)
+	}
+	if err := e.checkMaxSize(len(tail)); err != nil {
+		return err
+	}
+	e.pending.Write(tail)
+	e.count += len(tail)
+	return e.Commit(p.cp)
+}
+
+// abortByteSizes discards the output held for the `bytesizeof` fields
+// whose target wasn't encoded, after an error.
+func (e *Encoder) abortByteSizes(prefixes map[string]*byteSizePrefix) {
+	var first *byteSizePrefix
+	for _, p := range prefixes {
+		if first == nil || p.cp.depth < first.cp.depth {
+			first = p
+		}
+	}
+	if first != nil {
+		e.Rollback(first.cp)
+	}
+}
+
+// unencodedByteSizes returns an error if a `bytesizeof` field refers to a field that wasn't encoded.
+func unencodedByteSizes(prefixes map[string]*byteSizePrefix) error {
+	for target, p := range prefixes {
+		return newFieldError("encoding", p.field, fmt.Errorf("bytesizeof target %q was not encoded", target))
+	}
+	return nil
+}
+
+func setByteSize(rv reflect.Value, size int) error {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.OverflowInt(int64(size)) {
+			return fmt.Errorf("size %d overflows %s", size, rv.Type())
+		}
+		rv.SetInt(int64(size))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.OverflowUint(uint64(size)) {
+			return fmt.Errorf("size %d overflows %s", size, rv.Type())
+		}
+		rv.SetUint(uint64(size))
+	default:
+		return fmt.Errorf("bytesizeof field must be an integer, got %s", rv.Type())
+	}
+	return nil
+}
+
+// decodeByteSized decodes the target of a `bytesizeof` field, of the provided
+// size in bytes, with decode.
+func (dec *Decoder) decodeByteSized(size int, rv reflect.Value, opt *option, decode func(*Decoder, reflect.Value, *option) error) error {
+	sub, err := dec.SubDecoder(size)
+	if err != nil {
+		return err
+	}
+	if err := decode(sub, rv, opt); err != nil {
+		return err
+	}
+	if sub.HasRemaining() {
+		return fmt.Errorf("bytesizeof: %d of the %d bytes left after the field", sub.Remaining(), size)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type byteSizePayload struct {
+	Name  string
+	Items []uint16
+}
+
+type byteSizeRecord struct {
+	Tag   uint8
+	Len   uint16 `bin:"bytesizeof=Value"`
+	Value byteSizePayload
+	Tail  uint8
+}
+
+func TestByteSizeOf(t *testing.T) {
+	record := byteSizeRecord{
+		Tag:   1,
+		Len:   999, // Overwritten by the encoder.
+		Value: byteSizePayload{Name: "ab", Items: []uint16{1, 2}},
+		Tail:  9,
+	}
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			payload, err := encodingCodec(enc).Marshal(record.Value)
+			require.NoError(t, err)
+
+			buf := new(bytes.Buffer)
+			encoder := NewEncoderWithEncoding(buf, enc)
+			require.NoError(t, encoder.Encode(record))
+			expected := append([]byte{0x01, byte(len(payload)), 0x00}, payload...)
+			expected = append(expected, 0x09)
+			assert.Equal(t, expected, buf.Bytes())
+			assert.Equal(t, len(expected), encoder.Written())
+
+			var got byteSizeRecord
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&got))
+			assert.Equal(t, uint16(len(payload)), got.Len)
+			assert.Equal(t, record.Value, got.Value)
+			assert.Equal(t, record.Tail, got.Tail)
+
+			require.NoError(t, ConformsWithEncoding(buf.Bytes(), enc, byteSizeRecord{}))
+		})
+	}
+
+	data, err := BinCodec.Marshal(record)
+	require.NoError(t, err)
+	tail, err := Query(data, byteSizeRecord{}, "Tail")
+	require.NoError(t, err)
+	assert.Equal(t, uint8(9), tail.Value)
+	assert.Equal(t, len(data)-1, tail.Start)
+
+	explained, err := ExplainType(byteSizeRecord{})
+	require.NoError(t, err)
+	assert.Contains(t, explained, "bytesizeof=Len")
+}
+
+func TestByteSizeOf_Errors(t *testing.T) {
+	data, err := BinCodec.Marshal(byteSizeRecord{Value: byteSizePayload{Name: "ab"}})
+	require.NoError(t, err)
+
+	// A size larger than the value leaves bytes in the sub-decoder.
+	bad := append([]byte(nil), data...)
+	bad[1]++
+	err = BinCodec.Unmarshal(bad, &byteSizeRecord{})
+	assert.Error(t, err)
+	assert.Error(t, Conforms(bad, byteSizeRecord{}))
+
+	// A size smaller than the value cuts it.
+	bad[1] -= 2
+	assert.Error(t, BinCodec.Unmarshal(bad, &byteSizeRecord{}))
+
+	type tooSmall struct {
+		Len   uint8 `bin:"bytesizeof=Value"`
+		Value []byte
+	}
+	buf := new(bytes.Buffer)
+	err = NewBinEncoder(buf).Encode(tooSmall{Value: make([]byte, 300)})
+	assert.EqualError(t, err, `error while encoding "Value" field: bytesizeof field "Len": size 302 overflows uint8`)
+	assert.Equal(t, 0, buf.Len())
+
+	type notInteger struct {
+		Len   string `bin:"bytesizeof=Value"`
+		Value []byte
+	}
+	assert.Error(t, Precompile(notInteger{}))
+}
+
+func TestByteSizeOf_Nested(t *testing.T) {
+	type inner struct {
+		Len   uint8 `bin:"bytesizeof=Value"`
+		Value string
+	}
+	type outer struct {
+		Len   uint32 `bin:"bytesizeof=Inner"`
+		Inner inner
+	}
+	data, err := BorshCodec.Marshal(outer{Inner: inner{Value: "abc"}})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x08, 0x00, 0x00, 0x00,
+		0x07,
+		0x03, 0x00, 0x00, 0x00, 'a', 'b', 'c',
+	}, data)
+
+	var got outer
+	require.NoError(t, BorshCodec.Unmarshal(data, &got))
+	assert.Equal(t, outer{Len: 8, Inner: inner{Len: 7, Value: "abc"}}, got)
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The bit patterns NaN floats are written with in canonical mode:
+// positive quiet NaNs with an empty payload, as returned by math.NaN().
+const (
+	CanonicalNaN16 Float16 = 0x7e00
+	CanonicalNaN32 uint32  = 0x7fc00000
+	CanonicalNaN64 uint64  = 0x7ff8000000000000
+)
+
+// ErrNonCanonicalNaN is returned by decoders in canonical mode
+// when a NaN float doesn't have the canonical bit pattern.
+var ErrNonCanonicalNaN = errors.New("non-canonical NaN")
+
+// ErrNonMaximalRun is returned by decoders in canonical mode when
+// two consecutive runs of an `rle` slice have the same element.
+var ErrNonMaximalRun = errors.New("rle: non-maximal run")
+
+// ErrNonMinimalVarint is returned by decoders in canonical mode for
+// varints with trailing zero groups, e.g. 0x80 0x00 for zero.
+var ErrNonMinimalVarint = errors.New("non-minimal varint")
+
+// WithCanonicalMode makes the encoder write values that have more than one
+// possible encoding in a single, canonical way, so that equal values always
+// produce the same bytes (and hashes) on every node:
+//   - NaN floats are normalized to CanonicalNaN16, CanonicalNaN32 and CanonicalNaN64;
+//     other encoders keep their payload and signaling bit.
+func (e *Encoder) WithCanonicalMode() *Encoder {
+	e.canonical = true
+	return e
+}
+
+// WithCanonicalMode makes the decoder reject values that are not encoded
+// the way an encoder in canonical mode would encode them:
+//   - NaN floats that are not CanonicalNaN16, CanonicalNaN32 or CanonicalNaN64 (ErrNonCanonicalNaN).
+//   - `rle` slices with two consecutive runs of the same element (ErrNonMaximalRun);
+//     encoders always write maximal runs.
+//   - varints and uvarints that are not minimally encoded (ErrNonMinimalVarint);
+//     other decoders accept them with a warning (see Warnings).
+//   - length-prefixed big.Int values with leading zero bytes, or negative
+//     zeros (ErrNonCanonicalBigInt).
+func (dec *Decoder) WithCanonicalMode() *Decoder {
+	dec.canonical = true
+	return dec
+}
+
+// checkMinimalVarint checks that the varint of the provided size at the
+// position of the decoder is minimally encoded: that its last group,
+// when it has more than one, is not zero.
+func (dec *Decoder) checkMinimalVarint(size int) error {
+	if size == 1 || dec.data[dec.pos+size-1] != 0 {
+		return nil
+	}
+	if dec.canonical {
+		return fmt.Errorf("%w: % x", ErrNonMinimalVarint, dec.data[dec.pos:dec.pos+size])
+	}
+	dec.warnf("non-minimal varint % x", dec.data[dec.pos:dec.pos+size])
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalMode_NaN(t *testing.T) {
+	type floats struct {
+		F32 float32
+		F64 float64
+		C   complex64
+	}
+	// NaNs with a payload and the sign bit set.
+	nan32 := math.Float32frombits(0xffc00001)
+	nan64 := math.Float64frombits(0xfff8000000000001)
+	in := floats{F32: nan32, F64: nan64, C: complex(nan32, 1)}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).Encode(in))
+	assert.Equal(t, []byte{
+		0x01, 0x00, 0xc0, 0xff,
+		0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0xff,
+		0x01, 0x00, 0xc0, 0xff, 0x00, 0x00, 0x80, 0x3f,
+	}, buf.Bytes())
+	raw := append([]byte(nil), buf.Bytes()...)
+
+	buf.Reset()
+	require.NoError(t, NewBinEncoder(buf).WithCanonicalMode().Encode(in))
+	canonical := buf.Bytes()
+	assert.Equal(t, []byte{
+		0x00, 0x00, 0xc0, 0x7f,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x7f,
+		0x00, 0x00, 0xc0, 0x7f, 0x00, 0x00, 0x80, 0x3f,
+	}, canonical)
+
+	var out floats
+	require.NoError(t, NewBinDecoder(canonical).WithCanonicalMode().Decode(&out))
+	assert.True(t, math.IsNaN(float64(out.F32)))
+	assert.True(t, math.IsNaN(out.F64))
+
+	// Non-canonical NaNs are accepted by default, and rejected in canonical mode.
+	require.NoError(t, NewBinDecoder(raw).Decode(&out))
+	err := NewBinDecoder(raw).WithCanonicalMode().Decode(&out)
+	assert.ErrorIs(t, err, ErrNonCanonicalNaN)
+	assert.EqualError(t, err, `error while decoding "F32" field: float32 0xffc00001: non-canonical NaN`)
+
+	_, err = NewBinDecoder(raw[4:12]).WithCanonicalMode().ReadFloat64(LE)
+	assert.ErrorIs(t, err, ErrNonCanonicalNaN)
+
+	// Regular values are untouched.
+	buf.Reset()
+	require.NoError(t, NewBinEncoder(buf).WithCanonicalMode().WriteFloat64(-0.5, LE))
+	f, err := NewBinDecoder(buf.Bytes()).WithCanonicalMode().ReadFloat64(LE)
+	require.NoError(t, err)
+	assert.Equal(t, -0.5, f)
+}
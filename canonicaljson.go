@@ -0,0 +1,393 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// CanonicalJSONMarshaler is implemented by types that write their own
+// JSON for CanonicalJSON; their output is canonicalized like the one
+// of MarshalJSON.
+type CanonicalJSONMarshaler interface {
+	MarshalCanonicalJSON() ([]byte, error)
+}
+
+var (
+	canonicalJSONMarshalerType = reflect.TypeOf((*CanonicalJSONMarshaler)(nil)).Elem()
+	jsonMarshalerType          = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// maxSafeInteger is the largest integer that all JSON parsers read exactly.
+const maxSafeInteger = 1<<53 - 1
+
+// CanonicalJSON returns the canonical JSON of v, in the manner of RFC 8785:
+// no whitespace, object members sorted by the UTF-16 code units of their
+// names, minimal string escapes, and numbers written the way ECMAScript does.
+// It's meant to be stored next to the binary encoding of v, e.g. for audits,
+// so it follows the same tags:
+//   - the struct fields that are not encoded (`-`, `skip=encode`, reserved
+//     and unexported fields) are left out, and inline fields are flattened;
+//   - absent optional fields and nil pointers are null;
+//   - a borsh enum is an object holding its variant, e.g. {"Transfer":{...}}.
+//
+// Integers of 64 bits or more, and big integers, are written as decimal
+// strings, so that they aren't rounded; byte slices and arrays are written as
+// hexadecimal strings. Types implementing CanonicalJSONMarshaler or
+// json.Marshaler write themselves, and their JSON is canonicalized.
+func CanonicalJSON(v interface{}) ([]byte, error) {
+	w := &canonicalJSONWriter{}
+	if err := w.write(reflect.ValueOf(v)); err != nil {
+		return nil, fmt.Errorf("canonical json: %w", err)
+	}
+	return w.buf.Bytes(), nil
+}
+
+type canonicalJSONWriter struct {
+	buf bytes.Buffer
+	// enc tells absent optional fields apart, with the default modes.
+	enc Encoder
+}
+
+func (w *canonicalJSONWriter) write(rv reflect.Value) error {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			w.buf.WriteString("null")
+			return nil
+		}
+		if rv.Kind() == reflect.Ptr && rv.CanInterface() && rv.Type().Implements(canonicalJSONMarshalerType) {
+			break
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		w.buf.WriteString("null")
+		return nil
+	}
+	if handled, err := w.writeMarshaler(rv); handled {
+		return err
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		w.buf.WriteString(strconv.FormatBool(rv.Bool()))
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		w.buf.WriteString(strconv.FormatInt(rv.Int(), 10))
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		w.buf.WriteString(strconv.FormatUint(rv.Uint(), 10))
+	case reflect.Int, reflect.Int64:
+		return w.writeString(strconv.FormatInt(rv.Int(), 10))
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return w.writeString(strconv.FormatUint(rv.Uint(), 10))
+	case reflect.Float32:
+		return w.writeFloat(rv.Float(), 32)
+	case reflect.Float64:
+		return w.writeFloat(rv.Float(), 64)
+	case reflect.Complex64, reflect.Complex128:
+		bits := 64
+		if rv.Kind() == reflect.Complex64 {
+			bits = 32
+		}
+		c := rv.Complex()
+		w.buf.WriteByte('[')
+		if err := w.writeFloat(real(c), bits); err != nil {
+			return err
+		}
+		w.buf.WriteByte(',')
+		if err := w.writeFloat(imag(c), bits); err != nil {
+			return err
+		}
+		w.buf.WriteByte(']')
+	case reflect.String:
+		return w.writeString(rv.String())
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return w.writeString(hex.EncodeToString(b))
+		}
+		w.buf.WriteByte('[')
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			if err := w.write(rv.Index(i)); err != nil {
+				return newElementError("encoding", i, err)
+			}
+		}
+		w.buf.WriteByte(']')
+	case reflect.Map:
+		return w.writeMap(rv)
+	case reflect.Struct:
+		return w.writeStruct(rv)
+	default:
+		return fmt.Errorf("unsupported type %s", rv.Type())
+	}
+	return nil
+}
+
+// writeMarshaler writes the values whose JSON isn't derived from their kind.
+func (w *canonicalJSONWriter) writeMarshaler(rv reflect.Value) (bool, error) {
+	if !rv.CanInterface() {
+		return false, nil
+	}
+	switch rv.Type() {
+	case timeType:
+		return true, w.writeString(rv.Interface().(time.Time).UTC().Format(time.RFC3339Nano))
+	case bigIntType:
+		v := rv.Interface().(big.Int)
+		return true, w.writeString(v.String())
+	case ipType:
+		return true, w.writeString(rv.Interface().(net.IP).String())
+	case hardwareAddrType:
+		return true, w.writeString(rv.Interface().(net.HardwareAddr).String())
+	}
+	v := rv.Interface()
+	if rv.CanAddr() && !rv.Type().Implements(canonicalJSONMarshalerType) && !rv.Type().Implements(jsonMarshalerType) {
+		v = rv.Addr().Interface()
+	}
+	var data []byte
+	var err error
+	switch m := v.(type) {
+	case CanonicalJSONMarshaler:
+		data, err = m.MarshalCanonicalJSON()
+	case json.Marshaler:
+		data, err = m.MarshalJSON()
+	default:
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+	return true, w.canonicalize(data)
+}
+
+// canonicalize writes the canonical form of the provided JSON.
+func (w *canonicalJSONWriter) canonicalize(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("invalid JSON %q", data)
+	}
+	return w.writeJSONValue(v)
+}
+
+func (w *canonicalJSONWriter) writeJSONValue(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		w.buf.WriteString("null")
+	case bool:
+		w.buf.WriteString(strconv.FormatBool(v))
+	case string:
+		return w.writeString(v)
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return err
+		}
+		if !strings.ContainsAny(string(v), ".eE") && math.Abs(f) > maxSafeInteger {
+			return fmt.Errorf("integer %s can't be written exactly as a JSON number", v)
+		}
+		return w.writeFloat(f, 64)
+	case []interface{}:
+		w.buf.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			if err := w.writeJSONValue(elem); err != nil {
+				return err
+			}
+		}
+		w.buf.WriteByte(']')
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		return w.writeObject(names, func(name string) error {
+			return w.writeJSONValue(v[name])
+		})
+	}
+	return nil
+}
+
+func (w *canonicalJSONWriter) writeMap(rv reflect.Value) error {
+	keys := map[string]reflect.Value{}
+	names := make([]string, 0, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		var name string
+		switch key := iter.Key(); {
+		case key.Kind() == reflect.String:
+			name = key.String()
+		case key.Kind() >= reflect.Uint && key.Kind() <= reflect.Uintptr:
+			name = strconv.FormatUint(key.Uint(), 10)
+		case isIntegerKind(key.Kind()):
+			name = strconv.FormatInt(key.Int(), 10)
+		default:
+			return fmt.Errorf("unsupported map key type %s", key.Type())
+		}
+		keys[name] = iter.Value()
+		names = append(names, name)
+	}
+	return w.writeObject(names, func(name string) error {
+		return w.write(keys[name])
+	})
+}
+
+func (w *canonicalJSONWriter) writeStruct(rv reflect.Value) error {
+	plan := planOf(rv.Type())
+	if plan.complexEnum {
+		variant := int(rv.Field(0).Uint()) + 1
+		if variant >= len(plan.fields) {
+			return fmt.Errorf("complex enum too large")
+		}
+		return w.writeObject([]string{plan.fields[variant].Name}, func(string) error {
+			return w.write(plan.field(rv, variant))
+		})
+	}
+
+	fields := map[string]reflect.Value{}
+	names := []string{}
+	for i, structField := range plan.fields {
+		fieldTag := plan.tags[i]
+		if fieldTag.Skip || fieldTag.SkipEncode || fieldTag.Reserved > 0 || structField.PkgPath != "" {
+			continue
+		}
+		v := plan.field(rv, i)
+		if (fieldTag.Option || fieldTag.COption) && w.enc.isAbsent(v, plan.fieldOption(i, w.enc.encoding, nil)) {
+			v = reflect.Value{}
+		}
+		fields[structField.Name] = v
+		names = append(names, structField.Name)
+	}
+	return w.writeObject(names, func(name string) error {
+		if err := w.write(fields[name]); err != nil {
+			return newFieldError("encoding", name, err)
+		}
+		return nil
+	})
+}
+
+// writeObject writes the members with the provided names,
+// sorted by the UTF-16 code units of the names.
+func (w *canonicalJSONWriter) writeObject(names []string, writeValue func(name string) error) error {
+	sort.Slice(names, func(i, j int) bool {
+		return lessUTF16(names[i], names[j])
+	})
+	w.buf.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			w.buf.WriteByte(',')
+		}
+		if err := w.writeString(name); err != nil {
+			return err
+		}
+		w.buf.WriteByte(':')
+		if err := writeValue(name); err != nil {
+			return err
+		}
+	}
+	w.buf.WriteByte('}')
+	return nil
+}
+
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// writeString writes s with the minimal escapes of RFC 8785.
+func (w *canonicalJSONWriter) writeString(s string) error {
+	if !utf8.ValidString(s) {
+		return fmt.Errorf("invalid UTF-8 string %q", s)
+	}
+	w.buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			w.buf.WriteString(`\"`)
+		case '\\':
+			w.buf.WriteString(`\\`)
+		case '\b':
+			w.buf.WriteString(`\b`)
+		case '\f':
+			w.buf.WriteString(`\f`)
+		case '\n':
+			w.buf.WriteString(`\n`)
+		case '\r':
+			w.buf.WriteString(`\r`)
+		case '\t':
+			w.buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&w.buf, `\u%04x`, r)
+			} else {
+				w.buf.WriteRune(r)
+			}
+		}
+	}
+	w.buf.WriteByte('"')
+	return nil
+}
+
+// writeFloat writes f the way ECMAScript converts numbers to strings.
+func (w *canonicalJSONWriter) writeFloat(f float64, bits int) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("unsupported float value %v", f)
+	}
+	if f == 0 {
+		// Including -0.
+		w.buf.WriteByte('0')
+		return nil
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs < 1e-6 || abs >= 1e21 {
+		format = 'e'
+	}
+	b := strconv.AppendFloat(nil, f, format, -1, bits)
+	if format == 'e' {
+		// 1e-07 is written 1e-7.
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	w.buf.Write(b)
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type canonicalTransfer struct {
+	Amount uint64
+	Memo   *string `bin:"optional"`
+}
+
+type canonicalInstruction struct {
+	Enum     BorshEnum `borsh_enum:"true"`
+	Noop     EmptyVariant
+	Transfer canonicalTransfer
+}
+
+type canonicalRecord struct {
+	Kind     uint8
+	Name     string
+	Payload  []byte
+	Key      [4]byte
+	Scores   map[string]int16
+	Ratio    float64
+	Internal string  `bin:"-"`
+	Pad      [2]byte `bin:"reserved=2"`
+	Extra    Uint128
+	Op       canonicalInstruction
+	Ptr      *uint32
+	secret   uint8
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	in := canonicalRecord{
+		Kind:    7,
+		Name:    "é\"\n\x01",
+		Payload: []byte{0xde, 0xad},
+		Key:     [4]byte{1, 2, 3, 4},
+		// U+FB33 sorts after U+1F600, whose UTF-16 code units are surrogates.
+		Scores:   map[string]int16{"b": 2, "a": -1, "€": 3, "\U0001F600": 4, "\uFB33": 5},
+		Ratio:    0.1,
+		Internal: "ignored",
+		Extra:    Uint128{Lo: 5},
+		Op:       canonicalInstruction{Enum: 1, Transfer: canonicalTransfer{Amount: 1 << 60}},
+		secret:   1,
+	}
+	data, err := CanonicalJSON(&in)
+	require.NoError(t, err)
+	assert.Equal(t,
+		`{"Extra":"5","Key":"01020304","Kind":7,"Name":"é\"\n\u0001",`+
+			`"Op":{"Transfer":{"Amount":"1152921504606846976","Memo":null}},`+
+			`"Payload":"dead","Ptr":null,"Ratio":0.1,"Scores":{"a":-1,"b":2,"€":3,"😀":4,"`+"\uFB33"+`":5}}`,
+		string(data))
+
+	// The output doesn't depend on the order of the map entries.
+	again, err := CanonicalJSON(in)
+	require.NoError(t, err)
+	assert.Equal(t, data, again)
+}
+
+func TestCanonicalJSON_Numbers(t *testing.T) {
+	cases := map[float64]string{
+		0:                       "0",
+		math.Copysign(0, -1):    "0",
+		1:                       "1",
+		-1.5:                    "-1.5",
+		1e21:                    "1e+21",
+		1e20:                    "100000000000000000000",
+		1e-7:                    "1e-7",
+		0.000001:                "0.000001",
+		333333333.3333333:       "333333333.3333333",
+		4.50:                    "4.5",
+		9007199254740992:        "9007199254740992",
+		-1.7976931348623157e308: "-1.7976931348623157e+308",
+	}
+	for f, expected := range cases {
+		data, err := CanonicalJSON(f)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(data), "%v", f)
+	}
+
+	float32Data, err := CanonicalJSON(float32(0.1))
+	require.NoError(t, err)
+	assert.Equal(t, "0.1", string(float32Data))
+
+	_, err = CanonicalJSON(math.NaN())
+	assert.EqualError(t, err, "canonical json: unsupported float value NaN")
+	_, err = CanonicalJSON(Uint128JSON{Uint128: Uint128{Lo: math.MaxUint64, Hi: 1}, Format: JSONNumber})
+	assert.EqualError(t, err, "canonical json: integer 36893488147419103231 can't be written exactly as a JSON number")
+	_, err = CanonicalJSON(map[float64]bool{1: true})
+	assert.EqualError(t, err, "canonical json: unsupported map key type float64")
+}
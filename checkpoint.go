@@ -0,0 +1,117 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrInvalidCheckpoint is returned when rolling back to or committing a checkpoint
+// that was already rolled back or committed, or that belongs to another encoder.
+var ErrInvalidCheckpoint = errors.New("invalid checkpoint")
+
+// A Checkpoint is a position in the output of an Encoder that
+// it can be rolled back to; see Encoder.Checkpoint.
+type Checkpoint struct {
+	session int
+	depth   int
+	count   int
+	offset  int
+	heapLen int
+}
+
+// Checkpoint marks the current position of the output, so that what is written
+// after it can be discarded with Rollback, e.g. by a custom marshaler that fails
+// midway and falls back to another encoding:
+//
+//	cp := encoder.Checkpoint()
+//	if err := encoder.Encode(v.Compact()); err != nil {
+//		if err := encoder.Rollback(cp); err != nil {
+//			return err
+//		}
+//		return encoder.Encode(v.Full())
+//	}
+//	return encoder.Commit(cp)
+//
+// Every checkpoint must be either rolled back or committed. While there are
+// checkpoints, the output is held in memory, and it's written to the underlying
+// writer when the first checkpoint is committed or rolled back. Checkpoints
+// can be nested; rolling back to or committing a checkpoint also does so
+// for the checkpoints taken after it.
+func (e *Encoder) Checkpoint() Checkpoint {
+	if e.checkpoints == 0 {
+		e.session++
+		e.pending = new(bytes.Buffer)
+		e.direct = e.output
+		e.output = e.pending
+	}
+	e.checkpoints++
+	cp := Checkpoint{
+		session: e.session,
+		depth:   e.checkpoints,
+		count:   e.count,
+		offset:  e.pending.Len(),
+	}
+	if e.heap != nil {
+		cp.heapLen = e.heap.Len()
+	}
+	return cp
+}
+
+// Rollback discards everything written since the provided checkpoint,
+// and releases it; Written is also restored.
+func (e *Encoder) Rollback(cp Checkpoint) error {
+	if err := e.checkCheckpoint(cp); err != nil {
+		return err
+	}
+	e.pending.Truncate(cp.offset)
+	e.count = cp.count
+	if e.heap != nil && e.heap.Len() >= cp.heapLen {
+		e.heap.Truncate(cp.heapLen)
+	}
+	e.checkpoints = cp.depth - 1
+	return e.flushCheckpoints()
+}
+
+// Commit keeps everything written since the provided checkpoint, and releases it.
+func (e *Encoder) Commit(cp Checkpoint) error {
+	if err := e.checkCheckpoint(cp); err != nil {
+		return err
+	}
+	e.checkpoints = cp.depth - 1
+	return e.flushCheckpoints()
+}
+
+func (e *Encoder) checkCheckpoint(cp Checkpoint) error {
+	if cp.session != e.session || cp.depth == 0 || cp.depth > e.checkpoints {
+		return ErrInvalidCheckpoint
+	}
+	return nil
+}
+
+// flushCheckpoints writes the pending output once there are no more checkpoints.
+func (e *Encoder) flushCheckpoints() error {
+	if e.checkpoints > 0 {
+		return nil
+	}
+	pending := e.pending
+	e.output, e.direct, e.pending = e.direct, nil, nil
+	if pending.Len() == 0 {
+		return nil
+	}
+	_, err := e.output.Write(pending.Bytes())
+	return err
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fallbackMarshaler struct {
+	fail bool
+}
+
+func (f fallbackMarshaler) MarshalWithEncoder(encoder *Encoder) error {
+	cp := encoder.Checkpoint()
+	if err := encoder.WriteUint32(0xAABBCCDD, LE); err != nil {
+		return err
+	}
+	if f.fail {
+		if err := encoder.Rollback(cp); err != nil {
+			return err
+		}
+		return encoder.WriteByte(0x01)
+	}
+	return encoder.Commit(cp)
+}
+
+func TestEncoder_Checkpoint(t *testing.T) {
+	t.Run("commit keeps the output", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		enc := NewBinEncoder(buf)
+		require.NoError(t, enc.WriteByte(0x09))
+		require.NoError(t, enc.Encode(fallbackMarshaler{}))
+		assert.Equal(t, []byte{0x09, 0xDD, 0xCC, 0xBB, 0xAA}, buf.Bytes())
+		assert.Equal(t, 5, enc.Written())
+	})
+	t.Run("rollback discards the output", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		enc := NewBinEncoder(buf)
+		require.NoError(t, enc.WriteByte(0x09))
+		require.NoError(t, enc.Encode(fallbackMarshaler{fail: true}))
+		assert.Equal(t, []byte{0x09, 0x01}, buf.Bytes())
+		assert.Equal(t, 2, enc.Written())
+	})
+	t.Run("output is held until the outermost checkpoint is released", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		enc := NewBinEncoder(buf)
+		outer := enc.Checkpoint()
+		require.NoError(t, enc.WriteByte(0x01))
+		inner := enc.Checkpoint()
+		require.NoError(t, enc.WriteByte(0x02))
+		require.NoError(t, enc.Commit(inner))
+		require.NoError(t, enc.WriteByte(0x03))
+		assert.Empty(t, buf.Bytes())
+		require.NoError(t, enc.Commit(outer))
+		assert.Equal(t, []byte{0x01, 0x02, 0x03}, buf.Bytes())
+	})
+	t.Run("rollback releases the later checkpoints", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		enc := NewBinEncoder(buf)
+		outer := enc.Checkpoint()
+		require.NoError(t, enc.WriteByte(0x01))
+		inner := enc.Checkpoint()
+		require.NoError(t, enc.WriteByte(0x02))
+		require.NoError(t, enc.Rollback(outer))
+		assert.True(t, errors.Is(enc.Commit(inner), ErrInvalidCheckpoint))
+		assert.True(t, errors.Is(enc.Rollback(outer), ErrInvalidCheckpoint))
+		require.NoError(t, enc.WriteByte(0x03))
+		assert.Equal(t, []byte{0x03}, buf.Bytes())
+		assert.Equal(t, 1, enc.Written())
+	})
+	t.Run("stale checkpoint", func(t *testing.T) {
+		enc := NewBinEncoder(new(bytes.Buffer))
+		first := enc.Checkpoint()
+		require.NoError(t, enc.Commit(first))
+		second := enc.Checkpoint()
+		assert.True(t, errors.Is(enc.Rollback(first), ErrInvalidCheckpoint))
+		require.NoError(t, enc.Commit(second))
+		assert.True(t, errors.Is(enc.Commit(Checkpoint{}), ErrInvalidCheckpoint))
+	})
+	t.Run("max size is restored", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		enc := NewBinEncoder(buf).WithMaxEncodedSize(2)
+		cp := enc.Checkpoint()
+		require.NoError(t, enc.WriteUint16(1, LE))
+		require.Error(t, enc.WriteByte(0x01))
+		require.NoError(t, enc.Rollback(cp))
+		require.NoError(t, enc.WriteUint16(2, LE))
+		assert.Equal(t, []byte{0x02, 0x00}, buf.Bytes())
+	})
+	t.Run("tee during a checkpoint", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		tee := new(bytes.Buffer)
+		enc := NewBinEncoder(buf)
+		require.NoError(t, enc.WriteByte(0x09))
+		cp := enc.Checkpoint()
+		require.NoError(t, enc.WriteByte(0x01))
+		enc.Tee(tee)
+		require.NoError(t, enc.WriteByte(0x02))
+		assert.Empty(t, tee.Bytes())
+		require.NoError(t, enc.Commit(cp))
+		require.NoError(t, enc.WriteByte(0x03))
+		assert.Equal(t, []byte{0x09, 0x01, 0x02, 0x03}, buf.Bytes())
+		assert.Equal(t, []byte{0x01, 0x02, 0x03}, tee.Bytes())
+
+		// Rolled back output doesn't reach the tee.
+		tee.Reset()
+		cp = enc.Checkpoint()
+		enc.Tee(new(bytes.Buffer))
+		require.NoError(t, enc.WriteByte(0x04))
+		require.NoError(t, enc.Rollback(cp))
+		require.NoError(t, enc.WriteByte(0x05))
+		assert.Equal(t, []byte{0x05}, tee.Bytes())
+	})
+}
@@ -0,0 +1,54 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+)
+
+// A Codec marshals and unmarshals values with a given encoding. It's implemented
+// by BinCodec, BorshCodec and CompactU16Codec, so that code can accept any
+// of them, or another implementation (e.g. one that records or fails calls in tests).
+type Codec interface {
+	// Name returns the name of the encoding, e.g. "Borsh".
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal decodes data into v, which must be a pointer.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	BinCodec        Codec = encodingCodec(EncodingBin)
+	BorshCodec      Codec = encodingCodec(EncodingBorsh)
+	CompactU16Codec Codec = encodingCodec(EncodingCompactU16)
+)
+
+type encodingCodec Encoding
+
+func (c encodingCodec) Name() string {
+	return Encoding(c).String()
+}
+
+func (c encodingCodec) Marshal(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := NewEncoderWithEncoding(buf, Encoding(c)).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c encodingCodec) Unmarshal(data []byte, v interface{}) error {
+	return NewDecoderWithEncoding(data, Encoding(c)).Decode(v)
+}
@@ -0,0 +1,56 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec(t *testing.T) {
+	type msg struct {
+		A uint16
+		B string
+		C []uint8
+	}
+	in := msg{A: 7, B: "hi", C: []uint8{1, 2}}
+	for _, tc := range []struct {
+		codec   Codec
+		name    string
+		marshal func(interface{}) ([]byte, error)
+	}{
+		{BinCodec, "Bin", MarshalBin},
+		{BorshCodec, "Borsh", MarshalBorsh},
+		{CompactU16Codec, "CompactU16", MarshalCompactU16},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.name, tc.codec.Name())
+
+			data, err := tc.codec.Marshal(in)
+			require.NoError(t, err)
+			expected, err := tc.marshal(in)
+			require.NoError(t, err)
+			assert.Equal(t, expected, data)
+
+			var out msg
+			require.NoError(t, tc.codec.Unmarshal(data, &out))
+			assert.Equal(t, in, out)
+
+			require.Error(t, tc.codec.Unmarshal(data[:1], &out))
+		})
+	}
+}
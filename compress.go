@@ -0,0 +1,261 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sync"
+)
+
+// MaxDecompressedSize is the largest size a compressed field can decompress to;
+// larger ones are rejected, so that small inputs can't claim huge amounts of memory.
+const MaxDecompressedSize = 16 << 20
+
+// ErrUnknownDictionary is returned when compressed data refers
+// to a dictionary that isn't registered.
+var ErrUnknownDictionary = errors.New("unknown compression dictionary")
+
+// A Compressor compresses and decompresses streams with a preset dictionary,
+// which is nil when no dictionary is used.
+type Compressor interface {
+	NewWriter(w io.Writer, dict []byte) (io.WriteCloser, error)
+	NewReader(r io.Reader, dict []byte) (io.ReadCloser, error)
+}
+
+// FlateCompressor is the default Compressor: DEFLATE, from compress/flate.
+// Level is a compress/flate level; zero means flate.BestCompression, as the faster
+// levels store small inputs, like single messages, without compressing them.
+//
+// Other algorithms, like zstd, which also supports dictionaries, can be used
+// by setting another Compressor with SetCompressor or WithCompressor.
+type FlateCompressor struct {
+	Level int
+}
+
+func (c FlateCompressor) NewWriter(w io.Writer, dict []byte) (io.WriteCloser, error) {
+	level := c.Level
+	if level == 0 {
+		level = flate.BestCompression
+	}
+	return flate.NewWriterDict(w, level, dict)
+}
+
+func (c FlateCompressor) NewReader(r io.Reader, dict []byte) (io.ReadCloser, error) {
+	return flate.NewReaderDict(r, dict), nil
+}
+
+// The default compressor and the registered dictionaries are shared by
+// the whole program; they're guarded by compressionMu.
+var (
+	compressionMu sync.RWMutex
+	compressor    Compressor = FlateCompressor{}
+	dictionaries             = map[uint32][]byte{}
+)
+
+// SetCompressor sets the default Compressor, used by compressed streams
+// and by the encoders and decoders without their own (see WithCompressor).
+// Both sides must use the same one. It's safe to call concurrently with
+// encoding and decoding, but the values being encoded or decoded meanwhile
+// may use either compressor, so it's meant to be called at initialization.
+func SetCompressor(c Compressor) {
+	compressionMu.Lock()
+	defer compressionMu.Unlock()
+	compressor = c
+}
+
+// RegisterCompressionDictionary registers a preset dictionary, e.g. one trained
+// on a corpus of messages, under the provided ID, which must not be zero:
+// zero means no dictionary. Small messages compress poorly without a dictionary.
+//
+// Compressed data starts with the ID of its dictionary, so the dictionaries used
+// to compress data must stay registered for as long as that data can be decompressed;
+// new dictionaries are rolled out under new IDs. Dictionaries are shared by
+// all the encoders and decoders, and can be registered while they're used.
+func RegisterCompressionDictionary(id uint32, dict []byte) {
+	if id == 0 {
+		panic("RegisterCompressionDictionary: the dictionary ID 0 is reserved")
+	}
+	compressionMu.Lock()
+	defer compressionMu.Unlock()
+	dictionaries[id] = dict
+}
+
+// WithCompressor sets the Compressor of the fields tagged `bin:"compress"`
+// written by the encoder, instead of the default one (see SetCompressor).
+func (e *Encoder) WithCompressor(c Compressor) *Encoder {
+	e.compressor = c
+	return e
+}
+
+// WithCompressor sets the Compressor of the fields tagged `bin:"compress"`
+// read by the decoder, instead of the default one (see SetCompressor).
+func (dec *Decoder) WithCompressor(c Compressor) *Decoder {
+	dec.compressor = c
+	return dec
+}
+
+// compression returns the compressor to use instead of c when it's nil,
+// and the dictionary with the provided ID.
+func compression(c Compressor, id uint32) (Compressor, []byte, error) {
+	compressionMu.RLock()
+	defer compressionMu.RUnlock()
+	if c == nil {
+		c = compressor
+	}
+	if id == 0 {
+		return c, nil, nil
+	}
+	dict, ok := dictionaries[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %d", ErrUnknownDictionary, id)
+	}
+	return c, dict, nil
+}
+
+// NewCompressedWriter returns a writer that compresses what's written to it
+// with the dictionary with the provided ID (zero for none), e.g. to compress
+// a stream of messages written by an Encoder; the ID of the dictionary
+// is written first. Close must be called to flush the stream.
+func NewCompressedWriter(w io.Writer, dictionary uint32) (io.WriteCloser, error) {
+	return newCompressedWriter(w, nil, dictionary)
+}
+
+func newCompressedWriter(w io.Writer, c Compressor, dictionary uint32) (io.WriteCloser, error) {
+	c, dict, err := compression(c, dictionary)
+	if err != nil {
+		return nil, err
+	}
+	var header [binary.MaxVarintLen32]byte
+	if _, err := w.Write(header[:binary.PutUvarint(header[:], uint64(dictionary))]); err != nil {
+		return nil, err
+	}
+	return c.NewWriter(w, dict)
+}
+
+// NewCompressedReader returns a reader that decompresses a stream
+// written by NewCompressedWriter.
+func NewCompressedReader(r io.Reader) (io.ReadCloser, error) {
+	return newCompressedReader(r, nil)
+}
+
+func newCompressedReader(r io.Reader, c Compressor) (io.ReadCloser, error) {
+	id, err := binary.ReadUvarint(byteReader{r})
+	if err != nil {
+		return nil, fmt.Errorf("compressed stream header: %w", err)
+	}
+	if id > 0xFFFF_FFFF {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownDictionary, id)
+	}
+	c, dict, err := compression(c, uint32(id))
+	if err != nil {
+		return nil, err
+	}
+	return c.NewReader(r, dict)
+}
+
+// byteReader reads one byte at a time, so that it doesn't read past the header.
+type byteReader struct {
+	io.Reader
+}
+
+func (r byteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(r.Reader, b[:])
+	return b[0], err
+}
+
+// compressBlock compresses data with c, or the default compressor if it's nil,
+// in a block starting with the ID of the dictionary.
+func compressBlock(c Compressor, data []byte, dictionary uint32) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w, err := newCompressedWriter(buf, c, dictionary)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompressBlock(c Compressor, block []byte) ([]byte, error) {
+	r, err := newCompressedReader(bytes.NewReader(block), c)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := ioutil.ReadAll(io.LimitReader(r, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > MaxDecompressedSize {
+		return nil, fmt.Errorf("decompressed size exceeds %d bytes", MaxDecompressedSize)
+	}
+	return out, nil
+}
+
+// isCompressibleType reports whether the `compress` tag applies to the provided type.
+func isCompressibleType(rt reflect.Type) bool {
+	return rt.Kind() == reflect.String || (rt.Kind() == reflect.Slice && rt.Elem().Kind() == reflect.Uint8)
+}
+
+// encodeCompressed writes strings and byte slices tagged `bin:"compress"` or
+// `bin:"compress=ID"` as byte slices holding the compressed block.
+func (e *Encoder) encodeCompressed(rv reflect.Value, opt *option) (bool, error) {
+	if !opt.Compress || !isCompressibleType(rv.Type()) {
+		return false, nil
+	}
+	var data []byte
+	if rv.Kind() == reflect.String {
+		data = []byte(rv.String())
+	} else {
+		data = rv.Bytes()
+	}
+	block, err := compressBlock(e.compressor, data, opt.Dictionary)
+	if err != nil {
+		return true, err
+	}
+	return true, e.WriteBytes(block, true)
+}
+
+func (dec *Decoder) decodeCompressed(rv reflect.Value, opt *option) (bool, error) {
+	if !opt.Compress || !isCompressibleType(rv.Type()) {
+		return false, nil
+	}
+	block, err := dec.ReadByteSlice()
+	if err != nil {
+		return true, err
+	}
+	data, err := decompressBlock(dec.compressor, block)
+	if err != nil {
+		return true, err
+	}
+	if rv.Kind() == reflect.String {
+		rv.SetString(string(data))
+	} else {
+		rv.SetBytes(data)
+	}
+	return true, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type compressedMessage struct {
+	ID      uint32
+	Body    string `bin:"compress"`
+	Payload []byte `bin:"compress=7"`
+}
+
+func TestCompressedFields(t *testing.T) {
+	RegisterCompressionDictionary(7, []byte(strings.Repeat("account transfer lamports ", 20)))
+
+	in := compressedMessage{
+		ID:      1,
+		Body:    strings.Repeat("hello ", 100),
+		Payload: []byte("transfer 10 lamports to account 4"),
+	}
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(in))
+			assert.Less(t, buf.Len(), len(in.Body))
+
+			var out compressedMessage
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out))
+			assert.Equal(t, in, out)
+
+			require.NoError(t, ConformsWithEncoding(buf.Bytes(), enc, compressedMessage{}))
+			res, err := QueryWithEncoding(buf.Bytes(), enc, compressedMessage{}, "Payload")
+			require.NoError(t, err)
+			assert.Equal(t, in.Payload, res.Value)
+		})
+	}
+}
+
+func TestCompressedFields_Dictionary(t *testing.T) {
+	dict := []byte("The quick brown fox jumps over the lazy dog; pack my box with five dozen liquor jugs.")
+	RegisterCompressionDictionary(8, dict)
+
+	msg := []byte("pack my box with five dozen liquor jugs")
+	withDict, err := compressBlock(nil, msg, 8)
+	require.NoError(t, err)
+	withoutDict, err := compressBlock(nil, msg, 0)
+	require.NoError(t, err)
+	assert.Less(t, len(withDict), len(withoutDict))
+	assert.Equal(t, byte(8), withDict[0], "the block starts with the dictionary ID")
+
+	out, err := decompressBlock(nil, withDict)
+	require.NoError(t, err)
+	assert.Equal(t, msg, out)
+
+	withDict[0] = 99
+	_, err = decompressBlock(nil, withDict)
+	assert.True(t, errors.Is(err, ErrUnknownDictionary))
+}
+
+// storedCompressor "compresses" by copying the data as is.
+type storedCompressor struct{}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func (storedCompressor) NewWriter(w io.Writer, dict []byte) (io.WriteCloser, error) {
+	return nopWriteCloser{w}, nil
+}
+
+func (storedCompressor) NewReader(r io.Reader, dict []byte) (io.ReadCloser, error) {
+	return ioutil.NopCloser(r), nil
+}
+
+func TestCompressedFields_WithCompressor(t *testing.T) {
+	type storedMessage struct {
+		ID   uint32
+		Body string `bin:"compress"`
+	}
+	in := storedMessage{ID: 1, Body: "stored as is"}
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).WithCompressor(storedCompressor{}).Encode(in))
+	assert.Contains(t, buf.String(), in.Body)
+
+	var out storedMessage
+	require.NoError(t, NewBinDecoder(buf.Bytes()).WithCompressor(storedCompressor{}).Decode(&out))
+	assert.Equal(t, in, out)
+
+	// The other encoders and decoders still use the default compressor.
+	assert.Error(t, NewBinDecoder(buf.Bytes()).Decode(&out))
+	data, err := MarshalBin(in)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), in.Body)
+}
+
+func TestCompressedStream(t *testing.T) {
+	RegisterCompressionDictionary(9, []byte("some dictionary"))
+
+	buf := new(bytes.Buffer)
+	w, err := NewCompressedWriter(buf, 9)
+	require.NoError(t, err)
+	enc := NewBorshEncoder(w)
+	for i := uint32(0); i < 3; i++ {
+		require.NoError(t, enc.Encode(compressedMessage{ID: i, Body: "some dictionary"}))
+	}
+	require.NoError(t, w.Close())
+
+	r, err := NewCompressedReader(buf)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	dec := NewBorshDecoder(data)
+	for i := uint32(0); i < 3; i++ {
+		var out compressedMessage
+		require.NoError(t, dec.Decode(&out))
+		assert.Equal(t, i, out.ID)
+		assert.Equal(t, "some dictionary", out.Body)
+	}
+	assert.Equal(t, 0, dec.Remaining())
+
+	_, err = NewCompressedWriter(new(bytes.Buffer), 12345)
+	assert.True(t, errors.Is(err, ErrUnknownDictionary))
+}
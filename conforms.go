@@ -0,0 +1,467 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Conforms checks that the Bin-encoded data decodes cleanly into a value
+// of the type of `typ` (a value or a pointer to a value), without decoding it:
+// it performs the same reads and bounds checks as the decoder, following
+// the layout of the type, but doesn't build any value, so it's a cheap
+// way to reject malformed data before queuing an expensive full decode.
+//
+// Values with custom decoders (other than the ones of this package
+// that have a known layout) are decoded, as there is no other way to check them.
+// Like the decoder, it doesn't reject trailing bytes.
+func Conforms(data []byte, typ interface{}) error {
+	return ConformsWithEncoding(data, EncodingBin, typ)
+}
+
+// ConformsWithEncoding is like Conforms, but for data encoded with the provided encoding.
+func ConformsWithEncoding(data []byte, enc Encoding, typ interface{}) error {
+	rt := reflect.TypeOf(typ)
+	if rt == nil {
+		return fmt.Errorf("conforms: nil type")
+	}
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	layout, err := cachedLayout(rt, enc)
+	if err != nil {
+		return fmt.Errorf("conforms: %s: %w", rt, err)
+	}
+	dec := NewDecoderWithEncoding(data, enc)
+	if err := dec.conforms(layout, nil); err != nil {
+		return fmt.Errorf("conforms: %s: %w", rt, err)
+	}
+	return nil
+}
+
+// layouts caches the layouts used by Conforms, by layoutKey.
+var layouts sync.Map
+
+type layoutKey struct {
+	typ reflect.Type
+	enc Encoding
+}
+
+func cachedLayout(rt reflect.Type, enc Encoding) (*layoutNode, error) {
+	key := layoutKey{rt, enc}
+	if layout, ok := layouts.Load(key); ok {
+		return layout.(*layoutNode), nil
+	}
+	layout, err := describeType(rt, enc)
+	if err != nil {
+		return nil, err
+	}
+	layouts.Store(key, layout)
+	return layout, nil
+}
+
+// conforms reads past a value laid out as described by the provided node;
+// counters holds the values of the `sizeof` fields of the parent struct.
+func (dec *Decoder) conforms(n *layoutNode, counters map[string]int) error {
+	for level := 0; n.Presence != presenceNone && level < n.levels(); level++ {
+		var present bool
+		if n.Presence == presenceUint32 {
+			flag, err := dec.ReadUint32(LE)
+			if err != nil {
+				return fmt.Errorf("presence flag: %w", err)
+			}
+			present = flag != 0
+		} else {
+			flag, err := dec.ReadUint8()
+			if err != nil {
+				return fmt.Errorf("presence flag: %w", err)
+			}
+			present = flag != 0
+		}
+		if !present {
+			return nil
+		}
+	}
+	if size := n.valueSize(); size >= 0 {
+		return dec.conformsFixed(size)
+	}
+
+	switch n.Wire {
+	case wireUvarint:
+		_, err := dec.ReadUvarint64()
+		return err
+	case wireVarint:
+		_, err := dec.ReadVarint64()
+		return err
+	case wireVLQ:
+		_, err := dec.ReadVLQ()
+		return err
+	case wireSQLiteVarint:
+		_, err := dec.ReadSQLiteVarint()
+		return err
+	case wireGroupVarint:
+		l, err := dec.readLength(n, counters)
+		if err != nil {
+			return err
+		}
+		return dec.readGroupVarint(l, n.Type.Elem().Kind() == reflect.Uint64, func(int, uint64) {})
+	case wireRLE:
+		l, err := dec.readLength(n, counters)
+		if err != nil {
+			return err
+		}
+		isBool := n.Type.Elem().Kind() == reflect.Bool
+		return dec.readRuns(l, int(n.Type.Elem().Size()), n.Order, func(start, end int, v uint64) error {
+			if isBool && v > 1 {
+				return fmt.Errorf("invalid bool %d", v)
+			}
+			return nil
+		})
+	case wireDelta:
+		l, err := dec.readLength(n, counters)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < l; i++ {
+			if _, err := dec.ReadVarint64(); err != nil {
+				return newElementError("decoding", i, err)
+			}
+		}
+		return nil
+	case wireRuneUTF8:
+		_, err := dec.ReadRuneUTF8()
+		return err
+	case wireString, wireBytes, wireCompressed, wireEncrypted:
+		if n.Prefix == prefixNUL {
+			_, err := dec.readCStringBytes(n.Length)
+			return err
+		}
+		if n.Type.Kind() == reflect.Array && n.Prefix != prefixSizeOf {
+			return dec.conformsFixed(n.Size)
+		}
+		l, err := dec.readLength(n, counters)
+		if err != nil {
+			return err
+		}
+		if n.Type.Kind() == reflect.Array && l > n.Length {
+			return fmt.Errorf("sizeof field holds %d, larger than %s", l, n.Type)
+		}
+		if n.Elem != nil {
+			l *= n.Elem.Size
+		}
+		return dec.conformsFixed(l)
+	case wireSlice, wireArray:
+		l := n.Length
+		if n.Wire == wireSlice {
+			var err error
+			if l, err = dec.readLength(n, counters); err != nil {
+				return err
+			}
+			if l > dec.Remaining() {
+				return io.ErrUnexpectedEOF
+			}
+			// The size of the element type doesn't apply to varints.
+			if n.Elem.Wire != wireVLQ && n.Elem.Wire != wireSQLiteVarint {
+				if err := dec.checkLength(l, n.Type.Elem()); err != nil {
+					return err
+				}
+			}
+		}
+		if n.Elem.isFixed() {
+			return dec.conformsFixed(l * n.Elem.Size)
+		}
+		for i := 0; i < l; i++ {
+			if err := dec.conforms(n.Elem, nil); err != nil {
+				return newElementError("decoding", i, err)
+			}
+		}
+		return nil
+	case wireMap:
+		l, err := dec.readLength(n, counters)
+		if err != nil {
+			return err
+		}
+		if err := dec.checkLength(l, n.Type.Key(), n.Type.Elem()); err != nil {
+			return err
+		}
+		for i := 0; i < l; i++ {
+			if err := dec.conforms(n.Key, nil); err != nil {
+				return err
+			}
+			if err := dec.conforms(n.Elem, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	case wireEnum:
+		index, err := dec.ReadUint8()
+		if err != nil {
+			return err
+		}
+		if int(index) >= len(n.Fields) {
+			return errors.New("complex enum too large")
+		}
+		variant := n.Fields[index]
+		if err := dec.conforms(variant, nil); err != nil {
+			return newFieldError("decoding", variant.Name, err)
+		}
+		return nil
+	case wireUnion:
+		value, ok := counters[n.Union]
+		if !ok || value < 0 {
+			return fmt.Errorf("union discriminator %q not found", n.Union)
+		}
+		for _, variant := range n.Fields {
+			if variant.Name == strconv.Itoa(value) {
+				return dec.conforms(variant, nil)
+			}
+		}
+		return fmt.Errorf("no variant of union %s for discriminator %d", n.Type, value)
+	case wireStruct:
+		return dec.conformsStruct(n)
+	case wireCustom:
+		return dec.decodeField(reflect.New(n.Type), newDefaultOption())
+	case wireNothing:
+		return nil
+	default:
+		return fmt.Errorf("unsupported wire kind %s", n.Wire)
+	}
+}
+
+func (dec *Decoder) conformsStruct(n *layoutNode) error {
+	_, _, err := dec.walkStruct(n, false)
+	return err
+}
+
+// A fieldSpan is the position of an encoded struct field in the data,
+// including its presence flag; missing binary extensions are not present.
+type fieldSpan struct {
+	start, end int
+	present    bool
+}
+
+// walkStruct reads past the fields of a struct, and returns the layout of
+// the struct, along with the spans of its fields when record is set.
+func (dec *Decoder) walkStruct(n *layoutNode, record bool) (*layoutNode, []fieldSpan, error) {
+	if n.Recursive {
+		// The fields are described by the first node of that type.
+		layout, err := cachedLayout(n.Type, dec.encoding)
+		if err != nil {
+			return nil, nil, err
+		}
+		n = layout
+	}
+	var spans []fieldSpan
+	if record {
+		spans = make([]fieldSpan, len(n.Fields))
+	}
+	var counters map[string]int
+	for _, field := range n.Fields {
+		for _, counter := range []string{field.SizeOf, field.ByteSizeOf, field.Union} {
+			if counter == "" {
+				continue
+			}
+			if counters == nil {
+				counters = map[string]int{}
+			}
+			// Until the field is read.
+			counters[counter] = -1
+		}
+	}
+
+	for i, field := range n.Fields {
+		if field.Extension && !dec.HasRemaining() {
+			continue
+		}
+		start := dec.pos
+		var err error
+		if isCounter(field, counters) {
+			counters[field.Name], err = dec.readCounter(field)
+		} else if field.ByteSizeOf != "" {
+			err = dec.conformsByteSized(field, counters)
+		} else {
+			err = dec.conforms(field, counters)
+		}
+		if err == nil && field.Wire == wireStruct {
+			err = dec.readSizeOfPaths(n, field, start, counters)
+		}
+		if err != nil {
+			return nil, nil, newFieldError("decoding", field.Name, err)
+		}
+		if record {
+			spans[i] = fieldSpan{start: start, end: dec.pos, present: true}
+		}
+	}
+	return n, spans, nil
+}
+
+// readSizeOfPaths looks up the values of the dotted `sizeof` paths starting
+// at a struct field, e.g. Header.Count, by decoding the field that was just read.
+func (dec *Decoder) readSizeOfPaths(n, field *layoutNode, start int, counters map[string]int) error {
+	var scratch reflect.Value
+	for _, target := range n.Fields {
+		if !strings.HasPrefix(target.SizeOf, field.Name+".") {
+			continue
+		}
+		if !scratch.IsValid() {
+			scratch = reflect.New(n.Type).Elem()
+			sub := NewDecoderWithEncoding(dec.data[start:dec.pos], dec.encoding)
+			plan := planOf(n.Type)
+			if err := sub.Decode(plan.field(scratch, plan.fieldIndex(field.Name)).Addr().Interface()); err != nil {
+				return err
+			}
+		}
+		v, err := sizeOfPathValue(scratch, target.SizeOf)
+		if err != nil {
+			return err
+		}
+		counters[target.SizeOf] = sizeof(v.Type(), v)
+	}
+	return nil
+}
+
+// conformsByteSized checks a field whose size in bytes is held by a `bytesizeof` field.
+func (dec *Decoder) conformsByteSized(n *layoutNode, counters map[string]int) error {
+	size, ok := counters[n.ByteSizeOf]
+	if !ok || size < 0 {
+		return fmt.Errorf("invalid size in field %q", n.ByteSizeOf)
+	}
+	sub, err := dec.SubDecoder(size)
+	if err != nil {
+		return err
+	}
+	if err := sub.conforms(n, counters); err != nil {
+		return err
+	}
+	if sub.HasRemaining() {
+		return fmt.Errorf("bytesizeof: %d of the %d bytes left after the field", sub.Remaining(), size)
+	}
+	return nil
+}
+
+// isCounter reports whether the field holds the length of a `sizeof` slice
+// or the size of a `bytesizeof` field.
+func isCounter(n *layoutNode, counters map[string]int) bool {
+	_, ok := counters[n.Name]
+	if !ok || n.Presence != presenceNone {
+		return false
+	}
+	return n.Wire == wireVLQ || n.Wire == wireSQLiteVarint || ((n.Wire == wireUint || n.Wire == wireInt) && n.Size <= 8)
+}
+
+// readCounter reads an integer field that holds the length of a `sizeof` slice
+// or the size of a `bytesizeof` field.
+func (dec *Decoder) readCounter(n *layoutNode) (int, error) {
+	var v uint64
+	var err error
+	switch {
+	case n.Wire == wireVLQ:
+		v, err = dec.ReadVLQ()
+	case n.Wire == wireSQLiteVarint:
+		v, err = dec.ReadSQLiteVarint()
+	case n.Size == 1:
+		var b uint8
+		b, err = dec.ReadUint8()
+		v = uint64(b)
+	case n.Size == 2:
+		var u uint16
+		u, err = dec.ReadUint16(n.Order)
+		v = uint64(u)
+	case n.Size == 4:
+		var u uint32
+		u, err = dec.ReadUint32(n.Order)
+		v = uint64(u)
+	default:
+		v, err = dec.ReadUintN(n.Size, n.Order)
+	}
+	if err != nil {
+		return 0, err
+	}
+	v = swapBits(v, n.Size, false, n.BitReverse)
+	if n.Wire == wireInt {
+		// Sign-extend the value.
+		shift := 64 - 8*uint(n.Size)
+		return int(int64(v<<shift) >> shift), nil
+	}
+	if int(v) < 0 {
+		return 0, nil
+	}
+	return int(v), nil
+}
+
+// readLength reads the length prefix of a value, or looks up the
+// value of its `sizeof` field.
+func (dec *Decoder) readLength(n *layoutNode, counters map[string]int) (int, error) {
+	var l uint64
+	var err error
+	switch n.Prefix {
+	case prefixSizeOf:
+		count, ok := counters[n.SizeOf]
+		if !ok || count < 0 {
+			return 0, fmt.Errorf("invalid length in field %q", n.SizeOf)
+		}
+		size, err := applySizeFunc(n.SizeFunc, count)
+		if err == nil {
+			size, err = applySizeExpr(n.SizeExpr, size)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("field %q: %w", n.SizeOf, err)
+		}
+		l = uint64(size)
+	case prefixUvarint:
+		l, err = dec.ReadUvarint64()
+	case prefixUint8:
+		var u uint8
+		u, err = dec.ReadUint8()
+		l = uint64(u)
+	case prefixUint16:
+		var u uint16
+		u, err = dec.ReadUint16(n.prefixOrder())
+		l = uint64(u)
+	case prefixUint32:
+		var u uint32
+		u, err = dec.ReadUint32(n.prefixOrder())
+		l = uint64(u)
+	case prefixUint64:
+		l, err = dec.ReadUint64(n.prefixOrder())
+	case prefixCompactU16:
+		var u int
+		u, err = dec.ReadCompactU16()
+		l = uint64(u)
+	default:
+		return 0, fmt.Errorf("unexpected length prefix %s", n.Prefix)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if l > uint64(dec.Remaining()) {
+		return 0, fmt.Errorf("length %d exceeds the remaining [%d] bytes: %w", l, dec.Remaining(), io.ErrUnexpectedEOF)
+	}
+	return int(l), nil
+}
+
+func (dec *Decoder) conformsFixed(size int) error {
+	if size > dec.Remaining() {
+		return fmt.Errorf("%d bytes required, remaining [%d]: %w", size, dec.Remaining(), io.ErrUnexpectedEOF)
+	}
+	dec.skip(size)
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type conformsItem struct {
+	Name   string
+	Amount Uint64
+	Flags  []uint16
+}
+
+type conformsOrder struct {
+	ID      uint32 `bin:"big"`
+	Initial rune   `bin:"utf8"`
+	Count   uint8  `bin:"sizeof=Items"`
+	Items   []conformsItem
+	Tags    map[string]uint8
+	Hash    [4]byte
+	Note    *string `bin:"optional"`
+	Varint  Varint32
+	Memo    []byte `bin:"binary_extension"`
+}
+
+type conformsEnum struct {
+	Enum  BorshEnum `borsh_enum:"true"`
+	One   EmptyVariant
+	Two   conformsItem
+	Three int16
+}
+
+type conformsTree struct {
+	Value    uint16
+	Children []conformsTree
+}
+
+func TestConforms(t *testing.T) {
+	note := "note"
+	values := []interface{}{
+		conformsOrder{
+			ID:      7,
+			Initial: 'é',
+			Count:   2,
+			Items:   []conformsItem{{Name: "a", Amount: 1, Flags: []uint16{1, 2}}, {Name: "bc"}},
+			Tags:    map[string]uint8{"x": 1},
+			Hash:    [4]byte{1, 2, 3, 4},
+			Note:    &note,
+			Varint:  -300,
+			Memo:    []byte{9},
+		},
+		conformsTree{Value: 1, Children: []conformsTree{{Value: 2}, {Value: 3, Children: []conformsTree{{Value: 4}}}}},
+	}
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		for _, value := range append(values, conformsEnum{Enum: 1, Two: conformsItem{Name: "x"}}) {
+			if _, ok := value.(conformsEnum); ok && !enc.IsBorsh() {
+				continue
+			}
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(value))
+			data := buf.Bytes()
+			require.NoError(t, ConformsWithEncoding(data, enc, value), "%s %T", enc, value)
+
+			// Every truncation that fails to decode must not conform, and vice versa.
+			for i := 0; i < len(data); i++ {
+				decodeErr := NewDecoderWithEncoding(data[:i], enc).Decode(reflect.New(reflect.TypeOf(value)).Interface())
+				conformsErr := ConformsWithEncoding(data[:i], enc, value)
+				assert.Equal(t, decodeErr == nil, conformsErr == nil, "%s %T truncated to %d bytes: %v, %v", enc, value, i, decodeErr, conformsErr)
+			}
+		}
+	}
+}
+
+func TestConforms_Errors(t *testing.T) {
+	item := []byte{1, 0, 0, 0, 0, 0, 0, 0, 'a', 1, 0, 0, 0, 0, 0, 0, 0, 0}
+	// 5 items are announced, but the remaining bytes can't hold them.
+	data := append([]byte{0, 0, 0, 7, 'a', 5}, item...)
+	err := Conforms(data, &conformsOrder{})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	assert.Equal(t, "Items", FieldPath(err))
+
+	// 2 items are announced, but there is one.
+	data = append([]byte{0, 0, 0, 7, 'a', 2}, item...)
+	err = Conforms(data, &conformsOrder{})
+	require.Error(t, err)
+	assert.Equal(t, "Items[1].Name", FieldPath(err))
+
+	err = ConformsWithEncoding([]byte{5}, EncodingBorsh, conformsEnum{})
+	assert.EqualError(t, err, "conforms: bin.conformsEnum: complex enum too large")
+
+	assert.EqualError(t, Conforms(nil, nil), "conforms: nil type")
+}
+
+func TestConforms_Allocations(t *testing.T) {
+	data, err := MarshalBin(conformsItem{Name: "name", Amount: 3, Flags: []uint16{1, 2, 3}})
+	require.NoError(t, err)
+	require.NoError(t, Conforms(data, conformsItem{}))
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := Conforms(data, (*conformsItem)(nil)); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// Only the decoder is allocated.
+	assert.LessOrEqual(t, allocs, 1.0)
+}
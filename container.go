@@ -0,0 +1,318 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A container holds encoded messages and a trailing index of their offsets,
+// lengths and (optional) keys, so that a reader can decode record N or
+// the record with key K directly, without scanning the records before it:
+//
+//	header:  "BINC", u8 version, u8 encoding of the records
+//	records: the encoded records, one after the other
+//	index:   uvarint number of records, then for each record its uvarint offset
+//	         from the start of the container, uvarint length and key (a uvarint
+//	         length-prefixed string, empty for records without a key)
+//	footer:  u64 LE offset of the index, "BINI"
+const (
+	containerMagic      = "BINC"
+	containerIndexMagic = "BINI"
+	containerVersion    = 1
+	containerHeaderSize = len(containerMagic) + 2
+	containerFooterSize = 8 + len(containerIndexMagic)
+)
+
+var (
+	// ErrInvalidContainer is returned when opening data that isn't a container
+	// written by ContainerWriter, or one that is truncated or corrupted.
+	ErrInvalidContainer = errors.New("invalid container")
+	// ErrRecordNotFound is returned when reading a record that isn't in a container.
+	ErrRecordNotFound = errors.New("record not found")
+)
+
+type containerEntry struct {
+	offset uint64
+	length uint64
+	key    string
+}
+
+// A ContainerWriter writes messages to a container (see OpenContainer):
+//
+//	cw := bin.NewContainerWriter(file, bin.EncodingBorsh)
+//	for _, tx := range txs {
+//		if err := cw.AppendWithKey(tx.Signature, tx); err != nil {
+//			return err
+//		}
+//	}
+//	if err := cw.Close(); err != nil {
+//		return err
+//	}
+//
+// The index is kept in memory until Close writes it. Errors are sticky: after
+// a failed write, the following calls return the same error.
+type ContainerWriter struct {
+	w       io.Writer
+	enc     Encoding
+	offset  uint64
+	entries []containerEntry
+	keys    map[string]bool
+	err     error
+	closed  bool
+}
+
+// NewContainerWriter returns a writer of a container of messages encoded
+// with the provided encoding. Nothing is written before the first record.
+func NewContainerWriter(w io.Writer, enc Encoding) *ContainerWriter {
+	if !isValidEncoding(enc) {
+		panic(fmt.Sprintf("provided encoding is not valid: %s", enc))
+	}
+	return &ContainerWriter{
+		w:    w,
+		enc:  enc,
+		keys: map[string]bool{},
+	}
+}
+
+// Len returns the number of records appended so far.
+func (cw *ContainerWriter) Len() int {
+	return len(cw.entries)
+}
+
+// Append encodes v as the next record, without a key.
+func (cw *ContainerWriter) Append(v interface{}) error {
+	return cw.AppendWithKey("", v)
+}
+
+// AppendWithKey encodes v as the next record, which can then be read
+// by key with ContainerReader.DecodeKey. Keys must be unique;
+// an empty key means that the record has none.
+func (cw *ContainerWriter) AppendWithKey(key string, v interface{}) error {
+	if cw.err != nil {
+		return cw.err
+	}
+	if cw.closed {
+		return errors.New("container: append after close")
+	}
+	if key != "" && cw.keys[key] {
+		return fmt.Errorf("container: duplicate key %q", key)
+	}
+	data, err := encodingCodec(cw.enc).Marshal(v)
+	if err != nil {
+		return fmt.Errorf("container: record %d: %w", len(cw.entries), err)
+	}
+	if err := cw.write(data); err != nil {
+		return err
+	}
+	cw.entries = append(cw.entries, containerEntry{
+		offset: cw.offset - uint64(len(data)),
+		length: uint64(len(data)),
+		key:    key,
+	})
+	if key != "" {
+		cw.keys[key] = true
+	}
+	return nil
+}
+
+// Close writes the index and the footer; it doesn't close the underlying writer.
+func (cw *ContainerWriter) Close() error {
+	if cw.err != nil || cw.closed {
+		return cw.err
+	}
+	cw.closed = true
+
+	w := NewWriter().Uvarint(uint64(len(cw.entries)))
+	for _, entry := range cw.entries {
+		w.Uvarint(entry.offset).Uvarint(entry.length).Str(entry.key)
+	}
+	index, err := w.Result()
+	if err != nil {
+		return fmt.Errorf("container: index: %w", err)
+	}
+	if err := cw.write(index); err != nil {
+		return err
+	}
+	footer, _ := NewWriter().U64LE(cw.offset - uint64(len(index))).Bytes([]byte(containerIndexMagic)).Result()
+	return cw.write(footer)
+}
+
+// write writes b, after the header if nothing was written yet.
+func (cw *ContainerWriter) write(b []byte) error {
+	if cw.offset == 0 {
+		header := append([]byte(containerMagic), containerVersion, byte(cw.enc))
+		if err := cw.writeAll(header); err != nil {
+			return err
+		}
+	}
+	return cw.writeAll(b)
+}
+
+func (cw *ContainerWriter) writeAll(b []byte) error {
+	n, err := cw.w.Write(b)
+	cw.offset += uint64(n)
+	if err == nil && n < len(b) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		cw.err = fmt.Errorf("container: %w", err)
+	}
+	return cw.err
+}
+
+// A ContainerReader reads the records of a container written by ContainerWriter.
+// Only the index is read when opening it; records are read on demand.
+// It's safe for concurrent use if the underlying io.ReaderAt is.
+type ContainerReader struct {
+	r       io.ReaderAt
+	enc     Encoding
+	entries []containerEntry
+	keys    map[string]int
+}
+
+// OpenContainer reads the index of the container of the provided size held by r
+// (e.g. an *os.File, or a *bytes.Reader).
+func OpenContainer(r io.ReaderAt, size int64) (*ContainerReader, error) {
+	if size < int64(containerHeaderSize+containerFooterSize) {
+		return nil, fmt.Errorf("container: size %d is too small: %w", size, ErrInvalidContainer)
+	}
+	header := make([]byte, containerHeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("container: header: %w", err)
+	}
+	if string(header[:len(containerMagic)]) != containerMagic || header[4] != containerVersion {
+		return nil, fmt.Errorf("container: bad header: %w", ErrInvalidContainer)
+	}
+	enc := Encoding(header[5])
+	if !isValidEncoding(enc) {
+		return nil, fmt.Errorf("container: invalid encoding %d: %w", enc, ErrInvalidContainer)
+	}
+
+	footer := make([]byte, containerFooterSize)
+	if _, err := r.ReadAt(footer, size-int64(containerFooterSize)); err != nil {
+		return nil, fmt.Errorf("container: footer: %w", err)
+	}
+	fr := NewReader(footer)
+	indexOffset := fr.U64LE()
+	if string(fr.Bytes(len(containerIndexMagic))) != containerIndexMagic {
+		return nil, fmt.Errorf("container: bad footer: %w", ErrInvalidContainer)
+	}
+	indexEnd := uint64(size) - uint64(containerFooterSize)
+	if indexOffset < uint64(containerHeaderSize) || indexOffset > indexEnd {
+		return nil, fmt.Errorf("container: index offset %d out of bounds: %w", indexOffset, ErrInvalidContainer)
+	}
+	index := make([]byte, indexEnd-indexOffset)
+	if _, err := r.ReadAt(index, int64(indexOffset)); err != nil {
+		return nil, fmt.Errorf("container: index: %w", err)
+	}
+
+	ir := NewReader(index)
+	count := ir.Uvarint()
+	if ir.Err() == nil && count > uint64(len(index)) {
+		// Each entry takes at least three bytes.
+		return nil, fmt.Errorf("container: %d records in an index of %d bytes: %w", count, len(index), ErrInvalidContainer)
+	}
+	cr := &ContainerReader{
+		r:       r,
+		enc:     enc,
+		entries: make([]containerEntry, 0, count),
+		keys:    map[string]int{},
+	}
+	for i := uint64(0); i < count && ir.Err() == nil; i++ {
+		entry := containerEntry{
+			offset: ir.Uvarint(),
+			length: ir.Uvarint(),
+			key:    ir.Str(),
+		}
+		if ir.Err() != nil {
+			break
+		}
+		if entry.offset < uint64(containerHeaderSize) || entry.length > indexOffset || entry.offset > indexOffset-entry.length {
+			return nil, fmt.Errorf("container: record %d out of bounds: %w", i, ErrInvalidContainer)
+		}
+		if entry.key != "" {
+			if _, ok := cr.keys[entry.key]; ok {
+				return nil, fmt.Errorf("container: duplicate key %q: %w", entry.key, ErrInvalidContainer)
+			}
+			cr.keys[entry.key] = len(cr.entries)
+		}
+		cr.entries = append(cr.entries, entry)
+	}
+	if err := ir.Err(); err != nil {
+		return nil, fmt.Errorf("container: index: %v: %w", err, ErrInvalidContainer)
+	}
+	return cr, nil
+}
+
+// Encoding returns the encoding of the records.
+func (cr *ContainerReader) Encoding() Encoding {
+	return cr.enc
+}
+
+// Len returns the number of records.
+func (cr *ContainerReader) Len() int {
+	return len(cr.entries)
+}
+
+// Key returns the key of record i, or an empty string if it has none.
+func (cr *ContainerReader) Key(i int) string {
+	if i < 0 || i >= len(cr.entries) {
+		return ""
+	}
+	return cr.entries[i].key
+}
+
+// Find returns the number of the record with the provided key.
+func (cr *ContainerReader) Find(key string) (int, bool) {
+	i, ok := cr.keys[key]
+	return i, ok
+}
+
+// Record returns the encoded bytes of record i.
+func (cr *ContainerReader) Record(i int) ([]byte, error) {
+	if i < 0 || i >= len(cr.entries) {
+		return nil, fmt.Errorf("container: record %d of %d: %w", i, len(cr.entries), ErrRecordNotFound)
+	}
+	entry := cr.entries[i]
+	data := make([]byte, entry.length)
+	if _, err := cr.r.ReadAt(data, int64(entry.offset)); err != nil {
+		return nil, fmt.Errorf("container: record %d: %w", i, err)
+	}
+	return data, nil
+}
+
+// Decode decodes record i into v, which must be a pointer.
+func (cr *ContainerReader) Decode(i int, v interface{}) error {
+	data, err := cr.Record(i)
+	if err != nil {
+		return err
+	}
+	if err := encodingCodec(cr.enc).Unmarshal(data, v); err != nil {
+		return fmt.Errorf("container: record %d: %w", i, err)
+	}
+	return nil
+}
+
+// DecodeKey decodes the record with the provided key into v, which must be a pointer.
+func (cr *ContainerReader) DecodeKey(key string, v interface{}) error {
+	i, ok := cr.keys[key]
+	if !ok {
+		return fmt.Errorf("container: key %q: %w", key, ErrRecordNotFound)
+	}
+	return cr.Decode(i, v)
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type containerRecord struct {
+	ID   uint64
+	Name string
+}
+
+func writeTestContainer(t *testing.T, enc Encoding) []byte {
+	buf := new(bytes.Buffer)
+	cw := NewContainerWriter(buf, enc)
+	require.NoError(t, cw.Append(containerRecord{ID: 1, Name: "one"}))
+	require.NoError(t, cw.AppendWithKey("two", containerRecord{ID: 2, Name: "two"}))
+	require.NoError(t, cw.AppendWithKey("three", containerRecord{ID: 3, Name: "three"}))
+	assert.Error(t, cw.AppendWithKey("two", containerRecord{}))
+	assert.Equal(t, 3, cw.Len())
+	require.NoError(t, cw.Close())
+	assert.Error(t, cw.Append(containerRecord{}))
+	return buf.Bytes()
+}
+
+func TestContainer(t *testing.T) {
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			data := writeTestContainer(t, enc)
+
+			cr, err := OpenContainer(bytes.NewReader(data), int64(len(data)))
+			require.NoError(t, err)
+			assert.Equal(t, enc, cr.Encoding())
+			assert.Equal(t, 3, cr.Len())
+			assert.Equal(t, "", cr.Key(0))
+			assert.Equal(t, "three", cr.Key(2))
+
+			var got containerRecord
+			require.NoError(t, cr.Decode(2, &got))
+			assert.Equal(t, containerRecord{ID: 3, Name: "three"}, got)
+			require.NoError(t, cr.DecodeKey("two", &got))
+			assert.Equal(t, containerRecord{ID: 2, Name: "two"}, got)
+
+			raw, err := cr.Record(0)
+			require.NoError(t, err)
+			expected, err := encodingCodec(enc).Marshal(containerRecord{ID: 1, Name: "one"})
+			require.NoError(t, err)
+			assert.Equal(t, expected, raw)
+
+			err = cr.Decode(3, &got)
+			assert.True(t, errors.Is(err, ErrRecordNotFound), err)
+			err = cr.DecodeKey("four", &got)
+			assert.True(t, errors.Is(err, ErrRecordNotFound), err)
+		})
+	}
+}
+
+func TestContainer_Empty(t *testing.T) {
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewContainerWriter(buf, EncodingBin).Close())
+
+	cr, err := OpenContainer(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, 0, cr.Len())
+}
+
+func TestContainer_Invalid(t *testing.T) {
+	data := writeTestContainer(t, EncodingBin)
+
+	for name, corrupt := range map[string]func([]byte) []byte{
+		"truncated":   func(b []byte) []byte { return b[:len(b)-1] },
+		"bad magic":   func(b []byte) []byte { b[0] = 'X'; return b },
+		"bad footer":  func(b []byte) []byte { b[len(b)-1] = 'X'; return b },
+		"bad offset":  func(b []byte) []byte { b[len(b)-12] = 0xff; return b },
+		"too small":   func(b []byte) []byte { return b[:10] },
+		"bad version": func(b []byte) []byte { b[4] = 9; return b },
+	} {
+		t.Run(name, func(t *testing.T) {
+			b := corrupt(append([]byte(nil), data...))
+			_, err := OpenContainer(bytes.NewReader(b), int64(len(b)))
+			assert.True(t, errors.Is(err, ErrInvalidContainer), err)
+		})
+	}
+}
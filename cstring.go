@@ -0,0 +1,110 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// DefaultCStringMaxLen is the maximum length in bytes of the strings
+// tagged `cstring` without a `maxlen=N` tag.
+const DefaultCStringMaxLen = 64 << 10
+
+// ErrInvalidCString is returned when encoding a C string that holds a NUL byte.
+var ErrInvalidCString = errors.New("C string contains a NUL byte")
+
+// WriteCString writes a NUL-terminated string; it fails if s holds a NUL byte.
+func (e *Encoder) WriteCString(s string) error {
+	if strings.IndexByte(s, 0) >= 0 {
+		return ErrInvalidCString
+	}
+	if e.tracing() {
+		e.tlog().Debug("encode: write C string", logString("val", s))
+	}
+	buf := make([]byte, len(s)+1)
+	copy(buf, s)
+	return e.WriteBytes(buf, false)
+}
+
+// ReadCString reads a NUL-terminated string of at most maxLen bytes (not counting
+// the NUL); the scan for the terminator stops after that, failing with ErrMaxLen.
+func (dec *Decoder) ReadCString(maxLen int) (out string, err error) {
+	b, err := dec.readCStringBytes(maxLen)
+	if err != nil {
+		return "", err
+	}
+	out = string(b)
+	if dec.tracing() {
+		dec.tlog().Debug("read C string", logString("val", out))
+	}
+	return
+}
+
+func (dec *Decoder) readCStringBytes(maxLen int) ([]byte, error) {
+	limit := maxLen + 1
+	if remaining := dec.Remaining(); limit > remaining {
+		limit = remaining
+	}
+	dec.fill(limit)
+	i := bytes.IndexByte(dec.data[dec.pos:dec.pos+limit], 0)
+	if i < 0 {
+		if limit > maxLen {
+			return nil, fmt.Errorf("C string: no NUL in %d bytes, maxlen=%d: %w", limit, maxLen, ErrMaxLen)
+		}
+		return nil, fmt.Errorf("C string: no NUL in the remaining %d bytes: %w", limit, io.ErrUnexpectedEOF)
+	}
+	out := dec.data[dec.pos : dec.pos+i]
+	dec.pos += i + 1
+	return out, nil
+}
+
+// cStringMaxLen returns the maximum length of a `cstring` field.
+func cStringMaxLen(opt *option) int {
+	if opt.MaxLen > 0 {
+		return opt.MaxLen
+	}
+	return DefaultCStringMaxLen
+}
+
+// encodeCString encodes a string field tagged `cstring`
+// as its bytes followed by a NUL, without a length prefix.
+func (e *Encoder) encodeCString(rv reflect.Value, opt *option) (bool, error) {
+	if opt == nil || !opt.CString || rv.Kind() != reflect.String {
+		return false, nil
+	}
+	if rv.Len() > cStringMaxLen(opt) {
+		return true, fmt.Errorf("%d bytes, maxlen=%d: %w", rv.Len(), cStringMaxLen(opt), ErrMaxLen)
+	}
+	return true, e.WriteCString(rv.String())
+}
+
+// decodeCString decodes a string field tagged `cstring`, scanning for its
+// terminator in at most `maxlen=N` bytes, or DefaultCStringMaxLen bytes.
+func (dec *Decoder) decodeCString(rv reflect.Value, opt *option) (bool, error) {
+	if opt == nil || !opt.CString || rv.Kind() != reflect.String {
+		return false, nil
+	}
+	s, err := dec.ReadCString(cStringMaxLen(opt))
+	if err != nil {
+		return true, err
+	}
+	rv.SetString(s)
+	return true, nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type cStringHeader struct {
+	Magic uint16
+	Name  string  `bin:"cstring maxlen=8"`
+	Path  string  `bin:"cstring"`
+	Alias *string `bin:"optional cstring"`
+	Flags uint8
+}
+
+func TestCString(t *testing.T) {
+	alias := "tmp"
+	in := cStringHeader{Magic: 0xcafe, Name: "boot", Path: "/usr/lib", Alias: &alias, Flags: 3}
+
+	data := mustMarshalBin(t, in)
+	assert.Equal(t, append(append([]byte{0xfe, 0xca},
+		"boot\x00/usr/lib\x00"...),
+		1, 0, 0, 0, 't', 'm', 'p', 0, 3), data)
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&in), enc)
+
+		var out cStringHeader
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out), enc)
+		assert.Equal(t, in, out, enc)
+
+		// Strings straddling buffers.
+		out = cStringHeader{}
+		bufs := [][]byte{buf.Bytes()[:4], buf.Bytes()[4:9], buf.Bytes()[9:]}
+		require.NoError(t, NewDecoderWithBuffers(bufs, enc).Decode(&out), enc)
+		assert.Equal(t, in, out, enc)
+	}
+	require.NoError(t, Precompile(cStringHeader{}))
+
+	view, err := NewView(data, cStringHeader{})
+	require.NoError(t, err)
+	path, err := view.String("Path")
+	require.NoError(t, err)
+	assert.Equal(t, "/usr/lib", path)
+	flags, err := view.Uint8("Flags")
+	require.NoError(t, err)
+	assert.Equal(t, uint8(3), flags)
+}
+
+func TestCString_Errors(t *testing.T) {
+	_, err := MarshalBin(cStringHeader{Name: "a\x00b"})
+	assert.True(t, errors.Is(err, ErrInvalidCString))
+	_, err = MarshalBin(cStringHeader{Name: "too long name"})
+	assert.True(t, errors.Is(err, ErrMaxLen))
+
+	var out cStringHeader
+	err = UnmarshalBin(&out, append([]byte{0, 0}, "unterminated"...))
+	assert.True(t, errors.Is(err, ErrMaxLen), err)
+	err = UnmarshalBin(&out, append([]byte{0, 0}, "boot\x00/usr"...))
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), err)
+
+	dec := NewBinDecoder([]byte("abc\x00"))
+	_, err = dec.ReadCString(2)
+	assert.True(t, errors.Is(err, ErrMaxLen))
+	s, err := dec.ReadCString(3)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", s)
+
+	type notString struct {
+		N []byte `bin:"cstring"`
+	}
+	assert.EqualError(t, Precompile(notString{}), "precompile: bin.notString: field \"N\": the cstring tag only applies to strings, got []uint8")
+}
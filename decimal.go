@@ -0,0 +1,261 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// MaxDecimalScale is the largest number of decimal places of a Decimal.
+const MaxDecimalScale = 18
+
+// Decimal is an exact fixed-point decimal number: an integer mantissa
+// and a scale, the number of decimal places, e.g. 12.50 is 1250 with scale 2.
+//
+// A Decimal is encoded as its mantissa at the scale declared by the tag of
+// its field, e.g. `bin:"decimal,scale=6"`, as an 8-byte integer (or 4 bytes
+// with `width=4`) in the byte order of the field; encoding fails if the value
+// has more decimal places than that, or if it overflows. Decoded values have
+// the scale of their field, and Decimal values that are not struct fields
+// (e.g. the elements of a slice) have a scale of 0.
+type Decimal struct {
+	mantissa int64
+	scale    int
+}
+
+var decimalType = reflect.TypeOf(Decimal{})
+
+var pow10 = func() (out [MaxDecimalScale + 1]int64) {
+	out[0] = 1
+	for i := 1; i < len(out); i++ {
+		out[i] = out[i-1] * 10
+	}
+	return out
+}()
+
+// NewDecimal returns the decimal mantissa×10^-scale;
+// it panics if the scale is not between 0 and MaxDecimalScale.
+func NewDecimal(mantissa int64, scale int) Decimal {
+	if scale < 0 || scale > MaxDecimalScale {
+		panic(fmt.Sprintf("NewDecimal: invalid scale %d", scale))
+	}
+	return Decimal{mantissa: mantissa, scale: scale}
+}
+
+// ParseDecimal parses a decimal number, e.g. "-12.50", whose
+// scale is its number of decimal places.
+func ParseDecimal(s string) (Decimal, error) {
+	digits := strings.TrimLeft(s, "+-")
+	if len(s)-len(digits) > 1 {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	integer, fraction := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		integer, fraction = digits[:i], digits[i+1:]
+		if fraction == "" {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+		}
+	}
+	if integer == "" || strings.Trim(integer+fraction, "0123456789") != "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	if len(fraction) > MaxDecimalScale {
+		return Decimal{}, fmt.Errorf("decimal %q has more than %d decimal places", s, MaxDecimalScale)
+	}
+	mantissa, err := strconv.ParseInt(s[:len(s)-len(digits)]+integer+fraction, 10, 64)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("decimal %q overflows: %w", s, err)
+	}
+	return Decimal{mantissa: mantissa, scale: len(fraction)}, nil
+}
+
+// NewDecimalFromFloat64 returns the decimal nearest to f with the provided
+// scale (ties to even); it fails for NaNs, infinities and values that overflow.
+func NewDecimalFromFloat64(f float64, scale int) (Decimal, error) {
+	if scale < 0 || scale > MaxDecimalScale {
+		return Decimal{}, fmt.Errorf("invalid decimal scale %d", scale)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}, fmt.Errorf("cannot convert %v to a decimal", f)
+	}
+	return ParseDecimal(strconv.FormatFloat(f, 'f', scale, 64))
+}
+
+// NewDecimalFromRat returns the decimal equal to r with the provided scale;
+// it fails if r has more decimal places than that, or if it overflows.
+func NewDecimalFromRat(r *big.Rat, scale int) (Decimal, error) {
+	if scale < 0 || scale > MaxDecimalScale {
+		return Decimal{}, fmt.Errorf("invalid decimal scale %d", scale)
+	}
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt64(pow10[scale]))
+	if !scaled.IsInt() {
+		return Decimal{}, fmt.Errorf("%s has more than %d decimal places", r.RatString(), scale)
+	}
+	if !scaled.Num().IsInt64() {
+		return Decimal{}, fmt.Errorf("decimal %s overflows", r.RatString())
+	}
+	return Decimal{mantissa: scaled.Num().Int64(), scale: scale}, nil
+}
+
+// Mantissa returns the integer mantissa of the decimal.
+func (d Decimal) Mantissa() int64 {
+	return d.mantissa
+}
+
+// Scale returns the number of decimal places of the decimal.
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+// Rescale returns the same decimal with the provided scale; it fails
+// if the decimal has more decimal places than that, or if it overflows.
+func (d Decimal) Rescale(scale int) (Decimal, error) {
+	switch {
+	case scale < 0 || scale > MaxDecimalScale:
+		return Decimal{}, fmt.Errorf("invalid decimal scale %d", scale)
+	case scale > d.scale:
+		f := pow10[scale-d.scale]
+		m := d.mantissa * f
+		if m/f != d.mantissa {
+			return Decimal{}, fmt.Errorf("decimal %s overflows with %d decimal places", d, scale)
+		}
+		return Decimal{mantissa: m, scale: scale}, nil
+	case scale < d.scale:
+		f := pow10[d.scale-scale]
+		if d.mantissa%f != 0 {
+			return Decimal{}, fmt.Errorf("decimal %s has more than %d decimal places", d, scale)
+		}
+		return Decimal{mantissa: d.mantissa / f, scale: scale}, nil
+	}
+	return d, nil
+}
+
+// Float64 returns the float64 nearest to the decimal.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// Rat returns the value of the decimal as a big.Rat.
+func (d Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(big.NewInt(d.mantissa), big.NewInt(pow10[d.scale]))
+}
+
+// String returns the decimal with all of its decimal places, e.g. "-12.50".
+func (d Decimal) String() string {
+	u := uint64(d.mantissa)
+	sign := ""
+	if d.mantissa < 0 {
+		u = -u
+		sign = "-"
+	}
+	digits := strconv.FormatUint(u, 10)
+	if d.scale == 0 {
+		return sign + digits
+	}
+	if len(digits) <= d.scale {
+		digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+}
+
+// MarshalJSON writes the decimal as a string, so that it isn't rounded.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepts a decimal string or number.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	s := string(data)
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	v, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// decimalOptions returns the scale, width and byte order of the current field.
+func decimalOptions(enc Encoding, opt *option) (scale int, width int, order binary.ByteOrder) {
+	scale, width, order = 0, TypeSize.Uint64, defaultByteOrder
+	if opt == nil {
+		return
+	}
+	scale = opt.Scale
+	if opt.Width > 0 {
+		width = opt.Width
+	}
+	if opt.Order != nil {
+		order = widthOrder(enc, opt)
+	}
+	return
+}
+
+func (d *Decimal) UnmarshalWithDecoder(dec *Decoder) error {
+	scale, width, order := decimalOptions(dec.encoding, dec.currentFieldOpt)
+	var mantissa int64
+	if width == TypeSize.Uint32 {
+		v, err := dec.ReadInt32(order)
+		if err != nil {
+			return err
+		}
+		mantissa = int64(v)
+	} else {
+		v, err := dec.ReadInt64(order)
+		if err != nil {
+			return err
+		}
+		mantissa = v
+	}
+	*d = Decimal{mantissa: mantissa, scale: scale}
+	return nil
+}
+
+func (d Decimal) MarshalWithEncoder(enc *Encoder) error {
+	scale, width, order := decimalOptions(enc.encoding, enc.currentFieldOpt)
+	v, err := d.Rescale(scale)
+	if err != nil {
+		return err
+	}
+	if width == TypeSize.Uint32 {
+		if v.mantissa < math.MinInt32 || v.mantissa > math.MaxInt32 {
+			return fmt.Errorf("decimal %s overflows %d bytes", v, width)
+		}
+		return enc.WriteInt32(int32(v.mantissa), order)
+	}
+	return enc.WriteInt64(v.mantissa, order)
+}
+
+func isDecimalOrPtr(rt reflect.Type) bool {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	return rt == decimalType
+}
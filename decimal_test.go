@@ -0,0 +1,167 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecimal(t *testing.T) {
+	t.Run("parse and format", func(t *testing.T) {
+		for _, test := range []struct {
+			in       string
+			mantissa int64
+			scale    int
+			out      string
+		}{
+			{"0", 0, 0, "0"},
+			{"12.50", 1250, 2, "12.50"},
+			{"-0.001", -1, 3, "-0.001"},
+			{"+7", 7, 0, "7"},
+			{"9223372036854775807", math.MaxInt64, 0, "9223372036854775807"},
+			{"-9.223372036854775808", math.MinInt64, 18, "-9.223372036854775808"},
+		} {
+			d, err := ParseDecimal(test.in)
+			require.NoError(t, err, test.in)
+			assert.Equal(t, test.mantissa, d.Mantissa(), test.in)
+			assert.Equal(t, test.scale, d.Scale(), test.in)
+			assert.Equal(t, test.out, d.String(), test.in)
+		}
+		for _, in := range []string{"", "-", "1.", ".5", "1.2.3", "--1", "1e5", "0x10", "9223372036854775808", "0.1234567890123456789"} {
+			_, err := ParseDecimal(in)
+			assert.Error(t, err, in)
+		}
+	})
+
+	t.Run("rescale", func(t *testing.T) {
+		d := NewDecimal(1250, 2)
+		v, err := d.Rescale(6)
+		require.NoError(t, err)
+		assert.Equal(t, NewDecimal(12500000, 6), v)
+		v, err = d.Rescale(1)
+		require.NoError(t, err)
+		assert.Equal(t, NewDecimal(125, 1), v)
+		_, err = d.Rescale(0)
+		assert.Error(t, err)
+		_, err = NewDecimal(math.MaxInt64/10, 0).Rescale(2)
+		assert.Error(t, err)
+		assert.Panics(t, func() { NewDecimal(1, MaxDecimalScale+1) })
+	})
+
+	t.Run("float64", func(t *testing.T) {
+		d, err := NewDecimalFromFloat64(0.1, 6)
+		require.NoError(t, err)
+		assert.Equal(t, "0.100000", d.String())
+		assert.Equal(t, 0.1, d.Float64())
+
+		d, err = NewDecimalFromFloat64(-2.675, 2)
+		require.NoError(t, err)
+		// -2.675 is -2.67499999... as a float64.
+		assert.Equal(t, "-2.67", d.String())
+
+		_, err = NewDecimalFromFloat64(math.NaN(), 2)
+		assert.Error(t, err)
+		_, err = NewDecimalFromFloat64(1e30, 2)
+		assert.Error(t, err)
+	})
+
+	t.Run("rat", func(t *testing.T) {
+		d, err := NewDecimalFromRat(big.NewRat(-5, 4), 4)
+		require.NoError(t, err)
+		assert.Equal(t, "-1.2500", d.String())
+		assert.Equal(t, 0, big.NewRat(-5, 4).Cmp(d.Rat()))
+
+		_, err = NewDecimalFromRat(big.NewRat(1, 3), 18)
+		assert.Error(t, err)
+		_, err = NewDecimalFromRat(new(big.Rat).SetFloat64(1e30), 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		data, err := json.Marshal(NewDecimal(-1250, 3))
+		require.NoError(t, err)
+		assert.Equal(t, `"-1.250"`, string(data))
+
+		var d Decimal
+		require.NoError(t, json.Unmarshal(data, &d))
+		assert.Equal(t, NewDecimal(-1250, 3), d)
+		require.NoError(t, json.Unmarshal([]byte(`3.25`), &d))
+		assert.Equal(t, NewDecimal(325, 2), d)
+		assert.Error(t, json.Unmarshal([]byte(`"abc"`), &d))
+	})
+}
+
+func TestDecimal_Encoding(t *testing.T) {
+	type Order struct {
+		Price    Decimal  `bin:"decimal,scale=6"`
+		Quantity Decimal  `bin:"decimal,scale=2 width=4 big"`
+		Fee      *Decimal `bin:"decimal,scale=4 optional"`
+	}
+	fee := NewDecimal(5, 1)
+	in := Order{
+		Price:    NewDecimal(1250, 2),
+		Quantity: NewDecimal(-3, 0),
+		Fee:      &fee,
+	}
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&in))
+			var out Order
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out))
+			assert.Equal(t, NewDecimal(12500000, 6), out.Price)
+			assert.Equal(t, NewDecimal(-300, 2), out.Quantity)
+			assert.Equal(t, NewDecimal(5000, 4), *out.Fee)
+			assert.True(t, EqualWire(in, out))
+		})
+	}
+
+	data, err := MarshalBin(&in)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x20, 0xbc, 0xbe, 0, 0, 0, 0, 0,
+		0xff, 0xff, 0xfe, 0xd4,
+		1, 0, 0, 0, 0x88, 0x13, 0, 0, 0, 0, 0, 0,
+	}, data)
+
+	out, err := ExplainType(Order{})
+	require.NoError(t, err)
+	assert.Contains(t, out, "Quantity  bin.Decimal  int   -       BE")
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := MarshalBin(&Order{Price: NewDecimal(1, 7)})
+		assert.Error(t, err)
+		_, err = MarshalBin(&Order{Quantity: NewDecimal(math.MaxInt32, 0)})
+		assert.Error(t, err)
+
+		type notDecimal struct {
+			A int64 `bin:"decimal,scale=2"`
+		}
+		assert.Error(t, Precompile(notDecimal{}))
+		type badWidth struct {
+			A Decimal `bin:"decimal width=2"`
+		}
+		assert.Error(t, Precompile(badWidth{}))
+		assert.NoError(t, Precompile(Order{}))
+	})
+}
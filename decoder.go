@@ -18,6 +18,7 @@
 package bin
 
 import (
+	"context"
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
@@ -27,8 +28,7 @@ import (
 	"reflect"
 	"strings"
 	"unicode/utf8"
-
-	"go.uber.org/zap"
+	"unsafe"
 )
 
 var TypeSize = struct {
@@ -43,10 +43,15 @@ var TypeSize = struct {
 	Uint32  int
 	Uint64  int
 	Uint128 int
+	Uint256 int
 
+	Float16 int
 	Float32 int
 	Float64 int
 
+	Complex64  int
+	Complex128 int
+
 	PublicKey int
 	Signature int
 }{
@@ -61,10 +66,15 @@ var TypeSize = struct {
 	Uint32:  4,
 	Uint64:  8,
 	Uint128: 16,
+	Uint256: 32,
 
+	Float16: 2,
 	Float32: 4,
 	Float64: 8,
 
+	Complex64:  8,
+	Complex128: 16,
+
 	PublicKey: 32,
 	Signature: 64,
 }
@@ -76,6 +86,59 @@ type Decoder struct {
 	currentFieldOpt *option
 
 	encoding Encoding
+
+	strictTags bool
+	canonical  bool
+	stringHeap bool
+	heap       []byte
+
+	lenientReserved     bool
+	lenientEnums        bool
+	pointerMode         PointerMode
+	textMarshalers      bool
+	stdBinaryMarshalers bool
+	// faults are the faults injected by NewFaultDecoder,
+	// with the faultinject build tag.
+	faults decoderFaults
+
+	quota         *DecodeQuota
+	profileLabels context.Context
+	inDecode      bool
+
+	recorder  *Recorder
+	recording bool
+
+	// inheritedOrder is the byte order of the values nested
+	// in a `big,recursive` or `little,recursive` field.
+	inheritedOrder binary.ByteOrder
+	// prefixOrder is the byte order of fixed-width length prefixes, if set.
+	prefixOrder binary.ByteOrder
+
+	// pinnedPlan is the plan of pinnedType, set by TypedDecoder.
+	pinnedType reflect.Type
+	pinnedPlan *structPlan
+
+	values map[interface{}]interface{}
+
+	decryptionKeys map[string][]byte
+	keys           KeyProvider
+	compressor     Compressor
+
+	// trace enables tracing for this decoder (see WithTracing),
+	// and traceLog, if set, replaces the package logger (see WithTraceLogger).
+	trace    bool
+	traceLog *logger
+
+	// warnings is shared with the sub-decoders.
+	warnings *[]Warning
+
+	// When decoding from multiple buffers (see NewDecoderWithBuffers),
+	// data is the segment being read, base is its offset in the whole input,
+	// and rest are the segments that follow it.
+	segments [][]byte
+	rest     [][]byte
+	restLen  int
+	base     int
 }
 
 // Reset resets the decoder to decode a new message.
@@ -83,6 +146,11 @@ func (dec *Decoder) Reset(data []byte) {
 	dec.data = data
 	dec.pos = 0
 	dec.currentFieldOpt = nil
+	dec.warnings = nil
+	dec.segments = nil
+	dec.rest = nil
+	dec.restLen = 0
+	dec.base = 0
 }
 
 func (dec *Decoder) IsBorsh() bool {
@@ -112,6 +180,14 @@ func (dec *Decoder) SetEncoding(enc Encoding) {
 	dec.encoding = enc
 }
 
+// WithStrictTags makes the decoder fail on struct fields that have
+// unknown or malformed `bin` tag tokens, instead of silently ignoring them.
+// The check is done on the whole struct type before any of its fields is decoded.
+func (dec *Decoder) WithStrictTags() *Decoder {
+	dec.strictTags = true
+	return dec
+}
+
 func NewBinDecoder(data []byte) *Decoder {
 	return NewDecoderWithEncoding(data, EncodingBin)
 }
@@ -125,6 +201,20 @@ func NewCompactU16Decoder(data []byte) *Decoder {
 }
 
 func (dec *Decoder) Decode(v interface{}) (err error) {
+	if dec.recorder != nil && !dec.recording {
+		return dec.decodeRecorded(v)
+	}
+	if !dec.inDecode && (dec.quota != nil || dec.profileLabels != nil) {
+		dec.inDecode = true
+		defer func() { dec.inDecode = false }()
+		if dec.profileLabels != nil {
+			return dec.decodeWithProfileLabels(v)
+		}
+		return dec.decodeWithQuota(v)
+	}
+	if dec.stringHeap && dec.heap == nil {
+		return dec.decodeWithHeap(func() error { return dec.Decode(v) })
+	}
 	switch dec.encoding {
 	case EncodingBin:
 		return dec.decodeWithOptionBin(v, nil)
@@ -156,100 +246,141 @@ func sizeof(t reflect.Type, v reflect.Value) int {
 
 var ErrVarIntBufferSize = errors.New("varint: invalid buffer size")
 
+// ErrVarIntOverflow is returned when a decoded varint doesn't fit
+// in the width it's read into, instead of truncating it.
+var ErrVarIntOverflow = errors.New("varint: value overflows the target width")
+
 func (dec *Decoder) ReadUvarint64() (uint64, error) {
+	dec.fill(binary.MaxVarintLen64)
 	l, read := binary.Uvarint(dec.data[dec.pos:])
 	if read <= 0 {
 		return l, ErrVarIntBufferSize
 	}
-	if traceEnabled {
-		zlog.Debug("decode: read uvarint64", zap.Uint64("val", l))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read uvarint64", logUint64("val", l))
+	}
+	if err := dec.checkMinimalVarint(read); err != nil {
+		return l, err
 	}
 	dec.pos += read
 	return l, nil
 }
 
 func (d *Decoder) ReadVarint64() (out int64, err error) {
+	d.fill(binary.MaxVarintLen64)
 	l, read := binary.Varint(d.data[d.pos:])
 	if read <= 0 {
 		return l, ErrVarIntBufferSize
 	}
-	if traceEnabled {
-		zlog.Debug("decode: read varint", zap.Int64("val", l))
+	if d.tracing() {
+		d.tlog().Debug("decode: read varint", logInt64("val", l))
+	}
+	if err := d.checkMinimalVarint(read); err != nil {
+		return l, err
 	}
 	d.pos += read
 	return l, nil
 }
 
+// ReadVarint32 reads a varint, and returns ErrVarIntOverflow
+// if the decoded value doesn't fit in a int32.
 func (dec *Decoder) ReadVarint32() (out int32, err error) {
 	n, err := dec.ReadVarint64()
 	if err != nil {
 		return out, err
 	}
+	if n < math.MinInt32 || n > math.MaxInt32 {
+		return out, fmt.Errorf("varint32: value %d: %w", n, ErrVarIntOverflow)
+	}
 	out = int32(n)
-	if traceEnabled {
-		zlog.Debug("decode: read varint32", zap.Int32("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read varint32", logInt32("val", out))
 	}
 	return
 }
 
+// ReadUvarint32 reads a uvarint, and returns ErrVarIntOverflow
+// if the decoded value doesn't fit in a uint32.
 func (dec *Decoder) ReadUvarint32() (out uint32, err error) {
 	n, err := dec.ReadUvarint64()
 	if err != nil {
 		return out, err
 	}
+	if n > math.MaxUint32 {
+		return out, fmt.Errorf("uvarint32: value %d: %w", n, ErrVarIntOverflow)
+	}
 	out = uint32(n)
-	if traceEnabled {
-		zlog.Debug("decode: read uvarint32", zap.Uint32("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read uvarint32", logUint32("val", out))
 	}
 	return
 }
 
+// ReadVarint16 reads a varint, and returns ErrVarIntOverflow
+// if the decoded value doesn't fit in a int16.
 func (dec *Decoder) ReadVarint16() (out int16, err error) {
 	n, err := dec.ReadVarint64()
 	if err != nil {
 		return out, err
 	}
+	if n < math.MinInt16 || n > math.MaxInt16 {
+		return out, fmt.Errorf("varint16: value %d: %w", n, ErrVarIntOverflow)
+	}
 	out = int16(n)
-	if traceEnabled {
-		zlog.Debug("decode: read varint16", zap.Int16("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read varint16", logInt16("val", out))
 	}
 	return
 }
 
+// ReadUvarint16 reads a uvarint, and returns ErrVarIntOverflow
+// if the decoded value doesn't fit in a uint16.
 func (dec *Decoder) ReadUvarint16() (out uint16, err error) {
 	n, err := dec.ReadUvarint64()
 	if err != nil {
 		return out, err
 	}
+	if n > math.MaxUint16 {
+		return out, fmt.Errorf("uvarint16: value %d: %w", n, ErrVarIntOverflow)
+	}
 	out = uint16(n)
-	if traceEnabled {
-		zlog.Debug("decode: read uvarint16", zap.Uint16("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read uvarint16", logUint16("val", out))
 	}
 	return
 }
 
 func (dec *Decoder) ReadByteSlice() (out []byte, err error) {
+	if dec.heap != nil {
+		return dec.readHeapRef()
+	}
 	length, err := dec.ReadLength()
 	if err != nil {
 		return nil, err
 	}
 
-	if len(dec.data) < dec.pos+length {
-		return nil, fmt.Errorf("byte array: varlen=%d, missing %d bytes", length, dec.pos+length-len(dec.data))
+	if dec.Remaining() < length {
+		return nil, fmt.Errorf("byte array: varlen=%d, missing %d bytes", length, length-dec.Remaining())
 	}
+	dec.fill(length)
 
 	out = dec.data[dec.pos : dec.pos+length]
 	dec.pos += length
-	if traceEnabled {
-		zlog.Debug("decode: read byte array", zap.Stringer("hex", HexBytes(out)))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read byte array", logStringer("hex", HexBytes(out)))
 	}
 	return
 }
 
 func (dec *Decoder) ReadLength() (length int, err error) {
+	offset := int(dec.Position())
 	switch dec.encoding {
 	case EncodingBin:
-		val, err := dec.ReadUvarint64()
+		val, err := dec.ReadUvarint32()
+		if errors.Is(err, ErrVarIntOverflow) {
+			// Lengths that don't fit are reported like those that don't fit in the input.
+			return 0, io.ErrUnexpectedEOF
+		}
 		if err != nil {
 			return 0, err
 		}
@@ -258,7 +389,7 @@ func (dec *Decoder) ReadLength() (length int, err error) {
 		}
 		length = int(val)
 	case EncodingBorsh:
-		val, err := dec.ReadUint32(LE)
+		val, err := dec.ReadUint32(dec.lengthOrder())
 		if err != nil {
 			return 0, err
 		}
@@ -275,6 +406,7 @@ func (dec *Decoder) ReadLength() (length int, err error) {
 	default:
 		panic(fmt.Errorf("encoding not implemented: %s", dec.encoding))
 	}
+	length = dec.faultLength(offset, length)
 	return
 }
 
@@ -285,9 +417,10 @@ func readNBytes(n int, reader *Decoder) ([]byte, error) {
 	if n < 0 || n > 0x7FFF_FFFF {
 		return nil, fmt.Errorf("invalid length n: %v", n)
 	}
-	if reader.pos+n > len(reader.data) {
-		return nil, fmt.Errorf("not enough data: %d bytes missing", reader.pos+n-len(reader.data))
+	if reader.Remaining() < n {
+		return nil, fmt.Errorf("not enough data: %d bytes missing", n-reader.Remaining())
 	}
+	reader.fill(n)
 	out := reader.data[reader.pos : reader.pos+n]
 	reader.pos += n
 	return out, nil
@@ -304,9 +437,10 @@ func discardNBytes(n int, reader *Decoder) error {
 }
 
 func (d *Decoder) Read(buf []byte) (int, error) {
-	if d.pos+len(buf) > len(d.data) {
+	if len(buf) > d.Remaining() {
 		return 0, io.ErrShortBuffer
 	}
+	d.fill(len(buf))
 	numCopied := copy(buf, d.data[d.pos:])
 	d.pos += numCopied
 	// must read exactly len(buf) bytes
@@ -361,18 +495,20 @@ func (dec *Decoder) Peek(n int) (out []byte, err error) {
 		return
 	}
 
+	dec.fill(n)
 	out = dec.data[dec.pos : dec.pos+n]
-	if traceEnabled {
-		zlog.Debug("decode: peek", zap.Int("n", n), zap.Binary("out", out))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: peek", logInt("n", n), logBinary("out", out))
 	}
 	return
 }
 
 // ReadCompactU16 reads a compact u16 from the decoder.
 func (dec *Decoder) ReadCompactU16() (out int, err error) {
+	dec.fill(3)
 	out, size, err := DecodeCompactU16(dec.data[dec.pos:])
-	if traceEnabled {
-		zlog.Debug("decode: read compact u16", zap.Int("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read compact u16", logInt("val", out))
 	}
 	dec.pos += size
 	return out, err
@@ -384,8 +520,8 @@ func (dec *Decoder) ReadOption() (out bool, err error) {
 		return false, fmt.Errorf("decode: read option, %w", err)
 	}
 	out = b != 0
-	if traceEnabled {
-		zlog.Debug("decode: read option", zap.Bool("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read option", logBool("val", out))
 	}
 	return
 }
@@ -399,8 +535,8 @@ func (dec *Decoder) ReadCOption() (out bool, err error) {
 		return false, fmt.Errorf("decode: read c-option, invalid value: %d", b)
 	}
 	out = b != 0
-	if traceEnabled {
-		zlog.Debug("decode: read c-option", zap.Bool("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read c-option", logBool("val", out))
 	}
 	return
 }
@@ -411,10 +547,11 @@ func (dec *Decoder) ReadByte() (out byte, err error) {
 		return
 	}
 
+	dec.fill(TypeSize.Byte)
 	out = dec.data[dec.pos]
 	dec.pos++
-	if traceEnabled {
-		zlog.Debug("decode: read byte", zap.Uint8("byte", out), zap.String("hex", hex.EncodeToString([]byte{out})))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read byte", logUint8("byte", out), logString("hex", hex.EncodeToString([]byte{out})))
 	}
 	return
 }
@@ -430,8 +567,31 @@ func (dec *Decoder) ReadBool() (out bool, err error) {
 		err = fmt.Errorf("readBool, %s", err)
 	}
 	out = b != 0
-	if traceEnabled {
-		zlog.Debug("decode: read bool", zap.Bool("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read bool", logBool("val", out))
+	}
+	return
+}
+
+// ReadBoolWidth reads a bool encoded as a 1, 2 or 4-byte integer;
+// like ReadBool, any value other than 0 is true.
+func (dec *Decoder) ReadBoolWidth(width int, order binary.ByteOrder) (out bool, err error) {
+	switch width {
+	case TypeSize.Bool:
+		return dec.ReadBool()
+	case TypeSize.Uint16:
+		var n uint16
+		n, err = dec.ReadUint16(order)
+		out = n != 0
+	case TypeSize.Uint32:
+		var n uint32
+		n, err = dec.ReadUint32(order)
+		out = n != 0
+	default:
+		return false, fmt.Errorf("invalid bool width %d", width)
+	}
+	if err != nil {
+		err = fmt.Errorf("readBool, %s", err)
 	}
 	return
 }
@@ -444,8 +604,8 @@ func (dec *Decoder) ReadUint8() (out uint8, err error) {
 func (dec *Decoder) ReadInt8() (out int8, err error) {
 	b, err := dec.ReadByte()
 	out = int8(b)
-	if traceEnabled {
-		zlog.Debug("decode: read int8", zap.Int8("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read int8", logInt8("val", out))
 	}
 	return
 }
@@ -456,10 +616,11 @@ func (dec *Decoder) ReadUint16(order binary.ByteOrder) (out uint16, err error) {
 		return
 	}
 
+	dec.fill(TypeSize.Uint16)
 	out = order.Uint16(dec.data[dec.pos:])
 	dec.pos += TypeSize.Uint16
-	if traceEnabled {
-		zlog.Debug("decode: read uint16", zap.Uint16("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read uint16", logUint16("val", out))
 	}
 	return
 }
@@ -467,8 +628,8 @@ func (dec *Decoder) ReadUint16(order binary.ByteOrder) (out uint16, err error) {
 func (dec *Decoder) ReadInt16(order binary.ByteOrder) (out int16, err error) {
 	n, err := dec.ReadUint16(order)
 	out = int16(n)
-	if traceEnabled {
-		zlog.Debug("decode: read int16", zap.Int16("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read int16", logInt16("val", out))
 	}
 	return
 }
@@ -479,10 +640,11 @@ func (dec *Decoder) ReadUint32(order binary.ByteOrder) (out uint32, err error) {
 		return
 	}
 
+	dec.fill(TypeSize.Uint32)
 	out = order.Uint32(dec.data[dec.pos:])
 	dec.pos += TypeSize.Uint32
-	if traceEnabled {
-		zlog.Debug("decode: read uint32", zap.Uint32("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read uint32", logUint32("val", out))
 	}
 	return
 }
@@ -490,8 +652,8 @@ func (dec *Decoder) ReadUint32(order binary.ByteOrder) (out uint32, err error) {
 func (dec *Decoder) ReadInt32(order binary.ByteOrder) (out int32, err error) {
 	n, err := dec.ReadUint32(order)
 	out = int32(n)
-	if traceEnabled {
-		zlog.Debug("decode: read int32", zap.Int32("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read int32", logInt32("val", out))
 	}
 	return
 }
@@ -507,8 +669,8 @@ func (dec *Decoder) ReadUint64(order binary.ByteOrder) (out uint64, err error) {
 		return 0, err
 	}
 	out = order.Uint64(data)
-	if traceEnabled {
-		zlog.Debug("decode: read uint64", zap.Uint64("val", out), zap.Stringer("hex", HexBytes(data)))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read uint64", logUint64("val", out), logStringer("hex", HexBytes(data)))
 	}
 	return
 }
@@ -516,8 +678,8 @@ func (dec *Decoder) ReadUint64(order binary.ByteOrder) (out uint64, err error) {
 func (dec *Decoder) ReadInt64(order binary.ByteOrder) (out int64, err error) {
 	n, err := dec.ReadUint64(order)
 	out = int64(n)
-	if traceEnabled {
-		zlog.Debug("decode: read int64", zap.Int64("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read int64", logInt64("val", out))
 	}
 	return
 }
@@ -528,6 +690,7 @@ func (dec *Decoder) ReadUint128(order binary.ByteOrder) (out Uint128, err error)
 		return
 	}
 
+	dec.fill(TypeSize.Uint128)
 	data := dec.data[dec.pos : dec.pos+TypeSize.Uint128]
 
 	if order == binary.LittleEndian {
@@ -540,8 +703,8 @@ func (dec *Decoder) ReadUint128(order binary.ByteOrder) (out Uint128, err error)
 	}
 
 	dec.pos += TypeSize.Uint128
-	if traceEnabled {
-		zlog.Debug("decode: read uint128", zap.Stringer("hex", out), zap.Uint64("hi", out.Hi), zap.Uint64("lo", out.Lo))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read uint128", logStringer("hex", out), logUint64("hi", out.Hi), logUint64("lo", out.Lo))
 	}
 	return
 }
@@ -554,17 +717,81 @@ func (dec *Decoder) ReadInt128(order binary.ByteOrder) (out Int128, err error) {
 	return Int128(v), nil
 }
 
+func (dec *Decoder) ReadUint256(order binary.ByteOrder) (out Uint256, err error) {
+	if order != binary.LittleEndian && order != binary.BigEndian {
+		err = fmt.Errorf("invalid byte order: %v", order)
+		return
+	}
+	if dec.Remaining() < TypeSize.Uint256 {
+		err = fmt.Errorf("uint256 required [%d] bytes, remaining [%d]", TypeSize.Uint256, dec.Remaining())
+		return
+	}
+
+	dec.fill(TypeSize.Uint256)
+	data := dec.data[dec.pos : dec.pos+TypeSize.Uint256]
+
+	for k := range out.Words {
+		if order == binary.LittleEndian {
+			out.Words[k] = order.Uint64(data[k*8:])
+		} else {
+			out.Words[k] = order.Uint64(data[(3-k)*8:])
+		}
+	}
+
+	dec.pos += TypeSize.Uint256
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read uint256", logStringer("hex", out))
+	}
+	return
+}
+
+func (dec *Decoder) ReadInt256(order binary.ByteOrder) (out Int256, err error) {
+	v, err := dec.ReadUint256(order)
+	if err != nil {
+		return
+	}
+	return Int256(v), nil
+}
+
+func (dec *Decoder) ReadFloat16(order binary.ByteOrder) (out Float16, err error) {
+	if dec.Remaining() < TypeSize.Float16 {
+		err = fmt.Errorf("float16 required [%d] bytes, remaining [%d]", TypeSize.Float16, dec.Remaining())
+		return
+	}
+
+	dec.fill(TypeSize.Float16)
+	out = Float16(order.Uint16(dec.data[dec.pos:]))
+	if dec.canonical && out.IsNaN() && out != CanonicalNaN16 {
+		return 0, fmt.Errorf("float16 %#04x: %w", uint16(out), ErrNonCanonicalNaN)
+	}
+	dec.pos += TypeSize.Float16
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read float16", logStringer("val", out))
+	}
+
+	if dec.IsBorsh() {
+		if out.IsNaN() {
+			return 0, errors.New("NaN for float not allowed")
+		}
+	}
+	return
+}
+
 func (dec *Decoder) ReadFloat32(order binary.ByteOrder) (out float32, err error) {
 	if dec.Remaining() < TypeSize.Float32 {
 		err = fmt.Errorf("float32 required [%d] bytes, remaining [%d]", TypeSize.Float32, dec.Remaining())
 		return
 	}
 
+	dec.fill(TypeSize.Float32)
 	n := order.Uint32(dec.data[dec.pos:])
 	out = math.Float32frombits(n)
+	if dec.canonical && math.IsNaN(float64(out)) && n != CanonicalNaN32 {
+		return 0, fmt.Errorf("float32 %#08x: %w", n, ErrNonCanonicalNaN)
+	}
 	dec.pos += TypeSize.Float32
-	if traceEnabled {
-		zlog.Debug("decode: read float32", zap.Float32("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read float32", logFloat32("val", out))
 	}
 
 	if dec.IsBorsh() {
@@ -581,11 +808,15 @@ func (dec *Decoder) ReadFloat64(order binary.ByteOrder) (out float64, err error)
 		return
 	}
 
+	dec.fill(TypeSize.Float64)
 	n := order.Uint64(dec.data[dec.pos:])
 	out = math.Float64frombits(n)
+	if dec.canonical && math.IsNaN(out) && n != CanonicalNaN64 {
+		return 0, fmt.Errorf("float64 %#016x: %w", n, ErrNonCanonicalNaN)
+	}
 	dec.pos += TypeSize.Float64
-	if traceEnabled {
-		zlog.Debug("decode: read Float64", zap.Float64("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read Float64", logFloat64("val", out))
 	}
 	if dec.IsBorsh() {
 		if math.IsNaN(out) {
@@ -595,6 +826,50 @@ func (dec *Decoder) ReadFloat64(order binary.ByteOrder) (out float64, err error)
 	return
 }
 
+// ReadComplex64 reads a complex64 encoded as two consecutive float32 values
+// (real part first, then imaginary part).
+func (dec *Decoder) ReadComplex64(order binary.ByteOrder) (out complex64, err error) {
+	if dec.Remaining() < TypeSize.Complex64 {
+		err = fmt.Errorf("complex64 required [%d] bytes, remaining [%d]", TypeSize.Complex64, dec.Remaining())
+		return
+	}
+	re, err := dec.ReadFloat32(order)
+	if err != nil {
+		return 0, err
+	}
+	im, err := dec.ReadFloat32(order)
+	if err != nil {
+		return 0, err
+	}
+	out = complex(re, im)
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read complex64", logFloat32("real", re), logFloat32("imag", im))
+	}
+	return
+}
+
+// ReadComplex128 reads a complex128 encoded as two consecutive float64 values
+// (real part first, then imaginary part).
+func (dec *Decoder) ReadComplex128(order binary.ByteOrder) (out complex128, err error) {
+	if dec.Remaining() < TypeSize.Complex128 {
+		err = fmt.Errorf("complex128 required [%d] bytes, remaining [%d]", TypeSize.Complex128, dec.Remaining())
+		return
+	}
+	re, err := dec.ReadFloat64(order)
+	if err != nil {
+		return 0, err
+	}
+	im, err := dec.ReadFloat64(order)
+	if err != nil {
+		return 0, err
+	}
+	out = complex(re, im)
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read complex128", logFloat64("real", re), logFloat64("imag", im))
+	}
+	return
+}
+
 func (dec *Decoder) ReadFloat128(order binary.ByteOrder) (out Float128, err error) {
 	value, err := dec.ReadUint128(order)
 	if err != nil {
@@ -606,8 +881,8 @@ func (dec *Decoder) ReadFloat128(order binary.ByteOrder) (out Float128, err erro
 func (dec *Decoder) SafeReadUTF8String() (out string, err error) {
 	data, err := dec.ReadByteSlice()
 	out = strings.Map(fixUtf, string(data))
-	if traceEnabled {
-		zlog.Debug("read safe UTF8 string", zap.String("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("read safe UTF8 string", logString("val", out))
 	}
 	return
 }
@@ -622,61 +897,119 @@ func fixUtf(r rune) rune {
 func (dec *Decoder) ReadString() (out string, err error) {
 	data, err := dec.ReadByteSlice()
 	out = string(data)
-	if traceEnabled {
-		zlog.Debug("read string", zap.String("val", out))
+	if dec.tracing() {
+		dec.tlog().Debug("read string", logString("val", out))
+	}
+	return
+}
+
+// ReadStringUnsafe is like ReadString, but the returned string aliases
+// the decoder's input instead of being a copy of it, which saves
+// an allocation and a copy per string.
+//
+// The returned string is only valid as long as the input isn't modified:
+// modifying it (e.g. reusing the buffer for the next message) modifies
+// the string, which breaks the immutability that the rest of the program
+// (map keys, etc.) relies on. Keeping the string alive also keeps the whole
+// input alive. Copy the strings that need to outlive the input
+// with string([]byte(s)).
+func (dec *Decoder) ReadStringUnsafe() (out string, err error) {
+	data, err := dec.ReadByteSlice()
+	out = unsafeString(data)
+	if dec.tracing() {
+		dec.tlog().Debug("read unsafe string", logString("val", out))
 	}
 	return
 }
 
 func (dec *Decoder) ReadRustString() (out string, err error) {
-	length, err := dec.ReadUint64(binary.LittleEndian)
+	bytes, err := dec.readRustStringBytes()
 	if err != nil {
 		return "", err
 	}
-	if length > 0x7FFF_FFFF {
-		return "", io.ErrUnexpectedEOF
+	out = string(bytes)
+	if dec.tracing() {
+		dec.tlog().Debug("read Rust string", logString("val", out))
 	}
-	bytes, err := dec.ReadNBytes(int(length))
+	return
+}
+
+// ReadRustStringUnsafe is like ReadRustString, but the returned string
+// aliases the decoder's input; see ReadStringUnsafe.
+func (dec *Decoder) ReadRustStringUnsafe() (out string, err error) {
+	bytes, err := dec.readRustStringBytes()
 	if err != nil {
 		return "", err
 	}
-	out = string(bytes)
-	if traceEnabled {
-		zlog.Debug("read Rust string", zap.String("val", out))
+	out = unsafeString(bytes)
+	if dec.tracing() {
+		dec.tlog().Debug("read unsafe Rust string", logString("val", out))
 	}
 	return
 }
 
+// readRustStringBytes reads a Rust string, returning the bytes of the input.
+func (dec *Decoder) readRustStringBytes() ([]byte, error) {
+	if dec.heap != nil {
+		return dec.readHeapRef()
+	}
+	length, err := dec.ReadUint64(dec.lengthOrder())
+	if err != nil {
+		return nil, err
+	}
+	if length > 0x7FFF_FFFF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return dec.ReadNBytes(int(length))
+}
+
+// unsafeString returns a string that shares its bytes with b.
+func unsafeString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return *(*string)(unsafe.Pointer(&b))
+}
+
 func (dec *Decoder) ReadCompactU16Length() (int, error) {
-	return dec.ReadCompactU16()
+	offset := int(dec.Position())
+	length, err := dec.ReadCompactU16()
+	if err == nil {
+		length = dec.faultLength(offset, length)
+	}
+	return length, err
 }
 
 func (dec *Decoder) SkipBytes(count uint) error {
 	if uint(dec.Remaining()) < count {
 		return fmt.Errorf("request to skip %d but only %d bytes remain", count, dec.Remaining())
 	}
-	dec.pos += int(count)
+	dec.skip(int(count))
 	return nil
 }
 
 func (dec *Decoder) SetPosition(idx uint) error {
-	if int(idx) < len(dec.data) {
+	if int(idx) < dec.Len() {
+		if dec.segments != nil {
+			dec.seek(int(idx))
+			return nil
+		}
 		dec.pos = int(idx)
 		return nil
 	}
-	return fmt.Errorf("request to set position to %d outsize of buffer (buffer size %d)", idx, len(dec.data))
+	return fmt.Errorf("request to set position to %d outsize of buffer (buffer size %d)", idx, dec.Len())
 }
 
 func (dec *Decoder) Position() uint {
-	return uint(dec.pos)
+	return uint(dec.base + dec.pos)
 }
 
 func (dec *Decoder) Remaining() int {
-	return len(dec.data) - dec.pos
+	return len(dec.data) - dec.pos + dec.restLen
 }
 
 func (dec *Decoder) Len() int {
-	return len(dec.data)
+	return dec.base + len(dec.data) + dec.restLen
 }
 
 func (dec *Decoder) HasRemaining() bool {
@@ -796,12 +1129,16 @@ func reflect_readArrayOfBytes(d *Decoder, l int, rv reflect.Value) error {
 
 func reflect_readArrayOfUint16(d *Decoder, l int, rv reflect.Value, order binary.ByteOrder) error {
 	buf := make([]uint16, l)
-	for i := 0; i < l; i++ {
-		n, err := d.ReadUint16(order)
-		if err != nil {
-			return err
+	if data, ok := d.contiguous(l * TypeSize.Uint16); ok {
+		decodeUint16s(buf, data, order)
+	} else {
+		for i := 0; i < l; i++ {
+			n, err := d.ReadUint16(order)
+			if err != nil {
+				return err
+			}
+			buf[i] = n
 		}
-		buf[i] = n
 	}
 	switch rv.Kind() {
 	case reflect.Array:
@@ -835,12 +1172,16 @@ func reflect_readArrayOfUint16(d *Decoder, l int, rv reflect.Value, order binary
 
 func reflect_readArrayOfUint32(d *Decoder, l int, rv reflect.Value, order binary.ByteOrder) error {
 	buf := make([]uint32, l)
-	for i := 0; i < l; i++ {
-		n, err := d.ReadUint32(order)
-		if err != nil {
-			return err
+	if data, ok := d.contiguous(l * TypeSize.Uint32); ok {
+		decodeUint32s(buf, data, order)
+	} else {
+		for i := 0; i < l; i++ {
+			n, err := d.ReadUint32(order)
+			if err != nil {
+				return err
+			}
+			buf[i] = n
 		}
-		buf[i] = n
 	}
 	switch rv.Kind() {
 	case reflect.Array:
@@ -888,12 +1229,16 @@ var (
 
 func reflect_readArrayOfUint64(d *Decoder, l int, rv reflect.Value, order binary.ByteOrder) error {
 	buf := make([]uint64, l)
-	for i := 0; i < l; i++ {
-		n, err := d.ReadUint64(order)
-		if err != nil {
-			return err
+	if data, ok := d.contiguous(l * TypeSize.Uint64); ok {
+		decodeUint64s(buf, data, order)
+	} else {
+		for i := 0; i < l; i++ {
+			n, err := d.ReadUint64(order)
+			if err != nil {
+				return err
+			}
+			buf[i] = n
 		}
-		buf[i] = n
 	}
 	switch rv.Kind() {
 	case reflect.Array:
@@ -1,6 +1,7 @@
 package bin
 
 import (
+	"context"
 	"reflect"
 	"testing"
 )
@@ -417,3 +418,36 @@ func Benchmark_uintSlice32_Decode_field_withCustomDecoder(b *testing.B) {
 		}
 	}
 }
+
+func benchmarkDecodeProfileLabels(b *testing.B, labels bool) {
+	type S struct {
+		A uint64
+		B string
+		C []uint32
+	}
+	buf, err := MarshalBin(S{A: 1, B: "hello", C: []uint32{1, 2, 3}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var got S
+		decoder := NewBinDecoder(buf)
+		if labels {
+			decoder.WithProfileLabels(context.Background())
+		}
+		if err := decoder.Decode(&got); err != nil {
+			b.Error(err)
+		}
+	}
+}
+
+func Benchmark_Decode_noProfileLabels(b *testing.B) {
+	benchmarkDecodeProfileLabels(b, false)
+}
+
+func Benchmark_Decode_profileLabels(b *testing.B) {
+	benchmarkDecodeProfileLabels(b, true)
+}
@@ -22,8 +22,6 @@ import (
 	"fmt"
 	"io"
 	"reflect"
-
-	"go.uber.org/zap"
 )
 
 func (dec *Decoder) decodeWithOptionBorsh(v interface{}, option *option) (err error) {
@@ -47,13 +45,17 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 	}
 	dec.currentFieldOpt = opt
 
-	unmarshaler, rv := indirect(rv, opt.is_Optional() || opt.is_COptional())
+	if handled, err := dec.decodeUnion(rv, opt); handled {
+		return err
+	}
+
+	unmarshaler, rv := indirectOptional(rv, opt.is_Optional() || opt.is_COptional())
 
-	if traceEnabled {
-		zlog.Debug("decode: type",
-			zap.Stringer("value_kind", rv.Kind()),
-			zap.Bool("has_unmarshaler", (unmarshaler != nil)),
-			zap.Reflect("options", opt),
+	if dec.tracing() {
+		dec.tlog().Debug("decode: type",
+			logStringer("value_kind", rv.Kind()),
+			logBool("has_unmarshaler", (unmarshaler != nil)),
+			logReflect("options", opt),
 		)
 	}
 
@@ -65,14 +67,16 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 		}
 
 		if !isPresent {
-			if traceEnabled {
-				zlog.Debug("decode: skipping optional value", zap.Stringer("type", rv.Kind()))
+			if dec.tracing() {
+				dec.tlog().Debug("decode: skipping optional value", logStringer("type", rv.Kind()))
 			}
 
-			rv.Set(reflect.Zero(rv.Type()))
-			return
+			return setAbsent(rv, opt)
 		}
 
+		if handled, err := dec.decodePointerLevel(rv, opt, dec.decodeBorsh); handled {
+			return err
+		}
 		// we have ptr here we should not go get the element
 		unmarshaler, rv = indirect(rv, false)
 	}
@@ -84,14 +88,16 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 		}
 
 		if !isPresent {
-			if traceEnabled {
-				zlog.Debug("decode: skipping optional value", zap.Stringer("type", rv.Kind()))
+			if dec.tracing() {
+				dec.tlog().Debug("decode: skipping optional value", logStringer("type", rv.Kind()))
 			}
 
-			rv.Set(reflect.Zero(rv.Type()))
-			return
+			return setAbsent(rv, opt)
 		}
 
+		if handled, err := dec.decodePointerLevel(rv, opt, dec.decodeBorsh); handled {
+			return err
+		}
 		// we have ptr here we should not go get the element
 		unmarshaler, rv = indirect(rv, false)
 	}
@@ -99,12 +105,76 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 	opt = opt.clone().set_Optional(false).set_COptional(false)
 
 	if unmarshaler != nil {
-		if traceEnabled {
-			zlog.Debug("decode: using UnmarshalWithDecoder method to decode type")
+		if dec.tracing() {
+			dec.tlog().Debug("decode: using UnmarshalWithDecoder method to decode type")
 		}
 		return unmarshaler.UnmarshalWithDecoder(dec)
 	}
 
+	if handled, err := dec.decodeRunes(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeSwapped(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeVLQ(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeVarints(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeDelta(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeRLE(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeBigInt(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeWidthInt(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeNetAddr(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeDuration(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeTime(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeStdBinary(rv); handled {
+		return err
+	}
+	if handled, err := dec.decodeText(rv); handled {
+		return err
+	}
+	if handled, err := dec.decodeEncrypted(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeCompressed(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeSizeOfTarget(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeLenPrefixed(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeFixedString(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeCString(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeBoundedString(rv, opt); handled {
+		return err
+	}
+	if handled, err := dec.decodeHeapBytes(rv); handled {
+		return err
+	}
+
 	rt := rv.Type()
 	switch rv.Kind() {
 	// case reflect.Int:
@@ -170,16 +240,26 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 	case reflect.Float32:
 		var n float32
 		n, err = dec.ReadFloat32(LE)
-		rv.SetFloat(float64(n))
+		setFloat32(rv, n)
 		return
 	case reflect.Float64:
 		var n float64
 		n, err = dec.ReadFloat64(LE)
 		rv.SetFloat(n)
 		return
+	case reflect.Complex64:
+		var n complex64
+		n, err = dec.ReadComplex64(LE)
+		setComplex64(rv, n)
+		return
+	case reflect.Complex128:
+		var n complex128
+		n, err = dec.ReadComplex128(LE)
+		rv.SetComplex(n)
+		return
 	case reflect.Bool:
 		var r bool
-		r, err = dec.ReadBool()
+		r, err = dec.ReadBoolWidth(boolWidth(opt), LE)
 		rv.SetBool(r)
 		return
 	case reflect.Interface:
@@ -191,8 +271,8 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 	switch rt.Kind() {
 	case reflect.Array:
 		l := rt.Len()
-		if traceEnabled {
-			zlog.Debug("decoding: reading array", zap.Int("length", l))
+		if dec.tracing() {
+			dec.tlog().Debug("decoding: reading array", logInt("length", l))
 		}
 
 		switch k := rv.Type().Elem().Kind(); k {
@@ -203,7 +283,7 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 		default:
 			for i := 0; i < l; i++ {
 				if err = dec.decodeBorsh(rv.Index(i), nil); err != nil {
-					return
+					return newElementError("decoding", i, err)
 				}
 			}
 		}
@@ -213,15 +293,15 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 		if opt.hasSizeOfSlice() {
 			l = opt.getSizeOfSlice()
 		} else {
-			length, err := dec.ReadUint32(LE)
+			length, err := dec.readBorshLength()
 			if err != nil {
 				return err
 			}
 			l = int(length)
 		}
 
-		if traceEnabled {
-			zlog.Debug("reading slice", zap.Int("len", l), typeField("type", rv))
+		if dec.tracing() {
+			dec.tlog().Debug("reading slice", logInt("len", l), typeField("type", rv))
 		}
 
 		if l == 0 {
@@ -231,6 +311,9 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 		if l > dec.Remaining() {
 			return io.ErrUnexpectedEOF
 		}
+		if err := dec.checkLength(l, rt.Elem()); err != nil {
+			return err
+		}
 
 		switch k := rv.Type().Elem().Kind(); k {
 		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
@@ -244,7 +327,7 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 				element := reflect.New(rt.Elem())
 				// decode into element:
 				if err = dec.decodeBorsh(element, nil); err != nil {
-					return
+					return newElementError("decoding", i, err)
 				}
 				// append to slice:
 				rv.Set(reflect.Append(rv, element.Elem()))
@@ -257,7 +340,7 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 		}
 
 	case reflect.Map:
-		l, err := dec.ReadUint32(LE)
+		l, err := dec.readBorshLength()
 		if err != nil {
 			return err
 		}
@@ -265,6 +348,9 @@ func (dec *Decoder) decodeBorsh(rv reflect.Value, opt *option) (err error) {
 			// If the map has no content, keep it nil.
 			return nil
 		}
+		if err := dec.checkLength(int(l), rt.Key(), rt.Elem()); err != nil {
+			return err
+		}
 		rv.Set(reflect.MakeMap(rt))
 		for i := 0; i < int(l); i++ {
 			key := reflect.New(rt.Key())
@@ -313,33 +399,34 @@ func isTypeBorshEnum(typ reflect.Type) bool {
 }
 
 func (dec *Decoder) decodeStructBorsh(rt reflect.Type, rv reflect.Value) (err error) {
-	l := rv.NumField()
+	plan := dec.planOf(rt)
+	l := len(plan.fields)
 
-	if traceEnabled {
-		zlog.Debug("decode: struct", zap.Int("fields", l), zap.Stringer("type", rv.Kind()))
+	if dec.tracing() {
+		dec.tlog().Debug("decode: struct", logInt("fields", l), logStringer("type", rv.Kind()))
 	}
 
-	// Handle complex enum:
-	if rt.NumField() > 0 {
-		// If the first field has type BorshEnum and is flagged with "borsh_enum"
-		// we have a complex enum:
-		firstField := rt.Field(0)
-		if isTypeBorshEnum(firstField.Type) &&
-			parseFieldTag(firstField.Tag).IsBorshEnum {
-			return dec.deserializeComplexEnum(rv)
-		}
+	if dec.strictTags && plan.tagErr != nil {
+		return plan.tagErr
+	}
+
+	// Handle complex enum: the first field has type BorshEnum
+	// and is flagged with "borsh_enum".
+	if plan.complexEnum {
+		return dec.deserializeComplexEnum(rv)
 	}
 
 	sizeOfMap := map[string]int{}
+	var byteSizes map[string]int
 	seenBinaryExtensionField := false
 	for i := 0; i < l; i++ {
-		structField := rt.Field(i)
-		fieldTag := parseFieldTag(structField.Tag)
+		structField := plan.fields[i]
+		fieldTag := plan.tags[i]
 
-		if fieldTag.Skip {
-			if traceEnabled {
-				zlog.Debug("decode: skipping struct field with skip flag",
-					zap.String("struct_field_name", structField.Name),
+		if fieldTag.Skip || fieldTag.SkipDecode {
+			if dec.tracing() {
+				dec.tlog().Debug("decode: skipping struct field with skip flag",
+					logString("struct_field_name", structField.Name),
 				)
 			}
 			continue
@@ -358,20 +445,27 @@ func (dec *Decoder) decodeStructBorsh(rt reflect.Type, rv reflect.Value) (err er
 			//        But at the same time, does it make sense otherwise? What would be the inference
 			//        rule in the case of extra bytes available? Continue decoding and revert if it's
 			//        not working? But how to detect valid errors?
-			if len(dec.data[dec.pos:]) <= 0 {
+			if !dec.HasRemaining() {
+				dec.warnFieldf(structField.Name, "missing binary extension, left to its zero value")
 				continue
 			}
 		}
-		v := rv.Field(i)
+		if fieldTag.Reserved > 0 {
+			if err = dec.readReserved(fieldTag.Reserved); err != nil {
+				return newFieldError("decoding", structField.Name, err)
+			}
+			continue
+		}
+		v := plan.field(rv, i)
 		if !v.CanSet() {
 			// This means that the field cannot be set, to fix this
 			// we need to create a pointer to said field
 			if !v.CanAddr() {
 				// we cannot create a point to field skipping
-				if traceEnabled {
-					zlog.Debug("skipping struct field that cannot be addressed",
-						zap.String("struct_field_name", structField.Name),
-						zap.Stringer("struct_value_type", v.Kind()),
+				if dec.tracing() {
+					dec.tlog().Debug("skipping struct field that cannot be addressed",
+						logString("struct_field_name", structField.Name),
+						logStringer("struct_value_type", v.Kind()),
 					)
 				}
 				return fmt.Errorf("unable to decode a none setup struc field %q with type %q", structField.Name, v.Kind())
@@ -380,38 +474,49 @@ func (dec *Decoder) decodeStructBorsh(rt reflect.Type, rv reflect.Value) (err er
 		}
 
 		if !v.CanSet() {
-			if traceEnabled {
-				zlog.Debug("skipping struct field that cannot be addressed",
-					zap.String("struct_field_name", structField.Name),
-					zap.Stringer("struct_value_type", v.Kind()),
+			if dec.tracing() {
+				dec.tlog().Debug("skipping struct field that cannot be addressed",
+					logString("struct_field_name", structField.Name),
+					logStringer("struct_value_type", v.Kind()),
 				)
 			}
 			continue
 		}
 
-		option := &option{
-			is_OptionalField:  fieldTag.Option,
-			is_COptionalField: fieldTag.COption,
-			Order:             fieldTag.Order,
-		}
+		option := plan.fieldOption(i, EncodingBorsh, nil)
 
+		if isSizeOfPath(fieldTag) {
+			size, err := sizeOfPath(fieldTag, rv)
+			if err != nil {
+				return newFieldError("decoding", structField.Name, err)
+			}
+			sizeOfMap[structField.Name] = size
+		}
 		if s, ok := sizeOfMap[structField.Name]; ok {
-			option.setSizeOfSlice(s)
+			option = option.clone().setSizeOfSlice(s)
+		}
+		if fieldTag.Union != "" {
+			option = option.clone()
+			if option.UnionVariant, err = unionVariant(plan, rv, i); err != nil {
+				return newFieldError("decoding", structField.Name, err)
+			}
 		}
 
-		if traceEnabled {
-			zlog.Debug("decode: struct field",
-				zap.Stringer("struct_field_value_type", v.Kind()),
-				zap.String("struct_field_name", structField.Name),
-				zap.Reflect("struct_field_tags", fieldTag),
-				zap.Reflect("struct_field_option", option),
+		if dec.tracing() {
+			dec.tlog().Debug("decode: struct field",
+				logStringer("struct_field_value_type", v.Kind()),
+				logString("struct_field_name", structField.Name),
+				logReflect("struct_field_tags", fieldTag),
+				logReflect("struct_field_option", option),
 			)
 		}
 
 		rt := v.Type()
 		ptrImplements := reflect.PtrTo(rt).Implements(unmarshalableType)
 		vImplements := rt.Implements(unmarshalableType)
-		if ptrImplements || vImplements {
+		// Optional fields go through decodeBorsh, which reads their presence flag.
+		if (ptrImplements || vImplements) && !option.is_Optional() && !option.is_COptional() {
+			dec.currentFieldOpt = option
 			switch {
 			case ptrImplements:
 				m := reflect.New(rt)
@@ -434,20 +539,43 @@ func (dec *Decoder) decodeStructBorsh(rt reflect.Type, rv reflect.Value) (err er
 			}
 		}
 
-		if err = dec.decodeBorsh(v, option); err != nil {
-			return fmt.Errorf("error while decoding %q field: %w", structField.Name, err)
+		warned := dec.warningCount()
+		if size, ok := byteSizes[structField.Name]; ok {
+			err = dec.decodeByteSized(size, v, option, (*Decoder).decodeBorsh)
+		} else {
+			err = dec.decodeBorsh(v, option)
+		}
+		if err != nil {
+			return newFieldError("decoding", structField.Name, err)
+		}
+		if dec.warningCount() > warned {
+			dec.nameWarnings(warned, structField.Name)
+		}
+		if fieldTag.IsBorshEnum {
+			if err = dec.checkEnum(structField.Name, v); err != nil {
+				return newFieldError("decoding", structField.Name, err)
+			}
 		}
 
-		if fieldTag.SizeOf != "" {
-			size := sizeof(structField.Type, v)
-			if traceEnabled {
-				zlog.Debug("setting size of field",
-					zap.String("field_name", fieldTag.SizeOf),
-					zap.Int("size", size),
+		if fieldTag.SizeOf != "" && !isSizeOfPath(fieldTag) {
+			size, err := sizeOfField(fieldTag, structField.Type, v)
+			if err != nil {
+				return newFieldError("decoding", structField.Name, err)
+			}
+			if dec.tracing() {
+				dec.tlog().Debug("setting size of field",
+					logString("field_name", fieldTag.SizeOf),
+					logInt("size", size),
 				)
 			}
 			sizeOfMap[fieldTag.SizeOf] = size
 		}
+		if fieldTag.ByteSizeOf != "" {
+			if byteSizes == nil {
+				byteSizes = map[string]int{}
+			}
+			byteSizes[fieldTag.ByteSizeOf] = sizeof(structField.Type, v)
+		}
 	}
 	return
 }
@@ -456,3 +584,13 @@ var (
 	marshalableType   = reflect.TypeOf((*BinaryMarshaler)(nil)).Elem()
 	unmarshalableType = reflect.TypeOf((*BinaryUnmarshaler)(nil)).Elem()
 )
+
+// readBorshLength reads the u32 length prefix of a slice or a map.
+func (dec *Decoder) readBorshLength() (uint32, error) {
+	offset := int(dec.Position())
+	length, err := dec.ReadUint32(dec.lengthOrder())
+	if err == nil {
+		length = uint32(dec.faultLength(offset, int(length)))
+	}
+	return length, err
+}
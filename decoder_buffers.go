@@ -0,0 +1,110 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+)
+
+// NewDecoderWithBuffers returns a decoder that reads the provided buffers
+// one after the other, as if they were a single buffer, without concatenating them.
+// It accepts a net.Buffers, e.g. the result of a scatter-gather read.
+//
+// Values that straddle two or more buffers are copied into a scratch buffer
+// when read; everything else is read in place, so byte slices returned by
+// the decoder may alias the provided buffers, exactly like with NewDecoderWithEncoding.
+// The buffers must not be modified while decoding.
+func NewDecoderWithBuffers(bufs [][]byte, enc Encoding) *Decoder {
+	if !isValidEncoding(enc) {
+		panic(fmt.Sprintf("provided encoding is not valid: %s", enc))
+	}
+	dec := &Decoder{
+		encoding: enc,
+		segments: append([][]byte(nil), bufs...),
+	}
+	dec.seek(0)
+	return dec
+}
+
+// seek moves the decoder to the provided offset of the whole input.
+func (dec *Decoder) seek(offset int) {
+	dec.base = 0
+	dec.pos = 0
+	dec.data = nil
+	dec.rest = append(dec.rest[:0], dec.segments...)
+	dec.restLen = 0
+	for _, seg := range dec.segments {
+		dec.restLen += len(seg)
+	}
+	dec.skip(offset)
+}
+
+// next makes the first of the following segments the current one.
+func (dec *Decoder) next() {
+	dec.base += len(dec.data)
+	dec.data = dec.rest[0]
+	dec.rest = dec.rest[1:]
+	dec.restLen -= len(dec.data)
+	dec.pos = 0
+}
+
+// skip moves the decoder forward by n bytes.
+func (dec *Decoder) skip(n int) {
+	for n > len(dec.data)-dec.pos && len(dec.rest) > 0 {
+		n -= len(dec.data) - dec.pos
+		dec.next()
+	}
+	dec.pos += n
+}
+
+// fill makes at least n bytes (or all the remaining ones, when there are fewer)
+// available in dec.data from dec.pos. It's a no-op for single buffer decoders.
+func (dec *Decoder) fill(n int) {
+	if len(dec.data)-dec.pos >= n || len(dec.rest) == 0 {
+		return
+	}
+	// Skip to the next segment for free when the current one is exhausted.
+	for dec.pos == len(dec.data) && len(dec.rest) > 0 {
+		dec.next()
+	}
+	if len(dec.data)-dec.pos >= n || len(dec.rest) == 0 {
+		return
+	}
+
+	// The value straddles segments: copy it to a scratch buffer
+	// that becomes the current segment.
+	tail := dec.data[dec.pos:]
+	if n > len(tail)+dec.restLen {
+		n = len(tail) + dec.restLen
+	}
+	buf := make([]byte, len(tail), n)
+	copy(buf, tail)
+	for len(buf) < n {
+		take := n - len(buf)
+		if take > len(dec.rest[0]) {
+			take = len(dec.rest[0])
+		}
+		buf = append(buf, dec.rest[0][:take]...)
+		dec.restLen -= take
+		if take == len(dec.rest[0]) {
+			dec.rest = dec.rest[1:]
+		} else {
+			dec.rest[0] = dec.rest[0][take:]
+		}
+	}
+	dec.base += dec.pos
+	dec.data = buf
+	dec.pos = 0
+}
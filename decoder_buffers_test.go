@@ -0,0 +1,92 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type buffersTestStruct struct {
+	A uint16
+	B string
+	C []uint32
+	D Varuint32
+	E *uint64 `bin:"optional"`
+	F [3]int16
+	G float64
+	H []byte
+	I uint64 `bin:"binary_extension"`
+}
+
+func TestDecoder_WithBuffers(t *testing.T) {
+	e := uint64(42)
+	in := buffersTestStruct{
+		A: 0xabcd,
+		B: "straddle",
+		C: []uint32{1, 2, 3},
+		D: 300,
+		E: &e,
+		F: [3]int16{-1, 0, 1},
+		G: 3.5,
+		H: []byte{9, 8, 7},
+		I: 7,
+	}
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(in))
+		data := buf.Bytes()
+
+		// Split the data at every possible pair of points, with an empty buffer in between.
+		for i := 0; i <= len(data); i++ {
+			for j := i; j <= len(data); j++ {
+				bufs := net.Buffers{data[:i], nil, data[i:j], data[j:]}
+				dec := NewDecoderWithBuffers(bufs, enc)
+				assert.Equal(t, len(data), dec.Len())
+
+				var out buffersTestStruct
+				require.NoError(t, dec.Decode(&out), "%s split at %d and %d", enc, i, j)
+				assert.Equal(t, in, out)
+				assert.Equal(t, len(data), int(dec.Position()))
+				assert.False(t, dec.HasRemaining())
+			}
+		}
+	}
+}
+
+func TestDecoder_WithBuffers_Positions(t *testing.T) {
+	dec := NewDecoderWithBuffers([][]byte{{1, 2}, {3}, {}, {4, 5, 6}}, EncodingBin)
+	assert.Equal(t, 6, dec.Remaining())
+
+	require.NoError(t, dec.SkipBytes(1))
+	v, err := dec.ReadUint32(BE)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0x02030405), v)
+	assert.Equal(t, uint(5), dec.Position())
+	assert.Equal(t, 1, dec.Remaining())
+
+	require.NoError(t, dec.SetPosition(2))
+	b, err := dec.ReadNBytes(3)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{3, 4, 5}, b)
+
+	_, err = dec.ReadNBytes(2)
+	assert.Error(t, err)
+	assert.Error(t, dec.SetPosition(6))
+}
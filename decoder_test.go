@@ -18,8 +18,10 @@
 package bin
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"math"
 	"reflect"
 	"testing"
@@ -475,6 +477,82 @@ func TestDecoder_float64(t *testing.T) {
 	assert.True(t, math.IsNaN(n))
 }
 
+func TestDecoder_complex(t *testing.T) {
+	buf := []byte{
+		0x00, 0x00, 0x80, 0x3f, 0x00, 0x00, 0x00, 0xc0,
+		0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+
+	d := NewBinDecoder(buf)
+
+	c64, err := d.ReadComplex64(LE)
+	assert.NoError(t, err)
+	assert.Equal(t, complex(float32(1), float32(-2)), c64)
+	assert.Equal(t, 16, d.Remaining())
+
+	c128, err := d.ReadComplex128(BE)
+	assert.NoError(t, err)
+	assert.Equal(t, complex(float64(1), float64(-2)), c128)
+	assert.Equal(t, 0, d.Remaining())
+
+	_, err = d.ReadComplex64(LE)
+	assert.Error(t, err)
+
+	type signal struct {
+		A complex64
+		B complex128 `bin:"big"`
+	}
+	// B is big-endian, except in Borsh, which is always little-endian.
+	fieldOrders := []byte{
+		0x00, 0x00, 0xc0, 0x3f, 0x00, 0x00, 0x80, 0xbe,
+		0xc0, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x40, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	borshOrders := []byte{
+		0x00, 0x00, 0xc0, 0x3f, 0x00, 0x00, 0x80, 0xbe,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08, 0xc0,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x40,
+	}
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		in := signal{A: complex(1.5, -0.25), B: complex(-3, 4)}
+		out := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(out, enc).Encode(in))
+		if enc == EncodingBorsh {
+			assert.Equal(t, borshOrders, out.Bytes(), enc)
+		} else {
+			assert.Equal(t, fieldOrders, out.Bytes(), enc)
+		}
+
+		var got signal
+		require.NoError(t, NewDecoderWithEncoding(out.Bytes(), enc).Decode(&got))
+		assert.Equal(t, in, got)
+	}
+
+	type spectrum struct {
+		Bins    []complex64
+		Window  [2]complex128
+		Peak    *complex64  `bin:"optional"`
+		Missing *complex128 `bin:"optional"`
+	}
+	peak := complex64(complex(0.5, 2))
+	in := spectrum{
+		Bins:   []complex64{1, complex(0, -1)},
+		Window: [2]complex128{complex(1, 1), complex(-1, -1)},
+		Peak:   &peak,
+	}
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		out := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(out, enc).Encode(in))
+		require.NoError(t, ConformsWithEncoding(out.Bytes(), enc, spectrum{}), enc)
+		require.Error(t, ConformsWithEncoding(out.Bytes()[:out.Len()-5], enc, spectrum{}), enc)
+
+		var got spectrum
+		require.NoError(t, NewDecoderWithEncoding(out.Bytes(), enc).Decode(&got))
+		assert.Equal(t, in, got)
+	}
+}
+
 func TestDecoder_string(t *testing.T) {
 	buf := []byte{
 		0x03, 0x31, 0x32, 0x33, // "123"
@@ -500,6 +578,44 @@ func TestDecoder_string(t *testing.T) {
 	assert.Equal(t, 0, d.Remaining())
 }
 
+func TestDecoder_ReadStringUnsafe(t *testing.T) {
+	buf := []byte{
+		0x03, 0x31, 0x32, 0x33, // "123"
+		0x00,                                           // ""
+		0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Rust string length
+		0x61, 0x62, 0x63, // "abc"
+	}
+
+	d := NewBinDecoder(buf)
+
+	s, err := d.ReadStringUnsafe()
+	assert.NoError(t, err)
+	assert.Equal(t, "123", s)
+
+	empty, err := d.ReadStringUnsafe()
+	assert.NoError(t, err)
+	assert.Equal(t, "", empty)
+
+	rust, err := d.ReadRustStringUnsafe()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", rust)
+	assert.Equal(t, 0, d.Remaining())
+
+	// The strings alias the input.
+	buf[1] = '9'
+	buf[13] = 'x'
+	assert.Equal(t, "923", s)
+	assert.Equal(t, "xbc", rust)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		d.SetPosition(0)
+		if _, err := d.ReadStringUnsafe(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Equal(t, 0.0, allocs)
+}
+
 func TestDecoder_Decode_String_Err(t *testing.T) {
 	buf := []byte{
 		0x01, 0x00, 0x00, 0x00,
@@ -612,6 +728,41 @@ func TestDecoder_Slice_InvalidLen(t *testing.T) {
 	assert.EqualError(t, err, "unexpected EOF")
 }
 
+func TestDecoder_BoundedVarints(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewBinEncoder(buf)
+	require.NoError(t, enc.WriteUvarint32(math.MaxUint32))
+	require.NoError(t, enc.WriteUvarint16(math.MaxUint16))
+	require.NoError(t, enc.WriteVarint32(math.MinInt32))
+	require.NoError(t, enc.WriteVarint16(math.MinInt16))
+
+	dec := NewBinDecoder(buf.Bytes())
+	u32, err := dec.ReadUvarint32()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(math.MaxUint32), u32)
+	u16, err := dec.ReadUvarint16()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(math.MaxUint16), u16)
+	i32, err := dec.ReadVarint32()
+	require.NoError(t, err)
+	assert.Equal(t, int32(math.MinInt32), i32)
+	i16, err := dec.ReadVarint16()
+	require.NoError(t, err)
+	assert.Equal(t, int16(math.MinInt16), i16)
+
+	buf.Reset()
+	require.NoError(t, enc.WriteUvarint64(math.MaxUint16+1))
+	require.NoError(t, enc.WriteVarint64(math.MaxInt32+1))
+	dec = NewBinDecoder(buf.Bytes())
+	_, err = dec.ReadUvarint16()
+	assert.True(t, errors.Is(err, ErrVarIntOverflow), err)
+	_, err = dec.ReadVarint32()
+	assert.True(t, errors.Is(err, ErrVarIntOverflow), err)
+
+	err = NewBinEncoder(new(bytes.Buffer)).WriteLength(-1)
+	assert.True(t, errors.Is(err, ErrVarIntOverflow), err)
+}
+
 func TestDecoder_Int64(t *testing.T) {
 	// little endian
 	buf := []byte{
@@ -0,0 +1,78 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// setAbsent sets an optional value decoded as absent: to the default
+// of its field (see the `default` tag), if any, or else to its zero value.
+func setAbsent(rv reflect.Value, opt *option) error {
+	if opt.Default == nil {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	for rv.Kind() == reflect.Ptr {
+		rv.Set(reflect.New(rv.Type().Elem()))
+		rv = rv.Elem()
+	}
+	return setDefault(rv, *opt.Default)
+}
+
+// setDefault parses s as a value of the kind of rv, and sets rv to it.
+func setDefault(rv reflect.Value, s string) error {
+	switch rv.Kind() {
+	case reflect.Bool:
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("default %q: %w", s, err)
+		}
+		rv.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(s, 0, rv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("default %q: %w", s, err)
+		}
+		rv.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v, err := strconv.ParseUint(s, 0, rv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("default %q: %w", s, err)
+		}
+		rv.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(s, rv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("default %q: %w", s, err)
+		}
+		rv.SetFloat(v)
+	case reflect.String:
+		rv.SetString(s)
+	default:
+		return fmt.Errorf("default %q: unsupported type %s", s, rv.Type())
+	}
+	return nil
+}
+
+// checkDefault reports whether s is a valid default for values of type rt.
+func checkDefault(rt reflect.Type, s string) error {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	return setDefault(reflect.New(rt).Elem(), s)
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type defaultsV2 struct {
+	ID      uint16
+	Limit   *uint32 `bin:"optional,default=100"`
+	Ratio   float64 `bin:"optional default=0.5"`
+	Enabled bool    `bin:"optional,default=true"`
+}
+
+func TestDefault(t *testing.T) {
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		// Old peers omit the optional fields at the end.
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&defaultsV2{ID: 3}), enc)
+
+		var out defaultsV2
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out), enc)
+		require.NotNil(t, out.Limit, enc)
+		assert.Equal(t, uint32(100), *out.Limit, enc)
+		assert.Equal(t, 0.5, out.Ratio, enc)
+		assert.True(t, out.Enabled, enc)
+
+		// Present values are kept, zero or not.
+		limit := uint32(0)
+		in := defaultsV2{ID: 3, Limit: &limit, Ratio: 2}
+		buf.Reset()
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&in), enc)
+		out = defaultsV2{}
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out), enc)
+		assert.Equal(t, uint32(0), *out.Limit, enc)
+		assert.Equal(t, 2.0, out.Ratio, enc)
+	}
+	require.NoError(t, Precompile(defaultsV2{}))
+
+	type account struct {
+		Name *string `bin:"coption,default=anonymous"`
+	}
+	var out account
+	require.NoError(t, UnmarshalBorsh(&out, []byte{0, 0, 0, 0}))
+	assert.Equal(t, "anonymous", *out.Name)
+}
+
+func TestDefault_Errors(t *testing.T) {
+	type notOptional struct {
+		N uint32 `bin:"default=1"`
+	}
+	assert.EqualError(t, Precompile(notOptional{}), "precompile: bin.notOptional: field \"N\": the default tag only applies to optional fields")
+
+	type overflow struct {
+		N *uint8 `bin:"optional,default=300"`
+	}
+	assert.EqualError(t, Precompile(overflow{}), "precompile: bin.overflow: field \"N\": default \"300\": strconv.ParseUint: parsing \"300\": value out of range")
+
+	type point struct {
+		X, Y int32
+	}
+	type unsupported struct {
+		V *point `bin:"optional,default=1"`
+	}
+	assert.EqualError(t, Precompile(unsupported{}), "precompile: bin.unsupported: field \"V\": default \"1\": unsupported type bin.point")
+	assert.Equal(t, []string{"default="}, parseFieldTag(`bin:"optional default="`).Invalid)
+}
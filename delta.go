@@ -0,0 +1,108 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// isDeltaType reports whether the `delta` tag applies to the provided type.
+func isDeltaType(rt reflect.Type) bool {
+	return rt.Kind() == reflect.Slice && isIntegerKind(rt.Elem().Kind())
+}
+
+// encodeDelta handles the slices affected by the `delta` tag: after the length,
+// each element is written as the zigzag varint (as written by WriteVarint64)
+// of its difference with the previous element, the first element being
+// written as its difference with zero. The differences wrap around like
+// the integers of the slice do, so the slice doesn't need to be sorted,
+// but monotonic slices, e.g. of timestamps, take the least space.
+func (e *Encoder) encodeDelta(rv reflect.Value, opt *option) (handled bool, err error) {
+	if !opt.Delta {
+		return false, nil
+	}
+	if !isDeltaType(rv.Type()) {
+		return true, fmt.Errorf("delta: unsupported type %s, expected a slice of integers", rv.Type())
+	}
+	l := rv.Len()
+	if opt.hasSizeOfSlice() {
+		l = opt.getSizeOfSlice()
+	} else if err := e.WriteLength(l); err != nil {
+		return true, err
+	}
+	var prev uint64
+	for i := 0; i < l; i++ {
+		v := enumValue(rv.Index(i))
+		if err := e.WriteVarint64(int64(v - prev)); err != nil {
+			return true, newElementError("encoding", i, err)
+		}
+		prev = v
+	}
+	return true, nil
+}
+
+// decodeDelta handles the slices affected by the `delta` tag.
+func (dec *Decoder) decodeDelta(rv reflect.Value, opt *option) (handled bool, err error) {
+	if !opt.Delta {
+		return false, nil
+	}
+	if !isDeltaType(rv.Type()) {
+		return true, fmt.Errorf("delta: unsupported type %s, expected a slice of integers", rv.Type())
+	}
+	var l int
+	if opt.hasSizeOfSlice() {
+		l = opt.getSizeOfSlice()
+	} else if l, err = dec.ReadLength(); err != nil {
+		return true, err
+	}
+	// Each difference takes at least one byte.
+	if l > dec.Remaining() {
+		return true, fmt.Errorf("delta: length %d exceeds the remaining [%d] bytes", l, dec.Remaining())
+	}
+	out := reflect.MakeSlice(rv.Type(), l, l)
+	var prev uint64
+	for i := 0; i < l; i++ {
+		delta, err := dec.ReadVarint64()
+		if err != nil {
+			return true, newElementError("decoding", i, err)
+		}
+		v := prev + uint64(delta)
+		if err := setInteger(out.Index(i), v); err != nil {
+			return true, newElementError("decoding", i, err)
+		}
+		prev = v
+	}
+	rv.Set(out)
+	return true, nil
+}
+
+// setInteger sets an integer value from its 64-bit representation
+// (as returned by enumValue), rejecting the values that don't fit.
+func setInteger(rv reflect.Value, v uint64) error {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.OverflowInt(int64(v)) {
+			return fmt.Errorf("value %d overflows %s", int64(v), rv.Type())
+		}
+		rv.SetInt(int64(v))
+	default:
+		if rv.OverflowUint(v) {
+			return fmt.Errorf("value %d overflows %s", v, rv.Type())
+		}
+		rv.SetUint(v)
+	}
+	return nil
+}
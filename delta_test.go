@@ -0,0 +1,92 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelta(t *testing.T) {
+	type series struct {
+		Slots      []uint64 `bin:"delta"`
+		Timestamps []int64  `bin:"delta"`
+		Small      []uint8  `bin:"delta"`
+		Count      uint8    `bin:"sizeof=Sizes"`
+		Sizes      []int16  `bin:"delta"`
+	}
+	in := series{
+		Slots:      []uint64{1000, 1001, 1003, 1003},
+		Timestamps: []int64{-5, 60, 0},
+		Small:      []uint8{255, 0},
+		Count:      2,
+		Sizes:      []int16{math.MinInt16, math.MaxInt16},
+	}
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).Encode(in))
+	assert.Equal(t, []byte{
+		0x04, 0xd0, 0x0f, 0x02, 0x04, 0x00,
+		0x03, 0x09, 0x82, 0x01, 0x77,
+		0x02, 0xfe, 0x03, 0xfd, 0x03,
+		0x02,
+		0xff, 0xff, 0x03, 0xfe, 0xff, 0x07,
+	}, buf.Bytes())
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(in))
+		var out series
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out))
+		assert.Equal(t, in, out)
+		assert.NoError(t, ConformsWithEncoding(buf.Bytes(), enc, out))
+	}
+
+	// A difference that leads out of the range of the element type.
+	type bytesSeries struct {
+		Values []uint8 `bin:"delta"`
+	}
+	var out bytesSeries
+	err := NewBinDecoder([]byte{0x02, 0x02, 0xfe, 0x03}).Decode(&out)
+	assert.EqualError(t, err, `error while decoding "Values" field: error while decoding element 1: value 256 overflows uint8`)
+
+	type invalid struct {
+		Values []float64 `bin:"delta"`
+	}
+	_, err = MarshalBin(invalid{})
+	assert.EqualError(t, err, `error while encoding "Values" field: delta: unsupported type []float64, expected a slice of integers`)
+}
+
+func TestEncoder_WriteVarint64(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewBinEncoder(buf)
+	require.NoError(t, enc.WriteUvarint64(math.MaxUint64))
+	require.NoError(t, enc.WriteVarint64(math.MinInt64))
+	require.NoError(t, enc.WriteUVarInt(math.MaxInt64))
+
+	dec := NewBinDecoder(buf.Bytes())
+	u, err := dec.ReadUvarint64()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(math.MaxUint64), u)
+	v, err := dec.ReadVarint64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(math.MinInt64), v)
+	u, err = dec.ReadUvarint64()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(math.MaxInt64), u)
+}
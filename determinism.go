@@ -0,0 +1,193 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// ErrNonDeterministic is returned by DeterminismCheck when
+// encoding the same value twice produced different bytes.
+var ErrNonDeterministic = errors.New("non-deterministic encoding")
+
+// DeterminismCheck encodes v with the Bin encoding n times, from several
+// goroutines at once, and returns an error wrapping ErrNonDeterministic if
+// the encodings are not all identical, e.g. in the tests of types whose
+// encoding is signed or hashed.
+//
+// The encoders are deterministic: maps are encoded in the order of their
+// keys (by value for integer and string keys, by encoded bytes for the
+// other ones); DeterminismCheck catches what they can't control, like
+// custom MarshalWithEncoder methods that iterate over maps.
+func DeterminismCheck(v interface{}, n int) error {
+	return DeterminismCheckWithEncoding(v, EncodingBin, n)
+}
+
+// DeterminismCheckWithEncoding is like DeterminismCheck, but for the provided encoding.
+func DeterminismCheckWithEncoding(v interface{}, enc Encoding, n int) error {
+	if !isValidEncoding(enc) {
+		return fmt.Errorf("determinism check: invalid encoding %d", enc)
+	}
+	encode := func() ([]byte, error) {
+		buf := new(bytes.Buffer)
+		if err := NewEncoderWithEncoding(buf, enc).Encode(v); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	want, err := encode()
+	if err != nil {
+		return fmt.Errorf("determinism check: %w", err)
+	}
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers < 2 {
+		workers = 2
+	}
+	if workers > n {
+		workers = n
+	}
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			// The worker w does the encodings w, w+workers, w+2*workers, etc.
+			for i := w; i < n; i += workers {
+				got, err := encode()
+				if err == nil && !bytes.Equal(got, want) {
+					err = fmt.Errorf("%w: encoding %d differs from the first one at offset %d", ErrNonDeterministic, i+1, firstDifference(got, want))
+				}
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return fmt.Errorf("determinism check: %w", firstErr)
+	}
+	return nil
+}
+
+func firstDifference(a, b []byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	if len(a) < len(b) {
+		return len(a)
+	}
+	return len(b)
+}
+
+// sortedMapKeys returns the keys of a map in a deterministic order: by value
+// for integer, float and string keys (interface keys by their dynamic value,
+// when they all share one of those kinds), and by their encoding for the
+// other ones. Every map encoder orders its keys with it.
+func (e *Encoder) sortedMapKeys(rv reflect.Value) ([]reflect.Value, error) {
+	keys := rv.MapKeys()
+	values := make([]reflect.Value, len(keys))
+	for i, key := range keys {
+		if key.Kind() == reflect.Interface {
+			key = key.Elem()
+		}
+		values[i] = key
+	}
+	var less func(a, b reflect.Value) bool
+	switch sharedKind(values) {
+	case reflect.String:
+		less = func(a, b reflect.Value) bool { return a.String() < b.String() }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		less = func(a, b reflect.Value) bool { return a.Uint() < b.Uint() }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		less = func(a, b reflect.Value) bool { return a.Int() < b.Int() }
+	case reflect.Float32, reflect.Float64:
+		less = func(a, b reflect.Value) bool { return a.Float() < b.Float() }
+	default:
+		encoded := make([][]byte, len(keys))
+		for i, key := range keys {
+			buf := new(bytes.Buffer)
+			if err := NewEncoderWithEncoding(buf, e.encoding).Encode(key.Interface()); err != nil {
+				return nil, fmt.Errorf("map key: %w", err)
+			}
+			encoded[i] = buf.Bytes()
+		}
+		sort.Sort(keysByEncoding{keys, encoded})
+		return keys, nil
+	}
+	sort.Sort(keysByValue{keys, values, less})
+	return keys, nil
+}
+
+// sharedKind returns the kind all the values have, or reflect.Invalid.
+func sharedKind(values []reflect.Value) reflect.Kind {
+	if len(values) == 0 {
+		return reflect.Invalid
+	}
+	kind := values[0].Kind()
+	for _, v := range values[1:] {
+		if v.Kind() != kind {
+			return reflect.Invalid
+		}
+	}
+	return kind
+}
+
+type keysByValue struct {
+	keys   []reflect.Value
+	values []reflect.Value
+	less   func(a, b reflect.Value) bool
+}
+
+func (s keysByValue) Len() int { return len(s.keys) }
+
+func (s keysByValue) Less(i, j int) bool { return s.less(s.values[i], s.values[j]) }
+
+func (s keysByValue) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.values[i], s.values[j] = s.values[j], s.values[i]
+}
+
+type keysByEncoding struct {
+	keys    []reflect.Value
+	encoded [][]byte
+}
+
+func (s keysByEncoding) Len() int { return len(s.keys) }
+
+func (s keysByEncoding) Less(i, j int) bool { return bytes.Compare(s.encoded[i], s.encoded[j]) < 0 }
+
+func (s keysByEncoding) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.encoded[i], s.encoded[j] = s.encoded[j], s.encoded[i]
+}
@@ -0,0 +1,67 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unorderedSet encodes its map in iteration order.
+type unorderedSet map[uint32]bool
+
+func (s unorderedSet) MarshalWithEncoder(e *Encoder) error {
+	for k := range s {
+		if err := e.WriteUint32(k, LE); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestDeterminismCheck(t *testing.T) {
+	type value struct {
+		Names  map[string]uint8
+		Owners map[[2]byte]int64
+		Levels map[int16]string
+	}
+	v := value{
+		Names:  map[string]uint8{},
+		Owners: map[[2]byte]int64{},
+		Levels: map[int16]string{},
+	}
+	for i := 0; i < 64; i++ {
+		v.Names[string(rune('a'+i))] = uint8(i)
+		v.Owners[[2]byte{byte(i * 7), byte(i)}] = int64(i)
+		v.Levels[int16(i-32)] = "x"
+	}
+	for _, enc := range []Encoding{EncodingBin, EncodingCompactU16} {
+		require.NoError(t, DeterminismCheckWithEncoding(v, enc, 50), enc)
+	}
+
+	data, err := MarshalBin(map[uint16]bool{3: true, 1: false, 2: true})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{3, 1, 0, 0, 2, 0, 1, 3, 0, 1}, data)
+
+	set := unorderedSet{}
+	for i := uint32(0); i < 64; i++ {
+		set[i] = true
+	}
+	err = DeterminismCheck(set, 50)
+	assert.True(t, errors.Is(err, ErrNonDeterministic))
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"reflect"
+)
+
+// EmptyMode controls how optional (`optional`, or `coption` with Borsh)
+// slices, maps and strings that are empty are encoded, for dialects that
+// expect either form; decoding accepts both.
+type EmptyMode int
+
+const (
+	// EmptyDefault encodes nil slices and maps, and empty strings, as absent,
+	// and the other empty slices and maps as present with a zero length.
+	EmptyDefault EmptyMode = iota
+	// EmptyOmit encodes all the empty collections as absent (tag: `empty=omit`).
+	EmptyOmit
+	// EmptyZero encodes all the collections, including nil ones, as present,
+	// with a zero length when empty (tag: `empty=zero`).
+	EmptyZero
+)
+
+// WithEmptyMode sets how the encoder writes empty optional collections,
+// for the fields that have no `empty` tag.
+func (e *Encoder) WithEmptyMode(mode EmptyMode) *Encoder {
+	e.emptyMode = mode
+	return e
+}
+
+// isAbsent reports whether an optional value is encoded as absent.
+func (e *Encoder) isAbsent(rv reflect.Value, opt *option) bool {
+	mode := opt.Empty
+	if mode == EmptyDefault {
+		mode = e.emptyMode
+	}
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if pointerMode(opt, e.pointerMode) != PointerPerLevel {
+			return hasNilPointer(rv)
+		}
+	case reflect.Slice, reflect.Map, reflect.String:
+		switch mode {
+		case EmptyOmit:
+			return rv.Len() == 0
+		case EmptyZero:
+			return false
+		}
+	}
+	return rv.IsZero()
+}
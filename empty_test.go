@@ -0,0 +1,87 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type emptyDefault struct {
+	Items []uint8 `bin:"optional"`
+}
+
+type emptyOmit struct {
+	Items []uint8 `bin:"optional empty=omit"`
+}
+
+type emptyZero struct {
+	Items []uint8 `bin:"optional empty=zero"`
+}
+
+func TestEmptyMode(t *testing.T) {
+	absent := []byte{0x00, 0x00, 0x00, 0x00}
+	present := []byte{0x01, 0x00, 0x00, 0x00, 0x00}
+
+	tests := []struct {
+		name   string
+		value  interface{}
+		mode   EmptyMode
+		expect []byte
+	}{
+		{"default nil", emptyDefault{}, EmptyDefault, absent},
+		{"default empty", emptyDefault{Items: []uint8{}}, EmptyDefault, present},
+		{"omit nil", emptyOmit{}, EmptyDefault, absent},
+		{"omit empty", emptyOmit{Items: []uint8{}}, EmptyDefault, absent},
+		{"zero nil", emptyZero{}, EmptyDefault, present},
+		{"zero empty", emptyZero{Items: []uint8{}}, EmptyDefault, present},
+		{"encoder omit", emptyDefault{Items: []uint8{}}, EmptyOmit, absent},
+		{"encoder zero", emptyDefault{}, EmptyZero, present},
+		{"tag overrides encoder", emptyZero{}, EmptyOmit, present},
+		{"not empty", emptyOmit{Items: []uint8{7}}, EmptyDefault, []byte{0x01, 0x00, 0x00, 0x00, 0x01, 0x07}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewBinEncoder(buf).WithEmptyMode(test.mode).Encode(test.value))
+			assert.Equal(t, test.expect, buf.Bytes())
+		})
+	}
+
+	// Both forms decode.
+	var got emptyOmit
+	require.NoError(t, NewBinDecoder(present).Decode(&got))
+	assert.Equal(t, 0, len(got.Items))
+	require.NoError(t, NewBinDecoder(absent).Decode(&got))
+	assert.Nil(t, got.Items)
+
+	type notOptional struct {
+		Items []uint8 `bin:"empty=omit"`
+	}
+	assert.Error(t, Precompile(notOptional{}))
+}
+
+func TestEmptyMode_Borsh(t *testing.T) {
+	type value struct {
+		Name  string            `bin:"optional empty=zero"`
+		Attrs map[string]string `bin:"optional empty=omit"`
+	}
+	data, err := BorshCodec.Marshal(value{Attrs: map[string]string{}})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00}, data)
+}
@@ -0,0 +1,153 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// ErrEncodePoolClosed is returned when submitting a value to a closed EncodePool.
+var ErrEncodePoolClosed = errors.New("encode pool: closed")
+
+// An EncodePool encodes values on a fixed number of worker goroutines, e.g. to
+// keep serialization off latency-critical goroutines. Submitted values wait
+// in a bounded queue; when it's full, Submit blocks (and TrySubmit fails),
+// so that producers can't get ahead of the workers without bound.
+//
+//	pool := bin.NewEncodePool(bin.EncodingBorsh, 0, 1024)
+//	defer pool.Close()
+//	future, err := pool.Submit(ctx, tx)
+//	...
+//	data, err := future.Wait()
+//
+// A submitted value must not be modified until its future is done.
+type EncodePool struct {
+	encoding Encoding
+	queue    chan encodeJob
+	workers  sync.WaitGroup
+
+	// mu guards closed, and the sends on queue against its closing.
+	mu     sync.RWMutex
+	closed bool
+}
+
+type encodeJob struct {
+	v      interface{}
+	future *EncodeFuture
+}
+
+// An EncodeFuture is the pending result of a value submitted to an EncodePool.
+type EncodeFuture struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// Done returns a channel that is closed once the value is encoded.
+func (f *EncodeFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for the value to be encoded, and returns its encoding.
+func (f *EncodeFuture) Wait() ([]byte, error) {
+	<-f.done
+	return f.data, f.err
+}
+
+// NewEncodePool returns a pool of `workers` goroutines encoding values with the
+// provided encoding (one per CPU if workers is 0 or less), with room for
+// `queueSize` values waiting for a worker.
+func NewEncodePool(enc Encoding, workers, queueSize int) *EncodePool {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	p := &EncodePool{
+		encoding: enc,
+		queue:    make(chan encodeJob, queueSize),
+	}
+	p.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *EncodePool) work() {
+	defer p.workers.Done()
+	for job := range p.queue {
+		buf := new(bytes.Buffer)
+		job.future.err = NewEncoderWithEncoding(buf, p.encoding).Encode(job.v)
+		if job.future.err == nil {
+			job.future.data = buf.Bytes()
+		}
+		close(job.future.done)
+	}
+}
+
+// Submit queues a value to be encoded, waiting for room in the queue
+// until the context is done.
+func (p *EncodePool) Submit(ctx context.Context, v interface{}) (*EncodeFuture, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return nil, ErrEncodePoolClosed
+	}
+	job := newEncodeJob(v)
+	select {
+	case p.queue <- job:
+		return job.future, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// TrySubmit queues a value to be encoded if there's room in the queue,
+// and returns false if there isn't or the pool is closed.
+func (p *EncodePool) TrySubmit(v interface{}) (*EncodeFuture, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return nil, false
+	}
+	job := newEncodeJob(v)
+	select {
+	case p.queue <- job:
+		return job.future, true
+	default:
+		return nil, false
+	}
+}
+
+func newEncodeJob(v interface{}) encodeJob {
+	return encodeJob{v: v, future: &EncodeFuture{done: make(chan struct{})}}
+}
+
+// Close stops accepting values, and waits for the queued ones to be encoded.
+func (p *EncodePool) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	p.workers.Wait()
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingValue blocks its encoding until release is closed.
+type blockingValue struct {
+	release chan struct{}
+}
+
+func (v blockingValue) MarshalWithEncoder(enc *Encoder) error {
+	<-v.release
+	return enc.WriteUint8(1)
+}
+
+func TestEncodePool(t *testing.T) {
+	type S struct {
+		A uint32
+		B string
+	}
+	pool := NewEncodePool(EncodingBorsh, 4, 8)
+
+	var futures []*EncodeFuture
+	for i := 0; i < 100; i++ {
+		future, err := pool.Submit(context.Background(), S{A: uint32(i), B: "x"})
+		require.NoError(t, err)
+		futures = append(futures, future)
+	}
+	for i, future := range futures {
+		data, err := future.Wait()
+		require.NoError(t, err)
+		expected, err := MarshalBorsh(S{A: uint32(i), B: "x"})
+		require.NoError(t, err)
+		assert.Equal(t, expected, data)
+	}
+
+	future, err := pool.Submit(context.Background(), make(chan int))
+	require.NoError(t, err)
+	_, err = future.Wait()
+	assert.Error(t, err)
+
+	pool.Close()
+	pool.Close()
+	_, err = pool.Submit(context.Background(), S{})
+	assert.Equal(t, ErrEncodePoolClosed, err)
+	_, ok := pool.TrySubmit(S{})
+	assert.False(t, ok)
+}
+
+func TestEncodePool_BackPressure(t *testing.T) {
+	pool := NewEncodePool(EncodingBin, 1, 1)
+	release := make(chan struct{})
+
+	// One value is held by the worker, and one waits in the queue.
+	first, err := pool.Submit(context.Background(), blockingValue{release})
+	require.NoError(t, err)
+	var queued *EncodeFuture
+	require.Eventually(t, func() bool {
+		var ok bool
+		queued, ok = pool.TrySubmit(blockingValue{release})
+		return ok
+	}, time.Second, time.Millisecond)
+
+	_, ok := pool.TrySubmit(blockingValue{release})
+	assert.False(t, ok)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = pool.Submit(ctx, blockingValue{release})
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	select {
+	case <-first.Done():
+		t.Fatal("encoded before being released")
+	default:
+	}
+
+	// Close waits for the queued values.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pool.Close()
+	}()
+	close(release)
+	wg.Wait()
+
+	for _, future := range []*EncodeFuture{first, queued} {
+		data, err := future.Wait()
+		require.NoError(t, err)
+		assert.Equal(t, []byte{1}, data)
+	}
+}
@@ -18,14 +18,13 @@
 package bin
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"math"
 	"reflect"
-
-	"go.uber.org/zap"
 )
 
 type Encoder struct {
@@ -35,8 +34,60 @@ type Encoder struct {
 	encoding        Encoding
 
 	output io.Writer
+	// appendTo is the output of encoders created with NewAppendEncoder.
+	appendTo *appendWriter
+
+	strictTags bool
+	maxSize    int
+	canonical  bool
+	finite     bool
+
+	stringHeap bool
+	heap       *bytes.Buffer
+
+	// The output is held in pending while there are checkpoints.
+	pending     *bytes.Buffer
+	direct      io.Writer
+	checkpoints int
+	session     int
+
+	metrics  EncodeMetricsFunc
+	inEncode bool
+
+	recorder  *Recorder
+	recording bool
+
+	// inheritedOrder is the byte order of the values nested
+	// in a `big,recursive` or `little,recursive` field.
+	inheritedOrder binary.ByteOrder
+	// prefixOrder is the byte order of fixed-width length prefixes, if set.
+	prefixOrder binary.ByteOrder
+
+	emptyMode   EmptyMode
+	pointerMode PointerMode
+
+	textMarshalers      bool
+	stdBinaryMarshalers bool
+
+	values map[interface{}]interface{}
+
+	keys       KeyProvider
+	compressor Compressor
+
+	// trace enables tracing for this encoder (see WithTracing),
+	// and traceLog, if set, replaces the package logger (see WithTraceLogger).
+	trace    bool
+	traceLog *logger
 }
 
+// ErrMaxEncodedSizeExceeded is returned when an encoder configured with
+// WithMaxEncodedSize would write more than the allowed number of bytes.
+var ErrMaxEncodedSizeExceeded = errors.New("max encoded size exceeded")
+
+// ErrNonFiniteFloat is returned when an encoder configured with
+// RejectNonFiniteFloats is asked to write a NaN or infinite float.
+var ErrNonFiniteFloat = errors.New("non-finite float")
+
 func (enc *Encoder) IsBorsh() bool {
 	return enc.encoding.IsBorsh()
 }
@@ -60,6 +111,55 @@ func NewEncoderWithEncoding(writer io.Writer, enc Encoding) *Encoder {
 	}
 }
 
+// WithStrictTags makes the encoder fail on struct fields that have
+// unknown or malformed `bin` tag tokens, instead of silently ignoring them.
+// The check is done on the whole struct type before any of its fields is encoded.
+func (e *Encoder) WithStrictTags() *Encoder {
+	e.strictTags = true
+	return e
+}
+
+// Tee makes the encoder also write every encoded byte to w, in the same
+// order and at the same time as they are written to the encoder's output
+// (e.g. to feed a hasher or a journal without a second encoding pass).
+// Tee can be called multiple times to add several destinations;
+// a write error on any destination fails the encoding. Called while
+// there are checkpoints, w gets the output held since the first of them
+// when it's written to the encoder's output.
+func (e *Encoder) Tee(w io.Writer) *Encoder {
+	if e.checkpoints > 0 {
+		e.direct = io.MultiWriter(e.direct, w)
+		return e
+	}
+	e.output = io.MultiWriter(e.output, w)
+	return e
+}
+
+// WithMaxEncodedSize caps the number of bytes the encoder can write.
+// The write that would go past n bytes fails before anything is written,
+// with an error wrapping ErrMaxEncodedSizeExceeded; use FieldPath on it
+// to know which field was being encoded.
+// A zero or negative n disables the cap.
+func (e *Encoder) WithMaxEncodedSize(n int) *Encoder {
+	e.maxSize = n
+	return e
+}
+
+// RejectNonFiniteFloats makes the encoder fail on NaN and infinite floats
+// (including the parts of complex numbers) with an error wrapping ErrNonFiniteFloat;
+// use FieldPath on it to know which field holds the value.
+func (e *Encoder) RejectNonFiniteFloats() *Encoder {
+	e.finite = true
+	return e
+}
+
+func (e *Encoder) checkMaxSize(n int) error {
+	if e.maxSize > 0 && e.count+n > e.maxSize {
+		return fmt.Errorf("%w: writing %d bytes at offset %d would exceed %d bytes", ErrMaxEncodedSizeExceeded, n, e.count, e.maxSize)
+	}
+	return nil
+}
+
 func NewBinEncoder(writer io.Writer) *Encoder {
 	return NewEncoderWithEncoding(writer, EncodingBin)
 }
@@ -73,6 +173,15 @@ func NewCompactU16Encoder(writer io.Writer) *Encoder {
 }
 
 func (e *Encoder) Encode(v interface{}) (err error) {
+	if e.recorder != nil && !e.recording {
+		return e.encodeRecorded(v)
+	}
+	if e.metrics != nil && !e.inEncode {
+		return e.encodeWithMetrics(v)
+	}
+	if e.stringHeap && e.heap == nil {
+		return e.encodeWithHeap(func() error { return e.Encode(v) })
+	}
 	switch e.encoding {
 	case EncodingBin:
 		return e.encodeBin(reflect.ValueOf(v), nil)
@@ -86,9 +195,12 @@ func (e *Encoder) Encode(v interface{}) (err error) {
 }
 
 func (e *Encoder) toWriter(bytes []byte) (err error) {
+	if err := e.checkMaxSize(len(bytes)); err != nil {
+		return err
+	}
 	e.count += len(bytes)
-	if traceEnabled {
-		zlog.Debug("	> encode: appending", zap.Stringer("hex", HexBytes(bytes)), zap.Int("pos", e.count))
+	if e.tracing() {
+		e.tlog().Debug("	> encode: appending", logStringer("hex", HexBytes(bytes)), logInt("pos", e.count))
 	}
 	_, err = e.output.Write(bytes)
 	return
@@ -100,10 +212,13 @@ func (e *Encoder) Written() int {
 }
 
 func (e *Encoder) WriteBytes(b []byte, writeLength bool) error {
-	if traceEnabled {
-		zlog.Debug("encode: write byte array", zap.Int("len", len(b)))
+	if e.tracing() {
+		e.tlog().Debug("encode: write byte array", logInt("len", len(b)))
 	}
 	if writeLength {
+		if e.heap != nil {
+			return e.writeHeapRef(b)
+		}
 		if err := e.WriteLength(len(b)); err != nil {
 			return err
 		}
@@ -115,24 +230,30 @@ func (e *Encoder) WriteBytes(b []byte, writeLength bool) error {
 }
 
 func (e *Encoder) Write(b []byte) (n int, err error) {
+	if err := e.checkMaxSize(len(b)); err != nil {
+		return 0, err
+	}
 	e.count += len(b)
-	if traceEnabled {
-		zlog.Debug("	> encode: appending", zap.Stringer("hex", HexBytes(b)), zap.Int("pos", e.count))
+	if e.tracing() {
+		e.tlog().Debug("	> encode: appending", logStringer("hex", HexBytes(b)), logInt("pos", e.count))
 	}
 	return e.output.Write(b)
 }
 
 func (e *Encoder) WriteLength(length int) error {
-	if traceEnabled {
-		zlog.Debug("encode: write length", zap.Int("len", length))
+	if e.tracing() {
+		e.tlog().Debug("encode: write length", logInt("len", length))
 	}
 	switch e.encoding {
 	case EncodingBin:
-		if err := e.WriteUVarInt(length); err != nil {
+		if length < 0 || int64(length) > math.MaxUint32 {
+			return fmt.Errorf("length %d: %w", length, ErrVarIntOverflow)
+		}
+		if err := e.WriteUvarint32(uint32(length)); err != nil {
 			return err
 		}
 	case EncodingBorsh:
-		if err := e.WriteUint32(uint32(length), LE); err != nil {
+		if err := e.WriteUint32(uint32(length), e.lengthOrder()); err != nil {
 			return err
 		}
 	case EncodingCompactU16:
@@ -148,42 +269,70 @@ func (e *Encoder) WriteLength(length int) error {
 }
 
 func (e *Encoder) WriteUVarInt(v int) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write uvarint", zap.Int("val", v))
+	return e.WriteUvarint64(uint64(v))
+}
+
+func (e *Encoder) WriteVarInt(v int) (err error) {
+	return e.WriteVarint64(int64(v))
+}
+
+func (e *Encoder) WriteUvarint64(v uint64) (err error) {
+	if e.tracing() {
+		e.tlog().Debug("encode: write uvarint", logUint64("val", v))
 	}
 
-	buf := make([]byte, 8)
-	l := binary.PutUvarint(buf, uint64(v))
+	buf := make([]byte, binary.MaxVarintLen64)
+	l := binary.PutUvarint(buf, v)
 	return e.toWriter(buf[:l])
 }
 
-func (e *Encoder) WriteVarInt(v int) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write varint", zap.Int("val", v))
+func (e *Encoder) WriteVarint64(v int64) (err error) {
+	if e.tracing() {
+		e.tlog().Debug("encode: write varint", logInt64("val", v))
 	}
 
-	buf := make([]byte, 8)
-	l := binary.PutVarint(buf, int64(v))
+	buf := make([]byte, binary.MaxVarintLen64)
+	l := binary.PutVarint(buf, v)
 	return e.toWriter(buf[:l])
 }
 
+// WriteUvarint32 writes a uvarint that can be read back with ReadUvarint32.
+func (e *Encoder) WriteUvarint32(v uint32) (err error) {
+	return e.WriteUvarint64(uint64(v))
+}
+
+// WriteUvarint16 writes a uvarint that can be read back with ReadUvarint16.
+func (e *Encoder) WriteUvarint16(v uint16) (err error) {
+	return e.WriteUvarint64(uint64(v))
+}
+
+// WriteVarint32 writes a varint that can be read back with ReadVarint32.
+func (e *Encoder) WriteVarint32(v int32) (err error) {
+	return e.WriteVarint64(int64(v))
+}
+
+// WriteVarint16 writes a varint that can be read back with ReadVarint16.
+func (e *Encoder) WriteVarint16(v int16) (err error) {
+	return e.WriteVarint64(int64(v))
+}
+
 func (e *Encoder) WriteByte(b byte) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write byte", zap.Uint8("val", b))
+	if e.tracing() {
+		e.tlog().Debug("encode: write byte", logUint8("val", b))
 	}
 	return e.toWriter([]byte{b})
 }
 
 func (e *Encoder) WriteOption(b bool) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write option", zap.Bool("val", b))
+	if e.tracing() {
+		e.tlog().Debug("encode: write option", logBool("val", b))
 	}
 	return e.WriteBool(b)
 }
 
 func (e *Encoder) WriteCOption(b bool) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write c-option", zap.Bool("val", b))
+	if e.tracing() {
+		e.tlog().Debug("encode: write c-option", logBool("val", b))
 	}
 	var num uint32
 	if b {
@@ -193,8 +342,8 @@ func (e *Encoder) WriteCOption(b bool) (err error) {
 }
 
 func (e *Encoder) WriteBool(b bool) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write bool", zap.Bool("val", b))
+	if e.tracing() {
+		e.tlog().Debug("encode: write bool", logBool("val", b))
 	}
 	var out byte
 	if b {
@@ -203,6 +352,24 @@ func (e *Encoder) WriteBool(b bool) (err error) {
 	return e.WriteByte(out)
 }
 
+// WriteBoolWidth writes a bool as a 1, 2 or 4-byte integer holding 0 or 1,
+// as used by C and FFI formats with wider bools.
+func (e *Encoder) WriteBoolWidth(b bool, width int, order binary.ByteOrder) (err error) {
+	var out uint32
+	if b {
+		out = 1
+	}
+	switch width {
+	case TypeSize.Bool:
+		return e.WriteBool(b)
+	case TypeSize.Uint16:
+		return e.WriteUint16(uint16(out), order)
+	case TypeSize.Uint32:
+		return e.WriteUint32(out, order)
+	}
+	return fmt.Errorf("invalid bool width %d", width)
+}
+
 func (e *Encoder) WriteUint8(i uint8) (err error) {
 	return e.WriteByte(i)
 }
@@ -212,8 +379,8 @@ func (e *Encoder) WriteInt8(i int8) (err error) {
 }
 
 func (e *Encoder) WriteUint16(i uint16, order binary.ByteOrder) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write uint16", zap.Uint16("val", i))
+	if e.tracing() {
+		e.tlog().Debug("encode: write uint16", logUint16("val", i))
 	}
 	buf := make([]byte, TypeSize.Uint16)
 	order.PutUint16(buf, i)
@@ -221,15 +388,15 @@ func (e *Encoder) WriteUint16(i uint16, order binary.ByteOrder) (err error) {
 }
 
 func (e *Encoder) WriteInt16(i int16, order binary.ByteOrder) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write int16", zap.Int16("val", i))
+	if e.tracing() {
+		e.tlog().Debug("encode: write int16", logInt16("val", i))
 	}
 	return e.WriteUint16(uint16(i), order)
 }
 
 func (e *Encoder) WriteUint32(i uint32, order binary.ByteOrder) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write uint32", zap.Uint32("val", i))
+	if e.tracing() {
+		e.tlog().Debug("encode: write uint32", logUint32("val", i))
 	}
 	buf := make([]byte, TypeSize.Uint32)
 	order.PutUint32(buf, i)
@@ -237,15 +404,15 @@ func (e *Encoder) WriteUint32(i uint32, order binary.ByteOrder) (err error) {
 }
 
 func (e *Encoder) WriteInt32(i int32, order binary.ByteOrder) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write int32", zap.Int32("val", i))
+	if e.tracing() {
+		e.tlog().Debug("encode: write int32", logInt32("val", i))
 	}
 	return e.WriteUint32(uint32(i), order)
 }
 
 func (e *Encoder) WriteUint64(i uint64, order binary.ByteOrder) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write uint64", zap.Uint64("val", i))
+	if e.tracing() {
+		e.tlog().Debug("encode: write uint64", logUint64("val", i))
 	}
 	buf := make([]byte, TypeSize.Uint64)
 	order.PutUint64(buf, i)
@@ -253,15 +420,15 @@ func (e *Encoder) WriteUint64(i uint64, order binary.ByteOrder) (err error) {
 }
 
 func (e *Encoder) WriteInt64(i int64, order binary.ByteOrder) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write int64", zap.Int64("val", i))
+	if e.tracing() {
+		e.tlog().Debug("encode: write int64", logInt64("val", i))
 	}
 	return e.WriteUint64(uint64(i), order)
 }
 
 func (e *Encoder) WriteUint128(i Uint128, order binary.ByteOrder) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write uint128", zap.Stringer("hex", i), zap.Uint64("lo", i.Lo), zap.Uint64("hi", i.Hi))
+	if e.tracing() {
+		e.tlog().Debug("encode: write uint128", logStringer("hex", i), logUint64("lo", i.Lo), logUint64("hi", i.Hi))
 	}
 	buf := make([]byte, TypeSize.Uint128)
 	switch order {
@@ -278,8 +445,8 @@ func (e *Encoder) WriteUint128(i Uint128, order binary.ByteOrder) (err error) {
 }
 
 func (e *Encoder) WriteInt128(i Int128, order binary.ByteOrder) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write int128", zap.Stringer("hex", i), zap.Uint64("lo", i.Lo), zap.Uint64("hi", i.Hi))
+	if e.tracing() {
+		e.tlog().Debug("encode: write int128", logStringer("hex", i), logUint64("lo", i.Lo), logUint64("hi", i.Hi))
 	}
 	buf := make([]byte, TypeSize.Uint128)
 	switch order {
@@ -295,9 +462,57 @@ func (e *Encoder) WriteInt128(i Int128, order binary.ByteOrder) (err error) {
 	return e.toWriter(buf)
 }
 
+func (e *Encoder) WriteUint256(i Uint256, order binary.ByteOrder) (err error) {
+	if e.tracing() {
+		e.tlog().Debug("encode: write uint256", logStringer("hex", i))
+	}
+	buf := make([]byte, TypeSize.Uint256)
+	switch order {
+	case binary.LittleEndian:
+		for k, w := range i.Words {
+			order.PutUint64(buf[k*8:], w)
+		}
+	case binary.BigEndian:
+		for k, w := range i.Words {
+			order.PutUint64(buf[(3-k)*8:], w)
+		}
+	default:
+		return fmt.Errorf("invalid byte order: %v", order)
+	}
+	return e.toWriter(buf)
+}
+
+func (e *Encoder) WriteInt256(i Int256, order binary.ByteOrder) (err error) {
+	return e.WriteUint256(Uint256(i), order)
+}
+
+func (e *Encoder) WriteFloat16(f Float16, order binary.ByteOrder) (err error) {
+	if e.tracing() {
+		e.tlog().Debug("encode: write float16", logStringer("val", f))
+	}
+
+	if e.IsBorsh() {
+		if f.IsNaN() {
+			return errors.New("NaN float value")
+		}
+	}
+
+	if e.finite && (f.IsNaN() || f.IsInf()) {
+		return fmt.Errorf("%w: %v", ErrNonFiniteFloat, f)
+	}
+
+	if e.canonical && f.IsNaN() {
+		f = CanonicalNaN16
+	}
+	buf := make([]byte, TypeSize.Float16)
+	order.PutUint16(buf, uint16(f))
+
+	return e.toWriter(buf)
+}
+
 func (e *Encoder) WriteFloat32(f float32, order binary.ByteOrder) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write float32", zap.Float32("val", f))
+	if e.tracing() {
+		e.tlog().Debug("encode: write float32", logFloat32("val", f))
 	}
 
 	if e.IsBorsh() {
@@ -306,7 +521,14 @@ func (e *Encoder) WriteFloat32(f float32, order binary.ByteOrder) (err error) {
 		}
 	}
 
+	if e.finite && (math.IsNaN(float64(f)) || math.IsInf(float64(f), 0)) {
+		return fmt.Errorf("%w: %v", ErrNonFiniteFloat, f)
+	}
+
 	i := math.Float32bits(f)
+	if e.canonical && math.IsNaN(float64(f)) {
+		i = CanonicalNaN32
+	}
 	buf := make([]byte, TypeSize.Uint32)
 	order.PutUint32(buf, i)
 
@@ -314,8 +536,8 @@ func (e *Encoder) WriteFloat32(f float32, order binary.ByteOrder) (err error) {
 }
 
 func (e *Encoder) WriteFloat64(f float64, order binary.ByteOrder) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write float64", zap.Float64("val", f))
+	if e.tracing() {
+		e.tlog().Debug("encode: write float64", logFloat64("val", f))
 	}
 
 	if e.IsBorsh() {
@@ -323,34 +545,67 @@ func (e *Encoder) WriteFloat64(f float64, order binary.ByteOrder) (err error) {
 			return errors.New("NaN float value")
 		}
 	}
+	if e.finite && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		return fmt.Errorf("%w: %v", ErrNonFiniteFloat, f)
+	}
 	i := math.Float64bits(f)
+	if e.canonical && math.IsNaN(f) {
+		i = CanonicalNaN64
+	}
 	buf := make([]byte, TypeSize.Uint64)
 	order.PutUint64(buf, i)
 
 	return e.toWriter(buf)
 }
 
+// WriteComplex64 writes a complex64 as two consecutive float32 values:
+// the real part followed by the imaginary part.
+func (e *Encoder) WriteComplex64(c complex64, order binary.ByteOrder) (err error) {
+	if e.tracing() {
+		e.tlog().Debug("encode: write complex64", logFloat32("real", real(c)), logFloat32("imag", imag(c)))
+	}
+	if err = e.WriteFloat32(real(c), order); err != nil {
+		return err
+	}
+	return e.WriteFloat32(imag(c), order)
+}
+
+// WriteComplex128 writes a complex128 as two consecutive float64 values:
+// the real part followed by the imaginary part.
+func (e *Encoder) WriteComplex128(c complex128, order binary.ByteOrder) (err error) {
+	if e.tracing() {
+		e.tlog().Debug("encode: write complex128", logFloat64("real", real(c)), logFloat64("imag", imag(c)))
+	}
+	if err = e.WriteFloat64(real(c), order); err != nil {
+		return err
+	}
+	return e.WriteFloat64(imag(c), order)
+}
+
 func (e *Encoder) WriteString(s string) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write string", zap.String("val", s))
+	if e.tracing() {
+		e.tlog().Debug("encode: write string", logString("val", s))
 	}
 	return e.WriteBytes([]byte(s), true)
 }
 
 func (e *Encoder) WriteRustString(s string) (err error) {
-	err = e.WriteUint64(uint64(len(s)), binary.LittleEndian)
+	if e.heap != nil {
+		return e.writeHeapRef([]byte(s))
+	}
+	err = e.WriteUint64(uint64(len(s)), e.lengthOrder())
 	if err != nil {
 		return err
 	}
-	if traceEnabled {
-		zlog.Debug("encode: write Rust string", zap.String("val", s))
+	if e.tracing() {
+		e.tlog().Debug("encode: write Rust string", logString("val", s))
 	}
 	return e.WriteBytes([]byte(s), false)
 }
 
 func (e *Encoder) WriteCompactU16(ln int) (err error) {
-	if traceEnabled {
-		zlog.Debug("encode: write compact-u16", zap.Int("val", ln))
+	if e.tracing() {
+		e.tlog().Debug("encode: write compact-u16", logInt("val", ln))
 	}
 	buf := make([]byte, 0)
 	EncodeCompactU16Length(&buf, ln)
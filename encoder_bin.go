@@ -21,27 +21,25 @@ import (
 	"encoding/binary"
 	"fmt"
 	"reflect"
-
-	"go.uber.org/zap"
 )
 
 func (e *Encoder) encodeBin(rv reflect.Value, opt *option) (err error) {
 	if opt == nil {
-		opt = newDefaultOption()
+		opt = e.defaultOption()
 	}
 	e.currentFieldOpt = opt
 
-	if traceEnabled {
-		zlog.Debug("encode: type",
-			zap.Stringer("value_kind", rv.Kind()),
-			zap.Reflect("options", opt),
+	if e.tracing() {
+		e.tlog().Debug("encode: type",
+			logStringer("value_kind", rv.Kind()),
+			logReflect("options", opt),
 		)
 	}
 
 	if opt.is_Optional() {
-		if rv.IsZero() {
-			if traceEnabled {
-				zlog.Debug("encode: skipping optional value with", zap.Stringer("type", rv.Kind()))
+		if e.isAbsent(rv, opt) {
+			if e.tracing() {
+				e.tlog().Debug("encode: skipping optional value with", logStringer("type", rv.Kind()))
 			}
 			return e.WriteUint32(0, binary.LittleEndian)
 		}
@@ -49,6 +47,9 @@ func (e *Encoder) encodeBin(rv reflect.Value, opt *option) (err error) {
 		if err != nil {
 			return err
 		}
+		if e.nextPointerLevel(rv, opt) {
+			return e.encodeBin(rv.Elem(), opt)
+		}
 		// The optionality has been used; stop its propagation:
 		opt.set_Optional(false)
 	}
@@ -57,13 +58,81 @@ func (e *Encoder) encodeBin(rv reflect.Value, opt *option) (err error) {
 		return nil
 	}
 
-	if marshaler, ok := rv.Interface().(BinaryMarshaler); ok {
-		if traceEnabled {
-			zlog.Debug("encode: using MarshalerBinary method to encode type")
+	if handled, err := e.encodeUnion(rv, opt); handled {
+		return err
+	}
+
+	if marshaler, ok := binaryMarshaler(rv); ok {
+		if e.tracing() {
+			e.tlog().Debug("encode: using MarshalerBinary method to encode type")
 		}
 		return marshaler.MarshalWithEncoder(e)
 	}
 
+	if handled, err := e.encodeRunes(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeSwapped(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeVLQ(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeVarints(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeDelta(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeRLE(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeBigInt(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeWidthInt(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeNetAddr(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeDuration(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeTime(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeStdBinary(rv); handled {
+		return err
+	}
+	if handled, err := e.encodeText(rv); handled {
+		return err
+	}
+	if handled, err := e.encodeEncrypted(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeCompressed(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeSizeOfTarget(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeLenPrefixed(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeFixedString(rv, opt); handled {
+		return err
+	}
+	if err := checkMaxLen(rv, opt); err != nil {
+		return err
+	}
+	if handled, err := e.encodeCString(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeHeapBytes(rv); handled {
+		return err
+	}
+
 	switch rv.Kind() {
 	case reflect.String:
 		return e.WriteRustString(rv.String())
@@ -84,11 +153,15 @@ func (e *Encoder) encodeBin(rv reflect.Value, opt *option) (err error) {
 	case reflect.Int64:
 		return e.WriteInt64(rv.Int(), opt.Order)
 	case reflect.Float32:
-		return e.WriteFloat32(float32(rv.Float()), opt.Order)
+		return e.WriteFloat32(float32Value(rv), opt.Order)
 	case reflect.Float64:
 		return e.WriteFloat64(rv.Float(), opt.Order)
+	case reflect.Complex64:
+		return e.WriteComplex64(complex64Value(rv), opt.Order)
+	case reflect.Complex128:
+		return e.WriteComplex128(rv.Complex(), opt.Order)
 	case reflect.Bool:
-		return e.WriteBool(rv.Bool())
+		return e.WriteBoolWidth(rv.Bool(), boolWidth(opt), opt.Order)
 	case reflect.Ptr:
 		return e.encodeBin(rv.Elem(), opt)
 	case reflect.Interface:
@@ -101,22 +174,22 @@ func (e *Encoder) encodeBin(rv reflect.Value, opt *option) (err error) {
 	switch rt.Kind() {
 	case reflect.Array:
 		l := rt.Len()
-		if traceEnabled {
-			defer func(prev *zap.Logger) { zlog = prev }(zlog)
-			zlog = zlog.Named("array")
-			zlog.Debug("encode: array", zap.Int("length", l), zap.Stringer("type", rv.Kind()))
+		if e.tracing() {
+			defer func(prev *logger) { e.traceLog = prev }(e.traceLog)
+			e.traceLog = e.tlog().Named("array")
+			e.tlog().Debug("encode: array", logInt("length", l), logStringer("type", rv.Kind()))
 		}
 
 		switch k := rv.Type().Elem().Kind(); k {
 		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 			// if it's a [n]byte, accumulate and write in one command:
-			if err := reflect_writeArrayOfUint_(e, l, k, rv, LE); err != nil {
+			if err := reflect_writeArrayOfUint_(e, l, k, rv, e.elemOrder()); err != nil {
 				return err
 			}
 		default:
 			for i := 0; i < l; i++ {
 				if err = e.encodeBin(rv.Index(i), nil); err != nil {
-					return
+					return newElementError("encoding", i, err)
 				}
 			}
 		}
@@ -125,8 +198,8 @@ func (e *Encoder) encodeBin(rv reflect.Value, opt *option) (err error) {
 		var l int
 		if opt.hasSizeOfSlice() {
 			l = opt.getSizeOfSlice()
-			if traceEnabled {
-				zlog.Debug("encode: slice with sizeof set", zap.Int("size_of", l))
+			if e.tracing() {
+				e.tlog().Debug("encode: slice with sizeof set", logInt("size_of", l))
 			}
 		} else {
 			l = rv.Len()
@@ -134,10 +207,10 @@ func (e *Encoder) encodeBin(rv reflect.Value, opt *option) (err error) {
 				return
 			}
 		}
-		if traceEnabled {
-			defer func(prev *zap.Logger) { zlog = prev }(zlog)
-			zlog = zlog.Named("slice")
-			zlog.Debug("encode: slice", zap.Int("length", l), zap.Stringer("type", rv.Kind()))
+		if e.tracing() {
+			defer func(prev *logger) { e.traceLog = prev }(e.traceLog)
+			e.traceLog = e.tlog().Named("slice")
+			e.tlog().Debug("encode: slice", logInt("length", l), logStringer("type", rv.Kind()))
 		}
 
 		// we would want to skip to the correct head_offset
@@ -145,13 +218,13 @@ func (e *Encoder) encodeBin(rv reflect.Value, opt *option) (err error) {
 		switch k := rv.Type().Elem().Kind(); k {
 		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 			// if it's a [n]byte, accumulate and write in one command:
-			if err := reflect_writeArrayOfUint_(e, l, k, rv, LE); err != nil {
+			if err := reflect_writeArrayOfUint_(e, l, k, rv, e.elemOrder()); err != nil {
 				return err
 			}
 		default:
 			for i := 0; i < l; i++ {
 				if err = e.encodeBin(rv.Index(i), nil); err != nil {
-					return
+					return newElementError("encoding", i, err)
 				}
 			}
 		}
@@ -161,23 +234,27 @@ func (e *Encoder) encodeBin(rv reflect.Value, opt *option) (err error) {
 		}
 
 	case reflect.Map:
-		keyCount := len(rv.MapKeys())
+		var keys []reflect.Value
+		if keys, err = e.sortedMapKeys(rv); err != nil {
+			return
+		}
+		keyCount := len(keys)
 
-		if traceEnabled {
-			zlog.Debug("encode: map",
-				zap.Int("key_count", keyCount),
-				zap.String("key_type", rt.String()),
+		if e.tracing() {
+			e.tlog().Debug("encode: map",
+				logInt("key_count", keyCount),
+				logString("key_type", rt.String()),
 				typeField("value_type", rv.Elem()),
 			)
-			defer func(prev *zap.Logger) { zlog = prev }(zlog)
-			zlog = zlog.Named("struct")
+			defer func(prev *logger) { e.traceLog = prev }(e.traceLog)
+			e.traceLog = e.tlog().Named("struct")
 		}
 
 		if err = e.WriteUVarInt(keyCount); err != nil {
 			return
 		}
 
-		for _, mapKey := range rv.MapKeys() {
+		for _, mapKey := range keys {
 			if err = e.Encode(mapKey.Interface()); err != nil {
 				return
 			}
@@ -194,72 +271,133 @@ func (e *Encoder) encodeBin(rv reflect.Value, opt *option) (err error) {
 }
 
 func (e *Encoder) encodeStructBin(rt reflect.Type, rv reflect.Value) (err error) {
-	l := rv.NumField()
+	plan := planOf(rt)
+	l := len(plan.fields)
+
+	if e.tracing() {
+		e.tlog().Debug("encode: struct", logInt("fields", l), logStringer("type", rv.Kind()))
+	}
+
+	inherited := e.inheritedOrder
+	defer func() { e.inheritedOrder = inherited }()
 
-	if traceEnabled {
-		zlog.Debug("encode: struct", zap.Int("fields", l), zap.Stringer("type", rv.Kind()))
+	if e.strictTags && plan.tagErr != nil {
+		return plan.tagErr
 	}
 
 	sizeOfMap := map[string]int{}
+	var byteSizes map[string]*byteSizePrefix
 	for i := 0; i < l; i++ {
-		structField := rt.Field(i)
-		fieldTag := parseFieldTag(structField.Tag)
+		structField := plan.fields[i]
+		fieldTag := plan.tags[i]
 
-		if fieldTag.Skip {
-			if traceEnabled {
-				zlog.Debug("encode: skipping struct field with skip flag",
-					zap.String("struct_field_name", structField.Name),
+		if fieldTag.Skip || fieldTag.SkipEncode {
+			if e.tracing() {
+				e.tlog().Debug("encode: skipping struct field with skip flag",
+					logString("struct_field_name", structField.Name),
 				)
 			}
 			continue
 		}
 
-		rv := rv.Field(i)
+		if fieldTag.Reserved > 0 {
+			if err := e.writeReserved(fieldTag.Reserved); err != nil {
+				return newFieldError("encoding", structField.Name, err)
+			}
+			continue
+		}
 
-		if fieldTag.SizeOf != "" {
-			if traceEnabled {
-				zlog.Debug("encode: struct field has sizeof tag",
-					zap.String("sizeof_field_name", fieldTag.SizeOf),
-					zap.String("struct_field_name", structField.Name),
+		if isSizeOfPath(fieldTag) {
+			size, err := sizeOfPath(fieldTag, rv)
+			if err != nil {
+				return newFieldError("encoding", structField.Name, err)
+			}
+			sizeOfMap[structField.Name] = size
+		}
+
+		var counter reflect.Value
+		if fieldTag.SizeOf != "" && !isSizeOfPath(fieldTag) {
+			if e.tracing() {
+				e.tlog().Debug("encode: struct field has sizeof tag",
+					logString("sizeof_field_name", fieldTag.SizeOf),
+					logString("struct_field_name", structField.Name),
 				)
 			}
-			sizeOfMap[fieldTag.SizeOf] = sizeof(structField.Type, rv)
+			var size int
+			var err error
+			counter, size, err = sizeOfCounter(plan, rv, i)
+			if err != nil {
+				return newFieldError("encoding", structField.Name, err)
+			}
+			sizeOfMap[fieldTag.SizeOf] = size
+		}
+
+		var variant reflect.Type
+		if fieldTag.Union != "" {
+			if variant, err = unionVariant(plan, rv, i); err != nil {
+				return newFieldError("encoding", structField.Name, err)
+			}
+		}
+
+		rv := plan.field(rv, i)
+		if counter.IsValid() {
+			// It's populated from the length of its target when zero.
+			rv = counter
 		}
 
 		if !rv.CanInterface() {
-			if traceEnabled {
-				zlog.Debug("encode:  skipping field: unable to interface field, probably since field is not exported",
-					zap.String("sizeof_field_name", fieldTag.SizeOf),
-					zap.String("struct_field_name", structField.Name),
+			if e.tracing() {
+				e.tlog().Debug("encode:  skipping field: unable to interface field, probably since field is not exported",
+					logString("sizeof_field_name", fieldTag.SizeOf),
+					logString("struct_field_name", structField.Name),
 				)
 			}
 			continue
 		}
 
-		option := &option{
-			is_OptionalField: fieldTag.Option,
-			Order:            fieldTag.Order,
-		}
+		option := plan.fieldOption(i, EncodingBin, inherited)
+
+		e.inheritedOrder = nestedOrder(fieldTag, inherited)
 
 		if s, ok := sizeOfMap[structField.Name]; ok {
-			if traceEnabled {
-				zlog.Debug("setting sizeof option", zap.String("of", structField.Name), zap.Int("size", s))
+			if e.tracing() {
+				e.tlog().Debug("setting sizeof option", logString("of", structField.Name), logInt("size", s))
 			}
-			option.setSizeOfSlice(s)
+			option = option.clone().setSizeOfSlice(s)
+		}
+		if variant != nil {
+			option = option.clone()
+			option.UnionVariant = variant
 		}
 
-		if traceEnabled {
-			zlog.Debug("encode: struct field",
-				zap.Stringer("struct_field_value_type", rv.Kind()),
-				zap.String("struct_field_name", structField.Name),
-				zap.Reflect("struct_field_tags", fieldTag),
-				zap.Reflect("struct_field_option", option),
+		if e.tracing() {
+			e.tlog().Debug("encode: struct field",
+				logStringer("struct_field_value_type", rv.Kind()),
+				logString("struct_field_name", structField.Name),
+				logReflect("struct_field_tags", fieldTag),
+				logReflect("struct_field_option", option),
 			)
 		}
 
+		if fieldTag.ByteSizeOf != "" {
+			if byteSizes == nil {
+				byteSizes = map[string]*byteSizePrefix{}
+				defer e.abortByteSizes(byteSizes)
+			}
+			byteSizes[fieldTag.ByteSizeOf] = e.beginByteSize(structField.Name, structField.Type, option)
+			continue
+		}
+		if p := byteSizes[structField.Name]; p != nil {
+			if err := e.encodeByteSized(p, rv, option, e.encodeBin); err != nil {
+				return newFieldError("encoding", structField.Name, err)
+			}
+			delete(byteSizes, structField.Name)
+			continue
+		}
+
 		if err := e.encodeBin(rv, option); err != nil {
-			return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+			return newFieldError("encoding", structField.Name, err)
 		}
 	}
-	return nil
+	return unencodedByteSizes(byteSizes)
 }
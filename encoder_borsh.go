@@ -21,9 +21,6 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"sort"
-
-	"go.uber.org/zap"
 )
 
 func (e *Encoder) encodePrimitive(rv reflect.Value, opt *option) (isPrimitive bool, err error) {
@@ -52,11 +49,15 @@ func (e *Encoder) encodePrimitive(rv reflect.Value, opt *option) (isPrimitive bo
 	case reflect.Int64:
 		err = e.WriteInt64(rv.Int(), LE)
 	case reflect.Float32:
-		err = e.WriteFloat32(float32(rv.Float()), LE)
+		err = e.WriteFloat32(float32Value(rv), LE)
 	case reflect.Float64:
 		err = e.WriteFloat64(rv.Float(), LE)
+	case reflect.Complex64:
+		err = e.WriteComplex64(complex64Value(rv), LE)
+	case reflect.Complex128:
+		err = e.WriteComplex128(rv.Complex(), LE)
 	case reflect.Bool:
-		err = e.WriteBool(rv.Bool())
+		err = e.WriteBoolWidth(rv.Bool(), boolWidth(opt), LE)
 	default:
 		isPrimitive = false
 	}
@@ -69,17 +70,17 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 	}
 	e.currentFieldOpt = opt
 
-	if traceEnabled {
-		zlog.Debug("encode: type",
-			zap.Stringer("value_kind", rv.Kind()),
-			zap.Reflect("options", opt),
+	if e.tracing() {
+		e.tlog().Debug("encode: type",
+			logStringer("value_kind", rv.Kind()),
+			logReflect("options", opt),
 		)
 	}
 
 	if opt.is_Optional() {
-		if rv.IsZero() {
-			if traceEnabled {
-				zlog.Debug("encode: skipping optional value with", zap.Stringer("type", rv.Kind()))
+		if e.isAbsent(rv, opt) {
+			if e.tracing() {
+				e.tlog().Debug("encode: skipping optional value with", logStringer("type", rv.Kind()))
 			}
 			return e.WriteOption(false)
 		}
@@ -87,13 +88,16 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 		if err != nil {
 			return err
 		}
+		if e.nextPointerLevel(rv, opt) {
+			return e.encodeBorsh(rv.Elem(), opt)
+		}
 		// The optionality has been used; stop its propagation:
 		opt.set_Optional(false)
 	}
 	if opt.is_COptional() {
-		if rv.IsZero() {
-			if traceEnabled {
-				zlog.Debug("encode: skipping optional value with", zap.Stringer("type", rv.Kind()))
+		if e.isAbsent(rv, opt) {
+			if e.tracing() {
+				e.tlog().Debug("encode: skipping optional value with", logStringer("type", rv.Kind()))
 			}
 			return e.WriteCOption(false)
 		}
@@ -101,6 +105,9 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 		if err != nil {
 			return err
 		}
+		if e.nextPointerLevel(rv, opt) {
+			return e.encodeBorsh(rv.Elem(), opt)
+		}
 		// The optionality has been used; stop its propagation:
 		opt.set_COptional(false)
 	}
@@ -111,18 +118,86 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 		return nil
 	}
 
-	if marshaler, ok := rv.Interface().(BinaryMarshaler); ok {
+	if handled, err := e.encodeUnion(rv, opt); handled {
+		return err
+	}
+
+	if marshaler, ok := binaryMarshaler(rv); ok {
 		if rv.Kind() == reflect.Ptr && rv.IsZero() {
 			return nil
 		}
-		if traceEnabled {
-			zlog.Debug("encode: using MarshalerBinary method to encode type")
+		if e.tracing() {
+			e.tlog().Debug("encode: using MarshalerBinary method to encode type")
 		}
 		return marshaler.MarshalWithEncoder(e)
 	}
 
+	if handled, err := e.encodeRunes(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeSwapped(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeVLQ(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeVarints(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeDelta(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeRLE(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeBigInt(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeWidthInt(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeNetAddr(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeDuration(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeTime(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeStdBinary(rv); handled {
+		return err
+	}
+	if handled, err := e.encodeText(rv); handled {
+		return err
+	}
+	if handled, err := e.encodeEncrypted(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeCompressed(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeSizeOfTarget(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeLenPrefixed(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeFixedString(rv, opt); handled {
+		return err
+	}
+	if err := checkMaxLen(rv, opt); err != nil {
+		return err
+	}
+	if handled, err := e.encodeCString(rv, opt); handled {
+		return err
+	}
+	if handled, err := e.encodeHeapBytes(rv); handled {
+		return err
+	}
+
 	// Encode the value if it's a primitive type
-	isPrimitive, err := e.encodePrimitive(rv, nil)
+	isPrimitive, err := e.encodePrimitive(rv, opt)
 	if isPrimitive {
 		return err
 	}
@@ -131,9 +206,9 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 	case reflect.Ptr:
 		if rv.IsNil() {
 			el := reflect.New(rv.Type().Elem()).Elem()
-			return e.encodeBorsh(el, nil)
+			return e.encodeBorsh(el, opt)
 		} else {
-			return e.encodeBorsh(rv.Elem(), nil)
+			return e.encodeBorsh(rv.Elem(), opt)
 		}
 	case reflect.Interface:
 		// skip
@@ -147,10 +222,10 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 	switch rt.Kind() {
 	case reflect.Array:
 		l := rt.Len()
-		if traceEnabled {
-			defer func(prev *zap.Logger) { zlog = prev }(zlog)
-			zlog = zlog.Named("array")
-			zlog.Debug("encode: array", zap.Int("length", l), zap.Stringer("type", rv.Kind()))
+		if e.tracing() {
+			defer func(prev *logger) { e.traceLog = prev }(e.traceLog)
+			e.traceLog = e.tlog().Named("array")
+			e.tlog().Debug("encode: array", logInt("length", l), logStringer("type", rv.Kind()))
 		}
 
 		switch k := rv.Type().Elem().Kind(); k {
@@ -162,7 +237,7 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 		default:
 			for i := 0; i < l; i++ {
 				if err = e.encodeBorsh(rv.Index(i), nil); err != nil {
-					return
+					return newElementError("encoding", i, err)
 				}
 			}
 		}
@@ -170,19 +245,19 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 		var l int
 		if opt.hasSizeOfSlice() {
 			l = opt.getSizeOfSlice()
-			if traceEnabled {
-				zlog.Debug("encode: slice with sizeof set", zap.Int("size_of", l))
+			if e.tracing() {
+				e.tlog().Debug("encode: slice with sizeof set", logInt("size_of", l))
 			}
 		} else {
 			l = rv.Len()
-			if err = e.WriteUint32(uint32(l), LE); err != nil {
+			if err = e.WriteUint32(uint32(l), e.lengthOrder()); err != nil {
 				return
 			}
 		}
-		if traceEnabled {
-			defer func(prev *zap.Logger) { zlog = prev }(zlog)
-			zlog = zlog.Named("slice")
-			zlog.Debug("encode: slice", zap.Int("length", l), zap.Stringer("type", rv.Kind()))
+		if e.tracing() {
+			defer func(prev *logger) { e.traceLog = prev }(e.traceLog)
+			e.traceLog = e.tlog().Named("slice")
+			e.tlog().Debug("encode: slice", logInt("length", l), logStringer("type", rv.Kind()))
 		}
 
 		// we would want to skip to the correct head_offset
@@ -196,7 +271,7 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 		default:
 			for i := 0; i < l; i++ {
 				if err = e.encodeBorsh(rv.Index(i), nil); err != nil {
-					return
+					return newElementError("encoding", i, err)
 				}
 			}
 		}
@@ -207,21 +282,23 @@ func (e *Encoder) encodeBorsh(rv reflect.Value, opt *option) (err error) {
 		}
 
 	case reflect.Map:
-		keys := rv.MapKeys()
-		sort.Slice(keys, vComp(keys))
+		var keys []reflect.Value
+		if keys, err = e.sortedMapKeys(rv); err != nil {
+			return
+		}
 
 		keyCount := rv.Len()
-		if traceEnabled {
-			zlog.Debug("encode: map",
-				zap.Int("key_count", keyCount),
-				zap.String("key_type", rt.String()),
+		if e.tracing() {
+			e.tlog().Debug("encode: map",
+				logInt("key_count", keyCount),
+				logString("key_type", rt.String()),
 				typeField("value_type", rv),
 			)
-			defer func(prev *zap.Logger) { zlog = prev }(zlog)
-			zlog = zlog.Named("struct")
+			defer func(prev *logger) { e.traceLog = prev }(e.traceLog)
+			e.traceLog = e.tlog().Named("struct")
 		}
 
-		if err = e.WriteUint32(uint32(keyCount), LE); err != nil {
+		if err = e.WriteUint32(uint32(keyCount), e.lengthOrder()); err != nil {
 			return
 		}
 
@@ -282,109 +359,134 @@ func (*EmptyVariant) UnmarshalWithDecoder(_ *Decoder) error {
 }
 
 func (e *Encoder) encodeStructBorsh(rt reflect.Type, rv reflect.Value) (err error) {
-	l := rv.NumField()
+	plan := planOf(rt)
+	l := len(plan.fields)
 
-	if traceEnabled {
-		zlog.Debug("encode: struct", zap.Int("fields", l), zap.Stringer("type", rv.Kind()))
+	if e.tracing() {
+		e.tlog().Debug("encode: struct", logInt("fields", l), logStringer("type", rv.Kind()))
 	}
 
-	// Handle complex enum:
-	if rt.NumField() > 0 {
-		// If the first field has type BorshEnum and is flagged with "borsh_enum"
-		// we have a complex enum:
-		firstField := rt.Field(0)
-		if isTypeBorshEnum(firstField.Type) &&
-			parseFieldTag(firstField.Tag).IsBorshEnum {
-			return e.encodeComplexEnumBorsh(rv)
-		}
+	if e.strictTags && plan.tagErr != nil {
+		return plan.tagErr
+	}
+
+	// Handle complex enum: the first field has type BorshEnum
+	// and is flagged with "borsh_enum".
+	if plan.complexEnum {
+		return e.encodeComplexEnumBorsh(rv)
 	}
 
 	sizeOfMap := map[string]int{}
+	var byteSizes map[string]*byteSizePrefix
 	for i := 0; i < l; i++ {
-		structField := rt.Field(i)
-		fieldTag := parseFieldTag(structField.Tag)
+		structField := plan.fields[i]
+		fieldTag := plan.tags[i]
 
-		if fieldTag.Skip {
-			if traceEnabled {
-				zlog.Debug("encode: skipping struct field with skip flag",
-					zap.String("struct_field_name", structField.Name),
+		if fieldTag.Skip || fieldTag.SkipEncode {
+			if e.tracing() {
+				e.tlog().Debug("encode: skipping struct field with skip flag",
+					logString("struct_field_name", structField.Name),
 				)
 			}
 			continue
 		}
 
-		rv := rv.Field(i)
+		if fieldTag.Reserved > 0 {
+			if err := e.writeReserved(fieldTag.Reserved); err != nil {
+				return newFieldError("encoding", structField.Name, err)
+			}
+			continue
+		}
+
+		if isSizeOfPath(fieldTag) {
+			size, err := sizeOfPath(fieldTag, rv)
+			if err != nil {
+				return newFieldError("encoding", structField.Name, err)
+			}
+			sizeOfMap[structField.Name] = size
+		}
 
-		if fieldTag.SizeOf != "" {
-			if traceEnabled {
-				zlog.Debug("encode: struct field has sizeof tag",
-					zap.String("sizeof_field_name", fieldTag.SizeOf),
-					zap.String("struct_field_name", structField.Name),
+		var counter reflect.Value
+		if fieldTag.SizeOf != "" && !isSizeOfPath(fieldTag) {
+			if e.tracing() {
+				e.tlog().Debug("encode: struct field has sizeof tag",
+					logString("sizeof_field_name", fieldTag.SizeOf),
+					logString("struct_field_name", structField.Name),
 				)
 			}
-			sizeOfMap[fieldTag.SizeOf] = sizeof(structField.Type, rv)
+			var size int
+			var err error
+			counter, size, err = sizeOfCounter(plan, rv, i)
+			if err != nil {
+				return newFieldError("encoding", structField.Name, err)
+			}
+			sizeOfMap[fieldTag.SizeOf] = size
+		}
+
+		var variant reflect.Type
+		if fieldTag.Union != "" {
+			if variant, err = unionVariant(plan, rv, i); err != nil {
+				return newFieldError("encoding", structField.Name, err)
+			}
+		}
+
+		rv := plan.field(rv, i)
+		if counter.IsValid() {
+			// It's populated from the length of its target when zero.
+			rv = counter
 		}
 
 		if !rv.CanInterface() {
-			if traceEnabled {
-				zlog.Debug("encode:  skipping field: unable to interface field, probably since field is not exported",
-					zap.String("sizeof_field_name", fieldTag.SizeOf),
-					zap.String("struct_field_name", structField.Name),
+			if e.tracing() {
+				e.tlog().Debug("encode:  skipping field: unable to interface field, probably since field is not exported",
+					logString("sizeof_field_name", fieldTag.SizeOf),
+					logString("struct_field_name", structField.Name),
 				)
 			}
 			continue
 		}
 
-		option := &option{
-			is_OptionalField:  fieldTag.Option,
-			is_COptionalField: fieldTag.COption,
-			Order:             fieldTag.Order,
-		}
+		option := plan.fieldOption(i, EncodingBorsh, nil)
 
 		if s, ok := sizeOfMap[structField.Name]; ok {
-			if traceEnabled {
-				zlog.Debug("setting sizeof option", zap.String("of", structField.Name), zap.Int("size", s))
+			if e.tracing() {
+				e.tlog().Debug("setting sizeof option", logString("of", structField.Name), logInt("size", s))
 			}
-			option.setSizeOfSlice(s)
+			option = option.clone().setSizeOfSlice(s)
+		}
+		if variant != nil {
+			option = option.clone()
+			option.UnionVariant = variant
 		}
 
-		if traceEnabled {
-			zlog.Debug("encode: struct field",
-				zap.Stringer("struct_field_value_type", rv.Kind()),
-				zap.String("struct_field_name", structField.Name),
-				zap.Reflect("struct_field_tags", fieldTag),
-				zap.Reflect("struct_field_option", option),
+		if e.tracing() {
+			e.tlog().Debug("encode: struct field",
+				logStringer("struct_field_value_type", rv.Kind()),
+				logString("struct_field_name", structField.Name),
+				logReflect("struct_field_tags", fieldTag),
+				logReflect("struct_field_option", option),
 			)
 		}
 
-		if err := e.encodeBorsh(rv, option); err != nil {
-			return fmt.Errorf("error while encoding %q field: %w", structField.Name, err)
+		if fieldTag.ByteSizeOf != "" {
+			if byteSizes == nil {
+				byteSizes = map[string]*byteSizePrefix{}
+				defer e.abortByteSizes(byteSizes)
+			}
+			byteSizes[fieldTag.ByteSizeOf] = e.beginByteSize(structField.Name, structField.Type, option)
+			continue
+		}
+		if p := byteSizes[structField.Name]; p != nil {
+			if err := e.encodeByteSized(p, rv, option, e.encodeBorsh); err != nil {
+				return newFieldError("encoding", structField.Name, err)
+			}
+			delete(byteSizes, structField.Name)
+			continue
 		}
-	}
-	return nil
-}
 
-func vComp(keys []reflect.Value) func(int, int) bool {
-	return func(i int, j int) bool {
-		a, b := keys[i], keys[j]
-		if a.Kind() == reflect.Interface {
-			a = a.Elem()
-			b = b.Elem()
-		}
-		switch a.Kind() {
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
-			return a.Int() < b.Int()
-		case reflect.Int64:
-			return a.Interface().(int64) < b.Interface().(int64)
-		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
-			return a.Uint() < b.Uint()
-		case reflect.Uint64:
-			return a.Interface().(uint64) < b.Interface().(uint64)
-		case reflect.Float32, reflect.Float64:
-			return a.Float() < b.Float()
-		case reflect.String:
-			return a.String() < b.String()
-		}
-		panic("unsupported key compare")
+		if err := e.encodeBorsh(rv, option); err != nil {
+			return newFieldError("encoding", structField.Name, err)
+		}
 	}
+	return unencodedByteSizes(byteSizes)
 }
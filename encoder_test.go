@@ -19,8 +19,10 @@ package bin
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"math"
 	"reflect"
 	"testing"
@@ -54,6 +56,121 @@ func TestEncoder_Size(t *testing.T) {
 	}
 }
 
+func TestEncoder_Tee(t *testing.T) {
+	buf := new(bytes.Buffer)
+	journal := new(bytes.Buffer)
+	hasher := sha256.New()
+
+	enc := NewBorshEncoder(buf).Tee(journal).Tee(hasher)
+	require.NoError(t, enc.Encode(struct {
+		A uint32
+		B string
+	}{A: 1, B: "tee"}))
+	require.NoError(t, enc.WriteByte(0xff))
+
+	expected := []byte{1, 0, 0, 0, 3, 0, 0, 0, 't', 'e', 'e', 0xff}
+	assert.Equal(t, expected, buf.Bytes())
+	assert.Equal(t, expected, journal.Bytes())
+	sum := sha256.Sum256(expected)
+	assert.Equal(t, sum[:], hasher.Sum(nil))
+	assert.Equal(t, len(expected), enc.Written())
+}
+
+func TestEncoder_MaxEncodedSize(t *testing.T) {
+	type item struct {
+		ID   uint32
+		Memo string
+	}
+	type message struct {
+		Items []item
+	}
+	msg := message{Items: []item{{ID: 1, Memo: "a"}, {ID: 2, Memo: "too long"}}}
+
+	buf := new(bytes.Buffer)
+	err := NewBorshEncoder(buf).WithMaxEncodedSize(20).Encode(msg)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrMaxEncodedSizeExceeded))
+	assert.Equal(t, "Items[1].Memo", FieldPath(err))
+	assert.Equal(t, `error while encoding "Items" field: error while encoding element 1: error while encoding "Memo" field: max encoded size exceeded: writing 4 bytes at offset 17 would exceed 20 bytes`, err.Error())
+	// the offending write was not performed:
+	assert.Equal(t, 17, buf.Len())
+
+	buf.Reset()
+	require.NoError(t, NewBorshEncoder(buf).WithMaxEncodedSize(29).Encode(msg))
+	assert.Equal(t, 29, buf.Len())
+}
+
+func TestEncoder_OneWaySkip(t *testing.T) {
+	type frame struct {
+		Kind     uint8
+		Body     []byte
+		Checksum uint32 `bin:"skip=decode"`
+	}
+	in := frame{Kind: 1, Body: []byte{0xaa}, Checksum: 0xdeadbeef}
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&in), enc)
+		data := buf.Bytes()
+		assert.Equal(t, []byte{0xef, 0xbe, 0xad, 0xde}, data[len(data)-4:], enc)
+
+		// The checksum is left to the caller, after the decoded fields.
+		dec := NewDecoderWithEncoding(data, enc)
+		var out frame
+		require.NoError(t, dec.Decode(&out), enc)
+		assert.Equal(t, frame{Kind: 1, Body: []byte{0xaa}}, out, enc)
+		checksum, err := dec.ReadUint32(LE)
+		require.NoError(t, err)
+		assert.Equal(t, uint32(0xdeadbeef), checksum, enc)
+	}
+
+	type reply struct {
+		ID       uint16
+		Received uint32 `bin:"skip=encode"`
+	}
+	data, err := MarshalBin(reply{ID: 1, Received: 42})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 0}, data)
+	var out reply
+	require.NoError(t, UnmarshalBin(&out, []byte{1, 0, 42, 0, 0, 0}))
+	assert.Equal(t, reply{ID: 1, Received: 42}, out)
+
+	type counted struct {
+		N     uint8 `bin:"sizeof=Items skip=encode"`
+		Items []uint8
+	}
+	assert.EqualError(t, Precompile(counted{}), "precompile: bin.counted: field \"N\": sizeof and bytesizeof fields can't be skipped in one direction only")
+}
+
+func TestEncoder_RejectNonFiniteFloats(t *testing.T) {
+	type leg struct {
+		Price  float64
+		Weight float32
+	}
+	type order struct {
+		Legs []leg
+	}
+	ord := order{Legs: []leg{{Price: 1.5, Weight: 1}, {Price: 2, Weight: float32(math.Inf(-1))}}}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).Encode(ord))
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		err := NewEncoderWithEncoding(new(bytes.Buffer), enc).RejectNonFiniteFloats().Encode(ord)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrNonFiniteFloat))
+		assert.Equal(t, "Legs[1].Weight", FieldPath(err))
+	}
+
+	ord.Legs[1].Weight = 3
+	ord.Legs[0].Price = math.NaN()
+	err := NewBinEncoder(new(bytes.Buffer)).RejectNonFiniteFloats().Encode(ord)
+	assert.Equal(t, `error while encoding "Legs" field: error while encoding element 0: error while encoding "Price" field: non-finite float: NaN`, err.Error())
+
+	ord.Legs[0].Price = math.MaxFloat64
+	assert.NoError(t, NewBinEncoder(new(bytes.Buffer)).RejectNonFiniteFloats().Encode(ord))
+}
+
 func TestEncoder_AliastTestType(t *testing.T) {
 	buf := new(bytes.Buffer)
 	enc := NewBinEncoder(buf)
@@ -317,6 +434,20 @@ func TestEncoder_float64(t *testing.T) {
 	}, buf.Bytes())
 }
 
+func TestEncoder_complex(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	enc := NewBinEncoder(buf)
+	enc.WriteComplex64(complex(float32(1), float32(-2)), LE)
+	enc.WriteComplex128(complex(float64(1), float64(-2)), BE)
+
+	assert.Equal(t, []byte{
+		0x00, 0x00, 0x80, 0x3f, 0x00, 0x00, 0x00, 0xc0,
+		0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}, buf.Bytes())
+}
+
 func TestEncoder_string(t *testing.T) {
 	buf := new(bytes.Buffer)
 
@@ -0,0 +1,183 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ErrNoEncryptionKey is returned when encoding a field tagged
+// `bin:"encrypt"` with an encoder that has no encryption key.
+var ErrNoEncryptionKey = errors.New("no encryption key")
+
+// ErrUnknownKeyID is returned when decoding an encrypted field
+// whose key ID isn't one of the keys of the decoder.
+var ErrUnknownKeyID = errors.New("unknown encryption key ID")
+
+// ErrDecryption is returned when an encrypted field can't be decrypted:
+// it was encrypted with another key, or it was modified.
+var ErrDecryption = errors.New("decryption failed")
+
+// WithEncryptionKey sets the key that fields tagged `bin:"encrypt"` are
+// encrypted with, and its ID, which is written with every encrypted field
+// so that decoders can pick the right key. The key is an AES-128, AES-192 or
+// AES-256 key (16, 24 or 32 bytes); fields are encrypted with AES-GCM.
+// See WithKeyProvider for keys that are rotated.
+func (e *Encoder) WithEncryptionKey(id string, key []byte) *Encoder {
+	e.keys = staticKey{id: id, key: key}
+	return e
+}
+
+// WithDecryptionKey adds a key that fields tagged `bin:"encrypt"` can be
+// decrypted with; it can be called once per key ID, e.g. for the keys that
+// older messages were encrypted with. These keys are looked up before
+// those of the key provider of the decoder, if any.
+func (dec *Decoder) WithDecryptionKey(id string, key []byte) *Decoder {
+	if dec.decryptionKeys == nil {
+		dec.decryptionKeys = map[string][]byte{}
+	}
+	dec.decryptionKeys[id] = key
+	return dec
+}
+
+// decryptionKey returns the key with the provided ID.
+func (dec *Decoder) decryptionKey(id string) ([]byte, error) {
+	if key, ok := dec.decryptionKeys[id]; ok {
+		return key, nil
+	}
+	if dec.keys == nil {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKeyID, id)
+	}
+	return dec.keys.Key(id)
+}
+
+// encodeEncrypted writes strings and byte slices tagged `bin:"encrypt"` as
+// byte slices holding the encrypted block: the key ID (a uvarint length and
+// its bytes), the nonce, and the AES-GCM ciphertext, authenticated with the
+// key ID. With the `compress` tag, the data is compressed before being encrypted.
+func (e *Encoder) encodeEncrypted(rv reflect.Value, opt *option) (bool, error) {
+	if !opt.Encrypt || !isCompressibleType(rv.Type()) {
+		return false, nil
+	}
+	if e.keys == nil {
+		return true, ErrNoEncryptionKey
+	}
+	id, key, err := e.keys.CurrentKey()
+	if err != nil {
+		return true, err
+	}
+	var data []byte
+	if rv.Kind() == reflect.String {
+		data = []byte(rv.String())
+	} else {
+		data = rv.Bytes()
+	}
+	if opt.Compress {
+		if data, err = compressBlock(e.compressor, data, opt.Dictionary); err != nil {
+			return true, err
+		}
+	}
+	block, err := encryptBlock(id, key, data)
+	if err != nil {
+		return true, err
+	}
+	return true, e.WriteBytes(block, true)
+}
+
+func (dec *Decoder) decodeEncrypted(rv reflect.Value, opt *option) (bool, error) {
+	if !opt.Encrypt || !isCompressibleType(rv.Type()) {
+		return false, nil
+	}
+	block, err := dec.ReadByteSlice()
+	if err != nil {
+		return true, err
+	}
+	data, err := decryptBlock(block, dec.decryptionKey)
+	if err != nil {
+		return true, err
+	}
+	if opt.Compress {
+		if data, err = decompressBlock(dec.compressor, data); err != nil {
+			return true, err
+		}
+	}
+	if rv.Kind() == reflect.String {
+		rv.SetString(string(data))
+	} else {
+		rv.SetBytes(data)
+	}
+	return true, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptBlock(id string, key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	enc := NewBinEncoder(buf)
+	if err := enc.WriteBytes([]byte(id), true); err != nil {
+		return nil, err
+	}
+	header := buf.Len()
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	buf.Write(nonce)
+	out := buf.Bytes()
+	return gcm.Seal(out, nonce, data, out[:header]), nil
+}
+
+func decryptBlock(block []byte, keyOf func(id string) ([]byte, error)) ([]byte, error) {
+	dec := NewBinDecoder(block)
+	id, err := dec.ReadByteSlice()
+	if err != nil {
+		return nil, fmt.Errorf("encrypted block: %w", err)
+	}
+	header := dec.pos
+	key, err := keyOf(string(id))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if dec.Remaining() < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted block: %w", io.ErrUnexpectedEOF)
+	}
+	nonce := block[header : header+gcm.NonceSize()]
+	data, err := gcm.Open(nil, nonce, block[header+gcm.NonceSize():], block[:header])
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return data, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type encryptedPayment struct {
+	Amount uint64
+	Memo   string `bin:"encrypt"`
+	Tax    []byte `bin:"encrypt compress"`
+	Fee    uint32
+}
+
+func TestEncrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	in := encryptedPayment{Amount: 5, Memo: "rent for may", Tax: bytes.Repeat([]byte("tax"), 10), Fee: 9}
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).WithEncryptionKey("k1", key).Encode(&in), enc)
+		data := buf.Bytes()
+		assert.False(t, bytes.Contains(data, []byte("rent")), enc)
+
+		var out encryptedPayment
+		require.NoError(t, NewDecoderWithEncoding(data, enc).WithDecryptionKey("k1", key).Decode(&out), enc)
+		assert.Equal(t, in, out, enc)
+
+		// Other fields can be queried without the key.
+		res, err := QueryWithEncoding(data, enc, encryptedPayment{}, "Fee")
+		require.NoError(t, err, enc)
+		assert.Equal(t, uint32(9), res.Value, enc)
+
+		err = NewDecoderWithEncoding(data, enc).Decode(&out)
+		assert.True(t, errors.Is(err, ErrUnknownKeyID), enc)
+		assert.EqualError(t, err, `error while decoding "Memo" field: unknown encryption key ID "k1"`, enc)
+
+		err = NewDecoderWithEncoding(data, enc).WithDecryptionKey("k1", bytes.Repeat([]byte{8}, 32)).Decode(&out)
+		assert.True(t, errors.Is(err, ErrDecryption), enc)
+	}
+
+	// Each encoding uses a new nonce.
+	first := new(bytes.Buffer)
+	second := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(first).WithEncryptionKey("k1", key).Encode(&in))
+	require.NoError(t, NewBinEncoder(second).WithEncryptionKey("k1", key).Encode(&in))
+	assert.NotEqual(t, first.Bytes(), second.Bytes())
+
+	_, err := MarshalBin(&in)
+	assert.True(t, errors.Is(err, ErrNoEncryptionKey))
+}
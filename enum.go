@@ -0,0 +1,153 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// EnumValidator is implemented by integer enum types that
+// know their valid values, e.g.
+//
+//	func (c Color) ValidValues() []uint64 { return []uint64{0, 1, 2} }
+//
+// Signed values are converted with uint64(v).
+type EnumValidator interface {
+	ValidValues() []uint64
+}
+
+var enumValidatorType = reflect.TypeOf((*EnumValidator)(nil)).Elem()
+
+// An InvalidEnumValueError describes a decoded value of a field tagged
+// `bin:"enum"` that isn't one of the valid values of its type.
+type InvalidEnumValueError struct {
+	Type  reflect.Type
+	Value interface{}
+}
+
+func (e *InvalidEnumValueError) Error() string {
+	return fmt.Sprintf("invalid value %d for enum %s", e.Value, e.Type)
+}
+
+// enumValues maps an enum type to the set of its valid values.
+var enumValues sync.Map
+
+// RegisterEnumValues registers the valid values of an integer enum type, provided
+// as a slice of that type, e.g. the result of the `ColorValues()` function
+// generated by enumer:
+//
+//	bin.RegisterEnumValues(ColorValues())
+//
+// Decoding a field of that type tagged `bin:"enum"` then fails if the decoded
+// value is not one of them. The registered values take precedence over
+// the ValidValues method of the type.
+func RegisterEnumValues(values interface{}) {
+	rv := reflect.ValueOf(values)
+	if rv.Kind() != reflect.Slice || !isIntegerKind(rv.Type().Elem().Kind()) {
+		panic(fmt.Sprintf("RegisterEnumValues: expected a slice of integers, got %T", values))
+	}
+	set := make(map[uint64]bool, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		set[enumValue(rv.Index(i))] = true
+	}
+	enumValues.Store(rv.Type().Elem(), set)
+}
+
+func isIntegerKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func enumValue(rv reflect.Value) uint64 {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(rv.Int())
+	default:
+		return rv.Uint()
+	}
+}
+
+// validEnumValues returns the set of valid values of the provided type,
+// or nil if it has none.
+func validEnumValues(rt reflect.Type) map[uint64]bool {
+	if set, ok := enumValues.Load(rt); ok {
+		return set.(map[uint64]bool)
+	}
+	if !rt.Implements(enumValidatorType) {
+		return nil
+	}
+	values := reflect.Zero(rt).Interface().(EnumValidator).ValidValues()
+	set := make(map[uint64]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// WithLenientEnums makes the decoder keep the invalid values of the fields
+// tagged `bin:"enum"`, and report them as warnings (see Warnings) instead of
+// failing, e.g. to read data written by a newer version that added values.
+func (dec *Decoder) WithLenientEnums() *Decoder {
+	dec.lenientEnums = true
+	return dec
+}
+
+// checkEnum validates a decoded struct field tagged `bin:"enum"`.
+func (dec *Decoder) checkEnum(field string, rv reflect.Value) error {
+	err := validateEnum(rv)
+	if err != nil && dec.lenientEnums {
+		dec.warnFieldf(field, "%s", err)
+		return nil
+	}
+	return err
+}
+
+// validateEnum checks the value of a decoded struct field tagged `bin:"enum"`;
+// optional fields are checked when present, and slices and arrays element by element.
+// Fields whose type has no valid values are not checked.
+func validateEnum(rv reflect.Value) error {
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if !isIntegerKind(rv.Type().Elem().Kind()) {
+			return nil
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := validateEnum(rv.Index(i)); err != nil {
+				return newElementError("decoding", i, err)
+			}
+		}
+		return nil
+	}
+	if !isIntegerKind(rv.Kind()) {
+		return nil
+	}
+	set := validEnumValues(rv.Type())
+	if set == nil || set[enumValue(rv)] {
+		return nil
+	}
+	return &InvalidEnumValueError{Type: rv.Type(), Value: rv.Interface()}
+}
@@ -0,0 +1,88 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testColor uint8
+
+func (c testColor) ValidValues() []uint64 { return []uint64{0, 1, 2} }
+
+type testLevel int16
+
+func testLevelValues() []testLevel { return []testLevel{-1, 0, 10} }
+
+func TestDecoder_EnumValidation(t *testing.T) {
+	RegisterEnumValues(testLevelValues())
+
+	type palette struct {
+		Color   testColor   `bin:"enum"`
+		Level   testLevel   `bin:"enum big"`
+		Colors  []testColor `bin:"enum"`
+		Maybe   *testColor  `bin:"optional enum"`
+		Unknown testColor
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		two := testColor(2)
+		in := palette{Color: 1, Level: -1, Colors: []testColor{0, 2}, Maybe: &two, Unknown: 9}
+		for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(in))
+			data := buf.Bytes()
+			var out palette
+			require.NoError(t, NewDecoderWithEncoding(data, enc).Decode(&out), enc)
+			assert.Equal(t, in, out)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		in    palette
+		path  string
+		error string
+	}{
+		{"method", palette{Color: 3}, "Color", "invalid value 3 for enum bin.testColor"},
+		{"registered", palette{Level: 5}, "Level", "invalid value 5 for enum bin.testLevel"},
+		{"slice", palette{Colors: []testColor{1, 7}}, "Colors[1]", "invalid value 7 for enum bin.testColor"},
+		{"optional", palette{Maybe: func() *testColor { c := testColor(4); return &c }()}, "Maybe", "invalid value 4 for enum bin.testColor"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := MarshalBin(test.in)
+			require.NoError(t, err)
+			var out palette
+			err = NewBinDecoder(data).Decode(&out)
+			require.Error(t, err)
+			assert.Equal(t, test.path, FieldPath(err))
+
+			var enumErr *InvalidEnumValueError
+			require.True(t, errors.As(err, &enumErr))
+			assert.Equal(t, test.error, enumErr.Error())
+		})
+	}
+}
+
+func TestRegisterEnumValues_Invalid(t *testing.T) {
+	assert.Panics(t, func() { RegisterEnumValues([]string{"a"}) })
+	assert.Panics(t, func() { RegisterEnumValues(testColor(1)) })
+}
@@ -14,7 +14,13 @@
 
 package bin
 
-import "reflect"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
 
 // An InvalidDecoderError describes an invalid argument passed to Decoder.
 // (The argument to Decoder must be a non-nil pointer.)
@@ -32,3 +38,58 @@ func (e *InvalidDecoderError) Error() string {
 	}
 	return "decoder: Decode(nil " + e.Type.String() + ")"
 }
+
+// FieldError wraps an error that happened while encoding or decoding
+// a struct field or an element of a slice or array.
+//
+// Nested FieldErrors describe the path to the offending value;
+// use FieldPath to obtain it.
+type FieldError struct {
+	// Op is either "encoding" or "decoding".
+	Op string
+	// Field is the name of the struct field; it's empty for elements.
+	Field string
+	// Index is the position of the element in its slice or array.
+	Index int
+	Err   error
+}
+
+func newFieldError(op string, field string, err error) error {
+	return &FieldError{Op: op, Field: field, Err: err}
+}
+
+func newElementError(op string, index int, err error) error {
+	return &FieldError{Op: op, Index: index, Err: err}
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("error while %s element %d: %s", e.Op, e.Index, e.Err)
+	}
+	return fmt.Sprintf("error while %s %q field: %s", e.Op, e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldPath returns the path of the value that caused the provided
+// encoding or decoding error, e.g. "Transactions[2].Memo",
+// using the same syntax as Query. It returns an empty string if
+// the error doesn't carry any field information.
+func FieldPath(err error) string {
+	builder := new(strings.Builder)
+	var fieldErr *FieldError
+	for errors.As(err, &fieldErr) {
+		if fieldErr.Field == "" {
+			builder.WriteString("[" + strconv.Itoa(fieldErr.Index) + "]")
+		} else {
+			if builder.Len() > 0 {
+				builder.WriteByte('.')
+			}
+			builder.WriteString(fieldErr.Field)
+		}
+		err = fieldErr.Err
+	}
+	return builder.String()
+}
@@ -0,0 +1,235 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// ExplainType describes how the values of the type of `v` (a value or a pointer
+// to a value) are laid out by the Bin encoding: one row per encoded value,
+// in wire order, with its length prefix, byte order, optionality, offset and size.
+// Nested values are listed under their parent, e.g. "Items[].Amount".
+//
+//	bin.ExplainType(Transfer{})
+func ExplainType(v interface{}) (string, error) {
+	return ExplainTypeWithEncoding(v, EncodingBin)
+}
+
+// ExplainTypeWithEncoding is like ExplainType, but for the provided encoding.
+func ExplainTypeWithEncoding(v interface{}, enc Encoding) (string, error) {
+	rt := reflect.TypeOf(v)
+	if rt == nil {
+		return "", fmt.Errorf("explain: nil type")
+	}
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if !isValidEncoding(enc) {
+		return "", fmt.Errorf("explain: invalid encoding %d", enc)
+	}
+	layout, err := describeType(rt, enc)
+	if err != nil {
+		return "", fmt.Errorf("explain: %s: %w", rt, err)
+	}
+
+	buf := new(strings.Builder)
+	fmt.Fprintf(buf, "%s (%s encoding, %s)\n", rt, enc, explainSize(layout))
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tFIELD\tTYPE\tWIRE\tPREFIX\tORDER\tPRESENCE\tOFFSET\tSIZE")
+	ex := &explainer{w: w}
+	if layout.Wire == wireStruct && !layout.Recursive {
+		ex.fields("", layout, 0)
+	} else {
+		ex.row("", layout, 0)
+	}
+	w.Flush()
+	return buf.String(), nil
+}
+
+type explainer struct {
+	w     *tabwriter.Writer
+	index int
+}
+
+// fields writes a row for each field of a struct; offset is the offset
+// of the struct relative to its parent, or -1 if it's not fixed.
+func (ex *explainer) fields(path string, n *layoutNode, offset int) {
+	for _, field := range n.Fields {
+		name := field.Name
+		if path != "" {
+			name = path + "." + name
+		}
+		ex.row(name, field, offset)
+		if offset >= 0 && field.isFixed() && !field.Extension {
+			offset += field.Size
+		} else {
+			offset = -1
+		}
+	}
+}
+
+func (ex *explainer) row(path string, n *layoutNode, offset int) {
+	ex.index++
+	name := path
+	if name == "" {
+		name = "(value)"
+	}
+	typ := n.Type.String()
+	if n.Recursive {
+		typ += " (recursive)"
+	}
+	fmt.Fprintf(ex.w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+		ex.index,
+		name,
+		typ,
+		n.Wire,
+		explainPrefix(n),
+		explainOrder(n),
+		explainPresence(n),
+		explainOffset(offset),
+		explainOffset(n.Size),
+	)
+
+	// Offsets of nested values are relative to their parent.
+	switch n.Wire {
+	case wireStruct:
+		if !n.Recursive {
+			ex.fields(path, n, explainNestedOffset(n))
+		}
+	case wireEnum:
+		for _, variant := range n.Fields {
+			// Variants come after the one-byte variant index.
+			ex.row(path+"<"+variant.Name+">", variant, 1)
+		}
+	case wireUnion:
+		for _, variant := range n.Fields {
+			ex.row(path+"<"+variant.Name+">", variant, 0)
+		}
+	case wireSlice, wireArray:
+		ex.row(path+"[]", n.Elem, 0)
+	case wireMap:
+		ex.row(path+"{key}", n.Key, -1)
+		ex.row(path+"{value}", n.Elem, -1)
+	}
+}
+
+func explainNestedOffset(n *layoutNode) int {
+	if n.Presence != presenceNone {
+		return -1
+	}
+	return 0
+}
+
+func explainPrefix(n *layoutNode) string {
+	if n.ByteSizeOf != "" {
+		prefix := explainLengthPrefix(n)
+		if prefix == "-" {
+			return "bytesizeof=" + n.ByteSizeOf
+		}
+		return "bytesizeof=" + n.ByteSizeOf + "," + prefix
+	}
+	if n.Wire == wireUnion {
+		return "union=" + n.Union
+	}
+	return explainLengthPrefix(n)
+}
+
+func explainLengthPrefix(n *layoutNode) string {
+	switch n.Prefix {
+	case prefixNone:
+		if n.Wire == wireArray || n.Wire == wireBytes || n.Wire == wireString {
+			return "fixed=" + strconv.Itoa(n.Length)
+		}
+		return "-"
+	case prefixSizeOf:
+		if n.SizeFunc != "" {
+			return "sizeof=" + n.SizeOf + n.SizeExpr + ",sizefunc=" + n.SizeFunc
+		}
+		return "sizeof=" + n.SizeOf + n.SizeExpr
+	case prefixUint16, prefixUint32, prefixUint64:
+		if n.PrefixOrder == BE {
+			return n.Prefix.String() + ",BE"
+		}
+		return n.Prefix.String()
+	default:
+		return n.Prefix.String()
+	}
+}
+
+func explainOrder(n *layoutNode) string {
+	size := n.valueSize()
+	if n.BitReverse {
+		if size > 1 && n.Order == BE {
+			return "BE,bitreverse"
+		}
+		if size > 1 {
+			return "LE,bitreverse"
+		}
+		return "bitreverse"
+	}
+	switch n.Wire {
+	case wireUint, wireInt, wireFloat, wireComplex:
+		if size > 1 && n.Order == BE {
+			return "BE"
+		}
+		if size > 1 {
+			return "LE"
+		}
+	}
+	return "-"
+}
+
+func explainPresence(n *layoutNode) string {
+	var out []string
+	switch n.Presence {
+	case presenceUint8:
+		out = append(out, "optional(u8)")
+	case presenceUint32:
+		out = append(out, "optional(u32)")
+	}
+	if n.Presence != presenceNone && n.levels() > 1 {
+		out = append(out, "perlevel="+strconv.Itoa(n.levels()))
+	}
+	if n.Extension {
+		out = append(out, "extension")
+	}
+	if len(out) == 0 {
+		return "-"
+	}
+	return strings.Join(out, ",")
+}
+
+// explainOffset formats an offset or a size, which are negative when not fixed.
+func explainOffset(offset int) string {
+	if offset < 0 {
+		return "var"
+	}
+	return strconv.Itoa(offset)
+}
+
+func explainSize(n *layoutNode) string {
+	if !n.isFixed() {
+		return "variable size"
+	}
+	if n.Size == 1 {
+		return "1 byte"
+	}
+	return strconv.Itoa(n.Size) + " bytes"
+}
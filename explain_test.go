@@ -0,0 +1,65 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type explainTestHeader struct {
+	Magic   [4]byte
+	Version uint16 `bin:"big"`
+}
+
+type explainTestMessage struct {
+	Header explainTestHeader
+	Flags  uint8
+	Count  uint32 `bin:"sizeof=Values"`
+	Values []int64
+	Note   *string `bin:"optional"`
+	Extra  uint16  `bin:"binary_extension"`
+}
+
+func TestExplainType(t *testing.T) {
+	out, err := ExplainType(&explainTestMessage{})
+	require.NoError(t, err)
+	assert.Equal(t, `bin.explainTestMessage (Bin encoding, variable size)
+#  FIELD           TYPE                   WIRE    PREFIX        ORDER  PRESENCE       OFFSET  SIZE
+1  Header          bin.explainTestHeader  struct  -             -      -              0       6
+2  Header.Magic    [4]uint8               bytes   fixed=4       -      -              0       4
+3  Header.Version  uint16                 uint    -             BE     -              4       2
+4  Flags           uint8                  uint    -             -      -              6       1
+5  Count           uint32                 uint    -             LE     -              7       4
+6  Values          []int64                slice   sizeof=Count  -      -              11      var
+7  Values[]        int64                  int     -             LE     -              0       8
+8  Note            string                 string  u64           -      optional(u32)  var     var
+9  Extra           uint16                 uint    -             LE     extension      var     2
+`, out)
+
+	out, err = ExplainTypeWithEncoding(explainTestMessage{}, EncodingBorsh)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Header.Version  uint16                 uint    -             LE ")
+	assert.Contains(t, out, "Note            string                 string  u32           -      optional(u8) ")
+
+	out, err = ExplainType(explainTestHeader{})
+	require.NoError(t, err)
+	assert.Contains(t, out, "(Bin encoding, 6 bytes)")
+
+	_, err = ExplainType(struct{ N uintptr }{})
+	assert.EqualError(t, err, `explain: struct { N uintptr }: field "N": unsupported type "uintptr"`)
+}
@@ -0,0 +1,36 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+// Slices and arrays of uint16, uint32 and uint64 are decoded in bulk when
+// their bytes are all in the current segment of the decoder: their size
+// is checked once, and decodeUint16s, decodeUint32s and decodeUint64s
+// convert them without checking each element.
+//
+// By default these functions are plain Go; built with the `binfast` tag
+// (on amd64, arm64 and 386), they use unsafe loads and copies without
+// bounds checks instead, for users who need every nanosecond. Both produce
+// the same values, which the fuzz tests verify.
+
+// contiguous returns the next n bytes of the decoder and skips them, if they
+// are all in its current segment and it isn't tracing (which logs each element).
+func (dec *Decoder) contiguous(n int) ([]byte, bool) {
+	if dec.tracing() || n < 0 || len(dec.data)-dec.pos < n {
+		return nil, false
+	}
+	out := dec.data[dec.pos : dec.pos+n : dec.pos+n]
+	dec.pos += n
+	return out, true
+}
@@ -0,0 +1,91 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build binfast && (amd64 || arm64 || 386)
+// +build binfast
+// +build amd64 arm64 386
+
+package bin
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"unsafe"
+)
+
+// These architectures are little endian and allow unaligned loads. The callers
+// guarantee that src holds at least as many elements as dst.
+
+func decodeUint16s(dst []uint16, src []byte, order binary.ByteOrder) {
+	if len(dst) == 0 {
+		return
+	}
+	p := unsafe.Pointer(&src[0])
+	switch order {
+	case binary.LittleEndian:
+		for i := range dst {
+			dst[i] = *(*uint16)(unsafe.Pointer(uintptr(p) + uintptr(2*i)))
+		}
+	case binary.BigEndian:
+		for i := range dst {
+			dst[i] = bits.ReverseBytes16(*(*uint16)(unsafe.Pointer(uintptr(p) + uintptr(2*i))))
+		}
+	default:
+		for i := range dst {
+			dst[i] = order.Uint16(src[2*i:])
+		}
+	}
+}
+
+func decodeUint32s(dst []uint32, src []byte, order binary.ByteOrder) {
+	if len(dst) == 0 {
+		return
+	}
+	p := unsafe.Pointer(&src[0])
+	switch order {
+	case binary.LittleEndian:
+		for i := range dst {
+			dst[i] = *(*uint32)(unsafe.Pointer(uintptr(p) + uintptr(4*i)))
+		}
+	case binary.BigEndian:
+		for i := range dst {
+			dst[i] = bits.ReverseBytes32(*(*uint32)(unsafe.Pointer(uintptr(p) + uintptr(4*i))))
+		}
+	default:
+		for i := range dst {
+			dst[i] = order.Uint32(src[4*i:])
+		}
+	}
+}
+
+func decodeUint64s(dst []uint64, src []byte, order binary.ByteOrder) {
+	if len(dst) == 0 {
+		return
+	}
+	p := unsafe.Pointer(&src[0])
+	switch order {
+	case binary.LittleEndian:
+		for i := range dst {
+			dst[i] = *(*uint64)(unsafe.Pointer(uintptr(p) + uintptr(8*i)))
+		}
+	case binary.BigEndian:
+		for i := range dst {
+			dst[i] = bits.ReverseBytes64(*(*uint64)(unsafe.Pointer(uintptr(p) + uintptr(8*i))))
+		}
+	default:
+		for i := range dst {
+			dst[i] = order.Uint64(src[8*i:])
+		}
+	}
+}
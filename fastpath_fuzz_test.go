@@ -0,0 +1,118 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package bin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// The fuzz tests check that the bulk decoding of the build (the unsafe one
+// with the `binfast` tag) gives the same values as decoding one element at
+// a time, and that re-encoding them gives back the same bytes, e.g.:
+//
+//	go test -tags binfast -run '^$' -fuzz FuzzDecodeUints
+
+func FuzzDecodeUints(f *testing.F) {
+	f.Add([]byte{}, false)
+	f.Add([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17}, false)
+	f.Add(bytes.Repeat([]byte{0xff, 0x00, 0x80}, 100), true)
+
+	f.Fuzz(func(t *testing.T, data []byte, big bool) {
+		var order binary.ByteOrder = binary.LittleEndian
+		if big {
+			order = binary.BigEndian
+		}
+
+		u16 := make([]uint16, len(data)/2)
+		decodeUint16s(u16, data, order)
+		out := make([]byte, 2*len(u16))
+		for i, v := range u16 {
+			if want := order.Uint16(data[2*i:]); v != want {
+				t.Fatalf("uint16 %d: got %#x, want %#x", i, v, want)
+			}
+			order.PutUint16(out[2*i:], v)
+		}
+		if !bytes.Equal(out, data[:len(out)]) {
+			t.Fatalf("uint16: re-encoded % x, want % x", out, data[:len(out)])
+		}
+
+		u32 := make([]uint32, len(data)/4)
+		decodeUint32s(u32, data, order)
+		out = make([]byte, 4*len(u32))
+		for i, v := range u32 {
+			if want := order.Uint32(data[4*i:]); v != want {
+				t.Fatalf("uint32 %d: got %#x, want %#x", i, v, want)
+			}
+			order.PutUint32(out[4*i:], v)
+		}
+		if !bytes.Equal(out, data[:len(out)]) {
+			t.Fatalf("uint32: re-encoded % x, want % x", out, data[:len(out)])
+		}
+
+		u64 := make([]uint64, len(data)/8)
+		decodeUint64s(u64, data, order)
+		out = make([]byte, 8*len(u64))
+		for i, v := range u64 {
+			if want := order.Uint64(data[8*i:]); v != want {
+				t.Fatalf("uint64 %d: got %#x, want %#x", i, v, want)
+			}
+			order.PutUint64(out[8*i:], v)
+		}
+		if !bytes.Equal(out, data[:len(out)]) {
+			t.Fatalf("uint64: re-encoded % x, want % x", out, data[:len(out)])
+		}
+	})
+}
+
+// FuzzDecodeUintSlices checks that the slices decoded in bulk are the same
+// as the ones decoded from several buffers, one element at a time.
+func FuzzDecodeUintSlices(f *testing.F) {
+	f.Add([]byte{3, 1, 0, 2, 0, 3, 0, 1, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0})
+	f.Add([]byte{0, 0, 0})
+
+	type slices struct {
+		U16 []uint16
+		U32 []uint32 `bin:"big"`
+		U64 []uint64
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var fast slices
+		fastErr := NewBinDecoder(data).Decode(&fast)
+
+		// Split the input after every byte so that no element is contiguous.
+		segments := make([][]byte, len(data))
+		for i := range data {
+			segments[i] = data[i : i+1]
+		}
+		var slow slices
+		slowErr := NewDecoderWithBuffers(segments, EncodingBin).Decode(&slow)
+
+		if (fastErr == nil) != (slowErr == nil) {
+			t.Fatalf("got error %v, want %v", fastErr, slowErr)
+		}
+		if fastErr != nil {
+			return
+		}
+		if !reflect.DeepEqual(fast, slow) {
+			t.Fatalf("got %+v, want %+v", fast, slow)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// A Fault is a kind of corruption injected by NewFaultDecoder.
+type Fault int
+
+const (
+	// FaultTruncate cuts the data short.
+	FaultTruncate Fault = iota
+	// FaultBitFlip flips one bit of the data.
+	FaultBitFlip
+	// FaultInflateLength increases one of the length prefixes read by the decoder.
+	FaultInflateLength
+)
+
+func (f Fault) String() string {
+	switch f {
+	case FaultTruncate:
+		return "truncate"
+	case FaultBitFlip:
+		return "bit flip"
+	case FaultInflateLength:
+		return "inflate length"
+	default:
+		return fmt.Sprintf("Fault(%d)", int(f))
+	}
+}
+
+// NewFaultDecoder returns a decoder over the provided data as if it had been
+// corrupted by the provided fault, to test the handling of malformed data.
+// The seed deterministically chooses where the fault is, so that a failing
+// seed can be replayed:
+//
+//   - FaultTruncate drops the data from an offset on;
+//   - FaultBitFlip flips a bit of the byte at an offset, in a copy of the data;
+//   - FaultInflateLength increases the first length prefix of a slice, map
+//     or byte slice that starts at or after an offset. It's only available
+//     with the faultinject build tag (e.g. go test -tags faultinject), which
+//     keeps the hook it needs out of the decoders of production builds;
+//     NewFaultDecoder panics without it.
+//
+// The offset is in [0, len(data)); empty data is left as is.
+func NewFaultDecoder(data []byte, enc Encoding, fault Fault, seed int64) *Decoder {
+	rng := rand.New(rand.NewSource(seed))
+	offset := 0
+	if len(data) > 0 {
+		offset = rng.Intn(len(data))
+	}
+	switch fault {
+	case FaultTruncate:
+		if len(data) > 0 {
+			data = data[:offset]
+		}
+	case FaultBitFlip:
+		if len(data) > 0 {
+			data = append([]byte(nil), data...)
+			data[offset] ^= 1 << uint(rng.Intn(8))
+		}
+	case FaultInflateLength:
+		if !faultInjection {
+			panic("NewFaultDecoder: FaultInflateLength needs the faultinject build tag")
+		}
+		dec := NewDecoderWithEncoding(data, enc)
+		dec.setLengthFault(&lengthFault{
+			offset: offset,
+			by:     1 + rng.Intn(1<<16),
+		})
+		return dec
+	default:
+		panic(fmt.Sprintf("NewFaultDecoder: unknown fault %s", fault))
+	}
+	return NewDecoderWithEncoding(data, enc)
+}
+
+// lengthFault inflates the first length prefix read at or after offset.
+type lengthFault struct {
+	offset int
+	by     int
+	done   bool
+}
+
+func (f *lengthFault) apply(offset int, length int) int {
+	if f.done || offset < f.offset {
+		return length
+	}
+	f.done = true
+	return length + f.by
+}
@@ -0,0 +1,39 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build faultinject
+// +build faultinject
+
+package bin
+
+// faultInjection is set by the faultinject build tag, which enables
+// the faults of NewFaultDecoder that need a hook in the decoder.
+const faultInjection = true
+
+type decoderFaults struct {
+	lengthFault *lengthFault
+}
+
+func (dec *Decoder) setLengthFault(f *lengthFault) {
+	dec.faults.lengthFault = f
+}
+
+// faultLength returns the length prefix read at offset,
+// inflated by the length fault of the decoder, if any.
+func (dec *Decoder) faultLength(offset int, length int) int {
+	if dec.faults.lengthFault == nil {
+		return length
+	}
+	return dec.faults.lengthFault.apply(offset, length)
+}
@@ -0,0 +1,41 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build faultinject
+// +build faultinject
+
+package bin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFaultDecoder_InflateLength(t *testing.T) {
+	data, err := MarshalBorsh(faultMessage{ID: 1, Items: []uint16{1, 2, 3}, Tail: 9})
+	require.NoError(t, err)
+
+	// The only length prefix is at offset 4.
+	for seed := int64(0); seed < 20; seed++ {
+		dec := NewFaultDecoder(data, EncodingBorsh, FaultInflateLength, seed)
+		var got faultMessage
+		err := dec.Decode(&got)
+		if dec.faults.lengthFault.offset <= 4 {
+			require.Error(t, err, "seed %d", seed)
+		} else {
+			require.NoError(t, err, "seed %d", seed)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"math/bits"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type faultMessage struct {
+	ID    uint32
+	Items []uint16
+	Tail  uint64
+}
+
+func TestNewFaultDecoder(t *testing.T) {
+	data, err := MarshalBorsh(faultMessage{ID: 1, Items: []uint16{1, 2, 3}, Tail: 9})
+	require.NoError(t, err)
+
+	t.Run("truncate", func(t *testing.T) {
+		for seed := int64(0); seed < 20; seed++ {
+			dec := NewFaultDecoder(data, EncodingBorsh, FaultTruncate, seed)
+			assert.Less(t, dec.Remaining(), len(data))
+			var got faultMessage
+			require.Error(t, dec.Decode(&got), "seed %d", seed)
+		}
+	})
+	t.Run("bit flip", func(t *testing.T) {
+		for seed := int64(0); seed < 20; seed++ {
+			corrupted, err := NewFaultDecoder(data, EncodingBorsh, FaultBitFlip, seed).ReadNBytes(len(data))
+			require.NoError(t, err)
+			flipped := 0
+			for i := range data {
+				flipped += bits.OnesCount8(data[i] ^ corrupted[i])
+			}
+			assert.Equal(t, 1, flipped, "seed %d", seed)
+		}
+		original, err := MarshalBorsh(faultMessage{ID: 1, Items: []uint16{1, 2, 3}, Tail: 9})
+		require.NoError(t, err)
+		assert.Equal(t, original, data, "the data must not be modified")
+	})
+	if !faultInjection {
+		// See fault_inject_test.go.
+		t.Run("inflate length", func(t *testing.T) {
+			assert.PanicsWithValue(t, "NewFaultDecoder: FaultInflateLength needs the faultinject build tag", func() {
+				NewFaultDecoder(data, EncodingBorsh, FaultInflateLength, 0)
+			})
+		})
+	}
+	t.Run("deterministic", func(t *testing.T) {
+		a, err := NewFaultDecoder(data, EncodingBorsh, FaultBitFlip, 42).ReadNBytes(len(data))
+		require.NoError(t, err)
+		b, err := NewFaultDecoder(data, EncodingBorsh, FaultBitFlip, 42).ReadNBytes(len(data))
+		require.NoError(t, err)
+		assert.Equal(t, a, b)
+		assert.Equal(t, 0, NewFaultDecoder(nil, EncodingBorsh, FaultTruncate, 42).Remaining())
+	})
+}
@@ -0,0 +1,158 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// Float16 is an IEEE 754 half-precision float, stored as its bits,
+// and encoded as 2 bytes in the byte order of the field.
+type Float16 uint16
+
+var float16Type = reflect.TypeOf(Float16(0))
+
+// NewFloat16 converts f to the nearest half-precision float (ties to even);
+// values out of its range become infinities, and NaNs stay NaNs.
+func NewFloat16(f float32) Float16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int(b>>23) & 0xff
+	mant := b & 0x7fffff
+
+	if exp == 0xff {
+		if mant == 0 {
+			return Float16(sign | 0x7c00)
+		}
+		// Keep the top bits of the payload, and the quiet bit if they're all zero.
+		payload := uint16(mant >> 13)
+		if payload == 0 {
+			payload = 0x200
+		}
+		return Float16(sign | 0x7c00 | payload)
+	}
+
+	e := exp - 127 + 15
+	if e >= 0x1f {
+		return Float16(sign | 0x7c00)
+	}
+	if e <= 0 {
+		// A subnormal half, or zero.
+		if e < -10 {
+			return Float16(sign)
+		}
+		mant |= 0x800000
+		shift := uint(14 - e)
+		h := mant >> shift
+		rem := mant & (1<<shift - 1)
+		half := uint32(1) << (shift - 1)
+		if rem > half || (rem == half && h&1 == 1) {
+			h++
+		}
+		return Float16(sign | uint16(h))
+	}
+
+	h := uint32(e)<<10 | mant>>13
+	rem := mant & 0x1fff
+	// A carry out of the mantissa correctly rounds up to the next exponent, or to infinity.
+	if rem > 0x1000 || (rem == 0x1000 && h&1 == 1) {
+		h++
+	}
+	return Float16(sign | uint16(h))
+}
+
+// Float32 returns the value of f, which a float32 represents exactly.
+func (f Float16) Float32() float32 {
+	sign := uint32(f&0x8000) << 16
+	exp := uint32(f>>10) & 0x1f
+	mant := uint32(f) & 0x3ff
+	switch exp {
+	case 0:
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+		// Normalize the subnormal.
+		e := uint32(127 - 15 + 1)
+		for mant&0x400 == 0 {
+			mant <<= 1
+			e--
+		}
+		mant &= 0x3ff
+		return math.Float32frombits(sign | e<<23 | mant<<13)
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
+
+// IsNaN reports whether f is a NaN.
+func (f Float16) IsNaN() bool {
+	return f&0x7c00 == 0x7c00 && f&0x3ff != 0
+}
+
+// IsInf reports whether f is an infinity.
+func (f Float16) IsInf() bool {
+	return f&0x7fff == 0x7c00
+}
+
+func (f Float16) String() string {
+	return strconv.FormatFloat(float64(f.Float32()), 'g', -1, 32)
+}
+
+func (f Float16) MarshalJSON() ([]byte, error) {
+	if f.IsNaN() || f.IsInf() {
+		return nil, fmt.Errorf("json: unsupported float16 value %s", f)
+	}
+	return []byte(f.String()), nil
+}
+
+func (f *Float16) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var v float32
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*f = NewFloat16(v)
+	return nil
+}
+
+func (f *Float16) UnmarshalWithDecoder(dec *Decoder) error {
+	var order binary.ByteOrder = defaultByteOrder
+	if opt := dec.currentFieldOpt; opt != nil && opt.Order != nil {
+		order = widthOrder(dec.encoding, opt)
+	}
+	value, err := dec.ReadFloat16(order)
+	if err != nil {
+		return err
+	}
+
+	*f = value
+	return nil
+}
+
+func (f Float16) MarshalWithEncoder(enc *Encoder) error {
+	var order binary.ByteOrder = defaultByteOrder
+	if opt := enc.currentFieldOpt; opt != nil && opt.Order != nil {
+		order = widthOrder(enc.encoding, opt)
+	}
+	return enc.WriteFloat16(f, order)
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFloat16(t *testing.T) {
+	tests := []struct {
+		in   float32
+		bits Float16
+		out  float32
+	}{
+		{0, 0x0000, 0},
+		{1, 0x3c00, 1},
+		{-2, 0xc000, -2},
+		{0.5, 0x3800, 0.5},
+		{65504, 0x7bff, 65504},
+		{1.0 / 3, 0x3555, 0.333251953125},
+		// The smallest subnormal, and half of it rounding to zero (to even).
+		{5.9604645e-08, 0x0001, 5.9604645e-08},
+		{2.9802322e-08, 0x0000, 0},
+		{6.1035156e-05, 0x0400, 6.1035156e-05},
+		// Ties round to even.
+		{1 + 1.0/2048, 0x3c00, 1},
+		{1 + 3.0/2048, 0x3c02, 1 + 2.0/1024},
+		// Overflows.
+		{65520, 0x7c00, float32(math.Inf(1))},
+		{1e10, 0x7c00, float32(math.Inf(1))},
+		{float32(math.Inf(-1)), 0xfc00, float32(math.Inf(-1))},
+	}
+	for _, test := range tests {
+		f := NewFloat16(test.in)
+		assert.Equal(t, test.bits, f, "%v", test.in)
+		assert.Equal(t, test.out, f.Float32(), "%v", test.in)
+	}
+
+	nan := NewFloat16(float32(math.NaN()))
+	assert.True(t, nan.IsNaN())
+	assert.True(t, math.IsNaN(float64(nan.Float32())))
+	assert.True(t, Float16(0xfc00).IsInf())
+	assert.False(t, Float16(0x7bff).IsInf())
+
+	// Every half-precision float converts back to itself.
+	for bits := 0; bits <= math.MaxUint16; bits++ {
+		f := Float16(bits)
+		if f.IsNaN() {
+			continue
+		}
+		require.Equal(t, f, NewFloat16(f.Float32()), "%#04x", bits)
+	}
+}
+
+func TestFloat16_Encoding(t *testing.T) {
+	type S struct {
+		A Float16
+		B Float16  `bin:"big"`
+		C *Float16 `bin:"optional"`
+	}
+	one := NewFloat16(1)
+	v := S{A: NewFloat16(1.5), B: NewFloat16(-2), C: &one}
+
+	data, err := MarshalBin(&v)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x3e, 0xc0, 0x00, 1, 0, 0, 0, 0x00, 0x3c}, data)
+
+	var got S
+	require.NoError(t, UnmarshalBin(&got, data))
+	assert.Equal(t, v, got)
+
+	view, err := NewView(data, S{})
+	require.NoError(t, err)
+	b, err := view.Float16("B")
+	require.NoError(t, err)
+	assert.Equal(t, float32(-2), b.Float32())
+
+	out, err := ExplainType(S{})
+	require.NoError(t, err)
+	assert.Contains(t, out, "float")
+
+	t.Run("borsh", func(t *testing.T) {
+		data, err := MarshalBorsh(&S{A: NewFloat16(1.5), B: NewFloat16(1.5)})
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0x00, 0x3e, 0x00, 0x3e, 0}, data)
+
+		_, err = MarshalBorsh(&S{A: Float16(0x7e01)})
+		assert.Error(t, err)
+	})
+
+	t.Run("canonical", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewBinEncoder(buf).WithCanonicalMode().WriteFloat16(0x7d01, binary.LittleEndian))
+		assert.Equal(t, []byte{0x00, 0x7e}, buf.Bytes())
+
+		_, err := NewBinDecoder([]byte{0x01, 0x7d}).WithCanonicalMode().ReadFloat16(binary.LittleEndian)
+		assert.True(t, errors.Is(err, ErrNonCanonicalNaN))
+		_, err = NewBinDecoder([]byte{0x01}).ReadFloat16(binary.LittleEndian)
+		assert.Error(t, err)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		data, err := json.Marshal(NewFloat16(0.25))
+		require.NoError(t, err)
+		assert.Equal(t, "0.25", string(data))
+
+		var f Float16
+		require.NoError(t, json.Unmarshal([]byte("-3.5"), &f))
+		assert.Equal(t, float32(-3.5), f.Float32())
+
+		_, err = json.Marshal(Float16(0x7c00))
+		assert.Error(t, err)
+	})
+}
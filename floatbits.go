@@ -0,0 +1,77 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// Floats are written and read with the exact bits they hold: subnormals,
+// negative zeros, and NaNs with their sign, payload and signaling bit
+// round-trip as is, unless the encoder and the decoder are in canonical mode
+// (see WithCanonicalMode), or the encoding doesn't allow NaNs (Borsh).
+//
+// float32 and complex64 values mustn't go through reflect's Float and
+// SetFloat (or Complex and SetComplex), which convert them to float64 and
+// back: most CPUs quiet signaling NaNs on the way.
+
+// float32Value returns the float32 value of rv, whose kind is Float32.
+func float32Value(rv reflect.Value) float32 {
+	if p, ok := addrOf(rv); ok {
+		return *(*float32)(p)
+	}
+	return float32(rv.Float())
+}
+
+// setFloat32 sets rv, whose kind is Float32, to f.
+func setFloat32(rv reflect.Value, f float32) {
+	if rv.CanSet() {
+		*(*float32)(unsafe.Pointer(rv.UnsafeAddr())) = f
+		return
+	}
+	rv.SetFloat(float64(f))
+}
+
+// complex64Value returns the complex64 value of rv, whose kind is Complex64.
+func complex64Value(rv reflect.Value) complex64 {
+	if p, ok := addrOf(rv); ok {
+		return *(*complex64)(p)
+	}
+	return complex64(rv.Complex())
+}
+
+// setComplex64 sets rv, whose kind is Complex64, to c.
+func setComplex64(rv reflect.Value, c complex64) {
+	if rv.CanSet() {
+		*(*complex64)(unsafe.Pointer(rv.UnsafeAddr())) = c
+		return
+	}
+	rv.SetComplex(complex128(c))
+}
+
+// addrOf returns the address of rv, or of a copy of it
+// if it's not addressable.
+func addrOf(rv reflect.Value) (unsafe.Pointer, bool) {
+	if !rv.CanAddr() {
+		if !rv.CanInterface() {
+			return nil, false
+		}
+		c := reflect.New(rv.Type()).Elem()
+		c.Set(rv)
+		rv = c
+	}
+	return unsafe.Pointer(rv.UnsafeAddr()), true
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type floatBitsRecord struct {
+	F32 float32
+	F64 float64
+	C64 complex64
+	S32 []float32
+}
+
+var (
+	float32Patterns = []uint32{
+		0x7f800001, // signaling NaN
+		0xffc12345, // negative quiet NaN with a payload
+		0x00000001, // smallest subnormal
+		0x807fffff, // largest negative subnormal
+		0x80000000, // negative zero
+	}
+	float64Patterns = []uint64{
+		0x7ff0000000000001,
+		0xfff8123456789abc,
+		0x0000000000000001,
+		0x800fffffffffffff,
+		0x8000000000000000,
+	}
+)
+
+func TestFloatBits_RoundTrip(t *testing.T) {
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		for i := range float32Patterns {
+			f32 := math.Float32frombits(float32Patterns[i])
+			f64 := math.Float64frombits(float64Patterns[i])
+			if enc.IsBorsh() && (math.IsNaN(float64(f32)) || math.IsNaN(f64)) {
+				// Borsh doesn't allow NaNs.
+				continue
+			}
+			in := floatBitsRecord{F32: f32, F64: f64, C64: complex(f32, f32), S32: []float32{f32}}
+
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&in))
+			var out floatBitsRecord
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out))
+
+			assert.Equal(t, float32Patterns[i], math.Float32bits(out.F32), "%s %#x", enc, float32Patterns[i])
+			assert.Equal(t, float64Patterns[i], math.Float64bits(out.F64), "%s %#x", enc, float64Patterns[i])
+			assert.Equal(t, float32Patterns[i], math.Float32bits(real(out.C64)), "%s %#x", enc, float32Patterns[i])
+			assert.Equal(t, float32Patterns[i], math.Float32bits(imag(out.C64)), "%s %#x", enc, float32Patterns[i])
+			assert.Equal(t, float32Patterns[i], math.Float32bits(out.S32[0]), "%s %#x", enc, float32Patterns[i])
+		}
+	}
+}
+
+func TestFloatBits_TopLevel(t *testing.T) {
+	sNaN := math.Float32frombits(0x7f800001)
+	data, err := MarshalBin(sNaN)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x01, 0x00, 0x80, 0x7f}, data)
+
+	var out float32
+	require.NoError(t, UnmarshalBin(&out, data))
+	assert.Equal(t, uint32(0x7f800001), math.Float32bits(out))
+}
+
+func TestFloatBits_CanonicalMode(t *testing.T) {
+	in := floatBitsRecord{F32: math.Float32frombits(0x7f800001), F64: math.Float64frombits(0x7ff0000000000001)}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).WithCanonicalMode().Encode(&in))
+	var out floatBitsRecord
+	require.NoError(t, NewBinDecoder(buf.Bytes()).WithCanonicalMode().Decode(&out))
+	assert.Equal(t, CanonicalNaN32, math.Float32bits(out.F32))
+	assert.Equal(t, CanonicalNaN64, math.Float64bits(out.F64))
+
+	// Payloads are kept outside of canonical mode, and rejected by canonical decoders.
+	buf.Reset()
+	require.NoError(t, NewBinEncoder(buf).Encode(&in))
+	err := NewBinDecoder(buf.Bytes()).WithCanonicalMode().Decode(&out)
+	assert.True(t, errors.Is(err, ErrNonCanonicalNaN), err)
+}
@@ -0,0 +1,196 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// MigrateGob re-encodes a stream of gob-encoded values, e.g. a legacy gob
+// archive: it decodes the values of src one after the other, until its end,
+// each into a new value returned by newValue (a pointer to a value of the
+// matching type), and writes them to dst one after the other, with the
+// provided encoding. It returns the number of migrated values.
+//
+// Check the type with GobCompatibility first: the values it can't
+// represent are not migrated, or not faithfully.
+//
+//	n, err := bin.MigrateGob(out, archive, bin.EncodingBorsh, func() interface{} { return new(Account) })
+func MigrateGob(dst io.Writer, src io.Reader, enc Encoding, newValue func() interface{}) (int, error) {
+	if !isValidEncoding(enc) {
+		return 0, fmt.Errorf("migrate gob: invalid encoding %d", enc)
+	}
+	gobDec := gob.NewDecoder(src)
+	encoder := NewEncoderWithEncoding(dst, enc)
+	n := 0
+	for ; ; n++ {
+		v := newValue()
+		if err := gobDec.Decode(v); err != nil {
+			if err == io.EOF {
+				return n, nil
+			}
+			return n, fmt.Errorf("migrate gob: value %d: %w", n, err)
+		}
+		if err := encoder.Encode(v); err != nil {
+			return n, fmt.Errorf("migrate gob: value %d: %w", n, err)
+		}
+	}
+}
+
+// A GobIncompatibility is a part of a type that gob encodes,
+// but that the encodings of this package can't represent faithfully.
+type GobIncompatibility struct {
+	// Field is the path of the incompatible part,
+	// e.g. "Items[].Meta", or "" for the type itself.
+	Field  string
+	Type   reflect.Type
+	Reason string
+}
+
+func (i GobIncompatibility) String() string {
+	if i.Field == "" {
+		return fmt.Sprintf("%s: %s", i.Type, i.Reason)
+	}
+	return fmt.Sprintf("%s (%s): %s", i.Field, i.Type, i.Reason)
+}
+
+var (
+	gobEncoderType      = reflect.TypeOf((*gob.GobEncoder)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	binMarshalerStdType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+)
+
+// GobCompatibility reports the parts of the type of `v` (a value or a pointer
+// to a value) that gob encodes but that the provided encoding can't represent
+// faithfully, for MigrateGob: interfaces, int and uint without a `width=N` tag,
+// exported fields tagged `bin:"-"`, func and chan fields that are not, nil
+// pointers outside of optional fields, and types with a custom gob encoding
+// but no MarshalWithEncoder method.
+// It returns nil if the type is compatible.
+func GobCompatibility(v interface{}, enc Encoding) []GobIncompatibility {
+	rt := reflect.TypeOf(v)
+	if rt == nil {
+		return nil
+	}
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	c := &gobChecker{encoding: enc, visiting: map[reflect.Type]bool{}}
+	c.check("", rt, false)
+	return c.issues
+}
+
+type gobChecker struct {
+	encoding Encoding
+	visiting map[reflect.Type]bool
+	issues   []GobIncompatibility
+}
+
+func (c *gobChecker) report(path string, rt reflect.Type, reason string) {
+	c.issues = append(c.issues, GobIncompatibility{Field: path, Type: rt, Reason: reason})
+}
+
+// check checks a type at the provided path;
+// optional is whether the value is an optional field.
+func (c *gobChecker) check(path string, rt reflect.Type, optional bool) {
+	if rt.Implements(marshalableType) || reflect.PtrTo(rt).Implements(marshalableType) || rt == timeType || rt == bigIntType || rt == ipType {
+		return
+	}
+	if implementsAny(rt, gobEncoderType, binMarshalerStdType, textMarshalerType) {
+		c.report(path, rt, "has a custom gob encoding but no MarshalWithEncoder method; only its exported fields are encoded")
+		return
+	}
+
+	switch rt.Kind() {
+	case reflect.Ptr:
+		if rt == bigIntPtrType {
+			return
+		}
+		if !optional {
+			c.report(path, rt, "nil pointers are only represented in optional fields")
+		}
+		c.check(path, rt.Elem(), false)
+	case reflect.Interface:
+		c.report(path, rt, "interface values are not encoded")
+	case reflect.Int, reflect.Uint:
+		c.report(path, rt, "has no fixed size; tag the field `bin:\"width=8\"`, or use a sized integer type")
+	case reflect.Uintptr:
+		c.report(path, rt, "has no fixed size; use a sized integer type")
+	case reflect.Slice, reflect.Array:
+		if rt.Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		c.check(path+"[]", rt.Elem(), false)
+	case reflect.Map:
+		c.check(path+"{key}", rt.Key(), false)
+		c.check(path+"{value}", rt.Elem(), false)
+	case reflect.Struct:
+		if c.visiting[rt] {
+			return
+		}
+		c.visiting[rt] = true
+		defer delete(c.visiting, rt)
+
+		for i := 0; i < rt.NumField(); i++ {
+			structField := rt.Field(i)
+			if structField.PkgPath != "" {
+				// Gob doesn't encode them either.
+				continue
+			}
+			name := structField.Name
+			if path != "" {
+				name = path + "." + name
+			}
+			fieldTag := parseFieldTag(structField.Tag)
+			if fieldTag.Width > 0 && isPlatformIntKind(structField.Type.Kind()) {
+				continue
+			}
+			if isGobIgnored(structField.Type) {
+				if !fieldTag.Skip {
+					c.report(name, structField.Type, "can't be encoded; tag it `bin:\"-\"`, gob ignores it")
+				}
+				continue
+			}
+			if fieldTag.Skip {
+				c.report(name, structField.Type, "skipped by its `bin:\"-\"` tag")
+				continue
+			}
+			optional := fieldTag.Option || (fieldTag.COption && c.encoding.IsBorsh())
+			c.check(name, structField.Type, optional)
+		}
+	}
+}
+
+func implementsAny(rt reflect.Type, ifaces ...reflect.Type) bool {
+	for _, iface := range ifaces {
+		if rt.Implements(iface) || reflect.PtrTo(rt).Implements(iface) {
+			return true
+		}
+	}
+	return false
+}
+
+// isGobIgnored returns whether gob ignores the fields of the provided type.
+func isGobIgnored(rt reflect.Type) bool {
+	switch rt.Kind() {
+	case reflect.Chan, reflect.Func:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type gobAccount struct {
+	ID       uint64
+	Owner    string
+	Balances map[string]int64
+	Created  time.Time
+	Parent   *gobAccount `bin:"optional"`
+	Notify   func()      `bin:"-"`
+}
+
+func TestMigrateGob(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	in := []gobAccount{
+		{ID: 1, Owner: "alice", Balances: map[string]int64{"usd": 10, "eur": -3}, Created: created},
+		{ID: 2, Owner: "bob", Parent: &gobAccount{ID: 1, Owner: "alice", Created: created}},
+	}
+	archive := new(bytes.Buffer)
+	gobEnc := gob.NewEncoder(archive)
+	for _, account := range in {
+		require.NoError(t, gobEnc.Encode(account))
+	}
+	assert.Empty(t, GobCompatibility(gobAccount{}, EncodingBorsh))
+
+	out := new(bytes.Buffer)
+	n, err := MigrateGob(out, archive, EncodingBorsh, func() interface{} { return new(gobAccount) })
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	dec := NewBorshDecoder(out.Bytes())
+	for _, expected := range in {
+		var account gobAccount
+		require.NoError(t, dec.Decode(&account))
+		assert.Equal(t, expected.ID, account.ID)
+		assert.Equal(t, expected.Owner, account.Owner)
+		assert.Equal(t, len(expected.Balances), len(account.Balances))
+		for k, v := range expected.Balances {
+			assert.Equal(t, v, account.Balances[k])
+		}
+		assert.True(t, expected.Created.Equal(account.Created))
+		assert.Equal(t, expected.Parent != nil, account.Parent != nil)
+	}
+	assert.Equal(t, 0, dec.Remaining())
+
+	_, err = MigrateGob(new(bytes.Buffer), bytes.NewReader([]byte{0xff}), EncodingBin, func() interface{} { return new(gobAccount) })
+	assert.Error(t, err)
+}
+
+func TestGobCompatibility(t *testing.T) {
+	type legacy struct {
+		Count   int
+		Total   int `bin:"width=8"`
+		Extra   interface{}
+		Cache   []byte `bin:"-"`
+		Next    *legacy
+		Items   []*uint32
+		Lookup  map[string]uint
+		Done    chan bool
+		hidden  int
+		Skipped int `bin:"-"`
+	}
+	issues := GobCompatibility(&legacy{}, EncodingBin)
+	var lines []string
+	for _, issue := range issues {
+		lines = append(lines, issue.String())
+	}
+	assert.Equal(t, []string{
+		"Count (int): has no fixed size; tag the field `bin:\"width=8\"`, or use a sized integer type",
+		"Extra (interface {}): interface values are not encoded",
+		"Cache ([]uint8): skipped by its `bin:\"-\"` tag",
+		"Next (*bin.legacy): nil pointers are only represented in optional fields",
+		"Items[] (*uint32): nil pointers are only represented in optional fields",
+		"Lookup{value} (uint): has no fixed size; tag the field `bin:\"width=8\"`, or use a sized integer type",
+		"Done (chan bool): can't be encoded; tag it `bin:\"-\"`, gob ignores it",
+		"Skipped (int): skipped by its `bin:\"-\"` tag",
+	}, lines)
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ErrLayoutMismatch is returned by Handshake when the peer doesn't
+// have the same layout for one of the message types.
+var ErrLayoutMismatch = errors.New("layout mismatch")
+
+// Handshake sends the TypeIDFromLayout of each of the provided message types
+// (values or pointers to values) to the peer and checks that the peer sent
+// the same ones, in the same order, so that a connection fails fast when
+// the two sides don't agree on the encoding of their messages:
+//
+//	if err := bin.Handshake(conn, Ping{}, Transfer{}); err != nil {
+//		conn.Close()
+//		return err
+//	}
+//
+// Both sides must call it at the same point of the connection, with the same
+// types. The TypeIDs are sent while the peer's ones are read, so it works over
+// unbuffered connections (e.g. net.Pipe). On a mismatch, the returned error wraps
+// ErrLayoutMismatch.
+func Handshake(rw io.ReadWriter, types ...interface{}) error {
+	return HandshakeWithEncoding(rw, EncodingBin, types...)
+}
+
+// HandshakeWithEncoding is like Handshake, for messages encoded with the provided encoding.
+func HandshakeWithEncoding(rw io.ReadWriter, enc Encoding, types ...interface{}) error {
+	ids := make([]TypeID, len(types))
+	w := NewWriter().U32LE(uint32(len(types)))
+	for i, typ := range types {
+		id, err := TypeIDFromLayoutWithEncoding(typ, enc)
+		if err != nil {
+			return fmt.Errorf("handshake: %w", err)
+		}
+		ids[i] = id
+		w.Bytes(id[:])
+	}
+	msg, err := w.Result()
+	if err != nil {
+		return fmt.Errorf("handshake: %w", err)
+	}
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := rw.Write(msg)
+		written <- err
+	}()
+	if err := readHandshake(rw, ids, types); err != nil {
+		return err
+	}
+	if err := <-written; err != nil {
+		return fmt.Errorf("handshake: %w", err)
+	}
+	return nil
+}
+
+func readHandshake(r io.Reader, ids []TypeID, types []interface{}) error {
+	header := make([]byte, TypeSize.Uint32)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("handshake: %w", err)
+	}
+	count := int(LE.Uint32(header))
+	if count != len(ids) {
+		return fmt.Errorf("handshake: %w: peer has %d message types, expected %d", ErrLayoutMismatch, count, len(ids))
+	}
+	remote := make([]byte, count*len(TypeID{}))
+	if _, err := io.ReadFull(r, remote); err != nil {
+		return fmt.Errorf("handshake: %w", err)
+	}
+	for i, id := range ids {
+		if !id.Equal(remote[i*len(id) : (i+1)*len(id)]) {
+			return fmt.Errorf("handshake: %w: message type #%d (%s): local %x, remote %x",
+				ErrLayoutMismatch, i, reflect.TypeOf(types[i]), id[:], remote[i*len(id):(i+1)*len(id)])
+		}
+	}
+	return nil
+}
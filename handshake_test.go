@@ -0,0 +1,65 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func handshakeBoth(local, remote []interface{}) (error, error) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	remoteErr := make(chan error, 1)
+	go func() {
+		remoteErr <- Handshake(b, remote...)
+	}()
+	err := Handshake(a, local...)
+	return err, <-remoteErr
+}
+
+func TestHandshake(t *testing.T) {
+	localErr, remoteErr := handshakeBoth(
+		[]interface{}{layoutIDTransfer{}, uint32(0)},
+		[]interface{}{&layoutIDPayment{}, uint32(0)},
+	)
+	require.NoError(t, localErr)
+	require.NoError(t, remoteErr)
+}
+
+func TestHandshake_Mismatch(t *testing.T) {
+	localErr, remoteErr := handshakeBoth(
+		[]interface{}{layoutIDTransfer{}, uint32(0)},
+		[]interface{}{layoutIDTransfer{}, uint64(0)},
+	)
+	require.Error(t, localErr)
+	assert.True(t, errors.Is(localErr, ErrLayoutMismatch))
+	assert.Contains(t, localErr.Error(), "message type #1 (uint32)")
+	assert.True(t, errors.Is(remoteErr, ErrLayoutMismatch))
+
+	localErr, remoteErr = handshakeBoth(
+		[]interface{}{layoutIDTransfer{}},
+		[]interface{}{layoutIDTransfer{}, uint64(0)},
+	)
+	assert.True(t, errors.Is(localErr, ErrLayoutMismatch))
+	assert.Contains(t, localErr.Error(), "peer has 2 message types, expected 1")
+	require.Error(t, remoteErr)
+}
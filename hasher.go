@@ -0,0 +1,71 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"hash"
+)
+
+// HashEncoder is an Encoder that feeds the encoded bytes straight
+// into a hash.Hash, without retaining them.
+//
+// Since it is a regular Encoder, encoded values and manual writes
+// (e.g. from custom marshalers, or a domain separator written before
+// the value) can be freely interleaved.
+type HashEncoder struct {
+	*Encoder
+	hash hash.Hash
+}
+
+// NewHashEncoder creates a HashEncoder that encodes with the Bin encoding.
+func NewHashEncoder(h hash.Hash) *HashEncoder {
+	return NewHashEncoderWithEncoding(h, EncodingBin)
+}
+
+// NewHashEncoderWithEncoding creates a HashEncoder that encodes with the provided encoding.
+func NewHashEncoderWithEncoding(h hash.Hash, enc Encoding) *HashEncoder {
+	return &HashEncoder{
+		Encoder: NewEncoderWithEncoding(h, enc),
+		hash:    h,
+	}
+}
+
+// Sum appends the digest of everything written so far to b and returns
+// the resulting slice. It does not change the underlying hash state.
+func (he *HashEncoder) Sum(b []byte) []byte {
+	return he.hash.Sum(b)
+}
+
+// Digest returns the digest of everything written so far.
+func (he *HashEncoder) Digest() []byte {
+	return he.hash.Sum(nil)
+}
+
+// Reset resets the underlying hash and the count of written bytes.
+func (he *HashEncoder) Reset() {
+	he.hash.Reset()
+	he.count = 0
+}
+
+// EncodeAndHash encodes v with the provided encoding directly into h,
+// and returns the resulting digest.
+func EncodeAndHash(h hash.Hash, enc Encoding, v interface{}) ([]byte, error) {
+	he := NewHashEncoderWithEncoding(h, enc)
+	if err := he.Encode(v); err != nil {
+		return nil, fmt.Errorf("encode %T: %w", v, err)
+	}
+	return he.Digest(), nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashEncoder(t *testing.T) {
+	value := struct {
+		A uint16
+		B string
+	}{A: 7, B: "hash"}
+
+	encoded, err := MarshalBorsh(value)
+	require.NoError(t, err)
+
+	he := NewHashEncoderWithEncoding(sha256.New(), EncodingBorsh)
+	require.NoError(t, he.WriteBytes([]byte("prefix:"), false))
+	require.NoError(t, he.Encode(value))
+
+	expected := sha256.Sum256(append([]byte("prefix:"), encoded...))
+	assert.Equal(t, expected[:], he.Digest())
+	assert.Equal(t, len("prefix:")+len(encoded), he.Written())
+
+	he.Reset()
+	assert.Equal(t, 0, he.Written())
+
+	digest, err := EncodeAndHash(sha256.New(), EncodingBorsh, value)
+	require.NoError(t, err)
+	expected = sha256.Sum256(encoded)
+	assert.Equal(t, expected[:], digest)
+}
@@ -0,0 +1,176 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// HeapRefSize is the size of the reference that replaces a string
+// or a byte slice in the fixed section of a message encoded with a string heap.
+const HeapRefSize = 8
+
+// WithStringHeap makes the encoder write the contents of strings and byte slices
+// into a heap that trails each encoded value, replacing them in the main section
+// with a fixed-size (offset, length) reference (two little endian uint32, the offset
+// being relative to the start of the heap).
+//
+// This way, records that only have variable-length data in strings and byte
+// slices have a fixed stride and can be scanned without decoding them.
+// The encoded value is laid out as:
+//
+//	[u32 fixed section length][u32 heap length][fixed section][heap]
+//
+// Values encoded with a string heap must be decoded by a decoder configured with WithStringHeap.
+func (e *Encoder) WithStringHeap() *Encoder {
+	e.stringHeap = true
+	return e
+}
+
+// WithStringHeap makes the decoder read values encoded by an encoder
+// configured with WithStringHeap.
+func (dec *Decoder) WithStringHeap() *Decoder {
+	dec.stringHeap = true
+	return dec
+}
+
+// encodeWithHeap encodes a value with the provided function, moving
+// the contents of strings and byte slices to the heap.
+func (e *Encoder) encodeWithHeap(encode func() error) error {
+	output, count := e.output, e.count
+	fixed := new(bytes.Buffer)
+	e.output = fixed
+	e.heap = new(bytes.Buffer)
+	err := encode()
+	heap := e.heap
+	e.output, e.count, e.heap = output, count, nil
+	if err != nil {
+		return err
+	}
+
+	if uint64(fixed.Len()) > 0xFFFF_FFFF || uint64(heap.Len()) > 0xFFFF_FFFF {
+		return fmt.Errorf("string heap: sections too large: %d and %d bytes", fixed.Len(), heap.Len())
+	}
+	if err := e.WriteUint32(uint32(fixed.Len()), LE); err != nil {
+		return err
+	}
+	if err := e.WriteUint32(uint32(heap.Len()), LE); err != nil {
+		return err
+	}
+	if err := e.toWriter(fixed.Bytes()); err != nil {
+		return err
+	}
+	return e.toWriter(heap.Bytes())
+}
+
+// writeHeapRef appends b to the heap, and writes the reference to it.
+func (e *Encoder) writeHeapRef(b []byte) error {
+	if e.tracing() {
+		e.tlog().Debug("encode: write heap reference", logInt("offset", e.heap.Len()), logInt("len", len(b)))
+	}
+	if err := e.WriteUint32(uint32(e.heap.Len()), LE); err != nil {
+		return err
+	}
+	if err := e.WriteUint32(uint32(len(b)), LE); err != nil {
+		return err
+	}
+	// The heap and the section lengths are written after the fixed section.
+	if err := e.checkMaxSize(8 + e.heap.Len() + len(b)); err != nil {
+		return err
+	}
+	e.heap.Write(b)
+	return nil
+}
+
+// encodeHeapBytes handles the byte slices when the string heap is enabled;
+// strings are handled by the string writing methods.
+func (e *Encoder) encodeHeapBytes(rv reflect.Value) (handled bool, err error) {
+	if e.heap == nil || rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() != reflect.Uint8 {
+		return false, nil
+	}
+	return true, e.writeHeapRef(rv.Bytes())
+}
+
+// decodeWithHeap decodes a value with the provided function, reading
+// the contents of strings and byte slices from the heap.
+func (dec *Decoder) decodeWithHeap(decode func() error) error {
+	fixedLen, err := dec.ReadUint32(LE)
+	if err != nil {
+		return fmt.Errorf("string heap: fixed section length: %w", err)
+	}
+	heapLen, err := dec.ReadUint32(LE)
+	if err != nil {
+		return fmt.Errorf("string heap: heap length: %w", err)
+	}
+	if uint64(dec.Remaining()) < uint64(fixedLen)+uint64(heapLen) {
+		return fmt.Errorf("string heap: sections of %d and %d bytes, remaining [%d]", fixedLen, heapLen, dec.Remaining())
+	}
+	// The heap is located first, so that the fixed section can be decoded in place.
+	sections, err := dec.Peek(int(fixedLen) + int(heapLen))
+	if err != nil {
+		return err
+	}
+	start := dec.Position()
+	dec.heap = sections[fixedLen:]
+	if dec.heap == nil {
+		// A nil heap means that the heap is disabled.
+		dec.heap = []byte{}
+	}
+	err = decode()
+	dec.heap = nil
+	if err != nil {
+		return err
+	}
+	if read := dec.Position() - start; read != uint(fixedLen) {
+		return fmt.Errorf("string heap: read %d bytes of a fixed section of %d bytes", read, fixedLen)
+	}
+	return dec.SkipBytes(uint(heapLen))
+}
+
+// readHeapRef reads a reference to the heap, and returns the referenced bytes.
+func (dec *Decoder) readHeapRef() ([]byte, error) {
+	offset, err := dec.ReadUint32(LE)
+	if err != nil {
+		return nil, fmt.Errorf("heap reference offset: %w", err)
+	}
+	length, err := dec.ReadUint32(LE)
+	if err != nil {
+		return nil, fmt.Errorf("heap reference length: %w", err)
+	}
+	if uint64(offset)+uint64(length) > uint64(len(dec.heap)) {
+		return nil, fmt.Errorf("heap reference [%d:%d] out of heap of %d bytes", offset, uint64(offset)+uint64(length), len(dec.heap))
+	}
+	out := dec.heap[offset : offset+length]
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read heap reference", logUint32("offset", offset), logUint32("len", length))
+	}
+	return out, nil
+}
+
+// decodeHeapBytes handles the byte slices when the string heap is enabled;
+// strings are handled by the string reading methods.
+func (dec *Decoder) decodeHeapBytes(rv reflect.Value) (handled bool, err error) {
+	if dec.heap == nil || rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() != reflect.Uint8 {
+		return false, nil
+	}
+	b, err := dec.readHeapRef()
+	if err != nil {
+		return true, err
+	}
+	rv.SetBytes(append([]byte{}, b...))
+	return true, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type heapTestRecord struct {
+	ID   uint32
+	Name string
+	Data []byte
+	Memo SafeString
+}
+
+func TestStringHeap(t *testing.T) {
+	records := []heapTestRecord{
+		{ID: 1, Name: "alice", Data: []byte{1, 2}, Memo: "x"},
+		{ID: 2, Name: "bob", Data: nil, Memo: ""},
+		{ID: 3, Name: "carol", Data: []byte{3}, Memo: "yz"},
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).WithStringHeap().Encode(records))
+	data := buf.Bytes()
+
+	// Each record has a fixed stride in the fixed section.
+	stride := 4 + 3*HeapRefSize
+	assert.Equal(t, []byte{byte(1 + 3*stride), 0, 0, 0}, data[:4])
+	assert.Equal(t, []byte{19, 0, 0, 0}, data[4:8])
+	fixed := data[8 : 8+1+3*stride]
+	assert.Equal(t, byte(3), fixed[0])
+	second := fixed[1+stride : 1+2*stride]
+	assert.Equal(t, []byte{
+		2, 0, 0, 0,
+		8, 0, 0, 0, 3, 0, 0, 0, // "bob"
+		11, 0, 0, 0, 0, 0, 0, 0, // empty data
+		11, 0, 0, 0, 0, 0, 0, 0, // empty memo
+	}, second)
+	assert.Equal(t, "alice\x01\x02xbobcarol\x03yz", string(data[8+len(fixed):]))
+
+	var out []heapTestRecord
+	dec := NewBinDecoder(append(data, 0xff)).WithStringHeap()
+	require.NoError(t, dec.Decode(&out))
+	records[1].Data = []byte{}
+	assert.Equal(t, records, out)
+	assert.Equal(t, 1, dec.Remaining())
+
+	for _, enc := range []Encoding{EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).WithStringHeap().Encode(records[0]))
+		var out heapTestRecord
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).WithStringHeap().Decode(&out))
+		assert.Equal(t, records[0], out, enc.String())
+	}
+
+	// Without the heap, the references are read as regular data.
+	err := NewBinDecoder(data).Decode(&out)
+	assert.Error(t, err)
+
+	bad := append([]byte(nil), data...)
+	bad[8+1+4] = 200 // offset of the first name
+	err = NewBinDecoder(bad).WithStringHeap().Decode(&out)
+	assert.EqualError(t, err, `error while decoding element 0: error while decoding "Name" field: heap reference [200:205] out of heap of 19 bytes`)
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type inlineHeader struct {
+	Version uint8
+	Count   uint16 `bin:"sizeof=Items"`
+}
+
+type inlineFooter struct {
+	Checksum uint32
+}
+
+type inlineMessage struct {
+	inlineHeader `bin:"inline"`
+	Items        []uint32
+	Footer       inlineFooter `bin:"inline"`
+}
+
+func TestInline(t *testing.T) {
+	in := inlineMessage{
+		inlineHeader: inlineHeader{Version: 1},
+		Items:        []uint32{5, 6},
+		Footer:       inlineFooter{Checksum: 0xAABBCCDD},
+	}
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			data, err := MarshalAppend(nil, &in, enc)
+			require.NoError(t, err)
+			// The header of the unexported embedded type is encoded, and
+			// its counter is populated from the slice of the parent.
+			assert.Equal(t, []byte{
+				1, 2, 0,
+				5, 0, 0, 0, 6, 0, 0, 0,
+				0xDD, 0xCC, 0xBB, 0xAA,
+			}, data)
+
+			var out inlineMessage
+			require.NoError(t, NewDecoderWithEncoding(data, enc).Decode(&out))
+			expected := in
+			expected.Count = 2
+			assert.Equal(t, expected, out)
+
+			require.NoError(t, ConformsWithEncoding(data, enc, inlineMessage{}))
+			res, err := QueryWithEncoding(data, enc, inlineMessage{}, "Checksum")
+			require.NoError(t, err)
+			assert.Equal(t, uint32(0xAABBCCDD), res.Value)
+		})
+	}
+
+	explained, err := ExplainType(inlineMessage{})
+	require.NoError(t, err)
+	assert.Contains(t, explained, "Checksum")
+	assert.NotContains(t, explained, "Footer")
+	require.NoError(t, Precompile(inlineMessage{}))
+}
+
+func TestInline_Errors(t *testing.T) {
+	type notStruct struct {
+		N uint32 `bin:"inline"`
+	}
+	assert.EqualError(t, Precompile(notStruct{}), "precompile: bin.notStruct: field \"N\": the inline tag only applies to structs without a marshaler, got uint32")
+
+	type withOrder struct {
+		Footer inlineFooter `bin:"inline big"`
+	}
+	assert.EqualError(t, Precompile(withOrder{}), "precompile: bin.withOrder: field \"Footer\": the inline tag can't be combined with other tags")
+
+	type duplicate struct {
+		Checksum uint32
+		Footer   inlineFooter `bin:"inline"`
+	}
+	assert.EqualError(t, Precompile(duplicate{}), "precompile: bin.duplicate: field \"Checksum\": duplicate field name once inline fields are flattened")
+}
@@ -20,12 +20,27 @@ package bin
 import (
 	"bytes"
 	"fmt"
+	"reflect"
 )
 
 type BinaryMarshaler interface {
 	MarshalWithEncoder(encoder *Encoder) error
 }
 
+// binaryMarshaler returns the BinaryMarshaler of the provided value. Like with
+// encoding/json, when only the pointer type implements it, the marshaler of
+// the value's address is used if the value is addressable (e.g. a slice element).
+func binaryMarshaler(rv reflect.Value) (BinaryMarshaler, bool) {
+	if marshaler, ok := rv.Interface().(BinaryMarshaler); ok {
+		return marshaler, true
+	}
+	if rv.Kind() != reflect.Ptr && rv.CanAddr() {
+		marshaler, ok := rv.Addr().Interface().(BinaryMarshaler)
+		return marshaler, ok
+	}
+	return nil, false
+}
+
 type BinaryUnmarshaler interface {
 	UnmarshalWithDecoder(decoder *Decoder) error
 }
@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type Example struct {
@@ -149,3 +150,28 @@ func TestUnmarshalWithDecoder(t *testing.T) {
 		}
 	}
 }
+
+type ptrMarshaled struct {
+	V uint8
+}
+
+func (p *ptrMarshaled) MarshalWithEncoder(encoder *Encoder) error {
+	return encoder.WriteUint8(p.V + 100)
+}
+
+func TestEncode_PointerReceiverMarshalerOnSliceElements(t *testing.T) {
+	type holder struct {
+		Items []ptrMarshaled
+	}
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode([]ptrMarshaled{{1}, {2}}))
+			assert.Equal(t, []byte{101, 102}, buf.Bytes()[buf.Len()-2:])
+
+			buf.Reset()
+			require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&holder{Items: []ptrMarshaled{{3}}}))
+			assert.Equal(t, byte(103), buf.Bytes()[buf.Len()-1])
+		})
+	}
+}
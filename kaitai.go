@@ -0,0 +1,608 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// KaitaiStruct generates a Kaitai Struct (https://kaitai.io) definition
+// of the values of the type of `v` (a value or a pointer to a value),
+// as encoded by the provided encoding.
+//
+// The output is a .ksy YAML document that can be loaded in the Kaitai
+// Web IDE, compiled with kaitai-struct-compiler, or converted to other
+// binary-analysis tools. Types with custom encoders (other than the ones
+// of this package) and UTF-8 runes can't be described and return an error.
+func KaitaiStruct(v interface{}, enc Encoding) (string, error) {
+	rt := reflect.TypeOf(v)
+	if rt == nil {
+		return "", fmt.Errorf("kaitai: nil type")
+	}
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if !isValidEncoding(enc) {
+		return "", fmt.Errorf("kaitai: invalid encoding %d", enc)
+	}
+	layout, err := describeType(rt, enc)
+	if err != nil {
+		return "", fmt.Errorf("kaitai: %s: %w", rt, err)
+	}
+
+	gen := &kaitaiGen{
+		names:   make(map[reflect.Type]string),
+		used:    make(map[string]bool),
+		helpers: make(map[string]bool),
+	}
+	rootID := kaitaiID(rt.Name())
+	if rootID == "" {
+		rootID = "root"
+	}
+	gen.root = &kaitaiType{name: rootID}
+	gen.used[rootID] = true
+	if layout.Wire == wireStruct || layout.Wire == wireEnum {
+		gen.names[rt] = rootID
+		err = gen.fillType(gen.root, layout)
+	} else {
+		gen.root.seq, err = gen.entries("value", layout, rootID, nil)
+	}
+	if err != nil {
+		return "", fmt.Errorf("kaitai: %s: %w", rt, err)
+	}
+
+	buf := new(strings.Builder)
+	gen.write(buf, enc)
+	return buf.String(), nil
+}
+
+type kaitaiEntry struct {
+	id       string
+	typ      string
+	size     string
+	encoding string
+	padRight bool
+	enum     string
+	repeat   string
+	until    string
+	ifExpr   string
+	switchOn string
+	cases    [][2]string
+}
+
+type kaitaiInstance struct {
+	id    string
+	value string
+}
+
+type kaitaiType struct {
+	name      string
+	seq       []kaitaiEntry
+	instances []kaitaiInstance
+	enumName  string
+	enum      []string
+}
+
+type kaitaiGen struct {
+	root    *kaitaiType
+	types   []*kaitaiType
+	names   map[reflect.Type]string
+	used    map[string]bool
+	helpers map[string]bool
+}
+
+// kaitaiID converts a Go identifier to a Kaitai identifier.
+func kaitaiID(name string) string {
+	return ToRustSnakeCase(name)
+}
+
+// newName reserves a type name, derived from the provided one.
+func (g *kaitaiGen) newName(name string) string {
+	out := name
+	for i := 2; g.used[out]; i++ {
+		out = name + "_" + strconv.Itoa(i)
+	}
+	g.used[out] = true
+	return out
+}
+
+func (g *kaitaiGen) newType(name string) *kaitaiType {
+	t := &kaitaiType{name: g.newName(name)}
+	g.types = append(g.types, t)
+	return t
+}
+
+// userType returns the name of the type describing a struct or an enum,
+// generating it on first use.
+func (g *kaitaiGen) userType(n *layoutNode, fallback string) (string, error) {
+	if name, ok := g.names[n.Type]; ok {
+		return name, nil
+	}
+	if n.Recursive {
+		return "", fmt.Errorf("%s: recursive type has no name", n.Type)
+	}
+	name := kaitaiID(n.Type.Name())
+	if name == "" {
+		name = fallback
+	}
+	t := g.newType(name)
+	if n.Type.Name() != "" {
+		g.names[n.Type] = t.name
+	}
+	return t.name, g.fillType(t, n)
+}
+
+func (g *kaitaiGen) fillType(t *kaitaiType, n *layoutNode) error {
+	if n.Wire == wireEnum {
+		return g.fillEnum(t, n)
+	}
+	refs := map[string]string{}
+	for _, field := range n.Fields {
+		id := kaitaiID(field.Name)
+		entries, err := g.entries(id, field, t.name, refs)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", field.Name, err)
+		}
+		t.seq = append(t.seq, entries...)
+		refs[field.Name] = kaitaiValueExpr(id, field)
+		addKaitaiRefs(refs, field.Name, id, field)
+	}
+	return nil
+}
+
+// addKaitaiRefs adds the expressions of the fields of a struct
+// field, for the dotted `sizeof` paths such as Header.Count.
+func addKaitaiRefs(refs map[string]string, name, expr string, n *layoutNode) {
+	if n.Wire != wireStruct || n.Recursive || n.Presence != presenceNone {
+		return
+	}
+	for _, field := range n.Fields {
+		path := name + "." + field.Name
+		refs[path] = expr + "." + kaitaiValueExpr(kaitaiID(field.Name), field)
+		addKaitaiRefs(refs, path, expr+"."+kaitaiID(field.Name), field)
+	}
+}
+
+func (g *kaitaiGen) fillEnum(t *kaitaiType, n *layoutNode) error {
+	t.enumName = "variants"
+	value := kaitaiEntry{id: "value", switchOn: "variant"}
+	for _, variant := range n.Fields {
+		id := kaitaiID(variant.Name)
+		t.enum = append(t.enum, id)
+		if variant.Wire == wireStruct && len(variant.Fields) == 0 && !variant.Recursive {
+			// Empty variants carry no data.
+			continue
+		}
+		typ, err := g.variantType(variant, t.name+"_"+id)
+		if err != nil {
+			return fmt.Errorf("variant %q: %w", variant.Name, err)
+		}
+		value.cases = append(value.cases, [2]string{t.enumName + "::" + id, typ})
+	}
+	t.seq = append(t.seq, kaitaiEntry{id: "variant", typ: "u1", enum: t.enumName})
+	if len(value.cases) > 0 {
+		t.seq = append(t.seq, value)
+	}
+	return nil
+}
+
+// variantType returns the name of the type describing a variant of an
+// enum or a union, wrapping the variants that are not structs or enums.
+func (g *kaitaiGen) variantType(variant *layoutNode, name string) (string, error) {
+	if variant.Wire == wireStruct || variant.Wire == wireEnum {
+		return g.userType(variant, name)
+	}
+	wrapper := g.newType(name)
+	var err error
+	wrapper.seq, err = g.entries("value", variant, wrapper.name, nil)
+	return wrapper.name, err
+}
+
+// kaitaiValueExpr is the expression of the integer value of a field,
+// used by the `sizeof=` fields.
+func kaitaiValueExpr(id string, n *layoutNode) string {
+	if n.Wire == wireUvarint || n.Wire == wireVarint {
+		return id + ".value"
+	}
+	return id
+}
+
+func (g *kaitaiGen) scalar(n *layoutNode) (string, error) {
+	var prefix string
+	switch n.Wire {
+	case wireUint, wireBool:
+		prefix = "u"
+	case wireInt:
+		prefix = "s"
+	case wireFloat:
+		prefix = "f"
+		if n.valueSize() == TypeSize.Float16 {
+			// Kaitai Struct has no half-precision floats: read the bits.
+			prefix = "u"
+		}
+	case wireComplex:
+		prefix = "complex"
+	default:
+		return "", fmt.Errorf("%s is not a scalar", n.Wire)
+	}
+	size := n.valueSize()
+	if (n.Wire == wireUint || n.Wire == wireInt) && size != 1 && size != 2 && size != 4 && size != 8 {
+		return "", fmt.Errorf("%d-byte integers are not supported", size)
+	}
+	var typ string
+	if n.Wire == wireComplex {
+		typ = prefix + strconv.Itoa(size*8)
+	} else {
+		typ = prefix + strconv.Itoa(size)
+	}
+	if size > 1 && n.Order == BE {
+		typ += "be"
+	}
+	if n.Wire == wireComplex {
+		g.helpers[typ] = true
+	}
+	return typ, nil
+}
+
+// entries returns the seq entries of a value; refs are the
+// expressions of the previous fields of the same struct, if any.
+func (g *kaitaiGen) entries(id string, n *layoutNode, parent string, refs map[string]string) ([]kaitaiEntry, error) {
+	var out []kaitaiEntry
+	var cond string
+	if n.Extension {
+		cond = "not _io.eof"
+	}
+	for level := 0; n.Presence != presenceNone && level < n.levels(); level++ {
+		// The flags of a `pointers=perlevel` chain: _present, _present2...
+		flagID := id + "_present"
+		if level > 0 {
+			flagID += strconv.Itoa(level + 1)
+		}
+		flag := kaitaiEntry{id: flagID, typ: "u1", ifExpr: cond}
+		if n.Presence == presenceUint32 {
+			flag.typ = "u4"
+		}
+		out = append(out, flag)
+		cond = kaitaiAnd(cond, flagID+" != 0")
+	}
+
+	var length string
+	switch n.Prefix {
+	case prefixNone, prefixNUL:
+	case prefixSizeOf:
+		if n.SizeFunc != "" {
+			return nil, fmt.Errorf("sizefunc %q is not supported", n.SizeFunc)
+		}
+		length = refs[n.SizeOf]
+		if length == "" {
+			return nil, fmt.Errorf("size field %q not found", n.SizeOf)
+		}
+		if n.SizeExpr != "" {
+			length = "(" + length + " " + n.SizeExpr[:1] + " " + n.SizeExpr[1:] + ")"
+		}
+	default:
+		entry := kaitaiEntry{id: id + "_len", ifExpr: cond}
+		length = id + "_len"
+		switch n.Prefix {
+		case prefixUvarint:
+			entry.typ = "uvarint"
+			length += ".value"
+		case prefixCompactU16:
+			entry.typ = "compact_u16"
+			length += ".value"
+		case prefixUint8:
+			entry.typ = "u1"
+		case prefixUint16:
+			entry.typ = "u2"
+		case prefixUint32:
+			entry.typ = "u4"
+		case prefixUint64:
+			entry.typ = "u8"
+		}
+		if n.PrefixOrder == BE && (n.Prefix == prefixUint16 || n.Prefix == prefixUint32 || n.Prefix == prefixUint64) {
+			entry.typ += "be"
+		}
+		if entry.typ == "uvarint" || entry.typ == "compact_u16" {
+			g.helpers[entry.typ] = true
+		}
+		out = append(out, entry)
+	}
+
+	if n.BitReverse || (n.Elem != nil && n.Elem.BitReverse) {
+		return nil, fmt.Errorf("bit-reversed integers are not supported")
+	}
+	if n.Wire == wireVLQ || (n.Elem != nil && n.Elem.Wire == wireVLQ) {
+		return nil, fmt.Errorf("vlq integers are not supported")
+	}
+	if n.Wire == wireSQLiteVarint || n.Wire == wireGroupVarint || (n.Elem != nil && n.Elem.Wire == wireSQLiteVarint) {
+		return nil, fmt.Errorf("sqlite and group varints are not supported")
+	}
+	if n.Wire == wireDelta || n.Wire == wireRLE {
+		return nil, fmt.Errorf("%s slices are not supported", n.Wire)
+	}
+	if n.Wire == wireCompressed || n.Wire == wireEncrypted {
+		return nil, fmt.Errorf("%s fields are not supported", n.Wire)
+	}
+	if n.ByteSizeOf != "" {
+		return nil, fmt.Errorf("bytesizeof fields are not supported")
+	}
+	value := kaitaiEntry{id: id, ifExpr: cond}
+	switch n.Wire {
+	case wireUint, wireInt, wireFloat, wireComplex, wireBool:
+		if size := n.valueSize(); size > 8 && n.Wire != wireComplex {
+			value.size = strconv.Itoa(size)
+			break
+		}
+		typ, err := g.scalar(n)
+		if err != nil {
+			return nil, err
+		}
+		value.typ = typ
+	case wireUvarint, wireVarint:
+		value.typ = "uvarint"
+		if n.Wire == wireVarint {
+			value.typ = "varint"
+			g.helpers["varint"] = true
+		}
+		g.helpers["uvarint"] = true
+	case wireString:
+		value.typ = "str"
+		value.encoding = "UTF-8"
+		value.size = length
+		switch n.Prefix {
+		case prefixNone:
+			// A `strlen=N` string, padded with zeros.
+			value.size = strconv.Itoa(n.Length)
+			value.padRight = true
+		case prefixNUL:
+			value.typ = "strz"
+		}
+	case wireBytes:
+		if n.Prefix == prefixNone {
+			value.size = strconv.Itoa(n.Length)
+		} else {
+			value.size = length
+		}
+	case wireArray, wireSlice, wireMap:
+		count := length
+		if n.Wire == wireArray {
+			count = strconv.Itoa(n.Length)
+		}
+		item, err := g.repeated(id, n, parent)
+		if err != nil {
+			return nil, err
+		}
+		value.typ = item.typ
+		value.size = item.size
+		value.encoding = item.encoding
+		value.repeat = count
+	case wireStruct, wireEnum:
+		typ, err := g.userType(n, parent+"_"+id)
+		if err != nil {
+			return nil, err
+		}
+		value.typ = typ
+	case wireUnion:
+		value.switchOn = refs[n.Union]
+		if value.switchOn == "" {
+			return nil, fmt.Errorf("union discriminator %q not found", n.Union)
+		}
+		for _, variant := range n.Fields {
+			typ, err := g.variantType(variant, parent+"_"+id+"_"+variant.Name)
+			if err != nil {
+				return nil, fmt.Errorf("variant %s: %w", variant.Name, err)
+			}
+			value.cases = append(value.cases, [2]string{variant.Name, typ})
+		}
+	case wireReserved:
+		value.size = strconv.Itoa(n.Size)
+	case wireNothing:
+		return out, nil
+	case wireRuneUTF8:
+		return nil, fmt.Errorf("UTF-8 runes are not supported")
+	case wireCustom:
+		return nil, fmt.Errorf("%s has a custom encoder", n.Type)
+	default:
+		return nil, fmt.Errorf("unsupported wire kind %s", n.Wire)
+	}
+	return append(out, value), nil
+}
+
+// repeated returns the entry describing one element of a slice, array or map.
+// Elements that take more than one entry get their own type.
+func (g *kaitaiGen) repeated(id string, n *layoutNode, parent string) (kaitaiEntry, error) {
+	name := parent + "_" + id + "_item"
+	if n.Wire == wireMap {
+		t := g.newType(parent + "_" + id + "_entry")
+		key, err := g.entries("key", n.Key, t.name, nil)
+		if err != nil {
+			return kaitaiEntry{}, fmt.Errorf("map key: %w", err)
+		}
+		value, err := g.entries("value", n.Elem, t.name, nil)
+		if err != nil {
+			return kaitaiEntry{}, fmt.Errorf("map value: %w", err)
+		}
+		t.seq = append(key, value...)
+		return kaitaiEntry{typ: t.name}, nil
+	}
+	entries, err := g.entries("value", n.Elem, name, nil)
+	if err != nil {
+		return kaitaiEntry{}, err
+	}
+	if len(entries) == 1 && entries[0].repeat == "" {
+		return entries[0], nil
+	}
+	t := g.newType(name)
+	t.seq = entries
+	return kaitaiEntry{typ: t.name}, nil
+}
+
+func kaitaiAnd(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + " and " + b
+}
+
+// kaitaiQuote quotes a YAML scalar when it contains characters
+// with a special meaning.
+func kaitaiQuote(s string) string {
+	if strings.ContainsAny(s, ":#'\"!&*?|>%@`{}[],-") || strings.Contains(s, " ") {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+	return s
+}
+
+func (g *kaitaiGen) write(w *strings.Builder, enc Encoding) {
+	fmt.Fprintf(w, "meta:\n")
+	fmt.Fprintf(w, "  id: %s\n", g.root.name)
+	fmt.Fprintf(w, "  title: %s\n", kaitaiQuote(g.root.name+" ("+enc.String()+" encoding)"))
+	fmt.Fprintf(w, "  endian: le\n")
+	g.root.writeBody(w, "")
+
+	types := g.types
+	if g.helpers["uvarint"] || g.helpers["compact_u16"] {
+		g.helpers["varint_group"] = true
+	}
+	for _, name := range []string{"uvarint", "varint", "compact_u16", "varint_group", "complex64", "complex64be", "complex128", "complex128be"} {
+		if g.helpers[name] {
+			types = append(types, kaitaiHelper(name))
+		}
+	}
+	if len(types) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "types:\n")
+	for _, t := range types {
+		fmt.Fprintf(w, "  %s:\n", t.name)
+		t.writeBody(w, "    ")
+	}
+}
+
+func (t *kaitaiType) writeBody(w *strings.Builder, indent string) {
+	if len(t.seq) > 0 {
+		fmt.Fprintf(w, "%sseq:\n", indent)
+	}
+	for _, e := range t.seq {
+		fmt.Fprintf(w, "%s  - id: %s\n", indent, e.id)
+		field := indent + "    "
+		if e.switchOn != "" {
+			fmt.Fprintf(w, "%stype:\n", field)
+			fmt.Fprintf(w, "%s  switch-on: %s\n", field, e.switchOn)
+			fmt.Fprintf(w, "%s  cases:\n", field)
+			for _, c := range e.cases {
+				fmt.Fprintf(w, "%s    %s: %s\n", field, kaitaiQuote(c[0]), c[1])
+			}
+		}
+		if e.typ != "" {
+			fmt.Fprintf(w, "%stype: %s\n", field, e.typ)
+		}
+		if e.size != "" {
+			fmt.Fprintf(w, "%ssize: %s\n", field, kaitaiQuote(e.size))
+		}
+		if e.encoding != "" {
+			fmt.Fprintf(w, "%sencoding: %s\n", field, e.encoding)
+		}
+		if e.padRight {
+			fmt.Fprintf(w, "%spad-right: 0\n", field)
+		}
+		if e.enum != "" {
+			fmt.Fprintf(w, "%senum: %s\n", field, e.enum)
+		}
+		if e.repeat != "" {
+			fmt.Fprintf(w, "%srepeat: expr\n", field)
+			fmt.Fprintf(w, "%srepeat-expr: %s\n", field, kaitaiQuote(e.repeat))
+		}
+		if e.until != "" {
+			fmt.Fprintf(w, "%srepeat: until\n", field)
+			fmt.Fprintf(w, "%srepeat-until: %s\n", field, kaitaiQuote(e.until))
+		}
+		if e.ifExpr != "" {
+			fmt.Fprintf(w, "%sif: %s\n", field, kaitaiQuote(e.ifExpr))
+		}
+	}
+	if len(t.instances) > 0 {
+		fmt.Fprintf(w, "%sinstances:\n", indent)
+		for _, in := range t.instances {
+			fmt.Fprintf(w, "%s  %s:\n", indent, in.id)
+			fmt.Fprintf(w, "%s    value: %s\n", indent, kaitaiQuote(in.value))
+		}
+	}
+	if len(t.enum) > 0 {
+		fmt.Fprintf(w, "%senums:\n", indent)
+		fmt.Fprintf(w, "%s  %s:\n", indent, t.enumName)
+		for i, name := range t.enum {
+			fmt.Fprintf(w, "%s    %d: %s\n", indent, i, name)
+		}
+	}
+}
+
+// kaitaiHelper returns the definition of the helper types
+// shared by all the generated definitions.
+func kaitaiHelper(name string) *kaitaiType {
+	switch name {
+	case "uvarint", "compact_u16":
+		groups := 10
+		if name == "compact_u16" {
+			groups = 3
+		}
+		value := "groups[0].value"
+		for i := 1; i < groups; i++ {
+			value += fmt.Sprintf(" + (groups.size > %d ? groups[%d].value << %d : 0)", i, i, 7*i)
+		}
+		return &kaitaiType{
+			name: name,
+			seq: []kaitaiEntry{
+				{id: "groups", typ: "varint_group", until: "not _.has_next"},
+			},
+			instances: []kaitaiInstance{{id: "value", value: value}},
+		}
+	case "varint_group":
+		return &kaitaiType{
+			name: name,
+			seq:  []kaitaiEntry{{id: "b", typ: "u1"}},
+			instances: []kaitaiInstance{
+				{id: "has_next", value: "(b & 0x80) != 0"},
+				{id: "value", value: "b & 0x7f"},
+			},
+		}
+	case "varint":
+		return &kaitaiType{
+			name: name,
+			seq:  []kaitaiEntry{{id: "raw", typ: "uvarint"}},
+			instances: []kaitaiInstance{
+				{id: "value", value: "raw.value % 2 == 0 ? raw.value / 2 : -((raw.value + 1) / 2)"},
+			},
+		}
+	default:
+		float := "f4"
+		if strings.HasPrefix(name, "complex128") {
+			float = "f8"
+		}
+		if strings.HasSuffix(name, "be") {
+			float += "be"
+		}
+		return &kaitaiType{
+			name: name,
+			seq: []kaitaiEntry{
+				{id: "real", typ: float},
+				{id: "imag", typ: float},
+			},
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type kaitaiTestEntry struct {
+	Kind uint16 `bin:"big"`
+	Name string
+}
+
+type kaitaiTestRecord struct {
+	Count   uint8 `bin:"sizeof=Entries"`
+	Entries []kaitaiTestEntry
+	Hash    [4]byte
+	Memo    *string `bin:"optional"`
+	Version uint32  `bin:"binary_extension"`
+}
+
+type kaitaiTestEnum struct {
+	Enum    BorshEnum `borsh_enum:"true"`
+	Nothing EmptyVariant
+	Amount  uint64
+}
+
+func TestKaitaiStruct(t *testing.T) {
+	ksy, err := KaitaiStruct(&kaitaiTestRecord{}, EncodingBin)
+	require.NoError(t, err)
+	assert.Equal(t, `meta:
+  id: kaitai_test_record
+  title: 'kaitai_test_record (Bin encoding)'
+  endian: le
+seq:
+  - id: count
+    type: u1
+  - id: entries
+    type: kaitai_test_entry
+    repeat: expr
+    repeat-expr: count
+  - id: hash
+    size: 4
+  - id: memo_present
+    type: u4
+  - id: memo_len
+    type: u8
+    if: 'memo_present != 0'
+  - id: memo
+    type: str
+    size: memo_len
+    encoding: UTF-8
+    if: 'memo_present != 0'
+  - id: version
+    type: u4
+    if: 'not _io.eof'
+types:
+  kaitai_test_entry:
+    seq:
+      - id: kind
+        type: u2be
+      - id: name_len
+        type: u8
+      - id: name
+        type: str
+        size: name_len
+        encoding: UTF-8
+`, ksy)
+
+	ksy, err = KaitaiStruct(kaitaiTestRecord{}, EncodingCompactU16)
+	require.NoError(t, err)
+	assert.Contains(t, ksy, `
+      - id: name_len
+        type: compact_u16
+      - id: name
+        type: str
+        size: name_len.value
+`)
+	assert.Contains(t, ksy, "\n  compact_u16:\n")
+	assert.Contains(t, ksy, "\n  varint_group:\n")
+}
+
+func TestKaitaiStruct_BorshEnum(t *testing.T) {
+	ksy, err := KaitaiStruct(kaitaiTestEnum{}, EncodingBorsh)
+	require.NoError(t, err)
+	assert.Contains(t, ksy, `
+seq:
+  - id: variant
+    type: u1
+    enum: variants
+  - id: value
+    type:
+      switch-on: variant
+      cases:
+        'variants::amount': kaitai_test_enum_amount
+enums:
+  variants:
+    0: nothing
+    1: amount
+`)
+}
+
+func TestKaitaiStruct_Optional(t *testing.T) {
+	ksy, err := KaitaiStruct(struct {
+		Count *uint32    `bin:"optional"`
+		Phase *complex64 `bin:"optional big"`
+	}{}, EncodingBin)
+	require.NoError(t, err)
+	assert.Contains(t, ksy, "  - id: count\n    type: u4\n    if: 'count_present != 0'\n")
+	assert.Contains(t, ksy, "  - id: phase\n    type: complex64be\n    if: 'phase_present != 0'\n")
+}
+
+func TestKaitaiStruct_Unsupported(t *testing.T) {
+	_, err := KaitaiStruct(struct{ Custom CustomEncoding }{}, EncodingBin)
+	assert.EqualError(t, err, `kaitai: struct { Custom bin.CustomEncoding }: field "Custom": bin.CustomEncoding has a custom encoder`)
+
+	_, err = KaitaiStruct(struct {
+		Initial rune `bin:"utf8"`
+	}{}, EncodingBin)
+	assert.Error(t, err)
+}
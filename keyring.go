@@ -0,0 +1,178 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"crypto/aes"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// A KeyProvider provides the keys of encrypted fields (see the `encrypt` tag)
+// and envelopes (see SealEnvelope), identified by key IDs that are written
+// with the encrypted data, so that data encrypted before a key rotation can
+// still be decrypted after it.
+//
+// Keyring is an in-memory implementation; others can be backed by a KMS,
+// e.g. with data keys wrapped by a master key that never leaves the KMS.
+// The methods are called for every encrypted value, and must be safe
+// for concurrent use: implementations that call out to a KMS should cache
+// the keys they get.
+type KeyProvider interface {
+	// CurrentKey returns the key that new data is encrypted with, and its ID.
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns the key with the provided ID, or an error wrapping
+	// ErrUnknownKeyID if there is none.
+	Key(id string) ([]byte, error)
+}
+
+// WithKeyProvider makes the encoder encrypt the fields tagged
+// `bin:"encrypt"` with the current key of the provided KeyProvider.
+func (e *Encoder) WithKeyProvider(p KeyProvider) *Encoder {
+	e.keys = p
+	return e
+}
+
+// WithKeyProvider makes the decoder decrypt the fields tagged
+// `bin:"encrypt"` with the keys of the provided KeyProvider.
+func (dec *Decoder) WithKeyProvider(p KeyProvider) *Decoder {
+	dec.keys = p
+	return dec
+}
+
+// staticKey is the KeyProvider of Encoder.WithEncryptionKey.
+type staticKey struct {
+	id  string
+	key []byte
+}
+
+func (k staticKey) CurrentKey() (string, []byte, error) {
+	return k.id, k.key, nil
+}
+
+func (k staticKey) Key(id string) ([]byte, error) {
+	if id != k.id {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKeyID, id)
+	}
+	return k.key, nil
+}
+
+// A Keyring is an in-memory KeyProvider holding the current key and
+// the retired keys that older data can still be decrypted with:
+//
+//	keyring := bin.NewKeyring()
+//	keyring.Rotate("2024-05", key1)
+//	// Encrypted with "2024-06", and still decryptable with "2024-05":
+//	keyring.Rotate("2024-06", key2)
+//
+// It's safe for concurrent use.
+type Keyring struct {
+	mu      sync.RWMutex
+	current string
+	keys    map[string][]byte
+}
+
+// NewKeyring returns an empty keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{keys: map[string][]byte{}}
+}
+
+// Add adds a key that data can be decrypted with, without
+// making it the current key. Key IDs can't be reused for other keys.
+func (k *Keyring) Add(id string, key []byte) error {
+	if _, err := aes.NewCipher(key); err != nil {
+		return fmt.Errorf("keyring: key %q: %w", id, err)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if existing, ok := k.keys[id]; ok && !bytes.Equal(existing, key) {
+		return fmt.Errorf("keyring: key ID %q is already used by another key", id)
+	}
+	k.keys[id] = append([]byte(nil), key...)
+	return nil
+}
+
+// Rotate adds a key and makes it the current key; the
+// previous keys can still be used to decrypt data.
+func (k *Keyring) Rotate(id string, key []byte) error {
+	if err := k.Add(id, key); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	k.current = id
+	k.mu.Unlock()
+	return nil
+}
+
+// Remove removes a key, e.g. once all the data encrypted with it has been
+// re-encrypted; the current key can't be removed.
+func (k *Keyring) Remove(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if id == k.current {
+		return fmt.Errorf("keyring: cannot remove the current key %q", id)
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+func (k *Keyring) CurrentKey() (string, []byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.current == "" {
+		return "", nil, ErrNoEncryptionKey
+	}
+	return k.current, k.keys[k.current], nil
+}
+
+func (k *Keyring) Key(id string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKeyID, id)
+	}
+	return key, nil
+}
+
+// ErrInvalidEnvelope is returned by OpenEnvelope for data that isn't an envelope.
+var ErrInvalidEnvelope = errors.New("invalid envelope")
+
+var envelopeMagic = []byte("BINE")
+
+// SealEnvelope encrypts a whole message (e.g. encoded data) with the current
+// key of the provided KeyProvider; the envelope holds the ID of the key,
+// so it can be opened after the key is rotated.
+func SealEnvelope(p KeyProvider, data []byte) ([]byte, error) {
+	id, key, err := p.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := encryptBlock(id, key, data)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte(nil), envelopeMagic...), block...), nil
+}
+
+// OpenEnvelope decrypts an envelope sealed by SealEnvelope.
+func OpenEnvelope(p KeyProvider, envelope []byte) ([]byte, error) {
+	if !bytes.HasPrefix(envelope, envelopeMagic) {
+		return nil, ErrInvalidEnvelope
+	}
+	return decryptBlock(envelope[len(envelopeMagic):], p.Key)
+}
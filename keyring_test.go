@@ -0,0 +1,76 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring_Rotation(t *testing.T) {
+	keyring := NewKeyring()
+	_, _, err := keyring.CurrentKey()
+	assert.True(t, errors.Is(err, ErrNoEncryptionKey))
+
+	require.NoError(t, keyring.Rotate("k1", bytes.Repeat([]byte{1}, 16)))
+	in := encryptedPayment{Amount: 1, Memo: "old", Tax: []byte("t")}
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).WithKeyProvider(keyring).Encode(&in))
+	old := buf.Bytes()
+
+	require.NoError(t, keyring.Rotate("k2", bytes.Repeat([]byte{2}, 32)))
+	buf = new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).WithKeyProvider(keyring).Encode(&in))
+	assert.True(t, bytes.Contains(buf.Bytes(), []byte("k2")))
+
+	for _, data := range [][]byte{old, buf.Bytes()} {
+		var out encryptedPayment
+		require.NoError(t, NewBinDecoder(data).WithKeyProvider(keyring).Decode(&out))
+		assert.Equal(t, in, out)
+	}
+
+	assert.EqualError(t, keyring.Remove("k2"), `keyring: cannot remove the current key "k2"`)
+	require.NoError(t, keyring.Remove("k1"))
+	var out encryptedPayment
+	err = NewBinDecoder(old).WithKeyProvider(keyring).Decode(&out)
+	assert.True(t, errors.Is(err, ErrUnknownKeyID))
+
+	assert.EqualError(t, keyring.Add("k2", bytes.Repeat([]byte{3}, 32)), `keyring: key ID "k2" is already used by another key`)
+	assert.EqualError(t, keyring.Add("k3", []byte("short")), "keyring: key \"k3\": crypto/aes: invalid key size 5")
+}
+
+func TestEnvelope(t *testing.T) {
+	keyring := NewKeyring()
+	require.NoError(t, keyring.Rotate("k1", bytes.Repeat([]byte{1}, 32)))
+
+	sealed, err := SealEnvelope(keyring, []byte("message"))
+	require.NoError(t, err)
+	require.NoError(t, keyring.Rotate("k2", bytes.Repeat([]byte{2}, 32)))
+
+	data, err := OpenEnvelope(keyring, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("message"), data)
+
+	sealed[len(sealed)-1] ^= 1
+	_, err = OpenEnvelope(keyring, sealed)
+	assert.True(t, errors.Is(err, ErrDecryption))
+
+	_, err = OpenEnvelope(keyring, []byte("nope"))
+	assert.True(t, errors.Is(err, ErrInvalidEnvelope))
+}
@@ -0,0 +1,701 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+// wireKind is the kind of a value as it appears on the wire.
+type wireKind int
+
+const (
+	wireUint wireKind = iota
+	wireInt
+	wireFloat
+	wireComplex
+	wireBool
+	wireUvarint
+	wireVarint
+	wireVLQ
+	wireSQLiteVarint
+	wireGroupVarint
+	wireDelta
+	wireRLE
+	wireCompressed
+	wireEncrypted
+	wireRuneUTF8
+	wireString
+	wireBytes
+	wireSlice
+	wireArray
+	wireMap
+	wireStruct
+	wireEnum
+	wireUnion
+	wireCustom
+	wireReserved
+	wireNothing
+)
+
+func (k wireKind) String() string {
+	switch k {
+	case wireUint:
+		return "uint"
+	case wireInt:
+		return "int"
+	case wireFloat:
+		return "float"
+	case wireComplex:
+		return "complex"
+	case wireBool:
+		return "bool"
+	case wireUvarint:
+		return "uvarint"
+	case wireVarint:
+		return "varint"
+	case wireVLQ:
+		return "vlq"
+	case wireSQLiteVarint:
+		return "sqlite varint"
+	case wireGroupVarint:
+		return "group varint"
+	case wireDelta:
+		return "delta"
+	case wireRLE:
+		return "rle"
+	case wireCompressed:
+		return "compressed"
+	case wireEncrypted:
+		return "encrypted"
+	case wireRuneUTF8:
+		return "utf8 rune"
+	case wireString:
+		return "string"
+	case wireBytes:
+		return "bytes"
+	case wireSlice:
+		return "slice"
+	case wireArray:
+		return "array"
+	case wireMap:
+		return "map"
+	case wireStruct:
+		return "struct"
+	case wireEnum:
+		return "enum"
+	case wireUnion:
+		return "union"
+	case wireCustom:
+		return "custom"
+	case wireReserved:
+		return "reserved"
+	case wireNothing:
+		return "nothing"
+	default:
+		return ""
+	}
+}
+
+// lengthPrefix is the way the length of a variable-size value is encoded.
+type lengthPrefix int
+
+const (
+	prefixNone lengthPrefix = iota
+	prefixUvarint
+	prefixUint32
+	prefixUint64
+	prefixCompactU16
+	// prefixSizeOf means that the length is the value of another field.
+	prefixSizeOf
+	// prefixNUL means that the value is terminated by a NUL byte,
+	// within Length bytes.
+	prefixNUL
+	prefixUint8
+	prefixUint16
+)
+
+func (p lengthPrefix) String() string {
+	switch p {
+	case prefixNone:
+		return "none"
+	case prefixUvarint:
+		return "uvarint"
+	case prefixUint32:
+		return "u32"
+	case prefixUint64:
+		return "u64"
+	case prefixCompactU16:
+		return "compact-u16"
+	case prefixSizeOf:
+		return "sizeof"
+	case prefixNUL:
+		return "nul"
+	case prefixUint8:
+		return "u8"
+	case prefixUint16:
+		return "u16"
+	default:
+		return ""
+	}
+}
+
+// presenceFlag is the way the presence of an optional value is encoded.
+type presenceFlag int
+
+const (
+	presenceNone presenceFlag = iota
+	presenceUint8
+	presenceUint32
+)
+
+// layoutNode describes how a value of a given type is put on the wire
+// by a given encoding. It mirrors what the encoders and decoders do.
+type layoutNode struct {
+	// Name is the struct field name (empty for the root and for elements).
+	Name string
+	Type reflect.Type
+	Wire wireKind
+	// Size is the fixed size in bytes of the value (including
+	// its length prefix and presence flag), or -1 if it's variable.
+	Size int
+	// ValueSize is the size of the value of an optional node,
+	// without its presence flag, or -1 if it's variable.
+	ValueSize int
+	Order     binary.ByteOrder
+	Presence  presenceFlag
+	// Levels is the number of presence flags of an optional node, each read
+	// when the previous one is set: one per pointer of a `pointers=perlevel`
+	// chain, and 1 otherwise (see levels).
+	Levels int
+	Prefix lengthPrefix
+	// PrefixOrder is the byte order of a u16, u32 or u64 Prefix,
+	// when it's big endian (see the `prefix` tag).
+	PrefixOrder binary.ByteOrder
+	// SizeOf is the name of the field holding the element count
+	// when Prefix is prefixSizeOf.
+	SizeOf string
+	// SizeFunc is the name of the SizeFunc of the `sizeof` field, if any.
+	SizeFunc string
+	// SizeExpr is the arithmetic of the `sizeof` field, if any, e.g. "/4".
+	SizeExpr string
+	// ByteSizeOf is the name of the field holding the size in bytes
+	// of the value, for targets of `bytesizeof` fields.
+	ByteSizeOf string
+	// Union is the name of the field holding the discriminator
+	// of a `union` field, which selects one of its Fields.
+	Union string
+	// Extension is set for `binary_extension` fields.
+	Extension bool
+	// BitReverse is set for `bitreverse` integers.
+	BitReverse bool
+	// Length is the number of elements of an array.
+	Length int
+	Elem   *layoutNode
+	Key    *layoutNode
+	// Fields are the fields of a struct, or the variants of an enum or a union.
+	Fields []*layoutNode
+	// Recursive is set when the struct type is already being described
+	// by a parent node; its Fields are not repeated.
+	Recursive bool
+}
+
+// prefixOrder returns the byte order of the u16, u32 or u64 length prefix of the node.
+func (n *layoutNode) prefixOrder() binary.ByteOrder {
+	if n.PrefixOrder != nil {
+		return n.PrefixOrder
+	}
+	return LE
+}
+
+// levels returns the number of presence flags of an optional node.
+func (n *layoutNode) levels() int {
+	if n.Levels > 1 {
+		return n.Levels
+	}
+	return 1
+}
+
+func (n *layoutNode) isFixed() bool {
+	return n.Size >= 0
+}
+
+// valueSize returns the fixed size of the value of the node, without
+// its presence flag if it's optional, or -1 if it's variable.
+func (n *layoutNode) valueSize() int {
+	if n.Presence != presenceNone {
+		return n.ValueSize
+	}
+	return n.Size
+}
+
+type layoutBuilder struct {
+	encoding Encoding
+	visiting map[reflect.Type]bool
+	// inheritedOrder is the byte order of the values nested
+	// in a `big,recursive` or `little,recursive` field.
+	inheritedOrder binary.ByteOrder
+}
+
+// describeType builds the wire layout of the provided type for the provided encoding.
+func describeType(rt reflect.Type, enc Encoding) (*layoutNode, error) {
+	b := &layoutBuilder{
+		encoding: enc,
+		visiting: make(map[reflect.Type]bool),
+	}
+	return b.describe(rt, newDefaultOption())
+}
+
+func (b *layoutBuilder) lengthPrefix() lengthPrefix {
+	switch b.encoding {
+	case EncodingBorsh:
+		return prefixUint32
+	case EncodingCompactU16:
+		return prefixCompactU16
+	default:
+		return prefixUvarint
+	}
+}
+
+func (b *layoutBuilder) stringPrefix() lengthPrefix {
+	if b.encoding.IsBin() {
+		return prefixUint64
+	}
+	return b.lengthPrefix()
+}
+
+func (b *layoutBuilder) presence(opt *option) presenceFlag {
+	switch {
+	case opt.is_COptional() && b.encoding.IsBorsh():
+		return presenceUint32
+	case opt.is_Optional() && b.encoding.IsBin():
+		return presenceUint32
+	case opt.is_Optional():
+		return presenceUint8
+	default:
+		return presenceNone
+	}
+}
+
+func fixed(n *layoutNode, size int) *layoutNode {
+	n.Size = size
+	return n
+}
+
+// describeBuiltin describes the types of this package that have
+// a custom marshaler with a known layout.
+func (b *layoutBuilder) describeBuiltin(n *layoutNode) bool {
+	switch n.Type {
+	case reflect.TypeOf(Uint128{}), reflect.TypeOf(Float128{}):
+		fixed(n, TypeSize.Uint128).Wire = wireUint
+	case reflect.TypeOf(Int128{}):
+		fixed(n, TypeSize.Uint128).Wire = wireInt
+	case reflect.TypeOf(Uint256{}):
+		fixed(n, TypeSize.Uint256).Wire = wireUint
+	case reflect.TypeOf(Int256{}):
+		fixed(n, TypeSize.Uint256).Wire = wireInt
+	case reflect.TypeOf(Uint64(0)):
+		fixed(n, TypeSize.Uint64).Wire = wireUint
+	case reflect.TypeOf(Int64(0)):
+		fixed(n, TypeSize.Uint64).Wire = wireInt
+	case float16Type:
+		fixed(n, TypeSize.Float16).Wire = wireFloat
+	case reflect.TypeOf(JSONFloat64(0)):
+		fixed(n, TypeSize.Float64).Wire = wireFloat
+	case reflect.TypeOf(Bool(false)):
+		fixed(n, TypeSize.Bool).Wire = wireBool
+	case reflect.TypeOf(EmptyVariant{}):
+		fixed(n, 0).Wire = wireStruct
+	case reflect.TypeOf(Varuint16(0)), reflect.TypeOf(Varuint32(0)):
+		n.Wire = wireUvarint
+	case reflect.TypeOf(Varint16(0)), reflect.TypeOf(Varint32(0)):
+		n.Wire = wireVarint
+	case reflect.TypeOf(HexBytes{}):
+		n.Wire = wireBytes
+		n.Prefix = b.lengthPrefix()
+	case reflect.TypeOf(SafeString("")):
+		n.Wire = wireString
+		n.Prefix = b.lengthPrefix()
+	default:
+		return false
+	}
+	return true
+}
+
+func (b *layoutBuilder) describe(rt reflect.Type, opt *option) (*layoutNode, error) {
+	n := &layoutNode{
+		Type:     rt,
+		Size:     -1,
+		Order:    opt.Order,
+		Presence: b.presence(opt),
+	}
+	if opt.PrefixOrder == BE {
+		n.PrefixOrder = BE
+	}
+	if b.encoding.IsBorsh() {
+		// Borsh always uses little endian.
+		n.Order = LE
+	}
+	if n.Presence != presenceNone {
+		inner, err := b.describe(rt, opt.clone().set_Optional(false).set_COptional(false))
+		if err != nil {
+			return nil, err
+		}
+		*n = *inner
+		n.Presence = b.presence(opt)
+		n.Levels = presenceLevels(rt, opt.Pointers)
+		n.ValueSize = inner.Size
+		n.Size = -1
+		return n, nil
+	}
+
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	n.Type = rt
+	if opt.Swap && isSwappableKind(rt.Kind()) {
+		if n.Order == LE {
+			n.Order = BE
+		} else {
+			n.Order = LE
+		}
+	}
+	n.BitReverse = opt.BitReverse && isSwappableKind(rt.Kind())
+
+	if b.describeBuiltin(n) {
+		return n, nil
+	}
+	if rt == timeType {
+		if opt.TimeFormat == TimeRFC3339 {
+			n.Wire = wireString
+			n.Prefix = b.stringPrefix()
+		} else {
+			fixed(n, TypeSize.Uint64).Wire = wireInt
+		}
+		return n, nil
+	}
+	if opt.CString && rt.Kind() == reflect.String {
+		n.Wire = wireString
+		n.Prefix = prefixNUL
+		n.Length = cStringMaxLen(opt)
+		return n, nil
+	}
+	if opt.StrLen > 0 && rt.Kind() == reflect.String {
+		n.Wire = wireString
+		n.Length = opt.StrLen
+		return fixed(n, opt.StrLen), nil
+	}
+	if opt.BoolWidth > 0 && rt.Kind() == reflect.Bool {
+		n.Wire = wireBool
+		return fixed(n, opt.BoolWidth), nil
+	}
+	if rt == ipType && opt.IPFormat != IPBytes {
+		n.Wire = wireBytes
+		n.Length = opt.IPFormat.size()
+		return fixed(n, n.Length), nil
+	}
+	if rt == hardwareAddrType && opt.MACFormat != MACBytes {
+		n.Wire = wireBytes
+		n.Length = opt.MACFormat.size()
+		return fixed(n, n.Length), nil
+	}
+	if rt == bigIntType {
+		if opt.Width > 0 {
+			fixed(n, opt.Width).Wire = wireInt
+		} else {
+			// A sign byte and a length-prefixed magnitude.
+			n.Wire = wireCustom
+		}
+		return n, nil
+	}
+	if rt == decimalType {
+		width := opt.Width
+		if width == 0 {
+			width = TypeSize.Uint64
+		}
+		fixed(n, width).Wire = wireInt
+		return n, nil
+	}
+	if hasCustomUnmarshaler(rt) || rt.Implements(marshalableType) || reflect.PtrTo(rt).Implements(marshalableType) {
+		n.Wire = wireCustom
+		return n, nil
+	}
+
+	if opt.VLQ && isUnsignedKind(rt.Kind()) {
+		n.Wire = wireVLQ
+		return n, nil
+	}
+	if opt.SQLiteVarint && isUnsignedKind(rt.Kind()) {
+		n.Wire = wireSQLiteVarint
+		return n, nil
+	}
+	if opt.RLE && isRLEType(rt) {
+		n.Wire = wireRLE
+		n.Prefix = b.lengthPrefix()
+		if opt.hasSizeOfSlice() {
+			n.Prefix = prefixSizeOf
+		}
+		return n, nil
+	}
+	if opt.Delta && isDeltaType(rt) {
+		n.Wire = wireDelta
+		n.Prefix = b.lengthPrefix()
+		if opt.hasSizeOfSlice() {
+			n.Prefix = prefixSizeOf
+		}
+		return n, nil
+	}
+	if opt.Encrypt && isCompressibleType(rt) {
+		// The encrypted block is written as a byte slice.
+		n.Wire = wireEncrypted
+		n.Prefix = b.lengthPrefix()
+		return n, nil
+	}
+	if opt.Compress && isCompressibleType(rt) {
+		// The compressed block is written as a byte slice.
+		n.Wire = wireCompressed
+		n.Prefix = b.lengthPrefix()
+		return n, nil
+	}
+	if opt.GroupVarint && isGroupVarintType(rt) {
+		n.Wire = wireGroupVarint
+		n.Prefix = b.lengthPrefix()
+		if opt.hasSizeOfSlice() {
+			n.Prefix = prefixSizeOf
+		}
+		return n, nil
+	}
+	if opt.RuneFormat == RuneFormatUTF8 {
+		switch {
+		case rt.Kind() == reflect.Int32:
+			n.Wire = wireRuneUTF8
+			return n, nil
+		case isRuneSlice(rt):
+			n.Wire = wireString
+			n.Prefix = b.stringPrefix()
+			return n, nil
+		}
+	}
+
+	switch rt.Kind() {
+	case reflect.Bool:
+		n.Wire = wireBool
+		return fixed(n, TypeSize.Bool), nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n.Wire = wireUint
+		return fixed(n, intWidth(rt, opt)), nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n.Wire = wireInt
+		return fixed(n, intWidth(rt, opt)), nil
+	case reflect.Float32, reflect.Float64:
+		n.Wire = wireFloat
+		return fixed(n, int(rt.Size())), nil
+	case reflect.Complex64, reflect.Complex128:
+		n.Wire = wireComplex
+		return fixed(n, int(rt.Size())), nil
+	case reflect.Int, reflect.Uint:
+		if opt.Width < 1 || opt.Width > TypeSize.Uint64 {
+			return nil, errNoWidth(rt)
+		}
+		n.Wire = wireInt
+		if rt.Kind() == reflect.Uint {
+			n.Wire = wireUint
+		}
+		return fixed(n, opt.Width), nil
+	case reflect.String:
+		n.Wire = wireString
+		n.Prefix = b.stringPrefix()
+		if opt.hasSizeOfSlice() {
+			n.Prefix = prefixSizeOf
+		} else if opt.LenPrefix != prefixNone {
+			n.Prefix = opt.LenPrefix
+		}
+		return n, nil
+	case reflect.Interface:
+		n.Wire = wireNothing
+		return fixed(n, 0), nil
+	case reflect.Array, reflect.Slice:
+		// Field tags don't apply to the elements, except for bit transforms and varints.
+		elemOpt := b.defaultOption()
+		if opt.hasBitTransform() && isSwappableKind(rt.Elem().Kind()) {
+			elemOpt.Order = opt.Order
+			elemOpt.Swap = opt.Swap
+			elemOpt.BitReverse = opt.BitReverse
+		}
+		elemOpt.VLQ = opt.VLQ
+		elemOpt.SQLiteVarint = opt.SQLiteVarint
+		elem, err := b.describe(rt.Elem(), elemOpt)
+		if err != nil {
+			return nil, err
+		}
+		n.Elem = elem
+		if rt.Kind() == reflect.Array {
+			n.Wire = wireArray
+			n.Length = rt.Len()
+			if opt.hasSizeOfSlice() && rt.Elem().Kind() == reflect.Uint8 {
+				// The first bytes of the array, as many as its sizeof field holds.
+				n.Prefix = prefixSizeOf
+			} else if elem.isFixed() {
+				n.Size = elem.Size * rt.Len()
+			}
+		} else {
+			n.Wire = wireSlice
+			n.Prefix = b.lengthPrefix()
+			if opt.hasSizeOfSlice() {
+				n.Prefix = prefixSizeOf
+			} else if opt.LenPrefix != prefixNone {
+				n.Prefix = opt.LenPrefix
+			}
+		}
+		if elem.Wire == wireUint && elem.Size == 1 && !elem.BitReverse {
+			n.Wire = wireBytes
+		}
+		return n, nil
+	case reflect.Map:
+		key, err := b.describe(rt.Key(), b.defaultOption())
+		if err != nil {
+			return nil, err
+		}
+		elem, err := b.describe(rt.Elem(), b.defaultOption())
+		if err != nil {
+			return nil, err
+		}
+		n.Wire = wireMap
+		n.Prefix = b.lengthPrefix()
+		if opt.hasSizeOfSlice() {
+			n.Prefix = prefixSizeOf
+		} else if opt.LenPrefix != prefixNone {
+			n.Prefix = opt.LenPrefix
+		}
+		n.Key = key
+		n.Elem = elem
+		return n, nil
+	case reflect.Struct:
+		return b.describeStruct(n)
+	default:
+		return nil, fmt.Errorf("unsupported type %q", rt)
+	}
+}
+
+func (b *layoutBuilder) describeStruct(n *layoutNode) (*layoutNode, error) {
+	rt := n.Type
+	n.Wire = wireStruct
+	if b.visiting[rt] {
+		n.Recursive = true
+		return n, nil
+	}
+	b.visiting[rt] = true
+	defer delete(b.visiting, rt)
+
+	if b.encoding.IsBorsh() && rt.NumField() > 0 {
+		firstField := rt.Field(0)
+		if isTypeBorshEnum(firstField.Type) && parseFieldTag(firstField.Tag).IsBorshEnum {
+			n.Wire = wireEnum
+			for i := 1; i < rt.NumField(); i++ {
+				variant, err := b.describe(rt.Field(i).Type, newDefaultOption())
+				if err != nil {
+					return nil, fmt.Errorf("variant %q: %w", rt.Field(i).Name, err)
+				}
+				variant.Name = rt.Field(i).Name
+				n.Fields = append(n.Fields, variant)
+			}
+			return n, nil
+		}
+	}
+
+	inherited := b.inheritedOrder
+	defer func() { b.inheritedOrder = inherited }()
+
+	sizeOfTargets := map[string]string{}
+	sizeFuncs := map[string]string{}
+	sizeExprs := map[string]string{}
+	byteSizeOf := map[string]string{}
+	size := 0
+	plan := planOf(rt)
+	for i, structField := range plan.fields {
+		fieldTag := plan.tags[i]
+		if fieldTag.Skip || fieldTag.SkipDecode {
+			continue
+		}
+		if fieldTag.Reserved > 0 {
+			n.Fields = append(n.Fields, &layoutNode{
+				Name:      structField.Name,
+				Type:      structField.Type,
+				Wire:      wireReserved,
+				Size:      fieldTag.Reserved,
+				Order:     fieldTag.Order,
+				Extension: fieldTag.BinaryExtension,
+			})
+			if size >= 0 && !fieldTag.BinaryExtension {
+				size += fieldTag.Reserved
+			} else {
+				size = -1
+			}
+			continue
+		}
+		if structField.PkgPath != "" {
+			continue
+		}
+		if isSizeOfPath(fieldTag) {
+			sizeOfTargets[structField.Name] = fieldTag.SizeOf
+			sizeFuncs[structField.Name] = fieldTag.SizeFunc
+			sizeExprs[structField.Name] = fieldTag.SizeExpr
+		} else if fieldTag.SizeOf != "" {
+			sizeOfTargets[fieldTag.SizeOf] = structField.Name
+			sizeFuncs[fieldTag.SizeOf] = fieldTag.SizeFunc
+			sizeExprs[fieldTag.SizeOf] = fieldTag.SizeExpr
+		}
+		if fieldTag.ByteSizeOf != "" {
+			byteSizeOf[fieldTag.ByteSizeOf] = structField.Name
+		}
+
+		opt := plan.fieldOption(i, b.encoding, inherited)
+		counter, hasCounter := sizeOfTargets[structField.Name]
+		if hasCounter {
+			opt = opt.clone().setSizeOfSlice(0)
+		}
+
+		b.inheritedOrder = nestedOrder(fieldTag, inherited)
+		var field *layoutNode
+		var err error
+		if fieldTag.Union != "" {
+			field, err = b.describeUnion(structField.Type, fieldTag.Union)
+		} else {
+			field, err = b.describe(structField.Type, opt)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", structField.Name, err)
+		}
+		field.Name = structField.Name
+		field.Extension = fieldTag.BinaryExtension
+		if hasCounter {
+			field.SizeOf = counter
+			field.SizeFunc = sizeFuncs[structField.Name]
+			field.SizeExpr = sizeExprs[structField.Name]
+		}
+		field.ByteSizeOf = byteSizeOf[structField.Name]
+		n.Fields = append(n.Fields, field)
+
+		if size >= 0 && field.isFixed() && !field.Extension {
+			size += field.Size
+		} else {
+			size = -1
+		}
+	}
+	n.Size = size
+	return n, nil
+}
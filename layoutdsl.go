@@ -0,0 +1,654 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// A Layout describes a wire format programmatically, for formats that can't
+// be expressed with struct tags, e.g. a length that is several fields before
+// the value it applies to, or a payload that runs until the end of the data.
+// It's bound to a struct by field names:
+//
+//	var headerLayout = bin.NewLayout().
+//		Magic([]byte("PKT")).
+//		U16BE("Len").
+//		U8("Kind").
+//		Skip(1).
+//		Bytes("Payload", bin.LenFrom("Len")).
+//		Bytes("Trailer", bin.LenRest())
+//
+//	data, err := headerLayout.Marshal(header)
+//	err = headerLayout.Unmarshal(data, &header)
+//
+// Integer fields can be of any integer type that holds their values. Fields
+// that a length refers to (see LenFrom) are filled in when encoding.
+// A Layout is built once and is then safe for concurrent use; errors
+// in its description are returned by Err and by every use of the layout.
+type Layout struct {
+	steps []layoutStep
+	// counters holds the names of the integer fields.
+	counters map[string]bool
+	err      error
+}
+
+type layoutStepKind int
+
+const (
+	layoutUint layoutStepKind = iota
+	layoutInt
+	layoutBool
+	layoutUvarint
+	layoutVarint
+	layoutBytes
+	layoutSkip
+	layoutMagic
+	layoutStruct
+	layoutRepeat
+	layoutValue
+)
+
+type layoutStep struct {
+	kind   layoutStepKind
+	name   string
+	size   int
+	order  binary.ByteOrder
+	length LayoutLength
+	magic  []byte
+	nested *Layout
+}
+
+// A LayoutLength tells how the length of a Bytes or Repeat field is encoded.
+type LayoutLength struct {
+	from   string
+	fixed  int
+	prefix bool
+	rest   bool
+}
+
+// LenFrom makes the length (number of bytes, or of elements) the value
+// of the integer field with the provided name, which must come first.
+func LenFrom(field string) LayoutLength {
+	return LayoutLength{from: field}
+}
+
+// LenFixed makes the length n, which values must have when encoded.
+func LenFixed(n int) LayoutLength {
+	return LayoutLength{fixed: n}
+}
+
+// LenPrefix writes the length in front of the value, as a length
+// prefix of the encoding of the encoder or decoder.
+func LenPrefix() LayoutLength {
+	return LayoutLength{prefix: true}
+}
+
+// LenRest makes the value run until the end of the data; it must be last.
+func LenRest() LayoutLength {
+	return LayoutLength{rest: true}
+}
+
+// NewLayout returns an empty layout.
+func NewLayout() *Layout {
+	return &Layout{counters: map[string]bool{}}
+}
+
+// Err returns the first error in the description of the layout, if any.
+func (l *Layout) Err() error {
+	return l.err
+}
+
+func (l *Layout) add(step layoutStep) *Layout {
+	if l.err != nil {
+		return l
+	}
+	if len(l.steps) > 0 {
+		if last := l.steps[len(l.steps)-1]; last.length.rest {
+			l.err = fmt.Errorf("layout: field %q after %q, which runs until the end", step.name, last.name)
+			return l
+		}
+	}
+	switch {
+	case step.length.from != "" && !l.counters[step.length.from]:
+		l.err = fmt.Errorf("layout: field %q: length from unknown integer field %q", step.name, step.length.from)
+	case step.length.fixed < 0:
+		l.err = fmt.Errorf("layout: field %q: invalid length %d", step.name, step.length.fixed)
+	case step.kind == layoutSkip && step.size <= 0:
+		l.err = fmt.Errorf("layout: invalid skip of %d bytes", step.size)
+	case step.nested != nil && step.nested.err != nil:
+		l.err = fmt.Errorf("layout: field %q: %w", step.name, step.nested.err)
+	case (step.kind == layoutStruct || step.kind == layoutRepeat) && step.nested == nil:
+		l.err = fmt.Errorf("layout: field %q: nil layout", step.name)
+	}
+	if step.kind == layoutUint || step.kind == layoutInt || step.kind == layoutUvarint {
+		l.counters[step.name] = true
+	}
+	l.steps = append(l.steps, step)
+	return l
+}
+
+func (l *Layout) uint(name string, size int, order binary.ByteOrder) *Layout {
+	return l.add(layoutStep{kind: layoutUint, name: name, size: size, order: order})
+}
+
+func (l *Layout) int(name string, size int, order binary.ByteOrder) *Layout {
+	return l.add(layoutStep{kind: layoutInt, name: name, size: size, order: order})
+}
+
+// U8 adds an unsigned 8-bit integer field.
+func (l *Layout) U8(name string) *Layout { return l.uint(name, 1, LE) }
+
+// U16LE, U16BE, U32LE, ... add unsigned integer fields of the
+// size and byte order in their names.
+
+func (l *Layout) U16LE(name string) *Layout { return l.uint(name, 2, LE) }
+func (l *Layout) U16BE(name string) *Layout { return l.uint(name, 2, BE) }
+func (l *Layout) U32LE(name string) *Layout { return l.uint(name, 4, LE) }
+func (l *Layout) U32BE(name string) *Layout { return l.uint(name, 4, BE) }
+func (l *Layout) U64LE(name string) *Layout { return l.uint(name, 8, LE) }
+func (l *Layout) U64BE(name string) *Layout { return l.uint(name, 8, BE) }
+
+// I8 adds a signed 8-bit integer field.
+func (l *Layout) I8(name string) *Layout { return l.int(name, 1, LE) }
+
+// I16LE, I16BE, I32LE, ... add signed integer fields of the
+// size and byte order in their names.
+
+func (l *Layout) I16LE(name string) *Layout { return l.int(name, 2, LE) }
+func (l *Layout) I16BE(name string) *Layout { return l.int(name, 2, BE) }
+func (l *Layout) I32LE(name string) *Layout { return l.int(name, 4, LE) }
+func (l *Layout) I32BE(name string) *Layout { return l.int(name, 4, BE) }
+func (l *Layout) I64LE(name string) *Layout { return l.int(name, 8, LE) }
+func (l *Layout) I64BE(name string) *Layout { return l.int(name, 8, BE) }
+
+// Bool adds a one-byte boolean field.
+func (l *Layout) Bool(name string) *Layout {
+	return l.add(layoutStep{kind: layoutBool, name: name})
+}
+
+// Uvarint adds an unsigned integer field encoded as a uvarint.
+func (l *Layout) Uvarint(name string) *Layout {
+	return l.add(layoutStep{kind: layoutUvarint, name: name})
+}
+
+// Varint adds a signed integer field encoded as a zig-zag varint.
+func (l *Layout) Varint(name string) *Layout {
+	return l.add(layoutStep{kind: layoutVarint, name: name})
+}
+
+// Bytes adds a byte slice, byte array or string field, with the provided length.
+func (l *Layout) Bytes(name string, length LayoutLength) *Layout {
+	return l.add(layoutStep{kind: layoutBytes, name: name, length: length})
+}
+
+// Skip adds n bytes that are written as zeros and ignored when decoding.
+func (l *Layout) Skip(n int) *Layout {
+	return l.add(layoutStep{kind: layoutSkip, name: fmt.Sprintf("(skip %d)", n), size: n})
+}
+
+// Magic adds constant bytes, which decoding checks.
+func (l *Layout) Magic(b []byte) *Layout {
+	return l.add(layoutStep{kind: layoutMagic, name: fmt.Sprintf("(magic %x)", b), magic: append([]byte(nil), b...)})
+}
+
+// Struct adds a struct field with its own layout.
+func (l *Layout) Struct(name string, layout *Layout) *Layout {
+	return l.add(layoutStep{kind: layoutStruct, name: name, nested: layout})
+}
+
+// Repeat adds a slice of structs field, with the provided length (its number
+// of elements, or LenRest), whose elements have the provided layout.
+func (l *Layout) Repeat(name string, length LayoutLength, layout *Layout) *Layout {
+	return l.add(layoutStep{kind: layoutRepeat, name: name, length: length, nested: layout})
+}
+
+// Value adds a field that is encoded the usual way, following its struct tags.
+func (l *Layout) Value(name string) *Layout {
+	return l.add(layoutStep{kind: layoutValue, name: name})
+}
+
+// Marshal encodes v (a struct or a pointer to a struct) with the Bin encoding.
+func (l *Layout) Marshal(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := l.EncodeWith(NewBinEncoder(buf), v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes Bin-encoded data into v, which must be a pointer to a struct.
+func (l *Layout) Unmarshal(data []byte, v interface{}) error {
+	return l.DecodeWith(NewBinDecoder(data), v)
+}
+
+// EncodeWith encodes v (a struct or a pointer to a struct) with the provided
+// encoder, e.g. from a MarshalWithEncoder method. The encoding of the encoder
+// applies to the length prefixes and Value fields.
+func (l *Layout) EncodeWith(e *Encoder, v interface{}) error {
+	if l.err != nil {
+		return l.err
+	}
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("layout: expected a struct, got %T", v)
+	}
+	return l.encode(e, rv)
+}
+
+// DecodeWith decodes into v, which must be a pointer to a struct, with the
+// provided decoder, e.g. from an UnmarshalWithDecoder method.
+func (l *Layout) DecodeWith(dec *Decoder, v interface{}) error {
+	if l.err != nil {
+		return l.err
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("layout: expected a pointer to a struct, got %T", v)
+	}
+	return l.decode(dec, rv.Elem())
+}
+
+// layoutField returns the struct field bound to a step.
+func layoutField(rv reflect.Value, name string) (reflect.Value, error) {
+	field := rv.FieldByName(name)
+	if !field.IsValid() {
+		return field, fmt.Errorf("no field %q in %s", name, rv.Type())
+	}
+	return field, nil
+}
+
+func (l *Layout) encode(e *Encoder, rv reflect.Value) error {
+	// The lengths of the values whose length is held by another field.
+	var lengths map[string]int
+	for _, step := range l.steps {
+		if step.length.from == "" {
+			continue
+		}
+		field, err := layoutField(rv, step.name)
+		if err != nil {
+			return fmt.Errorf("layout: %w", err)
+		}
+		if lengths == nil {
+			lengths = map[string]int{}
+		}
+		lengths[step.length.from] = field.Len()
+	}
+
+	for _, step := range l.steps {
+		if err := l.encodeStep(e, rv, step, lengths); err != nil {
+			return fmt.Errorf("layout: field %q: %w", step.name, err)
+		}
+	}
+	return nil
+}
+
+func (l *Layout) encodeStep(e *Encoder, rv reflect.Value, step layoutStep, lengths map[string]int) error {
+	switch step.kind {
+	case layoutSkip:
+		return e.WriteBytes(make([]byte, step.size), false)
+	case layoutMagic:
+		return e.WriteBytes(step.magic, false)
+	}
+
+	field, err := layoutField(rv, step.name)
+	if err != nil {
+		return err
+	}
+	switch step.kind {
+	case layoutUint, layoutInt, layoutUvarint:
+		v, err := layoutInteger(field)
+		if err != nil {
+			return err
+		}
+		if length, ok := lengths[step.name]; ok {
+			v = uint64(length)
+		}
+		switch step.kind {
+		case layoutUvarint:
+			return e.WriteUvarint64(v)
+		case layoutInt:
+			// Check that the value fits by sign-extending it.
+			shift := 64 - 8*uint(step.size)
+			if int64(v<<shift)>>shift != int64(v) {
+				return fmt.Errorf("value %d overflows %d bytes", int64(v), step.size)
+			}
+		default:
+			if step.size < 8 && v>>(8*uint(step.size)) != 0 {
+				return fmt.Errorf("value %d overflows %d bytes", v, step.size)
+			}
+		}
+		return e.writeUintN(v, step.size, step.order)
+	case layoutVarint:
+		v, err := layoutInteger(field)
+		if err != nil {
+			return err
+		}
+		return e.WriteVarint64(int64(v))
+	case layoutBool:
+		if field.Kind() != reflect.Bool {
+			return fmt.Errorf("expected a bool, got %s", field.Type())
+		}
+		return e.WriteBool(field.Bool())
+	case layoutBytes:
+		b, err := layoutBytesOf(field)
+		if err != nil {
+			return err
+		}
+		if err := e.writeLayoutLength(step.length, len(b)); err != nil {
+			return err
+		}
+		return e.WriteBytes(b, false)
+	case layoutStruct:
+		field = reflect.Indirect(field)
+		if field.Kind() != reflect.Struct {
+			return fmt.Errorf("expected a struct, got %s", field.Type())
+		}
+		return step.nested.encode(e, field)
+	case layoutRepeat:
+		if field.Kind() != reflect.Slice || field.Type().Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("expected a slice of structs, got %s", field.Type())
+		}
+		if err := e.writeLayoutLength(step.length, field.Len()); err != nil {
+			return err
+		}
+		for i := 0; i < field.Len(); i++ {
+			if err := step.nested.encode(e, field.Index(i)); err != nil {
+				return newElementError("encoding", i, err)
+			}
+		}
+		return nil
+	case layoutValue:
+		return e.Encode(field.Interface())
+	default:
+		panic(fmt.Sprintf("unknown layout step %d", step.kind))
+	}
+}
+
+func (e *Encoder) writeLayoutLength(length LayoutLength, n int) error {
+	switch {
+	case length.prefix:
+		return e.WriteLength(n)
+	case length.from != "", length.rest:
+		return nil
+	case n != length.fixed:
+		return fmt.Errorf("length %d, expected %d", n, length.fixed)
+	}
+	return nil
+}
+
+func (e *Encoder) writeUintN(v uint64, size int, order binary.ByteOrder) error {
+	switch size {
+	case 1:
+		return e.WriteUint8(uint8(v))
+	case 2:
+		return e.WriteUint16(uint16(v), order)
+	case 4:
+		return e.WriteUint32(uint32(v), order)
+	default:
+		return e.WriteUint64(v, order)
+	}
+}
+
+// layoutInteger returns the value of an integer field, signed ones as two's complement.
+func layoutInteger(field reflect.Value) (uint64, error) {
+	if !isIntegerKind(field.Kind()) {
+		return 0, fmt.Errorf("expected an integer, got %s", field.Type())
+	}
+	return enumValue(field), nil
+}
+
+func layoutBytesOf(field reflect.Value) ([]byte, error) {
+	switch {
+	case field.Kind() == reflect.String:
+		return []byte(field.String()), nil
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8:
+		return field.Bytes(), nil
+	case field.Kind() == reflect.Array && field.Type().Elem().Kind() == reflect.Uint8:
+		b := make([]byte, field.Len())
+		reflect.Copy(reflect.ValueOf(b), field)
+		return b, nil
+	}
+	return nil, fmt.Errorf("expected bytes or a string, got %s", field.Type())
+}
+
+func (l *Layout) decode(dec *Decoder, rv reflect.Value) error {
+	// The values of the integer fields, for LenFrom.
+	var counts map[string]uint64
+	for _, step := range l.steps {
+		v, err := l.decodeStep(dec, rv, step, counts)
+		if err != nil {
+			return fmt.Errorf("layout: field %q: %w", step.name, err)
+		}
+		if l.counters[step.name] {
+			if counts == nil {
+				counts = map[string]uint64{}
+			}
+			counts[step.name] = v
+		}
+	}
+	return nil
+}
+
+// decodeStep decodes a step, and returns its value for integers.
+func (l *Layout) decodeStep(dec *Decoder, rv reflect.Value, step layoutStep, counts map[string]uint64) (uint64, error) {
+	switch step.kind {
+	case layoutSkip:
+		return 0, dec.SkipBytes(uint(step.size))
+	case layoutMagic:
+		b, err := dec.ReadNBytes(len(step.magic))
+		if err != nil {
+			return 0, err
+		}
+		if !bytes.Equal(b, step.magic) {
+			return 0, fmt.Errorf("got %x, expected %x", b, step.magic)
+		}
+		return 0, nil
+	}
+
+	field, err := layoutField(rv, step.name)
+	if err != nil {
+		return 0, err
+	}
+	if !field.CanSet() {
+		return 0, fmt.Errorf("field %q of %s can't be set", step.name, rv.Type())
+	}
+	switch step.kind {
+	case layoutUint, layoutInt, layoutUvarint, layoutVarint:
+		var v uint64
+		switch step.kind {
+		case layoutUvarint:
+			v, err = dec.ReadUvarint64()
+		case layoutVarint:
+			var i int64
+			i, err = dec.ReadVarint64()
+			v = uint64(i)
+		default:
+			v, err = dec.readUintN(step.size, step.order)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if step.kind == layoutInt {
+			// Sign-extend the value.
+			shift := 64 - 8*uint(step.size)
+			v = uint64(int64(v<<shift) >> shift)
+		}
+		signed := step.kind == layoutInt || step.kind == layoutVarint
+		return v, setLayoutInteger(field, v, signed)
+	case layoutBool:
+		if field.Kind() != reflect.Bool {
+			return 0, fmt.Errorf("expected a bool, got %s", field.Type())
+		}
+		b, err := dec.ReadBool()
+		field.SetBool(b)
+		return 0, err
+	case layoutBytes:
+		n, err := dec.readLayoutLength(step.length, counts, 1)
+		if err != nil {
+			return 0, err
+		}
+		b, err := dec.ReadNBytes(n)
+		if err != nil {
+			return 0, err
+		}
+		return 0, setLayoutBytes(field, b)
+	case layoutStruct:
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+			field = field.Elem()
+		}
+		if field.Kind() != reflect.Struct {
+			return 0, fmt.Errorf("expected a struct, got %s", field.Type())
+		}
+		return 0, step.nested.decode(dec, field)
+	case layoutRepeat:
+		if field.Kind() != reflect.Slice || field.Type().Elem().Kind() != reflect.Struct {
+			return 0, fmt.Errorf("expected a slice of structs, got %s", field.Type())
+		}
+		if step.length.rest {
+			field.Set(reflect.MakeSlice(field.Type(), 0, 0))
+			for i := 0; dec.HasRemaining(); i++ {
+				field.Set(reflect.Append(field, reflect.Zero(field.Type().Elem())))
+				if err := step.nested.decode(dec, field.Index(i)); err != nil {
+					return 0, newElementError("decoding", i, err)
+				}
+			}
+			return 0, nil
+		}
+		n, err := dec.readLayoutLength(step.length, counts, step.nested.minSize())
+		if err != nil {
+			return 0, err
+		}
+		field.Set(reflect.MakeSlice(field.Type(), n, n))
+		for i := 0; i < n; i++ {
+			if err := step.nested.decode(dec, field.Index(i)); err != nil {
+				return 0, newElementError("decoding", i, err)
+			}
+		}
+		return 0, nil
+	case layoutValue:
+		return 0, dec.Decode(field.Addr().Interface())
+	default:
+		panic(fmt.Sprintf("unknown layout step %d", step.kind))
+	}
+}
+
+// readLayoutLength reads a length of elements of at least minSize bytes each.
+func (dec *Decoder) readLayoutLength(length LayoutLength, counts map[string]uint64, minSize int) (int, error) {
+	var n uint64
+	switch {
+	case length.prefix:
+		l, err := dec.ReadLength()
+		if err != nil {
+			return 0, err
+		}
+		n = uint64(l)
+	case length.from != "":
+		n = counts[length.from]
+	case length.rest:
+		return dec.Remaining(), nil
+	default:
+		n = uint64(length.fixed)
+	}
+	if minSize < 1 {
+		minSize = 1
+	}
+	if n > uint64(dec.Remaining()/minSize) {
+		return 0, fmt.Errorf("length %d exceeds the remaining [%d] bytes: %w", n, dec.Remaining(), io.ErrUnexpectedEOF)
+	}
+	return int(n), nil
+}
+
+// minSize returns the smallest number of bytes that values with the layout take.
+func (l *Layout) minSize() int {
+	size := 0
+	for _, step := range l.steps {
+		switch step.kind {
+		case layoutUint, layoutInt:
+			size += step.size
+		case layoutBool, layoutUvarint, layoutVarint:
+			size++
+		case layoutSkip:
+			size += step.size
+		case layoutMagic:
+			size += len(step.magic)
+		case layoutStruct:
+			size += step.nested.minSize()
+		case layoutBytes:
+			if !step.length.prefix && step.length.from == "" && !step.length.rest {
+				size += step.length.fixed
+			}
+		}
+	}
+	return size
+}
+
+func (dec *Decoder) readUintN(size int, order binary.ByteOrder) (uint64, error) {
+	switch size {
+	case 1:
+		v, err := dec.ReadUint8()
+		return uint64(v), err
+	case 2:
+		v, err := dec.ReadUint16(order)
+		return uint64(v), err
+	case 4:
+		v, err := dec.ReadUint32(order)
+		return uint64(v), err
+	default:
+		return dec.ReadUint64(order)
+	}
+}
+
+func setLayoutInteger(field reflect.Value, v uint64, signed bool) error {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if (!signed && int64(v) < 0) || field.OverflowInt(int64(v)) {
+			return fmt.Errorf("value %d overflows %s", v, field.Type())
+		}
+		field.SetInt(int64(v))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if (signed && int64(v) < 0) || field.OverflowUint(v) {
+			return fmt.Errorf("value %d overflows %s", int64(v), field.Type())
+		}
+		field.SetUint(v)
+	default:
+		return fmt.Errorf("expected an integer, got %s", field.Type())
+	}
+	return nil
+}
+
+func setLayoutBytes(field reflect.Value, b []byte) error {
+	switch {
+	case field.Kind() == reflect.String:
+		field.SetString(string(b))
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8:
+		field.SetBytes(append([]byte(nil), b...))
+	case field.Kind() == reflect.Array && field.Type().Elem().Kind() == reflect.Uint8:
+		if field.Len() != len(b) {
+			return fmt.Errorf("%d bytes for %s", len(b), field.Type())
+		}
+		reflect.Copy(field, reflect.ValueOf(b))
+	default:
+		return fmt.Errorf("expected bytes or a string, got %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type layoutPacket struct {
+	Len     uint16
+	Kind    uint8
+	Delta   int32
+	Flag    bool
+	Tag     [2]byte
+	Payload []byte
+	Items   []layoutItem
+	Trailer string
+}
+
+type layoutItem struct {
+	ID   uint64
+	Name string
+}
+
+var layoutItemLayout = NewLayout().
+	Uvarint("ID").
+	Bytes("Name", LenPrefix())
+
+var layoutPacketLayout = NewLayout().
+	Magic([]byte("PKT")).
+	U16BE("Len").
+	U8("Kind").
+	Skip(1).
+	I32LE("Delta").
+	Bool("Flag").
+	Bytes("Tag", LenFixed(2)).
+	Bytes("Payload", LenFrom("Len")).
+	Repeat("Items", LenPrefix(), layoutItemLayout).
+	Bytes("Trailer", LenRest())
+
+func TestLayout_RoundTrip(t *testing.T) {
+	require.NoError(t, layoutPacketLayout.Err())
+
+	in := layoutPacket{
+		Kind:    7,
+		Delta:   -2,
+		Flag:    true,
+		Tag:     [2]byte{0xaa, 0xbb},
+		Payload: []byte{1, 2, 3},
+		Items:   []layoutItem{{ID: 300, Name: "a"}},
+		Trailer: "end",
+	}
+	data, err := layoutPacketLayout.Marshal(&in)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		'P', 'K', 'T',
+		0x00, 0x03, // Len, filled in from Payload
+		0x07,                   // Kind
+		0x00,                   // Skip(1)
+		0xfe, 0xff, 0xff, 0xff, // Delta
+		0x01,       // Flag
+		0xaa, 0xbb, // Tag
+		1, 2, 3, // Payload
+		0x01,       // len(Items)
+		0xac, 0x02, // ID
+		0x01, 'a', // Name
+		'e', 'n', 'd', // Trailer
+	}, data)
+
+	var out layoutPacket
+	require.NoError(t, layoutPacketLayout.Unmarshal(data, &out))
+	in.Len = 3
+	assert.Equal(t, in, out)
+}
+
+func TestLayout_Errors(t *testing.T) {
+	err := NewLayout().Bytes("Payload", LenFrom("Len")).Err()
+	assert.EqualError(t, err, `layout: field "Payload": length from unknown integer field "Len"`)
+
+	err = NewLayout().Bytes("Rest", LenRest()).U8("Kind").Err()
+	assert.EqualError(t, err, `layout: field "Kind" after "Rest", which runs until the end`)
+
+	_, err = NewLayout().U8("Missing").Marshal(layoutItem{})
+	assert.EqualError(t, err, `layout: field "Missing": no field "Missing" in bin.layoutItem`)
+
+	_, err = NewLayout().U8("ID").Marshal(layoutItem{ID: 256})
+	assert.EqualError(t, err, `layout: field "ID": value 256 overflows 1 bytes`)
+
+	_, err = NewLayout().Bytes("Name", LenFixed(2)).Marshal(layoutItem{Name: "abc"})
+	assert.EqualError(t, err, `layout: field "Name": length 3, expected 2`)
+
+	var item layoutItem
+	err = NewLayout().Magic([]byte("X")).Unmarshal([]byte("Y"), &item)
+	assert.EqualError(t, err, `layout: field "(magic 58)": got 59, expected 58`)
+
+	err = NewLayout().I8("ID").Unmarshal([]byte{0xff}, &item)
+	assert.EqualError(t, err, `layout: field "ID": value -1 overflows uint64`)
+
+	err = NewLayout().U8("ID").Bytes("Name", LenFrom("ID")).Unmarshal([]byte{5, 'a'}, &item)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+}
+
+func TestLayout_WithEncoding(t *testing.T) {
+	buf := new(bytes.Buffer)
+	in := layoutItem{ID: 1, Name: "ab"}
+	require.NoError(t, layoutItemLayout.EncodeWith(NewBorshEncoder(buf), in))
+	assert.Equal(t, []byte{0x01, 0x02, 0x00, 0x00, 0x00, 'a', 'b'}, buf.Bytes())
+
+	var out layoutItem
+	require.NoError(t, layoutItemLayout.DecodeWith(NewBorshDecoder(buf.Bytes()), &out))
+	assert.Equal(t, in, out)
+}
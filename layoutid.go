@@ -0,0 +1,104 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// TypeIDFromLayout returns a TypeID identifying the wire layout of the type
+// of `v` (a value or a pointer to a value) with the Bin encoding: the first
+// 8 bytes of the sha256 of a description of its encoded values, their order,
+// sizes, length prefixes, byte orders and presence flags.
+//
+// Types that are encoded the same way have the same TypeID, whatever
+// their names and the names of their fields, so two processes can compare
+// the TypeIDs of a type before exchanging its values. Values with custom
+// encoders are only identified by the name of their type.
+func TypeIDFromLayout(v interface{}) (TypeID, error) {
+	return TypeIDFromLayoutWithEncoding(v, EncodingBin)
+}
+
+// TypeIDFromLayoutWithEncoding is like TypeIDFromLayout, but for the provided
+// encoding; the encoding is part of the TypeID.
+func TypeIDFromLayoutWithEncoding(v interface{}, enc Encoding) (TypeID, error) {
+	rt := reflect.TypeOf(v)
+	if rt == nil {
+		return TypeID{}, fmt.Errorf("layout type id: nil type")
+	}
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if !isValidEncoding(enc) {
+		return TypeID{}, fmt.Errorf("layout type id: invalid encoding %d", enc)
+	}
+	layout, err := cachedLayout(rt, enc)
+	if err != nil {
+		return TypeID{}, fmt.Errorf("layout type id: %s: %w", rt, err)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", enc)
+	writeLayoutID(h, layout, nil)
+	return TypeIDFromBytes(h.Sum(nil)[:8]), nil
+}
+
+// writeLayoutID writes the description of a node hashed by TypeIDFromLayout;
+// parents are the struct types being described, so that recursive
+// references are written as the distance to the struct they refer to.
+func writeLayoutID(w io.Writer, n *layoutNode, parents []reflect.Type) {
+	if n.Recursive {
+		for i := len(parents) - 1; i >= 0; i-- {
+			if parents[i] == n.Type {
+				fmt.Fprintf(w, "(recursive %d)", len(parents)-1-i)
+				return
+			}
+		}
+	}
+	fmt.Fprintf(w, "(%s size=%d order=%s presence=%s prefix=%s length=%d",
+		n.Wire, n.Size, explainOrder(n), explainPresence(n), n.Prefix, n.Length)
+	if n.Wire == wireCustom {
+		fmt.Fprintf(w, " type=%s", n.Type)
+	}
+	if n.PrefixOrder == BE {
+		fmt.Fprintf(w, " prefix_order=BE")
+	}
+	if n.SizeFunc != "" {
+		fmt.Fprintf(w, " sizefunc=%s", n.SizeFunc)
+	}
+	if n.SizeExpr != "" {
+		fmt.Fprintf(w, " sizeexpr=%s", n.SizeExpr)
+	}
+	if n.ByteSizeOf != "" {
+		fmt.Fprintf(w, " bytesizeof")
+	}
+	if n.Union != "" {
+		fmt.Fprintf(w, " union=%s", n.Union)
+	}
+	if n.Wire == wireStruct || n.Wire == wireEnum {
+		parents = append(parents, n.Type)
+	}
+	for _, child := range []*layoutNode{n.Key, n.Elem} {
+		if child != nil {
+			writeLayoutID(w, child, parents)
+		}
+	}
+	for _, field := range n.Fields {
+		writeLayoutID(w, field, parents)
+	}
+	fmt.Fprint(w, ")")
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type layoutIDTransfer struct {
+	From   [32]byte
+	Amount uint64
+	Memo   string `bin:"optional"`
+}
+
+type layoutIDPayment struct {
+	Sender [32]byte
+	Value  uint64
+	Note   string `bin:"optional"`
+}
+
+type layoutIDTree struct {
+	Value    uint32
+	Children []layoutIDTree
+}
+
+func TestTypeIDFromLayout(t *testing.T) {
+	transfer, err := TypeIDFromLayout(layoutIDTransfer{})
+	require.NoError(t, err)
+	payment, err := TypeIDFromLayout(&layoutIDPayment{})
+	require.NoError(t, err)
+	assert.Equal(t, transfer, payment)
+	// The TypeID must not change across versions for the same layout.
+	assert.Equal(t, TypeID{0xee, 0xda, 0x8e, 0x95, 0x00, 0x53, 0x7f, 0x70}, transfer)
+
+	for _, v := range []interface{}{
+		struct {
+			From   [32]byte
+			Amount uint32
+			Memo   string `bin:"optional"`
+		}{},
+		struct {
+			From   [32]byte
+			Amount uint64 `bin:"big"`
+			Memo   string `bin:"optional"`
+		}{},
+		struct {
+			From   [32]byte
+			Amount uint64
+			Memo   string
+		}{},
+		struct {
+			Amount uint64
+			From   [32]byte
+			Memo   string `bin:"optional"`
+		}{},
+	} {
+		id, err := TypeIDFromLayout(v)
+		require.NoError(t, err)
+		assert.NotEqual(t, transfer, id, "%T", v)
+	}
+
+	borsh, err := TypeIDFromLayoutWithEncoding(layoutIDTransfer{}, EncodingBorsh)
+	require.NoError(t, err)
+	assert.NotEqual(t, transfer, borsh)
+
+	tree, err := TypeIDFromLayout(layoutIDTree{})
+	require.NoError(t, err)
+	again, err := TypeIDFromLayout(layoutIDTree{})
+	require.NoError(t, err)
+	assert.Equal(t, tree, again)
+
+	_, err = TypeIDFromLayout(nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,240 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
+
+// lenPrefixes are the values of the `lenprefix` tag.
+var lenPrefixes = map[string]lengthPrefix{
+	"u8":      prefixUint8,
+	"u16":     prefixUint16,
+	"u32":     prefixUint32,
+	"u64":     prefixUint64,
+	"uvarint": prefixUvarint,
+}
+
+// minSize returns the smallest number of bytes a length prefix of this
+// type takes on the wire.
+func (p lengthPrefix) minSize() int {
+	switch p {
+	case prefixUint16:
+		return TypeSize.Uint16
+	case prefixUint32:
+		return TypeSize.Uint32
+	case prefixUint64:
+		return TypeSize.Uint64
+	}
+	// u8 and uvarint prefixes.
+	return 1
+}
+
+// writeLenPrefix writes the length l as a length prefix of the provided
+// type; the fixed-width ones are in the prefix order of the current field.
+func (e *Encoder) writeLenPrefix(p lengthPrefix, l int) error {
+	switch p {
+	case prefixUint8:
+		if l > math.MaxUint8 {
+			return fmt.Errorf("length %d overflows its u8 prefix", l)
+		}
+		return e.WriteUint8(uint8(l))
+	case prefixUint16:
+		if l > math.MaxUint16 {
+			return fmt.Errorf("length %d overflows its u16 prefix", l)
+		}
+		return e.WriteUint16(uint16(l), e.lengthOrder())
+	case prefixUint32:
+		if uint64(l) > math.MaxUint32 {
+			return fmt.Errorf("length %d overflows its u32 prefix", l)
+		}
+		return e.WriteUint32(uint32(l), e.lengthOrder())
+	case prefixUint64:
+		return e.WriteUint64(uint64(l), e.lengthOrder())
+	}
+	return e.WriteUVarInt(l)
+}
+
+// readLenPrefix reads a length prefix of the provided type.
+func (dec *Decoder) readLenPrefix(p lengthPrefix) (int, error) {
+	var l uint64
+	switch p {
+	case prefixUint8:
+		v, err := dec.ReadUint8()
+		if err != nil {
+			return 0, err
+		}
+		l = uint64(v)
+	case prefixUint16:
+		v, err := dec.ReadUint16(dec.lengthOrder())
+		if err != nil {
+			return 0, err
+		}
+		l = uint64(v)
+	case prefixUint32:
+		v, err := dec.ReadUint32(dec.lengthOrder())
+		if err != nil {
+			return 0, err
+		}
+		l = uint64(v)
+	case prefixUint64:
+		v, err := dec.ReadUint64(dec.lengthOrder())
+		if err != nil {
+			return 0, err
+		}
+		l = v
+	default:
+		v, err := dec.ReadUvarint64()
+		if err != nil {
+			return 0, err
+		}
+		l = v
+	}
+	if l > 0x7FFF_FFFF {
+		// Lengths that don't fit are reported like those that don't fit in the input.
+		return 0, io.ErrUnexpectedEOF
+	}
+	return int(l), nil
+}
+
+// encodeLenPrefixed encodes a string, a slice or a map with the length
+// prefix of its `lenprefix` tag, instead of the one of the encoding.
+func (e *Encoder) encodeLenPrefixed(rv reflect.Value, opt *option) (bool, error) {
+	if opt == nil || opt.LenPrefix == prefixNone || opt.hasSizeOfSlice() || e.heap != nil {
+		return false, nil
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		if err := e.writeLenPrefix(opt.LenPrefix, rv.Len()); err != nil {
+			return true, err
+		}
+		return true, e.WriteBytes([]byte(rv.String()), false)
+	case reflect.Slice:
+		l := rv.Len()
+		if err := e.writeLenPrefix(opt.LenPrefix, l); err != nil {
+			return true, err
+		}
+		switch k := rv.Type().Elem().Kind(); k {
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return true, reflect_writeArrayOfUint_(e, l, k, rv, e.elemOrder())
+		}
+		for i := 0; i < l; i++ {
+			if err := e.encodeWithOption(rv.Index(i), nil); err != nil {
+				return true, newElementError("encoding", i, err)
+			}
+		}
+		return true, nil
+	case reflect.Map:
+		if err := e.writeLenPrefix(opt.LenPrefix, rv.Len()); err != nil {
+			return true, err
+		}
+		return true, e.encodeMapEntries(rv)
+	}
+	return false, nil
+}
+
+// decodeLenPrefixed decodes a string, a slice or a map
+// with the length prefix of its `lenprefix` tag.
+func (dec *Decoder) decodeLenPrefixed(rv reflect.Value, opt *option) (bool, error) {
+	if opt == nil || opt.LenPrefix == prefixNone || opt.hasSizeOfSlice() || dec.heap != nil {
+		return false, nil
+	}
+	rt := rv.Type()
+	switch rt.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+	default:
+		return false, nil
+	}
+	l, err := dec.readLenPrefix(opt.LenPrefix)
+	if err != nil {
+		return true, err
+	}
+	switch rt.Kind() {
+	case reflect.String:
+		b, err := dec.ReadNBytes(l)
+		if err != nil {
+			return true, err
+		}
+		rv.SetString(string(b))
+		return true, nil
+	case reflect.Map:
+		return true, dec.decodeMapEntries(rv, l)
+	}
+	if l > dec.Remaining() {
+		return true, io.ErrUnexpectedEOF
+	}
+	if err := dec.checkLength(l, rt.Elem()); err != nil {
+		return true, err
+	}
+	switch k := rt.Elem().Kind(); k {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true, reflect_readArrayOfUint_(dec, l, k, rv, dec.elemOrder())
+	}
+	rv.Set(reflect.MakeSlice(rt, 0, 0))
+	for i := 0; i < l; i++ {
+		element := reflect.New(rt.Elem())
+		if err := dec.decodeWithOption(element.Elem(), nil); err != nil {
+			return true, newElementError("decoding", i, err)
+		}
+		rv.Set(reflect.Append(rv, element.Elem()))
+	}
+	return true, nil
+}
+
+// encodeMapEntries encodes the entries of a map, without its length,
+// in the order of their keys.
+func (e *Encoder) encodeMapEntries(rv reflect.Value) error {
+	keys, err := e.sortedMapKeys(rv)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := e.encodeWithOption(key, nil); err != nil {
+			return err
+		}
+		if err := e.encodeWithOption(rv.MapIndex(key), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeMapEntries decodes the l entries of a map whose length has been read.
+func (dec *Decoder) decodeMapEntries(rv reflect.Value, l int) error {
+	if l == 0 {
+		// If the map has no content, keep it nil.
+		return nil
+	}
+	rt := rv.Type()
+	if err := dec.checkLength(l, rt.Key(), rt.Elem()); err != nil {
+		return err
+	}
+	rv.Set(reflect.MakeMap(rt))
+	for i := 0; i < l; i++ {
+		key := reflect.New(rt.Key())
+		if err := dec.decodeWithOption(key.Elem(), nil); err != nil {
+			return err
+		}
+		val := reflect.New(rt.Elem())
+		if err := dec.decodeWithOption(val.Elem(), nil); err != nil {
+			return err
+		}
+		rv.SetMapIndex(key.Elem(), val.Elem())
+	}
+	return nil
+}
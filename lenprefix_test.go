@@ -0,0 +1,142 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type lenPrefixRecord struct {
+	Name   string            `bin:"lenprefix=u8"`
+	Values []uint16          `bin:"lenprefix=u16 prefix=big"`
+	Tags   []string          `bin:"lenprefix=u32"`
+	Attrs  map[string]uint8  `bin:"lenprefix=uvarint"`
+	Data   []byte            `bin:"lenprefix=u64"`
+	Extra  map[uint8]Float16 `bin:"lenprefix=u8"`
+}
+
+func TestLenPrefix(t *testing.T) {
+	in := lenPrefixRecord{
+		Name:   "ab",
+		Values: []uint16{1, 2},
+		Tags:   []string{"c"},
+		Attrs:  map[string]uint8{"k": 7},
+		Data:   []byte{0xff},
+	}
+
+	data, err := MarshalBin(&in)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		2, 'a', 'b',
+		0, 2, 1, 0, 2, 0,
+		1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 'c',
+		1, 1, 0, 0, 0, 0, 0, 0, 0, 'k', 7,
+		1, 0, 0, 0, 0, 0, 0, 0, 0xff,
+		0,
+	}, data)
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		data, err := MarshalAppend(nil, &in, enc)
+		require.NoError(t, err)
+		var out lenPrefixRecord
+		require.NoError(t, NewDecoderWithEncoding(data, enc).Decode(&out))
+		assert.Equal(t, in, out, enc.String())
+		require.NoError(t, ConformsWithEncoding(data, enc, lenPrefixRecord{}), enc.String())
+	}
+
+	explained, err := ExplainTypeWithEncoding(lenPrefixRecord{}, EncodingBorsh)
+	require.NoError(t, err)
+	assert.Contains(t, explained, "u16,BE")
+	ksy, err := KaitaiStruct(lenPrefixRecord{}, EncodingBorsh)
+	require.NoError(t, err)
+	assert.Contains(t, ksy, "type: u1")
+	assert.Contains(t, ksy, "type: u2be")
+
+	res, err := Query(data, lenPrefixRecord{}, "Values[1]")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(2), res.Value)
+
+	// A string too long for its prefix.
+	_, err = MarshalBin(&lenPrefixRecord{Name: strings.Repeat("x", 256)})
+	assert.EqualError(t, err, `error while encoding "Name" field: length 256 overflows its u8 prefix`)
+
+	// A truncated input.
+	var out lenPrefixRecord
+	assert.Error(t, UnmarshalBin(&out, data[:7]))
+}
+
+func TestLenPrefix_Elements(t *testing.T) {
+	type element struct {
+		S string          `bin:"lenprefix=u8"`
+		B []uint8         `bin:"lenprefix=u8"`
+		M map[uint8]uint8 `bin:"lenprefix=u8"`
+	}
+	in := []element{
+		{S: "a", B: []uint8{1}, M: map[uint8]uint8{2: 3}},
+		{},
+	}
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		data, err := MarshalAppend(nil, &in, enc)
+		require.NoError(t, err)
+		var out []element
+		require.NoError(t, NewDecoderWithEncoding(data, enc).Decode(&out), enc.String())
+		assert.Equal(t, []element{
+			{S: "a", B: []uint8{1}, M: map[uint8]uint8{2: 3}},
+			{S: "", B: []uint8{}},
+		}, out, enc.String())
+	}
+}
+
+func TestLenPrefix_MapKeyOrder(t *testing.T) {
+	type untagged struct {
+		M map[float64]uint8
+	}
+	type tagged struct {
+		M map[float64]uint8 `bin:"lenprefix=u32"`
+	}
+	// The little-endian encoding of -1 sorts after the one of 1.
+	m := map[float64]uint8{1: 1, -1: 2, 0.5: 3, -2: 4}
+
+	want, err := MarshalBorsh(&untagged{M: m})
+	require.NoError(t, err)
+	got, err := MarshalBorsh(&tagged{M: m})
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 0xc0, 4}, want[4:13])
+}
+
+func TestLenPrefix_Errors(t *testing.T) {
+	type notPrefixed struct {
+		N uint32 `bin:"lenprefix=u8"`
+	}
+	assert.EqualError(t, Precompile(notPrefixed{}), "precompile: bin.notPrefixed: field \"N\": the lenprefix tag only applies to strings, slices and maps, got uint32")
+
+	type withSizeOf struct {
+		Count uint8  `bin:"sizeof=Items"`
+		Items []byte `bin:"lenprefix=u8"`
+	}
+	assert.EqualError(t, Precompile(withSizeOf{}), "precompile: bin.withSizeOf: field \"Items\": the lenprefix tag can't be combined with sizeof, strlen or cstring")
+
+	type withDelta struct {
+		Items []uint32 `bin:"delta lenprefix=u8"`
+	}
+	assert.EqualError(t, Precompile(withDelta{}), "precompile: bin.withDelta: field \"Items\": the lenprefix tag can't be combined with varint, delta, rle, swap, bitreverse, rune, compress or encrypt tags")
+	assert.Equal(t, []string{"lenprefix=u24"}, parseFieldTag(`bin:"lenprefix=u24"`).Invalid)
+}
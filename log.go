@@ -0,0 +1,359 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// A log is an append-only sequence of records, e.g. a write-ahead log, made
+// of checksummed records and of sync markers from which a reader can resume
+// after a corrupted or torn record:
+//
+//	marker: "BINLSYNC", u8 version, u8 encoding of the records, u64 LE index
+//	        of the next record, and u32 LE CRC-32C of the version, encoding and index
+//	record: u32 LE size of the record, u32 LE CRC-32C of the record, and the
+//	        encoded record
+//
+// A log starts with a marker, and a LogWriter writes one every
+// SyncInterval records. Records are never larger than MaxLogRecordSize,
+// so that their size can't be mistaken for the start of a marker.
+const (
+	logSyncMagic   = "BINLSYNC"
+	logVersion     = 1
+	logMarkerSize  = len(logSyncMagic) + 2 + 8 + 4
+	logRecordFrame = 8
+
+	// DefaultLogSyncInterval is the default number of records between sync markers.
+	DefaultLogSyncInterval = 64
+	// MaxLogRecordSize is the largest size of an encoded log record.
+	MaxLogRecordSize = 1 << 30
+)
+
+var (
+	// ErrInvalidLog is returned when reading data that doesn't start with a log sync marker.
+	ErrInvalidLog = errors.New("invalid log")
+	// ErrLogCorrupted is returned when a log record doesn't match its checksum,
+	// or its size is invalid; LogReader.Resync skips to the next sync marker.
+	ErrLogCorrupted = errors.New("log record corrupted")
+)
+
+var logTable = crc32.MakeTable(crc32.Castagnoli)
+
+// LogOptions configures a LogWriter.
+type LogOptions struct {
+	// SyncInterval is the number of records between sync markers;
+	// it defaults to DefaultLogSyncInterval.
+	SyncInterval int
+	// FirstIndex is the index of the first record appended, e.g. the
+	// number of records already in the log that the writer appends to.
+	FirstIndex uint64
+}
+
+// A LogWriter appends records to a log (see NewLogReader):
+//
+//	f, err := os.OpenFile("wal", os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+//	...
+//	lw := bin.NewLogWriter(f, bin.EncodingBorsh, bin.LogOptions{})
+//	if err := lw.Append(entry); err != nil {
+//		return err
+//	}
+//
+// It starts with a sync marker, so a new LogWriter can append to an existing
+// log. Each record, with the marker before it if any, is written with a single
+// call to the underlying writer. Write errors are sticky: after a failed write,
+// the following calls return the same error. A LogWriter isn't safe for
+// concurrent use.
+type LogWriter struct {
+	w         io.Writer
+	enc       Encoding
+	interval  int
+	next      uint64
+	sinceSync int
+	buf       []byte
+	err       error
+}
+
+// NewLogWriter returns a writer of records encoded with the provided encoding.
+// Nothing is written before the first record.
+func NewLogWriter(w io.Writer, enc Encoding, opts LogOptions) *LogWriter {
+	if !isValidEncoding(enc) {
+		panic(fmt.Sprintf("provided encoding is not valid: %s", enc))
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = DefaultLogSyncInterval
+	}
+	return &LogWriter{
+		w:        w,
+		enc:      enc,
+		interval: opts.SyncInterval,
+		next:     opts.FirstIndex,
+	}
+}
+
+// NextIndex returns the index of the next record appended.
+func (lw *LogWriter) NextIndex() uint64 {
+	return lw.next
+}
+
+// Append encodes v as the next record of the log.
+func (lw *LogWriter) Append(v interface{}) error {
+	if lw.err != nil {
+		return lw.err
+	}
+	data, err := encodingCodec(lw.enc).Marshal(v)
+	if err != nil {
+		return fmt.Errorf("log: record %d: %w", lw.next, err)
+	}
+	if len(data) > MaxLogRecordSize {
+		return fmt.Errorf("log: record %d: size %d exceeds %d bytes", lw.next, len(data), MaxLogRecordSize)
+	}
+
+	frame := lw.buf[:0]
+	if lw.sinceSync == 0 {
+		frame = appendLogMarker(frame, lw.enc, lw.next)
+	}
+	var head [logRecordFrame]byte
+	binary.LittleEndian.PutUint32(head[:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(head[4:], crc32.Checksum(data, logTable))
+	frame = append(append(frame, head[:]...), data...)
+	lw.buf = frame[:0]
+
+	n, err := lw.w.Write(frame)
+	if err == nil && n < len(frame) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		lw.err = fmt.Errorf("log: %w", err)
+		return lw.err
+	}
+	lw.next++
+	lw.sinceSync = (lw.sinceSync + 1) % lw.interval
+	return nil
+}
+
+func appendLogMarker(b []byte, enc Encoding, next uint64) []byte {
+	b = append(b, logSyncMagic...)
+	start := len(b)
+	b = append(b, logVersion, byte(enc))
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], next)
+	b = append(b, buf[:]...)
+	binary.LittleEndian.PutUint32(buf[:], crc32.Checksum(b[start:], logTable))
+	return append(b, buf[:4]...)
+}
+
+// parseLogMarker returns the encoding and the index of the next record
+// of a sync marker, and false if it's not a valid one.
+func parseLogMarker(b []byte) (Encoding, uint64, bool) {
+	if len(b) < logMarkerSize || string(b[:len(logSyncMagic)]) != logSyncMagic {
+		return 0, 0, false
+	}
+	body := b[len(logSyncMagic) : logMarkerSize-4]
+	if crc32.Checksum(body, logTable) != binary.LittleEndian.Uint32(b[logMarkerSize-4:]) {
+		return 0, 0, false
+	}
+	enc := Encoding(body[1])
+	if body[0] != logVersion || !isValidEncoding(enc) {
+		return 0, 0, false
+	}
+	return enc, binary.LittleEndian.Uint64(body[2:]), true
+}
+
+// A LogReader reads the records of a log written by LogWriter:
+//
+//	lr := bin.NewLogReader(f)
+//	for {
+//		var entry Entry
+//		err := lr.Next(&entry)
+//		if err == io.EOF {
+//			break
+//		}
+//		if errors.Is(err, bin.ErrLogCorrupted) {
+//			skipped, err := lr.Resync()
+//			...
+//			continue
+//		}
+//		...
+//	}
+//
+// A record whose checksum doesn't match fails with ErrLogCorrupted, and a
+// truncated one (e.g. torn by a crash) with io.ErrUnexpectedEOF. After
+// either error, Next keeps failing until Resync skips to the next sync marker.
+type LogReader struct {
+	r      *bufio.Reader
+	enc    Encoding
+	synced bool
+	next   uint64
+	offset int64
+	// pending holds the bytes of a bad record after its first one,
+	// which Resync looks for a marker in before the rest of the log.
+	pending []byte
+	err     error
+}
+
+// NewLogReader returns a reader of the records of a log.
+func NewLogReader(r io.Reader) *LogReader {
+	return &LogReader{r: bufio.NewReader(r)}
+}
+
+// Encoding returns the encoding of the records, as of the last sync marker.
+func (lr *LogReader) Encoding() Encoding {
+	return lr.enc
+}
+
+// Index returns the index of the next record.
+func (lr *LogReader) Index() uint64 {
+	return lr.next
+}
+
+// Offset returns the offset in the log of the next record.
+func (lr *LogReader) Offset() int64 {
+	return lr.offset
+}
+
+// Next decodes the next record into v, which must be a pointer.
+// It returns io.EOF after the last record.
+func (lr *LogReader) Next(v interface{}) error {
+	data, err := lr.nextRecord()
+	if err != nil {
+		return err
+	}
+	dec := NewDecoderWithEncoding(data, lr.enc)
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("log: record %d: %w", lr.next-1, err)
+	}
+	if dec.HasRemaining() {
+		return fmt.Errorf("log: record %d: %d bytes left after the value", lr.next-1, dec.Remaining())
+	}
+	return nil
+}
+
+// nextRecord reads the sync markers before the next record, and the record.
+func (lr *LogReader) nextRecord() ([]byte, error) {
+	if lr.err != nil {
+		return nil, lr.err
+	}
+	for {
+		head, err := lr.r.Peek(len(logSyncMagic))
+		if len(head) == 0 && err == io.EOF {
+			return nil, io.EOF
+		}
+		if string(head) == logSyncMagic {
+			if err := lr.readMarker(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !lr.synced {
+			lr.err = fmt.Errorf("log: no sync marker at offset %d: %w", lr.offset, ErrInvalidLog)
+			return nil, lr.err
+		}
+		return lr.readRecord()
+	}
+}
+
+func (lr *LogReader) readMarker() error {
+	marker := make([]byte, logMarkerSize)
+	n, err := io.ReadFull(lr.r, marker)
+	if err != nil {
+		return lr.fail(marker[1:n], fmt.Errorf("log: sync marker at offset %d: %w", lr.offset, unexpectedEOF(err)))
+	}
+	enc, next, ok := parseLogMarker(marker)
+	if !ok {
+		return lr.fail(marker[1:], fmt.Errorf("log: sync marker at offset %d: %w", lr.offset, ErrLogCorrupted))
+	}
+	lr.enc, lr.next, lr.synced = enc, next, true
+	lr.offset += int64(logMarkerSize)
+	return nil
+}
+
+func (lr *LogReader) readRecord() ([]byte, error) {
+	frame := make([]byte, logRecordFrame)
+	n, err := io.ReadFull(lr.r, frame)
+	if err != nil {
+		return nil, lr.fail(frame[1:n], fmt.Errorf("log: record %d at offset %d: %w", lr.next, lr.offset, unexpectedEOF(err)))
+	}
+	size := binary.LittleEndian.Uint32(frame)
+	if size > MaxLogRecordSize {
+		return nil, lr.fail(frame[1:], fmt.Errorf("log: record %d at offset %d: size %d: %w", lr.next, lr.offset, size, ErrLogCorrupted))
+	}
+	frame = append(frame, make([]byte, size)...)
+	n, err = io.ReadFull(lr.r, frame[logRecordFrame:])
+	if err != nil {
+		return nil, lr.fail(frame[1:logRecordFrame+n], fmt.Errorf("log: record %d at offset %d: %w", lr.next, lr.offset, unexpectedEOF(err)))
+	}
+	data := frame[logRecordFrame:]
+	if crc32.Checksum(data, logTable) != binary.LittleEndian.Uint32(frame[4:]) {
+		return nil, lr.fail(frame[1:], fmt.Errorf("log: record %d at offset %d: %w", lr.next, lr.offset, ErrLogCorrupted))
+	}
+	lr.next++
+	lr.offset += int64(len(frame))
+	return data, nil
+}
+
+// fail makes err sticky until Resync, which looks for a marker
+// in pending (the bytes read after the start of the bad frame).
+func (lr *LogReader) fail(pending []byte, err error) error {
+	lr.pending = append([]byte{}, pending...)
+	lr.err = err
+	return err
+}
+
+// Resync skips to the next sync marker after a corrupted or truncated record,
+// and returns the number of bytes skipped; reading resumes at the marker,
+// with the index of the record after it. It returns io.EOF if there's no
+// marker left in the log.
+func (lr *LogReader) Resync() (int64, error) {
+	if lr.err == nil || lr.err == io.EOF {
+		return 0, lr.err
+	}
+	skipped := int64(0)
+	if lr.pending != nil {
+		// The bad frame starts one byte before the pending ones.
+		skipped = 1
+		lr.r = bufio.NewReader(io.MultiReader(bytes.NewReader(lr.pending), lr.r))
+		lr.pending = nil
+	}
+	for {
+		head, err := lr.r.Peek(logMarkerSize)
+		if _, _, ok := parseLogMarker(head); ok {
+			lr.offset += skipped
+			lr.err = nil
+			return skipped, nil
+		}
+		if len(head) < logMarkerSize && err != nil {
+			n, _ := lr.r.Discard(len(head))
+			lr.offset += skipped + int64(n)
+			lr.err = io.EOF
+			return skipped + int64(n), io.EOF
+		}
+		lr.r.Discard(1)
+		skipped++
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
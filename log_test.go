@@ -0,0 +1,135 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type logEntry struct {
+	Seq  uint64
+	Note string
+}
+
+func writeTestLog(t *testing.T, n int, opts LogOptions) []byte {
+	buf := new(bytes.Buffer)
+	lw := NewLogWriter(buf, EncodingBorsh, opts)
+	for i := 0; i < n; i++ {
+		require.NoError(t, lw.Append(logEntry{Seq: opts.FirstIndex + uint64(i), Note: "entry"}))
+	}
+	assert.Equal(t, opts.FirstIndex+uint64(n), lw.NextIndex())
+	return buf.Bytes()
+}
+
+// readTestLog reads the records of a log, resyncing after bad
+// ones, and returns the sequence numbers of the records read.
+func readTestLog(t *testing.T, data []byte) (seqs []uint64, errs []error) {
+	lr := NewLogReader(bytes.NewReader(data))
+	for {
+		var got logEntry
+		err := lr.Next(&got)
+		if err == io.EOF {
+			return seqs, errs
+		}
+		if err != nil {
+			errs = append(errs, err)
+			if _, err := lr.Resync(); err == io.EOF {
+				return seqs, errs
+			}
+			continue
+		}
+		require.Equal(t, got.Seq+1, lr.Index())
+		seqs = append(seqs, got.Seq)
+	}
+}
+
+func seqRange(from, to uint64) (out []uint64) {
+	for i := from; i < to; i++ {
+		out = append(out, i)
+	}
+	return out
+}
+
+func TestLog(t *testing.T) {
+	data := writeTestLog(t, 10, LogOptions{SyncInterval: 4})
+	assert.Equal(t, 3, bytes.Count(data, []byte(logSyncMagic)))
+
+	seqs, errs := readTestLog(t, data)
+	assert.Empty(t, errs)
+	assert.Equal(t, seqRange(0, 10), seqs)
+
+	// A second writer appends to the same log.
+	data = append(data, writeTestLog(t, 5, LogOptions{FirstIndex: 10})...)
+	seqs, errs = readTestLog(t, data)
+	assert.Empty(t, errs)
+	assert.Equal(t, seqRange(0, 15), seqs)
+
+	lr := NewLogReader(bytes.NewReader(data))
+	var got logEntry
+	require.NoError(t, lr.Next(&got))
+	assert.Equal(t, EncodingBorsh, lr.Encoding())
+	assert.Equal(t, int64(logMarkerSize+logRecordFrame+8+4+5), lr.Offset())
+}
+
+func TestLog_Corrupted(t *testing.T) {
+	data := writeTestLog(t, 12, LogOptions{SyncInterval: 4})
+	record := logRecordFrame + 8 + 4 + 5
+
+	// A flipped bit in record 5 loses the records up to the next marker.
+	corrupted := append([]byte(nil), data...)
+	corrupted[2*logMarkerSize+5*record+logRecordFrame] ^= 0x01
+	seqs, errs := readTestLog(t, corrupted)
+	require.Len(t, errs, 1)
+	assert.True(t, errors.Is(errs[0], ErrLogCorrupted), errs[0])
+	assert.EqualError(t, errs[0], "log: record 5 at offset 169: log record corrupted")
+	assert.Equal(t, append(seqRange(0, 5), seqRange(8, 12)...), seqs)
+
+	// So does an invalid size.
+	corrupted = append([]byte(nil), data...)
+	corrupted[logMarkerSize+1*record+3] = 0xff
+	seqs, errs = readTestLog(t, corrupted)
+	require.Len(t, errs, 1)
+	assert.True(t, errors.Is(errs[0], ErrLogCorrupted), errs[0])
+	assert.Equal(t, append(seqRange(0, 1), seqRange(4, 12)...), seqs)
+
+	// A torn last record is truncated.
+	seqs, errs = readTestLog(t, data[:len(data)-3])
+	require.Len(t, errs, 1)
+	assert.True(t, errors.Is(errs[0], io.ErrUnexpectedEOF), errs[0])
+	assert.Equal(t, seqRange(0, 11), seqs)
+
+	// Data before the first marker is skipped by Resync.
+	seqs, errs = readTestLog(t, append([]byte("garbage"), data...))
+	require.Len(t, errs, 1)
+	assert.True(t, errors.Is(errs[0], ErrInvalidLog), errs[0])
+	assert.Equal(t, seqRange(0, 12), seqs)
+
+	lr := NewLogReader(bytes.NewReader(corrupted))
+	var got logEntry
+	require.NoError(t, lr.Next(&got))
+	assert.True(t, errors.Is(lr.Next(&got), ErrLogCorrupted))
+	assert.True(t, errors.Is(lr.Next(&got), ErrLogCorrupted), "errors are sticky until Resync")
+	skipped, err := lr.Resync()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3*record), skipped)
+	require.NoError(t, lr.Next(&got))
+	assert.Equal(t, uint64(4), got.Seq)
+}
@@ -15,6 +15,9 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+//go:build !tinygo && !nozap
+// +build !tinygo,!nozap
+
 package bin
 
 import (
@@ -24,6 +27,13 @@ import (
 	"go.uber.org/zap"
 )
 
+// logger is the logger of the package; builds tagged `tinygo` or `nozap`
+// replace it with a no-op one that doesn't depend on zap (see logging_nozap.go).
+type logger = zap.Logger
+
+// logField is a structured field of a log entry.
+type logField = zap.Field
+
 var (
 	zlog         = zap.NewNop()
 	traceEnabled = false
@@ -35,12 +45,45 @@ func init() {
 	zlog = zlog_
 }
 
+// WithTraceLogger makes the encoder trace to l instead of the package
+// logger; it doesn't enable tracing (see WithTracing).
+func (e *Encoder) WithTraceLogger(l *zap.Logger) *Encoder {
+	e.traceLog = l
+	return e
+}
+
+// WithTraceLogger makes the decoder trace to l instead of the package
+// logger; it doesn't enable tracing (see WithTracing).
+func (dec *Decoder) WithTraceLogger(l *zap.Logger) *Decoder {
+	dec.traceLog = l
+	return dec
+}
+
 type logStringerFunc func() string
 
 func (f logStringerFunc) String() string { return f() }
 
-func typeField(field string, v interface{}) zap.Field {
+func typeField(field string, v interface{}) logField {
 	return zap.Stringer(field, logStringerFunc(func() string {
 		return fmt.Sprintf("%T", v)
 	}))
 }
+
+var (
+	logBinary   = zap.Binary
+	logBool     = zap.Bool
+	logFloat32  = zap.Float32
+	logFloat64  = zap.Float64
+	logInt      = zap.Int
+	logInt8     = zap.Int8
+	logInt16    = zap.Int16
+	logInt32    = zap.Int32
+	logInt64    = zap.Int64
+	logReflect  = zap.Reflect
+	logString   = zap.String
+	logStringer = zap.Stringer
+	logUint8    = zap.Uint8
+	logUint16   = zap.Uint16
+	logUint32   = zap.Uint32
+	logUint64   = zap.Uint64
+)
@@ -0,0 +1,65 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build tinygo || nozap
+// +build tinygo nozap
+
+package bin
+
+import (
+	"fmt"
+)
+
+// Builds tagged `tinygo` (set by the TinyGo compiler) or `nozap` don't depend
+// on zap nor on streamingfast/logging: logging is a no-op and tracing is disabled.
+
+type logger struct{}
+
+type logField struct{}
+
+var (
+	zlog         = &logger{}
+	traceEnabled = false
+)
+
+// WithTraceLogger does nothing, tracing being disabled; l is the *zap.Logger
+// of the other builds.
+func (e *Encoder) WithTraceLogger(l interface{}) *Encoder { return e }
+
+// WithTraceLogger does nothing, tracing being disabled; l is the *zap.Logger
+// of the other builds.
+func (dec *Decoder) WithTraceLogger(l interface{}) *Decoder { return dec }
+
+func (l *logger) Debug(msg string, fields ...logField) {}
+
+func (l *logger) Named(name string) *logger { return l }
+
+func typeField(field string, v interface{}) logField { return logField{} }
+
+func logBinary(key string, val []byte) logField         { return logField{} }
+func logBool(key string, val bool) logField             { return logField{} }
+func logFloat32(key string, val float32) logField       { return logField{} }
+func logFloat64(key string, val float64) logField       { return logField{} }
+func logInt(key string, val int) logField               { return logField{} }
+func logInt8(key string, val int8) logField             { return logField{} }
+func logInt16(key string, val int16) logField           { return logField{} }
+func logInt32(key string, val int32) logField           { return logField{} }
+func logInt64(key string, val int64) logField           { return logField{} }
+func logReflect(key string, val interface{}) logField   { return logField{} }
+func logString(key string, val string) logField         { return logField{} }
+func logStringer(key string, val fmt.Stringer) logField { return logField{} }
+func logUint8(key string, val uint8) logField           { return logField{} }
+func logUint16(key string, val uint16) logField         { return logField{} }
+func logUint32(key string, val uint32) logField         { return logField{} }
+func logUint64(key string, val uint64) logField         { return logField{} }
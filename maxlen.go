@@ -0,0 +1,63 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrMaxLen is returned for strings longer than the
+// maximum length set by their `maxlen=N` tag.
+var ErrMaxLen = errors.New("string exceeds maxlen")
+
+// decodeBoundedString decodes a string field tagged `maxlen=N`. With the
+// `truncate` flag (`maxlen=N,truncate`), longer strings are truncated to their
+// first N bytes, with a warning, instead of failing: for legacy data
+// written before the limit was enforced.
+func (dec *Decoder) decodeBoundedString(rv reflect.Value, opt *option) (bool, error) {
+	if opt == nil || opt.MaxLen == 0 || rv.Kind() != reflect.String {
+		return false, nil
+	}
+	var b []byte
+	var err error
+	if dec.IsBin() {
+		b, err = dec.readRustStringBytes()
+	} else {
+		b, err = dec.ReadByteSlice()
+	}
+	if err != nil {
+		return true, err
+	}
+	if len(b) > opt.MaxLen {
+		if !opt.Truncate {
+			return true, fmt.Errorf("%d bytes, maxlen=%d: %w", len(b), opt.MaxLen, ErrMaxLen)
+		}
+		dec.warnf("string of %d bytes truncated to maxlen=%d", len(b), opt.MaxLen)
+		b = b[:opt.MaxLen]
+	}
+	rv.SetString(string(b))
+	return true, nil
+}
+
+// checkMaxLen rejects the strings longer than their `maxlen=N` tag allows;
+// they are never truncated when encoding.
+func checkMaxLen(rv reflect.Value, opt *option) error {
+	if opt == nil || opt.MaxLen == 0 || rv.Kind() != reflect.String || rv.Len() <= opt.MaxLen {
+		return nil
+	}
+	return fmt.Errorf("%d bytes, maxlen=%d: %w", rv.Len(), opt.MaxLen, ErrMaxLen)
+}
@@ -0,0 +1,91 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type maxLenName struct {
+	First string `bin:"maxlen=4,truncate"`
+	Last  string `bin:"maxlen=4"`
+}
+
+type maxLenRecord struct {
+	ID    uint32
+	Names []maxLenName
+	Note  *string `bin:"optional maxlen=3,truncate"`
+}
+
+func TestMaxLen_Truncate(t *testing.T) {
+	type legacyName struct {
+		First string
+		Last  string
+	}
+	type legacyRecord struct {
+		ID    uint32
+		Names []legacyName
+		Note  *string `bin:"optional"`
+	}
+	note := "long note"
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&legacyRecord{
+			ID:    1,
+			Names: []legacyName{{"Al", "Li"}, {"Alexander", "Ng"}},
+			Note:  &note,
+		}))
+		data := buf.Bytes()
+
+		var got maxLenRecord
+		dec := NewDecoderWithEncoding(data, enc)
+		require.NoError(t, dec.Decode(&got), enc)
+		assert.Equal(t, []maxLenName{{"Al", "Li"}, {"Alex", "Ng"}}, got.Names, enc)
+		assert.Equal(t, "lon", *got.Note, enc)
+		assert.Equal(t, []Warning{
+			{Field: "Names.First", Message: "string of 9 bytes truncated to maxlen=4"},
+			{Field: "Note", Message: "string of 9 bytes truncated to maxlen=3"},
+		}, dec.Warnings(), enc)
+
+		dec.Reset(data)
+		assert.Empty(t, dec.Warnings())
+	}
+}
+
+func TestMaxLen_Errors(t *testing.T) {
+	data, err := MarshalBin(&struct {
+		First string
+		Last  string
+	}{"Al", "Alexander"})
+	require.NoError(t, err)
+	var name maxLenName
+	err = UnmarshalBin(&name, data)
+	assert.True(t, errors.Is(err, ErrMaxLen))
+	assert.EqualError(t, err, `error while decoding "Last" field: 9 bytes, maxlen=4: string exceeds maxlen`)
+
+	_, err = MarshalBin(&maxLenName{First: "Alexander"})
+	assert.EqualError(t, err, `error while encoding "First" field: 9 bytes, maxlen=4: string exceeds maxlen`)
+
+	type notString struct {
+		A []byte `bin:"maxlen=4"`
+	}
+	assert.EqualError(t, Precompile(notString{}), `precompile: bin.notString: field "A": the maxlen tag only applies to strings, got []uint8`)
+	assert.Equal(t, []string{"maxlen=0,truncate"}, parseFieldTag(`bin:"maxlen=0,truncate"`).Invalid)
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type metricsNested struct {
+	A uint32
+}
+
+func (m metricsNested) MarshalWithEncoder(encoder *Encoder) error {
+	return encoder.Encode(m.A)
+}
+
+func TestEncoder_WithMetrics(t *testing.T) {
+	type call struct {
+		name string
+		size int
+	}
+	var calls []call
+	enc := NewBinEncoder(new(bytes.Buffer)).WithMetrics(func(typeName string, size int, elapsed time.Duration) {
+		assert.True(t, elapsed >= 0)
+		calls = append(calls, call{typeName, size})
+	})
+
+	require.NoError(t, enc.Encode(&metricsNested{A: 1}))
+	require.NoError(t, enc.Encode("hello"))
+	require.NoError(t, enc.Encode([]metricsNested{{1}, {2}}))
+	assert.Equal(t, []call{
+		{"bin.metricsNested", 4},
+		{"string", 13},
+		{"[]bin.metricsNested", 9},
+	}, calls)
+
+	enc.WithMaxEncodedSize(enc.Written() + 1)
+	require.Error(t, enc.Encode(uint64(1)))
+	assert.Len(t, calls, 3)
+}
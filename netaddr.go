@@ -0,0 +1,205 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+)
+
+// An IPFormat is how net.IP values are encoded, selected with the `ip=` tag.
+// With a fixed-size format, nil addresses are encoded as the unspecified
+// address (all zeros).
+type IPFormat int
+
+const (
+	// IPBytes encodes addresses like the other byte slices, with a length
+	// prefix. It's the format of the net.IP values without an `ip=` tag.
+	IPBytes IPFormat = iota
+	// IP16 encodes addresses as 16 bytes (`ip=v6`), IPv4 addresses
+	// being IPv4-mapped; they are decoded as 16-byte net.IP values,
+	// like the ones of net.ParseIP.
+	IP16
+	// IP4 encodes IPv4 addresses as 4 bytes (`ip=v4`);
+	// other addresses fail to encode.
+	IP4
+	// IPUnmapped encodes addresses as 16 bytes, like IP16, but decodes
+	// IPv4-mapped addresses as 4-byte net.IP values (`ip=unmap`).
+	IPUnmapped
+)
+
+func parseIPFormat(s string) (IPFormat, bool) {
+	switch s {
+	case "v6":
+		return IP16, true
+	case "v4":
+		return IP4, true
+	case "unmap":
+		return IPUnmapped, true
+	default:
+		return IPBytes, false
+	}
+}
+
+func (f IPFormat) String() string {
+	switch f {
+	case IPBytes:
+		return "bytes"
+	case IP16:
+		return "v6"
+	case IP4:
+		return "v4"
+	case IPUnmapped:
+		return "unmap"
+	default:
+		return fmt.Sprintf("IPFormat(%d)", int(f))
+	}
+}
+
+// size returns the encoded size of the addresses, for the fixed-size formats.
+func (f IPFormat) size() int {
+	if f == IP4 {
+		return net.IPv4len
+	}
+	return net.IPv6len
+}
+
+// A MACFormat is how net.HardwareAddr values are encoded, selected with
+// the `mac=` tag. With a fixed-size format, nil addresses are encoded as
+// all zeros.
+type MACFormat int
+
+const (
+	// MACBytes encodes addresses like the other byte slices, with a length
+	// prefix. It's the format of the net.HardwareAddr values without a `mac=` tag.
+	MACBytes MACFormat = iota
+	// EUI48 encodes 6-byte addresses as 6 bytes (`mac=eui48`).
+	EUI48
+	// EUI64 encodes 8-byte addresses as 8 bytes (`mac=eui64`).
+	EUI64
+)
+
+func parseMACFormat(s string) (MACFormat, bool) {
+	switch s {
+	case "eui48":
+		return EUI48, true
+	case "eui64":
+		return EUI64, true
+	default:
+		return MACBytes, false
+	}
+}
+
+func (f MACFormat) String() string {
+	switch f {
+	case MACBytes:
+		return "bytes"
+	case EUI48:
+		return "eui48"
+	case EUI64:
+		return "eui64"
+	default:
+		return fmt.Sprintf("MACFormat(%d)", int(f))
+	}
+}
+
+// size returns the encoded size of the addresses, for the fixed-size formats.
+func (f MACFormat) size() int {
+	if f == EUI64 {
+		return 8
+	}
+	return 6
+}
+
+var (
+	ipType           = reflect.TypeOf(net.IP{})
+	hardwareAddrType = reflect.TypeOf(net.HardwareAddr{})
+)
+
+// encodeNetAddr writes net.IP and net.HardwareAddr values in their
+// fixed-size IPFormat or MACFormat, if any; nil values are all zeros.
+func (e *Encoder) encodeNetAddr(rv reflect.Value, opt *option) (bool, error) {
+	switch rv.Type() {
+	case ipType:
+		if opt.IPFormat == IPBytes {
+			return false, nil
+		}
+		ip := rv.Interface().(net.IP)
+		buf := make([]byte, opt.IPFormat.size())
+		if len(ip) > 0 {
+			var addr net.IP
+			if opt.IPFormat == IP4 {
+				addr = ip.To4()
+			} else {
+				addr = ip.To16()
+			}
+			if addr == nil {
+				return true, fmt.Errorf("invalid IP address %s for ip=%s", ip, opt.IPFormat)
+			}
+			copy(buf, addr)
+		}
+		return true, e.WriteBytes(buf, false)
+	case hardwareAddrType:
+		if opt.MACFormat == MACBytes {
+			return false, nil
+		}
+		mac := rv.Interface().(net.HardwareAddr)
+		if len(mac) == 0 {
+			return true, e.WriteBytes(make([]byte, opt.MACFormat.size()), false)
+		}
+		if len(mac) != opt.MACFormat.size() {
+			return true, fmt.Errorf("hardware address %s is not %d bytes long for mac=%s", mac, opt.MACFormat.size(), opt.MACFormat)
+		}
+		return true, e.WriteBytes(mac, false)
+	default:
+		return false, nil
+	}
+}
+
+// decodeNetAddr reads the values written by encodeNetAddr.
+func (dec *Decoder) decodeNetAddr(rv reflect.Value, opt *option) (bool, error) {
+	switch rv.Type() {
+	case ipType:
+		if opt.IPFormat == IPBytes {
+			return false, nil
+		}
+		data, err := dec.ReadNBytes(opt.IPFormat.size())
+		if err != nil {
+			return true, err
+		}
+		ip := make(net.IP, len(data))
+		copy(ip, data)
+		if ip4 := ip.To4(); opt.IPFormat == IPUnmapped && ip4 != nil {
+			ip = ip4
+		}
+		rv.Set(reflect.ValueOf(ip))
+		return true, nil
+	case hardwareAddrType:
+		if opt.MACFormat == MACBytes {
+			return false, nil
+		}
+		data, err := dec.ReadNBytes(opt.MACFormat.size())
+		if err != nil {
+			return true, err
+		}
+		mac := make(net.HardwareAddr, len(data))
+		copy(mac, data)
+		rv.Set(reflect.ValueOf(mac))
+		return true, nil
+	default:
+		return false, nil
+	}
+}
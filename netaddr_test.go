@@ -0,0 +1,163 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type flowRecord struct {
+	Src      net.IP `bin:"ip=v6"`
+	Dst      net.IP `bin:"ip=v4"`
+	Gateway  net.IP `bin:"ip=unmap"`
+	Resolver net.IP `bin:"optional"`
+	Unset    net.IP `bin:"ip=v6"`
+	Peer     net.IP
+	MAC      net.HardwareAddr `bin:"mac=eui48"`
+	NoMAC    net.HardwareAddr `bin:"mac=eui48"`
+	Bytes    uint32
+}
+
+func TestNetAddr(t *testing.T) {
+	mac, err := net.ParseMAC("00:1b:44:11:3a:b7")
+	require.NoError(t, err)
+	in := flowRecord{
+		Src:     net.ParseIP("2001:db8::1"),
+		Dst:     net.ParseIP("10.0.0.1"),
+		Gateway: net.ParseIP("192.168.1.1"),
+		Peer:    net.ParseIP("10.0.0.2"),
+		MAC:     mac,
+		Bytes:   1500,
+	}
+
+	data := mustMarshalBin(t, in)
+	assert.Equal(t, 16+4+16+4+16+1+16+6+6+4, len(data))
+	assert.Equal(t, []byte{10, 0, 0, 1}, data[16:20])
+	// Untagged addresses are length-prefixed byte slices.
+	assert.Equal(t, append([]byte{16}, in.Peer...), data[56:73])
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&in), enc)
+		require.NoError(t, ConformsWithEncoding(buf.Bytes(), enc, flowRecord{}), enc)
+
+		var out flowRecord
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out), enc)
+		assert.Equal(t, in.Src, out.Src, enc)
+		assert.Equal(t, net.IP{10, 0, 0, 1}, out.Dst, enc)
+		assert.Equal(t, net.IP{192, 168, 1, 1}, out.Gateway, enc)
+		assert.Nil(t, out.Resolver, enc)
+		assert.Equal(t, net.IPv6unspecified, out.Unset, enc)
+		assert.Equal(t, in.Peer, out.Peer, enc)
+		assert.Equal(t, mac, out.MAC, enc)
+		assert.Equal(t, net.HardwareAddr{0, 0, 0, 0, 0, 0}, out.NoMAC, enc)
+		assert.Equal(t, uint32(1500), out.Bytes, enc)
+		assert.True(t, EqualWire(in, out), enc)
+	}
+}
+
+func TestNetAddr_Untagged(t *testing.T) {
+	type addr struct {
+		IP net.IP
+	}
+	type raw struct {
+		IP []byte
+	}
+	for _, ip := range []net.IP{nil, net.ParseIP("10.0.0.1").To4(), net.ParseIP("2001:db8::1")} {
+		for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+			got := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(got, enc).Encode(addr{ip}), enc)
+			expected := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(expected, enc).Encode(raw{ip}), enc)
+			assert.Equal(t, expected.Bytes(), got.Bytes(), enc)
+
+			var out addr
+			require.NoError(t, NewDecoderWithEncoding(got.Bytes(), enc).Decode(&out), enc)
+			assert.True(t, ip.Equal(out.IP), enc)
+		}
+	}
+}
+
+func TestNetAddr_UntaggedMAC(t *testing.T) {
+	type device struct {
+		MAC net.HardwareAddr
+	}
+	eui48, err := net.ParseMAC("00:1b:44:11:3a:b7")
+	require.NoError(t, err)
+	eui64, err := net.ParseMAC("02:00:5e:10:00:00:00:01")
+	require.NoError(t, err)
+	infiniband, err := net.ParseMAC("00:00:00:00:fe:80:00:00:00:00:00:00:02:00:5e:10:00:00:00:01")
+	require.NoError(t, err)
+
+	// Untagged addresses are length-prefixed byte slices, of any length.
+	assert.Equal(t, []byte{0}, mustMarshalBin(t, device{}))
+	assert.Equal(t, []byte{6, 0x00, 0x1b, 0x44, 0x11, 0x3a, 0xb7}, mustMarshalBin(t, device{eui48}))
+	assert.Equal(t, []byte{6, 0, 0, 0, 0, 0, 0}, mustMarshalBin(t, device{make(net.HardwareAddr, 6)}))
+	assert.Equal(t, append([]byte{8}, eui64...), mustMarshalBin(t, device{eui64}))
+	assert.Equal(t, append([]byte{20}, infiniband...), mustMarshalBin(t, device{infiniband}))
+
+	for _, mac := range []net.HardwareAddr{eui48, eui64, infiniband, make(net.HardwareAddr, 6)} {
+		for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(device{mac}), enc)
+			var out device
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out), enc)
+			assert.Equal(t, mac, out.MAC, enc)
+		}
+	}
+
+	type tagged struct {
+		MAC net.HardwareAddr `bin:"mac=eui64"`
+	}
+	assert.Equal(t, []byte(eui64), mustMarshalBin(t, tagged{eui64}))
+	var out tagged
+	require.NoError(t, UnmarshalBin(&out, eui64))
+	assert.Equal(t, eui64, out.MAC)
+}
+
+func TestNetAddr_Errors(t *testing.T) {
+	_, err := MarshalBin(flowRecord{Dst: net.ParseIP("::1")})
+	assert.EqualError(t, err, `error while encoding "Dst" field: invalid IP address ::1 for ip=v4`)
+
+	_, err = MarshalBin(flowRecord{MAC: net.HardwareAddr{1, 2, 3}})
+	assert.EqualError(t, err, `error while encoding "MAC" field: hardware address 01:02:03 is not 6 bytes long for mac=eui48`)
+
+	type notIP struct {
+		Addr []byte `bin:"ip=v4"`
+	}
+	assert.EqualError(t, Precompile(notIP{}), `precompile: bin.notIP: field "Addr": the ip tag only applies to net.IP, got []uint8`)
+
+	type notMAC struct {
+		Addr []byte `bin:"mac=eui48"`
+	}
+	assert.EqualError(t, Precompile(notMAC{}), `precompile: bin.notMAC: field "Addr": the mac tag only applies to net.HardwareAddr, got []uint8`)
+	assert.Equal(t, []string{"mac=eui32"}, parseFieldTag(`bin:"mac=eui32"`).Invalid)
+}
+
+func TestNetAddr_Explain(t *testing.T) {
+	out, err := ExplainType(struct {
+		Addr net.IP           `bin:"ip=v4"`
+		MAC  net.HardwareAddr `bin:"mac=eui48"`
+	}{})
+	require.NoError(t, err)
+	assert.Contains(t, out, "(Bin encoding, 10 bytes)")
+	assert.Contains(t, out, "fixed=4")
+	assert.Contains(t, out, "fixed=6")
+}
@@ -0,0 +1,168 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+// The helpers below write and read integers of any width from 1 to 8 bytes,
+// e.g. the 24-bit and 48-bit integers of media and network formats. Signed
+// values are stored in two's complement, and sign-extended when read.
+
+// WriteUintN writes v as an n-byte unsigned integer, 1 <= n <= 8,
+// in the provided byte order; it fails if v doesn't fit in n bytes.
+func (e *Encoder) WriteUintN(v uint64, n int, order binary.ByteOrder) error {
+	if n < 1 || n > TypeSize.Uint64 {
+		return fmt.Errorf("invalid integer width %d", n)
+	}
+	if n < TypeSize.Uint64 && v>>(8*uint(n)) != 0 {
+		return fmt.Errorf("value %d overflows width=%d", v, n)
+	}
+	if e.tracing() {
+		e.tlog().Debug("encode: write uintN", logUint64("val", v), logInt("width", n))
+	}
+	buf := make([]byte, TypeSize.Uint64)
+	switch order {
+	case binary.LittleEndian:
+		order.PutUint64(buf, v)
+		buf = buf[:n]
+	case binary.BigEndian:
+		order.PutUint64(buf, v)
+		buf = buf[TypeSize.Uint64-n:]
+	default:
+		return fmt.Errorf("invalid byte order: %v", order)
+	}
+	return e.toWriter(buf)
+}
+
+// WriteIntN writes v as an n-byte signed integer, 1 <= n <= 8,
+// in the provided byte order; it fails if v doesn't fit in n bytes.
+func (e *Encoder) WriteIntN(v int64, n int, order binary.ByteOrder) error {
+	if n < 1 || n > TypeSize.Uint64 {
+		return fmt.Errorf("invalid integer width %d", n)
+	}
+	if n < TypeSize.Uint64 {
+		bits := 8 * uint(n)
+		if min, max := -int64(1)<<(bits-1), int64(1)<<(bits-1)-1; v < min || v > max {
+			return fmt.Errorf("value %d overflows width=%d", v, n)
+		}
+		return e.WriteUintN(uint64(v)&(1<<bits-1), n, order)
+	}
+	return e.WriteUintN(uint64(v), n, order)
+}
+
+func (e *Encoder) WriteUint24(v uint32, order binary.ByteOrder) error {
+	return e.WriteUintN(uint64(v), 3, order)
+}
+
+func (e *Encoder) WriteInt24(v int32, order binary.ByteOrder) error {
+	return e.WriteIntN(int64(v), 3, order)
+}
+
+func (e *Encoder) WriteUint48(v uint64, order binary.ByteOrder) error {
+	return e.WriteUintN(v, 6, order)
+}
+
+func (e *Encoder) WriteInt48(v int64, order binary.ByteOrder) error {
+	return e.WriteIntN(v, 6, order)
+}
+
+// ReadUintN reads an n-byte unsigned integer, 1 <= n <= 8, in the provided byte order.
+func (dec *Decoder) ReadUintN(n int, order binary.ByteOrder) (out uint64, err error) {
+	if n < 1 || n > TypeSize.Uint64 {
+		return 0, fmt.Errorf("invalid integer width %d", n)
+	}
+	if dec.Remaining() < n {
+		return 0, fmt.Errorf("uint%d required [%d] bytes, remaining [%d]", 8*n, n, dec.Remaining())
+	}
+	if order != binary.LittleEndian && order != binary.BigEndian {
+		return 0, fmt.Errorf("invalid byte order: %v", order)
+	}
+	dec.fill(n)
+	out = uintN(dec.data[dec.pos:dec.pos+n], order)
+	dec.pos += n
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read uintN", logUint64("val", out), logInt("width", n))
+	}
+	return out, nil
+}
+
+// ReadIntN reads an n-byte signed integer, 1 <= n <= 8, in the
+// provided byte order, and sign-extends it.
+func (dec *Decoder) ReadIntN(n int, order binary.ByteOrder) (int64, error) {
+	v, err := dec.ReadUintN(n, order)
+	if err != nil {
+		return 0, err
+	}
+	shift := 64 - 8*uint(n)
+	return int64(v<<shift) >> shift, nil
+}
+
+func (dec *Decoder) ReadUint24(order binary.ByteOrder) (uint32, error) {
+	v, err := dec.ReadUintN(3, order)
+	return uint32(v), err
+}
+
+func (dec *Decoder) ReadInt24(order binary.ByteOrder) (int32, error) {
+	v, err := dec.ReadIntN(3, order)
+	return int32(v), err
+}
+
+func (dec *Decoder) ReadUint48(order binary.ByteOrder) (uint64, error) {
+	return dec.ReadUintN(6, order)
+}
+
+func (dec *Decoder) ReadInt48(order binary.ByteOrder) (int64, error) {
+	return dec.ReadIntN(6, order)
+}
+
+// uintN returns the unsigned integer of the bytes of b, at most 8, in the provided byte order.
+func uintN(b []byte, order binary.ByteOrder) uint64 {
+	buf := make([]byte, TypeSize.Uint64)
+	if order == binary.BigEndian {
+		copy(buf[TypeSize.Uint64-len(b):], b)
+	} else {
+		copy(buf, b)
+	}
+	return order.Uint64(buf)
+}
+
+// intWidth returns the encoded size in bytes of the integers of type rt:
+// that of their `width=N` tag, if any, or else their size.
+func intWidth(rt reflect.Type, opt *option) int {
+	if opt.Width > 0 && isWidthIntType(rt) {
+		return opt.Width
+	}
+	return int(rt.Size())
+}
+
+// widthIntSize returns the size of the integers of type rt, or of
+// the type rt points to, if their width can be set with a tag.
+func widthIntSize(rt reflect.Type) (int, bool) {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	return int(rt.Size()), isWidthIntType(rt)
+}
+
+// isWidthIntType reports whether the integers of type rt, including
+// time.Duration, can be encoded with another width than their size
+// with a `width=N` tag.
+func isWidthIntType(rt reflect.Type) bool {
+	return isIntegerKind(rt.Kind())
+}
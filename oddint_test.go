@@ -0,0 +1,170 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOddWidthIntegers(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewBinEncoder(buf)
+	require.NoError(t, enc.WriteUint24(0x010203, BE))
+	require.NoError(t, enc.WriteUint24(0x010203, LE))
+	require.NoError(t, enc.WriteInt24(-2, BE))
+	require.NoError(t, enc.WriteInt24(-2, LE))
+	require.NoError(t, enc.WriteUint48(0x010203040506, BE))
+	require.NoError(t, enc.WriteInt48(-0x800000000000, LE))
+	assert.Equal(t, []byte{
+		1, 2, 3,
+		3, 2, 1,
+		0xff, 0xff, 0xfe,
+		0xfe, 0xff, 0xff,
+		1, 2, 3, 4, 5, 6,
+		0, 0, 0, 0, 0, 0x80,
+	}, buf.Bytes())
+
+	dec := NewBinDecoder(buf.Bytes())
+	u24, err := dec.ReadUint24(BE)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0x010203), u24)
+	u24, err = dec.ReadUint24(LE)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0x010203), u24)
+	i24, err := dec.ReadInt24(BE)
+	require.NoError(t, err)
+	assert.Equal(t, int32(-2), i24)
+	i24, err = dec.ReadInt24(LE)
+	require.NoError(t, err)
+	assert.Equal(t, int32(-2), i24)
+	u48, err := dec.ReadUint48(BE)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0x010203040506), u48)
+	i48, err := dec.ReadInt48(LE)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-0x800000000000), i48)
+	_, err = dec.ReadUint24(LE)
+	assert.EqualError(t, err, "uint24 required [3] bytes, remaining [0]")
+
+	assert.EqualError(t, enc.WriteUint24(1<<24, LE), "value 16777216 overflows width=3")
+	assert.EqualError(t, enc.WriteInt24(1<<23, LE), "value 8388608 overflows width=3")
+	assert.EqualError(t, enc.WriteInt24(-1<<23-1, LE), "value -8388609 overflows width=3")
+	assert.EqualError(t, enc.WriteUintN(0, 9, LE), "invalid integer width 9")
+}
+
+type oddWidthRecord struct {
+	Length uint32  `bin:"width=3 big"`
+	Offset int32   `bin:"width=3"`
+	Stamp  int64   `bin:"width=6 big"`
+	Count  int     `bin:"width=3"`
+	Flags  *uint16 `bin:"optional width=1"`
+}
+
+func TestWidthTag(t *testing.T) {
+	flags := uint16(0x7f)
+	in := oddWidthRecord{Length: 0xabcdef, Offset: -3, Stamp: -1, Count: 1000, Flags: &flags}
+
+	data := mustMarshalBin(t, in)
+	assert.Equal(t, []byte{
+		0xab, 0xcd, 0xef,
+		0xfd, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xe8, 0x03, 0x00,
+		1, 0, 0, 0, 0x7f,
+	}, data)
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&in), enc)
+		var out oddWidthRecord
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out), enc)
+		assert.Equal(t, in, out, enc)
+	}
+	require.NoError(t, Precompile(oddWidthRecord{}))
+	require.NoError(t, Conforms(data, oddWidthRecord{}))
+
+	view, err := NewView(data, oddWidthRecord{})
+	require.NoError(t, err)
+	length, err := view.Uint32("Length")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0xabcdef), length)
+	offset, err := view.Int32("Offset")
+	require.NoError(t, err)
+	assert.Equal(t, int32(-3), offset)
+	stamp, err := view.Int64("Stamp")
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), stamp)
+
+	_, err = KaitaiStruct(oddWidthRecord{}, EncodingBin)
+	assert.EqualError(t, err, `kaitai: bin.oddWidthRecord: field "Length": 3-byte integers are not supported`)
+}
+
+func TestWidthTag_Errors(t *testing.T) {
+	_, err := MarshalBin(oddWidthRecord{Offset: 1 << 23})
+	assert.EqualError(t, err, `error while encoding "Offset" field: value 8388608 overflows width=3`)
+
+	type tooWide struct {
+		N uint16 `bin:"width=9"`
+	}
+	assert.EqualError(t, Precompile(tooWide{}), `precompile: bin.tooWide: field "N": width=9 is larger than 8 bytes`)
+
+	type withVarint struct {
+		N uint32 `bin:"width=3 vlq"`
+	}
+	assert.EqualError(t, Precompile(withVarint{}), `precompile: bin.withVarint: field "N": the width tag can't be combined with varint, swap or bitreverse tags`)
+}
+
+type widenedRecord struct {
+	Count  uint32 `bin:"width=8"`
+	Offset int16  `bin:"width=4 big"`
+}
+
+type widenedFrame struct {
+	Record widenedRecord
+	Tail   uint8
+}
+
+func TestWidthTag_Widening(t *testing.T) {
+	frame := widenedFrame{Record: widenedRecord{Count: 0xffffffff, Offset: -2}, Tail: 7}
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(frame), enc)
+		assert.Equal(t, 8+4+1, buf.Len(), enc)
+
+		var got widenedFrame
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&got), enc)
+		assert.Equal(t, frame, got, enc)
+		require.NoError(t, ConformsWithEncoding(buf.Bytes(), enc, widenedFrame{}), enc)
+
+		res, err := QueryWithEncoding(buf.Bytes(), enc, widenedFrame{}, "Tail")
+		require.NoError(t, err, enc)
+		assert.Equal(t, uint8(7), res.Value, enc)
+	}
+
+	data, err := MarshalBin(frame)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xfe, 7}, data)
+
+	// Values that don't fit in the Go field fail to decode, instead of being truncated.
+	var got widenedFrame
+	err = UnmarshalBin(&got, []byte{0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 7})
+	assert.EqualError(t, err, `error while decoding "Record" field: error while decoding "Count" field: value 4294967296 overflows uint32`)
+	err = UnmarshalBin(&got, []byte{0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0x7f, 0xff, 7})
+	assert.EqualError(t, err, `error while decoding "Record" field: error while decoding "Offset" field: value -32769 overflows int16`)
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import "encoding/binary"
+
+// orderScope is how far the byte order tag of a field applies.
+type orderScope int
+
+const (
+	// orderLocal applies the byte order to the field only
+	// (`big` or `little`), or inherits it if there's no tag.
+	orderLocal orderScope = iota
+	// orderRecursive applies the byte order to the field and to all
+	// the values nested in it (`big,recursive` or `little,recursive`).
+	orderRecursive
+	// orderIsolated stops the propagation of an inherited
+	// byte order to the field and to its values (`norecursive`).
+	orderIsolated
+)
+
+// fieldOrder returns the byte order of a field: the one of its tag, or the one
+// inherited from a `recursive` field it's nested in, if it has no tag.
+func fieldOrder(tag *fieldTag, inherited binary.ByteOrder) binary.ByteOrder {
+	if inherited == nil || tag.OrderExplicit || tag.OrderScope == orderIsolated {
+		return tag.Order
+	}
+	return inherited
+}
+
+// nestedOrder returns the byte order inherited by the values nested in a field.
+func nestedOrder(tag *fieldTag, inherited binary.ByteOrder) binary.ByteOrder {
+	switch tag.OrderScope {
+	case orderRecursive:
+		return tag.Order
+	case orderIsolated:
+		return nil
+	}
+	return inherited
+}
+
+// defaultOption returns the option of the values without a field tag,
+// like the elements of slices: they have the inherited byte order, if any.
+func (e *Encoder) defaultOption() *option {
+	opt := newDefaultOption()
+	if e.inheritedOrder != nil {
+		opt.Order = e.inheritedOrder
+	}
+	return opt
+}
+
+// elemOrder returns the byte order of the elements of integer slices and arrays.
+func (e *Encoder) elemOrder() binary.ByteOrder {
+	if e.inheritedOrder != nil {
+		return e.inheritedOrder
+	}
+	return LE
+}
+
+func (dec *Decoder) defaultOption() *option {
+	opt := newDefaultOption()
+	if dec.inheritedOrder != nil {
+		opt.Order = dec.inheritedOrder
+	}
+	return opt
+}
+
+func (dec *Decoder) elemOrder() binary.ByteOrder {
+	if dec.inheritedOrder != nil {
+		return dec.inheritedOrder
+	}
+	return LE
+}
+
+func (b *layoutBuilder) defaultOption() *option {
+	opt := newDefaultOption()
+	if b.inheritedOrder != nil {
+		opt.Order = b.inheritedOrder
+	}
+	return opt
+}
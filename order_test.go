@@ -0,0 +1,87 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type orderHeader struct {
+	Version uint16
+	Sizes   []uint32
+	Flags   [2]int16
+}
+
+type orderBody struct {
+	Count uint32
+	Tag   uint16 `bin:"big"`
+}
+
+type orderFrame struct {
+	Header orderHeader `bin:"big,recursive"`
+	Body   orderBody
+	Nested struct {
+		Inner orderHeader
+		Local orderBody `bin:"norecursive"`
+		Kind  uint16    `bin:"little"`
+	} `bin:"big,recursive"`
+}
+
+func TestRecursiveOrder(t *testing.T) {
+	var in orderFrame
+	in.Header = orderHeader{Version: 1, Sizes: []uint32{2}, Flags: [2]int16{3, -1}}
+	in.Body = orderBody{Count: 4, Tag: 5}
+	in.Nested.Inner = orderHeader{Version: 6, Sizes: []uint32{}}
+	in.Nested.Local = orderBody{Count: 7, Tag: 8}
+	in.Nested.Kind = 9
+
+	data := mustMarshalBin(t, &in)
+	assert.Equal(t, []byte{
+		// Header: big endian all the way down.
+		0, 1, 1, 0, 0, 0, 2, 0, 3, 0xff, 0xff,
+		// Body: little endian, except for its big endian tag.
+		4, 0, 0, 0, 0, 5,
+		// Nested.Inner: big endian.
+		0, 6, 0, 0, 0, 0, 0,
+		// Nested.Local: back to its own tags.
+		7, 0, 0, 0, 0, 8,
+		// Nested.Kind: overridden.
+		9, 0,
+	}, data)
+
+	for _, enc := range []Encoding{EncodingBin, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&in), enc)
+		var out orderFrame
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out), enc)
+		assert.Equal(t, in, out, enc)
+	}
+
+	view, err := NewView(data, orderFrame{})
+	require.NoError(t, err)
+	header, err := view.Struct("Header")
+	require.NoError(t, err)
+	version, err := header.Uint16("Version")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(1), version)
+
+	res, err := Query(data, &orderFrame{}, "Nested.Inner.Version")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(6), res.Value)
+}
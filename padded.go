@@ -0,0 +1,47 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrFrameOverflow is returned by EncodePadded for values
+// whose encoding doesn't fit in the frame.
+var ErrFrameOverflow = errors.New("encoding exceeds the frame size")
+
+// EncodePadded encodes v into a frame of exactly frameSize bytes, padded with
+// padByte, for protocols with fixed-size slots like UDP datagrams, flash pages
+// or shared memory rings. Values whose encoding is larger than the frame fail
+// with ErrFrameOverflow, and nothing is written: they are never truncated.
+func (e *Encoder) EncodePadded(v interface{}, frameSize int, padByte byte) error {
+	output, count := e.output, e.count
+	frame := new(bytes.Buffer)
+	e.output = frame
+	err := e.Encode(v)
+	e.output, e.count = output, count
+	if err != nil {
+		return err
+	}
+	if frame.Len() > frameSize {
+		return fmt.Errorf("%d bytes, frame of %d: %w", frame.Len(), frameSize, ErrFrameOverflow)
+	}
+	for frame.Len() < frameSize {
+		frame.WriteByte(padByte)
+	}
+	return e.toWriter(frame.Bytes())
+}
@@ -0,0 +1,50 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodePadded(t *testing.T) {
+	type slot struct {
+		Seq  uint32
+		Data []byte
+	}
+	buf := new(bytes.Buffer)
+	enc := NewBorshEncoder(buf)
+	require.NoError(t, enc.EncodePadded(&slot{Seq: 1, Data: []byte{0xaa}}, 12, 0xff))
+	require.NoError(t, enc.EncodePadded(&slot{Seq: 2}, 8, 0))
+	assert.Equal(t, []byte{
+		1, 0, 0, 0, 1, 0, 0, 0, 0xaa, 0xff, 0xff, 0xff,
+		2, 0, 0, 0, 0, 0, 0, 0,
+	}, buf.Bytes())
+	assert.Equal(t, 20, enc.Written())
+
+	err := enc.EncodePadded(&slot{Seq: 3, Data: []byte{1}}, 8, 0)
+	assert.True(t, errors.Is(err, ErrFrameOverflow))
+	assert.EqualError(t, err, "9 bytes, frame of 8: encoding exceeds the frame size")
+	assert.Equal(t, 20, buf.Len())
+	assert.Equal(t, 20, enc.Written())
+
+	// The frame counts against the maximum encoded size.
+	enc = NewBorshEncoder(new(bytes.Buffer)).WithMaxEncodedSize(10)
+	assert.True(t, errors.Is(enc.EncodePadded(uint8(1), 16, 0), ErrMaxEncodedSizeExceeded))
+}
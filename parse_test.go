@@ -15,11 +15,13 @@
 package bin
 
 import (
+	"bytes"
 	"encoding/binary"
 	"reflect"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_parseFieldTag(t *testing.T) {
@@ -43,6 +45,15 @@ func Test_parseFieldTag(t *testing.T) {
 				Skip:  true,
 			},
 		},
+		{
+			name: "with one-way skips",
+			tag:  `bin:"skip=encode skip=decode"`,
+			expectValue: &fieldTag{
+				Order:      binary.LittleEndian,
+				SkipEncode: true,
+				SkipDecode: true,
+			},
+		},
 		{
 			name: "with a sizeof",
 			tag:  `bin:"sizeof=Tokens"`,
@@ -68,6 +79,32 @@ func Test_parseFieldTag(t *testing.T) {
 				SizeOf: "Nodes",
 			},
 		},
+		{
+			name: "with unknown and malformed tokens",
+			tag:  `bin:"optinal  sizeof= big" borsh_skip:"yes"`,
+			expectValue: &fieldTag{
+				Order:         binary.BigEndian,
+				OrderExplicit: true,
+				Invalid:       []string{"optinal", "sizeof=", "borsh_skip:yes"},
+			},
+		},
+		{
+			name: "with recursive byte orders",
+			tag:  `bin:"little,recursive"`,
+			expectValue: &fieldTag{
+				Order:         binary.LittleEndian,
+				OrderExplicit: true,
+				OrderScope:    orderRecursive,
+			},
+		},
+		{
+			name: "with norecursive",
+			tag:  `bin:"norecursive"`,
+			expectValue: &fieldTag{
+				Order:      binary.LittleEndian,
+				OrderScope: orderIsolated,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -76,3 +113,29 @@ func Test_parseFieldTag(t *testing.T) {
 		})
 	}
 }
+
+func TestStrictTags(t *testing.T) {
+	type typo struct {
+		A uint8
+		B uint16 `bin:"bigg"`
+	}
+	type nested struct {
+		Inner typo
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).Encode(typo{A: 1, B: 2}))
+	require.Equal(t, []byte{1, 2, 0}, buf.Bytes())
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		out := new(bytes.Buffer)
+		err := NewEncoderWithEncoding(out, enc).WithStrictTags().Encode(nested{})
+		require.EqualError(t, err, `error while encoding "Inner" field: invalid tag on field bin.typo.B: ["bigg"]`)
+		// nothing has been written for the offending struct:
+		require.Equal(t, 0, out.Len())
+
+		var got typo
+		err = NewDecoderWithEncoding([]byte{1, 2, 0}, enc).WithStrictTags().Decode(&got)
+		require.EqualError(t, err, `invalid tag on field bin.typo.B: ["bigg"]`)
+	}
+}
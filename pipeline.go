@@ -0,0 +1,202 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// A TransformFunc receives the value found at a path of a Pipeline,
+// and returns the value to encode in its place, of the same type.
+type TransformFunc func(v interface{}) (interface{}, error)
+
+// A Pipeline rewrites selected values of encoded messages without decoding
+// the whole messages, e.g. for a proxy that only updates a fee or a timestamp:
+//
+//	p := bin.NewPipeline(Transfer{}).
+//		On("Fee", func(v interface{}) (interface{}, error) {
+//			return v.(uint64) * 2, nil
+//		})
+//	out, err := p.Transform(data)
+//
+// Only the values at the registered paths (see Query for their syntax) are
+// decoded and re-encoded; all the other bytes are copied verbatim, so fields
+// the pipeline doesn't know about, or that are encoded in a non-canonical
+// way, are kept as they are. A value can change size, unless its byte size
+// is held by a `bytesizeof` field, or its length by a `sizeof` field;
+// `sizeof` and `bytesizeof` fields themselves can't be changed.
+type Pipeline struct {
+	rt    reflect.Type
+	enc   Encoding
+	steps []pipelineStep
+	err   error
+}
+
+type pipelineStep struct {
+	path     string
+	segments []querySegment
+	fn       TransformFunc
+}
+
+// NewPipeline returns a pipeline for Bin-encoded messages of the type
+// of `typ` (a value or a pointer to a value).
+func NewPipeline(typ interface{}) *Pipeline {
+	return NewPipelineWithEncoding(typ, EncodingBin)
+}
+
+// NewPipelineWithEncoding is like NewPipeline, but for messages encoded
+// with the provided encoding.
+func NewPipelineWithEncoding(typ interface{}, enc Encoding) *Pipeline {
+	p := &Pipeline{enc: enc}
+	p.rt = reflect.TypeOf(typ)
+	if p.rt == nil {
+		p.err = fmt.Errorf("pipeline: nil type")
+		return p
+	}
+	for p.rt.Kind() == reflect.Ptr {
+		p.rt = p.rt.Elem()
+	}
+	if !isValidEncoding(enc) {
+		p.err = fmt.Errorf("pipeline: invalid encoding %d", enc)
+	}
+	return p
+}
+
+// On registers the function that transforms the value at the provided path.
+func (p *Pipeline) On(path string, fn TransformFunc) *Pipeline {
+	if p.err != nil {
+		return p
+	}
+	segments, err := parseQueryPath(path)
+	if err != nil {
+		p.err = fmt.Errorf("pipeline: %w", err)
+		return p
+	}
+	if len(segments) == 0 {
+		p.err = fmt.Errorf("pipeline: empty path")
+		return p
+	}
+	p.steps = append(p.steps, pipelineStep{path: path, segments: segments, fn: fn})
+	return p
+}
+
+// Err returns the first error in the description of the pipeline, if any.
+func (p *Pipeline) Err() error {
+	return p.err
+}
+
+// Transform returns a copy of the message with the registered transformations applied.
+func (p *Pipeline) Transform(data []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.Grow(len(data))
+	if err := p.TransformTo(buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type pipelineEdit struct {
+	step *pipelineStep
+	res  *QueryResult
+}
+
+// TransformTo writes a copy of the message with the registered transformations applied to w.
+func (p *Pipeline) TransformTo(w io.Writer, data []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	edits := make([]pipelineEdit, 0, len(p.steps))
+	for i := range p.steps {
+		step := &p.steps[i]
+		res, err := NewDecoderWithEncoding(data, p.enc).query(p.rt, nil, step.segments)
+		if err != nil {
+			return fmt.Errorf("pipeline: %q: %w", step.path, err)
+		}
+		edits = append(edits, pipelineEdit{step: step, res: res})
+	}
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].res.Start < edits[j].res.Start
+	})
+
+	pos := 0
+	for i, edit := range edits {
+		if i > 0 && edit.res.Start < edits[i-1].res.End {
+			return fmt.Errorf("pipeline: %q and %q overlap", edits[i-1].step.path, edit.step.path)
+		}
+		replacement, err := p.apply(data, edit)
+		if err != nil {
+			return fmt.Errorf("pipeline: %q: %w", edit.step.path, err)
+		}
+		if _, err := w.Write(data[pos:edit.res.Start]); err != nil {
+			return err
+		}
+		if _, err := w.Write(replacement); err != nil {
+			return err
+		}
+		pos = edit.res.End
+	}
+	_, err := w.Write(data[pos:])
+	return err
+}
+
+// apply returns the encoded replacement of a value.
+func (p *Pipeline) apply(data []byte, edit pipelineEdit) ([]byte, error) {
+	res := edit.res
+	old := res.Value
+	v, err := edit.step.fn(old)
+	if err != nil {
+		return nil, err
+	}
+	if reflect.TypeOf(v) != reflect.TypeOf(old) {
+		return nil, fmt.Errorf("transformed %T into %T", old, v)
+	}
+	if reflect.DeepEqual(v, old) {
+		// Keep the original bytes.
+		return res.Bytes(data), nil
+	}
+	if res.isSize {
+		return nil, fmt.Errorf("cannot change a sizeof or bytesizeof field")
+	}
+	rv := reflect.ValueOf(v)
+	if res.opt.hasSizeOfSlice() && rv.Len() != res.opt.getSizeOfSlice() {
+		return nil, fmt.Errorf("cannot change the length %d, held by a sizeof field, to %d", res.opt.getSizeOfSlice(), rv.Len())
+	}
+	buf := new(bytes.Buffer)
+	if err := NewEncoderWithEncoding(buf, p.enc).encodeWithOption(rv, res.opt); err != nil {
+		return nil, err
+	}
+	if res.byteSized && buf.Len() != res.End-res.Start {
+		return nil, fmt.Errorf("cannot change the size %d, held by a bytesizeof field, to %d", res.End-res.Start, buf.Len())
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeWithOption encodes rv using the encoder's encoding.
+func (e *Encoder) encodeWithOption(rv reflect.Value, opt *option) error {
+	switch e.encoding {
+	case EncodingBin:
+		return e.encodeBin(rv, opt)
+	case EncodingBorsh:
+		return e.encodeBorsh(rv, opt)
+	case EncodingCompactU16:
+		return e.encodeCompactU16(rv, opt)
+	default:
+		panic(fmt.Errorf("encoding not implemented: %s", e.encoding))
+	}
+}
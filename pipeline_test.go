@@ -0,0 +1,104 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type pipelineTransfer struct {
+	Fee       uint64
+	Memo      string
+	Count     uint8 `bin:"sizeof=Signers"`
+	Signers   []uint16
+	Timestamp int64
+}
+
+func TestPipeline_Transform(t *testing.T) {
+	in := pipelineTransfer{
+		Fee:       10,
+		Memo:      "hi",
+		Count:     2,
+		Signers:   []uint16{1, 2},
+		Timestamp: 100,
+	}
+	data, err := MarshalBin(&in)
+	require.NoError(t, err)
+
+	p := NewPipeline(pipelineTransfer{}).
+		On("Timestamp", func(v interface{}) (interface{}, error) {
+			return v.(int64) + 1, nil
+		}).
+		On("Memo", func(v interface{}) (interface{}, error) {
+			return v.(string) + " there", nil
+		}).
+		On("Signers[1]", func(v interface{}) (interface{}, error) {
+			return uint16(7), nil
+		}).
+		On("Fee", func(v interface{}) (interface{}, error) {
+			return v, nil
+		})
+	require.NoError(t, p.Err())
+	out, err := p.Transform(data)
+	require.NoError(t, err)
+
+	var got pipelineTransfer
+	require.NoError(t, UnmarshalBin(&got, out))
+	assert.Equal(t, pipelineTransfer{
+		Fee:       10,
+		Memo:      "hi there",
+		Count:     2,
+		Signers:   []uint16{1, 7},
+		Timestamp: 101,
+	}, got)
+}
+
+func TestPipeline_Errors(t *testing.T) {
+	in := pipelineTransfer{Count: 1, Signers: []uint16{1}}
+	data, err := MarshalBin(&in)
+	require.NoError(t, err)
+
+	_, err = NewPipeline(pipelineTransfer{}).
+		On("Fee", func(v interface{}) (interface{}, error) {
+			return 1, nil
+		}).
+		Transform(data)
+	assert.EqualError(t, err, `pipeline: "Fee": transformed uint64 into int`)
+
+	_, err = NewPipeline(pipelineTransfer{}).
+		On("Count", func(v interface{}) (interface{}, error) {
+			return uint8(2), nil
+		}).
+		Transform(data)
+	assert.EqualError(t, err, `pipeline: "Count": cannot change a sizeof or bytesizeof field`)
+
+	_, err = NewPipeline(pipelineTransfer{}).
+		On("Signers", func(v interface{}) (interface{}, error) {
+			return []uint16{1, 2}, nil
+		}).
+		Transform(data)
+	assert.EqualError(t, err, `pipeline: "Signers": cannot change the length 1, held by a sizeof field, to 2`)
+
+	_, err = NewPipeline(pipelineTransfer{}).
+		On("Signers", func(v interface{}) (interface{}, error) { return v, nil }).
+		On("Signers[0]", func(v interface{}) (interface{}, error) { return v, nil }).
+		Transform(data)
+	assert.EqualError(t, err, `pipeline: "Signers" and "Signers[0]" overlap`)
+
+	assert.EqualError(t, NewPipeline(pipelineTransfer{}).On("Fee[", nil).Err(), `pipeline: invalid path "Fee[": unclosed index`)
+}
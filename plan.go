@@ -0,0 +1,501 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// A structPlan holds the fields of a struct type along with their parsed tags,
+// so that they are computed once per type instead of once per encoded or decoded value.
+type structPlan struct {
+	fields []reflect.StructField
+	tags   []*fieldTag
+	// opts are the options of the fields, built from their tags;
+	// they are shared by all the walks of the type (see fieldOption).
+	opts []*option
+	// complexEnum is set when the struct is a borsh enum, i.e. when its
+	// first field is a BorshEnum tagged as enum.
+	complexEnum bool
+	// tagErr is the error returned in strict tags mode, if the fields have invalid tags.
+	tagErr error
+}
+
+// plans maps struct types to their *structPlan.
+var plans sync.Map
+
+func planOf(rt reflect.Type) *structPlan {
+	if plan, ok := plans.Load(rt); ok {
+		return plan.(*structPlan)
+	}
+	plan := &structPlan{
+		tagErr: checkStructTags(rt),
+	}
+	plan.addFields(rt, nil)
+	plan.complexEnum = len(plan.fields) > 0 && isTypeBorshEnum(plan.fields[0].Type) && plan.tags[0].IsBorshEnum
+	actual, _ := plans.LoadOrStore(rt, plan)
+	return actual.(*structPlan)
+}
+
+// addFields appends the fields of the struct type rt, found at the provided
+// index of the planned type, with the fields of its `inline` fields in their place.
+func (p *structPlan) addFields(rt reflect.Type, index []int) {
+	for i := 0; i < rt.NumField(); i++ {
+		structField := rt.Field(i)
+		structField.Index = append(append([]int(nil), index...), i)
+		tag := parseFieldTag(structField.Tag)
+		if tag.Inline && !tag.Skip && isInlinable(structField.Type) {
+			if p.tagErr == nil {
+				p.tagErr = checkStructTags(structField.Type)
+			}
+			p.addFields(structField.Type, structField.Index)
+			continue
+		}
+		p.fields = append(p.fields, structField)
+		p.tags = append(p.tags, tag)
+		p.opts = append(p.opts, optionFromTag(tag))
+	}
+}
+
+// isInlinable reports whether the fields of a struct field of type rt
+// can be flattened into its parent: rt must be a struct, not a pointer,
+// that has no marshaler of its own.
+func isInlinable(rt reflect.Type) bool {
+	return rt.Kind() == reflect.Struct && rt != bigIntType && rt != timeType &&
+		!hasCustomUnmarshaler(rt) && !rt.Implements(marshalableType) && !reflect.PtrTo(rt).Implements(marshalableType) && !hasStdBinaryMarshaler(rt)
+}
+
+// field returns the field at index i of the plan in the struct rv.
+func (p *structPlan) field(rv reflect.Value, i int) reflect.Value {
+	return rv.FieldByIndex(p.fields[i].Index)
+}
+
+// fieldOption returns the option of the field at index i for the provided
+// encoding, with the byte order it inherits, if any (Borsh doesn't inherit
+// byte orders, and only Borsh has `coption` fields). The option is shared by
+// all the walks of the type, unless the walk changes it: optional fields, whose
+// optionality is used up by the encoders, get a copy; walkers must clone it
+// before setting anything that depends on the value, e.g. its sizeof length.
+func (p *structPlan) fieldOption(i int, enc Encoding, inherited binary.ByteOrder) *option {
+	opt := p.opts[i]
+	tag := p.tags[i]
+	if enc.IsBorsh() {
+		inherited = nil
+	}
+	order := fieldOrder(tag, inherited)
+	if !tag.Option && !tag.COption && order == opt.Order {
+		return opt
+	}
+	opt = opt.clone()
+	opt.Order = order
+	if !enc.IsBorsh() {
+		opt.is_COptionalField = false
+	}
+	return opt
+}
+
+// fieldIndex returns the index in the plan of the field with
+// the provided name, or -1 if there's none.
+func (p *structPlan) fieldIndex(name string) int {
+	for i, structField := range p.fields {
+		if structField.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// planOf returns the plan of a struct type; the plan pinned by a
+// TypedDecoder is returned without looking it up.
+func (dec *Decoder) planOf(rt reflect.Type) *structPlan {
+	if dec.pinnedPlan != nil && rt == dec.pinnedType {
+		return dec.pinnedPlan
+	}
+	return planOf(rt)
+}
+
+// InvalidatePlanCache drops the cached plans of the provided types, given as
+// reflect.Type values or as values (or pointers to values) of those types, so that
+// they are computed again when next used, e.g. by hot code reloading environments
+// and test frameworks that redefine types. The cached layouts and size bounds
+// of all types, which can embed the provided ones, are dropped as well.
+func InvalidatePlanCache(types ...interface{}) {
+	for _, v := range types {
+		rt, ok := v.(reflect.Type)
+		if !ok {
+			rt = reflect.TypeOf(v)
+		}
+		if rt == nil {
+			continue
+		}
+		for rt.Kind() == reflect.Ptr {
+			rt = rt.Elem()
+		}
+		plans.Delete(rt)
+	}
+	clearSyncMap(&layouts)
+	clearSyncMap(&minSizeCache)
+}
+
+// ResetCaches drops all the plans, layouts and size bounds cached by the package.
+// Registrations, like the ones of RegisterEnumValues, are kept.
+func ResetCaches() {
+	clearSyncMap(&plans)
+	clearSyncMap(&layouts)
+	clearSyncMap(&minSizeCache)
+}
+
+func clearSyncMap(m *sync.Map) {
+	m.Range(func(key, _ interface{}) bool {
+		m.Delete(key)
+		return true
+	})
+}
+
+// Precompile prepares the encoding and decoding of the types of the provided
+// values (or pointers to values), and of all the types they contain, so that
+// it isn't done while handling the first value of each type.
+//
+// It's meant to be called at startup, and returns the errors that would otherwise
+// only show when encoding or decoding values: invalid tags (regardless of
+// the strict tags mode), misplaced binary extension fields, sizeof tags
+// that don't refer to a field, and types that can't be encoded.
+//
+//	func init() {
+//		if err := bin.Precompile(Transfer{}, Account{}); err != nil {
+//			panic(err)
+//		}
+//	}
+func Precompile(values ...interface{}) error {
+	seen := map[reflect.Type]bool{}
+	for _, v := range values {
+		rt := reflect.TypeOf(v)
+		if rt == nil {
+			return fmt.Errorf("precompile: nil type")
+		}
+		for rt.Kind() == reflect.Ptr {
+			rt = rt.Elem()
+		}
+		if err := precompileType(rt, seen); err != nil {
+			return fmt.Errorf("precompile: %s: %w", rt, err)
+		}
+		for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+			if _, err := describeType(rt, enc); err != nil {
+				return fmt.Errorf("precompile: %s: %w", rt, err)
+			}
+			minEncodedSize(rt, enc)
+		}
+	}
+	return nil
+}
+
+// precompileType builds and checks the plans of the struct types reachable from rt.
+func precompileType(rt reflect.Type, seen map[reflect.Type]bool) error {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if seen[rt] || hasCustomUnmarshaler(rt) || rt.Implements(marshalableType) || reflect.PtrTo(rt).Implements(marshalableType) {
+		return nil
+	}
+	seen[rt] = true
+
+	switch rt.Kind() {
+	case reflect.Array, reflect.Slice:
+		return precompileType(rt.Elem(), seen)
+	case reflect.Map:
+		if err := precompileType(rt.Key(), seen); err != nil {
+			return err
+		}
+		return precompileType(rt.Elem(), seen)
+	case reflect.Struct:
+	default:
+		return nil
+	}
+
+	plan := planOf(rt)
+	if plan.tagErr != nil {
+		return plan.tagErr
+	}
+	if err := checkInline(rt); err != nil {
+		return err
+	}
+	names := map[string]bool{}
+	// sized are the fields whose length is held by a sizeof field.
+	sized := map[string]bool{}
+	for i, structField := range plan.fields {
+		if names[structField.Name] && structField.PkgPath == "" {
+			return fmt.Errorf("field %q: duplicate field name once inline fields are flattened", structField.Name)
+		}
+		names[structField.Name] = true
+		if isSizeOfPath(plan.tags[i]) {
+			sized[structField.Name] = true
+		} else if plan.tags[i].SizeOf != "" {
+			sized[plan.tags[i].SizeOf] = true
+		}
+	}
+	seenBinaryExtensionField := false
+	for i, structField := range plan.fields {
+		fieldTag := plan.tags[i]
+		if fieldTag.Skip || structField.PkgPath != "" {
+			continue
+		}
+		if fieldTag.BinaryExtension {
+			seenBinaryExtensionField = true
+		} else if seenBinaryExtensionField {
+			return fmt.Errorf("the `bin:\"binary_extension\"` tags must be packed together at the end of struct fields, problematic field %q", structField.Name)
+		}
+		if (fieldTag.SkipEncode || fieldTag.SkipDecode) && (fieldTag.SizeOf != "" || fieldTag.ByteSizeOf != "") {
+			return fmt.Errorf("field %q: sizeof and bytesizeof fields can't be skipped in one direction only", structField.Name)
+		}
+		if isSizeOfPath(fieldTag) {
+			if structField.Type.Kind() != reflect.Slice {
+				return fmt.Errorf("field %q: a sizeof path only applies to slices, got %s", structField.Name, structField.Type)
+			}
+			if _, err := sizeOfPathType(rt, i, fieldTag.SizeOf); err != nil {
+				return fmt.Errorf("field %q: %w", structField.Name, err)
+			}
+			root := plan.fieldIndex(strings.SplitN(fieldTag.SizeOf, ".", 2)[0])
+			if root := plan.tags[root]; root.Skip || root.SkipDecode || root.SkipEncode {
+				return fmt.Errorf("field %q: sizeof path %q starts at a skipped field", structField.Name, fieldTag.SizeOf)
+			}
+		} else if fieldTag.SizeOf != "" {
+			target := plan.fieldIndex(fieldTag.SizeOf)
+			if target < 0 {
+				return fmt.Errorf("field %q: sizeof refers to unknown field %q", structField.Name, fieldTag.SizeOf)
+			}
+			if target := plan.fields[target]; !isSizeOfTarget(target.Type) {
+				return fmt.Errorf("field %q: sizeof target %q must be a slice, string, map or byte array, got %s", structField.Name, fieldTag.SizeOf, target.Type)
+			}
+		}
+		if fieldTag.SizeFunc != "" && fieldTag.SizeOf == "" {
+			return fmt.Errorf("field %q: sizefunc without sizeof", structField.Name)
+		}
+		if fieldTag.Empty != EmptyDefault && !fieldTag.Option && !fieldTag.COption {
+			return fmt.Errorf("field %q: the empty tag only applies to optional fields", structField.Name)
+		}
+		if fieldTag.Default != nil {
+			if !fieldTag.Option && !fieldTag.COption {
+				return fmt.Errorf("field %q: the default tag only applies to optional fields", structField.Name)
+			}
+			if err := checkDefault(structField.Type, *fieldTag.Default); err != nil {
+				return fmt.Errorf("field %q: %w", structField.Name, err)
+			}
+		}
+		if fieldTag.Pointers != PointerDefault && !fieldTag.Option && !fieldTag.COption {
+			return fmt.Errorf("field %q: the pointers tag only applies to optional fields", structField.Name)
+		}
+		if fieldTag.BoolWidth > 0 && !isBoolOrPtr(structField.Type) {
+			return fmt.Errorf("field %q: the boolwidth tag only applies to bool, got %s", structField.Name, structField.Type)
+		}
+		if fieldTag.TimeFormat != TimeUnixNano && !isTimeOrPtr(structField.Type) {
+			return fmt.Errorf("field %q: the time tag only applies to time.Time, got %s", structField.Name, structField.Type)
+		}
+		if fieldTag.IPFormat != IPBytes && !isIPOrPtr(structField.Type) {
+			return fmt.Errorf("field %q: the ip tag only applies to net.IP, got %s", structField.Name, structField.Type)
+		}
+		if fieldTag.MACFormat != MACBytes && !isHardwareAddrOrPtr(structField.Type) {
+			return fmt.Errorf("field %q: the mac tag only applies to net.HardwareAddr, got %s", structField.Name, structField.Type)
+		}
+		if fieldTag.DurationUnit != 0 && !isDurationOrPtr(structField.Type) {
+			return fmt.Errorf("field %q: duration unit tags only apply to time.Duration, got %s", structField.Name, structField.Type)
+		}
+		if isPlatformIntOrPtr(structField.Type) {
+			if fieldTag.Width < 1 || fieldTag.Width > TypeSize.Uint64 {
+				return fmt.Errorf("field %q: %w", structField.Name, errNoWidth(structField.Type))
+			}
+		} else if isDecimalOrPtr(structField.Type) {
+			if fieldTag.Width != 0 && fieldTag.Width != TypeSize.Uint32 && fieldTag.Width != TypeSize.Uint64 {
+				return fmt.Errorf("field %q: the width of a Decimal must be 4 or 8, got %d", structField.Name, fieldTag.Width)
+			}
+		} else if _, ok := widthIntSize(structField.Type); ok && fieldTag.Width > 0 {
+			// A wider width is checked for overflows when decoding.
+			if fieldTag.Width > TypeSize.Uint64 {
+				return fmt.Errorf("field %q: width=%d is larger than 8 bytes", structField.Name, fieldTag.Width)
+			}
+		} else if fieldTag.Width > 0 && !isBigIntOrPtr(structField.Type) {
+			return fmt.Errorf("field %q: the width tag only applies to big.Int, Decimal and integers, got %s", structField.Name, structField.Type)
+		}
+		if fieldTag.Width > 0 && (fieldTag.VLQ || fieldTag.GroupVarint || fieldTag.SQLiteVarint || fieldTag.Swap || fieldTag.BitReverse) {
+			return fmt.Errorf("field %q: the width tag can't be combined with varint, swap or bitreverse tags", structField.Name)
+		}
+		if fieldTag.Decimal && !isDecimalOrPtr(structField.Type) {
+			return fmt.Errorf("field %q: the decimal tag only applies to Decimal, got %s", structField.Name, structField.Type)
+		}
+		if fieldTag.MaxLen > 0 && !isStringOrPtr(structField.Type) {
+			return fmt.Errorf("field %q: the maxlen tag only applies to strings, got %s", structField.Name, structField.Type)
+		}
+		if fieldTag.StrLen > 0 && !isStringOrPtr(structField.Type) {
+			return fmt.Errorf("field %q: the strlen tag only applies to strings, got %s", structField.Name, structField.Type)
+		}
+		if fieldTag.StrLen > 0 && fieldTag.MaxLen > 0 {
+			return fmt.Errorf("field %q: the strlen and maxlen tags can't be combined", structField.Name)
+		}
+		if fieldTag.CString && !isStringOrPtr(structField.Type) {
+			return fmt.Errorf("field %q: the cstring tag only applies to strings, got %s", structField.Name, structField.Type)
+		}
+		if fieldTag.CString && (fieldTag.StrLen > 0 || fieldTag.SizeOf != "") {
+			return fmt.Errorf("field %q: the cstring tag can't be combined with strlen or sizeof", structField.Name)
+		}
+		if fieldTag.PrefixOrder != nil && !hasLengthPrefix(structField.Type) {
+			return fmt.Errorf("field %q: the prefix tag only applies to strings, slices and maps, got %s", structField.Name, structField.Type)
+		}
+		if fieldTag.PrefixOrder != nil && (fieldTag.StrLen > 0 || fieldTag.CString) {
+			return fmt.Errorf("field %q: the prefix tag can't be combined with strlen or cstring", structField.Name)
+		}
+		if fieldTag.LenPrefix != prefixNone {
+			if !hasLengthPrefix(structField.Type) {
+				return fmt.Errorf("field %q: the lenprefix tag only applies to strings, slices and maps, got %s", structField.Name, structField.Type)
+			}
+			if sized[structField.Name] || fieldTag.StrLen > 0 || fieldTag.CString {
+				return fmt.Errorf("field %q: the lenprefix tag can't be combined with sizeof, strlen or cstring", structField.Name)
+			}
+			if fieldTag.Delta || fieldTag.RLE || fieldTag.VLQ || fieldTag.GroupVarint || fieldTag.SQLiteVarint || fieldTag.Swap || fieldTag.BitReverse || fieldTag.RuneFormat != RuneFormatUTF32 || fieldTag.Compress || fieldTag.Encrypt {
+				return fmt.Errorf("field %q: the lenprefix tag can't be combined with varint, delta, rle, swap, bitreverse, rune, compress or encrypt tags", structField.Name)
+			}
+		}
+		if fieldTag.Union != "" {
+			if err := checkUnion(plan, i, sized, seen); err != nil {
+				return fmt.Errorf("field %q: %w", structField.Name, err)
+			}
+		}
+		if fieldTag.ByteSizeOf != "" {
+			if !names[fieldTag.ByteSizeOf] {
+				return fmt.Errorf("field %q: bytesizeof refers to unknown field %q", structField.Name, fieldTag.ByteSizeOf)
+			}
+			if !isIntegerKind(structField.Type.Kind()) {
+				return fmt.Errorf("field %q: bytesizeof field must be an integer, got %s", structField.Name, structField.Type)
+			}
+		}
+		if err := precompileType(structField.Type, seen); err != nil {
+			return fmt.Errorf("field %q: %w", structField.Name, err)
+		}
+	}
+	return nil
+}
+
+// checkUnion checks the `union` tag of the field at index i of a struct,
+// and precompiles the variants of its union.
+func checkUnion(plan *structPlan, i int, sized map[string]bool, seen map[reflect.Type]bool) error {
+	rt := plan.fields[i].Type
+	if rt.Kind() != reflect.Interface && (rt.Kind() != reflect.Slice || rt.Elem().Kind() != reflect.Uint8) {
+		return fmt.Errorf("the union tag only applies to interfaces and byte slices, got %s", rt)
+	}
+	rest := *plan.tags[i]
+	rest.Union = ""
+	if !reflect.DeepEqual(&rest, parseFieldTag("")) || sized[plan.fields[i].Name] {
+		return fmt.Errorf("the union tag can't be combined with other tags, nor be the target of a sizeof field")
+	}
+	name := plan.tags[i].Union
+	discriminator := plan.fieldIndex(name)
+	if discriminator < 0 {
+		return fmt.Errorf("union refers to unknown field %q", name)
+	}
+	if discriminator > i {
+		return fmt.Errorf("union discriminator %q must come before the union", name)
+	}
+	if tag := plan.tags[discriminator]; tag.Skip || tag.SkipEncode || tag.SkipDecode || plan.fields[discriminator].PkgPath != "" {
+		return fmt.Errorf("union discriminator %q is not encoded", name)
+	}
+	if t := plan.fields[discriminator].Type; !isIntegerKind(t.Kind()) {
+		return fmt.Errorf("union discriminator %q must be an integer, got %s", name, t)
+	}
+	variants, err := unionVariants(rt)
+	if err != nil {
+		return err
+	}
+	for value, variant := range variants {
+		if err := precompileType(variant, seen); err != nil {
+			return fmt.Errorf("variant %d: %w", value, err)
+		}
+	}
+	return nil
+}
+
+// checkInline checks the `inline` tags of the fields of the struct
+// type rt, and of the fields of the structs they flatten.
+func checkInline(rt reflect.Type) error {
+	for i := 0; i < rt.NumField(); i++ {
+		structField := rt.Field(i)
+		fieldTag := parseFieldTag(structField.Tag)
+		if !fieldTag.Inline || fieldTag.Skip {
+			continue
+		}
+		if !isInlinable(structField.Type) {
+			return fmt.Errorf("field %q: the inline tag only applies to structs without a marshaler, got %s", structField.Name, structField.Type)
+		}
+		rest := *fieldTag
+		rest.Inline = false
+		if !reflect.DeepEqual(&rest, parseFieldTag("")) {
+			return fmt.Errorf("field %q: the inline tag can't be combined with other tags", structField.Name)
+		}
+		if err := checkInline(structField.Type); err != nil {
+			return fmt.Errorf("field %q: %w", structField.Name, err)
+		}
+	}
+	return nil
+}
+
+func isStringOrPtr(rt reflect.Type) bool {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	return rt.Kind() == reflect.String
+}
+
+func isTimeOrPtr(rt reflect.Type) bool {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	return rt == timeType
+}
+
+func isDurationOrPtr(rt reflect.Type) bool {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	return rt == durationType
+}
+
+func isBigIntOrPtr(rt reflect.Type) bool {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	return rt == bigIntType
+}
+
+func isPlatformIntOrPtr(rt reflect.Type) bool {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	return isPlatformIntKind(rt.Kind())
+}
+
+func isIPOrPtr(rt reflect.Type) bool {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	return rt == ipType
+}
+
+func isHardwareAddrOrPtr(rt reflect.Type) bool {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	return rt == hardwareAddrType
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrecompile(t *testing.T) {
+	type item struct {
+		Count uint8 `bin:"sizeof=Data"`
+		Data  []byte
+	}
+	type order struct {
+		ID    uint64
+		Items []item
+		Index map[string]*item
+		Note  string `bin:"binary_extension"`
+	}
+	require.NoError(t, Precompile(order{}, &item{}))
+	_, ok := plans.Load(reflect.TypeOf(item{}))
+	assert.True(t, ok)
+
+	type badTag struct {
+		A uint32 `bin:"lttle"`
+	}
+	type nested struct {
+		Items []badTag
+	}
+	type extension struct {
+		A uint32 `bin:"binary_extension"`
+		B uint32
+	}
+	type sizeOf struct {
+		Count uint8 `bin:"sizeof=Date"`
+		Data  []byte
+	}
+	type unsupported struct {
+		C chan int
+	}
+	tests := []struct {
+		value interface{}
+		error string
+	}{
+		{nested{}, `precompile: bin.nested: field "Items": invalid tag on field bin.badTag.A: ["lttle"]`},
+		{extension{}, "precompile: bin.extension: the `bin:\"binary_extension\"` tags must be packed together at the end of struct fields, problematic field \"B\""},
+		{sizeOf{}, `precompile: bin.sizeOf: field "Count": sizeof refers to unknown field "Date"`},
+		{unsupported{}, `precompile: bin.unsupported: field "C": unsupported type "chan int"`},
+		{nil, "precompile: nil type"},
+	}
+	for _, test := range tests {
+		assert.EqualError(t, Precompile(test.value), test.error)
+	}
+}
+
+func TestInvalidatePlanCache(t *testing.T) {
+	type point struct {
+		X, Y int32
+	}
+	type shape struct {
+		Points []point
+	}
+	require.NoError(t, Precompile(shape{}))
+	pointType := reflect.TypeOf(point{})
+	_, ok := plans.Load(pointType)
+	require.True(t, ok)
+	_, ok = minSizeCache.Load(minSizeKey{reflect.TypeOf(shape{}), EncodingBin})
+	require.True(t, ok)
+
+	InvalidatePlanCache(pointType)
+	_, ok = plans.Load(pointType)
+	assert.False(t, ok)
+	_, ok = plans.Load(reflect.TypeOf(shape{}))
+	assert.True(t, ok)
+	// The size bound of shape depends on point.
+	_, ok = minSizeCache.Load(minSizeKey{reflect.TypeOf(shape{}), EncodingBin})
+	assert.False(t, ok)
+
+	InvalidatePlanCache(&shape{}, nil)
+	_, ok = plans.Load(reflect.TypeOf(shape{}))
+	assert.False(t, ok)
+
+	require.NoError(t, Precompile(shape{}))
+	ResetCaches()
+	_, ok = plans.Load(pointType)
+	assert.False(t, ok)
+	_, ok = minSizeCache.Load(minSizeKey{reflect.TypeOf(shape{}), EncodingBin})
+	assert.False(t, ok)
+
+	// Values are still encoded after a reset.
+	data, err := MarshalBin(shape{Points: []point{{1, 2}}})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 1, 0, 0, 0, 2, 0, 0, 0}, data)
+}
+
+func TestPlanFieldOption(t *testing.T) {
+	type fields struct {
+		A uint32 `bin:"big"`
+		B uint32
+		C *uint32 `bin:"optional"`
+		D *uint32 `bin:"coption"`
+	}
+	plan := planOf(reflect.TypeOf(fields{}))
+
+	// Plain fields share the option of the plan.
+	opt := plan.fieldOption(0, EncodingBin, LE)
+	assert.Same(t, plan.opts[0], opt)
+	assert.Equal(t, BE, opt.Order)
+	assert.Same(t, plan.opts[1], plan.fieldOption(1, EncodingBin, nil))
+
+	// Inherited byte orders, and optional fields, get their own.
+	opt = plan.fieldOption(1, EncodingBin, BE)
+	assert.NotSame(t, plan.opts[1], opt)
+	assert.Equal(t, BE, opt.Order)
+	assert.Equal(t, LE, plan.opts[1].Order)
+	assert.Equal(t, LE, plan.fieldOption(1, EncodingBorsh, BE).Order)
+
+	opt = plan.fieldOption(2, EncodingBin, nil)
+	assert.NotSame(t, plan.opts[2], opt)
+	assert.True(t, opt.is_Optional())
+	assert.False(t, plan.fieldOption(3, EncodingBin, nil).is_COptional())
+	assert.True(t, plan.fieldOption(3, EncodingBorsh, nil).is_COptional())
+
+	// Encoding values doesn't change the options of the plan.
+	v := uint32(7)
+	_, err := MarshalBin(fields{C: &v})
+	require.NoError(t, err)
+	assert.True(t, plan.opts[2].is_Optional())
+}
+
+func BenchmarkDecodeStructFields(b *testing.B) {
+	type fields struct {
+		A uint32
+		B uint64
+		C int16
+		D bool
+	}
+	data, err := MarshalBin(fields{1, 2, 3, true})
+	require.NoError(b, err)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var out fields
+		if err := NewBinDecoder(data).Decode(&out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"reflect"
+)
+
+// PointerMode controls how optional (`optional`, or `coption` with Borsh)
+// pointers to pointers, e.g. **T, are encoded and decoded. The encoder
+// and the decoder of a value must use the same mode.
+type PointerMode int
+
+const (
+	// PointerDefault uses the mode of the encoder or decoder,
+	// which is PointerFlatten unless set with WithPointerMode.
+	PointerDefault PointerMode = iota
+	// PointerFlatten encodes the chain of pointers as a single optional value,
+	// absent if any of the pointers is nil (tag: `pointers=flatten`). Decoding
+	// an absent value sets the outermost pointer to nil, and decoding a present
+	// one allocates all the pointers of the chain.
+	PointerFlatten
+	// PointerPerLevel gives each pointer of the chain its own presence flag,
+	// so that a nil pointer at any level round-trips (tag: `pointers=perlevel`).
+	// Layouts, and what's derived from them, only know the mode of the tags:
+	// they describe the fields without a `pointers` tag in the flattened form.
+	PointerPerLevel
+)
+
+// WithPointerMode sets how the encoder writes optional pointers
+// to pointers, for the fields that have no `pointers` tag.
+func (e *Encoder) WithPointerMode(mode PointerMode) *Encoder {
+	e.pointerMode = mode
+	return e
+}
+
+// WithPointerMode sets how the decoder reads optional pointers
+// to pointers, for the fields that have no `pointers` tag.
+func (dec *Decoder) WithPointerMode(mode PointerMode) *Decoder {
+	dec.pointerMode = mode
+	return dec
+}
+
+// isPointerChain reports whether rv is a pointer to a pointer.
+func isPointerChain(rv reflect.Value) bool {
+	return rv.Kind() == reflect.Ptr && isPointerChainType(rv.Type())
+}
+
+func isPointerChainType(rt reflect.Type) bool {
+	return rt.Kind() == reflect.Ptr && rt.Elem().Kind() == reflect.Ptr
+}
+
+// presenceLevels returns the number of presence flags of an optional value
+// of type rt: one per pointer of the chain with PointerPerLevel, and 1 otherwise.
+func presenceLevels(rt reflect.Type, mode PointerMode) int {
+	if mode != PointerPerLevel {
+		return 1
+	}
+	levels := 0
+	for ; rt.Kind() == reflect.Ptr; rt = rt.Elem() {
+		levels++
+	}
+	if levels == 0 {
+		return 1
+	}
+	return levels
+}
+
+// hasNilPointer reports whether one of the pointers of the chain rv is nil.
+func hasNilPointer(rv reflect.Value) bool {
+	for ; rv.Kind() == reflect.Ptr; rv = rv.Elem() {
+		if rv.IsNil() {
+			return true
+		}
+	}
+	return false
+}
+
+func pointerMode(opt *option, mode PointerMode) PointerMode {
+	if opt.Pointers != PointerDefault {
+		return opt.Pointers
+	}
+	return mode
+}
+
+// nextPointerLevel reports whether the optional value rv, whose presence
+// has just been written, is a pointer to a pointer whose own presence
+// must be written next.
+func (e *Encoder) nextPointerLevel(rv reflect.Value, opt *option) bool {
+	return isPointerChain(rv) && pointerMode(opt, e.pointerMode) == PointerPerLevel
+}
+
+// indirectOptional is indirect, except that an optional pointer to a pointer
+// is kept as is, so that the whole chain is set to nil when it's absent.
+func indirectOptional(rv reflect.Value, optional bool) (BinaryUnmarshaler, reflect.Value) {
+	if optional && isPointerChain(rv) && rv.CanSet() {
+		return nil, rv
+	}
+	return indirect(rv, optional)
+}
+
+// decodePointerLevel decodes the next level of the optional pointer to a pointer
+// rv, whose presence has just been read, with its own presence flag.
+func (dec *Decoder) decodePointerLevel(rv reflect.Value, opt *option, decode func(reflect.Value, *option) error) (handled bool, err error) {
+	if !isPointerChain(rv) || pointerMode(opt, dec.pointerMode) != PointerPerLevel {
+		return false, nil
+	}
+	if rv.IsNil() {
+		rv.Set(reflect.New(rv.Type().Elem()))
+	}
+	return true, decode(rv.Elem(), opt)
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type pointerChainStruct struct {
+	A **uint16 `bin:"optional"`
+	B uint8
+}
+
+type pointerChainPerLevel struct {
+	A **uint16 `bin:"optional pointers=perlevel"`
+	B uint8
+}
+
+func encodePointers(t *testing.T, enc Encoding, v interface{}) []byte {
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(v))
+	return buf.Bytes()
+}
+
+func TestPointerChain_Flatten(t *testing.T) {
+	v := uint16(7)
+	pv := &v
+	var nilInner *uint16
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			data := encodePointers(t, enc, &pointerChainStruct{A: &pv, B: 1})
+			var got pointerChainStruct
+			require.NoError(t, NewDecoderWithEncoding(data, enc).Decode(&got))
+			require.NotNil(t, got.A)
+			require.NotNil(t, *got.A)
+			assert.Equal(t, uint16(7), **got.A)
+			assert.Equal(t, uint8(1), got.B)
+
+			// A nil pointer at any level makes the whole chain absent.
+			absent := encodePointers(t, enc, &pointerChainStruct{B: 1})
+			data = encodePointers(t, enc, &pointerChainStruct{A: &nilInner, B: 1})
+			assert.Equal(t, absent, data)
+
+			// Decoding an absent chain clears it, whatever the destination held.
+			got = pointerChainStruct{A: &pv}
+			require.NoError(t, NewDecoderWithEncoding(data, enc).Decode(&got))
+			assert.Nil(t, got.A)
+			assert.Equal(t, uint16(7), v)
+		})
+	}
+}
+
+func TestPointerChain_PerLevel(t *testing.T) {
+	v := uint16(7)
+	pv := &v
+	var nilInner *uint16
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			for _, in := range []pointerChainPerLevel{{B: 1}, {A: &nilInner, B: 1}, {A: &pv, B: 1}} {
+				data := encodePointers(t, enc, &in)
+				var got pointerChainPerLevel
+				require.NoError(t, NewDecoderWithEncoding(data, enc).Decode(&got))
+				assert.Equal(t, in, got)
+			}
+		})
+	}
+
+	data, err := MarshalBorsh(&pointerChainPerLevel{A: &nilInner, B: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 0, 1}, data)
+	data, err = MarshalBorsh(&pointerChainPerLevel{A: &pv, B: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 1, 7, 0, 1}, data)
+}
+
+func TestPointerChain_Mode(t *testing.T) {
+	v := uint16(7)
+	pv := &v
+	var nilInner *uint16
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBorshEncoder(buf).WithPointerMode(PointerPerLevel).Encode(&pointerChainStruct{A: &nilInner, B: 1}))
+	assert.Equal(t, []byte{1, 0, 1}, buf.Bytes())
+
+	var got pointerChainStruct
+	require.NoError(t, NewBorshDecoder(buf.Bytes()).WithPointerMode(PointerPerLevel).Decode(&got))
+	require.NotNil(t, got.A)
+	assert.Nil(t, *got.A)
+
+	buf.Reset()
+	require.NoError(t, NewBorshEncoder(buf).WithPointerMode(PointerPerLevel).Encode(&pointerChainStruct{A: &pv, B: 1}))
+	assert.Equal(t, []byte{1, 1, 7, 0, 1}, buf.Bytes())
+}
+
+func TestPointerChain_TagRequiresOptional(t *testing.T) {
+	type notOptional struct {
+		A **uint16 `bin:"pointers=perlevel"`
+	}
+	err := Precompile(notOptional{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the pointers tag only applies to optional fields")
+}
+
+func TestPointerChain_PerLevelLayout(t *testing.T) {
+	v := uint16(7)
+	pv := &v
+	var nilInner *uint16
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			for _, in := range []pointerChainPerLevel{{B: 1}, {A: &nilInner, B: 1}, {A: &pv, B: 1}} {
+				data := encodePointers(t, enc, &in)
+				require.NoError(t, ConformsWithEncoding(data, enc, pointerChainPerLevel{}))
+				require.Error(t, ConformsWithEncoding(data[:len(data)-1], enc, pointerChainPerLevel{}))
+
+				res, err := QueryWithEncoding(data, enc, pointerChainPerLevel{}, "B")
+				require.NoError(t, err)
+				assert.Equal(t, uint8(1), res.Value)
+				res, err = QueryWithEncoding(data, enc, pointerChainPerLevel{}, "A")
+				require.NoError(t, err)
+				assert.Equal(t, in.A, res.Value)
+
+				view, err := NewViewWithEncoding(data, enc, pointerChainPerLevel{})
+				require.NoError(t, err)
+				assert.Equal(t, len(data), view.Size())
+				b, err := view.Uint8("B")
+				require.NoError(t, err)
+				assert.Equal(t, uint8(1), b)
+				assert.Equal(t, in.A != nil && *in.A != nil, view.Has("A"))
+				if view.Has("A") {
+					a, err := view.Uint16("A")
+					require.NoError(t, err)
+					assert.Equal(t, uint16(7), a)
+				}
+			}
+		})
+	}
+
+	explained, err := ExplainTypeWithEncoding(pointerChainPerLevel{}, EncodingBorsh)
+	require.NoError(t, err)
+	assert.Contains(t, explained, "optional(u8),perlevel=2")
+	ksy, err := KaitaiStruct(pointerChainPerLevel{}, EncodingBorsh)
+	require.NoError(t, err)
+	assert.Contains(t, ksy, "  - id: a\n    type: u2\n    if: 'a_present != 0 and a_present2 != 0'\n")
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"reflect"
+)
+
+// WithPrefixOrder sets the byte order of the fixed-width length prefixes the
+// encoder writes, independently of the byte order of the values: the u32
+// lengths of Borsh, and the u64 lengths of Rust strings. It applies to the
+// fields that have no `prefix=big` or `prefix=little` tag; the default is
+// little endian. Varint and compact-u16 prefixes have no byte order.
+func (e *Encoder) WithPrefixOrder(order binary.ByteOrder) *Encoder {
+	e.prefixOrder = order
+	return e
+}
+
+// WithPrefixOrder sets the byte order of the fixed-width length prefixes
+// the decoder reads, for the fields that have no `prefix` tag.
+func (dec *Decoder) WithPrefixOrder(order binary.ByteOrder) *Decoder {
+	dec.prefixOrder = order
+	return dec
+}
+
+// lengthOrder returns the byte order of the length prefix of the current field.
+func (e *Encoder) lengthOrder() binary.ByteOrder {
+	return prefixOrder(e.currentFieldOpt, e.prefixOrder)
+}
+
+func (dec *Decoder) lengthOrder() binary.ByteOrder {
+	return prefixOrder(dec.currentFieldOpt, dec.prefixOrder)
+}
+
+func prefixOrder(opt *option, order binary.ByteOrder) binary.ByteOrder {
+	if opt != nil && opt.PrefixOrder != nil {
+		return opt.PrefixOrder
+	}
+	if order != nil {
+		return order
+	}
+	return LE
+}
+
+// hasLengthPrefix reports whether values of type rt may have a length prefix.
+func hasLengthPrefix(rt reflect.Type) bool {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	switch rt.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type prefixOrderRecord struct {
+	Name   string   `bin:"prefix=big"`
+	Values []uint16 `bin:"prefix=big"`
+	Tags   []string
+}
+
+func TestPrefixOrder_Tag(t *testing.T) {
+	in := prefixOrderRecord{Name: "ab", Values: []uint16{1, 2}, Tags: []string{"c"}}
+
+	data, err := MarshalBorsh(&in)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0, 0, 0, 2, 'a', 'b',
+		0, 0, 0, 2, 1, 0, 2, 0,
+		1, 0, 0, 0, 1, 0, 0, 0, 'c',
+	}, data)
+
+	var out prefixOrderRecord
+	require.NoError(t, UnmarshalBorsh(&out, data))
+	assert.Equal(t, in, out)
+
+	require.NoError(t, ConformsWithEncoding(data, EncodingBorsh, prefixOrderRecord{}))
+	explained, err := ExplainTypeWithEncoding(prefixOrderRecord{}, EncodingBorsh)
+	require.NoError(t, err)
+	assert.Contains(t, explained, "u32,BE")
+	ksy, err := KaitaiStruct(prefixOrderRecord{}, EncodingBorsh)
+	require.NoError(t, err)
+	assert.Contains(t, ksy, "type: u4be")
+
+	bin, err := MarshalBin(&in)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 2, 'a', 'b'}, bin[:10])
+}
+
+func TestPrefixOrder_Encoder(t *testing.T) {
+	type message struct {
+		Kind uint16
+		Body string
+	}
+	in := message{Kind: 1, Body: "hi"}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBorshEncoder(buf).WithPrefixOrder(BE).Encode(&in))
+	assert.Equal(t, []byte{1, 0, 0, 0, 0, 2, 'h', 'i'}, buf.Bytes())
+
+	var out message
+	require.NoError(t, NewBorshDecoder(buf.Bytes()).WithPrefixOrder(BE).Decode(&out))
+	assert.Equal(t, in, out)
+	assert.Error(t, NewBorshDecoder(buf.Bytes()).Decode(&out))
+
+	// The tag of a field overrides the prefix order of the encoder.
+	type tagged struct {
+		Body string `bin:"prefix=little"`
+	}
+	buf.Reset()
+	require.NoError(t, NewBorshEncoder(buf).WithPrefixOrder(BE).Encode(&tagged{Body: "hi"}))
+	assert.Equal(t, []byte{2, 0, 0, 0, 'h', 'i'}, buf.Bytes())
+}
+
+func TestPrefixOrder_Errors(t *testing.T) {
+	type notPrefixed struct {
+		N uint32 `bin:"prefix=big"`
+	}
+	assert.EqualError(t, Precompile(notPrefixed{}), "precompile: bin.notPrefixed: field \"N\": the prefix tag only applies to strings, slices and maps, got uint32")
+
+	type withCString struct {
+		S string `bin:"cstring prefix=big"`
+	}
+	assert.EqualError(t, Precompile(withCString{}), "precompile: bin.withCString: field \"S\": the prefix tag can't be combined with strlen or cstring")
+	assert.Equal(t, []string{"prefix=middle"}, parseFieldTag(`bin:"prefix=middle"`).Invalid)
+}
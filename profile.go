@@ -0,0 +1,40 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"context"
+)
+
+// ProfileLabelType is the key of the pprof label set by Decoder.WithProfileLabels.
+const ProfileLabelType = "bin_type"
+
+// WithProfileLabels makes each Decode call run with a pprof label holding
+// the name of the decoded type (ProfileLabelType), so that CPU profiles
+// attribute the decoding time to message types instead of reflect frames:
+//
+//	go tool pprof -tagfocus=bin_type=Transfer cpu.pprof
+//
+// ctx holds the labels of the caller, if any, which are kept. Nested Decode
+// calls (made by custom decoders) are attributed to the top-level type.
+// Setting labels has a small cost per call, so it's opt-in.
+// Under TinyGo, which has no pprof labels, it's a no-op.
+func (dec *Decoder) WithProfileLabels(ctx context.Context) *Decoder {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	dec.profileLabels = ctx
+	return dec
+}
@@ -0,0 +1,40 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoder_WithProfileLabels(t *testing.T) {
+	msg := []byte{0x01, 0x00, 0x00, 0x00}
+
+	var v quotaNested
+	dec := NewBinDecoder(msg).WithProfileLabels(context.Background())
+	require.NoError(t, dec.Decode(&v))
+	assert.Equal(t, uint32(1), v.A)
+	assert.False(t, dec.inDecode)
+
+	quota := NewDecodeQuota("test", 4)
+	require.NoError(t, NewBinDecoder(msg).WithProfileLabels(nil).WithQuota(quota).Decode(&v))
+	assert.Equal(t, int64(4), quota.Used())
+	err := NewBinDecoder(msg).WithProfileLabels(nil).WithQuota(quota).Decode(&v)
+	assert.True(t, errors.Is(err, ErrDecodeQuotaExceeded))
+}
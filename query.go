@@ -0,0 +1,487 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// QueryResult is the outcome of a Query: the decoded value found
+// at the queried path, and the byte range it occupies in the data.
+type QueryResult struct {
+	Value interface{}
+	// Start is the offset of the first byte of the value.
+	Start int
+	// End is the offset right after the last byte of the value.
+	End int
+
+	// opt is the option the value was decoded with.
+	opt *option
+	// byteSized is set when the byte size of the value,
+	// or of a value that contains it, is held by a field.
+	byteSized bool
+	// isSize is set when the value is a sizeof or bytesizeof field.
+	isSize bool
+}
+
+// Bytes returns the encoded bytes of the queried value.
+func (r *QueryResult) Bytes(data []byte) []byte {
+	return data[r.Start:r.End]
+}
+
+// Query decodes only the value found at the provided path inside
+// Bin-encoded data of the type of `typ` (a value or a pointer to a value).
+//
+// The path is a dot-separated list of field names, with optional
+// indexes for slices and arrays, e.g. "Transactions[2].Signatures[0]".
+// Everything before the queried value is skipped, and everything after it
+// is not read at all.
+func Query(data []byte, typ interface{}, path string) (*QueryResult, error) {
+	return QueryWithEncoding(data, EncodingBin, typ, path)
+}
+
+// QueryWithEncoding is like Query, but for data encoded with the provided encoding.
+func QueryWithEncoding(data []byte, enc Encoding, typ interface{}, path string) (*QueryResult, error) {
+	segments, err := parseQueryPath(path)
+	if err != nil {
+		return nil, err
+	}
+	rt := reflect.TypeOf(typ)
+	if rt == nil {
+		return nil, fmt.Errorf("query: nil type")
+	}
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	dec := NewDecoderWithEncoding(data, enc)
+	res, err := dec.query(rt, nil, segments)
+	if err != nil {
+		return nil, fmt.Errorf("query %q: %w", path, err)
+	}
+	return res, nil
+}
+
+type querySegment struct {
+	field string
+	index int
+}
+
+func (s querySegment) isIndex() bool {
+	return s.field == ""
+}
+
+func (s querySegment) String() string {
+	if s.isIndex() {
+		return "[" + strconv.Itoa(s.index) + "]"
+	}
+	return s.field
+}
+
+func parseQueryPath(path string) ([]querySegment, error) {
+	var out []querySegment
+	rest := path
+	for rest != "" {
+		switch {
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unclosed index", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid path %q: bad index %q", path, rest[1:end])
+			}
+			out = append(out, querySegment{index: index})
+			rest = rest[end+1:]
+		case rest[0] == '.':
+			if len(out) == 0 || len(rest) == 1 {
+				return nil, fmt.Errorf("invalid path %q: misplaced dot", path)
+			}
+			rest = rest[1:]
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if len(out) > 0 && path[len(path)-len(rest)-1] != '.' {
+				return nil, fmt.Errorf("invalid path %q: missing dot before %q", path, rest[:end])
+			}
+			out = append(out, querySegment{field: rest[:end]})
+			rest = rest[end:]
+		}
+	}
+	return out, nil
+}
+
+// decodeWithOption decodes into rv using the decoder's encoding.
+func (dec *Decoder) decodeWithOption(rv reflect.Value, opt *option) error {
+	switch dec.encoding {
+	case EncodingBin:
+		return dec.decodeBin(rv, opt)
+	case EncodingBorsh:
+		return dec.decodeBorsh(rv, opt)
+	case EncodingCompactU16:
+		return dec.decodeCompactU16(rv, opt)
+	default:
+		panic(fmt.Errorf("encoding not implemented: %s", dec.encoding))
+	}
+}
+
+// readPresence reads the presence flag of an optional value, as written
+// by the decoder's encoding.
+func (dec *Decoder) readPresence(opt *option) (bool, error) {
+	switch {
+	case opt.is_COptional():
+		return dec.ReadCOption()
+	case dec.IsBin():
+		flag, err := dec.ReadUint32(LE)
+		return flag != 0, err
+	default:
+		return dec.ReadOption()
+	}
+}
+
+func hasCustomUnmarshaler(rt reflect.Type) bool {
+	return rt.Implements(unmarshalableType) || reflect.PtrTo(rt).Implements(unmarshalableType)
+}
+
+func (dec *Decoder) query(rt reflect.Type, opt *option, path []querySegment) (*QueryResult, error) {
+	if opt == nil {
+		opt = newDefaultOption()
+	}
+	if len(path) == 0 {
+		start := int(dec.Position())
+		value := reflect.New(rt)
+		if err := dec.decodeWithOption(value, opt); err != nil {
+			return nil, err
+		}
+		return &QueryResult{
+			Value: value.Elem().Interface(),
+			Start: start,
+			End:   int(dec.Position()),
+			opt:   opt,
+		}, nil
+	}
+
+	if opt.is_Optional() || opt.is_COptional() {
+		isPresent, err := dec.readPresence(opt)
+		if err != nil {
+			return nil, err
+		}
+		if !isPresent {
+			return nil, fmt.Errorf("optional %s is not present", rt)
+		}
+		if isPointerChainType(rt) && pointerMode(opt, dec.pointerMode) == PointerPerLevel {
+			// The next pointer of the chain has its own presence flag.
+			return dec.query(rt.Elem(), opt, path)
+		}
+		opt = opt.clone().set_Optional(false).set_COptional(false)
+	}
+	if hasCustomUnmarshaler(rt) {
+		return nil, fmt.Errorf("cannot query %s inside %s: type has a custom decoder", path[0], rt)
+	}
+
+	segment := path[0]
+	switch rt.Kind() {
+	case reflect.Ptr:
+		return dec.query(rt.Elem(), opt, path)
+	case reflect.Struct:
+		if segment.isIndex() {
+			return nil, fmt.Errorf("cannot index struct %s with %s", rt, segment)
+		}
+		return dec.queryStruct(rt, segment.field, path[1:])
+	case reflect.Slice, reflect.Array:
+		if !segment.isIndex() {
+			return nil, fmt.Errorf("cannot get field %q of %s", segment.field, rt)
+		}
+		var l int
+		if opt != nil && opt.hasSizeOfSlice() {
+			l = opt.getSizeOfSlice()
+		} else if rt.Kind() == reflect.Array {
+			l = rt.Len()
+		} else if opt != nil && opt.LenPrefix != prefixNone {
+			dec.currentFieldOpt = opt
+			length, err := dec.readLenPrefix(opt.LenPrefix)
+			if err != nil {
+				return nil, err
+			}
+			l = length
+		} else {
+			dec.currentFieldOpt = opt
+			length, err := dec.ReadLength()
+			if err != nil {
+				return nil, err
+			}
+			l = length
+		}
+		if segment.index >= l {
+			return nil, fmt.Errorf("index %d out of range for %s of length %d", segment.index, rt, l)
+		}
+		for i := 0; i < segment.index; i++ {
+			if err := dec.skipValue(rt.Elem(), nil); err != nil {
+				return nil, fmt.Errorf("skipping element %d: %w", i, err)
+			}
+		}
+		res, err := dec.query(rt.Elem(), nil, path[1:])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", segment, err)
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("cannot query %s inside %s", segment, rt)
+	}
+}
+
+func (dec *Decoder) queryStruct(rt reflect.Type, name string, rest []querySegment) (*QueryResult, error) {
+	inherited := dec.inheritedOrder
+	defer func() { dec.inheritedOrder = inherited }()
+
+	plan := dec.planOf(rt)
+	if dec.strictTags && plan.tagErr != nil {
+		return nil, plan.tagErr
+	}
+	if dec.IsBorsh() && plan.complexEnum {
+		return nil, fmt.Errorf("cannot query %q inside complex enum %s", name, rt)
+	}
+
+	sizeOfMap := map[string]int{}
+	byteSizes := map[string]int{}
+	// The fields holding the start of a `sizeof` path, or the discriminator
+	// of a union, are decoded into a scratch struct, to be looked up in it.
+	pathRoots := map[string]bool{}
+	for _, fieldTag := range plan.tags {
+		if isSizeOfPath(fieldTag) {
+			pathRoots[strings.SplitN(fieldTag.SizeOf, ".", 2)[0]] = true
+		}
+		if fieldTag.Union != "" {
+			pathRoots[fieldTag.Union] = true
+		}
+	}
+	var scratch reflect.Value
+	for i, structField := range plan.fields {
+		fieldTag := plan.tags[i]
+		if fieldTag.Skip || fieldTag.SkipDecode || (structField.PkgPath != "" && fieldTag.Reserved == 0) {
+			if structField.Name == name {
+				return nil, fmt.Errorf("field %q of %s is not encoded", name, rt)
+			}
+			continue
+		}
+		if fieldTag.BinaryExtension && !dec.HasRemaining() {
+			break
+		}
+		if fieldTag.Reserved > 0 {
+			if structField.Name == name {
+				return nil, fmt.Errorf("field %q of %s is reserved", name, rt)
+			}
+			if err := dec.readReserved(fieldTag.Reserved); err != nil {
+				return nil, fmt.Errorf("skipping %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+
+		option := plan.fieldOption(i, dec.encoding, inherited)
+		if isSizeOfPath(fieldTag) {
+			size, err := sizeOfPath(fieldTag, scratch)
+			if err != nil {
+				return nil, fmt.Errorf("skipping %q field: %w", structField.Name, err)
+			}
+			sizeOfMap[structField.Name] = size
+		}
+		if s, ok := sizeOfMap[structField.Name]; ok {
+			option = option.clone().setSizeOfSlice(s)
+		}
+		if fieldTag.Union != "" {
+			if !scratch.IsValid() {
+				scratch = reflect.New(rt).Elem()
+			}
+			variant, err := unionVariant(plan, scratch, i)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", structField.Name, err)
+			}
+			option = option.clone()
+			option.UnionVariant = variant
+		}
+		dec.inheritedOrder = nestedOrder(fieldTag, inherited)
+
+		if structField.Name == name {
+			typ := structField.Type
+			if option.UnionVariant != nil && len(rest) > 0 {
+				// The path continues inside the variant.
+				typ, option = option.UnionVariant, nil
+			}
+			res, err := dec.query(typ, option, rest)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			if _, ok := byteSizes[name]; ok {
+				res.byteSized = true
+			}
+			if len(rest) == 0 && (fieldTag.SizeOf != "" || fieldTag.ByteSizeOf != "") {
+				res.isSize = true
+			}
+			return res, nil
+		}
+
+		if pathRoots[structField.Name] {
+			if !scratch.IsValid() {
+				scratch = reflect.New(rt).Elem()
+			}
+			if err := dec.decodeField(plan.field(scratch, i).Addr(), option); err != nil {
+				return nil, fmt.Errorf("skipping %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+		if fieldTag.SizeOf != "" && !isSizeOfPath(fieldTag) {
+			v := reflect.New(structField.Type)
+			if err := dec.decodeField(v, option); err != nil {
+				return nil, fmt.Errorf("skipping %q field: %w", structField.Name, err)
+			}
+			size, err := sizeOfField(fieldTag, structField.Type, v.Elem())
+			if err != nil {
+				return nil, fmt.Errorf("skipping %q field: %w", structField.Name, err)
+			}
+			sizeOfMap[fieldTag.SizeOf] = size
+			continue
+		}
+		if fieldTag.ByteSizeOf != "" {
+			v := reflect.New(structField.Type)
+			if err := dec.decodeField(v, option); err != nil {
+				return nil, fmt.Errorf("skipping %q field: %w", structField.Name, err)
+			}
+			byteSizes[fieldTag.ByteSizeOf] = sizeof(structField.Type, v.Elem())
+			continue
+		}
+		if size, ok := byteSizes[structField.Name]; ok {
+			// The size of the field is known: skip it without decoding it.
+			if err := dec.SkipBytes(uint(size)); err != nil {
+				return nil, fmt.Errorf("skipping %q field: %w", structField.Name, err)
+			}
+			continue
+		}
+		if err := dec.skipValue(structField.Type, option); err != nil {
+			return nil, fmt.Errorf("skipping %q field: %w", structField.Name, err)
+		}
+	}
+	return nil, fmt.Errorf("no field %q in %s", name, rt)
+}
+
+// decodeField decodes a struct field the way the struct decoders do.
+func (dec *Decoder) decodeField(v reflect.Value, opt *option) error {
+	if dec.IsBorsh() && hasCustomUnmarshaler(v.Elem().Type()) {
+		// decodeStructBorsh calls custom decoders directly on fields.
+		return dec.decodeBorsh(v, nil)
+	}
+	return dec.decodeWithOption(v, opt)
+}
+
+// skipValue moves the decoder past a value of the provided type,
+// without decoding it when its encoded size is known in advance.
+func (dec *Decoder) skipValue(rt reflect.Type, opt *option) error {
+	if opt == nil || (!opt.hasSizeOfSlice() && !opt.is_Optional() && !opt.is_COptional() && opt.RuneFormat == RuneFormatUTF32 && !opt.VLQ && !opt.SQLiteVarint && opt.TimeFormat != TimeRFC3339 && opt.IPFormat == IPBytes && opt.MACFormat == MACBytes && opt.Width == 0 && opt.BoolWidth == 0) {
+		if size, ok := fixedSize(rt, dec.encoding); ok {
+			return dec.SkipBytes(uint(size))
+		}
+	}
+	if opt == nil {
+		opt = newDefaultOption()
+	}
+	if opt.Encrypt && !opt.is_Optional() && !opt.is_COptional() && isCompressibleType(rt) {
+		// Encrypted values are skipped without their key.
+		_, err := dec.ReadByteSlice()
+		return err
+	}
+	return dec.decodeField(reflect.New(rt), opt)
+}
+
+// fixedSize returns the encoded size of the values of the provided type,
+// when it's the same for all values.
+func fixedSize(rt reflect.Type, enc Encoding) (int, bool) {
+	if hasCustomUnmarshaler(rt) {
+		return 0, false
+	}
+	if rt == timeType {
+		return TypeSize.Uint64, true
+	}
+	if rt == bigIntType {
+		return 0, false
+	}
+	switch rt.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Uint8:
+		return 1, true
+	case reflect.Int16, reflect.Uint16:
+		return 2, true
+	case reflect.Int32, reflect.Uint32, reflect.Float32:
+		return 4, true
+	case reflect.Int64, reflect.Uint64, reflect.Float64, reflect.Complex64:
+		return 8, true
+	case reflect.Complex128:
+		return 16, true
+	case reflect.Array:
+		size, ok := fixedSize(rt.Elem(), enc)
+		return size * rt.Len(), ok
+	case reflect.Struct:
+		total := 0
+		plan := planOf(rt)
+		for i, structField := range plan.fields {
+			fieldTag := plan.tags[i]
+			if fieldTag.Skip || fieldTag.SkipDecode {
+				continue
+			}
+			if fieldTag.Reserved > 0 && !fieldTag.BinaryExtension {
+				total += fieldTag.Reserved
+				continue
+			}
+			if structField.PkgPath != "" {
+				continue
+			}
+			if fieldTag.Option || fieldTag.COption || fieldTag.BinaryExtension ||
+				fieldTag.IsBorshEnum || fieldTag.SizeOf != "" || fieldTag.RuneFormat != RuneFormatUTF32 || fieldTag.VLQ || fieldTag.SQLiteVarint ||
+				fieldTag.TimeFormat == TimeRFC3339 {
+				return 0, false
+			}
+			if fieldTag.Width > 0 && isWidthIntType(structField.Type) {
+				total += fieldTag.Width
+				continue
+			}
+			if fieldTag.StrLen > 0 && structField.Type.Kind() == reflect.String {
+				total += fieldTag.StrLen
+				continue
+			}
+			if fieldTag.BoolWidth > 0 && structField.Type.Kind() == reflect.Bool {
+				total += fieldTag.BoolWidth
+				continue
+			}
+			if structField.Type == ipType && fieldTag.IPFormat != IPBytes {
+				total += fieldTag.IPFormat.size()
+				continue
+			}
+			if structField.Type == hardwareAddrType && fieldTag.MACFormat != MACBytes {
+				total += fieldTag.MACFormat.size()
+				continue
+			}
+			size, ok := fixedSize(structField.Type, enc)
+			if !ok {
+				return 0, false
+			}
+			total += size
+		}
+		return total, true
+	default:
+		return 0, false
+	}
+}
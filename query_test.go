@@ -0,0 +1,96 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type queryTestTx struct {
+	Fee        uint64
+	Memo       string
+	Signatures [][4]byte
+}
+
+type queryTestBlock struct {
+	Slot         uint64
+	Count        uint8        `bin:"sizeof=Transactions"`
+	Parent       *queryTestTx `bin:"optional"`
+	Transactions []queryTestTx
+	Tail         uint16
+}
+
+func TestQuery(t *testing.T) {
+	block := queryTestBlock{
+		Slot:  7,
+		Count: 3,
+		Transactions: []queryTestTx{
+			{Fee: 1, Memo: "a", Signatures: [][4]byte{{1, 1, 1, 1}}},
+			{Fee: 2, Memo: "bb", Signatures: [][4]byte{{2, 2, 2, 2}, {3, 3, 3, 3}}},
+			{Fee: 3, Memo: "ccc", Signatures: [][4]byte{{4, 4, 4, 4}, {5, 5, 5, 5}}},
+		},
+		Tail: 9,
+	}
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(block))
+		data := buf.Bytes()
+
+		res, err := QueryWithEncoding(data, enc, block, "Transactions[2].Signatures[1]")
+		require.NoError(t, err, enc.String())
+		assert.Equal(t, [4]byte{5, 5, 5, 5}, res.Value)
+		assert.Equal(t, []byte{5, 5, 5, 5}, res.Bytes(data))
+
+		res, err = QueryWithEncoding(data, enc, &block, "Transactions[1].Memo")
+		require.NoError(t, err)
+		assert.Equal(t, "bb", res.Value)
+
+		res, err = QueryWithEncoding(data, enc, block, "Tail")
+		require.NoError(t, err)
+		assert.Equal(t, uint16(9), res.Value)
+		assert.Equal(t, len(data), res.End)
+
+		res, err = QueryWithEncoding(data, enc, block, "Transactions[1]")
+		require.NoError(t, err)
+		assert.Equal(t, block.Transactions[1], res.Value)
+
+		_, err = QueryWithEncoding(data, enc, block, "Transactions[3]")
+		require.EqualError(t, err, `query "Transactions[3]": Transactions: index 3 out of range for []bin.queryTestTx of length 3`)
+
+		_, err = QueryWithEncoding(data, enc, block, "Parent.Fee")
+		require.EqualError(t, err, `query "Parent.Fee": Parent: optional *bin.queryTestTx is not present`)
+
+		_, err = QueryWithEncoding(data, enc, block, "Missing")
+		require.Error(t, err)
+	}
+}
+
+func TestQuery_Path(t *testing.T) {
+	segments, err := parseQueryPath("A[2].B[0][1].C")
+	require.NoError(t, err)
+	assert.Equal(t, []querySegment{
+		{field: "A"}, {index: 2}, {field: "B"}, {index: 0}, {index: 1}, {field: "C"},
+	}, segments)
+
+	for _, path := range []string{"A[", "A[x]", ".A", "A.", "A[0]B", "A[-1]"} {
+		_, err := parseQueryPath(path)
+		assert.Error(t, err, path)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrDecodeQuotaExceeded is wrapped by the errors of decoders
+// whose DecodeQuota is exhausted.
+var ErrDecodeQuotaExceeded = errors.New("decode quota exceeded")
+
+// A DecodeQuota is a budget of bytes shared by the decoders it's attached to
+// (see Decoder.WithQuota), e.g. all the decoders of the messages of a connection,
+// so that many small messages can't add up to more than the budget.
+// It's safe for concurrent use.
+type DecodeQuota struct {
+	name  string
+	limit int64
+	used  int64
+}
+
+// NewDecodeQuota returns a quota of limit bytes; name identifies
+// the stream it's for (e.g. the remote address of a connection)
+// in the errors of the decoders that exceed it.
+func NewDecodeQuota(name string, limit int64) *DecodeQuota {
+	return &DecodeQuota{name: name, limit: limit}
+}
+
+// Name returns the name of the quota.
+func (q *DecodeQuota) Name() string {
+	return q.name
+}
+
+// Used returns the number of bytes charged to the quota.
+func (q *DecodeQuota) Used() int64 {
+	return atomic.LoadInt64(&q.used)
+}
+
+// Release gives n bytes back to the quota, e.g. once the values
+// decoded from them are not used anymore.
+func (q *DecodeQuota) Release(n int64) {
+	atomic.AddInt64(&q.used, -n)
+}
+
+// charge adds n bytes to the quota, and fails if it's exceeded.
+func (q *DecodeQuota) charge(n int64) error {
+	if used := atomic.AddInt64(&q.used, n); used > q.limit {
+		return q.errorf(used)
+	}
+	return nil
+}
+
+// checkAvailable fails if no byte is left in the quota.
+func (q *DecodeQuota) checkAvailable() error {
+	if used := q.Used(); used >= q.limit {
+		return q.errorf(used)
+	}
+	return nil
+}
+
+func (q *DecodeQuota) errorf(used int64) error {
+	return fmt.Errorf("%w: stream %q: used %d bytes of %d", ErrDecodeQuotaExceeded, q.name, used, q.limit)
+}
+
+// WithQuota charges the bytes read by each Decode call to the provided quota.
+// Decode fails with an error wrapping ErrDecodeQuotaExceeded without reading
+// anything once the quota is exhausted, and when the value it decoded makes
+// it exceed the quota; the bytes read are charged in both cases.
+// Nested Decode calls (made by custom decoders) are charged as part of
+// the top-level one.
+func (dec *Decoder) WithQuota(q *DecodeQuota) *Decoder {
+	dec.quota = q
+	return dec
+}
+
+func (dec *Decoder) decodeWithQuota(v interface{}) error {
+	if dec.quota == nil {
+		return dec.Decode(v)
+	}
+	if err := dec.quota.checkAvailable(); err != nil {
+		return err
+	}
+	start := dec.Position()
+	err := dec.Decode(v)
+	if quotaErr := dec.quota.charge(int64(dec.Position() - start)); quotaErr != nil && err == nil {
+		err = quotaErr
+	}
+	return err
+}
@@ -0,0 +1,56 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type quotaNested struct {
+	A uint32
+}
+
+func (q *quotaNested) UnmarshalWithDecoder(decoder *Decoder) error {
+	return decoder.Decode(&q.A)
+}
+
+func TestDecoder_WithQuota(t *testing.T) {
+	quota := NewDecodeQuota("10.0.0.1:4242", 10)
+	msg := []byte{0x01, 0x00, 0x00, 0x00}
+
+	var v quotaNested
+	require.NoError(t, NewBinDecoder(msg).WithQuota(quota).Decode(&v))
+	require.NoError(t, NewBorshDecoder(msg).WithQuota(quota).Decode(&v))
+	assert.Equal(t, int64(8), quota.Used())
+
+	err := NewBinDecoder(msg).WithQuota(quota).Decode(&v)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrDecodeQuotaExceeded))
+	assert.Contains(t, err.Error(), `stream "10.0.0.1:4242"`)
+	assert.Equal(t, int64(12), quota.Used())
+
+	dec := NewBinDecoder(msg).WithQuota(quota)
+	require.Error(t, dec.Decode(&v))
+	assert.Equal(t, 4, dec.Remaining(), "nothing is read once the quota is exhausted")
+
+	quota.Release(12)
+	require.NoError(t, dec.Decode(&v))
+	assert.Equal(t, uint32(1), v.A)
+	assert.Equal(t, "10.0.0.1:4242", quota.Name())
+}
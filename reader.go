@@ -0,0 +1,205 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// A Reader reads values one after the other from a byte slice, without
+// a struct definition, e.g. to probe a header:
+//
+//	r := bin.NewReader(data)
+//	version := r.U8()
+//	length := r.U32LE()
+//	hash := r.Bytes(32)
+//	if err := r.Err(); err != nil {
+//		return err
+//	}
+//
+// Errors are deferred: after the first failed read, the following ones
+// return zero values without reading, and Err returns that first error.
+type Reader struct {
+	dec *Decoder
+	err error
+}
+
+// NewReader returns a Reader over the provided data.
+func NewReader(data []byte) *Reader {
+	return &Reader{dec: NewBinDecoder(data)}
+}
+
+// Err returns the first error encountered by the reader, if any.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Offset returns the number of bytes read so far.
+func (r *Reader) Offset() int {
+	return int(r.dec.Position())
+}
+
+// Remaining returns the number of bytes left to read.
+func (r *Reader) Remaining() int {
+	return r.dec.Remaining()
+}
+
+// ok reports whether a value can be read; it records err
+// (the error of a read starting at offset) otherwise.
+func (r *Reader) ok(offset int, err error) bool {
+	if r.err != nil {
+		return false
+	}
+	if err != nil {
+		r.err = fmt.Errorf("read at offset %d: %w", offset, err)
+		return false
+	}
+	return true
+}
+
+func (r *Reader) u16(order binary.ByteOrder) uint16 {
+	if r.err != nil {
+		return 0
+	}
+	offset := r.Offset()
+	v, err := r.dec.ReadUint16(order)
+	if !r.ok(offset, err) {
+		return 0
+	}
+	return v
+}
+
+func (r *Reader) u32(order binary.ByteOrder) uint32 {
+	if r.err != nil {
+		return 0
+	}
+	offset := r.Offset()
+	v, err := r.dec.ReadUint32(order)
+	if !r.ok(offset, err) {
+		return 0
+	}
+	return v
+}
+
+func (r *Reader) u64(order binary.ByteOrder) uint64 {
+	if r.err != nil {
+		return 0
+	}
+	offset := r.Offset()
+	v, err := r.dec.ReadUint64(order)
+	if !r.ok(offset, err) {
+		return 0
+	}
+	return v
+}
+
+// U8 reads a byte.
+func (r *Reader) U8() uint8 {
+	if r.err != nil {
+		return 0
+	}
+	offset := r.Offset()
+	v, err := r.dec.ReadUint8()
+	if !r.ok(offset, err) {
+		return 0
+	}
+	return v
+}
+
+// Bool reads a byte that must be 0 or 1.
+func (r *Reader) Bool() bool {
+	if r.err != nil {
+		return false
+	}
+	offset := r.Offset()
+	v, err := r.dec.ReadBool()
+	if !r.ok(offset, err) {
+		return false
+	}
+	return v
+}
+
+func (r *Reader) U16LE() uint16 { return r.u16(LE) }
+func (r *Reader) U16BE() uint16 { return r.u16(BE) }
+func (r *Reader) U32LE() uint32 { return r.u32(LE) }
+func (r *Reader) U32BE() uint32 { return r.u32(BE) }
+func (r *Reader) U64LE() uint64 { return r.u64(LE) }
+func (r *Reader) U64BE() uint64 { return r.u64(BE) }
+
+func (r *Reader) I8() int8     { return int8(r.U8()) }
+func (r *Reader) I16LE() int16 { return int16(r.u16(LE)) }
+func (r *Reader) I16BE() int16 { return int16(r.u16(BE)) }
+func (r *Reader) I32LE() int32 { return int32(r.u32(LE)) }
+func (r *Reader) I32BE() int32 { return int32(r.u32(BE)) }
+func (r *Reader) I64LE() int64 { return int64(r.u64(LE)) }
+func (r *Reader) I64BE() int64 { return int64(r.u64(BE)) }
+
+// Uvarint reads an unsigned varint.
+func (r *Reader) Uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	offset := r.Offset()
+	v, err := r.dec.ReadUvarint64()
+	if !r.ok(offset, err) {
+		return 0
+	}
+	return v
+}
+
+// Varint reads a zigzag-encoded signed varint.
+func (r *Reader) Varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	offset := r.Offset()
+	v, err := r.dec.ReadVarint64()
+	if !r.ok(offset, err) {
+		return 0
+	}
+	return v
+}
+
+// Bytes reads n bytes; the returned slice aliases the data of the reader.
+func (r *Reader) Bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	offset := r.Offset()
+	v, err := r.dec.ReadNBytes(n)
+	if !r.ok(offset, err) {
+		return nil
+	}
+	return v
+}
+
+// Skip moves the reader past the next n bytes.
+func (r *Reader) Skip(n int) {
+	r.Bytes(n)
+}
+
+// Str reads a string prefixed by its length as a uvarint, like the Bin encoding does.
+func (r *Reader) Str() string {
+	if r.err != nil {
+		return ""
+	}
+	offset := r.Offset()
+	v, err := r.dec.ReadString()
+	if !r.ok(offset, err) {
+		return ""
+	}
+	return v
+}
@@ -0,0 +1,59 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader(t *testing.T) {
+	data := []byte{
+		0x01,
+		0x02, 0x00,
+		0x00, 0x00, 0x00, 0x03,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		0xAC, 0x02,
+		0x03,
+		0xAA, 0xBB,
+		0x01,
+	}
+	r := NewReader(data)
+	assert.Equal(t, uint8(1), r.U8())
+	assert.Equal(t, uint16(2), r.U16LE())
+	assert.Equal(t, uint32(3), r.U32BE())
+	assert.Equal(t, int64(-1), r.I64LE())
+	assert.Equal(t, uint64(300), r.Uvarint())
+	assert.Equal(t, int64(-2), r.Varint())
+	assert.Equal(t, []byte{0xAA, 0xBB}, r.Bytes(2))
+	assert.True(t, r.Bool())
+	assert.Equal(t, 0, r.Remaining())
+	require.NoError(t, r.Err())
+}
+
+func TestReader_DeferredError(t *testing.T) {
+	r := NewReader([]byte{0x01, 0x02, 0x03, 0x04, 0x05})
+	r.Skip(2)
+	assert.Equal(t, uint32(0), r.U32LE())
+	assert.Equal(t, uint8(0), r.U8())
+	assert.Nil(t, r.Bytes(1))
+
+	err := r.Err()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "read at offset 2")
+	assert.Equal(t, 2, r.Offset())
+}
@@ -0,0 +1,219 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Operations of a Fixture.
+const (
+	FixtureEncode = "encode"
+	FixtureDecode = "decode"
+)
+
+// A Fixture is a payload captured by a Recorder, e.g. to replay
+// in a test the data that made decoding fail in production.
+type Fixture struct {
+	// Op is FixtureEncode or FixtureDecode.
+	Op string `json:"op"`
+	// Type is the name of the type of the encoded or decoded value.
+	Type     string    `json:"type"`
+	Encoding Encoding  `json:"encoding"`
+	Time     time.Time `json:"time"`
+	// Data is the payload: the encoded value, the bytes a successful
+	// Decode read, or the bytes that were left when a Decode failed.
+	Data HexBytes `json:"data"`
+	// Error is the error of a failed Decode.
+	Error string `json:"error,omitempty"`
+}
+
+// Decode decodes the payload of the fixture into v, with its encoding.
+func (f *Fixture) Decode(v interface{}) error {
+	return NewDecoderWithEncoding(f.Data, f.Encoding).Decode(v)
+}
+
+// A Recorder tees the payloads of the encoders and decoders it's
+// attached to (see Encoder.WithRecorder and Decoder.WithRecorder) into
+// a fixture file, one JSON Fixture per line, that ReadFixtures loads back.
+// It records every top-level Encode that succeeds, and every top-level
+// Decode. A Recorder is safe for concurrent use; recording errors don't
+// fail the encoding or decoding, Err returns the first one.
+type Recorder struct {
+	mu     sync.Mutex
+	w      *json.Encoder
+	closer io.Closer
+	err    error
+}
+
+// NewRecorder returns a recorder that writes its fixtures to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: json.NewEncoder(w)}
+}
+
+// OpenRecorder returns a recorder that appends its fixtures to
+// the file at path, creating it if needed; Close closes the file.
+func OpenRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	r := NewRecorder(f)
+	r.closer = f
+	return r, nil
+}
+
+func (r *Recorder) record(f Fixture) {
+	f.Time = time.Now().UTC()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// A fixture is written at once, so fixtures recorded
+	// concurrently aren't interleaved.
+	if err := r.w.Encode(&f); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+// Err returns the first error that happened while recording.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close closes the file of a recorder returned by OpenRecorder,
+// and returns the first recording error, if any.
+func (r *Recorder) Close() error {
+	var err error
+	if r.closer != nil {
+		err = r.closer.Close()
+	}
+	if recErr := r.Err(); recErr != nil {
+		return recErr
+	}
+	return err
+}
+
+// WithRecorder makes the encoder record the payload of each successful
+// top-level Encode with r; a nil r stops recording.
+func (e *Encoder) WithRecorder(r *Recorder) *Encoder {
+	e.recorder = r
+	return e
+}
+
+func (e *Encoder) encodeRecorded(v interface{}) error {
+	output := e.output
+	buf := new(bytes.Buffer)
+	e.output = io.MultiWriter(output, buf)
+	e.recording = true
+	err := e.Encode(v)
+	e.output, e.recording = output, false
+	if err != nil {
+		return err
+	}
+	e.recorder.record(Fixture{Op: FixtureEncode, Type: typeNameOf(v), Encoding: e.encoding, Data: buf.Bytes()})
+	return nil
+}
+
+// WithRecorder makes the decoder record the payload of each top-level
+// Decode with r, including the ones that fail; a nil r stops recording.
+func (dec *Decoder) WithRecorder(r *Recorder) *Decoder {
+	dec.recorder = r
+	return dec
+}
+
+func (dec *Decoder) decodeRecorded(v interface{}) error {
+	start := dec.base + dec.pos
+	dec.recording = true
+	err := dec.Decode(v)
+	dec.recording = false
+	f := Fixture{Op: FixtureDecode, Type: typeNameOf(v), Encoding: dec.encoding}
+	if err != nil {
+		f.Data = dec.inputRange(start, dec.Len())
+		f.Error = err.Error()
+	} else {
+		f.Data = dec.inputRange(start, dec.base+dec.pos)
+	}
+	dec.recorder.record(f)
+	return err
+}
+
+// inputRange returns the bytes of the whole input between two offsets.
+func (dec *Decoder) inputRange(start, end int) []byte {
+	if dec.segments == nil {
+		return dec.data[start:end]
+	}
+	out := make([]byte, 0, end-start)
+	offset := 0
+	for _, seg := range dec.segments {
+		lo, hi := start-offset, end-offset
+		offset += len(seg)
+		if hi <= 0 {
+			break
+		}
+		if lo >= len(seg) {
+			continue
+		}
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > len(seg) {
+			hi = len(seg)
+		}
+		out = append(out, seg[lo:hi]...)
+	}
+	return out
+}
+
+// ReadFixtures reads the fixtures written by a Recorder.
+func ReadFixtures(r io.Reader) ([]Fixture, error) {
+	var out []Fixture
+	dec := json.NewDecoder(r)
+	for {
+		var f Fixture
+		err := dec.Decode(&f)
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("fixture %d: %w", len(out), err)
+		}
+		out = append(out, f)
+	}
+}
+
+// LoadFixtures reads the fixtures of the file at path, e.g.
+// a file of the testdata directory of a package:
+//
+//	fixtures, err := bin.LoadFixtures("testdata/orders.fixtures")
+//	require.NoError(t, err)
+//	for _, f := range fixtures {
+//		var order Order
+//		assert.NoError(t, f.Decode(&order), f.Time)
+//	}
+func LoadFixtures(path string) ([]Fixture, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadFixtures(f)
+}
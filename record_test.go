@@ -0,0 +1,105 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedOrder struct {
+	ID    uint64
+	Price int64
+	Note  string
+}
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fixtures")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "orders.fixtures")
+	rec, err := OpenRecorder(path)
+	require.NoError(t, err)
+
+	in := recordedOrder{ID: 1, Price: -5, Note: "limit"}
+	buf := new(bytes.Buffer)
+	enc := NewBorshEncoder(buf).WithRecorder(rec)
+	require.NoError(t, enc.Encode(&in))
+	payload := append([]byte(nil), buf.Bytes()...)
+
+	var out recordedOrder
+	dec := NewBorshDecoder(append(payload, payload[:10]...)).WithRecorder(rec)
+	require.NoError(t, dec.Decode(&out))
+	assert.Error(t, dec.Decode(&out))
+	require.NoError(t, rec.Close())
+
+	fixtures, err := LoadFixtures(path)
+	require.NoError(t, err)
+	require.Len(t, fixtures, 3)
+	for i, op := range []string{FixtureEncode, FixtureDecode, FixtureDecode} {
+		assert.Equal(t, op, fixtures[i].Op)
+		assert.Equal(t, "bin.recordedOrder", fixtures[i].Type)
+		assert.Equal(t, EncodingBorsh, fixtures[i].Encoding)
+		assert.False(t, fixtures[i].Time.IsZero())
+	}
+	assert.Equal(t, HexBytes(payload), fixtures[0].Data)
+	assert.Equal(t, HexBytes(payload), fixtures[1].Data)
+	assert.Empty(t, fixtures[1].Error)
+	assert.Equal(t, HexBytes(payload[:10]), fixtures[2].Data)
+	assert.NotEmpty(t, fixtures[2].Error)
+
+	var replayed recordedOrder
+	require.NoError(t, fixtures[1].Decode(&replayed))
+	assert.Equal(t, in, replayed)
+	assert.EqualError(t, fixtures[2].Decode(&replayed), fixtures[2].Error)
+
+	// Fixtures are appended to the file.
+	rec, err = OpenRecorder(path)
+	require.NoError(t, err)
+	require.NoError(t, NewBorshEncoder(new(bytes.Buffer)).WithRecorder(rec).Encode(&in))
+	require.NoError(t, rec.Close())
+	fixtures, err = LoadFixtures(path)
+	require.NoError(t, err)
+	assert.Len(t, fixtures, 4)
+}
+
+func TestRecorder_Buffers(t *testing.T) {
+	in := recordedOrder{ID: 2, Note: "split across buffers"}
+	data := mustMarshalBin(t, &in)
+
+	out := new(bytes.Buffer)
+	rec := NewRecorder(out)
+	dec := NewDecoderWithBuffers([][]byte{{0xff}, data[:5], data[5:12], data[12:]}, EncodingBin).WithRecorder(rec)
+	var skipped uint8
+	require.NoError(t, dec.Decode(&skipped))
+	var got recordedOrder
+	require.NoError(t, dec.Decode(&got))
+	require.NoError(t, rec.Err())
+
+	fixtures, err := ReadFixtures(out)
+	require.NoError(t, err)
+	require.Len(t, fixtures, 2)
+	assert.Equal(t, HexBytes{0xff}, fixtures[0].Data)
+	assert.Equal(t, HexBytes(data), fixtures[1].Data)
+
+	_, err = ReadFixtures(bytes.NewReader([]byte("{")))
+	assert.Error(t, err)
+}
@@ -0,0 +1,55 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrReservedNotZero is returned by decoders when the bytes of
+// a field tagged `bin:"reserved=N"` are not all zero.
+var ErrReservedNotZero = errors.New("reserved bytes are not zero")
+
+// WithLenientReserved makes the decoder accept non-zero bytes in the fields
+// tagged `bin:"reserved=N"`, e.g. to read data written by a newer version
+// of a protocol that started using them.
+func (dec *Decoder) WithLenientReserved() *Decoder {
+	dec.lenientReserved = true
+	return dec
+}
+
+// writeReserved writes the n zero bytes of a reserved field.
+func (e *Encoder) writeReserved(n int) error {
+	return e.toWriter(make([]byte, n))
+}
+
+// readReserved reads the n bytes of a reserved field,
+// which must be zero unless the decoder is lenient.
+func (dec *Decoder) readReserved(n int) error {
+	b, err := dec.ReadNBytes(n)
+	if err != nil {
+		return err
+	}
+	if dec.lenientReserved {
+		return nil
+	}
+	for _, c := range b {
+		if c != 0 {
+			return fmt.Errorf("%w: %x", ErrReservedNotZero, b)
+		}
+	}
+	return nil
+}
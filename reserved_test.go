@@ -0,0 +1,76 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type reservedHeader struct {
+	Version uint8
+	_       [3]byte `bin:"reserved=3"`
+	Flags   uint16
+	Spare   uint32 `bin:"reserved=2"`
+}
+
+func TestReserved(t *testing.T) {
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(reservedHeader{Version: 1, Flags: 0x0203, Spare: 7}))
+			assert.Equal(t, []byte{0x01, 0x00, 0x00, 0x00, 0x03, 0x02, 0x00, 0x00}, buf.Bytes())
+
+			var got reservedHeader
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&got))
+			assert.Equal(t, reservedHeader{Version: 1, Flags: 0x0203}, got)
+		})
+	}
+}
+
+func TestReserved_NotZero(t *testing.T) {
+	data := []byte{0x01, 0x00, 0x09, 0x00, 0x03, 0x02, 0x00, 0x00}
+
+	var got reservedHeader
+	err := NewBinDecoder(data).Decode(&got)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrReservedNotZero))
+	assert.Equal(t, "_", FieldPath(err))
+
+	require.NoError(t, NewBinDecoder(data).WithLenientReserved().Decode(&got))
+	assert.Equal(t, reservedHeader{Version: 1, Flags: 0x0203}, got)
+
+	// Like for integer enums, Conforms checks the layout but not the values.
+	require.NoError(t, Conforms(data, reservedHeader{}))
+	require.Error(t, Conforms(data[:7], reservedHeader{}))
+}
+
+func TestReserved_Layout(t *testing.T) {
+	res, err := Query([]byte{0x01, 0x00, 0x00, 0x00, 0x03, 0x02, 0x00, 0x00}, reservedHeader{}, "Flags")
+	require.NoError(t, err)
+	assert.Equal(t, 4, res.Start)
+
+	out, err := ExplainType(reservedHeader{})
+	require.NoError(t, err)
+	assert.Contains(t, out, "8 bytes")
+	assert.Contains(t, out, "reserved")
+
+	assert.Contains(t, parseFieldTag(`bin:"reserved=0"`).Invalid, "reserved=0")
+	assert.Contains(t, parseFieldTag(`bin:"reserved=x"`).Invalid, "reserved=x")
+}
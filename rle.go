@@ -0,0 +1,196 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+// MaxRLELength is the maximum number of elements of a decoded `rle` slice;
+// since runs expand, the length of such a slice can't be checked against the size
+// of the data, so this bounds the memory a few bytes of input can allocate.
+var MaxRLELength = 16 << 20
+
+// isRLEType reports whether the `rle` tag applies to the provided type.
+func isRLEType(rt reflect.Type) bool {
+	if rt.Kind() != reflect.Slice {
+		return false
+	}
+	k := rt.Elem().Kind()
+	return isSwappableKind(k) || k == reflect.Bool
+}
+
+// encodeRLE handles the slices affected by the `rle` tag: after the number
+// of elements, the slice is written as a sequence of runs of equal elements,
+// each run being its length as a uvarint followed by the element.
+// Runs are maximal: two consecutive runs always have different elements.
+func (e *Encoder) encodeRLE(rv reflect.Value, opt *option) (handled bool, err error) {
+	if !opt.RLE {
+		return false, nil
+	}
+	if !isRLEType(rv.Type()) {
+		return true, fmt.Errorf("rle: unsupported type %s, expected a slice of booleans or fixed-size integers", rv.Type())
+	}
+	l := rv.Len()
+	if opt.hasSizeOfSlice() {
+		l = opt.getSizeOfSlice()
+	} else if err := e.WriteLength(l); err != nil {
+		return true, err
+	}
+	order := byteOrder(e.encoding, opt)
+	size := int(rv.Type().Elem().Size())
+	for start := 0; start < l; {
+		v := rleValue(rv.Index(start))
+		end := start + 1
+		for end < l && rleValue(rv.Index(end)) == v {
+			end++
+		}
+		if e.tracing() {
+			e.tlog().Debug("encode: write rle run", logInt("len", end-start), logUint64("val", v))
+		}
+		if err := e.WriteUvarint64(uint64(end - start)); err != nil {
+			return true, newElementError("encoding", start, err)
+		}
+		if err := e.writeRLEValue(v, size, order); err != nil {
+			return true, newElementError("encoding", start, err)
+		}
+		start = end
+	}
+	return true, nil
+}
+
+func rleValue(rv reflect.Value) uint64 {
+	if rv.Kind() == reflect.Bool {
+		if rv.Bool() {
+			return 1
+		}
+		return 0
+	}
+	return enumValue(rv)
+}
+
+func (e *Encoder) writeRLEValue(v uint64, size int, order binary.ByteOrder) error {
+	switch size {
+	case 1:
+		return e.WriteUint8(uint8(v))
+	case 2:
+		return e.WriteUint16(uint16(v), order)
+	case 4:
+		return e.WriteUint32(uint32(v), order)
+	default:
+		return e.WriteUint64(v, order)
+	}
+}
+
+// decodeRLE handles the slices affected by the `rle` tag.
+func (dec *Decoder) decodeRLE(rv reflect.Value, opt *option) (handled bool, err error) {
+	if !opt.RLE {
+		return false, nil
+	}
+	if !isRLEType(rv.Type()) {
+		return true, fmt.Errorf("rle: unsupported type %s, expected a slice of booleans or fixed-size integers", rv.Type())
+	}
+	var l int
+	if opt.hasSizeOfSlice() {
+		l = opt.getSizeOfSlice()
+	} else if l, err = dec.ReadLength(); err != nil {
+		return true, err
+	}
+	if l > MaxRLELength {
+		return true, errRLELength(l)
+	}
+	out := reflect.MakeSlice(rv.Type(), l, l)
+	err = dec.readRuns(l, int(rv.Type().Elem().Size()), byteOrder(dec.encoding, opt), func(start, end int, v uint64) error {
+		elem := out.Index(start)
+		if elem.Kind() == reflect.Bool {
+			if v > 1 {
+				return fmt.Errorf("invalid bool %d", v)
+			}
+			elem.SetBool(v == 1)
+		} else {
+			size := 8 * uint(elem.Type().Size())
+			if elem.Kind() >= reflect.Int && elem.Kind() <= reflect.Int64 {
+				// Sign-extend the value.
+				v = uint64(int64(v<<(64-size)) >> (64 - size))
+			}
+			if err := setInteger(elem, v); err != nil {
+				return err
+			}
+		}
+		for i := start + 1; i < end; i++ {
+			out.Index(i).Set(elem)
+		}
+		return nil
+	})
+	if err != nil {
+		return true, err
+	}
+	rv.Set(out)
+	return true, nil
+}
+
+func errRLELength(l int) error {
+	return fmt.Errorf("rle: length %d exceeds the maximum of %d", l, MaxRLELength)
+}
+
+// readRuns reads the runs of an `rle` slice of l elements of the provided size,
+// calling fn with the range of elements of each run and their value.
+func (dec *Decoder) readRuns(l int, size int, order binary.ByteOrder, fn func(start, end int, v uint64) error) error {
+	if l > MaxRLELength {
+		return errRLELength(l)
+	}
+	var prev uint64
+	for start := 0; start < l; {
+		n, err := dec.ReadUvarint64()
+		if err != nil {
+			return newElementError("decoding", start, err)
+		}
+		if n == 0 || n > uint64(l-start) {
+			return newElementError("decoding", start, fmt.Errorf("rle: invalid run of %d elements, %d left", n, l-start))
+		}
+		var v uint64
+		switch size {
+		case 1:
+			var b uint8
+			b, err = dec.ReadUint8()
+			v = uint64(b)
+		case 2:
+			var u uint16
+			u, err = dec.ReadUint16(order)
+			v = uint64(u)
+		case 4:
+			var u uint32
+			u, err = dec.ReadUint32(order)
+			v = uint64(u)
+		default:
+			v, err = dec.ReadUint64(order)
+		}
+		if err != nil {
+			return newElementError("decoding", start, err)
+		}
+		if dec.canonical && start > 0 && v == prev {
+			return newElementError("decoding", start, ErrNonMaximalRun)
+		}
+		end := start + int(n)
+		if err := fn(start, end, v); err != nil {
+			return newElementError("decoding", start, err)
+		}
+		prev = v
+		start = end
+	}
+	return nil
+}
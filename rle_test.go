@@ -0,0 +1,103 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRLE(t *testing.T) {
+	type status struct {
+		Codes  []uint8  `bin:"rle"`
+		Flags  []bool   `bin:"rle"`
+		Levels []int16  `bin:"rle big"`
+		Empty  []uint32 `bin:"rle"`
+	}
+	in := status{
+		Codes:  []uint8{0, 0, 0, 0, 0, 1, 1, 0},
+		Flags:  []bool{true, true, true},
+		Levels: []int16{-1, -1, 2},
+		Empty:  nil,
+	}
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).Encode(in))
+	data := buf.Bytes()
+	assert.Equal(t, []byte{
+		0x08, 0x05, 0x00, 0x02, 0x01, 0x01, 0x00,
+		0x03, 0x03, 0x01,
+		0x03, 0x02, 0xff, 0xff, 0x01, 0x00, 0x02,
+		0x00,
+	}, data)
+
+	var out status
+	require.NoError(t, NewBinDecoder(data).Decode(&out))
+	assert.Equal(t, in.Codes, out.Codes)
+	assert.Equal(t, in.Flags, out.Flags)
+	assert.Equal(t, in.Levels, out.Levels)
+	assert.Empty(t, out.Empty)
+	require.NoError(t, Conforms(data, out))
+
+	for _, enc := range []Encoding{EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(in))
+		var out status
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out))
+		assert.Equal(t, in.Levels, out.Levels)
+		assert.NoError(t, ConformsWithEncoding(buf.Bytes(), enc, out))
+	}
+}
+
+func TestRLE_Invalid(t *testing.T) {
+	type codes struct {
+		Codes []uint8 `bin:"rle"`
+	}
+	tests := []struct {
+		name  string
+		data  []byte
+		error string
+	}{
+		{"empty run", []byte{0x02, 0x00, 0x01}, `error while decoding "Codes" field: error while decoding element 0: rle: invalid run of 0 elements, 2 left`},
+		{"run too long", []byte{0x02, 0x03, 0x01}, `error while decoding "Codes" field: error while decoding element 0: rle: invalid run of 3 elements, 2 left`},
+		{"too large", []byte{0x80, 0xc2, 0xd7, 0x2f, 0x01, 0x01}, `error while decoding "Codes" field: rle: length 100000000 exceeds the maximum of 16777216`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out codes
+			assert.EqualError(t, NewBinDecoder(test.data).Decode(&out), test.error)
+			assert.Error(t, Conforms(test.data, out))
+		})
+	}
+
+	// Non-maximal runs are accepted, except in canonical mode.
+	data := []byte{0x03, 0x01, 0x07, 0x02, 0x07}
+	var out codes
+	require.NoError(t, NewBinDecoder(data).Decode(&out))
+	assert.Equal(t, []uint8{7, 7, 7}, out.Codes)
+	err := NewBinDecoder(data).WithCanonicalMode().Decode(&out)
+	assert.True(t, errors.Is(err, ErrNonMaximalRun))
+	assert.Equal(t, "Codes[1]", FieldPath(err))
+
+	type bools struct {
+		Flags []bool `bin:"rle"`
+	}
+	var flags bools
+	assert.Error(t, NewBinDecoder([]byte{0x01, 0x01, 0x02}).Decode(&flags))
+	assert.Error(t, Conforms([]byte{0x01, 0x01, 0x02}, flags))
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+)
+
+// RuneFormat defines how `rune` and `[]rune` values are put on the wire.
+//
+// Since `rune` is an alias of `int32`, the two cannot be told apart via
+// reflection; the format must be selected with a struct tag:
+//
+//	type Message struct {
+//		Initial rune   `bin:"utf8"`  // 1 to 4 bytes of UTF-8
+//		Text    []rune `bin:"utf8"`  // encoded exactly like a string
+//		Raw     []rune `bin:"utf32"` // length prefix + 4-byte code points (default)
+//	}
+type RuneFormat int
+
+const (
+	// RuneFormatUTF32 encodes each rune as a 4-byte code point,
+	// which is how any other int32 is encoded. This is the default.
+	RuneFormatUTF32 RuneFormat = iota
+	// RuneFormatUTF8 transcodes runes to UTF-8: a single rune is written
+	// as its UTF-8 sequence, and a []rune is written as a string.
+	RuneFormatUTF8
+)
+
+func (f RuneFormat) String() string {
+	switch f {
+	case RuneFormatUTF32:
+		return "UTF32"
+	case RuneFormatUTF8:
+		return "UTF8"
+	default:
+		return ""
+	}
+}
+
+// WriteRuneUTF8 writes the UTF-8 sequence of the provided rune.
+// Invalid code points are rejected instead of being replaced by utf8.RuneError.
+func (e *Encoder) WriteRuneUTF8(r rune) (err error) {
+	if e.tracing() {
+		e.tlog().Debug("encode: write utf8 rune", logInt32("val", r))
+	}
+	if !utf8.ValidRune(r) {
+		return fmt.Errorf("invalid rune: %U", r)
+	}
+	buf := make([]byte, utf8.UTFMax)
+	n := utf8.EncodeRune(buf, r)
+	return e.toWriter(buf[:n])
+}
+
+// ReadRuneUTF8 reads a single UTF-8 encoded rune.
+func (dec *Decoder) ReadRuneUTF8() (out rune, err error) {
+	if dec.Remaining() < 1 {
+		err = fmt.Errorf("rune required at least [1] byte, remaining [%d]", dec.Remaining())
+		return
+	}
+	dec.fill(utf8.UTFMax)
+	out, size := utf8.DecodeRune(dec.data[dec.pos:])
+	if out == utf8.RuneError && size <= 1 {
+		return 0, fmt.Errorf("invalid utf8 sequence at position %d", dec.Position())
+	}
+	dec.pos += size
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read utf8 rune", logInt32("val", out))
+	}
+	return
+}
+
+// writeEncodingString writes a string the same way a string field
+// is written by the encoder's encoding.
+func (e *Encoder) writeEncodingString(s string) error {
+	if e.IsBin() {
+		return e.WriteRustString(s)
+	}
+	return e.WriteString(s)
+}
+
+// readEncodingString reads a string the same way a string field
+// is read by the decoder's encoding.
+func (dec *Decoder) readEncodingString() (string, error) {
+	if dec.IsBin() {
+		return dec.ReadRustString()
+	}
+	return dec.ReadString()
+}
+
+func isRuneSlice(rt reflect.Type) bool {
+	return rt.Kind() == reflect.Slice && rt.Elem().Kind() == reflect.Int32
+}
+
+// encodeRunes handles the values affected by a non-default rune format.
+func (e *Encoder) encodeRunes(rv reflect.Value, opt *option) (handled bool, err error) {
+	if opt.RuneFormat != RuneFormatUTF8 {
+		return false, nil
+	}
+	switch {
+	case rv.Kind() == reflect.Int32:
+		return true, e.WriteRuneUTF8(rune(rv.Int()))
+	case isRuneSlice(rv.Type()):
+		runes := make([]rune, rv.Len())
+		for i := range runes {
+			runes[i] = rune(rv.Index(i).Int())
+		}
+		for _, r := range runes {
+			if !utf8.ValidRune(r) {
+				return true, fmt.Errorf("invalid rune: %U", r)
+			}
+		}
+		return true, e.writeEncodingString(string(runes))
+	}
+	return false, nil
+}
+
+// decodeRunes handles the values affected by a non-default rune format.
+func (dec *Decoder) decodeRunes(rv reflect.Value, opt *option) (handled bool, err error) {
+	if opt.RuneFormat != RuneFormatUTF8 {
+		return false, nil
+	}
+	switch {
+	case rv.Kind() == reflect.Int32:
+		r, err := dec.ReadRuneUTF8()
+		if err != nil {
+			return true, err
+		}
+		rv.SetInt(int64(r))
+		return true, nil
+	case isRuneSlice(rv.Type()):
+		s, err := dec.readEncodingString()
+		if err != nil {
+			return true, err
+		}
+		if !utf8.ValidString(s) {
+			return true, fmt.Errorf("invalid utf8 string for %s", rv.Type())
+		}
+		runes := []rune(s)
+		out := reflect.MakeSlice(rv.Type(), len(runes), len(runes))
+		for i, r := range runes {
+			out.Index(i).SetInt(int64(r))
+		}
+		rv.Set(out)
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuneFormat(t *testing.T) {
+	type runes struct {
+		Initial rune   `bin:"utf8"`
+		Text    []rune `bin:"utf8"`
+		Raw     []rune `bin:"utf32"`
+		Code    rune
+	}
+	in := runes{
+		Initial: 'é',
+		Text:    []rune("héllo"),
+		Raw:     []rune("hé"),
+		Code:    'A',
+	}
+
+	{
+		data, err := MarshalBorsh(in)
+		require.NoError(t, err)
+		assert.Equal(t, concatByteSlices(
+			[]byte{0xc3, 0xa9},
+			[]byte{6, 0, 0, 0}, []byte("héllo"),
+			[]byte{2, 0, 0, 0}, []byte{'h', 0, 0, 0, 0xe9, 0, 0, 0},
+			[]byte{'A', 0, 0, 0},
+		), data)
+	}
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(in))
+
+		var out runes
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out))
+		assert.Equal(t, in, out, enc.String())
+	}
+}
+
+func TestRuneFormat_Invalid(t *testing.T) {
+	_, err := MarshalBin(struct {
+		R rune `bin:"utf8"`
+	}{R: 0xD800})
+	require.Error(t, err)
+
+	d := NewBinDecoder([]byte{0xff})
+	_, err = d.ReadRuneUTF8()
+	require.Error(t, err)
+}
@@ -0,0 +1,196 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A SizeFunc computes the number of elements of the target of a `sizeof` field
+// from the value of that field, for formats where they differ, e.g. a count
+// that excludes a trailing sentinel element:
+//
+//	bin.RegisterSizeFunc("withSentinel", func(count int) (int, error) {
+//		return count + 1, nil
+//	})
+//
+//	type Path struct {
+//		Count uint8    `bin:"sizeof=Hops sizefunc=withSentinel"`
+//		Hops  []uint32
+//	}
+//
+// It's used both when encoding and when decoding: the value of the `sizeof`
+// field isn't computed from its target, which is encoded with the number
+// of elements returned by the SizeFunc.
+type SizeFunc func(count int) (int, error)
+
+var sizeFuncs sync.Map
+
+// RegisterSizeFunc registers a SizeFunc under the provided name,
+// for use by `sizefunc=name` tags.
+func RegisterSizeFunc(name string, fn SizeFunc) {
+	if name == "" || fn == nil {
+		panic("RegisterSizeFunc: empty name or nil function")
+	}
+	sizeFuncs.Store(name, fn)
+}
+
+// applySizeFunc maps the value of a `sizeof` field to the number
+// of elements of its target with the named SizeFunc, if any.
+func applySizeFunc(name string, count int) (int, error) {
+	if name == "" {
+		return count, nil
+	}
+	fn, ok := sizeFuncs.Load(name)
+	if !ok {
+		return 0, fmt.Errorf("unknown sizefunc %q", name)
+	}
+	size, err := fn.(SizeFunc)(count)
+	if err != nil {
+		return 0, fmt.Errorf("sizefunc %q: %w", name, err)
+	}
+	if size < 0 {
+		return 0, fmt.Errorf("sizefunc %q: invalid size %d for count %d", name, size, count)
+	}
+	return size, nil
+}
+
+// parseSizeExpr parses the arithmetic of a `sizeof` tag: an operator,
+// one of * / + -, followed by a positive integer, e.g. "/4" or "*16".
+func parseSizeExpr(expr string) (op byte, n int, err error) {
+	if len(expr) < 2 {
+		return 0, 0, fmt.Errorf("invalid sizeof expression %q", expr)
+	}
+	n, err = strconv.Atoi(expr[1:])
+	if err != nil || n <= 0 {
+		return 0, 0, fmt.Errorf("invalid sizeof expression %q", expr)
+	}
+	return expr[0], n, nil
+}
+
+// applySizeExpr maps the value of a `sizeof` field to the number of elements
+// of its target with the arithmetic of its tag, if any, e.g. a count of bytes
+// divided by the size of the elements with `sizeof=Target/4`.
+func applySizeExpr(expr string, count int) (int, error) {
+	if expr == "" {
+		return count, nil
+	}
+	op, n, err := parseSizeExpr(expr)
+	if err != nil {
+		return 0, err
+	}
+	size := count
+	switch op {
+	case '*':
+		size = count * n
+		if count != 0 && size/count != n {
+			return 0, fmt.Errorf("sizeof expression %q: count %d overflows", expr, count)
+		}
+	case '/':
+		if count%n != 0 {
+			return 0, fmt.Errorf("sizeof expression %q: count %d is not a multiple of %d", expr, count, n)
+		}
+		size = count / n
+	case '+':
+		size = count + n
+	case '-':
+		size = count - n
+	}
+	if size < 0 {
+		return 0, fmt.Errorf("sizeof expression %q: invalid size %d for count %d", expr, size, count)
+	}
+	return size, nil
+}
+
+// sizeOfField returns the number of elements of the target
+// of the `sizeof` field with the provided tag and value.
+func sizeOfField(tag *fieldTag, rt reflect.Type, rv reflect.Value) (int, error) {
+	size, err := applySizeFunc(tag.SizeFunc, sizeof(rt, rv))
+	if err != nil {
+		return 0, err
+	}
+	return applySizeExpr(tag.SizeExpr, size)
+}
+
+// isSizeOfPath reports whether a `sizeof` tag is a dotted path, e.g.
+// `sizeof=Header.Count`: it's then on the slice itself, and names the
+// field holding its number of elements in an earlier struct field.
+func isSizeOfPath(tag *fieldTag) bool {
+	return strings.Contains(tag.SizeOf, ".")
+}
+
+// sizeOfPathType returns the type of the field named by a dotted
+// `sizeof` path in the struct type rt, looking only at the fields
+// before the one at index `before` of its plan.
+func sizeOfPathType(rt reflect.Type, before int, path string) (reflect.Type, error) {
+	names := strings.Split(path, ".")
+	plan := planOf(rt)
+	first := plan.fieldIndex(names[0])
+	if first < 0 || first >= before || plan.fields[first].PkgPath != "" {
+		return nil, fmt.Errorf("sizeof path %q: no field %q before this one", path, names[0])
+	}
+	ft := plan.fields[first].Type
+	for _, name := range names[1:] {
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("sizeof path %q: %s has no field %q", path, ft, name)
+		}
+		inner := planOf(ft)
+		i := inner.fieldIndex(name)
+		if i < 0 || inner.fields[i].PkgPath != "" {
+			return nil, fmt.Errorf("sizeof path %q: %s has no field %q", path, ft, name)
+		}
+		ft = inner.fields[i].Type
+	}
+	if !isIntegerKind(ft.Kind()) {
+		return nil, fmt.Errorf("sizeof path %q: %s is not an integer", path, ft)
+	}
+	return ft, nil
+}
+
+// sizeOfPathValue returns the field named by a dotted `sizeof` path in the struct rv.
+func sizeOfPathValue(rv reflect.Value, path string) (reflect.Value, error) {
+	for _, name := range strings.Split(path, ".") {
+		for rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return reflect.Value{}, fmt.Errorf("sizeof path %q: nil pointer before %q", path, name)
+			}
+			rv = rv.Elem()
+		}
+		plan := planOf(rv.Type())
+		i := plan.fieldIndex(name)
+		if i < 0 {
+			return reflect.Value{}, fmt.Errorf("sizeof path %q: %s has no field %q", path, rv.Type(), name)
+		}
+		rv = plan.field(rv, i)
+	}
+	return rv, nil
+}
+
+// sizeOfPath returns the number of elements of the slice whose tag is a
+// dotted `sizeof` path, from the field it names in the struct rv.
+func sizeOfPath(tag *fieldTag, rv reflect.Value) (int, error) {
+	v, err := sizeOfPathValue(rv, tag.SizeOf)
+	if err != nil {
+		return 0, err
+	}
+	return sizeOfField(tag, v.Type(), v)
+}
@@ -0,0 +1,212 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	RegisterSizeFunc("test.withSentinel", func(count int) (int, error) {
+		return count + 1, nil
+	})
+	RegisterSizeFunc("test.even", func(count int) (int, error) {
+		if count%2 != 0 {
+			return 0, errors.New("odd count")
+		}
+		return count, nil
+	})
+}
+
+type sizeFuncPath struct {
+	Count uint8 `bin:"sizeof=Hops sizefunc=test.withSentinel"`
+	Hops  []uint16
+	Tail  uint8
+}
+
+func TestSizeFunc(t *testing.T) {
+	path := sizeFuncPath{Count: 2, Hops: []uint16{1, 2, 0xFFFF}, Tail: 7}
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(path))
+			assert.Equal(t, []byte{0x02, 0x01, 0x00, 0x02, 0x00, 0xFF, 0xFF, 0x07}, buf.Bytes())
+
+			var got sizeFuncPath
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&got))
+			assert.Equal(t, path, got)
+
+			require.NoError(t, ConformsWithEncoding(buf.Bytes(), enc, sizeFuncPath{}))
+		})
+	}
+
+	explained, err := ExplainType(sizeFuncPath{})
+	require.NoError(t, err)
+	assert.Contains(t, explained, "sizeof=Count,sizefunc=test.withSentinel")
+
+	plain, err := TypeIDFromLayout(struct {
+		Count uint8 `bin:"sizeof=Hops"`
+		Hops  []uint16
+		Tail  uint8
+	}{})
+	require.NoError(t, err)
+	withFunc, err := TypeIDFromLayout(sizeFuncPath{})
+	require.NoError(t, err)
+	assert.NotEqual(t, plain, withFunc)
+}
+
+func TestSizeFunc_Errors(t *testing.T) {
+	type odd struct {
+		Count uint8 `bin:"sizeof=Items sizefunc=test.even"`
+		Items []uint8
+	}
+	err := NewBinDecoder([]byte{0x01, 0x05}).Decode(&odd{})
+	assert.EqualError(t, err, `error while decoding "Count" field: sizefunc "test.even": odd count`)
+
+	type unknown struct {
+		Count uint8 `bin:"sizeof=Items sizefunc=test.unknown"`
+		Items []uint8
+	}
+	err = NewBinEncoder(new(bytes.Buffer)).Encode(unknown{Count: 1, Items: []uint8{1}})
+	assert.EqualError(t, err, `error while encoding "Count" field: unknown sizefunc "test.unknown"`)
+
+	type withoutSizeOf struct {
+		Count uint8 `bin:"sizefunc=test.even"`
+	}
+	assert.Error(t, Precompile(withoutSizeOf{}))
+}
+
+type sizeExprFrame struct {
+	Bytes  uint16 `bin:"sizeof=Words/4"`
+	Words  []uint32
+	Blocks uint8 `bin:"sizeof=Data*2"`
+	Data   [][8]byte
+}
+
+func TestSizeExpr(t *testing.T) {
+	frame := sizeExprFrame{
+		Bytes:  8,
+		Words:  []uint32{1, 2},
+		Blocks: 1,
+		Data:   [][8]byte{{1}, {2}},
+	}
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(frame), enc)
+		assert.Equal(t, 2+8+1+16, buf.Len(), enc)
+
+		var got sizeExprFrame
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&got), enc)
+		assert.Equal(t, frame, got, enc)
+		require.NoError(t, ConformsWithEncoding(buf.Bytes(), enc, sizeExprFrame{}), enc)
+	}
+
+	explained, err := ExplainType(sizeExprFrame{})
+	require.NoError(t, err)
+	assert.Contains(t, explained, "sizeof=Bytes/4")
+	ksy, err := KaitaiStruct(sizeExprFrame{}, EncodingBin)
+	require.NoError(t, err)
+	assert.Contains(t, ksy, "repeat-expr: '(bytes / 4)'")
+
+	var got sizeExprFrame
+	err = UnmarshalBin(&got, []byte{6, 0, 1, 0, 0, 0})
+	assert.EqualError(t, err, `error while decoding "Bytes" field: sizeof expression "/4": count 6 is not a multiple of 4`)
+
+	tag := parseFieldTag(`bin:"sizeof=Items-1"`)
+	assert.Equal(t, "Items", tag.SizeOf)
+	assert.Equal(t, "-1", tag.SizeExpr)
+	assert.Equal(t, []string{"sizeof=Items/0", "sizeof=*4"}, parseFieldTag(`bin:"sizeof=Items/0 sizeof=*4"`).Invalid)
+}
+
+type SizeOfPathHeader struct {
+	Version uint8
+	Count   uint16
+}
+
+type sizeOfPathFrame struct {
+	SizeOfPathHeader
+	Items []uint32 `bin:"sizeof=SizeOfPathHeader.Count"`
+	Tail  uint8
+}
+
+type sizeOfPathNested struct {
+	Header struct {
+		Words uint8
+	}
+	Words []uint16 `bin:"sizeof=Header.Words/2"`
+	Tail  uint8
+}
+
+func TestSizeOfPath(t *testing.T) {
+	frame := sizeOfPathFrame{
+		SizeOfPathHeader: SizeOfPathHeader{Version: 1, Count: 2},
+		Items:            []uint32{7, 8},
+		Tail:             9,
+	}
+	nested := sizeOfPathNested{Words: []uint16{1, 2, 3}, Tail: 4}
+	nested.Header.Words = 6
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(frame), enc)
+		assert.Equal(t, 3+8+1, buf.Len(), enc)
+
+		var got sizeOfPathFrame
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&got), enc)
+		assert.Equal(t, frame, got, enc)
+		require.NoError(t, ConformsWithEncoding(buf.Bytes(), enc, sizeOfPathFrame{}), enc)
+
+		res, err := QueryWithEncoding(buf.Bytes(), enc, sizeOfPathFrame{}, "Tail")
+		require.NoError(t, err, enc)
+		assert.Equal(t, uint8(9), res.Value, enc)
+
+		buf.Reset()
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(nested), enc)
+		assert.Equal(t, 1+6+1, buf.Len(), enc)
+		var gotNested sizeOfPathNested
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&gotNested), enc)
+		assert.Equal(t, nested, gotNested, enc)
+		require.NoError(t, ConformsWithEncoding(buf.Bytes(), enc, sizeOfPathNested{}), enc)
+	}
+
+	explained, err := ExplainType(sizeOfPathNested{})
+	require.NoError(t, err)
+	assert.Contains(t, explained, "sizeof=Header.Words/2")
+	ksy, err := KaitaiStruct(sizeOfPathNested{}, EncodingBin)
+	require.NoError(t, err)
+	assert.Contains(t, ksy, "repeat-expr: '(header.words / 2)'")
+}
+
+func TestSizeOfPath_Errors(t *testing.T) {
+	type later struct {
+		Items  []uint8 `bin:"sizeof=Header.Count"`
+		Header SizeOfPathHeader
+	}
+	type notInteger struct {
+		Header struct{ Name string }
+		Items  []uint8 `bin:"sizeof=Header.Name"`
+	}
+	type notSlice struct {
+		Header SizeOfPathHeader
+		Items  string `bin:"sizeof=Header.Count"`
+	}
+	assert.EqualError(t, Precompile(later{}), `precompile: bin.later: field "Items": sizeof path "Header.Count": no field "Header" before this one`)
+	assert.EqualError(t, Precompile(notInteger{}), `precompile: bin.notInteger: field "Items": sizeof path "Header.Name": string is not an integer`)
+	assert.EqualError(t, Precompile(notSlice{}), `precompile: bin.notSlice: field "Items": a sizeof path only applies to slices, got string`)
+}
@@ -0,0 +1,204 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// isSizeOfTarget reports whether values of the provided type can be
+// the target of a `sizeof` field: slices, strings, maps and byte arrays,
+// or pointers to them.
+func isSizeOfTarget(rt reflect.Type) bool {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	switch rt.Kind() {
+	case reflect.Slice, reflect.String, reflect.Map:
+		return true
+	case reflect.Array:
+		return rt.Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+// sizeOfLen returns the length of the target of a `sizeof` field, used
+// to populate the field when it's zero: the number of elements of a slice
+// or a map, the number of bytes of a string, and the number of bytes of a
+// byte array up to its trailing zeros.
+func sizeOfLen(rv reflect.Value) (int, bool) {
+	switch rv.Kind() {
+	case reflect.Slice, reflect.String, reflect.Map:
+		return rv.Len(), true
+	case reflect.Array:
+		if rv.Type().Elem().Kind() != reflect.Uint8 {
+			return 0, false
+		}
+		return len(bytes.TrimRight(byteArray(rv), "\x00")), true
+	}
+	return 0, false
+}
+
+// sizeOfCounter returns the value to encode of the `sizeof` field at index i
+// of the plan of the struct rv, and the number of elements of its target. A
+// zero field without a SizeFunc is populated from the length of its target.
+func sizeOfCounter(plan *structPlan, rv reflect.Value, i int) (reflect.Value, int, error) {
+	tag := plan.tags[i]
+	field := plan.field(rv, i)
+	if tag.SizeFunc == "" && field.CanInterface() && isIntegerKind(field.Kind()) && field.IsZero() {
+		if j := plan.fieldIndex(tag.SizeOf); j >= 0 {
+			if l, ok := sizeOfLen(plan.field(rv, j)); ok && l > 0 {
+				count, err := unapplySizeExpr(tag.SizeExpr, l)
+				if err != nil {
+					return field, 0, err
+				}
+				counter := reflect.New(field.Type()).Elem()
+				if err := setCount(counter, count); err != nil {
+					return field, 0, fmt.Errorf("length of %q: %w", tag.SizeOf, err)
+				}
+				return counter, l, nil
+			}
+		}
+	}
+	size, err := sizeOfField(tag, field.Type(), field)
+	return field, size, err
+}
+
+// unapplySizeExpr maps the length of the target of a `sizeof` field
+// to the value of that field, the inverse of applySizeExpr.
+func unapplySizeExpr(expr string, size int) (int, error) {
+	if expr == "" {
+		return size, nil
+	}
+	op, n, err := parseSizeExpr(expr)
+	if err != nil {
+		return 0, err
+	}
+	switch op {
+	case '*':
+		if size%n != 0 {
+			return 0, fmt.Errorf("sizeof expression %q: length %d is not a multiple of %d", expr, size, n)
+		}
+		return size / n, nil
+	case '/':
+		count := size * n
+		if count/n != size {
+			return 0, fmt.Errorf("sizeof expression %q: length %d overflows", expr, size)
+		}
+		return count, nil
+	case '+':
+		if size < n {
+			return 0, fmt.Errorf("sizeof expression %q: invalid length %d", expr, size)
+		}
+		return size - n, nil
+	}
+	return size + n, nil
+}
+
+// setCount sets the integer rv to count, unless it overflows.
+func setCount(rv reflect.Value, count int) error {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.OverflowInt(int64(count)) {
+			return fmt.Errorf("%d overflows %s", count, rv.Type())
+		}
+		rv.SetInt(int64(count))
+	default:
+		if rv.OverflowUint(uint64(count)) {
+			return fmt.Errorf("%d overflows %s", count, rv.Type())
+		}
+		rv.SetUint(uint64(count))
+	}
+	return nil
+}
+
+func byteArray(rv reflect.Value) []byte {
+	b := make([]byte, rv.Len())
+	reflect.Copy(reflect.ValueOf(b), rv)
+	return b
+}
+
+// encodeSizeOfTarget encodes a string, a map or a byte array whose
+// length is held by a `sizeof` field, without a length prefix: a byte
+// array is encoded as its first bytes, and the bytes after them must be zeros.
+func (e *Encoder) encodeSizeOfTarget(rv reflect.Value, opt *option) (bool, error) {
+	if opt == nil || !opt.hasSizeOfSlice() {
+		return false, nil
+	}
+	l := opt.getSizeOfSlice()
+	switch rv.Kind() {
+	case reflect.String:
+		if rv.Len() != l {
+			return true, fmt.Errorf("string of %d bytes, its sizeof field holds %d", rv.Len(), l)
+		}
+		return true, e.WriteBytes([]byte(rv.String()), false)
+	case reflect.Map:
+		if rv.Len() != l {
+			return true, fmt.Errorf("map of %d entries, its sizeof field holds %d", rv.Len(), l)
+		}
+		return true, e.encodeMapEntries(rv)
+	case reflect.Array:
+		if rv.Type().Elem().Kind() != reflect.Uint8 {
+			return false, nil
+		}
+		b := byteArray(rv)
+		if l > len(b) {
+			return true, fmt.Errorf("sizeof field holds %d, larger than %s", l, rv.Type())
+		}
+		if len(bytes.TrimRight(b[l:], "\x00")) > 0 {
+			return true, fmt.Errorf("%s has non-zero bytes after the %d of its sizeof field", rv.Type(), l)
+		}
+		return true, e.WriteBytes(b[:l], false)
+	}
+	return false, nil
+}
+
+// decodeSizeOfTarget decodes a string, a map or a byte array
+// whose length is held by a `sizeof` field.
+func (dec *Decoder) decodeSizeOfTarget(rv reflect.Value, opt *option) (bool, error) {
+	if opt == nil || !opt.hasSizeOfSlice() {
+		return false, nil
+	}
+	l := opt.getSizeOfSlice()
+	rt := rv.Type()
+	switch rt.Kind() {
+	case reflect.String:
+		b, err := dec.ReadNBytes(l)
+		if err != nil {
+			return true, err
+		}
+		rv.SetString(string(b))
+		return true, nil
+	case reflect.Map:
+		return true, dec.decodeMapEntries(rv, l)
+	case reflect.Array:
+		if rt.Elem().Kind() != reflect.Uint8 {
+			return false, nil
+		}
+		if l > rt.Len() {
+			return true, fmt.Errorf("sizeof field holds %d, larger than %s", l, rt)
+		}
+		b, err := dec.ReadNBytes(l)
+		if err != nil {
+			return true, err
+		}
+		rv.Set(reflect.Zero(rt))
+		reflect.Copy(rv, reflect.ValueOf(b))
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sizeOfTargetsFrame struct {
+	NameLen  uint8  `bin:"sizeof=Name"`
+	TagCount uint16 `bin:"sizeof=Tags"`
+	KeyLen   uint8  `bin:"sizeof=Key"`
+	Bytes    uint8  `bin:"sizeof=Words/4"`
+	Name     string
+	Tags     map[string]uint8
+	Key      [8]byte
+	Words    []uint32
+	Tail     uint8
+}
+
+func TestSizeOf_Targets(t *testing.T) {
+	// The sizeof fields are left to zero, and populated when encoding.
+	frame := sizeOfTargetsFrame{
+		Name:  "alice",
+		Tags:  map[string]uint8{"a": 1, "b": 2},
+		Key:   [8]byte{1, 2, 3},
+		Words: []uint32{7, 8},
+		Tail:  9,
+	}
+	want := frame
+	want.NameLen, want.TagCount, want.KeyLen, want.Bytes = 5, 2, 3, 8
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(frame), enc)
+
+		var got sizeOfTargetsFrame
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&got), enc)
+		assert.Equal(t, want, got, enc)
+		require.NoError(t, ConformsWithEncoding(buf.Bytes(), enc, sizeOfTargetsFrame{}), enc)
+
+		res, err := QueryWithEncoding(buf.Bytes(), enc, sizeOfTargetsFrame{}, "Tail")
+		require.NoError(t, err, enc)
+		assert.Equal(t, uint8(9), res.Value, enc)
+
+		// Set sizeof fields are encoded as is.
+		buf.Reset()
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(want), enc)
+		got = sizeOfTargetsFrame{}
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&got), enc)
+		assert.Equal(t, want, got, enc)
+	}
+
+	data, err := MarshalBin(&sizeOfTargetsFrame{Name: "bob", Tail: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{3, 0, 0, 0, 0, 'b', 'o', 'b', 1}, data)
+
+	explained, err := ExplainType(sizeOfTargetsFrame{})
+	require.NoError(t, err)
+	assert.Contains(t, explained, "sizeof=NameLen")
+}
+
+func TestSizeOf_TargetErrors(t *testing.T) {
+	_, err := MarshalBin(&sizeOfTargetsFrame{NameLen: 3, Name: "alice"})
+	assert.EqualError(t, err, `error while encoding "Name" field: string of 5 bytes, its sizeof field holds 3`)
+
+	_, err = MarshalBin(&sizeOfTargetsFrame{KeyLen: 1, Key: [8]byte{1, 2}})
+	assert.EqualError(t, err, `error while encoding "Key" field: [8]uint8 has non-zero bytes after the 1 of its sizeof field`)
+
+	var got sizeOfTargetsFrame
+	err = UnmarshalBin(&got, []byte{0, 0, 0, 9, 0})
+	assert.EqualError(t, err, `error while decoding "Key" field: sizeof field holds 9, larger than [8]uint8`)
+
+	type overflow struct {
+		Len  uint8 `bin:"sizeof=Data"`
+		Data string
+	}
+	_, err = MarshalBin(&overflow{Data: string(make([]byte, 300))})
+	assert.EqualError(t, err, `error while encoding "Len" field: length of "Data": 300 overflows uint8`)
+
+	type notTarget struct {
+		Len  uint8 `bin:"sizeof=Data"`
+		Data uint32
+	}
+	assert.EqualError(t, Precompile(notTarget{}), `precompile: bin.notTarget: field "Len": sizeof target "Data" must be a slice, string, map or byte array, got uint32`)
+}
@@ -0,0 +1,367 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// A snapshot is a stream of values, encoded and compressed in chunks
+// that are checksummed, so that large dumps can be written by several
+// goroutines and corruption is detected when reading them back:
+//
+//	header: "BINS", u8 version, u8 encoding of the values
+//	chunks: uvarint number of values, uvarint size of the block, u32 LE CRC-32C
+//	        of the block, and the block (the encoded values, compressed
+//	        as by NewCompressedWriter)
+//	end:    a chunk of zero values, with an empty block
+const (
+	snapshotMagic   = "BINS"
+	snapshotVersion = 1
+
+	// DefaultSnapshotChunkRecords is the default number of values per chunk.
+	DefaultSnapshotChunkRecords = 4096
+)
+
+var (
+	// ErrSnapshotChecksum is returned when a chunk of a snapshot doesn't match its checksum.
+	ErrSnapshotChecksum = errors.New("snapshot chunk checksum mismatch")
+	// ErrInvalidSnapshot is returned when reading data that isn't a snapshot.
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+)
+
+var snapshotTable = crc32.MakeTable(crc32.Castagnoli)
+
+// SnapshotOptions configures a SnapshotWriter.
+type SnapshotOptions struct {
+	// Workers is the number of goroutines encoding and compressing
+	// chunks; it defaults to GOMAXPROCS.
+	Workers int
+	// ChunkRecords is the number of values per chunk; it defaults
+	// to DefaultSnapshotChunkRecords. A chunk must not encode to more
+	// than MaxDecompressedSize bytes.
+	ChunkRecords int
+	// Dictionary is the ID of the compression dictionary of the chunks
+	// (see RegisterCompressionDictionary), zero for none.
+	Dictionary uint32
+}
+
+// A SnapshotWriter writes a snapshot of values: chunks of values are encoded,
+// compressed and checksummed in parallel by a pool of workers, and written
+// in order:
+//
+//	sw := bin.NewSnapshotWriter(file, bin.EncodingBorsh, bin.SnapshotOptions{})
+//	for _, account := range accounts {
+//		if err := sw.Write(account); err != nil {
+//			return err
+//		}
+//	}
+//	if err := sw.Close(); err != nil {
+//		return err
+//	}
+//
+// Values are encoded asynchronously, so they must not be modified after being
+// written (e.g. pass values rather than a pointer to a reused one).
+// A SnapshotWriter isn't safe for concurrent use.
+type SnapshotWriter struct {
+	w    io.Writer
+	enc  Encoding
+	opts SnapshotOptions
+
+	batch   []interface{}
+	work    chan *snapshotChunk
+	pending chan *snapshotChunk
+	done    chan struct{}
+	closed  bool
+
+	mu  sync.Mutex
+	err error
+}
+
+type snapshotChunk struct {
+	values []interface{}
+	frame  chan snapshotFrame
+}
+
+type snapshotFrame struct {
+	data []byte
+	err  error
+}
+
+// NewSnapshotWriter returns a writer of a snapshot of values encoded with
+// the provided encoding, and starts its workers; Close must be called to
+// write the end of the snapshot and stop them.
+func NewSnapshotWriter(w io.Writer, enc Encoding, opts SnapshotOptions) *SnapshotWriter {
+	if !isValidEncoding(enc) {
+		panic(fmt.Sprintf("provided encoding is not valid: %s", enc))
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	if opts.ChunkRecords <= 0 {
+		opts.ChunkRecords = DefaultSnapshotChunkRecords
+	}
+	sw := &SnapshotWriter{
+		w:    w,
+		enc:  enc,
+		opts: opts,
+		work: make(chan *snapshotChunk, opts.Workers),
+		// Chunks are written in the order they were queued; at most
+		// this many are encoded or waiting to be written at a time.
+		pending: make(chan *snapshotChunk, 2*opts.Workers),
+		done:    make(chan struct{}),
+	}
+	for i := 0; i < opts.Workers; i++ {
+		go func() {
+			for chunk := range sw.work {
+				data, err := sw.encodeChunk(chunk.values)
+				chunk.frame <- snapshotFrame{data, err}
+			}
+		}()
+	}
+	go sw.writeChunks()
+	return sw
+}
+
+// Write adds v to the snapshot. It returns the first error encountered
+// while encoding or writing the previous chunks, if any.
+func (sw *SnapshotWriter) Write(v interface{}) error {
+	if sw.closed {
+		return errors.New("snapshot: write after close")
+	}
+	if err := sw.loadErr(); err != nil {
+		return err
+	}
+	sw.batch = append(sw.batch, v)
+	if len(sw.batch) == sw.opts.ChunkRecords {
+		sw.flush()
+	}
+	return nil
+}
+
+// Close writes the last chunk and the end of the snapshot, and stops the
+// workers; it doesn't close the underlying writer. It returns the first
+// error encountered while encoding or writing the snapshot.
+func (sw *SnapshotWriter) Close() error {
+	if sw.closed {
+		return sw.loadErr()
+	}
+	sw.closed = true
+	if len(sw.batch) > 0 {
+		sw.flush()
+	}
+	close(sw.work)
+	close(sw.pending)
+	<-sw.done
+
+	if err := sw.loadErr(); err != nil {
+		return err
+	}
+	end, err := snapshotChunkFrame(0, nil)
+	if err != nil {
+		return err
+	}
+	return sw.writeAll(end)
+}
+
+func (sw *SnapshotWriter) flush() {
+	chunk := &snapshotChunk{
+		values: sw.batch,
+		frame:  make(chan snapshotFrame, 1),
+	}
+	sw.batch = make([]interface{}, 0, sw.opts.ChunkRecords)
+	sw.pending <- chunk
+	sw.work <- chunk
+}
+
+// writeChunks writes the header, then the chunks in the order they were queued.
+func (sw *SnapshotWriter) writeChunks() {
+	defer close(sw.done)
+	if err := sw.writeAll(append([]byte(snapshotMagic), snapshotVersion, byte(sw.enc))); err != nil {
+		// Keep draining the chunks so that the workers and Write don't block.
+		for chunk := range sw.pending {
+			<-chunk.frame
+		}
+		return
+	}
+	for chunk := range sw.pending {
+		frame := <-chunk.frame
+		if sw.loadErr() != nil {
+			continue
+		}
+		if frame.err != nil {
+			sw.storeErr(frame.err)
+			continue
+		}
+		sw.writeAll(frame.data)
+	}
+}
+
+func (sw *SnapshotWriter) encodeChunk(values []interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := NewEncoderWithEncoding(buf, sw.enc)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			return nil, fmt.Errorf("snapshot: %w", err)
+		}
+	}
+	if buf.Len() > MaxDecompressedSize {
+		return nil, fmt.Errorf("snapshot: chunk of %d bytes exceeds %d bytes, lower ChunkRecords", buf.Len(), MaxDecompressedSize)
+	}
+	block, err := compressBlock(nil, buf.Bytes(), sw.opts.Dictionary)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+	return snapshotChunkFrame(len(values), block)
+}
+
+func snapshotChunkFrame(count int, block []byte) ([]byte, error) {
+	return NewWriter().
+		Uvarint(uint64(count)).
+		Uvarint(uint64(len(block))).
+		U32LE(crc32.Checksum(block, snapshotTable)).
+		Bytes(block).
+		Result()
+}
+
+func (sw *SnapshotWriter) writeAll(b []byte) error {
+	n, err := sw.w.Write(b)
+	if err == nil && n < len(b) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		sw.storeErr(fmt.Errorf("snapshot: %w", err))
+	}
+	return sw.loadErr()
+}
+
+func (sw *SnapshotWriter) loadErr() error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.err
+}
+
+func (sw *SnapshotWriter) storeErr(err error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.err == nil {
+		sw.err = err
+	}
+}
+
+// A SnapshotReader reads the values of a snapshot written by SnapshotWriter,
+// one chunk at a time.
+type SnapshotReader struct {
+	r     *bufio.Reader
+	enc   Encoding
+	dec   *Decoder
+	left  uint64
+	chunk int
+	ended bool
+}
+
+// NewSnapshotReader reads the header of a snapshot and returns a reader of its values.
+func NewSnapshotReader(r io.Reader) (*SnapshotReader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(snapshotMagic)+2)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("snapshot: header: %w", err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic || header[4] != snapshotVersion {
+		return nil, fmt.Errorf("snapshot: bad header: %w", ErrInvalidSnapshot)
+	}
+	enc := Encoding(header[5])
+	if !isValidEncoding(enc) {
+		return nil, fmt.Errorf("snapshot: invalid encoding %d: %w", enc, ErrInvalidSnapshot)
+	}
+	return &SnapshotReader{r: br, enc: enc}, nil
+}
+
+// Encoding returns the encoding of the values.
+func (sr *SnapshotReader) Encoding() Encoding {
+	return sr.enc
+}
+
+// Next decodes the next value into v, which must be a pointer. It returns
+// io.EOF after the last value, and io.ErrUnexpectedEOF if the snapshot
+// is truncated.
+func (sr *SnapshotReader) Next(v interface{}) error {
+	for sr.left == 0 {
+		if sr.ended {
+			return io.EOF
+		}
+		if err := sr.readChunk(); err != nil {
+			return err
+		}
+	}
+	if err := sr.dec.Decode(v); err != nil {
+		return fmt.Errorf("snapshot: chunk %d: %w", sr.chunk-1, err)
+	}
+	sr.left--
+	if sr.left == 0 && sr.dec.HasRemaining() {
+		return fmt.Errorf("snapshot: chunk %d: %d bytes left after the last value: %w", sr.chunk-1, sr.dec.Remaining(), ErrInvalidSnapshot)
+	}
+	return nil
+}
+
+func (sr *SnapshotReader) readChunk() error {
+	count, err := binary.ReadUvarint(sr.r)
+	if err != nil {
+		return sr.truncated(err)
+	}
+	size, err := binary.ReadUvarint(sr.r)
+	if err != nil {
+		return sr.truncated(err)
+	}
+	if size > 2*MaxDecompressedSize {
+		return fmt.Errorf("snapshot: chunk %d: block of %d bytes: %w", sr.chunk, size, ErrInvalidSnapshot)
+	}
+	frame := make([]byte, TypeSize.Uint32+int(size))
+	if _, err := io.ReadFull(sr.r, frame); err != nil {
+		return sr.truncated(err)
+	}
+	sum, block := binary.LittleEndian.Uint32(frame), frame[TypeSize.Uint32:]
+	if crc32.Checksum(block, snapshotTable) != sum {
+		return fmt.Errorf("snapshot: chunk %d: %w", sr.chunk, ErrSnapshotChecksum)
+	}
+	if count == 0 {
+		sr.ended = true
+		return nil
+	}
+	data, err := decompressBlock(nil, block)
+	if err != nil {
+		return fmt.Errorf("snapshot: chunk %d: %w", sr.chunk, err)
+	}
+	sr.dec = NewDecoderWithEncoding(data, sr.enc)
+	sr.left = count
+	sr.chunk++
+	return nil
+}
+
+// truncated returns the error of a chunk that couldn't be read.
+func (sr *SnapshotReader) truncated(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("snapshot: chunk %d: %w", sr.chunk, err)
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type snapshotAccount struct {
+	ID      uint64
+	Owner   string
+	Balance uint64
+}
+
+func writeTestSnapshot(t *testing.T, n int, opts SnapshotOptions) []byte {
+	buf := new(bytes.Buffer)
+	sw := NewSnapshotWriter(buf, EncodingBorsh, opts)
+	for i := 0; i < n; i++ {
+		require.NoError(t, sw.Write(snapshotAccount{ID: uint64(i), Owner: "owner", Balance: uint64(i * 10)}))
+	}
+	require.NoError(t, sw.Close())
+	return buf.Bytes()
+}
+
+func TestSnapshot(t *testing.T) {
+	for _, n := range []int{0, 1, 7, 1000} {
+		data := writeTestSnapshot(t, n, SnapshotOptions{Workers: 4, ChunkRecords: 7})
+
+		sr, err := NewSnapshotReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, EncodingBorsh, sr.Encoding())
+		for i := 0; i < n; i++ {
+			var got snapshotAccount
+			require.NoError(t, sr.Next(&got))
+			require.Equal(t, snapshotAccount{ID: uint64(i), Owner: "owner", Balance: uint64(i * 10)}, got)
+		}
+		var got snapshotAccount
+		assert.Equal(t, io.EOF, sr.Next(&got))
+	}
+}
+
+func TestSnapshot_Corrupted(t *testing.T) {
+	data := writeTestSnapshot(t, 100, SnapshotOptions{ChunkRecords: 10})
+
+	readAll := func(data []byte) error {
+		sr, err := NewSnapshotReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		for {
+			var got snapshotAccount
+			if err := sr.Next(&got); err != nil {
+				return err
+			}
+		}
+	}
+	require.Equal(t, io.EOF, readAll(data))
+
+	corrupted := append([]byte(nil), data...)
+	corrupted[len(corrupted)/2] ^= 0x01
+	err := readAll(corrupted)
+	assert.True(t, errors.Is(err, ErrSnapshotChecksum), err)
+
+	err = readAll(data[:len(data)-1])
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), err)
+
+	_, err = NewSnapshotReader(bytes.NewReader([]byte("BINC\x01\x00")))
+	assert.True(t, errors.Is(err, ErrInvalidSnapshot), err)
+}
+
+type failingSnapshotValue struct{}
+
+func (failingSnapshotValue) MarshalWithEncoder(*Encoder) error {
+	return errors.New("boom")
+}
+
+func TestSnapshot_EncodeError(t *testing.T) {
+	sw := NewSnapshotWriter(new(bytes.Buffer), EncodingBin, SnapshotOptions{ChunkRecords: 1})
+	require.NoError(t, sw.Write(failingSnapshotValue{}))
+	err := sw.Close()
+	assert.EqualError(t, err, "snapshot: boom")
+	assert.Error(t, sw.Write(failingSnapshotValue{}))
+}
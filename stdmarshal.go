@@ -0,0 +1,92 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding"
+	"reflect"
+)
+
+var binUnmarshalerStdType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+
+// WithStdBinaryMarshalers makes the encoder write the values of the types that
+// implement encoding.BinaryMarshaler and encoding.BinaryUnmarshaler, but not the
+// marshalers of this package (see hasStdBinaryMarshaler), e.g. uuid.UUID, as the
+// length-prefixed bytes returned by MarshalBinary, instead of as their underlying
+// type. Such values must be decoded by a decoder configured with
+// WithStdBinaryMarshalers; layouts and queries don't know about them.
+func (e *Encoder) WithStdBinaryMarshalers() *Encoder {
+	e.stdBinaryMarshalers = true
+	return e
+}
+
+// WithStdBinaryMarshalers makes the decoder read the values written
+// by an encoder configured with WithStdBinaryMarshalers.
+func (dec *Decoder) WithStdBinaryMarshalers() *Decoder {
+	dec.stdBinaryMarshalers = true
+	return dec
+}
+
+// hasStdBinaryMarshaler returns whether values of the provided type can be
+// encoded with their encoding.BinaryMarshaler and decoded with their
+// encoding.BinaryUnmarshaler (e.g. uuid.UUID): types that implement both,
+// but neither BinaryMarshaler nor BinaryUnmarshaler, and that have
+// no encoding of their own in this package, like time.Time.
+func hasStdBinaryMarshaler(rt reflect.Type) bool {
+	if rt.Kind() == reflect.Ptr || rt.Kind() == reflect.Interface || rt == timeType {
+		return false
+	}
+	if hasCustomUnmarshaler(rt) || rt.Implements(marshalableType) || reflect.PtrTo(rt).Implements(marshalableType) {
+		return false
+	}
+	return implementsAny(rt, binMarshalerStdType) && reflect.PtrTo(rt).Implements(binUnmarshalerStdType)
+}
+
+// encodeStdBinary writes a value of a type that has a standard library binary
+// marshaler as its length-prefixed MarshalBinary bytes, if the encoder is configured to.
+func (e *Encoder) encodeStdBinary(rv reflect.Value) (bool, error) {
+	rt := rv.Type()
+	if !e.stdBinaryMarshalers || !hasStdBinaryMarshaler(rt) {
+		return false, nil
+	}
+	m := rv
+	if !rt.Implements(binMarshalerStdType) {
+		// The method has a pointer receiver.
+		if rv.CanAddr() {
+			m = rv.Addr()
+		} else {
+			m = reflect.New(rt)
+			m.Elem().Set(rv)
+		}
+	}
+	data, err := m.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return true, err
+	}
+	return true, e.WriteBytes(data, true)
+}
+
+func (dec *Decoder) decodeStdBinary(rv reflect.Value) (bool, error) {
+	if !dec.stdBinaryMarshalers || !hasStdBinaryMarshaler(rv.Type()) {
+		return false, nil
+	}
+	data, err := dec.ReadByteSlice()
+	if err != nil {
+		return true, err
+	}
+	// The unmarshaler may keep the slice, which aliases the decoder's data.
+	data = append([]byte(nil), data...)
+	return true, rv.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(data)
+}
@@ -0,0 +1,146 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stdUUID is like uuid.UUID: its binary form is its 16 bytes.
+type stdUUID [16]byte
+
+func (u stdUUID) MarshalBinary() ([]byte, error) {
+	return u[:], nil
+}
+
+func (u *stdUUID) UnmarshalBinary(data []byte) error {
+	if len(data) != len(u) {
+		return errors.New("invalid uuid length")
+	}
+	copy(u[:], data)
+	return nil
+}
+
+// stdPoint has unexported fields, and a pointer receiver marshaler.
+type stdPoint struct {
+	x, y int8
+}
+
+func (p *stdPoint) MarshalBinary() ([]byte, error) {
+	return []byte{byte(p.x), byte(p.y)}, nil
+}
+
+func (p *stdPoint) UnmarshalBinary(data []byte) error {
+	if len(data) != 2 {
+		return errors.New("invalid point")
+	}
+	p.x, p.y = int8(data[0]), int8(data[1])
+	return nil
+}
+
+func TestStdBinaryMarshaler(t *testing.T) {
+	type S struct {
+		ID     stdUUID
+		Point  stdPoint
+		Parent *stdUUID `bin:"optional"`
+		Points []stdPoint
+		N      uint8
+	}
+	parent := stdUUID{15: 1}
+	in := S{
+		ID:     stdUUID{0: 0xaa, 15: 0xbb},
+		Point:  stdPoint{x: -1, y: 2},
+		Parent: &parent,
+		Points: []stdPoint{{1, 2}, {3, 4}},
+		N:      7,
+	}
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, enc).WithStdBinaryMarshalers().Encode(&in))
+			var out S
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).WithStdBinaryMarshalers().Decode(&out))
+			assert.Equal(t, in, out)
+		})
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).WithStdBinaryMarshalers().Encode(&in))
+	data := buf.Bytes()
+	// The ID is length-prefixed, rather than encoded as a 16-byte array.
+	assert.Equal(t, append([]byte{16}, in.ID[:]...), data[:17])
+	assert.Equal(t, []byte{2, 0xff, 2}, data[17:20])
+
+	assert.NoError(t, Precompile(S{}))
+
+	t.Run("errors", func(t *testing.T) {
+		var out S
+		assert.Error(t, NewBinDecoder([]byte{3, 1, 2, 3}).WithStdBinaryMarshalers().Decode(&out))
+	})
+}
+
+// stdPair has exported fields, and a binary marshaler of its own.
+type stdPair struct {
+	A, B uint16
+}
+
+func (p stdPair) MarshalBinary() ([]byte, error) {
+	return []byte{byte(p.A), byte(p.B)}, nil
+}
+
+func (p *stdPair) UnmarshalBinary(data []byte) error {
+	if len(data) != 2 {
+		return errors.New("invalid pair")
+	}
+	p.A, p.B = uint16(data[0]), uint16(data[1])
+	return nil
+}
+
+// stdQuad is an array with a binary marshaler.
+type stdQuad [4]byte
+
+func (q stdQuad) MarshalBinary() ([]byte, error) {
+	return q[:], nil
+}
+
+func (q *stdQuad) UnmarshalBinary(data []byte) error {
+	copy(q[:], data)
+	return nil
+}
+
+func TestStdBinaryMarshaler_Default(t *testing.T) {
+	// Without WithStdBinaryMarshalers, the encoding of these types doesn't change.
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			data, err := MarshalAppend(nil, stdQuad{1, 2, 3, 4}, enc)
+			require.NoError(t, err)
+			assert.Equal(t, []byte{1, 2, 3, 4}, data)
+
+			data, err = MarshalAppend(nil, stdPair{A: 5, B: 6}, enc)
+			require.NoError(t, err)
+			assert.Equal(t, []byte{5, 0, 6, 0}, data)
+
+			var pair stdPair
+			require.NoError(t, NewDecoderWithEncoding(data, enc).Decode(&pair))
+			assert.Equal(t, stdPair{A: 5, B: 6}, pair)
+		})
+	}
+}
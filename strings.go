@@ -0,0 +1,126 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The string helpers below write and read strings with an explicit length
+// prefix, regardless of the encoding of the encoder or decoder, for custom
+// marshalers of third-party formats. The lengths are in bytes.
+
+// WriteRawString writes the bytes of s, without a length prefix.
+func (e *Encoder) WriteRawString(s string) error {
+	return e.WriteBytes([]byte(s), false)
+}
+
+// WriteU8PrefixedString writes s after its length, as a uint8.
+func (e *Encoder) WriteU8PrefixedString(s string) error {
+	if len(s) > math.MaxUint8 {
+		return fmt.Errorf("string length %d overflows a uint8 prefix", len(s))
+	}
+	if err := e.WriteUint8(uint8(len(s))); err != nil {
+		return err
+	}
+	return e.WriteRawString(s)
+}
+
+// WriteU16PrefixedString writes s after its length, as a uint16 in the provided byte order.
+func (e *Encoder) WriteU16PrefixedString(s string, order binary.ByteOrder) error {
+	if len(s) > math.MaxUint16 {
+		return fmt.Errorf("string length %d overflows a uint16 prefix", len(s))
+	}
+	if err := e.WriteUint16(uint16(len(s)), order); err != nil {
+		return err
+	}
+	return e.WriteRawString(s)
+}
+
+// WriteU32PrefixedString writes s after its length, as a uint32 in the provided byte order.
+func (e *Encoder) WriteU32PrefixedString(s string, order binary.ByteOrder) error {
+	if uint64(len(s)) > math.MaxUint32 {
+		return fmt.Errorf("string length %d overflows a uint32 prefix", len(s))
+	}
+	if err := e.WriteUint32(uint32(len(s)), order); err != nil {
+		return err
+	}
+	return e.WriteRawString(s)
+}
+
+// WriteUvarintPrefixedString writes s after its length, as a uvarint.
+func (e *Encoder) WriteUvarintPrefixedString(s string) error {
+	if err := e.WriteUVarInt(len(s)); err != nil {
+		return err
+	}
+	return e.WriteRawString(s)
+}
+
+// ReadRawString reads a string of n bytes, without a length prefix.
+func (dec *Decoder) ReadRawString(n int) (string, error) {
+	data, err := dec.ReadNBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ReadU8PrefixedString reads a string written by WriteU8PrefixedString.
+func (dec *Decoder) ReadU8PrefixedString() (string, error) {
+	length, err := dec.ReadUint8()
+	if err != nil {
+		return "", err
+	}
+	return dec.readPrefixedString(uint64(length))
+}
+
+// ReadU16PrefixedString reads a string written by WriteU16PrefixedString.
+func (dec *Decoder) ReadU16PrefixedString(order binary.ByteOrder) (string, error) {
+	length, err := dec.ReadUint16(order)
+	if err != nil {
+		return "", err
+	}
+	return dec.readPrefixedString(uint64(length))
+}
+
+// ReadU32PrefixedString reads a string written by WriteU32PrefixedString.
+func (dec *Decoder) ReadU32PrefixedString(order binary.ByteOrder) (string, error) {
+	length, err := dec.ReadUint32(order)
+	if err != nil {
+		return "", err
+	}
+	return dec.readPrefixedString(uint64(length))
+}
+
+// ReadUvarintPrefixedString reads a string written by WriteUvarintPrefixedString.
+func (dec *Decoder) ReadUvarintPrefixedString() (string, error) {
+	length, err := dec.ReadUvarint64()
+	if err != nil {
+		return "", err
+	}
+	return dec.readPrefixedString(length)
+}
+
+// readPrefixedString reads a string of the provided length, which is checked
+// against the remaining bytes before anything is allocated.
+func (dec *Decoder) readPrefixedString(length uint64) (string, error) {
+	if length > uint64(dec.Remaining()) {
+		return "", fmt.Errorf("string length %d exceeds the remaining [%d] bytes: %w", length, dec.Remaining(), io.ErrUnexpectedEOF)
+	}
+	return dec.ReadRawString(int(length))
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefixedStrings(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewBorshEncoder(buf)
+	require.NoError(t, enc.WriteRawString("ab"))
+	require.NoError(t, enc.WriteU8PrefixedString("cd"))
+	require.NoError(t, enc.WriteU16PrefixedString("ef", BE))
+	require.NoError(t, enc.WriteU32PrefixedString("gh", LE))
+	require.NoError(t, enc.WriteUvarintPrefixedString(""))
+	assert.Equal(t, []byte{
+		'a', 'b',
+		2, 'c', 'd',
+		0, 2, 'e', 'f',
+		2, 0, 0, 0, 'g', 'h',
+		0,
+	}, buf.Bytes())
+
+	dec := NewBorshDecoder(buf.Bytes())
+	s, err := dec.ReadRawString(2)
+	require.NoError(t, err)
+	assert.Equal(t, "ab", s)
+	s, err = dec.ReadU8PrefixedString()
+	require.NoError(t, err)
+	assert.Equal(t, "cd", s)
+	s, err = dec.ReadU16PrefixedString(BE)
+	require.NoError(t, err)
+	assert.Equal(t, "ef", s)
+	s, err = dec.ReadU32PrefixedString(LE)
+	require.NoError(t, err)
+	assert.Equal(t, "gh", s)
+	s, err = dec.ReadUvarintPrefixedString()
+	require.NoError(t, err)
+	assert.Equal(t, "", s)
+	assert.Equal(t, 0, dec.Remaining())
+}
+
+func TestPrefixedStrings_Errors(t *testing.T) {
+	err := NewBinEncoder(new(bytes.Buffer)).WriteU8PrefixedString(strings.Repeat("a", 256))
+	assert.EqualError(t, err, "string length 256 overflows a uint8 prefix")
+
+	_, err = NewBinDecoder([]byte{0xff, 0xff, 0xff, 0xff, 'a'}).ReadU32PrefixedString(LE)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrStrLen is returned for strings longer than the
+// fixed length set by their `strlen=N,strict` tag.
+var ErrStrLen = errors.New("string exceeds strlen")
+
+// encodeFixedString encodes a string field tagged `strlen=N` as exactly
+// N bytes, without a length prefix: shorter strings are padded with zeros, and
+// longer ones are truncated to their first N bytes, unless the `strict` flag
+// (`strlen=N,strict`) makes them fail instead.
+func (e *Encoder) encodeFixedString(rv reflect.Value, opt *option) (bool, error) {
+	if opt == nil || opt.StrLen == 0 || rv.Kind() != reflect.String {
+		return false, nil
+	}
+	s := rv.String()
+	if len(s) > opt.StrLen {
+		if opt.StrLenStrict {
+			return true, fmt.Errorf("%d bytes, strlen=%d: %w", len(s), opt.StrLen, ErrStrLen)
+		}
+		s = s[:opt.StrLen]
+	}
+	buf := make([]byte, opt.StrLen)
+	copy(buf, s)
+	return true, e.WriteBytes(buf, false)
+}
+
+// decodeFixedString decodes a string field tagged `strlen=N`
+// from N bytes, trimmed of their trailing NULs.
+func (dec *Decoder) decodeFixedString(rv reflect.Value, opt *option) (bool, error) {
+	if opt == nil || opt.StrLen == 0 || rv.Kind() != reflect.String {
+		return false, nil
+	}
+	b, err := dec.ReadNBytes(opt.StrLen)
+	if err != nil {
+		return true, err
+	}
+	rv.SetString(string(bytes.TrimRight(b, "\x00")))
+	return true, nil
+}
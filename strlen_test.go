@@ -0,0 +1,86 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type strLenRecord struct {
+	Code  string  `bin:"strlen=4"`
+	Name  string  `bin:"strlen=6,strict"`
+	Label *string `bin:"optional strlen=3"`
+	ID    uint16
+}
+
+func TestStrLen(t *testing.T) {
+	label := "abcdef"
+	in := strLenRecord{Code: "USDCX", Name: "Alice", Label: &label, ID: 7}
+
+	data := mustMarshalBin(t, in)
+	assert.Equal(t, []byte{
+		'U', 'S', 'D', 'C',
+		'A', 'l', 'i', 'c', 'e', 0,
+		1, 0, 0, 0, 'a', 'b', 'c',
+		7, 0,
+	}, data)
+
+	want := strLenRecord{Code: "USDC", Name: "Alice", ID: 7}
+	short := "abc"
+	want.Label = &short
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&in), enc)
+
+		var out strLenRecord
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out), enc)
+		assert.Equal(t, want, out, enc)
+	}
+	require.NoError(t, Precompile(strLenRecord{}))
+
+	view, err := NewView(data, strLenRecord{})
+	require.NoError(t, err)
+	name, err := view.String("Name")
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", name)
+	id, err := view.Uint16("ID")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(7), id)
+}
+
+func TestStrLen_Errors(t *testing.T) {
+	_, err := MarshalBin(strLenRecord{Name: "Alexander"})
+	assert.True(t, errors.Is(err, ErrStrLen))
+	assert.EqualError(t, err, `error while encoding "Name" field: 9 bytes, strlen=6: string exceeds strlen`)
+
+	var out strLenRecord
+	assert.Error(t, UnmarshalBin(&out, []byte{'U', 'S'}))
+
+	type notString struct {
+		N uint32 `bin:"strlen=4"`
+	}
+	assert.EqualError(t, Precompile(notString{}), "precompile: bin.notString: field \"N\": the strlen tag only applies to strings, got uint32")
+
+	type bothLimits struct {
+		S string `bin:"strlen=4 maxlen=4"`
+	}
+	assert.EqualError(t, Precompile(bothLimits{}), "precompile: bin.bothLimits: field \"S\": the strlen and maxlen tags can't be combined")
+	assert.Equal(t, []string{"strlen=0"}, parseFieldTag(`bin:"strlen=0"`).Invalid)
+}
@@ -0,0 +1,221 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// SubDecoder returns a decoder over the next n bytes, and moves the decoder past them.
+// The returned decoder has the same encoding, options and context values as its parent,
+// adds its warnings to those of its parent, and can't read outside of those n bytes.
+//
+// A size that is larger than what remains in the parent is rejected before
+// anything is read or allocated; since the remaining bytes of a sub-decoder
+// are the size claimed for it, this holds at every nesting level, so that
+// nested length prefixes can never claim, in total, more than the outermost data.
+func (dec *Decoder) SubDecoder(n int) (*Decoder, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("sub-decoder: invalid size %d", n)
+	}
+	if n > dec.Remaining() {
+		return nil, fmt.Errorf("sub-decoder: size %d exceeds the remaining [%d] bytes: %w", n, dec.Remaining(), io.ErrUnexpectedEOF)
+	}
+	data, err := dec.ReadNBytes(n)
+	if err != nil {
+		return nil, err
+	}
+	if dec.warnings == nil {
+		dec.warnings = new([]Warning)
+	}
+	return &Decoder{
+		data:       data,
+		encoding:   dec.encoding,
+		strictTags: dec.strictTags,
+		canonical:  dec.canonical,
+		stringHeap: dec.stringHeap,
+		heap:       dec.heap,
+
+		lenientReserved:     dec.lenientReserved,
+		lenientEnums:        dec.lenientEnums,
+		pointerMode:         dec.pointerMode,
+		prefixOrder:         dec.prefixOrder,
+		textMarshalers:      dec.textMarshalers,
+		stdBinaryMarshalers: dec.stdBinaryMarshalers,
+		values:              dec.values,
+		warnings:            dec.warnings,
+		decryptionKeys:      dec.decryptionKeys,
+		keys:                dec.keys,
+		compressor:          dec.compressor,
+		trace:               dec.trace,
+		traceLog:            dec.traceLog,
+	}, nil
+}
+
+// ReadSubDecoder reads a length prefix (as encoded by the decoder's encoding)
+// and returns a sub-decoder over that many bytes; see SubDecoder.
+func (dec *Decoder) ReadSubDecoder() (*Decoder, error) {
+	n, err := dec.ReadLength()
+	if err != nil {
+		return nil, err
+	}
+	return dec.SubDecoder(n)
+}
+
+// checkLength rejects a declared number of elements of the provided
+// types (a slice element type, or a map key and value types) that
+// can't possibly fit in the remaining bytes.
+func (dec *Decoder) checkLength(l int, types ...reflect.Type) error {
+	min := 0
+	for _, rt := range types {
+		min += minEncodedSize(rt, dec.encoding)
+	}
+	if min > 0 && uint64(l)*uint64(min) > uint64(dec.Remaining()) {
+		return fmt.Errorf("length %d of %s needs at least %d bytes, remaining [%d]: %w", l, types[0], uint64(l)*uint64(min), dec.Remaining(), io.ErrUnexpectedEOF)
+	}
+	return nil
+}
+
+type minSizeKey struct {
+	typ reflect.Type
+	enc Encoding
+}
+
+var minSizeCache sync.Map
+
+// minEncodedSize returns the smallest number of bytes that a value of the provided
+// type can be encoded to by the provided encoding. It's a lower bound: types that
+// can't be measured (custom decoders, pointers, recursive types) count as zero bytes.
+func minEncodedSize(rt reflect.Type, enc Encoding) int {
+	key := minSizeKey{rt, enc}
+	if size, ok := minSizeCache.Load(key); ok {
+		return size.(int)
+	}
+	size := minSize(rt, enc, map[reflect.Type]bool{})
+	minSizeCache.Store(key, size)
+	return size
+}
+
+func lengthPrefixMinSize(enc Encoding) int {
+	if enc.IsBorsh() {
+		return TypeSize.Uint32
+	}
+	// uvarint and compact-u16 lengths take at least one byte.
+	return 1
+}
+
+func minSize(rt reflect.Type, enc Encoding, visiting map[reflect.Type]bool) int {
+	if hasCustomUnmarshaler(rt) {
+		return 0
+	}
+	if hasTextMarshaler(rt) || hasStdBinaryMarshaler(rt) {
+		// It may be encoded as its text or its binary marshaling
+		// (see WithTextMarshalers and WithStdBinaryMarshalers).
+		return lengthPrefixMinSize(enc)
+	}
+	if size, ok := fixedSize(rt, enc); ok {
+		return size
+	}
+	if rt == bigIntType {
+		// The sign byte and the length of the magnitude.
+		return 1 + lengthPrefixMinSize(enc)
+	}
+	switch rt.Kind() {
+	case reflect.String:
+		if enc.IsBin() {
+			return TypeSize.Uint64
+		}
+		return lengthPrefixMinSize(enc)
+	case reflect.Slice, reflect.Map:
+		return lengthPrefixMinSize(enc)
+	case reflect.Array:
+		return rt.Len() * minSize(rt.Elem(), enc, visiting)
+	case reflect.Struct:
+		if visiting[rt] {
+			return 0
+		}
+		visiting[rt] = true
+		defer delete(visiting, rt)
+
+		if enc.IsBorsh() && rt.NumField() > 0 {
+			firstField := rt.Field(0)
+			if isTypeBorshEnum(firstField.Type) && parseFieldTag(firstField.Tag).IsBorshEnum {
+				return 1
+			}
+		}
+		total := 0
+		sizeOfTargets := map[string]bool{}
+		plan := planOf(rt)
+		for i, structField := range plan.fields {
+			fieldTag := plan.tags[i]
+			if fieldTag.Skip || fieldTag.SkipDecode || fieldTag.BinaryExtension {
+				continue
+			}
+			if fieldTag.Reserved > 0 {
+				total += fieldTag.Reserved
+				continue
+			}
+			if structField.PkgPath != "" {
+				continue
+			}
+			if isSizeOfPath(fieldTag) {
+				sizeOfTargets[structField.Name] = true
+			} else if fieldTag.SizeOf != "" {
+				sizeOfTargets[fieldTag.SizeOf] = true
+			}
+			switch {
+			case fieldTag.COption && enc.IsBorsh():
+				total += TypeSize.Uint32
+			case fieldTag.Option && enc.IsBin():
+				total += TypeSize.Uint32
+			case fieldTag.Option:
+				total += 1
+			case sizeOfTargets[structField.Name]:
+			case fieldTag.RuneFormat == RuneFormatUTF8 && structField.Type.Kind() == reflect.Int32:
+				total += 1
+			case fieldTag.RuneFormat == RuneFormatUTF8 && isRuneSlice(structField.Type):
+				total += minSize(reflect.TypeOf(""), enc, visiting)
+			case fieldTag.Width > 0 && (structField.Type == bigIntType || isPlatformIntKind(structField.Type.Kind())):
+				total += fieldTag.Width
+			case fieldTag.LenPrefix != prefixNone && hasLengthPrefix(structField.Type):
+				total += fieldTag.LenPrefix.minSize()
+			case fieldTag.CString && structField.Type.Kind() == reflect.String:
+				total += 1
+			case fieldTag.StrLen > 0 && structField.Type.Kind() == reflect.String:
+				total += fieldTag.StrLen
+			case structField.Type == ipType && fieldTag.IPFormat != IPBytes:
+				total += fieldTag.IPFormat.size()
+			case structField.Type == hardwareAddrType && fieldTag.MACFormat != MACBytes:
+				total += fieldTag.MACFormat.size()
+			case fieldTag.TimeFormat == TimeRFC3339 && structField.Type == timeType:
+				total += minSize(reflect.TypeOf(""), enc, visiting)
+			case (fieldTag.Compress || fieldTag.Encrypt) && isCompressibleType(structField.Type):
+				total += lengthPrefixMinSize(enc)
+			case (fieldTag.VLQ || fieldTag.SQLiteVarint) && isUnsignedKind(structField.Type.Kind()):
+				total += 1
+			case (fieldTag.VLQ || fieldTag.SQLiteVarint) && structField.Type.Kind() == reflect.Array && isUnsignedKind(structField.Type.Elem().Kind()):
+				total += structField.Type.Len()
+			default:
+				total += minSize(structField.Type, enc, visiting)
+			}
+		}
+		return total
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoder_SubDecoder(t *testing.T) {
+	// outer length 6, containing: inner length 2 + 2 bytes, then 3 more bytes
+	data := []byte{6, 2, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+	dec := NewBinDecoder(data)
+
+	outer, err := dec.ReadSubDecoder()
+	require.NoError(t, err)
+	assert.Equal(t, 6, outer.Len())
+	assert.Equal(t, 1, dec.Remaining())
+
+	inner, err := outer.ReadSubDecoder()
+	require.NoError(t, err)
+	v, err := inner.ReadUint16(BE)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(0xaabb), v)
+	_, err = inner.ReadByte()
+	assert.Error(t, err, "sub-decoders can't read past their size")
+
+	// A nested claim larger than what its parent holds is rejected.
+	_, err = outer.SubDecoder(4)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	assert.EqualError(t, err, "sub-decoder: size 4 exceeds the remaining [3] bytes: unexpected EOF")
+
+	_, err = NewBinDecoder([]byte{0xff, 0xff, 0x03, 1, 2}).ReadSubDecoder()
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+}
+
+func TestDecoder_ImpossibleLengths(t *testing.T) {
+	type point struct {
+		X, Y uint64
+	}
+	// 3 points need 48 bytes, but only 20 follow the length.
+	data := append([]byte{3}, make([]byte, 20)...)
+	var points []point
+	err := NewBinDecoder(data).Decode(&points)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	assert.EqualError(t, err, "length 3 of bin.point needs at least 48 bytes, remaining [20]: unexpected EOF")
+
+	// Each string takes at least its 8-byte length prefix with the Bin encoding.
+	var strs map[string]string
+	err = NewBinDecoder(append([]byte{2}, make([]byte, 31)...)).Decode(&strs)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+
+	var nested [][]uint32
+	err = NewBorshDecoder([]byte{2, 0, 0, 0, 0, 0, 0, 0}).Decode(&nested)
+	assert.EqualError(t, err, "length 2 of []uint32 needs at least 8 bytes, remaining [4]: unexpected EOF")
+}
+
+func TestMinEncodedSize(t *testing.T) {
+	type inner struct {
+		A uint16
+		B *uint64
+		C string
+	}
+	type outer struct {
+		Opt    *uint32 `bin:"optional"`
+		Count  uint8   `bin:"sizeof=Items"`
+		Items  []inner
+		Arr    [2]inner
+		Ext    uint64 `bin:"binary_extension"`
+		Custom SafeString
+	}
+	assert.Equal(t, 4+1+0+2*(2+0+8), minEncodedSize(reflect.TypeOf(outer{}), EncodingBin))
+	assert.Equal(t, 1+1+0+2*(2+0+4), minEncodedSize(reflect.TypeOf(outer{}), EncodingBorsh))
+	assert.Equal(t, 1+1+0+2*(2+0+1), minEncodedSize(reflect.TypeOf(outer{}), EncodingCompactU16))
+}
@@ -17,13 +17,46 @@
 
 package bin
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"reflect"
+	"time"
+)
 
 type option struct {
 	is_OptionalField  bool
 	is_COptionalField bool
 	SizeOfSlice       *int
 	Order             binary.ByteOrder
+	RuneFormat        RuneFormat
+	Swap              bool
+	BitReverse        bool
+	VLQ               bool
+	GroupVarint       bool
+	SQLiteVarint      bool
+	Delta             bool
+	RLE               bool
+	Compress          bool
+	Encrypt           bool
+	Dictionary        uint32
+	Empty             EmptyMode
+	TimeFormat        TimeFormat
+	DurationUnit      time.Duration
+	MaxLen            int
+	Truncate          bool
+	StrLen            int
+	StrLenStrict      bool
+	CString           bool
+	Width             int
+	IPFormat          IPFormat
+	MACFormat         MACFormat
+	Scale             int
+	Pointers          PointerMode
+	BoolWidth         int
+	PrefixOrder       binary.ByteOrder
+	LenPrefix         lengthPrefix
+	UnionVariant      reflect.Type
+	Default           *string
 }
 
 var (
@@ -41,13 +74,46 @@ func newDefaultOption() *option {
 }
 
 func (o *option) clone() *option {
-	out := &option{
-		is_OptionalField:  o.is_OptionalField,
-		is_COptionalField: o.is_COptionalField,
-		SizeOfSlice:       o.SizeOfSlice,
-		Order:             o.Order,
+	out := *o
+	return &out
+}
+
+// optionFromTag returns the option of a struct field with the provided tag,
+// before it inherits a byte order from its parent (see fieldOrder).
+func optionFromTag(tag *fieldTag) *option {
+	return &option{
+		is_OptionalField:  tag.Option,
+		is_COptionalField: tag.COption,
+		Order:             tag.Order,
+		RuneFormat:        tag.RuneFormat,
+		Swap:              tag.Swap,
+		BitReverse:        tag.BitReverse,
+		VLQ:               tag.VLQ,
+		GroupVarint:       tag.GroupVarint,
+		SQLiteVarint:      tag.SQLiteVarint,
+		Delta:             tag.Delta,
+		RLE:               tag.RLE,
+		Compress:          tag.Compress,
+		Encrypt:           tag.Encrypt,
+		Dictionary:        tag.Dictionary,
+		Empty:             tag.Empty,
+		TimeFormat:        tag.TimeFormat,
+		DurationUnit:      tag.DurationUnit,
+		MaxLen:            tag.MaxLen,
+		Truncate:          tag.Truncate,
+		StrLen:            tag.StrLen,
+		StrLenStrict:      tag.StrLenStrict,
+		CString:           tag.CString,
+		Width:             tag.Width,
+		IPFormat:          tag.IPFormat,
+		MACFormat:         tag.MACFormat,
+		Scale:             tag.Scale,
+		Pointers:          tag.Pointers,
+		BoolWidth:         tag.BoolWidth,
+		PrefixOrder:       tag.PrefixOrder,
+		LenPrefix:         tag.LenPrefix,
+		Default:           tag.Default,
 	}
-	return out
 }
 
 func (o *option) is_Optional() bool {
@@ -19,19 +19,116 @@ package bin
 
 import (
 	"encoding/binary"
+	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type fieldTag struct {
-	SizeOf          string
-	Skip            bool
-	Order           binary.ByteOrder
-	Option          bool
-	COption         bool
+	SizeOf string
+	// SizeFunc is the name of the SizeFunc mapping the value of
+	// a sizeof field to the number of elements of its target.
+	SizeFunc string
+	// SizeExpr is the arithmetic of a `sizeof=Target/4` tag, e.g. "/4",
+	// applied to the value of the field after its SizeFunc, if any.
+	SizeExpr string
+	// ByteSizeOf is the name of the field whose encoded size
+	// in bytes is the value of this field.
+	ByteSizeOf string
+	// Union is the name of the integer field whose value selects
+	// the registered variant of a `union=Kind` field.
+	Union string
+	Skip  bool
+	// Inline flattens the fields of a struct field into
+	// the field sequence of its parent.
+	Inline bool
+	// SkipEncode and SkipDecode skip the field in one direction only:
+	// encoders don't write it, or decoders don't read it.
+	SkipEncode bool
+	SkipDecode bool
+	Order      binary.ByteOrder
+	// OrderExplicit is set when the field has a big or little tag,
+	// and OrderScope is how far that byte order applies.
+	OrderExplicit bool
+	OrderScope    orderScope
+	Option        bool
+	COption       bool
+	// Default is the value of an optional field decoded as absent, if set.
+	Default         *string
 	BinaryExtension bool
+	RuneFormat      RuneFormat
+	Swap            bool
+	BitReverse      bool
+	VLQ             bool
+	GroupVarint     bool
+	SQLiteVarint    bool
+	Delta           bool
+	RLE             bool
+	// Reserved is the number of zero bytes written in place of the field.
+	Reserved int
+	Compress bool
+	// Encrypt encrypts string and byte slice fields with the key of the encoder.
+	Encrypt bool
+	// Dictionary is the ID of the dictionary compressed fields are compressed with.
+	Dictionary uint32
+	// Empty is how empty optional collections are encoded.
+	Empty EmptyMode
+	// TimeFormat is how time.Time fields are encoded.
+	TimeFormat TimeFormat
+	// DurationUnit is the unit time.Duration fields are encoded in;
+	// zero means nanoseconds.
+	DurationUnit time.Duration
+	// MaxLen is the maximum length in bytes of a string field.
+	MaxLen int
+	// Truncate makes decoders truncate strings longer than MaxLen,
+	// with a warning, instead of failing.
+	Truncate bool
+	// StrLen is the fixed encoded size in bytes of a string field;
+	// StrLenStrict makes longer strings fail to encode instead of being truncated.
+	StrLen       int
+	StrLenStrict bool
+	// CString makes string fields NUL-terminated, of at most MaxLen bytes.
+	CString bool
+	// Width is the encoded size in bytes of big.Int, int and uint fields.
+	Width int
+	// IPFormat is how net.IP fields are encoded.
+	IPFormat IPFormat
+	// MACFormat is how net.HardwareAddr fields are encoded.
+	MACFormat MACFormat
+	// Decimal marks Decimal fields, whose mantissa is encoded with Scale decimal places.
+	Decimal bool
+	Scale   int
+	// Pointers is how optional pointers to pointers are encoded.
+	Pointers PointerMode
+	// BoolWidth is the encoded size in bytes of bool fields: 1, 2 or 4.
+	BoolWidth int
+	// PrefixOrder is the byte order of the fixed-width length prefix
+	// of the field, if it isn't that of the encoder or decoder.
+	PrefixOrder binary.ByteOrder
+	// LenPrefix is the length prefix of a `lenprefix=u8|u16|u32|u64|uvarint`
+	// tag, prefixNone for the one of the encoding.
+	LenPrefix lengthPrefix
 
+	// IsBorshEnum marks the variant index of a borsh enum, and integer
+	// enums whose values are validated when decoded.
 	IsBorshEnum bool
+
+	// Invalid holds the unknown or malformed tag tokens;
+	// they are ignored unless strict tags are enabled.
+	Invalid []string
+}
+
+// parseDefault returns the value of a `default=V` token,
+// or nil if it's empty.
+func parseDefault(s string, t *fieldTag) *string {
+	value := strings.TrimPrefix(s, "default=")
+	if value == "" {
+		t.Invalid = append(t.Invalid, s)
+		return nil
+	}
+	return &value
 }
 
 func isIn(s string, candidates ...string) bool {
@@ -49,13 +146,163 @@ func parseFieldTag(tag reflect.StructTag) *fieldTag {
 	}
 	tagStr := tag.Get("bin")
 	for _, s := range strings.Split(tagStr, " ") {
+		if s == "" {
+			continue
+		}
+		if i := strings.Index(s, ",default="); i > 0 && isIn(s[:i], "optional", "option", "coption") {
+			// The default of an optional field may be joined to its optional tag.
+			s, t.Default = s[:i], parseDefault(s[i+1:], t)
+		}
 		if strings.HasPrefix(s, "sizeof=") {
 			tmp := strings.SplitN(s, "=", 2)
 			t.SizeOf = tmp[1]
-		} else if s == "big" {
+			if i := strings.IndexAny(t.SizeOf, "*/+-"); i >= 0 {
+				t.SizeOf, t.SizeExpr = t.SizeOf[:i], t.SizeOf[i:]
+				if _, _, err := parseSizeExpr(t.SizeExpr); err != nil {
+					t.SizeOf, t.SizeExpr = "", ""
+				}
+			}
+			if t.SizeOf == "" {
+				t.Invalid = append(t.Invalid, s)
+			}
+		} else if strings.HasPrefix(s, "bytesizeof=") {
+			t.ByteSizeOf = strings.TrimPrefix(s, "bytesizeof=")
+			if t.ByteSizeOf == "" {
+				t.Invalid = append(t.Invalid, s)
+			}
+		} else if strings.HasPrefix(s, "union=") {
+			t.Union = strings.TrimPrefix(s, "union=")
+			if t.Union == "" {
+				t.Invalid = append(t.Invalid, s)
+			}
+		} else if strings.HasPrefix(s, "sizefunc=") {
+			t.SizeFunc = strings.TrimPrefix(s, "sizefunc=")
+			if t.SizeFunc == "" {
+				t.Invalid = append(t.Invalid, s)
+			}
+		} else if strings.HasPrefix(s, "reserved=") {
+			n, err := strconv.Atoi(strings.TrimPrefix(s, "reserved="))
+			if err != nil || n <= 0 {
+				t.Invalid = append(t.Invalid, s)
+			} else {
+				t.Reserved = n
+			}
+		} else if strings.HasPrefix(s, "ip=") {
+			format, ok := parseIPFormat(strings.TrimPrefix(s, "ip="))
+			if !ok {
+				t.Invalid = append(t.Invalid, s)
+			}
+			t.IPFormat = format
+		} else if strings.HasPrefix(s, "mac=") {
+			format, ok := parseMACFormat(strings.TrimPrefix(s, "mac="))
+			if !ok {
+				t.Invalid = append(t.Invalid, s)
+			}
+			t.MACFormat = format
+		} else if strings.HasPrefix(s, "time=") {
+			format, ok := parseTimeFormat(strings.TrimPrefix(s, "time="))
+			if !ok {
+				t.Invalid = append(t.Invalid, s)
+			}
+			t.TimeFormat = format
+		} else if unit, ok := durationUnits[s]; ok {
+			t.DurationUnit = unit
+		} else if s == "encrypt" {
+			t.Encrypt = true
+		} else if s == "compress" {
+			t.Compress = true
+		} else if strings.HasPrefix(s, "compress=") {
+			id, err := strconv.ParseUint(strings.TrimPrefix(s, "compress="), 10, 32)
+			if err != nil {
+				t.Invalid = append(t.Invalid, s)
+			} else {
+				t.Compress = true
+				t.Dictionary = uint32(id)
+			}
+		} else if strings.HasPrefix(s, "maxlen=") {
+			value := strings.TrimPrefix(s, "maxlen=")
+			if strings.HasSuffix(value, ",truncate") {
+				value = strings.TrimSuffix(value, ",truncate")
+				t.Truncate = true
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				t.Invalid = append(t.Invalid, s)
+				t.Truncate = false
+			} else {
+				t.MaxLen = n
+			}
+		} else if s == "cstring" {
+			t.CString = true
+		} else if strings.HasPrefix(s, "strlen=") {
+			value := strings.TrimPrefix(s, "strlen=")
+			strict := strings.HasSuffix(value, ",strict")
+			n, err := strconv.Atoi(strings.TrimSuffix(value, ",strict"))
+			if err != nil || n <= 0 {
+				t.Invalid = append(t.Invalid, s)
+			} else {
+				t.StrLen = n
+				t.StrLenStrict = strict
+			}
+		} else if strings.HasPrefix(s, "width=") {
+			n, err := strconv.Atoi(strings.TrimPrefix(s, "width="))
+			if err != nil || n <= 0 {
+				t.Invalid = append(t.Invalid, s)
+			} else {
+				t.Width = n
+			}
+		} else if strings.HasPrefix(s, "boolwidth=") {
+			n, err := strconv.Atoi(strings.TrimPrefix(s, "boolwidth="))
+			if err != nil || (n != 1 && n != 2 && n != 4) {
+				t.Invalid = append(t.Invalid, s)
+			} else {
+				t.BoolWidth = n
+			}
+		} else if s == "decimal" {
+			t.Decimal = true
+		} else if strings.HasPrefix(s, "decimal,scale=") {
+			n, err := strconv.Atoi(strings.TrimPrefix(s, "decimal,scale="))
+			if err != nil || n < 0 || n > MaxDecimalScale {
+				t.Invalid = append(t.Invalid, s)
+			} else {
+				t.Decimal = true
+				t.Scale = n
+			}
+		} else if s == "empty=omit" {
+			t.Empty = EmptyOmit
+		} else if s == "empty=zero" {
+			t.Empty = EmptyZero
+		} else if s == "pointers=flatten" {
+			t.Pointers = PointerFlatten
+		} else if s == "pointers=perlevel" {
+			t.Pointers = PointerPerLevel
+		} else if isIn(s, "big", "big,recursive") {
 			t.Order = binary.BigEndian
-		} else if s == "little" {
+			t.OrderExplicit = true
+			if s == "big,recursive" {
+				t.OrderScope = orderRecursive
+			}
+		} else if isIn(s, "little", "little,recursive") {
 			t.Order = binary.LittleEndian
+			t.OrderExplicit = true
+			if s == "little,recursive" {
+				t.OrderScope = orderRecursive
+			}
+		} else if s == "prefix=big" {
+			t.PrefixOrder = binary.BigEndian
+		} else if s == "prefix=little" {
+			t.PrefixOrder = binary.LittleEndian
+		} else if strings.HasPrefix(s, "lenprefix=") {
+			p, ok := lenPrefixes[strings.TrimPrefix(s, "lenprefix=")]
+			if !ok {
+				t.Invalid = append(t.Invalid, s)
+			} else {
+				t.LenPrefix = p
+			}
+		} else if s == "norecursive" {
+			t.OrderScope = orderIsolated
+		} else if strings.HasPrefix(s, "default=") {
+			t.Default = parseDefault(s, t)
 		} else if isIn(s, "optional", "option") {
 			t.Option = true
 		} else if isIn(s, "coption") {
@@ -64,17 +311,67 @@ func parseFieldTag(tag reflect.StructTag) *fieldTag {
 			t.BinaryExtension = true
 		} else if isIn(s, "-", "skip") {
 			t.Skip = true
+		} else if s == "inline" {
+			t.Inline = true
+		} else if s == "skip=encode" {
+			t.SkipEncode = true
+		} else if s == "skip=decode" {
+			t.SkipDecode = true
 		} else if isIn(s, "enum") {
 			t.IsBorshEnum = true
+		} else if s == "utf8" {
+			t.RuneFormat = RuneFormatUTF8
+		} else if s == "utf32" {
+			t.RuneFormat = RuneFormatUTF32
+		} else if s == "swap" {
+			t.Swap = true
+		} else if s == "bitreverse" {
+			t.BitReverse = true
+		} else if s == "vlq" {
+			t.VLQ = true
+		} else if s == "groupvarint" {
+			t.GroupVarint = true
+		} else if s == "sqlitevarint" {
+			t.SQLiteVarint = true
+		} else if s == "delta" {
+			t.Delta = true
+		} else if s == "rle" {
+			t.RLE = true
+		} else {
+			t.Invalid = append(t.Invalid, s)
 		}
 	}
 
 	// TODO: parse other borsh tags
-	if strings.TrimSpace(tag.Get("borsh_skip")) == "true" {
-		t.Skip = true
+	if v, ok := tag.Lookup("borsh_skip"); ok {
+		switch strings.TrimSpace(v) {
+		case "true":
+			t.Skip = true
+		case "false":
+		default:
+			t.Invalid = append(t.Invalid, "borsh_skip:"+v)
+		}
 	}
-	if strings.TrimSpace(tag.Get("borsh_enum")) == "true" {
-		t.IsBorshEnum = true
+	if v, ok := tag.Lookup("borsh_enum"); ok {
+		switch strings.TrimSpace(v) {
+		case "true":
+			t.IsBorshEnum = true
+		case "false":
+		default:
+			t.Invalid = append(t.Invalid, "borsh_enum:"+v)
+		}
 	}
 	return t
 }
+
+// checkStructTags returns an error listing the first field of the
+// provided struct type that has unknown or malformed tag tokens.
+func checkStructTags(rt reflect.Type) error {
+	for i := 0; i < rt.NumField(); i++ {
+		structField := rt.Field(i)
+		if invalid := parseFieldTag(structField.Tag).Invalid; len(invalid) > 0 {
+			return fmt.Errorf("invalid tag on field %s.%s: %q", rt, structField.Name, invalid)
+		}
+	}
+	return nil
+}
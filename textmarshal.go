@@ -0,0 +1,97 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding"
+	"reflect"
+)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// WithTextMarshalers makes the encoder write the values of the types that
+// implement encoding.TextMarshaler and encoding.TextUnmarshaler, but have no
+// binary encoding (see hasTextMarshaler), as their text in a string, e.g. enum-like
+// types of other libraries that only marshal to text. Such values must be decoded
+// by a decoder configured with WithTextMarshalers; layouts and queries don't know about them.
+func (e *Encoder) WithTextMarshalers() *Encoder {
+	e.textMarshalers = true
+	return e
+}
+
+// WithTextMarshalers makes the decoder read the values written
+// by an encoder configured with WithTextMarshalers.
+func (dec *Decoder) WithTextMarshalers() *Decoder {
+	dec.textMarshalers = true
+	return dec
+}
+
+// hasTextMarshaler returns whether values of the provided type can be encoded
+// as their text: types that implement encoding.TextMarshaler and
+// encoding.TextUnmarshaler, but neither the marshalers of this package
+// nor the binary ones of the standard library.
+func hasTextMarshaler(rt reflect.Type) bool {
+	if rt.Kind() == reflect.Ptr || rt.Kind() == reflect.Interface || rt == timeType {
+		return false
+	}
+	if hasCustomUnmarshaler(rt) || rt.Implements(marshalableType) || reflect.PtrTo(rt).Implements(marshalableType) || hasStdBinaryMarshaler(rt) {
+		return false
+	}
+	return implementsAny(rt, textMarshalerType) && reflect.PtrTo(rt).Implements(textUnmarshalerType)
+}
+
+// encodeText writes a value of a type that has a text marshaler as a string
+// holding its MarshalText bytes, if the encoder is configured to.
+func (e *Encoder) encodeText(rv reflect.Value) (bool, error) {
+	rt := rv.Type()
+	if !e.textMarshalers || !hasTextMarshaler(rt) {
+		return false, nil
+	}
+	m := rv
+	if !rt.Implements(textMarshalerType) {
+		// The method has a pointer receiver.
+		if rv.CanAddr() {
+			m = rv.Addr()
+		} else {
+			m = reflect.New(rt)
+			m.Elem().Set(rv)
+		}
+	}
+	text, err := m.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return true, err
+	}
+	if e.encoding.IsBin() {
+		return true, e.WriteRustString(string(text))
+	}
+	return true, e.WriteString(string(text))
+}
+
+func (dec *Decoder) decodeText(rv reflect.Value) (bool, error) {
+	if !dec.textMarshalers || !hasTextMarshaler(rv.Type()) {
+		return false, nil
+	}
+	var text string
+	var err error
+	if dec.encoding.IsBin() {
+		text, err = dec.ReadRustString()
+	} else {
+		text, err = dec.ReadString()
+	}
+	if err != nil {
+		return true, err
+	}
+	return true, rv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+}
@@ -0,0 +1,88 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// textLevel only marshals to text, like the enums of some libraries.
+type textLevel uint8
+
+var textLevelNames = []string{"debug", "info", "warn"}
+
+func (l textLevel) MarshalText() ([]byte, error) {
+	if int(l) >= len(textLevelNames) {
+		return nil, fmt.Errorf("unknown level %d", l)
+	}
+	return []byte(textLevelNames[l]), nil
+}
+
+func (l *textLevel) UnmarshalText(text []byte) error {
+	for i, name := range textLevelNames {
+		if name == string(text) {
+			*l = textLevel(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown level %q", text)
+}
+
+type textRecord struct {
+	Level  textLevel
+	Levels []textLevel
+	Ptr    *textLevel `bin:"optional"`
+}
+
+func TestTextMarshalers(t *testing.T) {
+	warn := textLevel(2)
+	in := textRecord{Level: 1, Levels: []textLevel{0, 2}, Ptr: &warn}
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, enc).WithTextMarshalers().Encode(&in))
+			assert.Contains(t, buf.String(), "info")
+
+			var got textRecord
+			require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).WithTextMarshalers().Decode(&got))
+			assert.Equal(t, in, got)
+		})
+	}
+}
+
+func TestTextMarshalers_Wire(t *testing.T) {
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBorshEncoder(buf).WithTextMarshalers().Encode(textLevel(1)))
+	assert.Equal(t, []byte{4, 0, 0, 0, 'i', 'n', 'f', 'o'}, buf.Bytes())
+
+	// Without the mode, the underlying type is encoded.
+	buf.Reset()
+	require.NoError(t, NewBorshEncoder(buf).Encode(textLevel(1)))
+	assert.Equal(t, []byte{1}, buf.Bytes())
+
+	buf.Reset()
+	err := NewBorshEncoder(buf).WithTextMarshalers().Encode(textLevel(5))
+	require.Error(t, err)
+
+	var got textLevel
+	err = NewBorshDecoder([]byte{5, 0, 0, 0, 'e', 'r', 'r', 'o', 'r'}).WithTextMarshalers().Decode(&got)
+	assert.EqualError(t, err, `unknown level "error"`)
+}
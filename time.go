@@ -0,0 +1,231 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// A TimeFormat is how time.Time values are encoded, selected with the
+// `time=` tag. All of them but TimeRFC3339 are encoded as an int64, in the
+// byte order of the field; the zero time.Time is encoded as 0, and 0 is
+// decoded as the zero time.Time. Decoded times are in UTC.
+type TimeFormat int
+
+const (
+	// TimeUnixNano encodes nanoseconds since the Unix epoch (`time=unixnano`),
+	// for the years 1678 to 2262; it's the default.
+	TimeUnixNano TimeFormat = iota
+	// TimeUnix encodes seconds since the Unix epoch (`time=unix`).
+	TimeUnix
+	// TimeUnixMilli encodes milliseconds since the Unix epoch (`time=unixmilli`).
+	TimeUnixMilli
+	// TimeRFC3339 encodes an RFC 3339 string with nanoseconds, keeping
+	// the time zone offset (`time=rfc3339`).
+	TimeRFC3339
+)
+
+func parseTimeFormat(s string) (TimeFormat, bool) {
+	switch s {
+	case "unixnano":
+		return TimeUnixNano, true
+	case "unix":
+		return TimeUnix, true
+	case "unixmilli":
+		return TimeUnixMilli, true
+	case "rfc3339":
+		return TimeRFC3339, true
+	default:
+		return TimeUnixNano, false
+	}
+}
+
+func (f TimeFormat) String() string {
+	switch f {
+	case TimeUnixNano:
+		return "unixnano"
+	case TimeUnix:
+		return "unix"
+	case TimeUnixMilli:
+		return "unixmilli"
+	case TimeRFC3339:
+		return "rfc3339"
+	default:
+		return fmt.Sprintf("TimeFormat(%d)", int(f))
+	}
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// durationUnits are the tags selecting the unit of time.Duration fields.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// timeOrder returns the byte order of integer times.
+func (e *Encoder) timeOrder(opt *option) binary.ByteOrder {
+	if e.IsBorsh() {
+		return LE
+	}
+	return opt.Order
+}
+
+func (dec *Decoder) timeOrder(opt *option) binary.ByteOrder {
+	if dec.IsBorsh() {
+		return LE
+	}
+	return opt.Order
+}
+
+// The range of the times whose UnixNano is defined.
+var (
+	minUnixNanoTime = time.Unix(0, math.MinInt64)
+	maxUnixNanoTime = time.Unix(0, math.MaxInt64)
+)
+
+func (e *Encoder) encodeTime(rv reflect.Value, opt *option) (bool, error) {
+	if rv.Type() != timeType {
+		return false, nil
+	}
+	t := rv.Interface().(time.Time)
+	if opt.TimeFormat == TimeRFC3339 {
+		s := t.Format(time.RFC3339Nano)
+		if e.IsBin() {
+			return true, e.WriteRustString(s)
+		}
+		return true, e.WriteString(s)
+	}
+
+	var v int64
+	switch {
+	case t.IsZero():
+	case opt.TimeFormat == TimeUnix:
+		v = t.Unix()
+	case opt.TimeFormat == TimeUnixMilli:
+		v = t.Unix()*1e3 + int64(t.Nanosecond())/1e6
+	default:
+		if t.Before(minUnixNanoTime) || t.After(maxUnixNanoTime) {
+			return true, fmt.Errorf("time %s out of the range of unixnano times", t)
+		}
+		v = t.UnixNano()
+	}
+	return true, e.WriteInt64(v, e.timeOrder(opt))
+}
+
+func (dec *Decoder) decodeTime(rv reflect.Value, opt *option) (bool, error) {
+	if rv.Type() != timeType {
+		return false, nil
+	}
+	if opt.TimeFormat == TimeRFC3339 {
+		var s string
+		var err error
+		if dec.IsBin() {
+			s, err = dec.ReadRustString()
+		} else {
+			s, err = dec.ReadString()
+		}
+		if err != nil {
+			return true, err
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return true, err
+		}
+		rv.Set(reflect.ValueOf(t))
+		return true, nil
+	}
+
+	v, err := dec.ReadInt64(dec.timeOrder(opt))
+	if err != nil {
+		return true, err
+	}
+	var t time.Time
+	switch {
+	case v == 0:
+	case opt.TimeFormat == TimeUnix:
+		t = time.Unix(v, 0).UTC()
+	case opt.TimeFormat == TimeUnixMilli:
+		t = time.Unix(v/1e3, v%1e3*1e6).UTC()
+	default:
+		t = time.Unix(0, v).UTC()
+	}
+	rv.Set(reflect.ValueOf(t))
+	return true, nil
+}
+
+// encodeDuration writes a time.Duration field tagged with a unit (`ns`, `us`,
+// `ms` or `s`) as a number of that unit, on 8 bytes or on those of its
+// `width=N` tag; durations that aren't a whole number of the unit fail to
+// encode. Untagged durations are written as int64 nanoseconds, like other int64s.
+func (e *Encoder) encodeDuration(rv reflect.Value, opt *option) (bool, error) {
+	if !isScaledDuration(rv, opt) {
+		return false, nil
+	}
+	unit := int64(durationUnit(opt))
+	if rv.Int()%unit != 0 {
+		return true, fmt.Errorf("duration %s is not a whole number of %s", time.Duration(rv.Int()), time.Duration(unit))
+	}
+	v := rv.Int() / unit
+	if opt.Width > 0 {
+		return true, e.WriteIntN(v, opt.Width, e.timeOrder(opt))
+	}
+	return true, e.WriteInt64(v, e.timeOrder(opt))
+}
+
+func (dec *Decoder) decodeDuration(rv reflect.Value, opt *option) (bool, error) {
+	if !isScaledDuration(rv, opt) {
+		return false, nil
+	}
+	var v int64
+	var err error
+	if opt.Width > 0 {
+		v, err = dec.ReadIntN(opt.Width, dec.timeOrder(opt))
+	} else {
+		v, err = dec.ReadInt64(dec.timeOrder(opt))
+	}
+	if err != nil {
+		return true, err
+	}
+	unit := int64(durationUnit(opt))
+	if v > math.MaxInt64/unit || v < math.MinInt64/unit {
+		return true, fmt.Errorf("duration of %d times %s overflows time.Duration", v, opt.DurationUnit)
+	}
+	rv.SetInt(v * unit)
+	return true, nil
+}
+
+// isScaledDuration reports whether rv is a time.Duration
+// with a unit tag other than `ns`, or a width tag.
+func isScaledDuration(rv reflect.Value, opt *option) bool {
+	return rv.Type() == durationType && (opt.DurationUnit > time.Nanosecond || opt.Width > 0)
+}
+
+// durationUnit returns the unit of the durations with the provided options.
+func durationUnit(opt *option) time.Duration {
+	if opt.DurationUnit <= time.Nanosecond {
+		return time.Nanosecond
+	}
+	return opt.DurationUnit
+}
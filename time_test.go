@@ -0,0 +1,177 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type timedEvent struct {
+	At       time.Time
+	Seconds  time.Time  `bin:"time=unix"`
+	Millis   time.Time  `bin:"time=unixmilli big"`
+	Text     time.Time  `bin:"time=rfc3339"`
+	Deadline *time.Time `bin:"optional time=unix"`
+	Zero     time.Time
+	History  []time.Time
+}
+
+func TestTime(t *testing.T) {
+	at := time.Date(2024, 5, 17, 10, 30, 15, 123456789, time.UTC)
+	zone := time.FixedZone("", 2*3600)
+	deadline := at.Add(time.Hour).Truncate(time.Second)
+	in := timedEvent{
+		At:       at,
+		Seconds:  at.Truncate(time.Second),
+		Millis:   at.Truncate(time.Millisecond),
+		Text:     at.In(zone),
+		Deadline: &deadline,
+		History:  []time.Time{at, at.Add(time.Nanosecond)},
+	}
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&in), enc)
+
+		var out timedEvent
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out), enc)
+		assert.Equal(t, in.At, out.At, enc)
+		assert.Equal(t, in.Seconds, out.Seconds, enc)
+		assert.Equal(t, in.Millis, out.Millis, enc)
+		assert.True(t, in.Text.Equal(out.Text), enc)
+		_, offset := out.Text.Zone()
+		assert.Equal(t, 2*3600, offset, enc)
+		assert.Equal(t, deadline, *out.Deadline, enc)
+		assert.True(t, out.Zero.IsZero(), enc)
+		assert.Equal(t, in.History, out.History, enc)
+	}
+
+	data, err := MarshalBin(struct {
+		Seconds time.Time `bin:"time=unix big"`
+	}{time.Unix(0x0102, 0)})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0x01, 0x02}, data)
+
+	res, err := Query(mustMarshalBin(t, &in), timedEvent{}, "Zero")
+	require.NoError(t, err)
+	assert.True(t, res.Value.(time.Time).IsZero())
+
+	_, err = MarshalBin(time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.EqualError(t, err, "time 3000-01-01 00:00:00 +0000 UTC out of the range of unixnano times")
+
+	type notTime struct {
+		A int64 `bin:"time=unix"`
+	}
+	assert.EqualError(t, Precompile(notTime{}), `precompile: bin.notTime: field "A": the time tag only applies to time.Time, got int64`)
+}
+
+func mustMarshalBin(t *testing.T, v interface{}) []byte {
+	data, err := MarshalBin(v)
+	require.NoError(t, err)
+	return data
+}
+
+func TestDuration(t *testing.T) {
+	type timeouts struct {
+		Default time.Duration
+		Micros  time.Duration  `bin:"us"`
+		Millis  time.Duration  `bin:"ms big"`
+		Seconds *time.Duration `bin:"optional s"`
+		Retries []time.Duration
+	}
+	seconds := 90 * time.Second
+	in := timeouts{
+		Default: 1500 * time.Nanosecond,
+		Micros:  1500 * time.Microsecond,
+		Millis:  1500 * time.Millisecond,
+		Seconds: &seconds,
+		Retries: []time.Duration{time.Second, time.Minute},
+	}
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&in), enc)
+		var out timeouts
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out), enc)
+		assert.Equal(t, in, out, enc)
+	}
+
+	data, err := MarshalBin(struct {
+		Millis time.Duration `bin:"ms big"`
+	}{1234 * time.Millisecond})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0x04, 0xd2}, data)
+
+	// Durations aren't truncated to their unit.
+	_, err = MarshalBin(struct {
+		Millis time.Duration `bin:"ms big"`
+	}{1234567 * time.Microsecond})
+	assert.EqualError(t, err, `error while encoding "Millis" field: duration 1.234567s is not a whole number of 1ms`)
+	_, err = MarshalBin(struct {
+		Seconds time.Duration `bin:"s width=4"`
+	}{-1500 * time.Millisecond})
+	assert.EqualError(t, err, `error while encoding "Seconds" field: duration -1.5s is not a whole number of 1s`)
+
+	var out struct {
+		Seconds time.Duration `bin:"s"`
+	}
+	err = UnmarshalBin(&out, []byte{0, 0, 0, 0, 0, 0, 0, 0x7f})
+	assert.EqualError(t, err, `error while decoding "Seconds" field: duration of 9151314442816847872 times 1s overflows time.Duration`)
+
+	type notDuration struct {
+		A int64 `bin:"ms"`
+	}
+	assert.EqualError(t, Precompile(notDuration{}), `precompile: bin.notDuration: field "A": duration unit tags only apply to time.Duration, got int64`)
+}
+
+func TestDuration_Width(t *testing.T) {
+	type probe struct {
+		Latency time.Duration  `bin:"us width=4"`
+		Timeout time.Duration  `bin:"ms width=2 big"`
+		Budget  time.Duration  `bin:"width=6"`
+		TTL     *time.Duration `bin:"optional s width=4"`
+	}
+	ttl := time.Hour
+	in := probe{Latency: 1500 * time.Microsecond, Timeout: 30 * time.Second, Budget: -time.Second, TTL: &ttl}
+
+	data := mustMarshalBin(t, in)
+	assert.Equal(t, []byte{
+		0xdc, 0x05, 0, 0,
+		0x75, 0x30,
+		0x00, 0x36, 0x65, 0xc4, 0xff, 0xff,
+		1, 0, 0, 0, 0x10, 0x0e, 0, 0,
+	}, data)
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&in), enc)
+		var out probe
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out), enc)
+		assert.Equal(t, in, out, enc)
+	}
+	require.NoError(t, Precompile(probe{}))
+	require.NoError(t, Conforms(data, probe{}))
+
+	_, err := MarshalBin(probe{Timeout: time.Hour})
+	assert.EqualError(t, err, `error while encoding "Timeout" field: value 3600000 overflows width=2`)
+
+	type tooWide struct {
+		D time.Duration `bin:"ms width=9"`
+	}
+	assert.Error(t, Precompile(tooWide{}))
+}
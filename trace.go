@@ -0,0 +1,74 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+// Tracing logs each step of encoding and decoding at the debug level. It's
+// enabled for all encoders and decoders by the package tracer of
+// streamingfast/logging, or for a single one with WithTracing, or for a
+// single call with EncodeTraced and DecodeTraced. The tracing state of an
+// encoder or decoder is its own, so that concurrent encoders and decoders
+// don't race on it.
+
+// WithTracing makes the encoder trace all of its encoding.
+func (e *Encoder) WithTracing() *Encoder {
+	e.trace = true
+	return e
+}
+
+// WithTracing makes the decoder trace all of its decoding.
+func (dec *Decoder) WithTracing() *Decoder {
+	dec.trace = true
+	return dec
+}
+
+// EncodeTraced encodes v like Encode, tracing the encoding.
+func (e *Encoder) EncodeTraced(v interface{}) error {
+	defer func(prev bool) { e.trace = prev }(e.trace)
+	e.trace = true
+	return e.Encode(v)
+}
+
+// DecodeTraced decodes into v like Decode, tracing the decoding.
+func (dec *Decoder) DecodeTraced(v interface{}) error {
+	defer func(prev bool) { dec.trace = prev }(dec.trace)
+	dec.trace = true
+	return dec.Decode(v)
+}
+
+func (e *Encoder) tracing() bool {
+	return e.trace || traceEnabled
+}
+
+// tlog returns the logger of the encoder, named after the
+// arrays, slices and structs it's encoding.
+func (e *Encoder) tlog() *logger {
+	if e.traceLog != nil {
+		return e.traceLog
+	}
+	return zlog
+}
+
+func (dec *Decoder) tracing() bool {
+	return dec.trace || traceEnabled
+}
+
+// tlog returns the logger of the decoder, named after the
+// arrays, slices and structs it's decoding.
+func (dec *Decoder) tlog() *logger {
+	if dec.traceLog != nil {
+		return dec.traceLog
+	}
+	return zlog
+}
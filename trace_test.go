@@ -0,0 +1,97 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !tinygo && !nozap
+// +build !tinygo,!nozap
+
+package bin
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type tracedStruct struct {
+	A uint16
+	B []uint32
+}
+
+func TestDecodeTraced(t *testing.T) {
+	if traceEnabled {
+		t.Skip("tracing is enabled for the whole package")
+	}
+	data, err := MarshalBin(&tracedStruct{A: 1, B: []uint32{2, 3}})
+	require.NoError(t, err)
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	dec := NewBinDecoder(data).WithTraceLogger(zap.New(core))
+
+	var out tracedStruct
+	require.NoError(t, dec.Decode(&out))
+	assert.Equal(t, 0, logs.Len())
+
+	dec.Reset(data)
+	require.NoError(t, dec.DecodeTraced(&out))
+	assert.Equal(t, tracedStruct{A: 1, B: []uint32{2, 3}}, out)
+	assert.NotZero(t, logs.Len())
+	assert.False(t, dec.trace)
+}
+
+func TestEncodeTraced(t *testing.T) {
+	if traceEnabled {
+		t.Skip("tracing is enabled for the whole package")
+	}
+	core, logs := observer.New(zapcore.DebugLevel)
+	buf := new(bytes.Buffer)
+	log := zap.New(core)
+	enc := NewBinEncoder(buf).WithTraceLogger(log)
+
+	require.NoError(t, enc.EncodeTraced(&tracedStruct{A: 1, B: []uint32{2}}))
+	assert.NotZero(t, logs.Len())
+	named := 0
+	for _, entry := range logs.All() {
+		if entry.LoggerName == "slice" {
+			named++
+		}
+	}
+	assert.NotZero(t, named)
+	// The logger named after the slice doesn't outlive its encoding.
+	assert.Same(t, log, enc.traceLog)
+	assert.False(t, enc.trace)
+}
+
+func TestTracing_Concurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			in := tracedStruct{A: uint16(i), B: []uint32{uint32(i)}}
+			buf := new(bytes.Buffer)
+			assert.NoError(t, NewBinEncoder(buf).WithTracing().Encode(&in))
+
+			var out tracedStruct
+			assert.NoError(t, NewBinDecoder(buf.Bytes()).WithTracing().Decode(&out))
+			assert.Equal(t, in, out)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,122 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	// ErrMessageTooLarge is returned by TypedDecoder.Decode for messages
+	// larger than TypedDecoderOptions.MaxSize.
+	ErrMessageTooLarge = errors.New("message too large")
+	// ErrTrailingBytes is returned by TypedDecoder.Decode for messages with
+	// bytes left after the value, when TypedDecoderOptions.Exact is set.
+	ErrTrailingBytes = errors.New("trailing bytes after the value")
+)
+
+// TypedDecoderOptions configures a TypedDecoder.
+type TypedDecoderOptions struct {
+	// Encoding is the encoding of the messages.
+	Encoding Encoding
+	// MaxSize is the size in bytes of the largest message accepted;
+	// zero means no limit.
+	MaxSize int
+	// Exact rejects the messages with bytes left after the value.
+	Exact bool
+}
+
+// A TypedDecoder decodes many messages of the same type, e.g. the
+// messages of a stream: the plan of the type is compiled once, when the
+// decoder is created, and each Decode reuses the same Decoder and options
+// instead of allocating them and looking up the plan of the type.
+//
+//	orders, err := bin.NewTypedDecoder(Order{}, bin.TypedDecoderOptions{Encoding: bin.EncodingBorsh, MaxSize: 512})
+//	...
+//	var order Order
+//	for msg := range messages {
+//		err := orders.Decode(msg, &order)
+//		...
+//	}
+//
+// A TypedDecoder isn't safe for concurrent use; use one per goroutine.
+// It keeps the plan of its type across InvalidatePlanCache and ResetCaches.
+type TypedDecoder struct {
+	opts TypedDecoderOptions
+	rt   reflect.Type
+	dec  *Decoder
+	opt  *option
+}
+
+// NewTypedDecoder returns a decoder of messages of the type of v; it fails
+// if that type can't be decoded, with the error of Precompile.
+func NewTypedDecoder(v interface{}, opts TypedDecoderOptions) (*TypedDecoder, error) {
+	if !isValidEncoding(opts.Encoding) {
+		return nil, fmt.Errorf("provided encoding is not valid: %s", opts.Encoding)
+	}
+	if v == nil {
+		return nil, errors.New("typed decoder: nil value")
+	}
+	rt := reflect.TypeOf(v)
+	if err := Precompile(reflect.New(rt).Interface()); err != nil {
+		return nil, err
+	}
+	dec := NewDecoderWithEncoding(nil, opts.Encoding)
+	pinned := rt
+	for pinned.Kind() == reflect.Ptr {
+		pinned = pinned.Elem()
+	}
+	if pinned.Kind() == reflect.Struct {
+		dec.pinnedType, dec.pinnedPlan = pinned, planOf(pinned)
+	}
+	return &TypedDecoder{opts: opts, rt: rt, dec: dec, opt: newDefaultOption()}, nil
+}
+
+// Decode decodes a message into v, which must be a non-nil pointer to a
+// value of the type of the decoder. The value is reset first; it's left
+// zero when decoding fails.
+func (d *TypedDecoder) Decode(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Type().Elem() != d.rt {
+		return fmt.Errorf("typed decoder: %T is not a non-nil pointer to %s", v, d.rt)
+	}
+	rv.Elem().Set(reflect.Zero(d.rt))
+	if d.opts.MaxSize > 0 && len(data) > d.opts.MaxSize {
+		return fmt.Errorf("%d bytes, max %d: %w", len(data), d.opts.MaxSize, ErrMessageTooLarge)
+	}
+	d.dec.Reset(data)
+	// The decoders can change the options they are given.
+	*d.opt = option{Order: defaultByteOrder}
+
+	var err error
+	switch d.dec.encoding {
+	case EncodingBin:
+		err = d.dec.decodeBin(rv, d.opt)
+	case EncodingBorsh:
+		err = d.dec.decodeBorsh(rv, d.opt)
+	case EncodingCompactU16:
+		err = d.dec.decodeCompactU16(rv, d.opt)
+	}
+	if err == nil && d.opts.Exact && d.dec.HasRemaining() {
+		err = fmt.Errorf("%d bytes left: %w", d.dec.Remaining(), ErrTrailingBytes)
+	}
+	if err != nil {
+		rv.Elem().Set(reflect.Zero(d.rt))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type typedOrder struct {
+	ID     uint64
+	Side   uint8
+	Price  int64
+	Amount uint32
+	Memo   *string `bin:"optional"`
+}
+
+func TestTypedDecoder(t *testing.T) {
+	memo := "fill or kill"
+	in := []typedOrder{{ID: 1, Side: 1, Price: -7, Amount: 3}, {ID: 2, Price: 8, Memo: &memo}}
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		orders, err := NewTypedDecoder(typedOrder{}, TypedDecoderOptions{Encoding: enc})
+		require.NoError(t, err)
+		var got typedOrder
+		for _, order := range in {
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&order))
+			require.NoError(t, orders.Decode(buf.Bytes(), &got), enc)
+			assert.Equal(t, order, got, enc)
+		}
+		assert.Error(t, orders.Decode([]byte{1, 2}, &got), enc)
+		assert.Equal(t, typedOrder{}, got, enc)
+	}
+
+	ptrs, err := NewTypedDecoder(&typedOrder{}, TypedDecoderOptions{})
+	require.NoError(t, err)
+	var got *typedOrder
+	require.NoError(t, ptrs.Decode(mustMarshalBin(t, &in[1]), &got))
+	assert.Equal(t, &in[1], got)
+
+	ints, err := NewTypedDecoder(uint16(0), TypedDecoderOptions{Encoding: EncodingBorsh})
+	require.NoError(t, err)
+	var n uint16
+	require.NoError(t, ints.Decode([]byte{0x34, 0x12}, &n))
+	assert.Equal(t, uint16(0x1234), n)
+	assert.EqualError(t, ints.Decode([]byte{0x34, 0x12}, n), "typed decoder: uint16 is not a non-nil pointer to uint16")
+	assert.EqualError(t, ints.Decode([]byte{0x34, 0x12}, new(uint32)), "typed decoder: *uint32 is not a non-nil pointer to uint16")
+}
+
+func TestTypedDecoder_Bounds(t *testing.T) {
+	orders, err := NewTypedDecoder(typedOrder{}, TypedDecoderOptions{MaxSize: 32, Exact: true})
+	require.NoError(t, err)
+	data := mustMarshalBin(t, &typedOrder{ID: 1})
+
+	var got typedOrder
+	err = orders.Decode(append(data, 0), &got)
+	assert.True(t, errors.Is(err, ErrTrailingBytes))
+	err = orders.Decode(append(data, make([]byte, 32)...), &got)
+	assert.True(t, errors.Is(err, ErrMessageTooLarge))
+	require.NoError(t, orders.Decode(data, &got))
+	assert.Equal(t, typedOrder{ID: 1}, got)
+
+	type unsized struct {
+		N int
+	}
+	_, err = NewTypedDecoder(unsized{}, TypedDecoderOptions{})
+	assert.Error(t, err)
+	_, err = NewTypedDecoder(typedOrder{}, TypedDecoderOptions{Encoding: Encoding(7)})
+	assert.Error(t, err)
+	_, err = NewTypedDecoder(nil, TypedDecoderOptions{})
+	assert.Error(t, err)
+}
+
+func TestTypedDecoder_Allocs(t *testing.T) {
+	type fixed struct {
+		A, B uint64
+		C    int32
+	}
+	data := mustMarshalBin(t, &fixed{A: 1, B: 2, C: 3})
+	orders, err := NewTypedDecoder(fixed{}, TypedDecoderOptions{})
+	require.NoError(t, err)
+
+	var v fixed
+	typed := testing.AllocsPerRun(100, func() {
+		if err := orders.Decode(data, &v); err != nil {
+			t.Fatal(err)
+		}
+	})
+	plain := testing.AllocsPerRun(100, func() {
+		if err := UnmarshalBin(&v, data); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Less(t, typed, plain)
+}
+
+func BenchmarkTypedDecoder(b *testing.B) {
+	data, err := MarshalBin(&typedOrder{ID: 1, Side: 1, Price: 100, Amount: 5})
+	require.NoError(b, err)
+	orders, err := NewTypedDecoder(typedOrder{}, TypedDecoderOptions{})
+	require.NoError(b, err)
+	var order typedOrder
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := orders.Decode(data, &order); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
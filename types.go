@@ -61,7 +61,8 @@ func (b *Bool) UnmarshalJSON(data []byte) error {
 }
 
 func (b *Bool) UnmarshalWithDecoder(decoder *Decoder) error {
-	value, err := decoder.ReadBool()
+	width, order := boolOptions(decoder.encoding, decoder.currentFieldOpt)
+	value, err := decoder.ReadBoolWidth(width, order)
 	if err != nil {
 		return err
 	}
@@ -71,7 +72,8 @@ func (b *Bool) UnmarshalWithDecoder(decoder *Decoder) error {
 }
 
 func (b Bool) MarshalWithEncoder(encoder *Encoder) error {
-	return encoder.WriteBool(bool(b))
+	width, order := boolOptions(encoder.encoding, encoder.currentFieldOpt)
+	return encoder.WriteBoolWidth(bool(b), width, order)
 }
 
 type HexBytes []byte
@@ -18,11 +18,14 @@
 package bin
 
 import (
+	"database/sql/driver"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
+	"math/bits"
 	"strings"
 )
 
@@ -33,6 +36,39 @@ type Uint128 struct {
 	Endianness binary.ByteOrder
 }
 
+// Uint128JSON is a Uint128 that MarshalJSON writes in the provided
+// Format; it's encoded and unmarshaled like the Uint128 it embeds.
+//
+//	out, err := json.Marshal(bin.Uint128JSON{Uint128: v, Format: bin.JSONHexString})
+type Uint128JSON struct {
+	Uint128
+	Format JSONFormat
+}
+
+// MarshalJSON writes the value in its Format.
+func (i Uint128JSON) MarshalJSON() ([]byte, error) {
+	switch i.Format {
+	case JSONHexString:
+		return []byte(`"` + i.HexString() + `"`), nil
+	case JSONNumber:
+		return []byte(i.String()), nil
+	}
+	return i.Uint128.MarshalJSON()
+}
+
+// JSONFormat is how a Uint128JSON is marshaled to JSON.
+type JSONFormat int
+
+const (
+	// JSONDecimalString writes a decimal string, e.g. "255".
+	JSONDecimalString JSONFormat = iota
+	// JSONHexString writes a 0x-prefixed string of 32 hexadecimal digits.
+	JSONHexString
+	// JSONNumber writes a number, e.g. 255; JSON parsers that decode
+	// numbers to float64 (like JavaScript's) round large values.
+	JSONNumber
+)
+
 func NewUint128BigEndian() *Uint128 {
 	return &Uint128{
 		Endianness: binary.BigEndian,
@@ -103,64 +139,63 @@ func ReverseBytes(s []byte) {
 	}
 }
 
+// UnmarshalJSON accepts a decimal string, a hexadecimal one prefixed
+// with 0x, or a number.
 func (i *Uint128) UnmarshalJSON(data []byte) error {
 	if string(data) == "null" {
 		return nil
 	}
 
+	if !strings.HasPrefix(string(data), `"`) {
+		return i.setString(string(data), 10)
+	}
 	var s string
 	if err := json.Unmarshal(data, &s); err != nil {
 		return err
 	}
-
-	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
-		return i.unmarshalJSON_hex(s)
-	}
-
-	return i.unmarshalJSON_decimal(s)
+	// Base 0 also accepts the 0x prefix, among others.
+	return i.setString(s, 0)
 }
 
-func (i *Uint128) unmarshalJSON_decimal(s string) error {
-	parsed, ok := (&big.Int{}).SetString(s, 0)
+// setString sets the value to the number written in s in the provided base.
+func (i *Uint128) setString(s string, base int) error {
+	parsed, ok := new(big.Int).SetString(s, base)
 	if !ok {
 		return fmt.Errorf("could not parse %q", s)
 	}
-	oo := parsed.FillBytes(make([]byte, 16))
-	ReverseBytes(oo)
-
-	dec := NewBinDecoder(oo)
+	return i.setBigInt(parsed)
+}
 
-	out, err := dec.ReadUint128(i.getByteOrder())
-	if err != nil {
-		return err
+func (i *Uint128) setBigInt(v *big.Int) error {
+	if v.Sign() < 0 || v.BitLen() > 128 {
+		return fmt.Errorf("uint128: value %s out of range", v)
 	}
-	i.Lo = out.Lo
-	i.Hi = out.Hi
-
+	buf := v.FillBytes(make([]byte, 16))
+	i.Hi = binary.BigEndian.Uint64(buf[:8])
+	i.Lo = binary.BigEndian.Uint64(buf[8:])
 	return nil
 }
 
-func (i *Uint128) unmarshalJSON_hex(s string) error {
-	truncatedVal := s[2:]
-	if len(truncatedVal) != 16 {
-		return fmt.Errorf("uint128 expects 16 characters after 0x, had %v", len(truncatedVal))
-	}
-
-	data, err := hex.DecodeString(truncatedVal)
-	if err != nil {
-		return err
-	}
-
-	order := i.getByteOrder()
-	if order == binary.LittleEndian {
-		i.Lo = order.Uint64(data[:8])
-		i.Hi = order.Uint64(data[8:])
-	} else {
-		i.Hi = order.Uint64(data[:8])
-		i.Lo = order.Uint64(data[8:])
+// Scan implements sql.Scanner, for NUMERIC columns: it accepts
+// decimal strings (or 0x-prefixed hexadecimal ones) and integers.
+func (i *Uint128) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case string:
+		return i.setString(v, 0)
+	case []byte:
+		return i.setString(string(v), 0)
+	case int64:
+		return i.setBigInt(big.NewInt(v))
+	case nil:
+		return errors.New("uint128: cannot scan NULL")
 	}
+	return fmt.Errorf("uint128: cannot scan %T", src)
+}
 
-	return nil
+// Value implements driver.Valuer: the value is stored as a decimal string,
+// e.g. in a NUMERIC(39) column.
+func (i Uint128) Value() (driver.Value, error) {
+	return i.String(), nil
 }
 
 func (i *Uint128) UnmarshalWithDecoder(dec *Decoder) error {
@@ -189,9 +224,97 @@ func (i Uint128) MarshalWithEncoder(enc *Encoder) error {
 	return enc.WriteUint128(i, order)
 }
 
-// Int128
+// Int128 is a signed 128-bit integer, stored in two's complement.
+// Add, Sub, Mul and Neg wrap around on overflow, like Go's integers.
 type Int128 Uint128
 
+var (
+	two128    = new(big.Int).Lsh(big.NewInt(1), 128)
+	maxInt128 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 127), big.NewInt(1))
+	minInt128 = new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 127))
+)
+
+// NewInt128FromInt64 returns v as an Int128.
+func NewInt128FromInt64(v int64) Int128 {
+	return Int128{Lo: uint64(v), Hi: uint64(v >> 63)}
+}
+
+// NewInt128FromBigInt converts v to an Int128; it fails if v is out of
+// the range of a signed 128-bit integer.
+func NewInt128FromBigInt(v *big.Int) (Int128, error) {
+	if v.Cmp(minInt128) < 0 || v.Cmp(maxInt128) > 0 {
+		return Int128{}, fmt.Errorf("int128: value %s out of range", v)
+	}
+	if v.Sign() < 0 {
+		v = new(big.Int).Add(v, two128)
+	}
+	buf := v.FillBytes(make([]byte, 16))
+	return Int128{
+		Hi: binary.BigEndian.Uint64(buf[:8]),
+		Lo: binary.BigEndian.Uint64(buf[8:]),
+	}, nil
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of i.
+func (i Int128) Sign() int {
+	switch {
+	case int64(i.Hi) < 0:
+		return -1
+	case i.Hi == 0 && i.Lo == 0:
+		return 0
+	}
+	return 1
+}
+
+// Cmp returns -1, 0 or +1 depending on whether i is
+// less than, equal to, or greater than j.
+func (i Int128) Cmp(j Int128) int {
+	switch {
+	case i.Hi == j.Hi && i.Lo == j.Lo:
+		return 0
+	case int64(i.Hi) < int64(j.Hi), i.Hi == j.Hi && i.Lo < j.Lo:
+		return -1
+	}
+	return 1
+}
+
+// Add returns i+j.
+func (i Int128) Add(j Int128) Int128 {
+	lo, carry := bits.Add64(i.Lo, j.Lo, 0)
+	hi, _ := bits.Add64(i.Hi, j.Hi, carry)
+	return Int128{Lo: lo, Hi: hi, Endianness: i.Endianness}
+}
+
+// Sub returns i-j.
+func (i Int128) Sub(j Int128) Int128 {
+	lo, borrow := bits.Sub64(i.Lo, j.Lo, 0)
+	hi, _ := bits.Sub64(i.Hi, j.Hi, borrow)
+	return Int128{Lo: lo, Hi: hi, Endianness: i.Endianness}
+}
+
+// Mul returns i*j.
+func (i Int128) Mul(j Int128) Int128 {
+	hi, lo := bits.Mul64(i.Lo, j.Lo)
+	hi += i.Hi*j.Lo + i.Lo*j.Hi
+	return Int128{Lo: lo, Hi: hi, Endianness: i.Endianness}
+}
+
+// Neg returns -i.
+func (i Int128) Neg() Int128 {
+	return Int128{Endianness: i.Endianness}.Sub(i)
+}
+
+// IsInt64 reports whether i can be represented as an int64.
+func (i Int128) IsInt64() bool {
+	return i.Hi == uint64(int64(i.Lo)>>63)
+}
+
+// Int64 returns the low 64 bits of i as an int64; the result is
+// undefined if i can't be represented as an int64 (see IsInt64).
+func (i Int128) Int64() int64 {
+	return int64(i.Lo)
+}
+
 func (i Int128) BigInt() *big.Int {
 	comp := byte(0x80)
 	buf := Uint128(i).Bytes()
@@ -208,26 +331,45 @@ func (i Int128) BigInt() *big.Int {
 }
 
 func (i Int128) String() string {
-	return Uint128(i).String()
+	return i.DecimalString()
 }
 
 func (i Int128) DecimalString() string {
 	return i.BigInt().String()
 }
 
+// HexString returns the 16 big-endian bytes of the two's complement of the value.
+func (i Int128) HexString() string {
+	return Uint128(i).HexString()
+}
+
 func (i Int128) MarshalJSON() (data []byte, err error) {
-	return []byte(`"` + Uint128(i).String() + `"`), nil
+	return []byte(`"` + i.String() + `"`), nil
 }
 
+// UnmarshalJSON accepts a signed decimal string, or the hexadecimal
+// two's complement of the value prefixed with 0x.
 func (i *Int128) UnmarshalJSON(data []byte) error {
-	var el Uint128
-	if err := json.Unmarshal(data, &el); err != nil {
+	if string(data) == "null" {
+		return nil
+	}
+	parsed, isHex, err := parseBigIntJSON(data)
+	if err != nil {
 		return err
 	}
-
-	out := Int128(el)
-	*i = out
-
+	if isHex {
+		if parsed.BitLen() > 128 {
+			return fmt.Errorf("int128: value %s overflows 128 bits", parsed)
+		}
+		if parsed.Cmp(maxInt128) > 0 {
+			parsed.Sub(parsed, two128)
+		}
+	}
+	v, err := NewInt128FromBigInt(parsed)
+	if err != nil {
+		return err
+	}
+	i.Lo, i.Hi = v.Lo, v.Hi
 	return nil
 }
 
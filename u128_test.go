@@ -1,7 +1,13 @@
 package bin
 
 import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
 	"encoding/json"
+	"math"
+	"math/big"
 	"testing"
 
 	"github.com/shopspring/decimal"
@@ -62,3 +68,153 @@ func TestUint128(t *testing.T) {
 		}
 	}
 }
+
+func TestInt128(t *testing.T) {
+	minusOne := NewInt128FromInt64(-1)
+	require.Equal(t, Int128{Lo: math.MaxUint64, Hi: math.MaxUint64}, minusOne)
+	require.Equal(t, "-1", minusOne.String())
+	require.Equal(t, "0xffffffffffffffffffffffffffffffff", minusOne.HexString())
+	require.Equal(t, -1, minusOne.Sign())
+
+	min, ok := new(big.Int).SetString("-170141183460469231731687303715884105728", 10)
+	require.True(t, ok)
+	v, err := NewInt128FromBigInt(min)
+	require.NoError(t, err)
+	require.Equal(t, Int128{Hi: 1 << 63}, v)
+	require.Equal(t, min.String(), v.String())
+	_, err = NewInt128FromBigInt(new(big.Int).Sub(min, big.NewInt(1)))
+	require.Error(t, err)
+
+	{
+		// Borsh i128 is little endian two's complement.
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewBorshEncoder(buf).Encode(NewInt128FromInt64(-2)))
+		require.Equal(t, append([]byte{0xfe}, bytes.Repeat([]byte{0xff}, 15)...), buf.Bytes())
+
+		var out Int128
+		require.NoError(t, NewBorshDecoder(buf.Bytes()).Decode(&out))
+		require.Equal(t, int64(-2), out.Int64())
+		require.True(t, out.IsInt64())
+	}
+	{
+		type record struct {
+			V Int128 `bin:"big"`
+		}
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewBinEncoder(buf).Encode(&record{V: NewInt128FromInt64(-2)}))
+		require.Equal(t, append(bytes.Repeat([]byte{0xff}, 15), 0xfe), buf.Bytes())
+		var out record
+		require.NoError(t, NewBinDecoder(buf.Bytes()).Decode(&out))
+		require.Equal(t, "-2", out.V.String())
+		require.Equal(t, binary.ByteOrder(nil), out.V.Endianness)
+	}
+	{
+		j := []byte(`{"i":"-57240246860720736513843"}`)
+		var object struct {
+			I Int128 `json:"i"`
+		}
+		require.NoError(t, json.Unmarshal(j, &object))
+		require.Equal(t, "-57240246860720736513843", object.I.String())
+		out, err := json.Marshal(object)
+		require.NoError(t, err)
+		require.Equal(t, j, out)
+
+		require.NoError(t, json.Unmarshal([]byte(`{"i":"0xfffffffffffffffffffffffffffffffe"}`), &object))
+		require.Equal(t, NewInt128FromInt64(-2), object.I)
+		require.Error(t, json.Unmarshal([]byte(`{"i":"170141183460469231731687303715884105728"}`), &object))
+	}
+}
+
+func TestInt128_Arithmetic(t *testing.T) {
+	a := NewInt128FromInt64(math.MaxInt64)
+	b := NewInt128FromInt64(math.MaxInt64)
+
+	sum := a.Add(b)
+	require.False(t, sum.IsInt64())
+	require.Equal(t, "18446744073709551614", sum.String())
+	require.Equal(t, a, sum.Sub(b))
+	require.Equal(t, "85070591730234615847396907784232501249", a.Mul(b).String())
+	require.Equal(t, "-9223372036854775807", a.Neg().String())
+	require.Equal(t, "-85070591730234615847396907784232501249", a.Neg().Mul(b).String())
+
+	require.Equal(t, -1, a.Neg().Cmp(a))
+	require.Equal(t, 1, sum.Cmp(a))
+	require.Equal(t, 0, a.Cmp(b))
+	require.Equal(t, -1, NewInt128FromInt64(-1).Cmp(NewInt128FromInt64(0)))
+	require.Equal(t, 0, Int128{}.Sign())
+
+	// Overflow wraps around.
+	max := Int128{Lo: math.MaxUint64, Hi: math.MaxInt64}
+	require.Equal(t, Int128{Hi: 1 << 63}, max.Add(NewInt128FromInt64(1)))
+}
+
+func TestUint128_JSONFormat(t *testing.T) {
+	v := Uint128JSON{Uint128: Uint128{Lo: 255, Hi: 1}}
+	out, err := json.Marshal(v)
+	require.NoError(t, err)
+	require.Equal(t, `"18446744073709551871"`, string(out))
+
+	v.Format = JSONHexString
+	out, err = json.Marshal(v)
+	require.NoError(t, err)
+	require.Equal(t, `"0x000000000000000100000000000000ff"`, string(out))
+
+	v.Format = JSONNumber
+	out, err = json.Marshal(v)
+	require.NoError(t, err)
+	require.Equal(t, `18446744073709551871`, string(out))
+
+	for _, in := range []string{`"18446744073709551871"`, `"0x000000000000000100000000000000ff"`, `"0x100000000000000ff"`, `"0X100000000000000FF"`, `"0o2000000000000000000377"`, `18446744073709551871`} {
+		var got Uint128
+		require.NoError(t, json.Unmarshal([]byte(in), &got), in)
+		require.Equal(t, Uint128{Lo: 255, Hi: 1}, got, in)
+
+		var wrapped Uint128JSON
+		require.NoError(t, json.Unmarshal([]byte(in), &wrapped), in)
+		require.Equal(t, Uint128{Lo: 255, Hi: 1}, wrapped.Uint128, in)
+	}
+	// Big endian values parse to the same number.
+	got := NewUint128BigEndian()
+	require.NoError(t, json.Unmarshal([]byte(`"18446744073709551871"`), got))
+	require.Equal(t, "18446744073709551871", got.String())
+
+	for _, in := range []string{`"-1"`, `"340282366920938463463374607431768211456"`, `"0x"`, `1.5`} {
+		var got Uint128
+		require.Error(t, json.Unmarshal([]byte(in), &got), in)
+	}
+
+	// The wrapper is encoded like a Uint128, and decoding keeps its format.
+	data, err := MarshalBorsh(Uint128JSON{Uint128: Uint128{Lo: 255}, Format: JSONHexString})
+	require.NoError(t, err)
+	expected, err := MarshalBorsh(Uint128{Lo: 255})
+	require.NoError(t, err)
+	require.Equal(t, expected, data)
+	decoded := Uint128JSON{Format: JSONNumber}
+	require.NoError(t, UnmarshalBorsh(&decoded, data))
+	out, err = json.Marshal(decoded)
+	require.NoError(t, err)
+	require.Equal(t, `255`, string(out))
+}
+
+func TestUint128_SQL(t *testing.T) {
+	var _ sql.Scanner = (*Uint128)(nil)
+	var _ driver.Valuer = Uint128{}
+
+	value, err := Uint128{Lo: 255, Hi: 1}.Value()
+	require.NoError(t, err)
+	require.Equal(t, "18446744073709551871", value)
+
+	for _, src := range []interface{}{"18446744073709551871", []byte("18446744073709551871"), "0x100000000000000ff"} {
+		var got Uint128
+		require.NoError(t, got.Scan(src), src)
+		require.Equal(t, Uint128{Lo: 255, Hi: 1}, got)
+	}
+	var got Uint128
+	require.NoError(t, got.Scan(int64(7)))
+	require.Equal(t, Uint128{Lo: 7}, got)
+
+	require.Error(t, got.Scan(int64(-7)))
+	require.Error(t, got.Scan(nil))
+	require.Error(t, got.Scan(1.5))
+	require.Error(t, got.Scan("12.5"))
+}
@@ -0,0 +1,264 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Uint256 is an unsigned 256-bit integer, encoded as 32 bytes
+// in the byte order of the field (or its Endianness, if set).
+type Uint256 struct {
+	// Words are the 64-bit words of the value, least significant first.
+	Words      [4]uint64
+	Endianness binary.ByteOrder
+}
+
+// Int256 is a signed 256-bit integer, stored in two's complement.
+type Int256 Uint256
+
+var (
+	two256    = new(big.Int).Lsh(big.NewInt(1), 256)
+	maxInt256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(1))
+	minInt256 = new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 255))
+)
+
+func NewUint256BigEndian() *Uint256 {
+	return &Uint256{
+		Endianness: binary.BigEndian,
+	}
+}
+
+func NewUint256LittleEndian() *Uint256 {
+	return &Uint256{
+		Endianness: binary.LittleEndian,
+	}
+}
+
+// NewUint256FromBigInt converts v to a Uint256; it fails if v is negative
+// or doesn't fit in 256 bits.
+func NewUint256FromBigInt(v *big.Int) (Uint256, error) {
+	if v.Sign() < 0 {
+		return Uint256{}, fmt.Errorf("uint256: negative value %s", v)
+	}
+	if v.BitLen() > 256 {
+		return Uint256{}, fmt.Errorf("uint256: value %s overflows 256 bits", v)
+	}
+	return uint256FromBytes(v.Bytes()), nil
+}
+
+// NewInt256FromBigInt converts v to an Int256; it fails if v is out of
+// the range of a signed 256-bit integer.
+func NewInt256FromBigInt(v *big.Int) (Int256, error) {
+	if v.Cmp(minInt256) < 0 || v.Cmp(maxInt256) > 0 {
+		return Int256{}, fmt.Errorf("int256: value %s out of range", v)
+	}
+	if v.Sign() < 0 {
+		v = new(big.Int).Add(v, two256)
+	}
+	return Int256(uint256FromBytes(v.Bytes())), nil
+}
+
+// uint256FromBytes converts a big-endian magnitude of at most 32 bytes.
+func uint256FromBytes(b []byte) (out Uint256) {
+	buf := make([]byte, 32)
+	copy(buf[32-len(b):], b)
+	for k := range out.Words {
+		out.Words[k] = binary.BigEndian.Uint64(buf[(3-k)*8:])
+	}
+	return out
+}
+
+func (i Uint256) getByteOrder() binary.ByteOrder {
+	if i.Endianness == nil {
+		return defaultByteOrder
+	}
+	return i.Endianness
+}
+
+func (i Int256) getByteOrder() binary.ByteOrder {
+	return Uint256(i).getByteOrder()
+}
+
+// Bytes returns the 32 big-endian bytes of the value.
+func (i Uint256) Bytes() []byte {
+	buf := make([]byte, 32)
+	for k, w := range i.Words {
+		binary.BigEndian.PutUint64(buf[(3-k)*8:], w)
+	}
+	return buf
+}
+
+func (i Uint256) BigInt() *big.Int {
+	return new(big.Int).SetBytes(i.Bytes())
+}
+
+func (i Uint256) String() string {
+	return i.DecimalString()
+}
+
+func (i Uint256) DecimalString() string {
+	return i.BigInt().String()
+}
+
+func (i Uint256) HexString() string {
+	return fmt.Sprintf("0x%s", hex.EncodeToString(i.Bytes()))
+}
+
+func (i Uint256) MarshalJSON() (data []byte, err error) {
+	return []byte(`"` + i.String() + `"`), nil
+}
+
+// UnmarshalJSON accepts a decimal string, or a hexadecimal one prefixed with 0x.
+func (i *Uint256) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	parsed, _, err := parseBigIntJSON(data)
+	if err != nil {
+		return err
+	}
+	v, err := NewUint256FromBigInt(parsed)
+	if err != nil {
+		return err
+	}
+	i.Words = v.Words
+	return nil
+}
+
+// parseBigIntJSON parses a JSON string holding a decimal
+// or a 0x-prefixed hexadecimal number.
+func parseBigIntJSON(data []byte) (value *big.Int, isHex bool, err error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, false, err
+	}
+	base, digits := 10, s
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		base, digits = 16, s[2:]
+	}
+	value, ok := new(big.Int).SetString(digits, base)
+	if !ok || (base == 16 && value.Sign() < 0) {
+		return nil, false, fmt.Errorf("could not parse %q", s)
+	}
+	return value, base == 16, nil
+}
+
+func (i *Uint256) UnmarshalWithDecoder(dec *Decoder) error {
+	var order binary.ByteOrder
+	if dec != nil && dec.currentFieldOpt != nil {
+		order = dec.currentFieldOpt.Order
+	} else {
+		order = i.getByteOrder()
+	}
+	value, err := dec.ReadUint256(order)
+	if err != nil {
+		return err
+	}
+
+	*i = value
+	return nil
+}
+
+func (i Uint256) MarshalWithEncoder(enc *Encoder) error {
+	var order binary.ByteOrder
+	if enc != nil && enc.currentFieldOpt != nil {
+		order = enc.currentFieldOpt.Order
+	} else {
+		order = i.getByteOrder()
+	}
+	return enc.WriteUint256(i, order)
+}
+
+func (i Int256) BigInt() *big.Int {
+	value := Uint256(i).BigInt()
+	if i.Words[3]>>63 == 1 {
+		value.Sub(value, two256)
+	}
+	return value
+}
+
+func (i Int256) String() string {
+	return i.DecimalString()
+}
+
+func (i Int256) DecimalString() string {
+	return i.BigInt().String()
+}
+
+// HexString returns the 32 big-endian bytes of the two's complement of the value.
+func (i Int256) HexString() string {
+	return Uint256(i).HexString()
+}
+
+func (i Int256) MarshalJSON() (data []byte, err error) {
+	return []byte(`"` + i.String() + `"`), nil
+}
+
+// UnmarshalJSON accepts a signed decimal string, or the hexadecimal
+// two's complement of the value prefixed with 0x.
+func (i *Int256) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	parsed, isHex, err := parseBigIntJSON(data)
+	if err != nil {
+		return err
+	}
+	var v Int256
+	if isHex {
+		var u Uint256
+		u, err = NewUint256FromBigInt(parsed)
+		v = Int256(u)
+	} else {
+		v, err = NewInt256FromBigInt(parsed)
+	}
+	if err != nil {
+		return err
+	}
+	i.Words = v.Words
+	return nil
+}
+
+func (i *Int256) UnmarshalWithDecoder(dec *Decoder) error {
+	var order binary.ByteOrder
+	if dec != nil && dec.currentFieldOpt != nil {
+		order = dec.currentFieldOpt.Order
+	} else {
+		order = i.getByteOrder()
+	}
+	value, err := dec.ReadInt256(order)
+	if err != nil {
+		return err
+	}
+
+	*i = value
+	return nil
+}
+
+func (i Int256) MarshalWithEncoder(enc *Encoder) error {
+	var order binary.ByteOrder
+	if enc != nil && enc.currentFieldOpt != nil {
+		order = enc.currentFieldOpt.Order
+	} else {
+		order = i.getByteOrder()
+	}
+	return enc.WriteInt256(i, order)
+}
@@ -0,0 +1,137 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUint256(t *testing.T) {
+	v, ok := new(big.Int).SetString("0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20", 16)
+	require.True(t, ok)
+	u, err := NewUint256FromBigInt(v)
+	require.NoError(t, err)
+	assert.Equal(t, [4]uint64{0x191a1b1c1d1e1f20, 0x1112131415161718, 0x090a0b0c0d0e0f10, 0x0102030405060708}, u.Words)
+	assert.Equal(t, v, u.BigInt())
+	assert.Equal(t, "0x0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20", u.HexString())
+
+	t.Run("wire", func(t *testing.T) {
+		type S struct {
+			LE Uint256
+			BE Uint256 `bin:"big"`
+		}
+		data, err := MarshalBin(&S{LE: u, BE: u})
+		require.NoError(t, err)
+		require.Len(t, data, 64)
+		assert.Equal(t, u.Bytes(), data[32:])
+		le := append([]byte(nil), data[:32]...)
+		ReverseBytes(le)
+		assert.Equal(t, u.Bytes(), le)
+
+		var got S
+		require.NoError(t, UnmarshalBin(&got, data))
+		assert.Equal(t, u.Words, got.LE.Words)
+		assert.Equal(t, u.Words, got.BE.Words)
+
+		_, err = NewBinDecoder(data[:31]).ReadUint256(binary.LittleEndian)
+		assert.Error(t, err)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		data, err := json.Marshal(u)
+		require.NoError(t, err)
+		assert.Equal(t, `"`+v.String()+`"`, string(data))
+
+		var got Uint256
+		require.NoError(t, json.Unmarshal(data, &got))
+		assert.Equal(t, u.Words, got.Words)
+
+		got = Uint256{}
+		require.NoError(t, json.Unmarshal([]byte(`"0xff"`), &got))
+		assert.Equal(t, [4]uint64{0xff}, got.Words)
+
+		assert.Error(t, json.Unmarshal([]byte(`"-1"`), &got))
+		assert.Error(t, json.Unmarshal([]byte(`"abc"`), &got))
+	})
+
+	t.Run("range", func(t *testing.T) {
+		_, err := NewUint256FromBigInt(big.NewInt(-1))
+		assert.Error(t, err)
+		_, err = NewUint256FromBigInt(two256)
+		assert.Error(t, err)
+		max, err := NewUint256FromBigInt(new(big.Int).Sub(two256, big.NewInt(1)))
+		require.NoError(t, err)
+		assert.Equal(t, [4]uint64{^uint64(0), ^uint64(0), ^uint64(0), ^uint64(0)}, max.Words)
+	})
+}
+
+func TestInt256(t *testing.T) {
+	for _, s := range []string{
+		"0",
+		"1",
+		"-1",
+		"-123456789012345678901234567890",
+		maxInt256.String(),
+		minInt256.String(),
+	} {
+		t.Run(s, func(t *testing.T) {
+			v, _ := new(big.Int).SetString(s, 10)
+			i, err := NewInt256FromBigInt(v)
+			require.NoError(t, err)
+			assert.Equal(t, s, i.String())
+
+			buf := new(bytes.Buffer)
+			require.NoError(t, NewBorshEncoder(buf).Encode(i))
+			require.Len(t, buf.Bytes(), 32)
+			var got Int256
+			require.NoError(t, NewBorshDecoder(buf.Bytes()).Decode(&got))
+			assert.Equal(t, 0, v.Cmp(got.BigInt()))
+
+			data, err := json.Marshal(i)
+			require.NoError(t, err)
+			assert.Equal(t, `"`+s+`"`, string(data))
+			got = Int256{}
+			require.NoError(t, json.Unmarshal(data, &got))
+			assert.Equal(t, i.Words, got.Words)
+		})
+	}
+
+	var minusOne Int256
+	require.NoError(t, json.Unmarshal([]byte(`"0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"`), &minusOne))
+	assert.Equal(t, "-1", minusOne.String())
+
+	_, err := NewInt256FromBigInt(new(big.Int).Add(maxInt256, big.NewInt(1)))
+	assert.Error(t, err)
+	_, err = NewInt256FromBigInt(new(big.Int).Sub(minInt256, big.NewInt(1)))
+	assert.Error(t, err)
+}
+
+func TestUint256_Layout(t *testing.T) {
+	type S struct {
+		A Uint256
+		B Int256 `bin:"big"`
+	}
+	out, err := ExplainType(S{})
+	require.NoError(t, err)
+	assert.Contains(t, out, "64 bytes")
+	assert.Contains(t, out, "BE")
+}
@@ -0,0 +1,186 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// unions maps the type of a `union` field to the types
+// of its variants, by the value of their discriminator.
+var unions sync.Map
+
+// RegisterUnion registers the variants of a union type, for use by
+// `union=Kind` tags: an interface type, provided as a nil pointer to it,
+// or a byte slice type. Each variant is provided as a value of its type,
+// under the value of the discriminator field that selects it:
+//
+//	type Shape interface{ Area() float64 }
+//
+//	bin.RegisterUnion((*Shape)(nil), map[uint64]interface{}{
+//		1: Circle{},
+//		2: Rect{},
+//	})
+//
+//	type Drawing struct {
+//		Kind  uint8
+//		Shape Shape `bin:"union=Kind"`
+//	}
+//
+// The variants of an interface must implement it. A byte slice union holds
+// the encoding of its variant, which is written as is, and which decoders
+// find the end of by decoding the variant.
+func RegisterUnion(union interface{}, variants map[uint64]interface{}) {
+	rt := reflect.TypeOf(union)
+	if rt != nil && rt.Kind() == reflect.Ptr && rt.Elem().Kind() == reflect.Interface {
+		rt = rt.Elem()
+	} else if rt == nil || rt.Kind() != reflect.Slice || rt.Elem().Kind() != reflect.Uint8 {
+		panic(fmt.Sprintf("RegisterUnion: expected a nil pointer to an interface or a byte slice, got %T", union))
+	}
+	if len(variants) == 0 {
+		panic(fmt.Sprintf("RegisterUnion: no variants for %s", rt))
+	}
+	types := make(map[uint64]reflect.Type, len(variants))
+	for value, variant := range variants {
+		vt := reflect.TypeOf(variant)
+		if vt == nil {
+			panic(fmt.Sprintf("RegisterUnion: nil variant for discriminator %d of %s", value, rt))
+		}
+		if rt.Kind() == reflect.Interface && !vt.Implements(rt) {
+			panic(fmt.Sprintf("RegisterUnion: variant %s doesn't implement %s", vt, rt))
+		}
+		types[value] = vt
+	}
+	unions.Store(rt, types)
+}
+
+// unionVariants returns the variants registered for a union type.
+func unionVariants(rt reflect.Type) (map[uint64]reflect.Type, error) {
+	variants, ok := unions.Load(rt)
+	if !ok {
+		return nil, fmt.Errorf("union %s is not registered", rt)
+	}
+	return variants.(map[uint64]reflect.Type), nil
+}
+
+// lookupUnionVariant returns the variant of a union type selected by a discriminator value.
+func lookupUnionVariant(rt reflect.Type, value uint64) (reflect.Type, error) {
+	variants, err := unionVariants(rt)
+	if err != nil {
+		return nil, err
+	}
+	variant, ok := variants[value]
+	if !ok {
+		return nil, fmt.Errorf("no variant of union %s for discriminator %d", rt, value)
+	}
+	return variant, nil
+}
+
+// unionVariant returns the variant of the `union` field at index i
+// of a struct, selected by the value of its discriminator field.
+func unionVariant(plan *structPlan, rv reflect.Value, i int) (reflect.Type, error) {
+	name := plan.tags[i].Union
+	discriminator := plan.fieldIndex(name)
+	if discriminator < 0 {
+		return nil, fmt.Errorf("union refers to unknown field %q", name)
+	}
+	v := plan.field(rv, discriminator)
+	if !isIntegerKind(v.Kind()) {
+		return nil, fmt.Errorf("union discriminator %q must be an integer, got %s", name, v.Type())
+	}
+	return lookupUnionVariant(plan.fields[i].Type, enumValue(v))
+}
+
+// encodeUnion encodes the variant held by a `union` field: the value of
+// an interface, whose type must be the one selected by the discriminator,
+// or the bytes of a byte slice, as is.
+func (e *Encoder) encodeUnion(rv reflect.Value, opt *option) (bool, error) {
+	if opt == nil || opt.UnionVariant == nil {
+		return false, nil
+	}
+	if rv.Kind() == reflect.Slice {
+		return true, e.WriteBytes(rv.Bytes(), false)
+	}
+	if rv.IsNil() {
+		return true, fmt.Errorf("union %s is nil", rv.Type())
+	}
+	variant := rv.Elem()
+	if variant.Type() != opt.UnionVariant {
+		return true, fmt.Errorf("the discriminator selects %s, got %s", opt.UnionVariant, variant.Type())
+	}
+	return true, e.encodeWithOption(variant, nil)
+}
+
+// decodeUnion decodes the variant selected by the discriminator of a
+// `union` field into it, or keeps its encoding for a byte slice.
+func (dec *Decoder) decodeUnion(rv reflect.Value, opt *option) (bool, error) {
+	if opt == nil || opt.UnionVariant == nil {
+		return false, nil
+	}
+	// Fields that are queried or skipped are decoded through a pointer.
+	rv = reflect.Indirect(rv)
+	start := dec.base + dec.pos
+	variant := reflect.New(opt.UnionVariant)
+	if err := dec.decodeWithOption(variant, nil); err != nil {
+		return true, err
+	}
+	if rv.Kind() == reflect.Slice {
+		data := append([]byte(nil), dec.inputRange(start, dec.base+dec.pos)...)
+		rv.Set(reflect.ValueOf(data).Convert(rv.Type()))
+		return true, nil
+	}
+	rv.Set(variant.Elem())
+	return true, nil
+}
+
+// describeUnion describes a `union` field, whose variants
+// are named after the value of their discriminator.
+func (b *layoutBuilder) describeUnion(rt reflect.Type, discriminator string) (*layoutNode, error) {
+	variants, err := unionVariants(rt)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]uint64, 0, len(variants))
+	for value := range variants {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	n := &layoutNode{
+		Type:  rt,
+		Wire:  wireUnion,
+		Union: discriminator,
+	}
+	for i, value := range values {
+		variant, err := b.describe(variants[value], b.defaultOption())
+		if err != nil {
+			return nil, fmt.Errorf("variant %d: %w", value, err)
+		}
+		variant.Name = strconv.FormatUint(value, 10)
+		n.Fields = append(n.Fields, variant)
+		// The union has a fixed size when all of its variants have the same one.
+		switch {
+		case i == 0:
+			n.Size = variant.Size
+		case variant.Size != n.Size:
+			n.Size = -1
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type unionShape interface {
+	Sides() int
+}
+
+type unionCircle struct {
+	Radius uint16
+}
+
+func (unionCircle) Sides() int { return 0 }
+
+type unionRect struct {
+	Width, Height uint16
+	Label         string
+}
+
+func (*unionRect) Sides() int { return 4 }
+
+type unionShapeBytes []byte
+
+func init() {
+	RegisterUnion((*unionShape)(nil), map[uint64]interface{}{
+		1: unionCircle{},
+		2: &unionRect{},
+	})
+	RegisterUnion(unionShapeBytes(nil), map[uint64]interface{}{
+		1: unionCircle{},
+		2: unionRect{},
+	})
+}
+
+type unionDrawing struct {
+	Kind  uint8
+	Shape unionShape `bin:"union=Kind"`
+	Tail  uint8
+}
+
+type unionRawDrawing struct {
+	Kind  uint8
+	Shape unionShapeBytes `bin:"union=Kind"`
+	Tail  uint8
+}
+
+func TestUnion(t *testing.T) {
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		t.Run(enc.String(), func(t *testing.T) {
+			circle := unionDrawing{Kind: 1, Shape: unionCircle{Radius: 7}, Tail: 9}
+			data, err := MarshalAppend(nil, &circle, enc)
+			require.NoError(t, err)
+			assert.Equal(t, []byte{1, 7, 0, 9}, data)
+
+			var out unionDrawing
+			require.NoError(t, NewDecoderWithEncoding(data, enc).Decode(&out))
+			assert.Equal(t, circle, out)
+
+			rect := unionDrawing{Kind: 2, Shape: &unionRect{Width: 3, Height: 4, Label: "a"}, Tail: 9}
+			data, err = MarshalAppend(nil, &rect, enc)
+			require.NoError(t, err)
+			out = unionDrawing{}
+			require.NoError(t, NewDecoderWithEncoding(data, enc).Decode(&out))
+			assert.Equal(t, rect, out)
+
+			require.NoError(t, ConformsWithEncoding(data, enc, unionDrawing{}))
+			res, err := QueryWithEncoding(data, enc, unionDrawing{}, "Shape.Height")
+			require.NoError(t, err)
+			assert.Equal(t, uint16(4), res.Value)
+			res, err = QueryWithEncoding(data, enc, unionDrawing{}, "Tail")
+			require.NoError(t, err)
+			assert.Equal(t, uint8(9), res.Value)
+
+			// A byte slice union keeps the encoding of its variant.
+			var raw unionRawDrawing
+			require.NoError(t, NewDecoderWithEncoding(data, enc).Decode(&raw))
+			assert.Equal(t, uint8(9), raw.Tail)
+			assert.Equal(t, data[1:len(data)-1], []byte(raw.Shape))
+			reencoded, err := MarshalAppend(nil, &raw, enc)
+			require.NoError(t, err)
+			assert.Equal(t, data, reencoded)
+		})
+	}
+
+	_, err := MarshalAppend(nil, &unionDrawing{Kind: 2, Shape: unionCircle{}}, EncodingBin)
+	assert.EqualError(t, err, "error while encoding \"Shape\" field: the discriminator selects *bin.unionRect, got bin.unionCircle")
+	_, err = MarshalAppend(nil, &unionDrawing{Kind: 3, Shape: unionCircle{}}, EncodingBin)
+	assert.EqualError(t, err, "error while encoding \"Shape\" field: no variant of union bin.unionShape for discriminator 3")
+	assert.Error(t, NewBinDecoder([]byte{3, 0, 0, 0}).Decode(&unionDrawing{}))
+
+	explained, err := ExplainType(unionDrawing{})
+	require.NoError(t, err)
+	assert.Contains(t, explained, "union=Kind")
+	assert.Contains(t, explained, "Shape<2>")
+	ksy, err := KaitaiStruct(unionDrawing{}, EncodingBorsh)
+	require.NoError(t, err)
+	assert.Contains(t, ksy, "switch-on: kind")
+	require.NoError(t, Precompile(unionDrawing{}, unionRawDrawing{}))
+}
+
+func TestUnion_Errors(t *testing.T) {
+	type notUnion struct {
+		Kind  uint8
+		Shape uint32 `bin:"union=Kind"`
+	}
+	assert.EqualError(t, Precompile(notUnion{}), "precompile: bin.notUnion: field \"Shape\": the union tag only applies to interfaces and byte slices, got uint32")
+
+	type after struct {
+		Shape unionShape `bin:"union=Kind"`
+		Kind  uint8
+	}
+	assert.EqualError(t, Precompile(after{}), "precompile: bin.after: field \"Shape\": union discriminator \"Kind\" must come before the union")
+
+	type notInteger struct {
+		Kind  string
+		Shape unionShape `bin:"union=Kind"`
+	}
+	assert.EqualError(t, Precompile(notInteger{}), "precompile: bin.notInteger: field \"Shape\": union discriminator \"Kind\" must be an integer, got string")
+
+	type unregistered struct {
+		Kind  uint8
+		Shape []byte `bin:"union=Kind"`
+	}
+	assert.EqualError(t, Precompile(unregistered{}), "precompile: bin.unregistered: field \"Shape\": union []uint8 is not registered")
+
+	assert.Panics(t, func() {
+		RegisterUnion((*unionShape)(nil), map[uint64]interface{}{1: uint8(0)})
+	})
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+// SetContextValue stores a value under the provided key, for the custom
+// marshalers called by the encoder, e.g. the version of the protocol
+// spoken with a peer:
+//
+//	type protocolVersion struct{}
+//
+//	encoder.SetContextValue(protocolVersion{}, 2)
+//
+//	func (m *Message) MarshalWithEncoder(encoder *bin.Encoder) error {
+//		if v, _ := encoder.ContextValue(protocolVersion{}).(int); v >= 2 {
+//			...
+//		}
+//	}
+//
+// Like with context.Context, keys should be of an unexported type
+// of the package that uses them, so that they don't collide.
+// A nil value deletes the key.
+func (e *Encoder) SetContextValue(key, val interface{}) {
+	e.values = setContextValue(e.values, key, val)
+}
+
+// ContextValue returns the value stored under the provided key with SetContextValue, or nil.
+func (e *Encoder) ContextValue(key interface{}) interface{} {
+	return e.values[key]
+}
+
+// SetContextValue stores a value under the provided key, for the custom
+// unmarshalers called by the decoder; see Encoder.SetContextValue.
+// Sub-decoders inherit the values of their parent.
+func (dec *Decoder) SetContextValue(key, val interface{}) {
+	dec.values = setContextValue(dec.values, key, val)
+}
+
+// ContextValue returns the value stored under the provided key with SetContextValue, or nil.
+func (dec *Decoder) ContextValue(key interface{}) interface{} {
+	return dec.values[key]
+}
+
+func setContextValue(values map[interface{}]interface{}, key, val interface{}) map[interface{}]interface{} {
+	if key == nil {
+		panic("nil context value key")
+	}
+	if val == nil {
+		delete(values, key)
+		return values
+	}
+	if values == nil {
+		values = map[interface{}]interface{}{}
+	}
+	values[key] = val
+	return values
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type versionKey struct{}
+
+// versionedMessage has a field that exists only from version 2 of a protocol.
+type versionedMessage struct {
+	ID    uint8
+	Flags uint8
+}
+
+func (m versionedMessage) MarshalWithEncoder(encoder *Encoder) error {
+	if err := encoder.WriteUint8(m.ID); err != nil {
+		return err
+	}
+	if v, _ := encoder.ContextValue(versionKey{}).(int); v >= 2 {
+		return encoder.WriteUint8(m.Flags)
+	}
+	return nil
+}
+
+func (m *versionedMessage) UnmarshalWithDecoder(decoder *Decoder) (err error) {
+	if m.ID, err = decoder.ReadUint8(); err != nil {
+		return err
+	}
+	if v, _ := decoder.ContextValue(versionKey{}).(int); v >= 2 {
+		m.Flags, err = decoder.ReadUint8()
+	}
+	return err
+}
+
+func TestContextValues(t *testing.T) {
+	msg := versionedMessage{ID: 1, Flags: 7}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, NewBinEncoder(buf).Encode(msg))
+	assert.Equal(t, []byte{0x01}, buf.Bytes())
+
+	buf.Reset()
+	encoder := NewBinEncoder(buf)
+	encoder.SetContextValue(versionKey{}, 2)
+	require.NoError(t, encoder.Encode(msg))
+	assert.Equal(t, []byte{0x01, 0x07}, buf.Bytes())
+
+	decoder := NewBinDecoder(append(buf.Bytes(), buf.Bytes()...))
+	decoder.SetContextValue(versionKey{}, 2)
+	var got versionedMessage
+	require.NoError(t, decoder.Decode(&got))
+	assert.Equal(t, msg, got)
+
+	sub, err := decoder.SubDecoder(2)
+	require.NoError(t, err)
+	require.NoError(t, sub.Decode(&got))
+	assert.Equal(t, msg, got)
+
+	decoder.SetContextValue(versionKey{}, nil)
+	assert.Nil(t, decoder.ContextValue(versionKey{}))
+}
@@ -0,0 +1,275 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+// MaxSQLiteVarintLen is the maximum length of an SQLite varint.
+const MaxSQLiteVarintLen = 9
+
+// WriteSQLiteVarint writes an integer in the varint format of SQLite:
+// big-endian, 1 to 9 bytes, where the first 8 bytes carry 7 bits each
+// and have their high bit set when another byte follows, and the 9th byte
+// carries 8 bits. Values of up to 56 bits take at most 8 bytes.
+func (e *Encoder) WriteSQLiteVarint(v uint64) (err error) {
+	if e.tracing() {
+		e.tlog().Debug("encode: write sqlite varint", logUint64("val", v))
+	}
+	buf := make([]byte, MaxSQLiteVarintLen)
+	if v&(0xff000000<<32) != 0 {
+		buf[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			buf[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return e.toWriter(buf)
+	}
+	i := len(buf) - 1
+	buf[i] = byte(v & 0x7f)
+	for v >>= 7; v != 0; v >>= 7 {
+		i--
+		buf[i] = byte(v&0x7f) | 0x80
+	}
+	return e.toWriter(buf[i:])
+}
+
+// ReadSQLiteVarint reads an integer written by WriteSQLiteVarint.
+func (dec *Decoder) ReadSQLiteVarint() (out uint64, err error) {
+	dec.fill(MaxSQLiteVarintLen)
+	for i := 0; ; i++ {
+		if dec.pos+i >= len(dec.data) {
+			return 0, fmt.Errorf("sqlite varint: required [%d] bytes, remaining [%d]", i+1, dec.Remaining())
+		}
+		b := dec.data[dec.pos+i]
+		if i == MaxSQLiteVarintLen-1 {
+			out = out<<8 | uint64(b)
+			dec.pos += MaxSQLiteVarintLen
+			break
+		}
+		out = out<<7 | uint64(b&0x7f)
+		if b&0x80 == 0 {
+			dec.pos += i + 1
+			break
+		}
+	}
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read sqlite varint", logUint64("val", out))
+	}
+	return out, nil
+}
+
+// groupVarintSizes64 are the byte lengths of the uint64 values
+// selected by the 2-bit codes of a group varint control byte.
+var groupVarintSizes64 = [4]int{1, 2, 4, 8}
+
+// groupVarintCode returns the 2-bit code and the byte length of a value.
+func groupVarintCode(v uint64, wide bool) (code byte, size int) {
+	if wide {
+		for code, size := range groupVarintSizes64 {
+			if size == 8 || v < 1<<(8*uint(size)) {
+				return byte(code), size
+			}
+		}
+	}
+	switch {
+	case v < 1<<8:
+		return 0, 1
+	case v < 1<<16:
+		return 1, 2
+	case v < 1<<24:
+		return 2, 3
+	default:
+		return 3, 4
+	}
+}
+
+// WriteGroupVarint32 writes the values in groups of four, each group being
+// a control byte holding the byte length minus one of each value (2 bits
+// per value, first value in the lowest bits), followed by the values in
+// little endian, using only the bytes they need. The last group can hold
+// fewer than four values. The number of values is not written.
+func (e *Encoder) WriteGroupVarint32(values []uint32) error {
+	wide := make([]uint64, len(values))
+	for i, v := range values {
+		wide[i] = uint64(v)
+	}
+	return e.writeGroupVarint(wide, false)
+}
+
+// WriteGroupVarint64 is like WriteGroupVarint32, but for uint64 values:
+// the 2-bit codes select a length of 1, 2, 4 or 8 bytes.
+func (e *Encoder) WriteGroupVarint64(values []uint64) error {
+	return e.writeGroupVarint(values, true)
+}
+
+func (e *Encoder) writeGroupVarint(values []uint64, wide bool) error {
+	if e.tracing() {
+		e.tlog().Debug("encode: write group varint", logInt("len", len(values)), logBool("wide", wide))
+	}
+	group := make([]byte, 1+4*8)
+	for start := 0; start < len(values); start += 4 {
+		end := start + 4
+		if end > len(values) {
+			end = len(values)
+		}
+		group[0] = 0
+		n := 1
+		for i, v := range values[start:end] {
+			code, size := groupVarintCode(v, wide)
+			group[0] |= code << (2 * uint(i))
+			var le [8]byte
+			binary.LittleEndian.PutUint64(le[:], v)
+			n += copy(group[n:], le[:size])
+		}
+		if err := e.toWriter(group[:n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadGroupVarint32 reads n values written by WriteGroupVarint32.
+func (dec *Decoder) ReadGroupVarint32(n int) ([]uint32, error) {
+	out := make([]uint32, n)
+	err := dec.readGroupVarint(n, false, func(i int, v uint64) { out[i] = uint32(v) })
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReadGroupVarint64 reads n values written by WriteGroupVarint64.
+func (dec *Decoder) ReadGroupVarint64(n int) ([]uint64, error) {
+	out := make([]uint64, n)
+	err := dec.readGroupVarint(n, true, func(i int, v uint64) { out[i] = v })
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (dec *Decoder) readGroupVarint(n int, wide bool, set func(i int, v uint64)) error {
+	if n < 0 || n > dec.Remaining() {
+		return fmt.Errorf("group varint: %d values can't fit in the remaining [%d] bytes", n, dec.Remaining())
+	}
+	for start := 0; start < n; start += 4 {
+		control, err := dec.ReadByte()
+		if err != nil {
+			return fmt.Errorf("group varint: control byte: %w", err)
+		}
+		count := n - start
+		if count > 4 {
+			count = 4
+		}
+		for i := 0; i < count; i++ {
+			code := int(control>>(2*uint(i))) & 3
+			size := code + 1
+			if wide {
+				size = groupVarintSizes64[code]
+			}
+			b, err := dec.ReadNBytes(size)
+			if err != nil {
+				return fmt.Errorf("group varint: value %d: %w", start+i, err)
+			}
+			var le [8]byte
+			copy(le[:], b)
+			set(start+i, binary.LittleEndian.Uint64(le[:]))
+		}
+	}
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read group varint", logInt("len", n), logBool("wide", wide))
+	}
+	return nil
+}
+
+// isGroupVarintType reports whether the `groupvarint` tag applies to the provided type.
+func isGroupVarintType(rt reflect.Type) bool {
+	if rt.Kind() != reflect.Slice {
+		return false
+	}
+	k := rt.Elem().Kind()
+	return k == reflect.Uint32 || k == reflect.Uint64
+}
+
+// encodeVarints handles the values affected by the `groupvarint` and `sqlitevarint` tags.
+func (e *Encoder) encodeVarints(rv reflect.Value, opt *option) (handled bool, err error) {
+	switch {
+	case opt.GroupVarint:
+		if !isGroupVarintType(rv.Type()) {
+			return true, fmt.Errorf("group varint: unsupported type %s, expected a slice of uint32 or uint64", rv.Type())
+		}
+		l := rv.Len()
+		if opt.hasSizeOfSlice() {
+			l = opt.getSizeOfSlice()
+		} else if err := e.WriteLength(l); err != nil {
+			return true, err
+		}
+		values := make([]uint64, l)
+		for i := range values {
+			values[i] = rv.Index(i).Uint()
+		}
+		return true, e.writeGroupVarint(values, rv.Type().Elem().Kind() == reflect.Uint64)
+	case opt.SQLiteVarint:
+		rt, ok := integerElemType(rv.Type())
+		if !ok {
+			return false, nil
+		}
+		if !isUnsignedKind(rt.Kind()) {
+			return true, fmt.Errorf("sqlite varint: unsupported type %s, expected an unsigned integer", rt)
+		}
+		return true, e.writeUnsigned(rv, opt, e.WriteSQLiteVarint)
+	}
+	return false, nil
+}
+
+// decodeVarints handles the values affected by the `groupvarint` and `sqlitevarint` tags.
+func (dec *Decoder) decodeVarints(rv reflect.Value, opt *option) (handled bool, err error) {
+	switch {
+	case opt.GroupVarint:
+		if !isGroupVarintType(rv.Type()) {
+			return true, fmt.Errorf("group varint: unsupported type %s, expected a slice of uint32 or uint64", rv.Type())
+		}
+		var l int
+		if opt.hasSizeOfSlice() {
+			l = opt.getSizeOfSlice()
+		} else if l, err = dec.ReadLength(); err != nil {
+			return true, err
+		}
+		out := reflect.MakeSlice(rv.Type(), l, l)
+		err := dec.readGroupVarint(l, rv.Type().Elem().Kind() == reflect.Uint64, func(i int, v uint64) {
+			out.Index(i).SetUint(v)
+		})
+		if err != nil {
+			return true, err
+		}
+		rv.Set(out)
+		return true, nil
+	case opt.SQLiteVarint:
+		rt, ok := integerElemType(rv.Type())
+		if !ok {
+			return false, nil
+		}
+		if !isUnsignedKind(rt.Kind()) {
+			return true, fmt.Errorf("sqlite varint: unsupported type %s, expected an unsigned integer", rt)
+		}
+		return true, dec.readUnsigned(rv, opt, dec.ReadSQLiteVarint)
+	}
+	return false, nil
+}
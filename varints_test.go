@@ -0,0 +1,129 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteVarint(t *testing.T) {
+	tests := []struct {
+		value   uint64
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{0x7f, []byte{0x7f}},
+		{0x80, []byte{0x81, 0x00}},
+		{0x3fff, []byte{0xff, 0x7f}},
+		{1<<56 - 1, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}},
+		{1 << 56, []byte{0x80, 0xc0, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}},
+		{math.MaxUint64, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, test := range tests {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewBinEncoder(buf).WriteSQLiteVarint(test.value))
+		assert.Equal(t, test.encoded, buf.Bytes(), "%#x", test.value)
+
+		dec := NewBinDecoder(test.encoded)
+		v, err := dec.ReadSQLiteVarint()
+		require.NoError(t, err)
+		assert.Equal(t, test.value, v)
+		assert.Equal(t, 0, dec.Remaining())
+	}
+
+	_, err := NewBinDecoder([]byte{0x81, 0x80}).ReadSQLiteVarint()
+	assert.EqualError(t, err, "sqlite varint: required [3] bytes, remaining [2]")
+}
+
+func TestGroupVarint(t *testing.T) {
+	buf := new(bytes.Buffer)
+	values32 := []uint32{1, 256, 65536, 1 << 24, 5}
+	require.NoError(t, NewBinEncoder(buf).WriteGroupVarint32(values32))
+	assert.Equal(t, []byte{
+		0xe4, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05,
+	}, buf.Bytes())
+	out32, err := NewBinDecoder(buf.Bytes()).ReadGroupVarint32(len(values32))
+	require.NoError(t, err)
+	assert.Equal(t, values32, out32)
+
+	buf.Reset()
+	values64 := []uint64{1, 300, 1 << 40}
+	require.NoError(t, NewBinEncoder(buf).WriteGroupVarint64(values64))
+	assert.Equal(t, []byte{
+		0x34, 0x01, 0x2c, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00,
+	}, buf.Bytes())
+	out64, err := NewBinDecoder(buf.Bytes()).ReadGroupVarint64(len(values64))
+	require.NoError(t, err)
+	assert.Equal(t, values64, out64)
+
+	_, err = NewBinDecoder(buf.Bytes()[:5]).ReadGroupVarint64(len(values64))
+	assert.Error(t, err)
+	_, err = NewBinDecoder(buf.Bytes()).ReadGroupVarint64(100)
+	assert.EqualError(t, err, "group varint: 100 values can't fit in the remaining [12] bytes")
+}
+
+func TestVarints_Tags(t *testing.T) {
+	type column struct {
+		IDs     []uint32 `bin:"groupvarint"`
+		Offsets []uint64 `bin:"groupvarint"`
+		Rowid   uint64   `bin:"sqlitevarint"`
+		Sizes   []uint16 `bin:"sqlitevarint"`
+		Count   uint8    `bin:"sizeof=Ticks"`
+		Ticks   []uint32 `bin:"groupvarint"`
+	}
+	in := column{
+		IDs:     []uint32{1, 2, 300},
+		Offsets: []uint64{1 << 33},
+		Rowid:   0x80,
+		Sizes:   []uint16{1, 0x3fff},
+		Count:   1,
+		Ticks:   []uint32{7},
+	}
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(in))
+		data := buf.Bytes()
+		if enc.IsBin() {
+			assert.Equal(t, []byte{
+				0x03, 0x10, 0x01, 0x02, 0x2c, 0x01,
+				0x01, 0x03, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00,
+				0x81, 0x00,
+				0x02, 0x01, 0xff, 0x7f,
+				0x01,
+				0x00, 0x07,
+			}, data)
+		}
+
+		var out column
+		require.NoError(t, NewDecoderWithEncoding(data, enc).Decode(&out))
+		assert.Equal(t, in, out)
+		assert.NoError(t, ConformsWithEncoding(data, enc, out))
+		for i := 0; i < len(data); i++ {
+			assert.Error(t, ConformsWithEncoding(data[:i], enc, out), "%s truncated to %d bytes", enc, i)
+		}
+	}
+
+	type invalid struct {
+		Values []int32 `bin:"groupvarint"`
+	}
+	_, err := MarshalBin(invalid{})
+	assert.EqualError(t, err, `error while encoding "Values" field: group varint: unsupported type []int32, expected a slice of uint32 or uint64`)
+}
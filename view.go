@@ -0,0 +1,302 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// A View reads the fields of an encoded struct in place, without decoding
+// the struct: creating it walks the encoded fields once, with the same bounds
+// checks as Conforms, to find where each of them is, and its getters then read
+// a field directly from the data, e.g. for read-mostly consumers that only
+// look at a few fields of large values.
+//
+// Views are built at run time from the layout of the struct type, the
+// one described by ExplainType, and their getters check the type of the field.
+// The byte slices they return alias the data.
+//
+//	view, err := bin.NewView(data, Transfer{})
+//	amount, err := view.Uint64("Amount")
+type View struct {
+	data     []byte
+	encoding Encoding
+	layout   *layoutNode
+	spans    []fieldSpan
+}
+
+// NewView returns a view over Bin-encoded data of a struct of the type
+// of `typ` (a value or a pointer to a value).
+func NewView(data []byte, typ interface{}) (*View, error) {
+	return NewViewWithEncoding(data, EncodingBin, typ)
+}
+
+// NewViewWithEncoding is like NewView, but for data encoded with the provided encoding.
+func NewViewWithEncoding(data []byte, enc Encoding, typ interface{}) (*View, error) {
+	rt := reflect.TypeOf(typ)
+	if rt == nil {
+		return nil, fmt.Errorf("view: nil type")
+	}
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if !isValidEncoding(enc) {
+		return nil, fmt.Errorf("view: invalid encoding %d", enc)
+	}
+	layout, err := cachedLayout(rt, enc)
+	if err != nil {
+		return nil, fmt.Errorf("view: %s: %w", rt, err)
+	}
+	if layout.Wire != wireStruct {
+		return nil, fmt.Errorf("view: %s is not a struct", rt)
+	}
+	return newView(data, enc, layout)
+}
+
+func newView(data []byte, enc Encoding, layout *layoutNode) (*View, error) {
+	dec := NewDecoderWithEncoding(data, enc)
+	fields, spans, err := dec.walkStruct(layout, true)
+	if err != nil {
+		return nil, fmt.Errorf("view: %s: %w", layout.Type, err)
+	}
+	return &View{
+		data:     data[:dec.pos],
+		encoding: enc,
+		layout:   fields,
+		spans:    spans,
+	}, nil
+}
+
+// Size returns the encoded size of the struct.
+func (v *View) Size() int {
+	return len(v.data)
+}
+
+// field returns the layout of a field and its encoded bytes,
+// without its presence flags; present is false for absent optional
+// fields and missing binary extensions.
+func (v *View) field(name string) (n *layoutNode, data []byte, present bool, err error) {
+	for i, field := range v.layout.Fields {
+		if field.Name != name {
+			continue
+		}
+		span := v.spans[i]
+		if !span.present {
+			return field, nil, false, nil
+		}
+		data = v.data[span.start:span.end]
+		for level := 0; field.Presence != presenceNone && level < field.levels(); level++ {
+			var flag uint32
+			switch field.Presence {
+			case presenceUint8:
+				flag, data = uint32(data[0]), data[1:]
+			case presenceUint32:
+				flag, data = binary.LittleEndian.Uint32(data), data[4:]
+			}
+			if flag == 0 {
+				return field, data, false, nil
+			}
+		}
+		return field, data, true, nil
+	}
+	return nil, nil, false, fmt.Errorf("view: %s has no field %q", v.layout.Type, name)
+}
+
+// value returns the encoded bytes of a present field.
+func (v *View) value(name string) (*layoutNode, []byte, error) {
+	n, data, present, err := v.field(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !present {
+		return nil, nil, fmt.Errorf("view: field %q is absent", name)
+	}
+	return n, data, nil
+}
+
+// Has reports whether the struct has a field of the provided name that's
+// present in the data: optional fields can be absent, and binary extensions missing.
+func (v *View) Has(name string) bool {
+	_, _, present, err := v.field(name)
+	return err == nil && present
+}
+
+// Raw returns the encoded bytes of a field, without its presence flags.
+func (v *View) Raw(name string) ([]byte, error) {
+	_, data, err := v.value(name)
+	return data, err
+}
+
+// scalar returns the value of an integer, boolean or float field of the
+// provided wire kind and size (any size if 0), as an unsigned integer.
+func (v *View) scalar(name string, wire wireKind, size int) (uint64, error) {
+	n, data, err := v.value(name)
+	if err != nil {
+		return 0, err
+	}
+	if n.Wire != wire || (size > 0 && n.valueSize() != size && int(n.Type.Size()) != size) {
+		return 0, fmt.Errorf("view: field %q is a %s", name, n.Type)
+	}
+	size = n.valueSize()
+	var u uint64
+	switch size {
+	case 1:
+		u = uint64(data[0])
+	case 2:
+		u = uint64(n.Order.Uint16(data))
+	case 4:
+		u = uint64(n.Order.Uint32(data))
+	case 8:
+		u = n.Order.Uint64(data)
+	default:
+		// An integer with a `width=N` tag.
+		u = uintN(data[:size], n.Order)
+	}
+	u = swapBits(u, size, false, n.BitReverse)
+	if wire == wireInt && size < TypeSize.Uint64 {
+		// Sign-extend the value.
+		shift := 64 - 8*uint(size)
+		u = uint64(int64(u<<shift) >> shift)
+	}
+	return u, nil
+}
+
+// Bool returns the value of a bool field, of any `boolwidth=N`.
+func (v *View) Bool(name string) (bool, error) {
+	u, err := v.scalar(name, wireBool, 0)
+	return u != 0, err
+}
+
+// Uint8 returns the value of a uint8 field.
+func (v *View) Uint8(name string) (uint8, error) {
+	u, err := v.scalar(name, wireUint, TypeSize.Uint8)
+	return uint8(u), err
+}
+
+// Uint16 returns the value of a uint16 field.
+func (v *View) Uint16(name string) (uint16, error) {
+	u, err := v.scalar(name, wireUint, TypeSize.Uint16)
+	return uint16(u), err
+}
+
+// Uint32 returns the value of a uint32 field.
+func (v *View) Uint32(name string) (uint32, error) {
+	u, err := v.scalar(name, wireUint, TypeSize.Uint32)
+	return uint32(u), err
+}
+
+// Uint64 returns the value of a uint64 field.
+func (v *View) Uint64(name string) (uint64, error) {
+	return v.scalar(name, wireUint, TypeSize.Uint64)
+}
+
+// Int8 returns the value of an int8 field.
+func (v *View) Int8(name string) (int8, error) {
+	u, err := v.scalar(name, wireInt, TypeSize.Int8)
+	return int8(u), err
+}
+
+// Int16 returns the value of an int16 field.
+func (v *View) Int16(name string) (int16, error) {
+	u, err := v.scalar(name, wireInt, TypeSize.Int16)
+	return int16(u), err
+}
+
+// Int32 returns the value of an int32 field.
+func (v *View) Int32(name string) (int32, error) {
+	u, err := v.scalar(name, wireInt, TypeSize.Uint32)
+	return int32(u), err
+}
+
+// Int64 returns the value of an int64 field.
+func (v *View) Int64(name string) (int64, error) {
+	u, err := v.scalar(name, wireInt, TypeSize.Uint64)
+	return int64(u), err
+}
+
+// Float16 returns the value of a Float16 field.
+func (v *View) Float16(name string) (Float16, error) {
+	u, err := v.scalar(name, wireFloat, TypeSize.Float16)
+	return Float16(u), err
+}
+
+// Float32 returns the value of a float32 field.
+func (v *View) Float32(name string) (float32, error) {
+	u, err := v.scalar(name, wireFloat, TypeSize.Float32)
+	return math.Float32frombits(uint32(u)), err
+}
+
+// Float64 returns the value of a float64 field.
+func (v *View) Float64(name string) (float64, error) {
+	u, err := v.scalar(name, wireFloat, TypeSize.Float64)
+	return math.Float64frombits(u), err
+}
+
+// Bytes returns the content of a byte slice, byte array or string
+// field, without its length prefix.
+func (v *View) Bytes(name string) ([]byte, error) {
+	n, data, err := v.value(name)
+	if err != nil {
+		return nil, err
+	}
+	if n.Wire != wireBytes && n.Wire != wireString {
+		return nil, fmt.Errorf("view: field %q is a %s", name, n.Type)
+	}
+	if n.Prefix == prefixNone || n.Prefix == prefixSizeOf {
+		return data, nil
+	}
+	if n.Prefix == prefixNUL {
+		return data[:len(data)-1], nil
+	}
+	dec := Decoder{data: data, encoding: v.encoding}
+	l, err := dec.readLength(n, nil)
+	if err != nil {
+		return nil, fmt.Errorf("view: field %q: %w", name, err)
+	}
+	return data[dec.pos : dec.pos+l], nil
+}
+
+// String returns the value of a string field.
+func (v *View) String(name string) (string, error) {
+	n, _, err := v.value(name)
+	if err != nil {
+		return "", err
+	}
+	if n.Wire != wireString {
+		return "", fmt.Errorf("view: field %q is a %s", name, n.Type)
+	}
+	data, err := v.Bytes(name)
+	if n.Prefix == prefixNone {
+		// A `strlen=N` string, padded with zeros.
+		data = bytes.TrimRight(data, "\x00")
+	}
+	return string(data), err
+}
+
+// Struct returns a view over a struct field.
+func (v *View) Struct(name string) (*View, error) {
+	n, data, err := v.value(name)
+	if err != nil {
+		return nil, err
+	}
+	if n.Wire != wireStruct {
+		return nil, fmt.Errorf("view: field %q is a %s", name, n.Type)
+	}
+	return newView(data, v.encoding, n)
+}
@@ -0,0 +1,150 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type viewHeader struct {
+	Version uint8
+	Flags   uint16 `bin:"big"`
+}
+
+type viewRecord struct {
+	Header  viewHeader
+	ID      int64
+	Name    string
+	Count   uint8 `bin:"sizeof=Data"`
+	Data    []byte
+	Key     [4]byte
+	Score   float64
+	Active  bool
+	Parent  *uint32 `bin:"optional"`
+	Missing *uint32 `bin:"optional"`
+	Memo    string  `bin:"binary_extension"`
+}
+
+func TestView(t *testing.T) {
+	parent := uint32(7)
+	in := viewRecord{
+		Header: viewHeader{Version: 2, Flags: 0x0102},
+		ID:     -42,
+		Name:   "alice",
+		Count:  3,
+		Data:   []byte{1, 2, 3},
+		Key:    [4]byte{9, 8, 7, 6},
+		Score:  1.5,
+		Active: true,
+		Parent: &parent,
+	}
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&in), enc)
+		// Without the memo extension.
+		data := buf.Bytes()[:buf.Len()-extensionSize(enc)]
+		view, err := NewViewWithEncoding(data, enc, &viewRecord{})
+		require.NoError(t, err, enc)
+		assert.Equal(t, len(data), view.Size(), enc)
+
+		header, err := view.Struct("Header")
+		require.NoError(t, err, enc)
+		version, err := header.Uint8("Version")
+		require.NoError(t, err, enc)
+		assert.Equal(t, uint8(2), version, enc)
+		flags, err := header.Uint16("Flags")
+		require.NoError(t, err, enc)
+		assert.Equal(t, uint16(0x0102), flags, enc)
+
+		id, err := view.Int64("ID")
+		require.NoError(t, err, enc)
+		assert.Equal(t, int64(-42), id, enc)
+		name, err := view.String("Name")
+		require.NoError(t, err, enc)
+		assert.Equal(t, "alice", name, enc)
+		payload, err := view.Bytes("Data")
+		require.NoError(t, err, enc)
+		assert.Equal(t, []byte{1, 2, 3}, payload, enc)
+		key, err := view.Bytes("Key")
+		require.NoError(t, err, enc)
+		assert.Equal(t, []byte{9, 8, 7, 6}, key, enc)
+		score, err := view.Float64("Score")
+		require.NoError(t, err, enc)
+		assert.Equal(t, 1.5, score, enc)
+		active, err := view.Bool("Active")
+		require.NoError(t, err, enc)
+		assert.True(t, active, enc)
+
+		assert.True(t, view.Has("Parent"), enc)
+		p, err := view.Uint32("Parent")
+		require.NoError(t, err, enc)
+		assert.Equal(t, uint32(7), p, enc)
+		assert.False(t, view.Has("Missing"), enc)
+		_, err = view.Uint32("Missing")
+		assert.EqualError(t, err, `view: field "Missing" is absent`, enc)
+		assert.False(t, view.Has("Memo"), enc)
+	}
+}
+
+// extensionSize is the size of the empty Memo of viewRecord.
+func extensionSize(enc Encoding) int {
+	switch enc {
+	case EncodingBin:
+		return 8
+	case EncodingBorsh:
+		return 4
+	default:
+		return 1
+	}
+}
+
+func TestView_Errors(t *testing.T) {
+	data := mustMarshalBin(t, viewHeader{Version: 1})
+	view, err := NewView(append(data, 0xff), viewHeader{})
+	require.NoError(t, err)
+	// Trailing bytes are not part of the view.
+	assert.Equal(t, 3, view.Size())
+
+	_, err = view.Uint32("Version")
+	assert.EqualError(t, err, `view: field "Version" is a uint8`)
+	_, err = view.Uint8("Nope")
+	assert.EqualError(t, err, `view: bin.viewHeader has no field "Nope"`)
+
+	_, err = NewView(data[:2], viewHeader{})
+	assert.Error(t, err)
+	_, err = NewView(data, uint8(0))
+	assert.EqualError(t, err, "view: uint8 is not a struct")
+}
+
+func BenchmarkView(b *testing.B) {
+	in := viewRecord{Name: "alice", Count: 3, Data: []byte{1, 2, 3}, Score: 1.5}
+	data, err := MarshalBin(in)
+	require.NoError(b, err)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		view, err := NewView(data, viewRecord{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := view.Float64("Score"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// MaxVLQLen64 is the maximum length of a VLQ-encoded 64-bit integer.
+const MaxVLQLen64 = 10
+
+var ErrVLQOverflow = errors.New("vlq: value overflows a 64-bit integer")
+
+// WriteVLQ writes a variable-length quantity: the 7-bit groups of the value,
+// most significant first, with the high bit set on all bytes but the last,
+// as in MIDI files. It's the big-endian counterpart of WriteUVarInt.
+func (e *Encoder) WriteVLQ(v uint64) (err error) {
+	if e.tracing() {
+		e.tlog().Debug("encode: write vlq", logUint64("val", v))
+	}
+	buf := make([]byte, MaxVLQLen64)
+	i := len(buf) - 1
+	buf[i] = byte(v & 0x7f)
+	for v >>= 7; v != 0; v >>= 7 {
+		i--
+		buf[i] = byte(v&0x7f) | 0x80
+	}
+	return e.toWriter(buf[i:])
+}
+
+// ReadVLQ reads a variable-length quantity written by WriteVLQ.
+func (dec *Decoder) ReadVLQ() (out uint64, err error) {
+	dec.fill(MaxVLQLen64)
+	for i := 0; ; i++ {
+		if dec.pos+i >= len(dec.data) {
+			return 0, fmt.Errorf("vlq: required [%d] bytes, remaining [%d]", i+1, dec.Remaining())
+		}
+		if out > (1<<64-1)>>7 {
+			return 0, ErrVLQOverflow
+		}
+		b := dec.data[dec.pos+i]
+		out = out<<7 | uint64(b&0x7f)
+		if b&0x80 == 0 {
+			dec.pos += i + 1
+			break
+		}
+	}
+	if dec.tracing() {
+		dec.tlog().Debug("decode: read vlq", logUint64("val", out))
+	}
+	return out, nil
+}
+
+func isUnsignedKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+// integerElemType returns the type of the integers of the provided type,
+// which is either an integer type or a slice or an array of integers.
+func integerElemType(rt reflect.Type) (reflect.Type, bool) {
+	if rt.Kind() == reflect.Slice || rt.Kind() == reflect.Array {
+		rt = rt.Elem()
+	}
+	return rt, isIntegerKind(rt.Kind())
+}
+
+// encodeVLQ handles the values affected by the `vlq` tag.
+func (e *Encoder) encodeVLQ(rv reflect.Value, opt *option) (handled bool, err error) {
+	if !opt.VLQ {
+		return false, nil
+	}
+	rt, ok := integerElemType(rv.Type())
+	if !ok {
+		return false, nil
+	}
+	if !isUnsignedKind(rt.Kind()) {
+		return true, fmt.Errorf("vlq: unsupported type %s, expected an unsigned integer", rt)
+	}
+	return true, e.writeUnsigned(rv, opt, e.WriteVLQ)
+}
+
+// writeUnsigned writes an unsigned integer, or the elements of a slice
+// or an array of unsigned integers, with the provided function.
+func (e *Encoder) writeUnsigned(rv reflect.Value, opt *option, write func(uint64) error) error {
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return write(rv.Uint())
+	}
+	l := rv.Len()
+	if rv.Kind() == reflect.Slice {
+		if opt.hasSizeOfSlice() {
+			l = opt.getSizeOfSlice()
+		} else if err := e.WriteLength(l); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < l; i++ {
+		if err := write(rv.Index(i).Uint()); err != nil {
+			return newElementError("encoding", i, err)
+		}
+	}
+	return nil
+}
+
+// decodeVLQ handles the values affected by the `vlq` tag.
+func (dec *Decoder) decodeVLQ(rv reflect.Value, opt *option) (handled bool, err error) {
+	if !opt.VLQ {
+		return false, nil
+	}
+	rt, ok := integerElemType(rv.Type())
+	if !ok {
+		return false, nil
+	}
+	if !isUnsignedKind(rt.Kind()) {
+		return true, fmt.Errorf("vlq: unsupported type %s, expected an unsigned integer", rt)
+	}
+	return true, dec.readUnsigned(rv, opt, dec.ReadVLQ)
+}
+
+// readUnsigned reads an unsigned integer, or the elements of a slice
+// or an array of unsigned integers, with the provided function.
+// Each integer is expected to take at least one byte.
+func (dec *Decoder) readUnsigned(rv reflect.Value, opt *option, read func() (uint64, error)) (err error) {
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return dec.readUnsignedInto(rv, read)
+	}
+	if rv.Kind() == reflect.Array {
+		for i := 0; i < rv.Len(); i++ {
+			if err := dec.readUnsignedInto(rv.Index(i), read); err != nil {
+				return newElementError("decoding", i, err)
+			}
+		}
+		return nil
+	}
+
+	var l int
+	if opt.hasSizeOfSlice() {
+		l = opt.getSizeOfSlice()
+	} else if l, err = dec.ReadLength(); err != nil {
+		return err
+	}
+	if l > dec.Remaining() {
+		return fmt.Errorf("length %d exceeds the remaining [%d] bytes", l, dec.Remaining())
+	}
+	out := reflect.MakeSlice(rv.Type(), l, l)
+	for i := 0; i < l; i++ {
+		if err := dec.readUnsignedInto(out.Index(i), read); err != nil {
+			return newElementError("decoding", i, err)
+		}
+	}
+	rv.Set(out)
+	return nil
+}
+
+func (dec *Decoder) readUnsignedInto(rv reflect.Value, read func() (uint64, error)) error {
+	v, err := read()
+	if err != nil {
+		return err
+	}
+	if rv.OverflowUint(v) {
+		return fmt.Errorf("value %d overflows %s", v, rv.Type())
+	}
+	rv.SetUint(v)
+	return nil
+}
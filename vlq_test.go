@@ -0,0 +1,119 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVLQ(t *testing.T) {
+	tests := []struct {
+		value   uint64
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{0x40, []byte{0x40}},
+		{0x7f, []byte{0x7f}},
+		{0x80, []byte{0x81, 0x00}},
+		{0x2000, []byte{0xc0, 0x00}},
+		{0x3fff, []byte{0xff, 0x7f}},
+		{0x4000, []byte{0x81, 0x80, 0x00}},
+		{0x0fffffff, []byte{0xff, 0xff, 0xff, 0x7f}},
+		{math.MaxUint64, []byte{0x81, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}},
+	}
+	for _, test := range tests {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewBinEncoder(buf).WriteVLQ(test.value))
+		assert.Equal(t, test.encoded, buf.Bytes())
+
+		dec := NewBinDecoder(test.encoded)
+		v, err := dec.ReadVLQ()
+		require.NoError(t, err)
+		assert.Equal(t, test.value, v)
+		assert.Equal(t, 0, dec.Remaining())
+	}
+
+	_, err := NewBinDecoder([]byte{0x82, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}).ReadVLQ()
+	assert.Equal(t, ErrVLQOverflow, err)
+	_, err = NewBinDecoder([]byte{0x81, 0x80}).ReadVLQ()
+	assert.EqualError(t, err, "vlq: required [3] bytes, remaining [2]")
+}
+
+func TestVLQ_Tag(t *testing.T) {
+	type event struct {
+		Delta  uint32   `bin:"vlq"`
+		Count  uint16   `bin:"vlq sizeof=Ticks"`
+		Ticks  []uint64 `bin:"vlq"`
+		Notes  [2]uint8 `bin:"vlq"`
+		Values []uint16 `bin:"vlq"`
+		Plain  uint16
+	}
+	in := event{
+		Delta:  0x80,
+		Count:  2,
+		Ticks:  []uint64{1, 0x4000},
+		Notes:  [2]uint8{0x7f, 0xff},
+		Values: []uint16{0x3fff},
+		Plain:  1,
+	}
+	expected := map[Encoding][]byte{
+		EncodingBin: {
+			0x81, 0x00,
+			0x02,
+			0x01, 0x81, 0x80, 0x00,
+			0x7f, 0x81, 0x7f,
+			0x01, 0xff, 0x7f,
+			0x01, 0x00,
+		},
+		EncodingBorsh: {
+			0x81, 0x00,
+			0x02,
+			0x01, 0x81, 0x80, 0x00,
+			0x7f, 0x81, 0x7f,
+			0x01, 0x00, 0x00, 0x00, 0xff, 0x7f,
+			0x01, 0x00,
+		},
+	}
+	for enc, data := range expected {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(in))
+		assert.Equal(t, data, buf.Bytes(), enc)
+
+		var out event
+		require.NoError(t, NewDecoderWithEncoding(data, enc).Decode(&out))
+		assert.Equal(t, in, out)
+		assert.NoError(t, ConformsWithEncoding(data, enc, out))
+		assert.Error(t, ConformsWithEncoding(data[:len(data)-3], enc, out))
+	}
+
+	// Values that don't fit in the field are rejected.
+	type small struct {
+		V uint8 `bin:"vlq"`
+	}
+	var out small
+	err := NewBinDecoder([]byte{0x82, 0x00}).Decode(&out)
+	assert.EqualError(t, err, `error while decoding "V" field: value 256 overflows uint8`)
+
+	type signed struct {
+		V int32 `bin:"vlq"`
+	}
+	_, err = MarshalBin(signed{V: 1})
+	assert.EqualError(t, err, `error while encoding "V" field: vlq: unsupported type int32, expected an unsigned integer`)
+}
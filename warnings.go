@@ -0,0 +1,79 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import "fmt"
+
+// A Warning is a non-fatal anomaly found while decoding:
+//   - a string truncated by a `maxlen=N,truncate` tag.
+//   - a varint that isn't minimally encoded, outside of canonical mode.
+//   - a `binary_extension` field missing from the data, left to its zero value.
+//   - an invalid value of an integer enum, kept by a decoder with lenient enums.
+type Warning struct {
+	// Field is the path of the struct field the warning
+	// is about, e.g. "Items.Name", if any.
+	Field   string
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.Field == "" {
+		return w.Message
+	}
+	return w.Field + ": " + w.Message
+}
+
+// Warnings returns the warnings found since the decoder was created or
+// last reset, in the order they were found, e.g. for pipelines that
+// monitor the quality of the data they decode, or for data-migration
+// jobs that keep going over dirty data but report what they changed.
+func (dec *Decoder) Warnings() []Warning {
+	if dec.warnings == nil {
+		return nil
+	}
+	return *dec.warnings
+}
+
+func (dec *Decoder) warnf(format string, args ...interface{}) {
+	if dec.warnings == nil {
+		dec.warnings = new([]Warning)
+	}
+	*dec.warnings = append(*dec.warnings, Warning{Message: fmt.Sprintf(format, args...)})
+}
+
+func (dec *Decoder) warnFieldf(field string, format string, args ...interface{}) {
+	dec.warnf(format, args...)
+	(*dec.warnings)[len(*dec.warnings)-1].Field = field
+}
+
+func (dec *Decoder) warningCount() int {
+	if dec.warnings == nil {
+		return 0
+	}
+	return len(*dec.warnings)
+}
+
+// nameWarnings prefixes the field path of the warnings
+// found since the provided count with a struct field name.
+func (dec *Decoder) nameWarnings(from int, name string) {
+	warnings := *dec.warnings
+	for i := from; i < len(warnings); i++ {
+		if warnings[i].Field == "" {
+			warnings[i].Field = name
+		} else {
+			warnings[i].Field = name + "." + warnings[i].Field
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoder_Warnings(t *testing.T) {
+	type inner struct {
+		Color testColor `bin:"enum"`
+		Extra uint16    `bin:"binary_extension"`
+	}
+	type outer struct {
+		Count uint64
+		Inner inner
+	}
+	// An invalid color, and no extension.
+	data := []byte{1, 0, 0, 0, 0, 0, 0, 0, 7}
+
+	var got outer
+	dec := NewBinDecoder(data)
+	err := dec.Decode(&got)
+	var enumErr *InvalidEnumValueError
+	assert.True(t, errors.As(err, &enumErr))
+
+	dec = NewBinDecoder(data).WithLenientEnums()
+	require.NoError(t, dec.Decode(&got))
+	assert.Equal(t, outer{Count: 1, Inner: inner{Color: 7}}, got)
+	assert.Equal(t, []Warning{
+		{Field: "Inner.Color", Message: "invalid value 7 for enum bin.testColor"},
+		{Field: "Inner.Extra", Message: "missing binary extension, left to its zero value"},
+	}, dec.Warnings())
+	assert.Equal(t, "Inner.Color: invalid value 7 for enum bin.testColor", dec.Warnings()[0].String())
+}
+
+func TestDecoder_NonMinimalVarint(t *testing.T) {
+	data := []byte{0x81, 0x80, 0x00}
+
+	dec := NewBinDecoder(data)
+	v, err := dec.ReadUvarint64()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), v)
+	assert.Equal(t, []Warning{{Message: "non-minimal varint 81 80 00"}}, dec.Warnings())
+
+	_, err = NewBinDecoder(data).WithCanonicalMode().ReadUvarint64()
+	assert.True(t, errors.Is(err, ErrNonMinimalVarint))
+	assert.EqualError(t, err, "non-minimal varint: 81 80 00")
+
+	dec = NewBinDecoder([]byte{0x80, 0x01, 0x00})
+	_, err = dec.ReadVarint64()
+	require.NoError(t, err)
+	assert.Empty(t, dec.Warnings())
+}
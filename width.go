@@ -0,0 +1,118 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+// widthOrder returns the byte order of the values of a field with
+// a `width=N` tag; Borsh is always little endian.
+func widthOrder(enc Encoding, opt *option) binary.ByteOrder {
+	if enc.IsBorsh() {
+		return LE
+	}
+	return opt.Order
+}
+
+// boolWidth returns the encoded size in bytes of a bool
+// with the provided options: 1 unless it has a `boolwidth=N` tag.
+func boolWidth(opt *option) int {
+	if opt == nil || opt.BoolWidth == 0 {
+		return TypeSize.Bool
+	}
+	return opt.BoolWidth
+}
+
+// boolOptions returns the width and byte order of the current bool field.
+func boolOptions(enc Encoding, opt *option) (width int, order binary.ByteOrder) {
+	width, order = boolWidth(opt), defaultByteOrder
+	if opt != nil && opt.Order != nil {
+		order = widthOrder(enc, opt)
+	}
+	return
+}
+
+func isBoolOrPtr(rt reflect.Type) bool {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	return rt.Kind() == reflect.Bool
+}
+
+// isPlatformIntKind returns whether the provided kind is int or uint,
+// whose size depends on the platform.
+func isPlatformIntKind(k reflect.Kind) bool {
+	return k == reflect.Int || k == reflect.Uint
+}
+
+// errNoWidth is the error of int and uint values without a width tag,
+// which would otherwise be encoded differently on 32-bit and 64-bit platforms.
+func errNoWidth(rt reflect.Type) error {
+	return fmt.Errorf("%s has a platform-dependent size; tag the field `bin:\"width=4\"` or `bin:\"width=8\"`", rt)
+}
+
+// encodeWidthInt writes an integer field with a `width=N` tag as an N-byte
+// integer, in the byte order of the field; int and uint fields must have one.
+func (e *Encoder) encodeWidthInt(rv reflect.Value, opt *option) (bool, error) {
+	if isPlatformIntKind(rv.Kind()) && opt.Width == 0 {
+		return true, errNoWidth(rv.Type())
+	}
+	if opt.Width == 0 || !isWidthIntType(rv.Type()) || rv.Type() == durationType {
+		// Durations are scaled to their unit first.
+		return false, nil
+	}
+	order := widthOrder(e.encoding, opt)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true, e.WriteIntN(rv.Int(), opt.Width, order)
+	default:
+		return true, e.WriteUintN(rv.Uint(), opt.Width, order)
+	}
+}
+
+func (dec *Decoder) decodeWidthInt(rv reflect.Value, opt *option) (bool, error) {
+	if isPlatformIntKind(rv.Kind()) && opt.Width == 0 {
+		return true, errNoWidth(rv.Type())
+	}
+	if opt.Width == 0 || !isWidthIntType(rv.Type()) || rv.Type() == durationType {
+		// Durations are scaled to their unit first.
+		return false, nil
+	}
+	order := widthOrder(dec.encoding, opt)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := dec.ReadIntN(opt.Width, order)
+		if err != nil {
+			return true, err
+		}
+		if rv.OverflowInt(v) {
+			return true, fmt.Errorf("value %d overflows %s", v, rv.Type())
+		}
+		rv.SetInt(v)
+	default:
+		v, err := dec.ReadUintN(opt.Width, order)
+		if err != nil {
+			return true, err
+		}
+		if rv.OverflowUint(v) {
+			return true, fmt.Errorf("value %d overflows %s", v, rv.Type())
+		}
+		rv.SetUint(v)
+	}
+	return true, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type platformInts struct {
+	Count  int   `bin:"width=4"`
+	Total  int   `bin:"width=8 big"`
+	Index  uint  `bin:"width=4 big"`
+	Offset uint  `bin:"width=8"`
+	Limit  *int  `bin:"optional width=4"`
+	Extra  *uint `bin:"optional width=8"`
+}
+
+func TestPlatformInt(t *testing.T) {
+	limit := -5
+	in := platformInts{Count: -1, Total: 1 << 40, Index: 7, Offset: 1 << 33, Limit: &limit}
+
+	data := mustMarshalBin(t, in)
+	assert.Equal(t, []byte{
+		0xff, 0xff, 0xff, 0xff,
+		0, 0, 1, 0, 0, 0, 0, 0,
+		0, 0, 0, 7,
+		0, 0, 0, 0, 2, 0, 0, 0,
+		1, 0, 0, 0, 0xfb, 0xff, 0xff, 0xff,
+		0, 0, 0, 0,
+	}, data)
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&in), enc)
+
+		var out platformInts
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out), enc)
+		assert.Equal(t, in, out, enc)
+	}
+	require.NoError(t, Precompile(platformInts{}))
+}
+
+func TestPlatformInt_Errors(t *testing.T) {
+	_, err := MarshalBin(platformInts{Count: math.MaxInt32 + 1})
+	assert.EqualError(t, err, `error while encoding "Count" field: value 2147483648 overflows width=4`)
+
+	type untagged struct {
+		N int
+	}
+	_, err = MarshalBin(untagged{N: 1})
+	assert.EqualError(t, err, "error while encoding \"N\" field: int has a platform-dependent size; tag the field `bin:\"width=4\"` or `bin:\"width=8\"`")
+	assert.EqualError(t, Precompile(untagged{}), "precompile: bin.untagged: field \"N\": int has a platform-dependent size; tag the field `bin:\"width=4\"` or `bin:\"width=8\"`")
+
+	type tooWide struct {
+		N uint `bin:"width=9"`
+	}
+	assert.Error(t, Precompile(tooWide{}))
+}
+
+type cBools struct {
+	Ready   bool  `bin:"boolwidth=4"`
+	Closed  bool  `bin:"boolwidth=2 big"`
+	Flag    Bool  `bin:"boolwidth=4"`
+	Default bool  `bin:"boolwidth=1"`
+	Maybe   *bool `bin:"optional boolwidth=4"`
+}
+
+func TestBoolWidth(t *testing.T) {
+	yes := true
+	in := cBools{Ready: true, Closed: true, Flag: true, Maybe: &yes}
+
+	data := mustMarshalBin(t, in)
+	assert.Equal(t, []byte{
+		1, 0, 0, 0,
+		0, 1,
+		1, 0, 0, 0,
+		0,
+		1, 0, 0, 0, 1, 0, 0, 0,
+	}, data)
+
+	for _, enc := range []Encoding{EncodingBin, EncodingBorsh, EncodingCompactU16} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, NewEncoderWithEncoding(buf, enc).Encode(&in), enc)
+
+		var out cBools
+		require.NoError(t, NewDecoderWithEncoding(buf.Bytes(), enc).Decode(&out), enc)
+		assert.Equal(t, in, out, enc)
+	}
+	require.NoError(t, Precompile(cBools{}))
+
+	view, err := NewView(data, cBools{})
+	require.NoError(t, err)
+	closed, err := view.Bool("Closed")
+	require.NoError(t, err)
+	assert.True(t, closed)
+}
+
+func TestBoolWidth_ReadWrite(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewBinEncoder(buf)
+	require.NoError(t, enc.WriteBoolWidth(true, 2, BE))
+	require.NoError(t, enc.WriteBoolWidth(false, 4, LE))
+	assert.EqualError(t, enc.WriteBoolWidth(true, 3, LE), "invalid bool width 3")
+	assert.Equal(t, []byte{0, 1, 0, 0, 0, 0}, buf.Bytes())
+
+	dec := NewBinDecoder([]byte{0, 2, 0, 0, 0, 0})
+	b, err := dec.ReadBoolWidth(2, BE)
+	require.NoError(t, err)
+	assert.True(t, b)
+	b, err = dec.ReadBoolWidth(4, LE)
+	require.NoError(t, err)
+	assert.False(t, b)
+	_, err = dec.ReadBoolWidth(4, LE)
+	assert.Error(t, err)
+}
+
+func TestBoolWidth_Errors(t *testing.T) {
+	type notBool struct {
+		N uint32 `bin:"boolwidth=4"`
+	}
+	assert.EqualError(t, Precompile(notBool{}), "precompile: bin.notBool: field \"N\": the boolwidth tag only applies to bool, got uint32")
+	assert.Equal(t, []string{"boolwidth=3"}, parseFieldTag(`bin:"boolwidth=3"`).Invalid)
+}
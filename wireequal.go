@@ -0,0 +1,233 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"reflect"
+)
+
+// EqualWire reports whether a and b, two values of the same type, have the
+// same wire content: only the fields that are encoded are compared, so fields
+// tagged `bin:"-"`, unexported fields and reserved fields are ignored, nil and
+// empty slices and maps are equal, floats are compared bit for bit (like their
+// encodings), times are compared in the unit of their tags,
+// and big integers by value, a non-optional nil *big.Int being zero.
+// Values of types with a MarshalWithEncoder method are compared by their
+// Bin encodings.
+//
+// It's meant for caches keyed by the encoded identity of values, which can
+// then avoid encoding values to compare them. Like the struct tags it follows,
+// it works by reflection; there is no code generation in this package.
+func EqualWire(a, b interface{}) bool {
+	ra, rb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !ra.IsValid() || !rb.IsValid() {
+		return ra.IsValid() == rb.IsValid()
+	}
+	if ra.Type() != rb.Type() {
+		return false
+	}
+	return equalWire(ra, rb, newDefaultOption())
+}
+
+func equalWire(a, b reflect.Value, opt *option) bool {
+	rt := a.Type()
+	if rt.Implements(marshalableType) {
+		return equalEncoded(a, b, opt)
+	}
+	switch {
+	case rt == timeType, rt == ipType, rt == hardwareAddrType:
+		return equalEncoded(a, b, opt)
+	case opt.StrLen > 0 && a.Kind() == reflect.String:
+		// Fixed-length strings may be truncated.
+		return equalEncoded(a, b, opt)
+	case rt == bigIntType, rt == bigIntPtrType && !opt.is_Optional():
+		// Non-optional nil *big.Int values are encoded as zero.
+		x, _ := bigIntValue(a)
+		y, _ := bigIntValue(b)
+		return x.Cmp(y) == 0
+	}
+
+	switch a.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		if a.Kind() == reflect.Interface && a.Elem().Type() != b.Elem().Type() {
+			return false
+		}
+		return equalWire(a.Elem(), b.Elem(), opt)
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return math.Float64bits(a.Float()) == math.Float64bits(b.Float())
+	case reflect.Complex64, reflect.Complex128:
+		ca, cb := a.Complex(), b.Complex()
+		return math.Float64bits(real(ca)) == math.Float64bits(real(cb)) &&
+			math.Float64bits(imag(ca)) == math.Float64bits(imag(cb))
+	case reflect.String:
+		return a.String() == b.String()
+	case reflect.Slice, reflect.Array:
+		if a.Len() != b.Len() {
+			return false
+		}
+		if rt.Elem().Kind() == reflect.Uint8 && a.Kind() == reflect.Slice {
+			return bytes.Equal(a.Bytes(), b.Bytes())
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equalWire(a.Index(i), b.Index(i), newDefaultOption()) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for _, key := range a.MapKeys() {
+			vb := b.MapIndex(key)
+			if !vb.IsValid() || !equalWire(a.MapIndex(key), vb, newDefaultOption()) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		plan := planOf(rt)
+		for i, structField := range plan.fields {
+			fieldTag := plan.tags[i]
+			if !isWireField(structField, fieldTag) {
+				continue
+			}
+			fieldOpt := plan.fieldOption(i, EncodingBin, nil)
+			if fieldTag.COption || fieldTag.Compress || fieldTag.Encrypt {
+				// Borsh `coption` fields are optional too, and the values are
+				// compared before compression and encryption.
+				fieldOpt = fieldOpt.clone().set_Optional(fieldTag.Option || fieldTag.COption)
+				fieldOpt.Compress, fieldOpt.Encrypt = false, false
+			}
+			if !equalWire(plan.field(a, i), plan.field(b, i), fieldOpt) {
+				return false
+			}
+		}
+		return true
+	default:
+		return equalEncoded(a, b, opt)
+	}
+}
+
+// isWireField reports whether a struct field is encoded from its value.
+func isWireField(structField reflect.StructField, fieldTag *fieldTag) bool {
+	return !fieldTag.Skip && !fieldTag.SkipEncode && fieldTag.Reserved == 0 && structField.PkgPath == ""
+}
+
+// equalEncoded compares the Bin encodings of two values, with the provided option.
+func equalEncoded(a, b reflect.Value, opt *option) bool {
+	encode := func(rv reflect.Value) ([]byte, bool) {
+		buf := new(bytes.Buffer)
+		// The encoder changes the option of optional values.
+		if err := NewBinEncoder(buf).encodeBin(rv, opt.clone()); err != nil {
+			return nil, false
+		}
+		return buf.Bytes(), true
+	}
+	ea, okA := encode(a)
+	eb, okB := encode(b)
+	return okA && okB && bytes.Equal(ea, eb)
+}
+
+// CloneWire returns a deep copy of v holding only its wire content: the
+// fields that are not encoded (see EqualWire) are left to their zero value.
+// Values of types with a MarshalWithEncoder method, and times,
+// are copied as they are (a shallow copy).
+func CloneWire(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return nil
+	}
+	out := reflect.New(rv.Type()).Elem()
+	cloneWire(out, rv)
+	return out.Interface()
+}
+
+func cloneWire(dst, src reflect.Value) {
+	rt := src.Type()
+	if rt.Implements(marshalableType) || rt == timeType || hasStdBinaryMarshaler(rt) {
+		dst.Set(src)
+		return
+	}
+	if rt == bigIntType {
+		x, _ := bigIntValue(src)
+		dst.Addr().Interface().(*big.Int).Set(x)
+		return
+	}
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.New(rt.Elem()))
+		cloneWire(dst.Elem(), src.Elem())
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		elem := reflect.New(src.Elem().Type()).Elem()
+		cloneWire(elem, src.Elem())
+		dst.Set(elem)
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeSlice(rt, src.Len(), src.Len()))
+		if rt.Elem().Kind() == reflect.Uint8 {
+			reflect.Copy(dst, src)
+			return
+		}
+		for i := 0; i < src.Len(); i++ {
+			cloneWire(dst.Index(i), src.Index(i))
+		}
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			cloneWire(dst.Index(i), src.Index(i))
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeMapWithSize(rt, src.Len()))
+		for _, key := range src.MapKeys() {
+			k := reflect.New(rt.Key()).Elem()
+			cloneWire(k, key)
+			v := reflect.New(rt.Elem()).Elem()
+			cloneWire(v, src.MapIndex(key))
+			dst.SetMapIndex(k, v)
+		}
+	case reflect.Struct:
+		plan := planOf(rt)
+		for i, structField := range plan.fields {
+			if isWireField(structField, plan.tags[i]) {
+				cloneWire(plan.field(dst, i), plan.field(src, i))
+			}
+		}
+	default:
+		dst.Set(src)
+	}
+}
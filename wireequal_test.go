@@ -0,0 +1,104 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type wireOrder struct {
+	ID      uint64
+	Price   float64
+	Items   []string
+	Labels  map[string]int32
+	Created time.Time `bin:"time=unix"`
+	Note    *string   `bin:"optional"`
+	Amount  Uint128
+	Cache   []byte `bin:"-"`
+	seen    bool
+}
+
+func TestEqualWire(t *testing.T) {
+	note := "n"
+	a := wireOrder{
+		ID:      1,
+		Price:   2.5,
+		Items:   []string{},
+		Created: time.Unix(100, 5),
+		Note:    &note,
+		Amount:  Uint128{Lo: 1},
+		Cache:   []byte{1},
+		seen:    true,
+	}
+	other := "n"
+	b := wireOrder{
+		ID:      1,
+		Price:   2.5,
+		Labels:  map[string]int32{},
+		Created: time.Unix(100, 9).In(time.FixedZone("", 3600)),
+		Note:    &other,
+		Amount:  Uint128{Lo: 1},
+	}
+	assert.True(t, EqualWire(a, b))
+	assert.True(t, EqualWire(&a, &b))
+
+	changes := []func(o *wireOrder){
+		func(o *wireOrder) { o.ID = 2 },
+		func(o *wireOrder) { o.Price = math.Copysign(0, -1) },
+		func(o *wireOrder) { o.Items = []string{"x"} },
+		func(o *wireOrder) { o.Created = time.Unix(101, 0) },
+		func(o *wireOrder) { o.Note = nil },
+		func(o *wireOrder) { o.Amount = Uint128{Hi: 1} },
+	}
+	for i, change := range changes {
+		c := b
+		change(&c)
+		assert.False(t, EqualWire(a, c), i)
+	}
+
+	nan := math.NaN()
+	assert.True(t, EqualWire(nan, nan))
+	assert.False(t, EqualWire(uint8(1), int8(1)))
+}
+
+func TestCloneWire(t *testing.T) {
+	note := "n"
+	in := wireOrder{
+		ID:     1,
+		Items:  []string{"a"},
+		Labels: map[string]int32{"x": 1},
+		Note:   &note,
+		Cache:  []byte{1},
+		seen:   true,
+	}
+	out := CloneWire(in).(wireOrder)
+	assert.True(t, EqualWire(in, out))
+	assert.Nil(t, out.Cache)
+	assert.False(t, out.seen)
+
+	out.Items[0] = "b"
+	out.Labels["x"] = 2
+	*out.Note = "m"
+	assert.Equal(t, "a", in.Items[0])
+	assert.Equal(t, int32(1), in.Labels["x"])
+	assert.Equal(t, "n", *in.Note)
+
+	ptr := CloneWire(&in).(*wireOrder)
+	assert.True(t, EqualWire(in, *ptr))
+}
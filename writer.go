@@ -0,0 +1,127 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// A Writer is the counterpart of Reader: it writes values one after
+// the other, e.g. to handcraft a message in a test:
+//
+//	w := bin.NewWriter()
+//	w.U8(1).U32LE(5).Bytes(sig).Str(name)
+//	out, err := w.Result()
+//
+// Errors are deferred: after the first failed write, the following ones
+// are no-ops, and Result and Err return that first error.
+type Writer struct {
+	buf *bytes.Buffer
+	enc *Encoder
+	err error
+}
+
+// NewWriter returns an empty Writer.
+func NewWriter() *Writer {
+	buf := new(bytes.Buffer)
+	return &Writer{buf: buf, enc: NewBinEncoder(buf)}
+}
+
+// Err returns the first error encountered by the writer, if any.
+func (w *Writer) Err() error {
+	return w.err
+}
+
+// Len returns the number of bytes written so far.
+func (w *Writer) Len() int {
+	return w.buf.Len()
+}
+
+// Result returns the written bytes, or the first error encountered.
+func (w *Writer) Result() ([]byte, error) {
+	if w.err != nil {
+		return nil, w.err
+	}
+	return w.buf.Bytes(), nil
+}
+
+// do runs write unless an error was already encountered, and records its error.
+func (w *Writer) do(write func() error) *Writer {
+	if w.err != nil {
+		return w
+	}
+	offset := w.buf.Len()
+	if err := write(); err != nil {
+		w.err = fmt.Errorf("write at offset %d: %w", offset, err)
+	}
+	return w
+}
+
+func (w *Writer) U8(v uint8) *Writer {
+	return w.do(func() error { return w.enc.WriteUint8(v) })
+}
+
+func (w *Writer) Bool(v bool) *Writer {
+	return w.do(func() error { return w.enc.WriteBool(v) })
+}
+
+func (w *Writer) u16(v uint16, order binary.ByteOrder) *Writer {
+	return w.do(func() error { return w.enc.WriteUint16(v, order) })
+}
+
+func (w *Writer) u32(v uint32, order binary.ByteOrder) *Writer {
+	return w.do(func() error { return w.enc.WriteUint32(v, order) })
+}
+
+func (w *Writer) u64(v uint64, order binary.ByteOrder) *Writer {
+	return w.do(func() error { return w.enc.WriteUint64(v, order) })
+}
+
+func (w *Writer) U16LE(v uint16) *Writer { return w.u16(v, LE) }
+func (w *Writer) U16BE(v uint16) *Writer { return w.u16(v, BE) }
+func (w *Writer) U32LE(v uint32) *Writer { return w.u32(v, LE) }
+func (w *Writer) U32BE(v uint32) *Writer { return w.u32(v, BE) }
+func (w *Writer) U64LE(v uint64) *Writer { return w.u64(v, LE) }
+func (w *Writer) U64BE(v uint64) *Writer { return w.u64(v, BE) }
+
+func (w *Writer) I8(v int8) *Writer     { return w.U8(uint8(v)) }
+func (w *Writer) I16LE(v int16) *Writer { return w.u16(uint16(v), LE) }
+func (w *Writer) I16BE(v int16) *Writer { return w.u16(uint16(v), BE) }
+func (w *Writer) I32LE(v int32) *Writer { return w.u32(uint32(v), LE) }
+func (w *Writer) I32BE(v int32) *Writer { return w.u32(uint32(v), BE) }
+func (w *Writer) I64LE(v int64) *Writer { return w.u64(uint64(v), LE) }
+func (w *Writer) I64BE(v int64) *Writer { return w.u64(uint64(v), BE) }
+
+// Uvarint writes an unsigned varint.
+func (w *Writer) Uvarint(v uint64) *Writer {
+	return w.do(func() error { return w.enc.WriteUvarint64(v) })
+}
+
+// Varint writes a zigzag-encoded signed varint.
+func (w *Writer) Varint(v int64) *Writer {
+	return w.do(func() error { return w.enc.WriteVarint64(v) })
+}
+
+// Bytes writes the provided bytes as they are, without a length prefix.
+func (w *Writer) Bytes(b []byte) *Writer {
+	return w.do(func() error { return w.enc.WriteBytes(b, false) })
+}
+
+// Str writes a string prefixed by its length as a uvarint, like the Bin encoding does.
+func (w *Writer) Str(s string) *Writer {
+	return w.do(func() error { return w.enc.WriteString(s) })
+}
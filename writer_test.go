@@ -0,0 +1,58 @@
+// Copyright 2021 github.com/gagliardetto
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	w := NewWriter()
+	w.U8(1).U16LE(2).U32BE(3).I64LE(-1).Uvarint(300).Varint(-2).Bytes([]byte{0xAA, 0xBB}).Bool(true).Str("hi")
+	out, err := w.Result()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x01,
+		0x02, 0x00,
+		0x00, 0x00, 0x00, 0x03,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		0xAC, 0x02,
+		0x03,
+		0xAA, 0xBB,
+		0x01,
+		0x02, 'h', 'i',
+	}, out)
+
+	r := NewReader(out)
+	r.Skip(21)
+	assert.Equal(t, "hi", r.Str())
+	require.NoError(t, r.Err())
+}
+
+func TestWriter_DeferredError(t *testing.T) {
+	w := NewWriter()
+	w.enc.WithMaxEncodedSize(3)
+	w.U16LE(1).U32LE(2).U8(3)
+
+	out, err := w.Result()
+	require.Error(t, err)
+	assert.Nil(t, out)
+	assert.Contains(t, err.Error(), "write at offset 2")
+	assert.Equal(t, err, w.Err())
+	assert.Equal(t, 2, w.Len())
+}